@@ -42,8 +42,6 @@ func MakeApplicationWithDB() (*vbeam.Application, *vbolt.DB) {
 
 	db := OpenDB(cfg.DBPath)
 
-	// Reset viewer counts on startup (SSE connections don't persist across restarts)
-	backend.ResetAllCurrentViewers(db)
 	backend.ResetAllRoomStreaming(db)
 
 	// Initialize transcoder for ABR HLS
@@ -52,20 +50,45 @@ func MakeApplicationWithDB() (*vbeam.Application, *vbolt.DB) {
 		SRSRTMPBase: cfg.SRSRTMPBase,
 	})
 
+	// Share SSE broadcasts and viewer presence between instances (optional,
+	// in-process by default). Before the background jobs, so the presence
+	// heartbeat starts on the shared store.
+	if err := backend.SetupEventBus(); err != nil {
+		log.Printf("Event bus setup failed, broadcasts stay on this instance: %v", err)
+	}
+
 	// Start background jobs
 	backend.StartOldCodeCleanup(db)
 	backend.StartExpiredCodeHandler(db)
 	backend.StartMonthlyAnalyticsReset(db)
 	backend.StartViewerSessionCleanup(db)
+	backend.StartViewerPresence(db)
 	backend.StartClassScheduler(db)
+	backend.StartSecurityEventCleanup(db)
+	backend.StartChatArchiveCleanup(db)
+
+	// Configure outgoing email (optional in development)
+	if err := backend.SetupMailer(); err != nil {
+		log.Printf("Mailer setup failed: %v", err)
+	}
 
 	var app = vbeam.NewApplication("Stream", db)
 
 	backend.SetupAuth(app)
+	backend.StartJWTKeyRotation(db)
 	backend.RegisterUserMethods(app)
+	backend.RegisterAccountTokenMethods(app)
+	backend.RegisterTwoFactorMethods(app)
+	backend.RegisterSessionMethods(app)
+	backend.RegisterJWTKeyMethods(app)
+	backend.RegisterSecurityEventMethods(app)
+	backend.RegisterAccountDataMethods(app)
 	backend.RegisterRoleMethods(app)
 	backend.RegisterStudioMethods(app)
 	backend.RegisterStudioMembershipMethods(app)
+	backend.RegisterStudioInvitationMethods(app)
+	backend.RegisterStudioOwnershipMethods(app)
+	backend.RegisterStudioCapabilityMethods(app)
 	backend.RegisterCodeAccessMethods(app)
 	backend.RegisterCameraConfigMethods(app)
 	backend.RegisterIngestMethods(app)
@@ -74,10 +97,22 @@ func MakeApplicationWithDB() (*vbeam.Application, *vbolt.DB) {
 	backend.RegisterAdminMethods(app)
 	backend.RegisterEmoteMethods(app)
 	backend.RegisterChatMethods(app)
+	backend.RegisterChatModerationMethods(app)
+	backend.RegisterChatFilterMethods(app)
+	backend.RegisterChatArchiveMethods(app)
+	backend.RegisterChatReactionMethods(app)
+	backend.RegisterChatPinMethods(app)
+	backend.RegisterStudioEmoteMethods(app)
+	backend.RegisterPollMethods(app)
+	backend.RegisterClassQuestionMethods(app)
 	backend.RegisterClassScheduleMethods(app)
 	backend.RegisterClassPermissionMethods(app)
+	backend.RegisterTicketingMethods(app)
+	backend.RegisterInviteMethods(app)
+	backend.RegisterWatchAuditMethods(app)
 	backend.RegisterRoomStreamProxy(app)
 	backend.RegisterHLSFileServer(app)
+	backend.RegisterEmoteFileServer(app)
 
 	// Transcoder health check
 	vbeam.RegisterProc(app, backend.GetTranscoderHealth)
@@ -1,8 +1,10 @@
 package backend
 
 import (
+	"errors"
 	"time"
 
+	"go.hasen.dev/vbeam"
 	"go.hasen.dev/vbolt"
 )
 
@@ -55,17 +57,8 @@ func CheckClassPermission(tx *vbolt.Tx, userId int, roomId int) RoomAccessResult
 			continue
 		}
 
-		// Get the time window when the class is active (includes pre/post-roll)
-		startWindow, endWindow := getScheduleTimeWindow(&schedule, now)
-		if startWindow.IsZero() {
-			continue // Schedule not active today
-		}
-
-		// Add grace period to end window
-		endWithGrace := endWindow.Add(CLASS_GRACE_PERIOD)
-
-		// Check if current time falls within the window (including grace period)
-		if now.After(startWindow) && now.Before(endWithGrace) {
+		// Check if current time falls within the class window (including grace period)
+		if scheduleWindowOpen(&schedule, now) {
 			// Access granted via class permission
 			return RoomAccessResult{
 				Allowed:     true,
@@ -80,6 +73,35 @@ func CheckClassPermission(tx *vbolt.Tx, userId int, roomId int) RoomAccessResult
 	return RoomAccessResult{Allowed: false}
 }
 
+// scheduleWindowOpen reports whether now falls within the schedule's class window
+// (pre/post-roll from getScheduleTimeWindow plus CLASS_GRACE_PERIOD)
+func scheduleWindowOpen(schedule *ClassSchedule, now time.Time) bool {
+	startWindow, endWindow := getScheduleTimeWindow(schedule, now)
+	if startWindow.IsZero() {
+		return false // Schedule not active today
+	}
+
+	endWithGrace := endWindow.Add(CLASS_GRACE_PERIOD)
+	return now.After(startWindow) && now.Before(endWithGrace)
+}
+
+// codeScheduleWindowOpen reports whether a schedule-bound code may admit viewers now.
+// Codes without a schedule are always open; codes whose schedule was deleted or
+// deactivated are always closed.
+func codeScheduleWindowOpen(tx *vbolt.Tx, accessCode AccessCode, now time.Time) bool {
+	if accessCode.ScheduleId == 0 {
+		return true
+	}
+
+	var schedule ClassSchedule
+	vbolt.Read(tx, ClassSchedulesBkt, accessCode.ScheduleId, &schedule)
+	if schedule.Id == 0 || !schedule.IsActive {
+		return false
+	}
+
+	return scheduleWindowOpen(&schedule, now)
+}
+
 // CheckRoomAccess is the single source of truth for room access permissions
 // Handles all access types: anonymous code sessions, logged-in code sessions,
 // class permissions, studio membership, and site admin privileges
@@ -157,6 +179,16 @@ func CheckRoomAccess(tx *vbolt.Tx, user User, roomId int, anonymousSessionToken
 	}
 }
 
+// requireRoomAccess refuses callers of a room's procs who cannot view the
+// room, such as non-members or code sessions scoped to another room
+func requireRoomAccess(ctx *vbeam.Context, user User, roomId int) error {
+	access := CheckRoomAccess(ctx.Tx, user, roomId, codeSessionTokenFromContext(ctx))
+	if !access.Allowed {
+		return errors.New(access.DenialReason)
+	}
+	return nil
+}
+
 // checkCodeAccessForRoom checks if a user has code-based access to a specific room
 // Handles both anonymous (userId=-1) and logged-in (userId>0) users
 func checkCodeAccessForRoom(tx *vbolt.Tx, userId int, anonymousSessionToken string, roomId int, studioId int) RoomAccessResult {
@@ -205,6 +237,11 @@ func checkCodeAccessForRoom(tx *vbolt.Tx, userId int, anonymousSessionToken stri
 		return RoomAccessResult{Allowed: false}
 	}
 
+	// Schedule-bound codes only work during the class
+	if !codeScheduleWindowOpen(tx, accessCode, time.Now()) {
+		return RoomAccessResult{Allowed: false}
+	}
+
 	// Access granted via code
 	return RoomAccessResult{
 		Allowed:       true,
@@ -0,0 +1,404 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DELETED_USER_NAME replaces the author name on chat messages from deleted accounts
+const DELETED_USER_NAME = "Deleted User"
+
+func RegisterAccountDataMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, ExportAccountData)
+	vbeam.RegisterProc(app, DeleteAccount)
+}
+
+// Request/Response types
+type ExportedMembership struct {
+	StudioId   int        `json:"studioId"`
+	StudioName string     `json:"studioName"`
+	Role       StudioRole `json:"role"`
+	RoleName   string     `json:"roleName"`
+	JoinedAt   time.Time  `json:"joinedAt"`
+}
+
+type ExportedClassPermission struct {
+	Permission   ClassPermission `json:"permission"`
+	ScheduleName string          `json:"scheduleName"`
+}
+
+type ExportAccountDataResponse struct {
+	ExportedAt       time.Time                 `json:"exportedAt"`
+	Profile          User                      `json:"profile"`
+	Memberships      []ExportedMembership      `json:"memberships"`
+	ClassPermissions []ExportedClassPermission `json:"classPermissions"`
+	ChatMessages     []ChatMessage             `json:"chatMessages"`
+	ViewerSessions   []ViewerSession           `json:"viewerSessions"`
+	ChatRestrictions []ChatRestriction         `json:"chatRestrictions"`
+	FilteredChat     []ChatFilterEntry         `json:"filteredChat"`
+	ClassQuestions   []ClassQuestion           `json:"classQuestions"`
+	PollVotes        []PollVote                `json:"pollVotes"`
+	Devices          []DeviceSession           `json:"devices"`
+}
+
+type StudioTransfer struct {
+	StudioId   int `json:"studioId"`
+	NewOwnerId int `json:"newOwnerId"`
+}
+
+type DeleteAccountRequest struct {
+	Password     string           `json:"password"` // not needed for accounts without one
+	ConfirmEmail string           `json:"confirmEmail"`
+	Transfers    []StudioTransfer `json:"transfers"` // one per studio the caller owns
+}
+
+type DeleteAccountResponse struct {
+	Success            bool `json:"success"`
+	StudiosTransferred int  `json:"studiosTransferred"`
+}
+
+func userViewerId(userId int) string {
+	return "user:" + strconv.Itoa(userId)
+}
+
+// userMembershipIdsTx returns the ids of every studio membership the user holds
+func userMembershipIdsTx(tx *vbolt.Tx, userId int) (membershipIds []int) {
+	vbolt.ReadTermTargets(tx, MembershipByUserIdx, userId, &membershipIds, vbolt.Window{})
+	return
+}
+
+// userChatMessagesTx returns the user's chat messages across all rooms, oldest first
+func userChatMessagesTx(tx *vbolt.Tx, userId int) (messages []ChatMessage) {
+	var messageIds []int
+	vbolt.ReadTermTargets(tx, ChatByUserIdx, userId, &messageIds, vbolt.Window{})
+	messages = make([]ChatMessage, 0, len(messageIds))
+	for _, msgId := range messageIds {
+		var msg ChatMessage
+		vbolt.Read(tx, ChatMessagesBkt, msgId, &msg)
+		if msg.Id != 0 && msg.UserId == userId {
+			messages = append(messages, msg)
+		}
+	}
+	return
+}
+
+// userViewerSessionsTx returns the viewer sessions recorded while the user was signed in
+func userViewerSessionsTx(tx *vbolt.Tx, userId int) (sessions []ViewerSession) {
+	var sessionKeys []string
+	vbolt.ReadTermTargets(tx, SessionsByViewerIndex, userViewerId(userId), &sessionKeys, vbolt.Window{})
+	sessions = make([]ViewerSession, 0, len(sessionKeys))
+	for _, key := range sessionKeys {
+		var session ViewerSession
+		vbolt.Read(tx, ViewerSessionsBkt, key, &session)
+		if session.SessionKey != "" {
+			sessions = append(sessions, session)
+		}
+	}
+	return
+}
+
+// userChatRestrictionsTx returns the timeouts and bans placed on the user in any studio
+func userChatRestrictionsTx(tx *vbolt.Tx, userId int) (restrictions []ChatRestriction) {
+	var ids []int
+	vbolt.ReadTermTargets(tx, ChatRestrictionsByViewerIdx, userViewerId(userId), &ids, vbolt.Window{})
+	restrictions = make([]ChatRestriction, 0, len(ids))
+	for _, id := range ids {
+		var restriction ChatRestriction
+		vbolt.Read(tx, ChatRestrictionsBkt, id, &restriction)
+		if restriction.Id != 0 {
+			restrictions = append(restrictions, restriction)
+		}
+	}
+	return
+}
+
+// userFilteredChatTx returns the user's messages the chat filter held or blocked
+func userFilteredChatTx(tx *vbolt.Tx, userId int) (entries []ChatFilterEntry) {
+	var ids []int
+	vbolt.ReadTermTargets(tx, ChatFilterLogByUserIdx, userId, &ids, vbolt.Window{})
+	entries = make([]ChatFilterEntry, 0, len(ids))
+	for _, id := range ids {
+		var entry ChatFilterEntry
+		vbolt.Read(tx, ChatFilterLogBkt, id, &entry)
+		if entry.Id != 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return
+}
+
+// userClassQuestionsTx returns the questions the user asked during classes
+func userClassQuestionsTx(tx *vbolt.Tx, userId int) (questions []ClassQuestion) {
+	var ids []int
+	vbolt.ReadTermTargets(tx, ClassQuestionsByViewerIdx, userViewerId(userId), &ids, vbolt.Window{})
+	questions = make([]ClassQuestion, 0, len(ids))
+	for _, id := range ids {
+		var question ClassQuestion
+		vbolt.Read(tx, ClassQuestionsBkt, id, &question)
+		if question.Id != 0 {
+			questions = append(questions, question)
+		}
+	}
+	return
+}
+
+// userPollVotesTx returns the user's poll votes
+func userPollVotesTx(tx *vbolt.Tx, userId int) (votes []PollVote) {
+	var keys []string
+	vbolt.ReadTermTargets(tx, PollVotesByViewerIdx, userViewerId(userId), &keys, vbolt.Window{})
+	votes = make([]PollVote, 0, len(keys))
+	for _, key := range keys {
+		var vote PollVote
+		if vbolt.Read(tx, PollVotesBkt, key, &vote) {
+			votes = append(votes, vote)
+		}
+	}
+	return
+}
+
+// ExportAccountData returns everything stored about the caller as a single
+// JSON document
+func ExportAccountData(ctx *vbeam.Context, req Empty) (resp ExportAccountDataResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	resp.ExportedAt = time.Now()
+	resp.Profile = user
+
+	resp.Memberships = []ExportedMembership{}
+	for _, membershipId := range userMembershipIdsTx(ctx.Tx, user.Id) {
+		membership := GetMembership(ctx.Tx, membershipId)
+		if membership.UserId != user.Id {
+			continue
+		}
+		resp.Memberships = append(resp.Memberships, ExportedMembership{
+			StudioId:   membership.StudioId,
+			StudioName: GetStudioById(ctx.Tx, membership.StudioId).Name,
+			Role:       membership.Role,
+			RoleName:   GetStudioRoleName(membership.Role),
+			JoinedAt:   membership.JoinedAt,
+		})
+	}
+
+	resp.ClassPermissions = []ExportedClassPermission{}
+	var permIds []int
+	vbolt.ReadTermTargets(ctx.Tx, PermsByUserIdx, user.Id, &permIds, vbolt.Window{})
+	for _, permId := range permIds {
+		var perm ClassPermission
+		vbolt.Read(ctx.Tx, ClassPermissionsBkt, permId, &perm)
+		if perm.Id == 0 {
+			continue
+		}
+		var schedule ClassSchedule
+		vbolt.Read(ctx.Tx, ClassSchedulesBkt, perm.ScheduleId, &schedule)
+		resp.ClassPermissions = append(resp.ClassPermissions, ExportedClassPermission{
+			Permission:   perm,
+			ScheduleName: schedule.Name,
+		})
+	}
+
+	resp.ChatMessages = userChatMessagesTx(ctx.Tx, user.Id)
+	resp.ViewerSessions = userViewerSessionsTx(ctx.Tx, user.Id)
+	resp.ChatRestrictions = userChatRestrictionsTx(ctx.Tx, user.Id)
+	resp.FilteredChat = userFilteredChatTx(ctx.Tx, user.Id)
+	resp.ClassQuestions = userClassQuestionsTx(ctx.Tx, user.Id)
+	resp.PollVotes = userPollVotesTx(ctx.Tx, user.Id)
+
+	sessions, _ := ListSessions(ctx, Empty{})
+	resp.Devices = sessions.Sessions
+
+	LogInfo(LogCategoryAuth, "Account data exported", map[string]interface{}{
+		"userId":       user.Id,
+		"chatMessages": len(resp.ChatMessages),
+	})
+	return
+}
+
+// DeleteAccount permanently removes the caller's account. Studios the caller
+// owns must be handed to another member first; their chat messages stay in
+// the room history under DELETED_USER_NAME.
+func DeleteAccount(ctx *vbeam.Context, req DeleteAccountRequest) (resp DeleteAccountResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(req.ConfirmEmail), user.Email) {
+		return resp, errors.New("Type your email address to confirm")
+	}
+	if hash := GetPassHash(ctx.Tx, user.Id); len(hash) > 0 {
+		if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil {
+			return resp, errors.New("Incorrect password")
+		}
+	}
+
+	if user.Role == RoleSiteAdmin {
+		otherAdmin := false
+		vbolt.IterateAll(ctx.Tx, UsersBkt, func(id int, other User) bool {
+			otherAdmin = other.Id != user.Id && other.Role == RoleSiteAdmin
+			return !otherAdmin
+		})
+		if !otherAdmin {
+			return resp, errors.New("Make someone else a site admin before deleting your account")
+		}
+	}
+
+	newOwners := make(map[int]int)
+	for _, transfer := range req.Transfers {
+		newOwners[transfer.StudioId] = transfer.NewOwnerId
+	}
+
+	membershipIds := userMembershipIdsTx(ctx.Tx, user.Id)
+	var owned []Studio
+	for _, membershipId := range membershipIds {
+		studio := GetStudioById(ctx.Tx, GetMembership(ctx.Tx, membershipId).StudioId)
+		if studio.Id == 0 || studio.OwnerId != user.Id {
+			continue
+		}
+		newOwnerId := newOwners[studio.Id]
+		if newOwnerId == 0 {
+			return resp, fmt.Errorf("Choose a new owner for %s or delete the studio first", studio.Name)
+		}
+		if newOwnerId == user.Id || GetUserStudioRole(ctx.Tx, newOwnerId, studio.Id) == -1 {
+			return resp, fmt.Errorf("The new owner of %s must be a member of the studio", studio.Name)
+		}
+		owned = append(owned, studio)
+	}
+
+	var permIds []int
+	vbolt.ReadTermTargets(ctx.Tx, PermsByUserIdx, user.Id, &permIds, vbolt.Window{})
+	messages := userChatMessagesTx(ctx.Tx, user.Id)
+	viewerSessions := userViewerSessionsTx(ctx.Tx, user.Id)
+	restrictions := userChatRestrictionsTx(ctx.Tx, user.Id)
+	filteredChat := userFilteredChatTx(ctx.Tx, user.Id)
+	questions := userClassQuestionsTx(ctx.Tx, user.Id)
+	votes := userPollVotesTx(ctx.Tx, user.Id)
+
+	vbeam.UseWriteTx(ctx)
+
+	// 1. Hand over owned studios
+	for _, studio := range owned {
+		transferStudioOwnershipTx(ctx.Tx, studio, newOwners[studio.Id])
+		recordOwnershipTransferTx(ctx.Tx, OwnershipTransfer{
+			StudioId:   studio.Id,
+			FromUserId: user.Id,
+			ToUserId:   newOwners[studio.Id],
+			Status:     OwnershipTransferAssigned,
+		})
+		LogInfo(LogCategorySystem, "Studio ownership transferred", map[string]interface{}{
+			"studioId":   studio.Id,
+			"studioName": studio.Name,
+			"fromUserId": user.Id,
+			"toUserId":   newOwners[studio.Id],
+		})
+	}
+
+	// 2. Remove memberships and class permissions
+	for _, membershipId := range membershipIds {
+		vbolt.SetTargetSingleTerm(ctx.Tx, MembershipByUserIdx, membershipId, -1)
+		vbolt.SetTargetSingleTerm(ctx.Tx, MembershipByStudioIdx, membershipId, -1)
+		vbolt.Delete(ctx.Tx, MembershipBkt, membershipId)
+	}
+	for _, permId := range permIds {
+		vbolt.SetTargetSingleTerm(ctx.Tx, PermsByScheduleIdx, permId, -1)
+		vbolt.SetTargetSingleTerm(ctx.Tx, PermsByUserIdx, permId, -1)
+		vbolt.Delete(ctx.Tx, ClassPermissionsBkt, permId)
+	}
+
+	// 3. Anonymize chat messages
+	for _, msg := range messages {
+		msg.UserId = 0
+		msg.UserName = DELETED_USER_NAME
+		vbolt.Write(ctx.Tx, ChatMessagesBkt, msg.Id, &msg)
+		vbolt.SetTargetSingleTerm(ctx.Tx, ChatByUserIdx, msg.Id, -1)
+	}
+
+	// 4. Delete viewer sessions (live viewer counts are decremented when the
+	// stream connection closes)
+	for _, session := range viewerSessions {
+		vbolt.SetTargetSingleTerm(ctx.Tx, SessionsByRoomIndex, session.SessionKey, -1)
+		vbolt.SetTargetSingleTerm(ctx.Tx, SessionsByCodeIndex, session.SessionKey, "")
+		vbolt.SetTargetSingleTerm(ctx.Tx, SessionsByViewerIndex, session.SessionKey, "")
+		vbolt.Delete(ctx.Tx, ViewerSessionsBkt, session.SessionKey)
+	}
+
+	// Timeouts and bans carry the user's name and the moderator's reason
+	for _, restriction := range restrictions {
+		vbolt.SetTargetSingleTerm(ctx.Tx, ChatRestrictionsByStudioIdx, restriction.Id, -1)
+		vbolt.SetTargetSingleTerm(ctx.Tx, ChatRestrictionsByViewerIdx, restriction.Id, "")
+		vbolt.Delete(ctx.Tx, ChatRestrictionsBkt, restriction.Id)
+	}
+
+	// Filtered messages were never posted, so they go rather than being
+	// anonymized like the chat history
+	for _, entry := range filteredChat {
+		vbolt.SetTargetSingleTerm(ctx.Tx, ChatFilterLogByStudioIdx, entry.Id, -1)
+		vbolt.SetTargetSingleTerm(ctx.Tx, ChatFilterLogByUserIdx, entry.Id, -1)
+		vbolt.Delete(ctx.Tx, ChatFilterLogBkt, entry.Id)
+	}
+
+	// Class questions stay with the class like chat does; poll votes go, but
+	// the tallies they were counted in are kept
+	for _, question := range questions {
+		vbolt.SetTargetSingleTerm(ctx.Tx, ClassQuestionsByViewerIdx, question.Id, "")
+		question.UserId = 0
+		question.ViewerId = ""
+		question.UserName = DELETED_USER_NAME
+		vbolt.Write(ctx.Tx, ClassQuestionsBkt, question.Id, &question)
+	}
+	for _, vote := range votes {
+		key := pollVoteKey(vote.PollId, vote.ViewerId)
+		vbolt.SetTargetSingleTerm(ctx.Tx, PollVotesByPollIdx, key, -1)
+		vbolt.SetTargetSingleTerm(ctx.Tx, PollVotesByViewerIdx, key, "")
+		vbolt.Delete(ctx.Tx, PollVotesBkt, key)
+	}
+
+	// 5. Revoke sessions and delete sign-in data
+	DeleteUserRefreshTokens(ctx.Tx, user.Id)
+	vbolt.Delete(ctx.Tx, UserCodeSessionsBkt, user.Id)
+
+	var tokenHashes []string
+	vbolt.ReadTermTargets(ctx.Tx, AccountTokensByUserIdx, user.Id, &tokenHashes, vbolt.Window{})
+	for _, hash := range tokenHashes {
+		vbolt.SetTargetSingleTerm(ctx.Tx, AccountTokensByUserIdx, hash, -1)
+		vbolt.Delete(ctx.Tx, AccountTokensBkt, hash)
+	}
+
+	var identityKeys []string
+	vbolt.ReadTermTargets(ctx.Tx, OIDCIdentitiesByUserIdx, user.Id, &identityKeys, vbolt.Window{})
+	for _, key := range identityKeys {
+		vbolt.SetTargetSingleTerm(ctx.Tx, OIDCIdentitiesByUserIdx, key, -1)
+		vbolt.Delete(ctx.Tx, OIDCIdentitiesBkt, key)
+	}
+
+	vbolt.Delete(ctx.Tx, TwoFactorBkt, user.Id)
+
+	// 6. Delete the user itself
+	vbolt.Delete(ctx.Tx, EmailBkt, user.Email)
+	vbolt.Delete(ctx.Tx, PasswdBkt, user.Id)
+	vbolt.Delete(ctx.Tx, UsersBkt, user.Id)
+
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategoryAuth, "Account deleted", map[string]interface{}{
+		"userId":             user.Id,
+		"email":              user.Email,
+		"studiosTransferred": len(owned),
+		"membershipsDeleted": len(membershipIds),
+		"messagesAnonymized": len(messages),
+		"sessionsDeleted":    len(viewerSessions),
+	})
+
+	resp.Success = true
+	resp.StudiosTransferred = len(owned)
+	return
+}
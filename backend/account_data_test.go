@@ -0,0 +1,168 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestExportAndDeleteAccount(t *testing.T) {
+	db, admin, studio, room := setupTestStudioRoom(t)
+	parent := addTestStudioMember(t, db, studio.Id, "parent@test.com", StudioRoleOwner)
+	setTestPassword(t, db, parent.Id, "password123")
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+
+	sessionKey := userViewerId(parent.Id) + ":1"
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		msg := ChatMessage{RoomId: room.Id, UserId: parent.Id, UserName: "Parent", Text: "hi", Timestamp: time.Now()}
+		saveChatMessageTx(tx, &msg)
+
+		session := ViewerSession{SessionKey: sessionKey, ViewerId: userViewerId(parent.Id), RoomId: room.Id, FirstSeenAt: time.Now(), LastSeenAt: time.Now()}
+		vbolt.Write(tx, ViewerSessionsBkt, sessionKey, &session)
+		vbolt.SetTargetSingleTerm(tx, SessionsByRoomIndex, sessionKey, room.Id)
+		vbolt.SetTargetSingleTerm(tx, SessionsByViewerIndex, sessionKey, session.ViewerId)
+
+		restriction := ChatRestriction{Id: vbolt.NextIntId(tx, ChatRestrictionsBkt), StudioId: studio.Id, ViewerId: userViewerId(parent.Id), UserId: parent.Id, TargetName: "Parent", Kind: ChatRestrictionBan, Reason: "spam"}
+		vbolt.Write(tx, ChatRestrictionsBkt, restriction.Id, &restriction)
+		vbolt.SetTargetSingleTerm(tx, ChatRestrictionsByStudioIdx, restriction.Id, studio.Id)
+		vbolt.SetTargetSingleTerm(tx, ChatRestrictionsByViewerIdx, restriction.Id, restriction.ViewerId)
+
+		entry := ChatFilterEntry{Id: vbolt.NextIntId(tx, ChatFilterLogBkt), StudioId: studio.Id, RoomId: room.Id, UserId: parent.Id, UserName: "Parent", Text: "held", Status: ChatFilterStatusPending}
+		vbolt.Write(tx, ChatFilterLogBkt, entry.Id, &entry)
+		vbolt.SetTargetSingleTerm(tx, ChatFilterLogByStudioIdx, entry.Id, studio.Id)
+		vbolt.SetTargetSingleTerm(tx, ChatFilterLogByUserIdx, entry.Id, parent.Id)
+
+		question := ClassQuestion{Id: vbolt.NextIntId(tx, ClassQuestionsBkt), RoomId: room.Id, UserId: parent.Id, ViewerId: userViewerId(parent.Id), UserName: "Parent", Text: "Which piece next?"}
+		vbolt.Write(tx, ClassQuestionsBkt, question.Id, &question)
+		vbolt.SetTargetSingleTerm(tx, ClassQuestionsByRoomIdx, question.Id, room.Id)
+		vbolt.SetTargetSingleTerm(tx, ClassQuestionsByViewerIdx, question.Id, question.ViewerId)
+
+		vote := PollVote{PollId: 1, ViewerId: userViewerId(parent.Id), Option: 0}
+		voteKey := pollVoteKey(vote.PollId, vote.ViewerId)
+		vbolt.Write(tx, PollVotesBkt, voteKey, &vote)
+		vbolt.SetTargetSingleTerm(tx, PollVotesByPollIdx, voteKey, vote.PollId)
+		vbolt.SetTargetSingleTerm(tx, PollVotesByViewerIdx, voteKey, vote.ViewerId)
+
+		perm := ClassPermission{Id: vbolt.NextIntId(tx, ClassPermissionsBkt), ScheduleId: 1, UserId: parent.Id, Role: int(StudioRoleViewer), GrantedAt: time.Now()}
+		vbolt.Write(tx, ClassPermissionsBkt, perm.Id, &perm)
+		vbolt.SetTargetSingleTerm(tx, PermsByUserIdx, perm.Id, parent.Id)
+		vbolt.SetTargetSingleTerm(tx, PermsByScheduleIdx, perm.Id, perm.ScheduleId)
+		vbolt.TxCommit(tx)
+	})
+	parentToken, _ := createTestToken(parent.Id)
+
+	var export ExportAccountDataResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		export, err = ExportAccountData(&vbeam.Context{Tx: tx, Token: parentToken}, Empty{})
+	})
+	if err != nil || export.Profile.Email != parent.Email {
+		t.Fatalf("ExportAccountData failed: %v %+v", err, export.Profile)
+	}
+	if len(export.Memberships) != 1 || export.Memberships[0].StudioName != studio.Name || export.Memberships[0].Role != StudioRoleOwner {
+		t.Errorf("Unexpected memberships: %+v", export.Memberships)
+	}
+	if len(export.ChatMessages) != 1 || len(export.ViewerSessions) != 1 || len(export.ClassPermissions) != 1 || len(export.ChatRestrictions) != 1 || len(export.FilteredChat) != 1 {
+		t.Errorf("Expected chat, sessions, restrictions, filtered chat and permissions in the export, got %+v", export)
+	}
+	if len(export.ClassQuestions) != 1 || len(export.PollVotes) != 1 {
+		t.Errorf("Expected class questions and poll votes in the export, got %+v %+v", export.ClassQuestions, export.PollVotes)
+	}
+
+	deleteAccount := func(token string, req DeleteAccountRequest) (resp DeleteAccountResponse, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = DeleteAccount(&vbeam.Context{Tx: tx, Token: token}, req)
+		})
+		return
+	}
+
+	if _, err = deleteAccount(parentToken, DeleteAccountRequest{Password: "wrong", ConfirmEmail: parent.Email}); err == nil {
+		t.Errorf("Expected wrong password to be refused")
+	}
+	_, err = deleteAccount(parentToken, DeleteAccountRequest{Password: "password123", ConfirmEmail: parent.Email})
+	if err == nil || !strings.Contains(err.Error(), studio.Name) {
+		t.Fatalf("Expected owned studio to block deletion, got %v", err)
+	}
+	_, err = deleteAccount(parentToken, DeleteAccountRequest{
+		Password:     "password123",
+		ConfirmEmail: parent.Email,
+		Transfers:    []StudioTransfer{{StudioId: studio.Id, NewOwnerId: admin.Id}},
+	})
+	if err == nil {
+		t.Errorf("Expected transfer to a non-member to be refused")
+	}
+
+	resp, err := deleteAccount(parentToken, DeleteAccountRequest{
+		Password:     "password123",
+		ConfirmEmail: " PARENT@test.com ",
+		Transfers:    []StudioTransfer{{StudioId: studio.Id, NewOwnerId: member.Id}},
+	})
+	if err != nil || resp.StudiosTransferred != 1 {
+		t.Fatalf("DeleteAccount failed: %v %+v", err, resp)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if GetUser(tx, parent.Id).Id != 0 || GetUserId(tx, parent.Email) != 0 {
+			t.Errorf("Expected the user to be deleted")
+		}
+		if GetStudioById(tx, studio.Id).OwnerId != member.Id || GetUserStudioRole(tx, member.Id, studio.Id) != StudioRoleOwner {
+			t.Errorf("Expected ownership to pass to the member")
+		}
+		if transfers := studioOwnershipTransfersTx(tx, studio.Id); len(transfers) != 1 || transfers[0].Status != OwnershipTransferAssigned {
+			t.Errorf("Expected the handoff to be recorded, got %+v", transfers)
+		}
+		if len(userMembershipIdsTx(tx, parent.Id)) != 0 {
+			t.Errorf("Expected memberships to be removed")
+		}
+
+		var msg ChatMessage
+		vbolt.Read(tx, ChatMessagesBkt, 1, &msg)
+		if msg.UserId != 0 || msg.UserName != DELETED_USER_NAME || msg.Text != "hi" {
+			t.Errorf("Expected the chat message to be anonymized, got %+v", msg)
+		}
+
+		var session ViewerSession
+		if vbolt.Read(tx, ViewerSessionsBkt, sessionKey, &session) {
+			t.Errorf("Expected viewer sessions to be deleted")
+		}
+		var permIds []int
+		vbolt.ReadTermTargets(tx, PermsByUserIdx, parent.Id, &permIds, vbolt.Window{})
+		if len(permIds) != 0 {
+			t.Errorf("Expected class permissions to be removed")
+		}
+		var restrictionIds []int
+		vbolt.ReadTermTargets(tx, ChatRestrictionsByStudioIdx, studio.Id, &restrictionIds, vbolt.Window{})
+		var restriction ChatRestriction
+		if len(restrictionIds) != 0 || vbolt.Read(tx, ChatRestrictionsBkt, 1, &restriction) {
+			t.Errorf("Expected chat restrictions on the user to be deleted")
+		}
+		var filterIds []int
+		vbolt.ReadTermTargets(tx, ChatFilterLogByStudioIdx, studio.Id, &filterIds, vbolt.Window{})
+		if len(filterIds) != 0 {
+			t.Errorf("Expected the user's filtered messages to be deleted")
+		}
+		var question ClassQuestion
+		vbolt.Read(tx, ClassQuestionsBkt, 1, &question)
+		if question.UserId != 0 || question.ViewerId != "" || question.UserName != DELETED_USER_NAME {
+			t.Errorf("Expected the class question to be anonymized, got %+v", question)
+		}
+		var voteKeys []string
+		vbolt.ReadTermTargets(tx, PollVotesByPollIdx, 1, &voteKeys, vbolt.Window{})
+		if len(voteKeys) != 0 {
+			t.Errorf("Expected the user's poll votes to be deleted")
+		}
+	})
+
+	if _, err = deleteAccount(parentToken, DeleteAccountRequest{ConfirmEmail: parent.Email}); err == nil {
+		t.Errorf("Expected the deleted account's token to stop working")
+	}
+
+	// The last site admin can't leave
+	adminToken, _ := createTestToken(admin.Id)
+	if _, err = deleteAccount(adminToken, DeleteAccountRequest{ConfirmEmail: admin.Email}); err == nil {
+		t.Errorf("Expected the only site admin to be refused")
+	}
+}
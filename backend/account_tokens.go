@@ -0,0 +1,343 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"stream/cfg"
+	"strings"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountTokenPurpose identifies what an emailed account token can be used for
+type AccountTokenPurpose int
+
+const (
+	AccountTokenPasswordReset AccountTokenPurpose = 0
+	AccountTokenVerifyEmail   AccountTokenPurpose = 1
+)
+
+const (
+	PASSWORD_RESET_TOKEN_TTL = 1 * time.Hour
+	VERIFY_EMAIL_TOKEN_TTL   = 48 * time.Hour
+)
+
+// AccountToken is a single-use token sent by email. Only the SHA-256 hash of the
+// token is stored, so a database leak doesn't expose working reset links.
+type AccountToken struct {
+	TokenHash string              `json:"tokenHash"`
+	UserId    int                 `json:"userId"`
+	Purpose   AccountTokenPurpose `json:"purpose"`
+	Email     string              `json:"email"` // Address the token was sent to
+	ExpiresAt time.Time           `json:"expiresAt"`
+	CreatedAt time.Time           `json:"createdAt"`
+}
+
+func PackAccountToken(self *AccountToken, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.String(&self.TokenHash, buf)
+	vpack.Int(&self.UserId, buf)
+	vpack.Int((*int)(&self.Purpose), buf)
+	vpack.String(&self.Email, buf)
+	vpack.Time(&self.ExpiresAt, buf)
+	vpack.Time(&self.CreatedAt, buf)
+}
+
+// token hash => AccountToken
+var AccountTokensBkt = vbolt.Bucket(&cfg.Info, "account_tokens", vpack.StringZ, PackAccountToken)
+
+// user id => token hashes
+var AccountTokensByUserIdx = vbolt.Index(&cfg.Info, "account_tokens_by_user", vpack.FInt, vpack.StringZ)
+
+func hashAccountToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createAccountTokenTx issues a new token for the user, replacing any outstanding
+// token with the same purpose. Returns the raw token to put in the email.
+func createAccountTokenTx(tx *vbolt.Tx, user User, purpose AccountTokenPurpose, ttl time.Duration) (string, error) {
+	deleteAccountTokensTx(tx, user.Id, purpose)
+
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	record := AccountToken{
+		TokenHash: hashAccountToken(token),
+		UserId:    user.Id,
+		Purpose:   purpose,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
+	}
+	vbolt.Write(tx, AccountTokensBkt, record.TokenHash, &record)
+	vbolt.SetTargetSingleTerm(tx, AccountTokensByUserIdx, record.TokenHash, user.Id)
+	return token, nil
+}
+
+// consumeAccountTokenTx validates a raw token and deletes it so it can't be used again
+func consumeAccountTokenTx(tx *vbolt.Tx, token string, purpose AccountTokenPurpose) (User, error) {
+	var record AccountToken
+	hash := hashAccountToken(token)
+	vbolt.Read(tx, AccountTokensBkt, hash, &record)
+	if record.TokenHash == "" || record.Purpose != purpose {
+		return User{}, errors.New("This link is invalid or has already been used")
+	}
+
+	vbolt.Delete(tx, AccountTokensBkt, hash)
+	vbolt.SetTargetSingleTerm(tx, AccountTokensByUserIdx, hash, -1)
+
+	if time.Now().After(record.ExpiresAt) {
+		return User{}, errors.New("This link has expired")
+	}
+
+	user := GetUser(tx, record.UserId)
+	// Tokens are tied to the address they were sent to
+	if user.Id == 0 || !strings.EqualFold(user.Email, record.Email) {
+		return User{}, errors.New("This link is invalid or has already been used")
+	}
+	return user, nil
+}
+
+// deleteAccountTokensTx removes the user's outstanding tokens with the given purpose
+func deleteAccountTokensTx(tx *vbolt.Tx, userId int, purpose AccountTokenPurpose) {
+	var hashes []string
+	vbolt.ReadTermTargets(tx, AccountTokensByUserIdx, userId, &hashes, vbolt.Window{})
+	for _, hash := range hashes {
+		var record AccountToken
+		vbolt.Read(tx, AccountTokensBkt, hash, &record)
+		if record.TokenHash != "" && record.Purpose != purpose {
+			continue
+		}
+		vbolt.Delete(tx, AccountTokensBkt, hash)
+		vbolt.SetTargetSingleTerm(tx, AccountTokensByUserIdx, hash, -1)
+	}
+}
+
+// Request/Response types
+type RequestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type RequestPasswordResetResponse struct {
+	Success bool `json:"success"`
+}
+
+type ResetPasswordRequest struct {
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+type ResetPasswordResponse struct {
+	Success bool `json:"success"`
+}
+
+type SendVerificationEmailResponse struct {
+	Success bool `json:"success"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type VerifyEmailResponse struct {
+	Success bool   `json:"success"`
+	Email   string `json:"email"`
+}
+
+// RequestPasswordReset emails a reset link. It reports success whether or not the
+// address is registered, so it can't be used to discover accounts.
+func RequestPasswordReset(ctx *vbeam.Context, req RequestPasswordResetRequest) (resp RequestPasswordResetResponse, err error) {
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		return resp, errors.New("Email is required")
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckPasswordResetRequest(strings.ToLower(email)); rateLimitErr != nil {
+		return resp, rateLimitErr
+	}
+
+	resp.Success = true
+
+	userId := GetUserId(ctx.Tx, email)
+	if userId == 0 {
+		LogInfo(LogCategoryAuth, "Password reset requested for unknown email", map[string]interface{}{
+			"email": email,
+		})
+		return
+	}
+	user := GetUser(ctx.Tx, userId)
+
+	vbeam.UseWriteTx(ctx)
+	token, tokenErr := createAccountTokenTx(ctx.Tx, user, AccountTokenPasswordReset, PASSWORD_RESET_TOKEN_TTL)
+	if tokenErr != nil {
+		return resp, errors.New("Failed to create reset link")
+	}
+	vbolt.TxCommit(ctx.Tx)
+
+	sendPasswordResetEmail(user, token)
+
+	LogInfo(LogCategoryAuth, "Password reset requested", map[string]interface{}{
+		"userId": user.Id,
+	})
+	return
+}
+
+// ResetPassword sets a new password using an emailed reset token and signs the
+// user out of every device by deleting their refresh tokens
+func ResetPassword(ctx *vbeam.Context, req ResetPasswordRequest) (resp ResetPasswordResponse, err error) {
+	if req.Token == "" {
+		return resp, errors.New("Reset token is required")
+	}
+	if len(req.Password) < 8 {
+		return resp, errors.New("Password must be at least 8 characters")
+	}
+	if req.Password != req.ConfirmPassword {
+		return resp, errors.New("Passwords do not match")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return resp, errors.New("Failed to process password")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	user, err := consumeAccountTokenTx(ctx.Tx, req.Token, AccountTokenPasswordReset)
+	if err != nil {
+		// Commit so a used or expired token is removed
+		vbolt.TxCommit(ctx.Tx)
+		return
+	}
+
+	vbolt.Write(ctx.Tx, PasswdBkt, user.Id, &hash)
+
+	// Following the link proves the user controls the address
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		vbolt.Write(ctx.Tx, UsersBkt, user.Id, &user)
+		acceptPendingInvitationsTx(ctx.Tx, user)
+	}
+
+	deleteAccountTokensTx(ctx.Tx, user.Id, AccountTokenPasswordReset)
+	DeleteUserRefreshTokens(ctx.Tx, user.Id)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategoryAuth, "Password reset completed", map[string]interface{}{
+		"userId": user.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+// SendVerificationEmail emails the logged-in user a link to verify their address
+func SendVerificationEmail(ctx *vbeam.Context, req Empty) (resp SendVerificationEmailResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	if user.EmailVerified {
+		return resp, errors.New("Email is already verified")
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckVerificationEmail(user.Id); rateLimitErr != nil {
+		return resp, rateLimitErr
+	}
+
+	vbeam.UseWriteTx(ctx)
+	token, tokenErr := createAccountTokenTx(ctx.Tx, user, AccountTokenVerifyEmail, VERIFY_EMAIL_TOKEN_TTL)
+	if tokenErr != nil {
+		return resp, errors.New("Failed to create verification link")
+	}
+	vbolt.TxCommit(ctx.Tx)
+
+	if err = sendVerificationEmail(user, token); err != nil {
+		return resp, errors.New("Failed to send verification email")
+	}
+
+	resp.Success = true
+	return
+}
+
+// VerifyEmail marks the token's user as verified
+func VerifyEmail(ctx *vbeam.Context, req VerifyEmailRequest) (resp VerifyEmailResponse, err error) {
+	if req.Token == "" {
+		return resp, errors.New("Verification token is required")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	user, err := consumeAccountTokenTx(ctx.Tx, req.Token, AccountTokenVerifyEmail)
+	if err != nil {
+		vbolt.TxCommit(ctx.Tx)
+		return
+	}
+
+	user.EmailVerified = true
+	vbolt.Write(ctx.Tx, UsersBkt, user.Id, &user)
+
+	// Invitations sent to this address can now be accepted
+	acceptPendingInvitationsTx(ctx.Tx, user)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategoryAuth, "Email verified", map[string]interface{}{
+		"userId": user.Id,
+	})
+
+	resp.Success = true
+	resp.Email = user.Email
+	return
+}
+
+func sendPasswordResetEmail(user User, token string) error {
+	body := fmt.Sprintf(`Hi %s,
+
+We received a request to reset the password for your account.
+Choose a new password here:
+
+    %s/reset-password/%s
+
+This link expires in 1 hour and can only be used once.
+If you didn't ask to reset your password, you can ignore this email.
+`, user.Name, cfg.SiteURL, token)
+
+	return sendMail(MailMessage{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	})
+}
+
+func sendVerificationEmail(user User, token string) error {
+	body := fmt.Sprintf(`Hi %s,
+
+Please confirm your email address by opening this link:
+
+    %s/verify-email/%s
+
+This link expires in 48 hours.
+`, user.Name, cfg.SiteURL, token)
+
+	return sendMail(MailMessage{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    body,
+	})
+}
+
+// RegisterAccountTokenMethods registers password reset and email verification procedures
+func RegisterAccountTokenMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, RequestPasswordReset)
+	vbeam.RegisterProc(app, ResetPassword)
+	vbeam.RegisterProc(app, SendVerificationEmail)
+	vbeam.RegisterProc(app, VerifyEmail)
+}
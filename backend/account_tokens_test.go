@@ -0,0 +1,197 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tokenFromMail extracts the token from the link in an account email
+func tokenFromMail(t *testing.T, msg MailMessage, path string) string {
+	idx := strings.Index(msg.Body, path)
+	if idx < 0 {
+		t.Fatalf("Expected %s link in email, got %q", path, msg.Body)
+	}
+	rest := msg.Body[idx+len(path):]
+	return strings.Fields(rest)[0]
+}
+
+func TestPasswordReset(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	_, mailer := setupTestTicketingGlobals(t, db)
+
+	var user User
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+		user = AddUserTx(tx, CreateAccountRequest{Name: "Reset User", Email: "reset@test.com"}, hash)
+		CreateRefreshToken(tx, user.Id, time.Hour, "", "")
+		vbolt.TxCommit(tx)
+	})
+
+	// Unknown addresses look the same to the caller but send nothing
+	var err error
+	var requestResp RequestPasswordResetResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		requestResp, err = RequestPasswordReset(&vbeam.Context{Tx: tx}, RequestPasswordResetRequest{Email: "nobody@test.com"})
+	})
+	if err != nil || !requestResp.Success || len(mailer.Sent()) != 0 {
+		t.Fatalf("Expected silent success for unknown email, got %v %+v %d", err, requestResp, len(mailer.Sent()))
+	}
+
+	// Request twice: only the latest link works
+	for i := 0; i < 2; i++ {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err = RequestPasswordReset(&vbeam.Context{Tx: tx}, RequestPasswordResetRequest{Email: user.Email})
+		})
+		if err != nil {
+			t.Fatalf("RequestPasswordReset failed: %v", err)
+		}
+	}
+	sent := mailer.Sent()
+	if len(sent) != 2 || sent[1].To != user.Email {
+		t.Fatalf("Expected 2 reset emails, got %+v", sent)
+	}
+	staleToken := tokenFromMail(t, sent[0], "/reset-password/")
+	token := tokenFromMail(t, sent[1], "/reset-password/")
+
+	reset := func(token string) error {
+		var resetErr error
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, resetErr = ResetPassword(&vbeam.Context{Tx: tx}, ResetPasswordRequest{
+				Token:           token,
+				Password:        "new-password",
+				ConfirmPassword: "new-password",
+			})
+		})
+		return resetErr
+	}
+
+	if err := reset(staleToken); err == nil {
+		t.Errorf("Expected superseded token to be rejected")
+	}
+	if err := reset(token); err != nil {
+		t.Fatalf("ResetPassword failed: %v", err)
+	}
+	if err := reset(token); err == nil || !strings.Contains(err.Error(), "already been used") {
+		t.Errorf("Expected token to be single-use, got %v", err)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if bcrypt.CompareHashAndPassword(GetPassHash(tx, user.Id), []byte("new-password")) != nil {
+			t.Errorf("Expected password to be changed")
+		}
+		var tokenIds []int
+		vbolt.ReadTermTargets(tx, RefreshTokenByUserIndex, user.Id, &tokenIds, vbolt.Window{})
+		if len(tokenIds) != 0 {
+			t.Errorf("Expected refresh tokens to be deleted, got %d", len(tokenIds))
+		}
+		if !GetUser(tx, user.Id).EmailVerified {
+			t.Errorf("Expected reset to verify the email address")
+		}
+	})
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	var token string
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		user := AddUserTx(tx, CreateAccountRequest{Name: "Late User", Email: "late@test.com"}, []byte{})
+		token, _ = createAccountTokenTx(tx, user, AccountTokenPasswordReset, -time.Minute)
+		vbolt.TxCommit(tx)
+	})
+
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = ResetPassword(&vbeam.Context{Tx: tx}, ResetPasswordRequest{
+			Token:           token,
+			Password:        "new-password",
+			ConfirmPassword: "new-password",
+		})
+	})
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected expired token error, got %v", err)
+	}
+
+	// A reset token can't be used to verify an email and vice versa
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = VerifyEmail(&vbeam.Context{Tx: tx}, VerifyEmailRequest{Token: token})
+	})
+	if err == nil {
+		t.Errorf("Expected token to be rejected for the wrong purpose")
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	_, mailer := setupTestTicketingGlobals(t, db)
+
+	var err error
+	var createResp CreateAccountResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		createResp, err = CreateAccount(&vbeam.Context{Tx: tx}, CreateAccountRequest{
+			Name:            "New User",
+			Email:           "new@test.com",
+			Password:        "password123",
+			ConfirmPassword: "password123",
+		})
+	})
+	if err != nil {
+		t.Fatalf("CreateAccount failed: %v", err)
+	}
+	if createResp.Auth.EmailVerified {
+		t.Errorf("New accounts should start unverified")
+	}
+
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != "new@test.com" {
+		t.Fatalf("Expected verification email, got %+v", sent)
+	}
+	token := tokenFromMail(t, sent[0], "/verify-email/")
+
+	// Resend replaces the original link
+	userToken, _ := createTestToken(createResp.Auth.Id)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = SendVerificationEmail(&vbeam.Context{Tx: tx, Token: userToken}, Empty{})
+	})
+	if err != nil {
+		t.Fatalf("SendVerificationEmail failed: %v", err)
+	}
+	sent = mailer.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("Expected resent verification email, got %d", len(sent))
+	}
+	resentToken := tokenFromMail(t, sent[1], "/verify-email/")
+
+	var verifyResp VerifyEmailResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = VerifyEmail(&vbeam.Context{Tx: tx}, VerifyEmailRequest{Token: token})
+	})
+	if err == nil {
+		t.Errorf("Expected replaced verification token to be rejected")
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		verifyResp, err = VerifyEmail(&vbeam.Context{Tx: tx}, VerifyEmailRequest{Token: resentToken})
+	})
+	if err != nil || verifyResp.Email != "new@test.com" {
+		t.Fatalf("VerifyEmail failed: %v %+v", err, verifyResp)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if !GetUser(tx, createResp.Auth.Id).EmailVerified {
+			t.Errorf("Expected user to be verified")
+		}
+		_, err = SendVerificationEmail(&vbeam.Context{Tx: tx, Token: userToken}, Empty{})
+	})
+	if err == nil || !strings.Contains(err.Error(), "already verified") {
+		t.Errorf("Expected already verified error, got %v", err)
+	}
+}
@@ -180,13 +180,20 @@ var SessionsByRoomIndex = vbolt.Index(&cfg.Info, "sessions_by_room", vpack.FInt,
 // Find all viewer sessions using a specific access code
 var SessionsByCodeIndex = vbolt.Index(&cfg.Info, "sessions_by_code", vpack.StringZ, vpack.StringZ)
 
+// SessionsByViewerIndex: Term=viewerId, Target=sessionKey
+// Find all of a viewer's sessions across rooms
+var SessionsByViewerIndex = vbolt.Index(&cfg.Info, "sessions_by_viewer", vpack.StringZ, vpack.StringZ)
+
 // Helper functions for analytics tracking
 
+// IncrementRoomViewerCount records a viewer connecting to a room: it counts
+// the view (reconnects within SESSION_TIMEOUT don't count again), joins the
+// viewer's presence, and recounts CurrentViewers from presence.
 func IncrementRoomViewerCount(db *vbolt.DB, roomId int, viewerId string, code string) {
-	var currentCount int
+	var room Room
 	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
 		// Get room to find studioId
-		room := GetRoom(tx, roomId)
+		room = GetRoom(tx, roomId)
 		if room.Id == 0 {
 			return
 		}
@@ -209,9 +216,6 @@ func IncrementRoomViewerCount(db *vbolt.DB, roomId int, viewerId string, code st
 			analytics.RoomId = roomId
 		}
 
-		// Always increment current viewers (live count)
-		analytics.CurrentViewers++
-
 		// Only increment view totals if new or expired session
 		if isNewSession || isExpiredSession {
 			analytics.TotalViewsAllTime++
@@ -224,12 +228,6 @@ func IncrementRoomViewerCount(db *vbolt.DB, roomId int, viewerId string, code st
 			}
 		}
 
-		// Update peak if necessary
-		if analytics.CurrentViewers > analytics.PeakViewers {
-			analytics.PeakViewers = analytics.CurrentViewers
-			analytics.PeakViewersAt = now
-		}
-
 		// Update or create session
 		if isNewSession {
 			session.SessionKey = sessionKey
@@ -243,87 +241,32 @@ func IncrementRoomViewerCount(db *vbolt.DB, roomId int, viewerId string, code st
 		// Save session and analytics
 		vbolt.Write(tx, ViewerSessionsBkt, sessionKey, &session)
 		vbolt.SetTargetSingleTerm(tx, SessionsByRoomIndex, sessionKey, roomId)
+		vbolt.SetTargetSingleTerm(tx, SessionsByViewerIndex, sessionKey, viewerId)
 
 		// Index by code if this is a code-based session
 		if code != "" {
 			vbolt.SetTargetSingleTerm(tx, SessionsByCodeIndex, sessionKey, code)
-
-			// Increment code analytics
-			var codeAnalytics CodeAnalytics
-			vbolt.Read(tx, CodeAnalyticsBkt, code, &codeAnalytics)
-			codeAnalytics.CurrentViewers++
-
-			// Update peak if necessary
-			if codeAnalytics.CurrentViewers > codeAnalytics.PeakViewers {
-				codeAnalytics.PeakViewers = codeAnalytics.CurrentViewers
-				codeAnalytics.PeakViewersAt = now
-			}
-
-			vbolt.Write(tx, CodeAnalyticsBkt, code, &codeAnalytics)
 		}
 
 		vbolt.Write(tx, RoomAnalyticsBkt, roomId, &analytics)
 
-		incrementStudioViewerCount(tx, room.StudioId, viewerId, isNewSession, isExpiredSession)
+		incrementStudioViews(tx, room.StudioId, isNewSession, isExpiredSession)
 
 		vbolt.TxCommit(tx)
-		currentCount = analytics.CurrentViewers
 	})
+	if room.Id == 0 {
+		return
+	}
 
+	// Live counts (room, studio and code) come from presence
+	currentCount := joinPresence(db, room, viewerId, code)
 	sseManager.BroadcastViewerCount(roomId, currentCount)
 }
 
+// DecrementRoomViewerCount records a viewer's connection to a room closing
+// and recounts CurrentViewers from presence
 func DecrementRoomViewerCount(db *vbolt.DB, roomId int, viewerId string, accessCode string) {
-	var currentCount int
-	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
-		room := GetRoom(tx, roomId)
-		if room.Id == 0 {
-			return
-		}
-
-		// Determine the code to use for analytics
-		// Prefer the passed accessCode, but fall back to session lookup for backwards compatibility
-		codeToUse := accessCode
-
-		// If no code was passed, try to look it up from the session
-		if codeToUse == "" {
-			sessionKey := viewerId + ":" + strconv.Itoa(roomId)
-			var session ViewerSession
-			vbolt.Read(tx, ViewerSessionsBkt, sessionKey, &session)
-			codeToUse = session.Code
-		}
-
-		// If this is a code-based session, decrement code analytics
-		if codeToUse != "" {
-			var codeAnalytics CodeAnalytics
-			vbolt.Read(tx, CodeAnalyticsBkt, codeToUse, &codeAnalytics)
-
-			if codeAnalytics.CurrentViewers > 0 {
-				codeAnalytics.CurrentViewers--
-			}
-
-			vbolt.Write(tx, CodeAnalyticsBkt, codeToUse, &codeAnalytics)
-		}
-
-		// Load room analytics
-		var analytics RoomAnalytics
-		vbolt.Read(tx, RoomAnalyticsBkt, roomId, &analytics)
-		if analytics.RoomId == 0 {
-			return
-		}
-
-		if analytics.CurrentViewers > 0 {
-			analytics.CurrentViewers--
-		}
-
-		// Save
-		vbolt.Write(tx, RoomAnalyticsBkt, roomId, &analytics)
-
-		decrementStudioViewerCount(tx, room.StudioId)
-
-		vbolt.TxCommit(tx)
-		currentCount = analytics.CurrentViewers
-	})
+	currentCount := leavePresence(db, roomId, viewerId, accessCode)
 
 	// Broadcast updated viewer count to all connected clients
 	sseManager.BroadcastViewerCount(roomId, currentCount)
@@ -394,7 +337,7 @@ func UpdateStudioAnalyticsFromRoom(tx *vbolt.Tx, studioId int) {
 	vbolt.Write(tx, StudioAnalyticsBkt, studioId, &studioAnalytics)
 }
 
-func incrementStudioViewerCount(tx *vbolt.Tx, studioId int, viewerId string, isNewSession bool, isExpiredSession bool) {
+func incrementStudioViews(tx *vbolt.Tx, studioId int, isNewSession bool, isExpiredSession bool) {
 	var studioAnalytics StudioAnalytics
 	vbolt.Read(tx, StudioAnalyticsBkt, studioId, &studioAnalytics)
 
@@ -403,9 +346,6 @@ func incrementStudioViewerCount(tx *vbolt.Tx, studioId int, viewerId string, isN
 		studioAnalytics.StudioId = studioId
 	}
 
-	// Always increment current viewers
-	studioAnalytics.CurrentViewers++
-
 	// Only increment totals if new or expired session
 	if isNewSession || isExpiredSession {
 		studioAnalytics.TotalViewsAllTime++
@@ -421,21 +361,6 @@ func incrementStudioViewerCount(tx *vbolt.Tx, studioId int, viewerId string, isN
 	vbolt.Write(tx, StudioAnalyticsBkt, studioId, &studioAnalytics)
 }
 
-func decrementStudioViewerCount(tx *vbolt.Tx, studioId int) {
-	var studioAnalytics StudioAnalytics
-	vbolt.Read(tx, StudioAnalyticsBkt, studioId, &studioAnalytics)
-
-	if studioAnalytics.StudioId == 0 {
-		return
-	}
-
-	if studioAnalytics.CurrentViewers > 0 {
-		studioAnalytics.CurrentViewers--
-	}
-
-	vbolt.Write(tx, StudioAnalyticsBkt, studioId, &studioAnalytics)
-}
-
 func RecordStreamStart(db *vbolt.DB, roomId int, studioId int) {
 	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
 		var analytics RoomAnalytics
@@ -684,38 +609,10 @@ func StartViewerSessionCleanup(db *vbolt.DB) {
 			vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
 				// Iterate all viewer sessions
 				vbolt.IterateAll(tx, ViewerSessionsBkt, func(sessionKey string, session ViewerSession) bool {
-					// Delete sessions older than cleanup age
+					// Delete sessions older than cleanup age. Live viewer
+					// counts come from presence, so they're left alone.
 					if session.LastSeenAt.Before(cutoffTime) {
-						// Decrement viewer counts before deleting session
-						// Get room to find studioId
-						room := GetRoom(tx, session.RoomId)
-						if room.Id != 0 {
-							// Decrement room analytics
-							var roomAnalytics RoomAnalytics
-							vbolt.Read(tx, RoomAnalyticsBkt, session.RoomId, &roomAnalytics)
-							if roomAnalytics.CurrentViewers > 0 {
-								roomAnalytics.CurrentViewers--
-								vbolt.Write(tx, RoomAnalyticsBkt, session.RoomId, &roomAnalytics)
-							}
-
-							// Decrement studio analytics
-							var studioAnalytics StudioAnalytics
-							vbolt.Read(tx, StudioAnalyticsBkt, room.StudioId, &studioAnalytics)
-							if studioAnalytics.CurrentViewers > 0 {
-								studioAnalytics.CurrentViewers--
-								vbolt.Write(tx, StudioAnalyticsBkt, room.StudioId, &studioAnalytics)
-							}
-						}
-
-						// If this is a code-based session, decrement code analytics
 						if session.Code != "" {
-							var codeAnalytics CodeAnalytics
-							vbolt.Read(tx, CodeAnalyticsBkt, session.Code, &codeAnalytics)
-							if codeAnalytics.CurrentViewers > 0 {
-								codeAnalytics.CurrentViewers--
-								vbolt.Write(tx, CodeAnalyticsBkt, session.Code, &codeAnalytics)
-							}
-
 							// Remove from code index
 							vbolt.SetTargetSingleTerm(tx, SessionsByCodeIndex, sessionKey, "")
 						}
@@ -723,6 +620,7 @@ func StartViewerSessionCleanup(db *vbolt.DB) {
 						// Now delete the session
 						vbolt.Delete(tx, ViewerSessionsBkt, sessionKey)
 						vbolt.SetTargetSingleTerm(tx, SessionsByRoomIndex, sessionKey, -1)
+						vbolt.SetTargetSingleTerm(tx, SessionsByViewerIndex, sessionKey, "")
 						cleanupCount++
 					}
 					return true // continue iteration
@@ -739,71 +637,3 @@ func StartViewerSessionCleanup(db *vbolt.DB) {
 		}
 	}()
 }
-
-// ResetAllCurrentViewers resets all CurrentViewers counts to 0 across all analytics buckets
-// This should be called on server startup since SSE connections cannot persist across restarts
-func ResetAllCurrentViewers(db *vbolt.DB) {
-	resetCount := 0
-	sessionCount := 0
-
-	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
-		// Reset room analytics
-		vbolt.IterateAll(tx, RoomAnalyticsBkt, func(roomId int, analytics RoomAnalytics) bool {
-			if analytics.CurrentViewers > 0 {
-				analytics.CurrentViewers = 0
-				vbolt.Write(tx, RoomAnalyticsBkt, roomId, &analytics)
-				resetCount++
-			}
-			return true // continue iteration
-		})
-
-		// Reset studio analytics
-		vbolt.IterateAll(tx, StudioAnalyticsBkt, func(studioId int, analytics StudioAnalytics) bool {
-			if analytics.CurrentViewers > 0 {
-				analytics.CurrentViewers = 0
-				vbolt.Write(tx, StudioAnalyticsBkt, studioId, &analytics)
-				resetCount++
-			}
-			return true // continue iteration
-		})
-
-		vbolt.TxCommit(tx)
-	})
-
-	// Also reset code analytics (imported from code_access.go)
-	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
-		codeResetCount := 0
-		vbolt.IterateAll(tx, CodeAnalyticsBkt, func(code string, analytics CodeAnalytics) bool {
-			if analytics.CurrentViewers > 0 {
-				analytics.CurrentViewers = 0
-				vbolt.Write(tx, CodeAnalyticsBkt, code, &analytics)
-				codeResetCount++
-			}
-			return true // continue iteration
-		})
-		vbolt.TxCommit(tx)
-		resetCount += codeResetCount
-	})
-
-	// Delete all viewer sessions since SSE connections are closed on restart
-	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
-		vbolt.IterateAll(tx, ViewerSessionsBkt, func(sessionKey string, session ViewerSession) bool {
-			// Delete session from bucket
-			vbolt.Delete(tx, ViewerSessionsBkt, sessionKey)
-			// Remove from room index
-			vbolt.SetTargetSingleTerm(tx, SessionsByRoomIndex, sessionKey, -1)
-			// Remove from code index if applicable
-			if session.Code != "" {
-				vbolt.SetTargetSingleTerm(tx, SessionsByCodeIndex, sessionKey, "")
-			}
-			sessionCount++
-			return true // continue iteration
-		})
-		vbolt.TxCommit(tx)
-	})
-
-	LogInfo(LogCategorySystem, "Reset all CurrentViewers to 0 on startup", map[string]interface{}{
-		"resetCount":      resetCount,
-		"deletedSessions": sessionCount,
-	})
-}
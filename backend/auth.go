@@ -23,6 +23,7 @@ var ErrAuthFailure = errors.New("AuthFailure")
 type Claims struct {
 	UserId       int    `json:"userId"`                 // User ID (-1 for anonymous code sessions, >0 for logged-in users)
 	SessionToken string `json:"sessionToken,omitempty"` // UUID of CodeSession (only for userId=-1)
+	RefreshId    int    `json:"rid,omitempty"`          // RefreshToken (device session) the JWT was issued for
 	jwt.RegisteredClaims
 }
 
@@ -52,15 +53,29 @@ func SetupAuth(app *vbeam.Application) {
 
 	jwtKey = []byte(jwtSecret)
 
+	// Keyring for signing auth JWTs; JWT_SECRET_KEY stays the legacy key and
+	// the base for derived HMAC keys
+	if err := SetupJWTKeys(app.DB); err != nil {
+		log.Fatalf("JWT key setup failed: %v", err)
+	}
+
 	// Register essential auth API endpoints
 	app.HandleFunc("/api/login", loginHandler)
 	app.HandleFunc("/api/logout", logoutHandler)
 	app.HandleFunc("/api/refresh", refreshTokenHandler)
 
+	// Second login step for accounts with two-factor authentication
+	app.HandleFunc("/api/login/2fa", twoFactorLoginHandler)
+	app.HandleFunc("/api/login/2fa/setup", twoFactorLoginSetupHandler)
+
 	// Register Google OAuth endpoints
 	app.HandleFunc("/api/login/google", googleLoginHandler)
 	app.HandleFunc("/api/google/callback", googleCallbackHandler)
 
+	// Register generic OpenID Connect endpoints (Microsoft, Apple, school IdPs, ...)
+	app.HandleFunc("/api/login/oidc", oidcLoginHandler)
+	app.HandleFunc("/api/oidc/callback", oidcCallbackHandler)
+
 	// Setup Google OAuth configuration
 	err := SetupGoogleOAuth()
 	if err != nil {
@@ -68,6 +83,14 @@ func SetupAuth(app *vbeam.Application) {
 		log.Println("Google login will not be available. Set GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET to enable.")
 	}
 
+	if err := SetupOIDCProviders(); err != nil {
+		log.Printf("OIDC setup failed: %v", err)
+	}
+
+	if err := SetupTwoFactorPolicy(); err != nil {
+		log.Printf("Two-factor policy setup failed: %v", err)
+	}
+
 	appDb = app.DB
 }
 
@@ -97,10 +120,17 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		passHash = GetPassHash(tx, userId)
 	})
 
+	// Per-IP and per-account throttling with backoff, before the password check
+	loginEmail := normalizeLoginEmail(credentials.Email)
+	if throttleLogin(w, r, user, loginEmail) {
+		return
+	}
+
 	if user.Id == 0 {
 		LogWarnWithRequest(r, LogCategoryAuth, "Login attempt with unknown email", map[string]interface{}{
 			"email": credentials.Email,
 		})
+		recordLoginEvent(r, SecurityEventLoginFailed, 0, loginEmail, "unknown email")
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
@@ -111,9 +141,25 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 			"userId": user.Id,
 			"email":  user.Email,
 		})
+		recordLoginEvent(r, SecurityEventLoginFailed, user.Id, loginEmail, "invalid password")
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	globalRateLimiter.ResetLoginAccount(loginEmail)
+
+	// Accounts with two-factor authentication finish signing in at /api/login/2fa
+	pending, setupRequired, err := beginTwoFactorLogin(w, user)
+	if err != nil {
+		http.Error(w, "Failed to start two-factor login", http.StatusInternalServerError)
+		return
+	}
+	if pending {
+		LogInfoWithRequest(r, LogCategoryAuth, "Password accepted, awaiting second factor", map[string]interface{}{
+			"userId": user.Id,
+		})
+		json.NewEncoder(w).Encode(LoginResponse{TwoFactorRequired: true, TwoFactorSetupRequired: setupRequired})
+		return
+	}
 
 	// Migrate anonymous code session if user had one before logging in
 	migratedSession, _ := migrateAnonymousCodeSession(r, user.Id)
@@ -124,7 +170,7 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	token, err := generateAuthJwt(user, w)
+	token, err := generateAuthJwt(user, w, r)
 	if err != nil {
 		LogErrorWithRequest(r, LogCategoryAuth, "Failed to generate JWT token", map[string]interface{}{
 			"userId": user.Id,
@@ -207,17 +253,41 @@ func generateToken(n int) (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-func generateAuthJwt(user User, w http.ResponseWriter) (tokenString string, err error) {
+// generateAuthJwt signs the user in on a new device: it creates a refresh token
+// session for the request's browser and sets both cookies
+func generateAuthJwt(user User, w http.ResponseWriter, r *http.Request) (tokenString string, err error) {
+	// Create refresh token (30 days) and update last login
+	var refreshToken RefreshToken
+	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+		refreshToken, err = CreateRefreshToken(tx, user.Id, REFRESH_TOKEN_TTL, r.UserAgent(), getClientIP(r))
+		if err != nil {
+			return
+		}
+
+		user.LastLogin = time.Now()
+		vbolt.Write(tx, UsersBkt, user.Id, &user)
+		vbolt.TxCommit(tx)
+	})
+
+	if err != nil {
+		return
+	}
+
+	setRefreshTokenCookie(w, refreshToken)
+	return issueAuthJwt(user, refreshToken.Id, w)
+}
+
+// issueAuthJwt sets the short-lived authToken cookie for an existing session
+func issueAuthJwt(user User, refreshId int, w http.ResponseWriter) (tokenString string, err error) {
 	expirationTime := time.Now().Add(24 * time.Hour) // 24 hour expiry
 	claims := &Claims{
-		UserId: user.Id,
+		UserId:    user.Id,
+		RefreshId: refreshId,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	tokenString, err = token.SignedString(jwtKey)
+	tokenString, err = signJwt(claims)
 	if err != nil {
 		return
 	}
@@ -228,35 +298,17 @@ func generateAuthJwt(user User, w http.ResponseWriter) (tokenString string, err
 		HttpOnly: true,
 		MaxAge:   60 * 60 * 24, // 24 hours
 	})
+	return
+}
 
-	// Create and set refresh token (30 days)
-	var refreshToken RefreshToken
-	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
-		refreshToken, err = CreateRefreshToken(tx, user.Id, 30*24*time.Hour)
-		if err != nil {
-			return
-		}
-
-		// Update last login
-		user.LastLogin = time.Now()
-		vbolt.Write(tx, UsersBkt, user.Id, &user)
-		vbolt.TxCommit(tx)
-	})
-
-	if err != nil {
-		return
-	}
-
-	// Set refresh token cookie (30 days)
+func setRefreshTokenCookie(w http.ResponseWriter, refreshToken RefreshToken) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "refreshToken",
 		Value:    refreshToken.Token,
 		Path:     "/",
 		HttpOnly: true,
-		MaxAge:   60 * 60 * 24 * 30, // 30 days
+		MaxAge:   int(REFRESH_TOKEN_TTL.Seconds()), // 30 days
 	})
-
-	return
 }
 
 // migrateAnonymousCodeSession checks if the request has an anonymous code session (userId=-1)
@@ -271,12 +323,7 @@ func migrateAnonymousCodeSession(r *http.Request, newUserId int) (sessionToken s
 	}
 
 	// Parse the existing JWT
-	token, parseErr := jwt.ParseWithClaims(authCookie.Value, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	token, parseErr := jwt.ParseWithClaims(authCookie.Value, &Claims{}, jwtKeyFunc)
 
 	// If parsing failed or token is invalid, nothing to migrate
 	if parseErr != nil || !token.Valid {
@@ -308,12 +355,7 @@ func GetAuthUser(ctx *vbeam.Context) (user User, err error) {
 	if len(ctx.Token) == 0 {
 		return user, ErrAuthFailure
 	}
-	token, err := jwt.ParseWithClaims(ctx.Token, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	token, err := jwt.ParseWithClaims(ctx.Token, &Claims{}, jwtKeyFunc)
 	if err != nil || !token.Valid {
 		LogWarn(LogCategoryAuth, "JWT parsing failed in GetAuthUser", map[string]interface{}{
 			"error": err,
@@ -376,12 +418,39 @@ func GetAuthUser(ctx *vbeam.Context) (user User, err error) {
 			return user, nil
 		}
 
-		// Regular user JWT (userId > 0)
+		// Regular user JWT (userId > 0); revoked sessions stop working right away
+		if claims.RefreshId > 0 && !refreshSessionActiveTx(ctx.Tx, claims.RefreshId, claims.UserId) {
+			return user, ErrAuthFailure
+		}
 		user = GetUser(ctx.Tx, claims.UserId)
 	}
 	return
 }
 
+// codeSessionTokenFromContext returns the code session behind the request's JWT:
+// the token's own session for anonymous viewers, or the logged-in user's code session
+func codeSessionTokenFromContext(ctx *vbeam.Context) string {
+	if len(ctx.Token) == 0 {
+		return ""
+	}
+	token, err := jwt.ParseWithClaims(ctx.Token, &Claims{}, jwtKeyFunc)
+	if err != nil || !token.Valid {
+		return ""
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return ""
+	}
+	if claims.UserId == -1 {
+		return claims.SessionToken
+	}
+
+	var sessionToken string
+	vbolt.Read(ctx.Tx, UserCodeSessionsBkt, claims.UserId, &sessionToken)
+	return sessionToken
+}
+
 // AuthContext represents the authentication context for a request
 // It can be either a regular user (via JWT) or a code session (via access code)
 type AuthContext struct {
@@ -403,12 +472,7 @@ func GetAuthFromRequest(r *http.Request, db *vbolt.DB) (authCtx AuthContext, err
 	}
 
 	// Parse JWT token
-	token, tokenErr := jwt.ParseWithClaims(authCookie.Value, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	token, tokenErr := jwt.ParseWithClaims(authCookie.Value, &Claims{}, jwtKeyFunc)
 
 	if tokenErr != nil || !token.Valid {
 		return authCtx, ErrAuthFailure
@@ -463,6 +527,11 @@ func GetAuthFromRequest(r *http.Request, db *vbolt.DB) (authCtx AuthContext, err
 
 	// Logged-in user JWT (userId > 0)
 	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		// Revoked sessions stop working right away
+		if claims.RefreshId > 0 && !refreshSessionActiveTx(tx, claims.RefreshId, claims.UserId) {
+			return
+		}
+
 		// Load user
 		authCtx.User = GetUser(tx, claims.UserId)
 
@@ -526,19 +595,21 @@ func refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user User
-	var validToken RefreshToken
+	var session RefreshToken
 
-	// Validate refresh token and get user
-	vbolt.WithReadTx(appDb, func(tx *vbolt.Tx) {
-		var valid bool
-		validToken, valid = ValidateRefreshToken(tx, cookie.Value)
-		if !valid {
-			return
+	// Rotate the refresh token; a replayed old token revokes the session
+	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+		session, err = UseRefreshToken(tx, cookie.Value, r.UserAgent(), getClientIP(r))
+		if err == nil {
+			user = GetUser(tx, session.UserId)
 		}
-
-		user = GetUser(tx, validToken.UserId)
+		vbolt.TxCommit(tx)
 	})
 
+	if errors.Is(err, ErrRefreshTokenReused) {
+		LogWarnWithRequest(r, LogCategoryAuth, "Refresh token reuse detected", nil)
+	}
+
 	if user.Id == 0 {
 		LogWarnWithRequest(r, LogCategoryAuth, "Refresh attempt with invalid token", nil)
 		w.WriteHeader(http.StatusUnauthorized)
@@ -549,14 +620,9 @@ func refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update last used timestamp
-	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
-		UpdateRefreshTokenLastUsed(tx, validToken.Id)
-		vbolt.TxCommit(tx)
-	})
-
-	// Generate new JWT
-	token, err := generateAuthJwt(user, w)
+	// Generate new JWT for the same session
+	setRefreshTokenCookie(w, session)
+	token, err := issueAuthJwt(user, session.Id, w)
 	if err != nil {
 		LogErrorWithRequest(r, LogCategoryAuth, "Failed to generate JWT during refresh", map[string]interface{}{
 			"userId": user.Id,
@@ -98,8 +98,8 @@ func SetCameraConfig(ctx *vbeam.Context, req SetCameraConfigRequest) (resp SetCa
 		return resp, errors.New("room not found")
 	}
 
-	// Check permissions - require Admin or higher on studio
-	if !HasStudioPermission(ctx.Tx, caller.Id, room.StudioId, StudioRoleAdmin) {
+	// Check permissions - require camera control in the studio
+	if !HasStudioCapability(ctx.Tx, caller.Id, room.StudioId, CapControlCameras) {
 		return resp, errors.New("only studio admins can configure cameras")
 	}
 
@@ -138,8 +138,8 @@ func GetCameraConfigProc(ctx *vbeam.Context, req GetCameraConfigRequest) (resp G
 		return resp, errors.New("room not found")
 	}
 
-	// Check permissions - require Viewer or higher on studio
-	if !HasStudioPermission(ctx.Tx, caller.Id, room.StudioId, StudioRoleViewer) {
+	// Check permissions - require studio view access
+	if !HasStudioCapability(ctx.Tx, caller.Id, room.StudioId, CapViewStudio) {
 		return resp, errors.New("permission denied")
 	}
 
@@ -173,8 +173,8 @@ func DeleteCameraConfigProc(ctx *vbeam.Context, req DeleteCameraConfigRequest) (
 		return resp, errors.New("room not found")
 	}
 
-	// Check permissions - require Admin or higher on studio
-	if !HasStudioPermission(ctx.Tx, caller.Id, room.StudioId, StudioRoleAdmin) {
+	// Check permissions - require camera control in the studio
+	if !HasStudioCapability(ctx.Tx, caller.Id, room.StudioId, CapControlCameras) {
 		return resp, errors.New("only studio admins can delete camera configurations")
 	}
 
@@ -3,9 +3,12 @@ package backend
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"stream/cfg"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"go.hasen.dev/vbeam"
 	"go.hasen.dev/vbolt"
@@ -16,8 +19,12 @@ import (
 func RegisterChatMethods(app *vbeam.Application) {
 	vbeam.RegisterProc(app, SendChatMessage)
 	vbeam.RegisterProc(app, GetChatHistory)
+	vbeam.RegisterProc(app, SetChatDisplayName)
 }
 
+const MIN_CHAT_DISPLAY_NAME_LENGTH = 2
+const MAX_CHAT_DISPLAY_NAME_LENGTH = 32
+
 // ChatMessage represents a single chat message in a room
 type ChatMessage struct {
 	Id           int       `json:"id"`        // Auto-incremented unique ID
@@ -27,11 +34,16 @@ type ChatMessage struct {
 	UserName     string    `json:"userName"`  // Display name for message
 	Text         string    `json:"text"`      // Message content (max 500 chars)
 	Timestamp    time.Time `json:"timestamp"` // When message was sent
+	StreamId     int       `json:"streamId"`  // Stream session it was sent during (0 if the room was offline)
+
+	ReplyToId   int            `json:"replyToId"`   // Message this one answers (0 if none)
+	ReplyToName string         `json:"replyToName"` // Author of that message, kept if it's deleted
+	Reactions   []ChatReaction `json:"reactions"`   // Emoji reactions, in the order first used
 }
 
 // Pack function for serialization
 func PackChatMessage(self *ChatMessage, buf *vpack.Buffer) {
-	vpack.Version(1, buf)
+	version := vpack.Version(3, buf)
 	vpack.Int(&self.Id, buf)
 	vpack.Int(&self.RoomId, buf)
 	vpack.Int(&self.UserId, buf)
@@ -39,6 +51,18 @@ func PackChatMessage(self *ChatMessage, buf *vpack.Buffer) {
 	vpack.String(&self.UserName, buf)
 	vpack.String(&self.Text, buf)
 	vpack.Time(&self.Timestamp, buf)
+
+	// Version 2: Stream archives
+	if version >= 2 {
+		vpack.Int(&self.StreamId, buf)
+	}
+
+	// Version 3: Replies and reactions
+	if version >= 3 {
+		vpack.Int(&self.ReplyToId, buf)
+		vpack.String(&self.ReplyToName, buf)
+		vpack.Slice(&self.Reactions, PackChatReaction, buf)
+	}
 }
 
 // Database buckets and indexes
@@ -49,24 +73,48 @@ var ChatMessagesBkt = vbolt.Bucket(&cfg.Info, "chat_messages", vpack.FInt, PackC
 // ChatByRoomIdx finds all messages for a room (term=roomId, target=messageId)
 var ChatByRoomIdx = vbolt.Index(&cfg.Info, "chat_by_room", vpack.FInt, vpack.FInt)
 
+// ChatByStreamIdx finds a stream session's messages after the live chat is cleared (term=streamId, target=messageId)
+var ChatByStreamIdx = vbolt.Index(&cfg.Info, "chat_by_stream", vpack.FInt, vpack.FInt)
+
+// ChatByUserIdx finds a signed-in user's messages across rooms (term=userId, target=messageId)
+var ChatByUserIdx = vbolt.Index(&cfg.Info, "chat_by_user", vpack.FInt, vpack.FInt)
+
 // API Request/Response types
 
 type SendChatMessageRequest struct {
-	RoomId int    `json:"roomId"`
-	Text   string `json:"text"`
+	RoomId    int    `json:"roomId"`
+	Text      string `json:"text"`
+	ReplyToId int    `json:"replyToId"` // Optional: message being replied to
 }
 
 type SendChatMessageResponse struct {
 	Message ChatMessage `json:"message"`
+	Held    bool        `json:"held"` // Waiting for a moderator; Message is empty
 }
 
 type GetChatHistoryRequest struct {
-	RoomId int `json:"roomId"`
-	Limit  int `json:"limit"` // Default: 100, max: 500
+	RoomId   int `json:"roomId"`
+	Limit    int `json:"limit"`    // Default: 100, max: 500
+	BeforeId int `json:"beforeId"` // Only messages older than this ID (loading earlier chat)
+	AfterId  int `json:"afterId"`  // Only messages newer than this ID (catching up after a gap)
 }
 
 type GetChatHistoryResponse struct {
-	Messages []ChatMessage `json:"messages"`
+	Messages    []ChatMessage    `json:"messages"` // Oldest first
+	HasMore     bool             `json:"hasMore"`  // More messages exist past this page
+	Settings    ChatRoomSettings `json:"settings"`
+	CanModerate bool             `json:"canModerate"`
+	CanPin      bool             `json:"canPin"`    // May set the pinned announcement (chat moderators)
+	IsGuest     bool             `json:"isGuest"`   // Code session (no account)
+	GuestName   string           `json:"guestName"` // Guest's chosen chat name ("" until set)
+}
+
+type SetChatDisplayNameRequest struct {
+	Name string `json:"name"`
+}
+
+type SetChatDisplayNameResponse struct {
+	Name string `json:"name"`
 }
 
 // SendChatMessage handles sending a new chat message
@@ -93,7 +141,20 @@ func SendChatMessage(ctx *vbeam.Context, req SendChatMessageRequest) (resp SendC
 		return resp, errors.New("Message must be 500 characters or less")
 	}
 
-	// 4. Rate limiting (1 message per 2 seconds)
+	var replyTo ChatMessage
+	if req.ReplyToId > 0 {
+		vbolt.Read(ctx.Tx, ChatMessagesBkt, req.ReplyToId, &replyTo)
+		if replyTo.Id == 0 || replyTo.RoomId != room.Id {
+			return resp, errors.New("The message you're replying to is no longer available")
+		}
+	}
+
+	// 4. Moderation: restrictions, disabled chat and slow mode
+	if err := checkChatAllowed(ctx, user, room); err != nil {
+		return resp, err
+	}
+
+	// 5. Rate limiting (1 message per 2 seconds)
 	// Use user ID for JWT users and code sessions (code sessions have userId = -1)
 	// For code sessions, we could use session token but userId=-1 works for rate limiting
 	identifier := fmt.Sprintf("%d-%s", user.Id, ctx.Token)
@@ -107,30 +168,55 @@ func SendChatMessage(ctx *vbeam.Context, req SendChatMessageRequest) (resp SendC
 		return resp, errors.New("Please wait before sending another message")
 	}
 
-	// 5. Determine display name
+	// 6. Determine display name
 	userName := user.Name
+	var sessionToken string
 	if user.Id == -1 {
-		// code session
-		userName = "Viewer"
+		// code session; the token lets moderators act on the sender
+		sessionToken = codeSessionTokenFromContext(ctx)
+		userName = codeSessionDisplayName(ctx.Tx, sessionToken)
+	}
+
+	// 7. Content filter (moderators are exempt)
+	if !canModerateChat(ctx.Tx, user, room.StudioId) {
+		filter := getChatFilterSettings(ctx.Tx, room.StudioId)
+		if reason := filterChatText(filter, text); reason != "" {
+			entry := recordFilteredChatMessage(ctx, filter, ChatFilterEntry{
+				StudioId:     room.StudioId,
+				RoomId:       room.Id,
+				UserId:       user.Id,
+				SessionToken: sessionToken,
+				UserName:     userName,
+				Text:         text,
+				Reason:       reason,
+				ReplyToId:    replyTo.Id,
+				ReplyToName:  replyTo.UserName,
+			})
+			if entry.Status == ChatFilterStatusPending {
+				return SendChatMessageResponse{Held: true}, nil
+			}
+			return resp, errors.New("Your message was blocked by the chat filter")
+		}
 	}
 
-	// 6. Create message
+	// 8. Create message
 	vbeam.UseWriteTx(ctx)
 	msg := ChatMessage{
-		Id:        vbolt.NextIntId(ctx.Tx, ChatMessagesBkt),
-		RoomId:    req.RoomId,
-		UserId:    user.Id,
-		UserName:  userName,
-		Text:      text,
-		Timestamp: time.Now(),
+		RoomId:       req.RoomId,
+		UserId:       user.Id,
+		SessionToken: sessionToken,
+		UserName:     userName,
+		Text:         text,
+		Timestamp:    time.Now(),
+		ReplyToId:    replyTo.Id,
+		ReplyToName:  replyTo.UserName,
 	}
 
-	// 7. Store message in database
-	vbolt.Write(ctx.Tx, ChatMessagesBkt, msg.Id, &msg)
-	vbolt.SetTargetSingleTerm(ctx.Tx, ChatByRoomIdx, msg.Id, msg.RoomId)
+	// 9. Store message in database
+	saveChatMessageTx(ctx.Tx, &msg)
 	vbolt.TxCommit(ctx.Tx)
 
-	// 8. Broadcast to all viewers via SSE
+	// 10. Broadcast to all viewers via SSE
 	sseManager.BroadcastChatMessage(msg.RoomId, msg)
 
 	LogDebug(LogCategorySystem, "Chat message sent", map[string]interface{}{
@@ -160,21 +246,23 @@ func GetChatHistory(ctx *vbeam.Context, req GetChatHistoryRequest) (resp GetChat
 
 	// 3. Set default limit
 	limit := req.Limit
-	if limit == 0 {
+	if limit <= 0 {
 		limit = 100
 	}
 	if limit > 500 {
 		limit = 500
 	}
+	if req.BeforeId > 0 && req.AfterId > 0 {
+		return resp, errors.New("Use either beforeId or afterId, not both")
+	}
 
-	// 4. Query messages using index
-	var messageIds []int
-	vbolt.ReadTermTargets(ctx.Tx, ChatByRoomIdx, req.RoomId, &messageIds, vbolt.Window{
-		Offset: 0,
-		Limit:  limit,
-	})
+	// 4. Query messages using index and page by cursor
+	var allIds []int
+	vbolt.ReadTermTargets(ctx.Tx, ChatByRoomIdx, req.RoomId, &allIds, vbolt.Window{})
+	messageIds, hasMore := pageChatMessageIds(allIds, req.BeforeId, req.AfterId, limit)
 
 	// 5. Load full message objects
+	viewerId := chatViewerId(ctx, user)
 	messages := []ChatMessage{}
 	for _, id := range messageIds {
 		var msg ChatMessage
@@ -182,6 +270,7 @@ func GetChatHistory(ctx *vbeam.Context, req GetChatHistoryRequest) (resp GetChat
 		if msg.Id != 0 {
 			// Don't expose session token to frontend
 			msg.SessionToken = ""
+			msg.Reactions = reactionsForViewer(msg.Reactions, viewerId)
 			messages = append(messages, msg)
 		}
 	}
@@ -192,11 +281,129 @@ func GetChatHistory(ctx *vbeam.Context, req GetChatHistoryRequest) (resp GetChat
 		"messageCount": len(messages),
 	})
 
-	return GetChatHistoryResponse{Messages: messages}, nil
+	resp = GetChatHistoryResponse{
+		Messages:    messages,
+		HasMore:     hasMore,
+		Settings:    getChatRoomSettings(ctx.Tx, req.RoomId),
+		CanModerate: canModerateChat(ctx.Tx, user, room.StudioId),
+		CanPin:      user.Id > 0 && HasStudioCapability(ctx.Tx, user.Id, room.StudioId, CapModerateChat),
+		IsGuest:     user.Id == -1,
+	}
+	if resp.IsGuest {
+		var session CodeSession
+		vbolt.Read(ctx.Tx, CodeSessionsBkt, codeSessionTokenFromContext(ctx), &session)
+		resp.GuestName = session.DisplayName
+	}
+	return resp, nil
+}
+
+// pageChatMessageIds picks one page from a room's message IDs (ascending).
+// With afterId it returns the oldest messages after the cursor; otherwise the
+// newest messages before beforeId (or the newest overall when it is 0).
+func pageChatMessageIds(ids []int, beforeId int, afterId int, limit int) (page []int, hasMore bool) {
+	if afterId > 0 {
+		start := sort.SearchInts(ids, afterId+1)
+		end := min(start+limit, len(ids))
+		return ids[start:end], end < len(ids)
+	}
+	end := len(ids)
+	if beforeId > 0 {
+		end = sort.SearchInts(ids, beforeId)
+	}
+	start := max(end-limit, 0)
+	return ids[start:end], start > 0
+}
+
+// normalizeChatDisplayName collapses whitespace in a guest chat name and checks
+// its length and characters
+func normalizeChatDisplayName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	length := utf8.RuneCountInString(name)
+	if length < MIN_CHAT_DISPLAY_NAME_LENGTH || length > MAX_CHAT_DISPLAY_NAME_LENGTH {
+		return "", fmt.Errorf("Name must be between %d and %d characters", MIN_CHAT_DISPLAY_NAME_LENGTH, MAX_CHAT_DISPLAY_NAME_LENGTH)
+	}
+	hasLetter := false
+	for _, r := range name {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		} else if !unicode.IsDigit(r) && r != ' ' && !strings.ContainsRune("-'.&", r) {
+			return "", errors.New("Name can only contain letters, numbers, spaces and - ' . &")
+		}
+	}
+	if !hasLetter {
+		return "", errors.New("Name must contain a letter")
+	}
+	return name, nil
+}
+
+// codeSessionDisplayName is the name a code session's messages are sent under
+func codeSessionDisplayName(tx *vbolt.Tx, sessionToken string) string {
+	var session CodeSession
+	vbolt.Read(tx, CodeSessionsBkt, sessionToken, &session)
+	if session.DisplayName == "" {
+		return "Viewer"
+	}
+	return session.DisplayName
+}
+
+// SetChatDisplayName lets a code-session viewer choose the name shown on their
+// chat messages. Names go through the studio's chat filter.
+func SetChatDisplayName(ctx *vbeam.Context, req SetChatDisplayNameRequest) (resp SetChatDisplayNameResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, ErrAuthFailure
+	}
+	if user.Id != -1 {
+		return resp, errors.New("Signed-in users chat under their account name")
+	}
+
+	sessionToken := codeSessionTokenFromContext(ctx)
+	valid, session, accessCode, reason := ValidateCodeSession(ctx.Tx, sessionToken)
+	if !valid {
+		return resp, errors.New(reason)
+	}
+
+	name, err := normalizeChatDisplayName(req.Name)
+	if err != nil {
+		return
+	}
+	studioId := codeStudioId(ctx.Tx, accessCode)
+	if filterChatText(getChatFilterSettings(ctx.Tx, studioId), name) != "" {
+		return resp, errors.New("That name isn't allowed in this chat")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	session.DisplayName = name
+	vbolt.Write(ctx.Tx, CodeSessionsBkt, session.Token, &session)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Guest chat name set", map[string]interface{}{
+		"code":     session.Code,
+		"studioId": studioId,
+		"name":     name,
+	})
+
+	resp.Name = name
+	return
+}
+
+// saveChatMessageTx stores a new message in its room's live chat and in the
+// archive of the stream that's live, if any
+func saveChatMessageTx(tx *vbolt.Tx, msg *ChatMessage) {
+	msg.Id = vbolt.NextIntId(tx, ChatMessagesBkt)
+	msg.StreamId = liveStream(tx, msg.RoomId).Id
+	vbolt.Write(tx, ChatMessagesBkt, msg.Id, msg)
+	vbolt.SetTargetSingleTerm(tx, ChatByRoomIdx, msg.Id, msg.RoomId)
+	if msg.StreamId != 0 {
+		vbolt.SetTargetSingleTerm(tx, ChatByStreamIdx, msg.Id, msg.StreamId)
+	}
+	if msg.UserId > 0 {
+		vbolt.SetTargetSingleTerm(tx, ChatByUserIdx, msg.Id, msg.UserId)
+	}
 }
 
-// DeleteChatMessagesForRoom deletes all chat messages for a room
-// Called when a stream ends
+// DeleteChatMessagesForRoom deletes all chat messages in a room's live chat,
+// including their stream archive entries
 func DeleteChatMessagesForRoom(tx *vbolt.Tx, roomId int) error {
 	// 1. Get all message IDs for the room
 	var messageIds []int
@@ -210,6 +417,8 @@ func DeleteChatMessagesForRoom(tx *vbolt.Tx, roomId int) error {
 	// 3. Remove all index entries for this room
 	for _, msgId := range messageIds {
 		vbolt.SetTargetSingleTerm(tx, ChatByRoomIdx, msgId, -1)
+		vbolt.SetTargetSingleTerm(tx, ChatByStreamIdx, msgId, -1)
+		vbolt.SetTargetSingleTerm(tx, ChatByUserIdx, msgId, -1)
 	}
 
 	LogInfo(LogCategorySystem, "Deleted chat messages for room", map[string]interface{}{
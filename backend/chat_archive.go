@@ -0,0 +1,442 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"stream/cfg"
+	"strings"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const CHAT_RETENTION_OFF = 0      // Chat is cleared when the stream ends
+const CHAT_RETENTION_FOREVER = -1 // Archives are never cleaned up
+const DEFAULT_CHAT_RETENTION_DAYS = 90
+const MAX_CHAT_RETENTION_DAYS = 3650
+const CHAT_ARCHIVE_CLEANUP_INTERVAL = 6 * time.Hour
+
+// ChatArchiveSettings controls how long a studio keeps each stream's chat.
+// Studios without a record keep it for DEFAULT_CHAT_RETENTION_DAYS.
+type ChatArchiveSettings struct {
+	StudioId      int       `json:"studioId"`
+	RetentionDays int       `json:"retentionDays"` // CHAT_RETENTION_OFF, CHAT_RETENTION_FOREVER or days after the stream ends
+	UpdatedBy     int       `json:"updatedBy"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func PackChatArchiveSettings(self *ChatArchiveSettings, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.Int(&self.RetentionDays, buf)
+	vpack.Int(&self.UpdatedBy, buf)
+	vpack.Time(&self.UpdatedAt, buf)
+}
+
+// ChatArchiveSettingsBkt: studioId -> ChatArchiveSettings
+var ChatArchiveSettingsBkt = vbolt.Bucket(&cfg.Info, "chat_archive_settings", vpack.FInt, PackChatArchiveSettings)
+
+func getChatArchiveSettings(tx *vbolt.Tx, studioId int) ChatArchiveSettings {
+	settings := ChatArchiveSettings{StudioId: studioId, RetentionDays: DEFAULT_CHAT_RETENTION_DAYS}
+	vbolt.Read(tx, ChatArchiveSettingsBkt, studioId, &settings)
+	return settings
+}
+
+// archiveExpiresAt is when a stream's chat archive will be cleaned up (zero if
+// never). With retention off, archives kept under an earlier setting are
+// already due.
+func archiveExpiresAt(settings ChatArchiveSettings, stream Stream) time.Time {
+	if stream.EndTime.IsZero() || settings.RetentionDays == CHAT_RETENTION_FOREVER {
+		return time.Time{}
+	}
+	if settings.RetentionDays == CHAT_RETENTION_OFF {
+		return stream.EndTime
+	}
+	return stream.EndTime.AddDate(0, 0, settings.RetentionDays)
+}
+
+// streamChatMessagesTx returns a stream's archived messages, oldest first
+func streamChatMessagesTx(tx *vbolt.Tx, streamId int) []ChatMessage {
+	var messageIds []int
+	vbolt.ReadTermTargets(tx, ChatByStreamIdx, streamId, &messageIds, vbolt.Window{})
+	messages := make([]ChatMessage, 0, len(messageIds))
+	for _, id := range messageIds {
+		var msg ChatMessage
+		vbolt.Read(tx, ChatMessagesBkt, id, &msg)
+		if msg.Id != 0 {
+			msg.SessionToken = ""
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// archiveRoomChatTx clears a room's live chat when its stream ends. Messages
+// sent during a stream stay in that stream's archive unless the studio doesn't
+// keep chat; the rest are deleted.
+func archiveRoomChatTx(tx *vbolt.Tx, room Room) (archived int, deleted int) {
+	keep := getChatArchiveSettings(tx, room.StudioId).RetentionDays != CHAT_RETENTION_OFF
+
+	var messageIds []int
+	vbolt.ReadTermTargets(tx, ChatByRoomIdx, room.Id, &messageIds, vbolt.Window{})
+	for _, msgId := range messageIds {
+		var msg ChatMessage
+		vbolt.Read(tx, ChatMessagesBkt, msgId, &msg)
+		vbolt.SetTargetSingleTerm(tx, ChatByRoomIdx, msgId, -1)
+		if keep && msg.StreamId != 0 {
+			archived++
+			continue
+		}
+		vbolt.SetTargetSingleTerm(tx, ChatByStreamIdx, msgId, -1)
+		vbolt.SetTargetSingleTerm(tx, ChatByUserIdx, msgId, -1)
+		vbolt.Delete(tx, ChatMessagesBkt, msgId)
+		deleted++
+	}
+
+	LogInfo(LogCategorySystem, "Archived chat for room", map[string]interface{}{
+		"roomId":   room.Id,
+		"archived": archived,
+		"deleted":  deleted,
+	})
+	return
+}
+
+// deleteStreamChatTx deletes a stream's chat archive
+func deleteStreamChatTx(tx *vbolt.Tx, streamId int) int {
+	var messageIds []int
+	vbolt.ReadTermTargets(tx, ChatByStreamIdx, streamId, &messageIds, vbolt.Window{})
+	for _, msgId := range messageIds {
+		vbolt.SetTargetSingleTerm(tx, ChatByStreamIdx, msgId, -1)
+		vbolt.SetTargetSingleTerm(tx, ChatByRoomIdx, msgId, -1)
+		vbolt.SetTargetSingleTerm(tx, ChatByUserIdx, msgId, -1)
+		vbolt.Delete(tx, ChatMessagesBkt, msgId)
+	}
+	return len(messageIds)
+}
+
+// CleanupExpiredChatArchives deletes the chat of streams that ended longer ago
+// than their studio's retention. Returns the number of messages deleted.
+func CleanupExpiredChatArchives(db *vbolt.DB) int {
+	now := time.Now()
+	var expired []int
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		settingsByStudio := make(map[int]ChatArchiveSettings)
+		vbolt.IterateAll(tx, StreamsBkt, func(id int, stream Stream) bool {
+			settings, ok := settingsByStudio[stream.StudioId]
+			if !ok {
+				settings = getChatArchiveSettings(tx, stream.StudioId)
+				settingsByStudio[stream.StudioId] = settings
+			}
+			expiresAt := archiveExpiresAt(settings, stream)
+			if !expiresAt.IsZero() && now.After(expiresAt) {
+				expired = append(expired, stream.Id)
+			}
+			return true
+		})
+	})
+
+	deleted := 0
+	if len(expired) == 0 {
+		return deleted
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		for _, streamId := range expired {
+			deleted += deleteStreamChatTx(tx, streamId)
+		}
+		vbolt.TxCommit(tx)
+	})
+
+	if deleted > 0 {
+		LogInfo(LogCategorySystem, "Cleaned up expired chat archives", map[string]interface{}{
+			"messagesDeleted": deleted,
+		})
+	}
+	return deleted
+}
+
+// StartChatArchiveCleanup starts a background goroutine that applies each
+// studio's chat retention
+func StartChatArchiveCleanup(db *vbolt.DB) {
+	LogInfo(LogCategorySystem, "Starting chat archive cleanup job", map[string]interface{}{
+		"interval": CHAT_ARCHIVE_CLEANUP_INTERVAL.String(),
+	})
+
+	go func() {
+		CleanupExpiredChatArchives(db)
+
+		ticker := time.NewTicker(CHAT_ARCHIVE_CLEANUP_INTERVAL)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			CleanupExpiredChatArchives(db)
+		}
+	}()
+}
+
+// formatStreamOffset renders seconds since the stream started as H:MM:SS
+func formatStreamOffset(seconds int) string {
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// streamOffsetSeconds is how far into the stream a message was sent
+func streamOffsetSeconds(stream Stream, msg ChatMessage) int {
+	offset := int(msg.Timestamp.Sub(stream.StartTime).Seconds())
+	if offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+func RegisterChatArchiveMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, GetChatArchiveSettings)
+	vbeam.RegisterProc(app, UpdateChatArchiveSettings)
+	vbeam.RegisterProc(app, ListChatArchives)
+	vbeam.RegisterProc(app, GetStreamChatReplay)
+	vbeam.RegisterProc(app, ExportChatTranscript)
+}
+
+// API Types
+
+type GetChatArchiveSettingsRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type GetChatArchiveSettingsResponse struct {
+	Settings ChatArchiveSettings `json:"settings"`
+}
+
+type UpdateChatArchiveSettingsRequest struct {
+	StudioId      int `json:"studioId"`
+	RetentionDays int `json:"retentionDays"`
+}
+
+type UpdateChatArchiveSettingsResponse struct {
+	Settings ChatArchiveSettings `json:"settings"`
+}
+
+type ListChatArchivesRequest struct {
+	StudioId int `json:"studioId"`
+	Limit    int `json:"limit"` // Default: 50
+}
+
+type ChatArchiveItem struct {
+	Stream       Stream    `json:"stream"`
+	RoomName     string    `json:"roomName"`
+	MessageCount int       `json:"messageCount"`
+	ExpiresAt    time.Time `json:"expiresAt"` // Zero if kept forever
+}
+
+type ListChatArchivesResponse struct {
+	Archives []ChatArchiveItem `json:"archives"`
+}
+
+type GetStreamChatReplayRequest struct {
+	StreamId int `json:"streamId"`
+}
+
+type ReplayChatMessage struct {
+	ChatMessage
+	OffsetSeconds int `json:"offsetSeconds"` // Seconds since the stream started
+}
+
+type GetStreamChatReplayResponse struct {
+	Stream   Stream              `json:"stream"`
+	RoomName string              `json:"roomName"`
+	Messages []ReplayChatMessage `json:"messages"`
+}
+
+type ExportChatTranscriptRequest struct {
+	StreamId int `json:"streamId"`
+}
+
+type ExportChatTranscriptResponse struct {
+	FileName   string `json:"fileName"`
+	Transcript string `json:"transcript"`
+}
+
+// archivedStream loads a stream the caller can read the chat archive of
+func archivedStream(ctx *vbeam.Context, streamId int, capability StudioCapability) (caller User, stream Stream, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return caller, stream, errors.New("Authentication required")
+	}
+	stream = GetStream(ctx.Tx, streamId)
+	if stream.Id == 0 {
+		return caller, stream, errors.New("Stream not found")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, stream.StudioId, capability) {
+		return caller, stream, errors.New("Access denied")
+	}
+	return
+}
+
+// API Procedures
+
+// GetChatArchiveSettings returns a studio's chat retention
+func GetChatArchiveSettings(ctx *vbeam.Context, req GetChatArchiveSettingsRequest) (resp GetChatArchiveSettingsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, req.StudioId, CapViewStudio) {
+		return resp, errors.New("Access denied")
+	}
+
+	resp.Settings = getChatArchiveSettings(ctx.Tx, req.StudioId)
+	return
+}
+
+// UpdateChatArchiveSettings changes how long a studio keeps stream chat.
+// Existing archives follow the new retention from the next cleanup.
+func UpdateChatArchiveSettings(ctx *vbeam.Context, req UpdateChatArchiveSettingsRequest) (resp UpdateChatArchiveSettingsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if GetStudioById(ctx.Tx, req.StudioId).Id == 0 {
+		return resp, errors.New("Studio not found")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, req.StudioId, CapModerateChat) {
+		return resp, errors.New("Only chat moderators can do that")
+	}
+	if req.RetentionDays < CHAT_RETENTION_FOREVER || req.RetentionDays > MAX_CHAT_RETENTION_DAYS {
+		return resp, fmt.Errorf("Retention must be off, forever or 1 to %d days", MAX_CHAT_RETENTION_DAYS)
+	}
+
+	settings := ChatArchiveSettings{
+		StudioId:      req.StudioId,
+		RetentionDays: req.RetentionDays,
+		UpdatedBy:     caller.Id,
+		UpdatedAt:     time.Now(),
+	}
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.Write(ctx.Tx, ChatArchiveSettingsBkt, settings.StudioId, &settings)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Chat retention updated", map[string]interface{}{
+		"studioId":      settings.StudioId,
+		"retentionDays": settings.RetentionDays,
+		"updatedBy":     caller.Id,
+	})
+
+	resp.Settings = settings
+	return
+}
+
+// ListChatArchives returns a studio's ended streams that still have chat,
+// newest first
+func ListChatArchives(ctx *vbeam.Context, req ListChatArchivesRequest) (resp ListChatArchivesResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, req.StudioId, CapViewStudio) {
+		return resp, errors.New("Access denied")
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	settings := getChatArchiveSettings(ctx.Tx, req.StudioId)
+	var streamIds []int
+	vbolt.ReadTermTargets(ctx.Tx, StreamsByStudioIdx, req.StudioId, &streamIds, vbolt.Window{})
+	roomNames := make(map[int]string)
+	resp.Archives = []ChatArchiveItem{}
+	for i := len(streamIds) - 1; i >= 0 && len(resp.Archives) < limit; i-- {
+		stream := GetStream(ctx.Tx, streamIds[i])
+		if stream.Id == 0 || stream.EndTime.IsZero() {
+			continue
+		}
+		var messageIds []int
+		vbolt.ReadTermTargets(ctx.Tx, ChatByStreamIdx, stream.Id, &messageIds, vbolt.Window{})
+		if len(messageIds) == 0 {
+			continue
+		}
+		if _, ok := roomNames[stream.RoomId]; !ok {
+			roomNames[stream.RoomId] = GetRoom(ctx.Tx, stream.RoomId).Name
+		}
+		resp.Archives = append(resp.Archives, ChatArchiveItem{
+			Stream:       stream,
+			RoomName:     roomNames[stream.RoomId],
+			MessageCount: len(messageIds),
+			ExpiresAt:    archiveExpiresAt(settings, stream),
+		})
+	}
+	return
+}
+
+// GetStreamChatReplay returns a stream's chat with each message's offset from
+// the start of the stream, for playing back alongside its recording
+func GetStreamChatReplay(ctx *vbeam.Context, req GetStreamChatReplayRequest) (resp GetStreamChatReplayResponse, err error) {
+	_, stream, err := archivedStream(ctx, req.StreamId, CapViewStudio)
+	if err != nil {
+		return
+	}
+
+	resp.Stream = stream
+	resp.RoomName = GetRoom(ctx.Tx, stream.RoomId).Name
+	resp.Messages = []ReplayChatMessage{}
+	for _, msg := range streamChatMessagesTx(ctx.Tx, stream.Id) {
+		resp.Messages = append(resp.Messages, ReplayChatMessage{
+			ChatMessage:   msg,
+			OffsetSeconds: streamOffsetSeconds(stream, msg),
+		})
+	}
+	return
+}
+
+// ExportChatTranscript renders a stream's chat as a plain-text transcript
+func ExportChatTranscript(ctx *vbeam.Context, req ExportChatTranscriptRequest) (resp ExportChatTranscriptResponse, err error) {
+	caller, stream, err := archivedStream(ctx, req.StreamId, CapModerateChat)
+	if err != nil {
+		return
+	}
+
+	roomName := GetRoom(ctx.Tx, stream.RoomId).Name
+	messages := streamChatMessagesTx(ctx.Tx, stream.Id)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Chat transcript: %s\n", roomName)
+	fmt.Fprintf(&b, "Stream started %s", stream.StartTime.Format(time.RFC1123))
+	if !stream.EndTime.IsZero() {
+		fmt.Fprintf(&b, ", ended %s", stream.EndTime.Format(time.RFC1123))
+	}
+	fmt.Fprintf(&b, "\n%d messages\n\n", len(messages))
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", formatStreamOffset(streamOffsetSeconds(stream, msg)), msg.UserName, msg.Text)
+	}
+
+	LogInfo(LogCategorySystem, "Chat transcript exported", map[string]interface{}{
+		"streamId":   stream.Id,
+		"studioId":   stream.StudioId,
+		"messages":   len(messages),
+		"exportedBy": caller.Id,
+	})
+
+	resp.FileName = fmt.Sprintf("chat-%s-%s.txt", stream.StartTime.Format("2006-01-02-1504"), slugifyFileName(roomName))
+	resp.Transcript = b.String()
+	return
+}
+
+// slugifyFileName keeps letters and digits from a name for use in a file name
+func slugifyFileName(name string) string {
+	slug := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name))
+	slug = strings.Trim(slug, "-")
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	if slug == "" {
+		return "room"
+	}
+	return slug
+}
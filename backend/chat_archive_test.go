@@ -0,0 +1,169 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestChatArchives(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+
+	call := func(userId int, fn func(ctx *vbeam.Context) error) (err error) {
+		token, _ := createTestToken(userId)
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			err = fn(&vbeam.Context{Tx: tx, Token: token})
+		})
+		return
+	}
+	send := func(text string) (msg ChatMessage) {
+		globalRateLimiter.Reset()
+		err := call(member.Id, func(ctx *vbeam.Context) error {
+			resp, err := SendChatMessage(ctx, SendChatMessageRequest{RoomId: room.Id, Text: text})
+			msg = resp.Message
+			return err
+		})
+		if err != nil {
+			t.Fatalf("SendChatMessage failed: %v", err)
+		}
+		return
+	}
+	runStream := func(texts ...string) (stream Stream) {
+		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+			stream = startStreamTx(tx, room)
+			vbolt.TxCommit(tx)
+		})
+		for _, text := range texts {
+			if msg := send(text); msg.StreamId != stream.Id {
+				t.Errorf("Expected the message to belong to stream %d, got %d", stream.Id, msg.StreamId)
+			}
+		}
+		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+			endStreamTx(tx, room.Id)
+			archiveRoomChatTx(tx, room)
+			vbolt.TxCommit(tx)
+		})
+		return
+	}
+
+	// Chat sent while offline is not archived
+	if msg := send("anyone here?"); msg.StreamId != 0 {
+		t.Errorf("Expected offline chat to have no stream, got %d", msg.StreamId)
+	}
+	first := runStream("hello", "great class")
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if live := liveStream(tx, room.Id); live.Id != 0 {
+			t.Errorf("Expected no live stream after it ended, got %d", live.Id)
+		}
+	})
+
+	var history GetChatHistoryResponse
+	call(member.Id, func(ctx *vbeam.Context) (err error) {
+		history, err = GetChatHistory(ctx, GetChatHistoryRequest{RoomId: room.Id})
+		return
+	})
+	if len(history.Messages) != 0 {
+		t.Errorf("Expected the live chat to be cleared, got %d messages", len(history.Messages))
+	}
+
+	var archives ListChatArchivesResponse
+	call(member.Id, func(ctx *vbeam.Context) (err error) {
+		archives, err = ListChatArchives(ctx, ListChatArchivesRequest{StudioId: room.StudioId})
+		return
+	})
+	if len(archives.Archives) != 1 || archives.Archives[0].MessageCount != 2 || archives.Archives[0].ExpiresAt.IsZero() {
+		t.Fatalf("Unexpected archives: %+v", archives.Archives)
+	}
+
+	var replay GetStreamChatReplayResponse
+	call(member.Id, func(ctx *vbeam.Context) (err error) {
+		replay, err = GetStreamChatReplay(ctx, GetStreamChatReplayRequest{StreamId: first.Id})
+		return
+	})
+	if len(replay.Messages) != 2 || replay.Messages[0].Text != "hello" || replay.Messages[0].OffsetSeconds < 0 {
+		t.Errorf("Unexpected replay: %+v", replay.Messages)
+	}
+
+	exportTranscript := func(userId int) (resp ExportChatTranscriptResponse, err error) {
+		err = call(userId, func(ctx *vbeam.Context) (err error) {
+			resp, err = ExportChatTranscript(ctx, ExportChatTranscriptRequest{StreamId: first.Id})
+			return
+		})
+		return
+	}
+	if _, err := exportTranscript(member.Id); err == nil {
+		t.Errorf("Expected non-moderators to be refused the transcript")
+	}
+	transcript, err := exportTranscript(admin.Id)
+	if err != nil {
+		t.Fatalf("ExportChatTranscript failed: %v", err)
+	}
+	if !strings.Contains(transcript.Transcript, "[0:00:00] "+member.Name+": hello") || !strings.HasSuffix(transcript.FileName, ".txt") {
+		t.Errorf("Unexpected transcript %q:\n%s", transcript.FileName, transcript.Transcript)
+	}
+
+	// Studios that don't keep chat clear it when the stream ends
+	updateRetention := func(userId int, days int) error {
+		return call(userId, func(ctx *vbeam.Context) error {
+			_, err := UpdateChatArchiveSettings(ctx, UpdateChatArchiveSettingsRequest{StudioId: room.StudioId, RetentionDays: days})
+			return err
+		})
+	}
+	if err := updateRetention(member.Id, CHAT_RETENTION_OFF); err == nil {
+		t.Errorf("Expected non-moderators to be refused")
+	}
+	if err := updateRetention(admin.Id, MAX_CHAT_RETENTION_DAYS+1); err == nil {
+		t.Errorf("Expected an out-of-range retention to be refused")
+	}
+	if err := updateRetention(admin.Id, CHAT_RETENTION_OFF); err != nil {
+		t.Fatalf("UpdateChatArchiveSettings failed: %v", err)
+	}
+	second := runStream("bye")
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if messages := streamChatMessagesTx(tx, second.Id); len(messages) != 0 {
+			t.Errorf("Expected no archive with retention off, got %d messages", len(messages))
+		}
+	})
+
+	// Archives older than the retention are cleaned up
+	if err := updateRetention(admin.Id, 1); err != nil {
+		t.Fatalf("UpdateChatArchiveSettings failed: %v", err)
+	}
+	if deleted := CleanupExpiredChatArchives(db); deleted != 0 {
+		t.Errorf("Expected a recent archive to be kept, deleted %d", deleted)
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		first.EndTime = time.Now().AddDate(0, 0, -2)
+		vbolt.Write(tx, StreamsBkt, first.Id, &first)
+		vbolt.TxCommit(tx)
+	})
+	if deleted := CleanupExpiredChatArchives(db); deleted != 2 {
+		t.Errorf("Expected the expired archive to be deleted, deleted %d", deleted)
+	}
+
+	// Turning retention off also clears the archives already kept
+	if err := updateRetention(admin.Id, CHAT_RETENTION_FOREVER); err != nil {
+		t.Fatalf("UpdateChatArchiveSettings failed: %v", err)
+	}
+	third := runStream("again")
+	if deleted := CleanupExpiredChatArchives(db); deleted != 0 {
+		t.Errorf("Expected archives kept forever to stay, deleted %d", deleted)
+	}
+	if err := updateRetention(admin.Id, CHAT_RETENTION_OFF); err != nil {
+		t.Fatalf("UpdateChatArchiveSettings failed: %v", err)
+	}
+	if deleted := CleanupExpiredChatArchives(db); deleted == 0 {
+		t.Errorf("Expected the archive to be cleaned up once retention is off")
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if messages := streamChatMessagesTx(tx, third.Id); len(messages) != 0 {
+			t.Errorf("Expected no archive left with retention off, got %d messages", len(messages))
+		}
+	})
+}
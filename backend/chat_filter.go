@@ -0,0 +1,456 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"stream/cfg"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const MAX_FILTER_TERMS = 500
+const MAX_FILTER_TERM_LENGTH = 50
+
+// defaultBlockedTerms is the built-in profanity list. Terms match whole words,
+// so "class" and "scrap" don't trip over shorter entries.
+var defaultBlockedTerms = []string{
+	"arse", "arsehole", "ass", "asshole", "bastard", "bitch", "bollocks",
+	"bullshit", "crap", "cunt", "damn", "dick", "dickhead", "douche",
+	"fag", "faggot", "fuck", "fucked", "fucker", "fucking", "goddamn",
+	"hell", "jackass", "motherfucker", "nigga", "nigger", "piss", "pissed",
+	"prick", "pussy", "retard", "shit", "shitty", "slut", "twat", "wanker",
+	"whore",
+}
+
+var chatLinkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9-]+\.(com|net|org|io|ly|gg|me|co|tv|app|xyz|info|link)\b`)
+
+// ChatFilterAction is what happens to a message the filter flags
+type ChatFilterAction int
+
+const (
+	ChatFilterBlock ChatFilterAction = 0 // Refuse the message
+	ChatFilterHold  ChatFilterAction = 1 // Queue it for a moderator
+)
+
+// ChatFilterStatus tracks a filtered message through review
+type ChatFilterStatus int
+
+const (
+	ChatFilterStatusBlocked  ChatFilterStatus = 0
+	ChatFilterStatusPending  ChatFilterStatus = 1
+	ChatFilterStatusApproved ChatFilterStatus = 2
+	ChatFilterStatusRejected ChatFilterStatus = 3
+)
+
+// ChatFilterSettings is a studio's content filter. Studios without a record
+// use the default profanity list and block what it catches.
+type ChatFilterSettings struct {
+	StudioId       int              `json:"studioId"`
+	Disabled       bool             `json:"disabled"`
+	UseDefaultList bool             `json:"useDefaultList"`
+	BlockedTerms   []string         `json:"blockedTerms"`
+	AllowedTerms   []string         `json:"allowedTerms"` // Exempt from the default and custom lists
+	BlockLinks     bool             `json:"blockLinks"`
+	Action         ChatFilterAction `json:"action"`
+	UpdatedBy      int              `json:"updatedBy"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+}
+
+// ChatFilterEntry records a message the filter caught, and for held
+// messages, the moderator's decision
+type ChatFilterEntry struct {
+	Id           int              `json:"id"`
+	StudioId     int              `json:"studioId"`
+	RoomId       int              `json:"roomId"`
+	UserId       int              `json:"userId"`
+	SessionToken string           `json:"-"`
+	UserName     string           `json:"userName"`
+	Text         string           `json:"text"`
+	Reason       string           `json:"reason"`
+	Status       ChatFilterStatus `json:"status"`
+	CreatedAt    time.Time        `json:"createdAt"`
+	ReviewedBy   int              `json:"reviewedBy"`
+	ReviewedAt   time.Time        `json:"reviewedAt"`
+	MessageId    int              `json:"messageId"` // Set once an approved message is posted
+	ReplyToId    int              `json:"replyToId"`
+	ReplyToName  string           `json:"replyToName"`
+}
+
+func PackChatFilterSettings(self *ChatFilterSettings, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.Bool(&self.Disabled, buf)
+	vpack.Bool(&self.UseDefaultList, buf)
+	vpack.Slice(&self.BlockedTerms, vpack.String, buf)
+	vpack.Slice(&self.AllowedTerms, vpack.String, buf)
+	vpack.Bool(&self.BlockLinks, buf)
+	vpack.Int((*int)(&self.Action), buf)
+	vpack.Int(&self.UpdatedBy, buf)
+	vpack.Time(&self.UpdatedAt, buf)
+}
+
+func PackChatFilterEntry(self *ChatFilterEntry, buf *vpack.Buffer) {
+	version := vpack.Version(2, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.Int(&self.RoomId, buf)
+	vpack.Int(&self.UserId, buf)
+	vpack.String(&self.SessionToken, buf)
+	vpack.String(&self.UserName, buf)
+	vpack.String(&self.Text, buf)
+	vpack.String(&self.Reason, buf)
+	vpack.Int((*int)(&self.Status), buf)
+	vpack.Time(&self.CreatedAt, buf)
+	vpack.Int(&self.ReviewedBy, buf)
+	vpack.Time(&self.ReviewedAt, buf)
+	vpack.Int(&self.MessageId, buf)
+
+	// Version 2: Replies
+	if version >= 2 {
+		vpack.Int(&self.ReplyToId, buf)
+		vpack.String(&self.ReplyToName, buf)
+	}
+}
+
+// ChatFilterSettingsBkt stores filter settings by studio ID
+var ChatFilterSettingsBkt = vbolt.Bucket(&cfg.Info, "chat_filter_settings", vpack.FInt, PackChatFilterSettings)
+
+// ChatFilterLogBkt stores filtered messages by ID
+var ChatFilterLogBkt = vbolt.Bucket(&cfg.Info, "chat_filter_log", vpack.FInt, PackChatFilterEntry)
+
+// ChatFilterLogByStudioIdx finds filtered messages for a studio (term=studioId, target=entryId)
+var ChatFilterLogByStudioIdx = vbolt.Index(&cfg.Info, "chat_filter_log_by_studio", vpack.FInt, vpack.FInt)
+
+// ChatFilterLogByUserIdx finds a signed-in user's filtered messages (term=userId, target=entryId)
+var ChatFilterLogByUserIdx = vbolt.Index(&cfg.Info, "chat_filter_log_by_user", vpack.FInt, vpack.FInt)
+
+func getChatFilterSettings(tx *vbolt.Tx, studioId int) ChatFilterSettings {
+	settings := ChatFilterSettings{StudioId: studioId, UseDefaultList: true}
+	vbolt.Read(tx, ChatFilterSettingsBkt, studioId, &settings)
+	if settings.BlockedTerms == nil {
+		settings.BlockedTerms = []string{}
+	}
+	if settings.AllowedTerms == nil {
+		settings.AllowedTerms = []string{}
+	}
+	return settings
+}
+
+// normalizeFilterText lowercases text and collapses everything that isn't a
+// letter or digit into single spaces, padded so terms can match on word edges
+func normalizeFilterText(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(fields, " ") + " "
+}
+
+// normalizeFilterTerms trims, lowercases and de-duplicates a term list
+func normalizeFilterTerms(terms []string) ([]string, error) {
+	if len(terms) > MAX_FILTER_TERMS {
+		return nil, fmt.Errorf("Lists can hold at most %d terms", MAX_FILTER_TERMS)
+	}
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, term := range terms {
+		term = strings.TrimSpace(normalizeFilterText(term))
+		if term == "" || seen[term] {
+			continue
+		}
+		if len(term) > MAX_FILTER_TERM_LENGTH {
+			return nil, fmt.Errorf("Terms must be %d characters or less", MAX_FILTER_TERM_LENGTH)
+		}
+		seen[term] = true
+		result = append(result, term)
+	}
+	return result, nil
+}
+
+// filterChatText runs a message through a studio's filter and returns why it
+// was flagged, or "" if it's clean
+func filterChatText(settings ChatFilterSettings, text string) string {
+	if settings.Disabled {
+		return ""
+	}
+
+	normalized := normalizeFilterText(text)
+	for _, allowed := range settings.AllowedTerms {
+		normalized = strings.ReplaceAll(normalized, " "+allowed+" ", " ")
+	}
+
+	var terms []string
+	if settings.UseDefaultList {
+		terms = append(terms, defaultBlockedTerms...)
+	}
+	terms = append(terms, settings.BlockedTerms...)
+	for _, term := range terms {
+		if strings.Contains(normalized, " "+term+" ") {
+			return fmt.Sprintf("Blocked term: %s", term)
+		}
+	}
+
+	if settings.BlockLinks && chatLinkPattern.MatchString(text) {
+		return "Link"
+	}
+	return ""
+}
+
+// recordFilteredChatMessage logs a flagged message, held for review or blocked
+// depending on the studio's filter action
+func recordFilteredChatMessage(ctx *vbeam.Context, settings ChatFilterSettings, entry ChatFilterEntry) ChatFilterEntry {
+	entry.Status = ChatFilterStatusBlocked
+	if settings.Action == ChatFilterHold {
+		entry.Status = ChatFilterStatusPending
+	}
+	entry.CreatedAt = time.Now()
+
+	vbeam.UseWriteTx(ctx)
+	entry.Id = vbolt.NextIntId(ctx.Tx, ChatFilterLogBkt)
+	vbolt.Write(ctx.Tx, ChatFilterLogBkt, entry.Id, &entry)
+	vbolt.SetTargetSingleTerm(ctx.Tx, ChatFilterLogByStudioIdx, entry.Id, entry.StudioId)
+	if entry.UserId > 0 {
+		vbolt.SetTargetSingleTerm(ctx.Tx, ChatFilterLogByUserIdx, entry.Id, entry.UserId)
+	}
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Chat message filtered", map[string]interface{}{
+		"studioId": entry.StudioId,
+		"roomId":   entry.RoomId,
+		"userId":   entry.UserId,
+		"entryId":  entry.Id,
+		"reason":   entry.Reason,
+		"status":   entry.Status,
+	})
+	return entry
+}
+
+// RegisterChatFilterMethods registers chat filter procedures
+func RegisterChatFilterMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, GetChatFilterSettings)
+	vbeam.RegisterProc(app, UpdateChatFilterSettings)
+	vbeam.RegisterProc(app, ListChatFilterLog)
+	vbeam.RegisterProc(app, ReviewHeldChatMessage)
+}
+
+// Request/Response types
+type GetChatFilterSettingsRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type GetChatFilterSettingsResponse struct {
+	Settings     ChatFilterSettings `json:"settings"`
+	DefaultTerms []string           `json:"defaultTerms"`
+}
+
+type UpdateChatFilterSettingsRequest struct {
+	StudioId       int              `json:"studioId"`
+	Disabled       bool             `json:"disabled"`
+	UseDefaultList bool             `json:"useDefaultList"`
+	BlockedTerms   []string         `json:"blockedTerms"`
+	AllowedTerms   []string         `json:"allowedTerms"`
+	BlockLinks     bool             `json:"blockLinks"`
+	Action         ChatFilterAction `json:"action"`
+}
+
+type UpdateChatFilterSettingsResponse struct {
+	Settings ChatFilterSettings `json:"settings"`
+}
+
+type ListChatFilterLogRequest struct {
+	StudioId    int  `json:"studioId"`
+	PendingOnly bool `json:"pendingOnly"`
+	Limit       int  `json:"limit"` // Default: 100
+}
+
+type ChatFilterLogItem struct {
+	ChatFilterEntry
+	RoomName string `json:"roomName"`
+}
+
+type ListChatFilterLogResponse struct {
+	Entries []ChatFilterLogItem `json:"entries"`
+}
+
+type ReviewHeldChatMessageRequest struct {
+	EntryId int  `json:"entryId"`
+	Approve bool `json:"approve"`
+}
+
+type ReviewHeldChatMessageResponse struct {
+	Entry ChatFilterEntry `json:"entry"`
+}
+
+// API Procedures
+
+// GetChatFilterSettings returns a studio's filter, for moderators
+func GetChatFilterSettings(ctx *vbeam.Context, req GetChatFilterSettingsRequest) (resp GetChatFilterSettingsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if !canModerateChat(ctx.Tx, caller, req.StudioId) {
+		return resp, errors.New("Only chat moderators can do that")
+	}
+
+	resp.Settings = getChatFilterSettings(ctx.Tx, req.StudioId)
+	resp.DefaultTerms = defaultBlockedTerms
+	return
+}
+
+// UpdateChatFilterSettings replaces a studio's filter
+func UpdateChatFilterSettings(ctx *vbeam.Context, req UpdateChatFilterSettingsRequest) (resp UpdateChatFilterSettingsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if GetStudioById(ctx.Tx, req.StudioId).Id == 0 {
+		return resp, errors.New("Studio not found")
+	}
+	if !canModerateChat(ctx.Tx, caller, req.StudioId) {
+		return resp, errors.New("Only chat moderators can do that")
+	}
+	if req.Action != ChatFilterBlock && req.Action != ChatFilterHold {
+		return resp, errors.New("Unknown filter action")
+	}
+
+	blocked, err := normalizeFilterTerms(req.BlockedTerms)
+	if err != nil {
+		return
+	}
+	allowed, err := normalizeFilterTerms(req.AllowedTerms)
+	if err != nil {
+		return
+	}
+
+	settings := ChatFilterSettings{
+		StudioId:       req.StudioId,
+		Disabled:       req.Disabled,
+		UseDefaultList: req.UseDefaultList,
+		BlockedTerms:   blocked,
+		AllowedTerms:   allowed,
+		BlockLinks:     req.BlockLinks,
+		Action:         req.Action,
+		UpdatedBy:      caller.Id,
+		UpdatedAt:      time.Now(),
+	}
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.Write(ctx.Tx, ChatFilterSettingsBkt, settings.StudioId, &settings)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Chat filter updated", map[string]interface{}{
+		"studioId":     settings.StudioId,
+		"disabled":     settings.Disabled,
+		"blockedTerms": len(settings.BlockedTerms),
+		"allowedTerms": len(settings.AllowedTerms),
+		"blockLinks":   settings.BlockLinks,
+		"action":       settings.Action,
+		"updatedBy":    caller.Id,
+	})
+
+	resp.Settings = settings
+	return
+}
+
+// ListChatFilterLog returns messages the filter caught, newest first
+func ListChatFilterLog(ctx *vbeam.Context, req ListChatFilterLogRequest) (resp ListChatFilterLogResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if !canModerateChat(ctx.Tx, caller, req.StudioId) {
+		return resp, errors.New("Only chat moderators can do that")
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	var ids []int
+	vbolt.ReadTermTargets(ctx.Tx, ChatFilterLogByStudioIdx, req.StudioId, &ids, vbolt.Window{})
+	roomNames := make(map[int]string)
+	resp.Entries = []ChatFilterLogItem{}
+	for i := len(ids) - 1; i >= 0 && len(resp.Entries) < limit; i-- {
+		var entry ChatFilterEntry
+		vbolt.Read(ctx.Tx, ChatFilterLogBkt, ids[i], &entry)
+		if entry.Id == 0 || (req.PendingOnly && entry.Status != ChatFilterStatusPending) {
+			continue
+		}
+		if _, ok := roomNames[entry.RoomId]; !ok {
+			roomNames[entry.RoomId] = GetRoom(ctx.Tx, entry.RoomId).Name
+		}
+		resp.Entries = append(resp.Entries, ChatFilterLogItem{
+			ChatFilterEntry: entry,
+			RoomName:        roomNames[entry.RoomId],
+		})
+	}
+	return
+}
+
+// ReviewHeldChatMessage approves or rejects a held message. Approved messages
+// are posted to the room as if just sent.
+func ReviewHeldChatMessage(ctx *vbeam.Context, req ReviewHeldChatMessageRequest) (resp ReviewHeldChatMessageResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	var entry ChatFilterEntry
+	vbolt.Read(ctx.Tx, ChatFilterLogBkt, req.EntryId, &entry)
+	if entry.Id == 0 {
+		return resp, errors.New("Message not found")
+	}
+	if !canModerateChat(ctx.Tx, caller, entry.StudioId) {
+		return resp, errors.New("Only chat moderators can do that")
+	}
+	if entry.Status != ChatFilterStatusPending {
+		return resp, errors.New("This message has already been reviewed")
+	}
+	if GetRoom(ctx.Tx, entry.RoomId).Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	entry.ReviewedBy = caller.Id
+	entry.ReviewedAt = time.Now()
+	entry.Status = ChatFilterStatusRejected
+	var msg ChatMessage
+	if req.Approve {
+		entry.Status = ChatFilterStatusApproved
+		msg = ChatMessage{
+			RoomId:       entry.RoomId,
+			UserId:       entry.UserId,
+			SessionToken: entry.SessionToken,
+			UserName:     entry.UserName,
+			Text:         entry.Text,
+			Timestamp:    entry.ReviewedAt,
+			ReplyToId:    entry.ReplyToId,
+			ReplyToName:  entry.ReplyToName,
+		}
+		saveChatMessageTx(ctx.Tx, &msg)
+		entry.MessageId = msg.Id
+	}
+	vbolt.Write(ctx.Tx, ChatFilterLogBkt, entry.Id, &entry)
+	vbolt.TxCommit(ctx.Tx)
+
+	if req.Approve {
+		sseManager.BroadcastChatMessage(msg.RoomId, msg)
+	}
+
+	LogInfo(LogCategorySystem, "Held chat message reviewed", map[string]interface{}{
+		"studioId":   entry.StudioId,
+		"entryId":    entry.Id,
+		"approved":   req.Approve,
+		"reviewedBy": caller.Id,
+	})
+
+	resp.Entry = entry
+	return
+}
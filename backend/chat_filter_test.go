@@ -0,0 +1,146 @@
+package backend
+
+import (
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestFilterChatText(t *testing.T) {
+	settings := ChatFilterSettings{
+		UseDefaultList: true,
+		BlockedTerms:   []string{"tiktok", "meet me"},
+		AllowedTerms:   []string{"hell"},
+		BlockLinks:     true,
+	}
+
+	cases := []struct {
+		text    string
+		flagged bool
+	}{
+		{"Great class today!", false},
+		{"Scrap that, assess the spin", false},
+		{"oh SHIT I fell", true},
+		{"what the hell", false},
+		{"follow my TikTok", true},
+		{"Meet   me after", true},
+		{"meeting me later", false},
+		{"see www.example.com", true},
+		{"go to example.io now", true},
+		{"the score was 3.5", false},
+	}
+	for _, c := range cases {
+		if got := filterChatText(settings, c.text) != ""; got != c.flagged {
+			t.Errorf("filterChatText(%q) flagged=%v, want %v", c.text, got, c.flagged)
+		}
+	}
+
+	settings.Disabled = true
+	if filterChatText(settings, "shit") != "" {
+		t.Errorf("Expected a disabled filter to pass everything")
+	}
+
+	terms, err := normalizeFilterTerms([]string{" Bad-Word ", "bad word", ""})
+	if err != nil || len(terms) != 1 || terms[0] != "bad word" {
+		t.Errorf("Expected terms to be normalized and de-duplicated, got %v %v", terms, err)
+	}
+}
+
+func TestChatFilterHoldForReview(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+
+	send := func(userId int, text string) (resp SendChatMessageResponse, err error) {
+		globalRateLimiter.Reset()
+		token, _ := createTestToken(userId)
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = SendChatMessage(&vbeam.Context{Tx: tx, Token: token}, SendChatMessageRequest{RoomId: room.Id, Text: text})
+		})
+		return
+	}
+	call := func(userId int, fn func(ctx *vbeam.Context) error) (err error) {
+		token, _ := createTestToken(userId)
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			err = fn(&vbeam.Context{Tx: tx, Token: token})
+		})
+		return
+	}
+
+	// The default list blocks out of the box
+	if _, err := send(member.Id, "this is shit"); err == nil {
+		t.Errorf("Expected profanity to be blocked by default")
+	}
+	if resp, err := send(admin.Id, "this is shit"); err != nil || resp.Held {
+		t.Errorf("Expected moderators to be exempt, got %v", err)
+	}
+
+	if err := call(member.Id, func(ctx *vbeam.Context) error {
+		_, err := UpdateChatFilterSettings(ctx, UpdateChatFilterSettingsRequest{StudioId: room.StudioId})
+		return err
+	}); err == nil {
+		t.Errorf("Expected non-moderators to be refused")
+	}
+	if err := call(admin.Id, func(ctx *vbeam.Context) error {
+		_, err := UpdateChatFilterSettings(ctx, UpdateChatFilterSettingsRequest{
+			StudioId:       room.StudioId,
+			UseDefaultList: true,
+			BlockLinks:     true,
+			Action:         ChatFilterHold,
+		})
+		return err
+	}); err != nil {
+		t.Fatalf("UpdateChatFilterSettings failed: %v", err)
+	}
+
+	resp, err := send(member.Id, "watch https://example.com")
+	if err != nil || !resp.Held || resp.Message.Id != 0 {
+		t.Fatalf("Expected the link to be held, got %v %+v", err, resp)
+	}
+	send(member.Id, "what the shit")
+
+	var pending ListChatFilterLogResponse
+	call(admin.Id, func(ctx *vbeam.Context) (err error) {
+		pending, err = ListChatFilterLog(ctx, ListChatFilterLogRequest{StudioId: room.StudioId, PendingOnly: true})
+		return
+	})
+	if len(pending.Entries) != 2 || pending.Entries[1].Reason != "Link" || pending.Entries[1].RoomName != room.Name {
+		t.Fatalf("Unexpected review queue: %+v", pending.Entries)
+	}
+
+	review := func(entryId int, approve bool) (resp ReviewHeldChatMessageResponse, err error) {
+		err = call(admin.Id, func(ctx *vbeam.Context) (err error) {
+			resp, err = ReviewHeldChatMessage(ctx, ReviewHeldChatMessageRequest{EntryId: entryId, Approve: approve})
+			return
+		})
+		return
+	}
+	approved, err := review(pending.Entries[1].Id, true)
+	if err != nil || approved.Entry.MessageId == 0 {
+		t.Fatalf("ReviewHeldChatMessage failed: %v %+v", err, approved)
+	}
+	if _, err := review(pending.Entries[0].Id, false); err != nil {
+		t.Fatalf("ReviewHeldChatMessage failed: %v", err)
+	}
+	if _, err := review(pending.Entries[1].Id, false); err == nil {
+		t.Errorf("Expected a reviewed message to stay decided")
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		var msg ChatMessage
+		vbolt.Read(tx, ChatMessagesBkt, approved.Entry.MessageId, &msg)
+		if msg.Text != "watch https://example.com" || msg.UserId != member.Id {
+			t.Errorf("Expected the approved message to be posted, got %+v", msg)
+		}
+	})
+
+	var all ListChatFilterLogResponse
+	call(admin.Id, func(ctx *vbeam.Context) (err error) {
+		all, err = ListChatFilterLog(ctx, ListChatFilterLogRequest{StudioId: room.StudioId})
+		return
+	})
+	if len(all.Entries) != 3 || all.Entries[2].Status != ChatFilterStatusBlocked {
+		t.Errorf("Expected blocked messages in the log too, got %+v", all.Entries)
+	}
+}
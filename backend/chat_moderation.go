@@ -0,0 +1,461 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"stream/cfg"
+	"strings"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const MAX_SLOW_MODE_SECONDS = 600
+const MAX_CHAT_TIMEOUT_MINUTES = 24 * 60
+
+// ChatRestrictionKind says how a viewer is silenced
+type ChatRestrictionKind int
+
+const (
+	ChatRestrictionTimeout ChatRestrictionKind = 0 // Muted until ExpiresAt
+	ChatRestrictionBan     ChatRestrictionKind = 1 // Muted until lifted
+)
+
+// ChatRoomSettings holds per-room moderation switches. Rooms without a record
+// use the zero value: chat on, no slow mode.
+type ChatRoomSettings struct {
+	RoomId          int       `json:"roomId"`
+	SlowModeSeconds int       `json:"slowModeSeconds"` // 0 = off
+	ChatDisabled    bool      `json:"chatDisabled"`
+	UpdatedBy       int       `json:"updatedBy"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// ChatRestriction silences one viewer in every room of a studio. Viewers are
+// identified by chatViewerId: "user:<id>", or for code sessions the code and
+// device they redeemed it from.
+type ChatRestriction struct {
+	Id         int                 `json:"id"`
+	StudioId   int                 `json:"studioId"`
+	ViewerId   string              `json:"-"`      // Device IDs are not exposed
+	UserId     int                 `json:"userId"` // -1 for code sessions
+	TargetName string              `json:"targetName"`
+	Kind       ChatRestrictionKind `json:"kind"`
+	Reason     string              `json:"reason"`
+	ExpiresAt  time.Time           `json:"expiresAt"` // Zero for bans
+	CreatedBy  int                 `json:"createdBy"`
+	CreatedAt  time.Time           `json:"createdAt"`
+}
+
+func (r ChatRestriction) isActive(now time.Time) bool {
+	return r.Kind == ChatRestrictionBan || now.Before(r.ExpiresAt)
+}
+
+func PackChatRoomSettings(self *ChatRoomSettings, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.RoomId, buf)
+	vpack.Int(&self.SlowModeSeconds, buf)
+	vpack.Bool(&self.ChatDisabled, buf)
+	vpack.Int(&self.UpdatedBy, buf)
+	vpack.Time(&self.UpdatedAt, buf)
+}
+
+func PackChatRestriction(self *ChatRestriction, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.String(&self.ViewerId, buf)
+	vpack.Int(&self.UserId, buf)
+	vpack.String(&self.TargetName, buf)
+	vpack.Int((*int)(&self.Kind), buf)
+	vpack.String(&self.Reason, buf)
+	vpack.Time(&self.ExpiresAt, buf)
+	vpack.Int(&self.CreatedBy, buf)
+	vpack.Time(&self.CreatedAt, buf)
+}
+
+// ChatRoomSettingsBkt stores settings by room ID
+var ChatRoomSettingsBkt = vbolt.Bucket(&cfg.Info, "chat_room_settings", vpack.FInt, PackChatRoomSettings)
+
+// ChatRestrictionsBkt stores timeouts and bans by ID
+var ChatRestrictionsBkt = vbolt.Bucket(&cfg.Info, "chat_restrictions", vpack.FInt, PackChatRestriction)
+
+// ChatRestrictionsByStudioIdx finds restrictions for a studio (term=studioId, target=restrictionId)
+var ChatRestrictionsByStudioIdx = vbolt.Index(&cfg.Info, "chat_restrictions_by_studio", vpack.FInt, vpack.FInt)
+
+// ChatRestrictionsByViewerIdx finds restrictions for a viewer (term=viewerId, target=restrictionId)
+var ChatRestrictionsByViewerIdx = vbolt.Index(&cfg.Info, "chat_restrictions_by_viewer", vpack.StringZ, vpack.FInt)
+
+func getChatRoomSettings(tx *vbolt.Tx, roomId int) (settings ChatRoomSettings) {
+	vbolt.Read(tx, ChatRoomSettingsBkt, roomId, &settings)
+	settings.RoomId = roomId
+	return
+}
+
+// chatViewerId identifies the sender for restrictions, slow mode, votes and
+// reactions
+func chatViewerId(ctx *vbeam.Context, user User) string {
+	if user.Id == -1 {
+		sessionToken := codeSessionTokenFromContext(ctx)
+		if viewerId := codeChatViewerId(ctx.Tx, sessionToken); viewerId != "" {
+			return viewerId
+		}
+		return "code:" + sessionToken
+	}
+	return userViewerId(user.Id)
+}
+
+// codeChatViewerId identifies a code session's viewer by the code and the
+// device it was redeemed from, so redeeming the code again in a new session
+// doesn't shed a ban. Sessions from an unknown device share the code's ID.
+// Returns "" if the session is gone.
+func codeChatViewerId(tx *vbolt.Tx, sessionToken string) string {
+	var session CodeSession
+	if sessionToken == "" || !vbolt.Read(tx, CodeSessionsBkt, sessionToken, &session) {
+		return ""
+	}
+	if session.DeviceId == "" {
+		return "code:" + session.Code
+	}
+	return "code:" + codeDeviceKey(session.Code, session.DeviceId)
+}
+
+// activeChatRestriction returns the viewer's current timeout or ban in a
+// studio, preferring a ban over a timeout
+func activeChatRestriction(tx *vbolt.Tx, studioId int, viewerId string) (found ChatRestriction) {
+	var ids []int
+	vbolt.ReadTermTargets(tx, ChatRestrictionsByViewerIdx, viewerId, &ids, vbolt.Window{})
+	now := time.Now()
+	for _, id := range ids {
+		var r ChatRestriction
+		vbolt.Read(tx, ChatRestrictionsBkt, id, &r)
+		if r.StudioId != studioId || !r.isActive(now) {
+			continue
+		}
+		if found.Id > 0 && found.Kind == ChatRestrictionBan {
+			continue
+		}
+		if r.Kind == ChatRestrictionBan || r.ExpiresAt.After(found.ExpiresAt) {
+			found = r
+		}
+	}
+	return
+}
+
+func chatRestrictionError(r ChatRestriction) error {
+	if r.Kind == ChatRestrictionBan {
+		return errors.New("You have been banned from this chat")
+	}
+	wait := int(time.Until(r.ExpiresAt).Minutes()) + 1
+	return fmt.Errorf("You have been timed out from chat for %d more minute(s)", wait)
+}
+
+// canModerateChat checks the moderate chat capability; code sessions never can
+func canModerateChat(tx *vbolt.Tx, user User, studioId int) bool {
+	return user.Id > 0 && HasStudioCapability(tx, user.Id, studioId, CapModerateChat)
+}
+
+// checkChatAllowed enforces room settings and restrictions for a sender.
+// Moderators bypass slow mode and disabled chat.
+func checkChatAllowed(ctx *vbeam.Context, user User, room Room) error {
+	if canModerateChat(ctx.Tx, user, room.StudioId) {
+		return nil
+	}
+
+	viewerId := chatViewerId(ctx, user)
+	if r := activeChatRestriction(ctx.Tx, room.StudioId, viewerId); r.Id > 0 {
+		return chatRestrictionError(r)
+	}
+
+	settings := getChatRoomSettings(ctx.Tx, room.Id)
+	if settings.ChatDisabled {
+		return errors.New("Chat is turned off in this room")
+	}
+	if settings.SlowModeSeconds > 0 {
+		if globalRateLimiter.CheckChatSlowMode(room.Id, viewerId, settings.SlowModeSeconds) != nil {
+			return fmt.Errorf("Slow mode is on: one message every %d seconds", settings.SlowModeSeconds)
+		}
+	}
+	return nil
+}
+
+// RegisterChatModerationMethods registers chat moderation procedures
+func RegisterChatModerationMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, DeleteChatMessage)
+	vbeam.RegisterProc(app, RestrictChatViewer)
+	vbeam.RegisterProc(app, LiftChatRestriction)
+	vbeam.RegisterProc(app, ListChatRestrictions)
+	vbeam.RegisterProc(app, UpdateChatSettings)
+}
+
+// Request/Response types
+type DeleteChatMessageRequest struct {
+	MessageId int `json:"messageId"`
+}
+
+type DeleteChatMessageResponse struct {
+	Success bool `json:"success"`
+}
+
+type RestrictChatViewerRequest struct {
+	MessageId       int                 `json:"messageId"` // The message that prompted the action
+	Kind            ChatRestrictionKind `json:"kind"`
+	DurationMinutes int                 `json:"durationMinutes"` // Timeouts only
+	Reason          string              `json:"reason"`
+	DeleteMessage   bool                `json:"deleteMessage"`
+}
+
+type RestrictChatViewerResponse struct {
+	Restriction ChatRestriction `json:"restriction"`
+}
+
+type LiftChatRestrictionRequest struct {
+	RestrictionId int `json:"restrictionId"`
+}
+
+type LiftChatRestrictionResponse struct {
+	Success bool `json:"success"`
+}
+
+type ListChatRestrictionsRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type ListChatRestrictionsResponse struct {
+	Restrictions []ChatRestriction `json:"restrictions"`
+}
+
+type UpdateChatSettingsRequest struct {
+	RoomId          int  `json:"roomId"`
+	SlowModeSeconds int  `json:"slowModeSeconds"`
+	ChatDisabled    bool `json:"chatDisabled"`
+}
+
+type UpdateChatSettingsResponse struct {
+	Settings ChatRoomSettings `json:"settings"`
+}
+
+// moderatedMessage loads a message and checks the caller may moderate its room
+func moderatedMessage(ctx *vbeam.Context, messageId int) (caller User, msg ChatMessage, room Room, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		err = errors.New("Authentication required")
+		return
+	}
+	vbolt.Read(ctx.Tx, ChatMessagesBkt, messageId, &msg)
+	if msg.Id == 0 {
+		err = errors.New("Message not found")
+		return
+	}
+	room = GetRoom(ctx.Tx, msg.RoomId)
+	if !canModerateChat(ctx.Tx, caller, room.StudioId) {
+		err = errors.New("Only chat moderators can do that")
+	}
+	return
+}
+
+func deleteChatMessageTx(tx *vbolt.Tx, msg ChatMessage) {
+	vbolt.SetTargetSingleTerm(tx, ChatByRoomIdx, msg.Id, -1)
+	vbolt.SetTargetSingleTerm(tx, ChatByStreamIdx, msg.Id, -1)
+	vbolt.SetTargetSingleTerm(tx, ChatByUserIdx, msg.Id, -1)
+	vbolt.Delete(tx, ChatMessagesBkt, msg.Id)
+}
+
+// API Procedures
+
+// DeleteChatMessage removes a message and tells viewers to drop it
+func DeleteChatMessage(ctx *vbeam.Context, req DeleteChatMessageRequest) (resp DeleteChatMessageResponse, err error) {
+	caller, msg, room, err := moderatedMessage(ctx, req.MessageId)
+	if err != nil {
+		return
+	}
+
+	vbeam.UseWriteTx(ctx)
+	deleteChatMessageTx(ctx.Tx, msg)
+	vbolt.TxCommit(ctx.Tx)
+
+	sseManager.BroadcastChatMessageDeleted(room.Id, msg.Id)
+
+	LogInfo(LogCategorySystem, "Chat message deleted", map[string]interface{}{
+		"roomId":    room.Id,
+		"messageId": msg.Id,
+		"authorId":  msg.UserId,
+		"deletedBy": caller.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+// RestrictChatViewer times out or bans the author of a message across the
+// studio's rooms. Moderators can't be restricted.
+func RestrictChatViewer(ctx *vbeam.Context, req RestrictChatViewerRequest) (resp RestrictChatViewerResponse, err error) {
+	caller, msg, room, err := moderatedMessage(ctx, req.MessageId)
+	if err != nil {
+		return
+	}
+
+	var viewerId string
+	if msg.UserId > 0 {
+		viewerId = userViewerId(msg.UserId)
+		if HasStudioCapability(ctx.Tx, msg.UserId, room.StudioId, CapModerateChat) {
+			return resp, errors.New("Moderators can't be restricted")
+		}
+	} else if msg.UserId == -1 {
+		viewerId = codeChatViewerId(ctx.Tx, msg.SessionToken)
+	}
+	if viewerId == "" {
+		return resp, errors.New("This message's author can't be identified")
+	}
+
+	restriction := ChatRestriction{
+		StudioId:   room.StudioId,
+		ViewerId:   viewerId,
+		UserId:     msg.UserId,
+		TargetName: msg.UserName,
+		Kind:       req.Kind,
+		Reason:     strings.TrimSpace(req.Reason),
+		CreatedBy:  caller.Id,
+		CreatedAt:  time.Now(),
+	}
+	switch req.Kind {
+	case ChatRestrictionTimeout:
+		if req.DurationMinutes <= 0 || req.DurationMinutes > MAX_CHAT_TIMEOUT_MINUTES {
+			return resp, fmt.Errorf("Timeouts must be between 1 and %d minutes", MAX_CHAT_TIMEOUT_MINUTES)
+		}
+		restriction.ExpiresAt = restriction.CreatedAt.Add(time.Duration(req.DurationMinutes) * time.Minute)
+	case ChatRestrictionBan:
+	default:
+		return resp, errors.New("Unknown restriction")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	restriction.Id = vbolt.NextIntId(ctx.Tx, ChatRestrictionsBkt)
+	vbolt.Write(ctx.Tx, ChatRestrictionsBkt, restriction.Id, &restriction)
+	vbolt.SetTargetSingleTerm(ctx.Tx, ChatRestrictionsByStudioIdx, restriction.Id, restriction.StudioId)
+	vbolt.SetTargetSingleTerm(ctx.Tx, ChatRestrictionsByViewerIdx, restriction.Id, restriction.ViewerId)
+	if req.DeleteMessage {
+		deleteChatMessageTx(ctx.Tx, msg)
+	}
+	vbolt.TxCommit(ctx.Tx)
+
+	if req.DeleteMessage {
+		sseManager.BroadcastChatMessageDeleted(room.Id, msg.Id)
+	}
+	sseManager.BroadcastChatRestricted(room.Id, restriction)
+
+	LogInfo(LogCategorySystem, "Chat viewer restricted", map[string]interface{}{
+		"studioId":      restriction.StudioId,
+		"roomId":        room.Id,
+		"restrictionId": restriction.Id,
+		"userId":        restriction.UserId,
+		"kind":          restriction.Kind,
+		"expiresAt":     restriction.ExpiresAt,
+		"createdBy":     caller.Id,
+	})
+
+	resp.Restriction = restriction
+	return
+}
+
+// LiftChatRestriction ends a timeout or ban early
+func LiftChatRestriction(ctx *vbeam.Context, req LiftChatRestrictionRequest) (resp LiftChatRestrictionResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	var restriction ChatRestriction
+	vbolt.Read(ctx.Tx, ChatRestrictionsBkt, req.RestrictionId, &restriction)
+	if restriction.Id == 0 {
+		return resp, errors.New("Restriction not found")
+	}
+	if !canModerateChat(ctx.Tx, caller, restriction.StudioId) {
+		return resp, errors.New("Only chat moderators can do that")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.SetTargetSingleTerm(ctx.Tx, ChatRestrictionsByStudioIdx, restriction.Id, -1)
+	vbolt.SetTargetSingleTerm(ctx.Tx, ChatRestrictionsByViewerIdx, restriction.Id, "")
+	vbolt.Delete(ctx.Tx, ChatRestrictionsBkt, restriction.Id)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Chat restriction lifted", map[string]interface{}{
+		"studioId":      restriction.StudioId,
+		"restrictionId": restriction.Id,
+		"userId":        restriction.UserId,
+		"liftedBy":      caller.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+// ListChatRestrictions returns a studio's active timeouts and bans, newest first
+func ListChatRestrictions(ctx *vbeam.Context, req ListChatRestrictionsRequest) (resp ListChatRestrictionsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if !canModerateChat(ctx.Tx, caller, req.StudioId) {
+		return resp, errors.New("Only chat moderators can do that")
+	}
+
+	var ids []int
+	vbolt.ReadTermTargets(ctx.Tx, ChatRestrictionsByStudioIdx, req.StudioId, &ids, vbolt.Window{})
+	now := time.Now()
+	resp.Restrictions = []ChatRestriction{}
+	for i := len(ids) - 1; i >= 0; i-- {
+		var r ChatRestriction
+		vbolt.Read(ctx.Tx, ChatRestrictionsBkt, ids[i], &r)
+		if r.Id > 0 && r.isActive(now) {
+			resp.Restrictions = append(resp.Restrictions, r)
+		}
+	}
+	return
+}
+
+// UpdateChatSettings changes a room's slow mode and on/off switch
+func UpdateChatSettings(ctx *vbeam.Context, req UpdateChatSettingsRequest) (resp UpdateChatSettingsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	room := GetRoom(ctx.Tx, req.RoomId)
+	if room.Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+	if !canModerateChat(ctx.Tx, caller, room.StudioId) {
+		return resp, errors.New("Only chat moderators can do that")
+	}
+	if req.SlowModeSeconds < 0 || req.SlowModeSeconds > MAX_SLOW_MODE_SECONDS {
+		return resp, fmt.Errorf("Slow mode must be between 0 and %d seconds", MAX_SLOW_MODE_SECONDS)
+	}
+
+	settings := ChatRoomSettings{
+		RoomId:          room.Id,
+		SlowModeSeconds: req.SlowModeSeconds,
+		ChatDisabled:    req.ChatDisabled,
+		UpdatedBy:       caller.Id,
+		UpdatedAt:       time.Now(),
+	}
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.Write(ctx.Tx, ChatRoomSettingsBkt, room.Id, &settings)
+	vbolt.TxCommit(ctx.Tx)
+
+	sseManager.BroadcastChatSettings(room.Id, settings)
+
+	LogInfo(LogCategorySystem, "Chat settings updated", map[string]interface{}{
+		"roomId":          room.Id,
+		"slowModeSeconds": settings.SlowModeSeconds,
+		"chatDisabled":    settings.ChatDisabled,
+		"updatedBy":       caller.Id,
+	})
+
+	resp.Settings = settings
+	return
+}
@@ -0,0 +1,216 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestChatModeration(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	viewer := addTestStudioMember(t, db, studio.Id, "viewer@test.com", StudioRoleViewer)
+	other := addTestStudioMember(t, db, studio.Id, "other@test.com", StudioRoleMember)
+
+	var codeMsg ChatMessage
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		session := CodeSession{Token: "code-token", Code: "12345", DeviceId: "device-1", ConnectedAt: time.Now()}
+		vbolt.Write(tx, CodeSessionsBkt, session.Token, &session)
+		codeMsg = ChatMessage{Id: vbolt.NextIntId(tx, ChatMessagesBkt), RoomId: room.Id, UserId: -1, SessionToken: "code-token", UserName: "Viewer", Text: "hi", Timestamp: time.Now()}
+		vbolt.Write(tx, ChatMessagesBkt, codeMsg.Id, &codeMsg)
+		vbolt.SetTargetSingleTerm(tx, ChatByRoomIdx, codeMsg.Id, room.Id)
+		vbolt.TxCommit(tx)
+	})
+
+	send := func(userId int) (msg ChatMessage, err error) {
+		token, _ := createTestToken(userId)
+		var resp SendChatMessageResponse
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = SendChatMessage(&vbeam.Context{Tx: tx, Token: token}, SendChatMessageRequest{RoomId: room.Id, Text: "hello"})
+		})
+		return resp.Message, err
+	}
+	call := func(userId int, fn func(ctx *vbeam.Context) error) (err error) {
+		token, _ := createTestToken(userId)
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			err = fn(&vbeam.Context{Tx: tx, Token: token})
+		})
+		return
+	}
+	restrict := func(callerId int, req RestrictChatViewerRequest) error {
+		return call(callerId, func(ctx *vbeam.Context) error {
+			_, err := RestrictChatViewer(ctx, req)
+			return err
+		})
+	}
+
+	msg, err := send(viewer.Id)
+	if err != nil {
+		t.Fatalf("SendChatMessage failed: %v", err)
+	}
+
+	// Only moderators can act
+	deleteMsg := func(callerId int, messageId int) error {
+		return call(callerId, func(ctx *vbeam.Context) error {
+			_, err := DeleteChatMessage(ctx, DeleteChatMessageRequest{MessageId: messageId})
+			return err
+		})
+	}
+	if err := deleteMsg(other.Id, msg.Id); err == nil {
+		t.Errorf("Expected non-moderators to be refused")
+	}
+	if err := deleteMsg(admin.Id, msg.Id); err != nil {
+		t.Fatalf("DeleteChatMessage failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		var stored ChatMessage
+		if vbolt.Read(tx, ChatMessagesBkt, msg.Id, &stored) {
+			t.Errorf("Expected the message to be deleted")
+		}
+	})
+
+	// A timeout silences the author until it ends or is lifted
+	globalRateLimiter.Reset()
+	msg, _ = send(viewer.Id)
+	if err := restrict(admin.Id, RestrictChatViewerRequest{MessageId: msg.Id, Kind: ChatRestrictionTimeout}); err == nil {
+		t.Errorf("Expected a timeout without a duration to be refused")
+	}
+	if err := restrict(admin.Id, RestrictChatViewerRequest{MessageId: msg.Id, Kind: ChatRestrictionTimeout, DurationMinutes: 10}); err != nil {
+		t.Fatalf("RestrictChatViewer failed: %v", err)
+	}
+	globalRateLimiter.Reset()
+	if _, err := send(viewer.Id); err == nil {
+		t.Errorf("Expected a timed-out viewer to be refused")
+	}
+	if _, err := send(other.Id); err != nil {
+		t.Errorf("Expected other members to keep chatting, got %v", err)
+	}
+
+	adminMsg, _ := send(admin.Id)
+	if err := restrict(admin.Id, RestrictChatViewerRequest{MessageId: adminMsg.Id, Kind: ChatRestrictionBan}); err == nil {
+		t.Errorf("Expected moderators to be immune")
+	}
+
+	// Code sessions are banned by their code and device
+	if err := restrict(admin.Id, RestrictChatViewerRequest{MessageId: codeMsg.Id, Kind: ChatRestrictionBan, DeleteMessage: true}); err != nil {
+		t.Fatalf("RestrictChatViewer failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if r := activeChatRestriction(tx, room.StudioId, "code:12345:device-1"); r.Kind != ChatRestrictionBan {
+			t.Errorf("Expected the code session to be banned, got %+v", r)
+		}
+	})
+
+	var list ListChatRestrictionsResponse
+	call(admin.Id, func(ctx *vbeam.Context) (err error) {
+		list, err = ListChatRestrictions(ctx, ListChatRestrictionsRequest{StudioId: room.StudioId})
+		return
+	})
+	if len(list.Restrictions) != 2 || list.Restrictions[0].Kind != ChatRestrictionBan {
+		t.Fatalf("Unexpected restrictions: %+v", list.Restrictions)
+	}
+	timeout := list.Restrictions[1]
+	if err := call(admin.Id, func(ctx *vbeam.Context) error {
+		_, err := LiftChatRestriction(ctx, LiftChatRestrictionRequest{RestrictionId: timeout.Id})
+		return err
+	}); err != nil {
+		t.Fatalf("LiftChatRestriction failed: %v", err)
+	}
+	globalRateLimiter.Reset()
+	if _, err := send(viewer.Id); err != nil {
+		t.Errorf("Expected the lifted viewer to chat again, got %v", err)
+	}
+
+	// Slow mode and disabled chat apply to everyone but moderators
+	updateSettings := func(req UpdateChatSettingsRequest) error {
+		req.RoomId = room.Id
+		return call(admin.Id, func(ctx *vbeam.Context) error {
+			_, err := UpdateChatSettings(ctx, req)
+			return err
+		})
+	}
+	if err := updateSettings(UpdateChatSettingsRequest{SlowModeSeconds: 60}); err != nil {
+		t.Fatalf("UpdateChatSettings failed: %v", err)
+	}
+	globalRateLimiter.Reset()
+	if _, err := send(viewer.Id); err != nil {
+		t.Fatalf("Expected the first message under slow mode to pass, got %v", err)
+	}
+	if _, err := send(viewer.Id); err == nil || !strings.Contains(err.Error(), "Slow mode") {
+		t.Errorf("Expected slow mode to hold back a second message, got %v", err)
+	}
+
+	if err := updateSettings(UpdateChatSettingsRequest{ChatDisabled: true}); err != nil {
+		t.Fatalf("UpdateChatSettings failed: %v", err)
+	}
+	globalRateLimiter.Reset()
+	if _, err := send(other.Id); err == nil {
+		t.Errorf("Expected disabled chat to refuse members")
+	}
+	if _, err := send(admin.Id); err != nil {
+		t.Errorf("Expected moderators to post in disabled chat, got %v", err)
+	}
+
+	var history GetChatHistoryResponse
+	call(viewer.Id, func(ctx *vbeam.Context) (err error) {
+		history, err = GetChatHistory(ctx, GetChatHistoryRequest{RoomId: room.Id})
+		return
+	})
+	if !history.Settings.ChatDisabled || history.CanModerate {
+		t.Errorf("Expected the viewer to see chat disabled without moderator tools, got %+v", history.Settings)
+	}
+}
+
+// A banned guest who redeems the code again from the same device is still
+// banned; another device on the code is not
+func TestChatBanSurvivesRedeemingAgain(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	adminToken, _ := createTestToken(admin.Id)
+
+	var generated GenerateAccessCodeResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		generated, err = GenerateAccessCode(&vbeam.Context{Tx: tx, Token: adminToken}, GenerateAccessCodeRequest{Type: int(CodeTypeRoom), TargetId: room.Id, DurationMinutes: 60})
+	})
+	if err != nil {
+		t.Fatalf("GenerateAccessCode failed: %v", err)
+	}
+	redeem := func(deviceId string) string {
+		validated, err := validateAccessCodeLogicForDevice(db, generated.Code, CodeDeviceInfo{DeviceId: deviceId})
+		if err != nil {
+			t.Fatalf("validateAccessCodeLogicForDevice failed: %v", err)
+		}
+		return signTestGuestToken(t, validated)
+	}
+	send := func(token string) (msg ChatMessage, err error) {
+		globalRateLimiter.Reset()
+		var resp SendChatMessageResponse
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = SendChatMessage(&vbeam.Context{Tx: tx, Token: token}, SendChatMessageRequest{RoomId: room.Id, Text: "hello"})
+		})
+		return resp.Message, err
+	}
+
+	banned := redeem("device-1")
+	msg, err := send(banned)
+	if err != nil {
+		t.Fatalf("SendChatMessage failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = RestrictChatViewer(&vbeam.Context{Tx: tx, Token: adminToken}, RestrictChatViewerRequest{MessageId: msg.Id, Kind: ChatRestrictionBan})
+	})
+	if err != nil {
+		t.Fatalf("RestrictChatViewer failed: %v", err)
+	}
+
+	if _, err := send(redeem("device-1")); err == nil {
+		t.Errorf("Expected a new session from the banned device to stay banned")
+	}
+	if _, err := send(redeem("device-2")); err != nil {
+		t.Errorf("Expected another device on the code to keep chatting, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"stream/cfg"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const MAX_CHAT_PIN_LENGTH = 200
+
+// ChatPinnedAnnouncement is the note shown above a room's chat, such as
+// "Recital resumes at 7:15". Rooms without one have no record.
+type ChatPinnedAnnouncement struct {
+	RoomId       int       `json:"roomId"`
+	Text         string    `json:"text"` // Empty when nothing is pinned
+	PinnedBy     int       `json:"pinnedBy"`
+	PinnedByName string    `json:"pinnedByName"`
+	PinnedAt     time.Time `json:"pinnedAt"`
+}
+
+func PackChatPinnedAnnouncement(self *ChatPinnedAnnouncement, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.RoomId, buf)
+	vpack.String(&self.Text, buf)
+	vpack.Int(&self.PinnedBy, buf)
+	vpack.String(&self.PinnedByName, buf)
+	vpack.Time(&self.PinnedAt, buf)
+}
+
+// ChatPinsBkt stores pinned announcements by room ID
+var ChatPinsBkt = vbolt.Bucket(&cfg.Info, "chat_pins", vpack.FInt, PackChatPinnedAnnouncement)
+
+func getChatPinnedAnnouncement(tx *vbolt.Tx, roomId int) (pin ChatPinnedAnnouncement) {
+	vbolt.Read(tx, ChatPinsBkt, roomId, &pin)
+	pin.RoomId = roomId
+	return
+}
+
+// RegisterChatPinMethods registers pinned announcement procedures
+func RegisterChatPinMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, SetChatPinnedAnnouncement)
+}
+
+type SetChatPinnedAnnouncementRequest struct {
+	RoomId int    `json:"roomId"`
+	Text   string `json:"text"` // Empty to unpin
+}
+
+type SetChatPinnedAnnouncementResponse struct {
+	Pin ChatPinnedAnnouncement `json:"pin"`
+}
+
+// SetChatPinnedAnnouncement pins, replaces or clears a room's announcement
+// and sends it to everyone watching. Studio admins only.
+func SetChatPinnedAnnouncement(ctx *vbeam.Context, req SetChatPinnedAnnouncementRequest) (resp SetChatPinnedAnnouncementResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	room := GetRoom(ctx.Tx, req.RoomId)
+	if room.Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, room.StudioId, CapModerateChat) {
+		return resp, errors.New("Only chat moderators can pin announcements")
+	}
+
+	text := strings.TrimSpace(req.Text)
+	if utf8.RuneCountInString(text) > MAX_CHAT_PIN_LENGTH {
+		return resp, fmt.Errorf("Announcement must be %d characters or less", MAX_CHAT_PIN_LENGTH)
+	}
+
+	pin := ChatPinnedAnnouncement{RoomId: room.Id}
+	vbeam.UseWriteTx(ctx)
+	if text == "" {
+		vbolt.Delete(ctx.Tx, ChatPinsBkt, room.Id)
+	} else {
+		pin.Text = text
+		pin.PinnedBy = caller.Id
+		pin.PinnedByName = caller.Name
+		pin.PinnedAt = time.Now()
+		vbolt.Write(ctx.Tx, ChatPinsBkt, room.Id, &pin)
+	}
+	vbolt.TxCommit(ctx.Tx)
+
+	sseManager.BroadcastChatPinned(room.Id, pin)
+
+	LogInfo(LogCategorySystem, "Chat announcement pinned", map[string]interface{}{
+		"roomId":   room.Id,
+		"pinned":   pin.Text != "",
+		"pinnedBy": caller.Id,
+	})
+
+	resp.Pin = pin
+	return
+}
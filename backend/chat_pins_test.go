@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestChatPinnedAnnouncement(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+
+	adminToken, _ := createTestToken(admin.Id)
+	memberToken, _ := createTestToken(member.Id)
+	pin := func(token string, text string) (resp SetChatPinnedAnnouncementResponse, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = SetChatPinnedAnnouncement(&vbeam.Context{Tx: tx, Token: token}, SetChatPinnedAnnouncementRequest{RoomId: room.Id, Text: text})
+		})
+		return
+	}
+	connect := func() string {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest("GET", "/api/room/events?roomId="+strconv.Itoa(room.Id), nil).WithContext(ctx)
+		req.AddCookie(&http.Cookie{Name: "authToken", Value: memberToken})
+		w := httptest.NewRecorder()
+		MakeStreamRoomEventsHandler(db)(w, req)
+		return w.Body.String()
+	}
+
+	if _, err := pin(memberToken, "Recital resumes at 7:15"); err == nil {
+		t.Errorf("Expected members to be refused")
+	}
+	if _, err := pin(adminToken, strings.Repeat("x", MAX_CHAT_PIN_LENGTH+1)); err == nil {
+		t.Errorf("Expected an overlong announcement to be refused")
+	}
+	resp, err := pin(adminToken, "  Recital resumes at 7:15 ")
+	if err != nil {
+		t.Fatalf("SetChatPinnedAnnouncement failed: %v", err)
+	}
+	if resp.Pin.Text != "Recital resumes at 7:15" || resp.Pin.PinnedByName != admin.Name {
+		t.Errorf("Unexpected pin: %+v", resp.Pin)
+	}
+
+	// New viewers get the pin when they connect
+	if body := connect(); !strings.Contains(body, "event: chat_pinned\ndata: ") || !strings.Contains(body, "Recital resumes at 7:15") {
+		t.Errorf("Expected the pin in the initial SSE payload, got:\n%s", body)
+	}
+
+	if _, err := pin(adminToken, ""); err != nil {
+		t.Fatalf("Unpinning failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if got := getChatPinnedAnnouncement(tx, room.Id); got.Text != "" {
+			t.Errorf("Expected the pin to be cleared, got %q", got.Text)
+		}
+	})
+	if body := connect(); strings.Contains(body, "event: chat_pinned") {
+		t.Errorf("Expected no pin after clearing it, got:\n%s", body)
+	}
+}
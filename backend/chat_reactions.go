@@ -0,0 +1,148 @@
+package backend
+
+import (
+	"errors"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+// ChatReaction is one emoji on a chat message and who added it. Viewers are
+// identified like chat restrictions, by chatViewerId.
+type ChatReaction struct {
+	Emote     string   `json:"emote"`
+	Count     int      `json:"count"`
+	ViewerIds []string `json:"-"`    // Device IDs are not exposed
+	Mine      bool     `json:"mine"` // The caller reacted (not stored)
+}
+
+func PackChatReaction(self *ChatReaction, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.String(&self.Emote, buf)
+	vpack.Int(&self.Count, buf)
+	vpack.Slice(&self.ViewerIds, vpack.String, buf)
+}
+
+// RegisterChatReactionMethods registers chat reaction procedures
+func RegisterChatReactionMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, ToggleChatReaction)
+}
+
+type ToggleChatReactionRequest struct {
+	MessageId int    `json:"messageId"`
+	Emote     string `json:"emote"` // Emote code from the studio's set
+}
+
+type ToggleChatReactionResponse struct {
+	Reactions []ChatReaction `json:"reactions"`
+}
+
+// toggleChatReaction adds the viewer's reaction, or removes it if they had
+// already reacted with that emote. Emotes left with no viewers are dropped.
+func toggleChatReaction(reactions []ChatReaction, emote string, viewerId string) []ChatReaction {
+	result := make([]ChatReaction, 0, len(reactions)+1)
+	found := false
+	for _, reaction := range reactions {
+		if reaction.Emote == emote {
+			found = true
+			viewers := make([]string, 0, len(reaction.ViewerIds)+1)
+			removed := false
+			for _, id := range reaction.ViewerIds {
+				if id == viewerId {
+					removed = true
+				} else {
+					viewers = append(viewers, id)
+				}
+			}
+			if !removed {
+				viewers = append(viewers, viewerId)
+			}
+			reaction.ViewerIds = viewers
+			reaction.Count = len(viewers)
+		}
+		if reaction.Count > 0 {
+			result = append(result, reaction)
+		}
+	}
+	if !found {
+		result = append(result, ChatReaction{Emote: emote, Count: 1, ViewerIds: []string{viewerId}})
+	}
+	return result
+}
+
+// reactionsForViewer copies reactions with Mine set for one viewer
+func reactionsForViewer(reactions []ChatReaction, viewerId string) []ChatReaction {
+	result := make([]ChatReaction, len(reactions))
+	for i, reaction := range reactions {
+		reaction.Mine = false
+		for _, id := range reaction.ViewerIds {
+			if id == viewerId {
+				reaction.Mine = true
+				break
+			}
+		}
+		result[i] = reaction
+	}
+	return result
+}
+
+// ToggleChatReaction adds or removes the caller's emoji reaction on a message.
+// Both JWT users and code sessions can react; silenced viewers can't.
+func ToggleChatReaction(ctx *vbeam.Context, req ToggleChatReactionRequest) (resp ToggleChatReactionResponse, err error) {
+	user, err := GetAuthUser(ctx)
+	if err != nil {
+		return resp, ErrAuthFailure
+	}
+
+	var msg ChatMessage
+	vbolt.Read(ctx.Tx, ChatMessagesBkt, req.MessageId, &msg)
+	if msg.Id == 0 {
+		return resp, errors.New("Message not found")
+	}
+	room := GetRoom(ctx.Tx, msg.RoomId)
+	if room.Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+	if _, ok := findStudioEmote(ctx.Tx, room.StudioId, req.Emote); !ok {
+		return resp, errors.New("That emote isn't available in this room")
+	}
+
+	viewerId := chatViewerId(ctx, user)
+	if !canModerateChat(ctx.Tx, user, room.StudioId) {
+		if r := activeChatRestriction(ctx.Tx, room.StudioId, viewerId); r.Id > 0 {
+			return resp, chatRestrictionError(r)
+		}
+		if getChatRoomSettings(ctx.Tx, room.Id).ChatDisabled {
+			return resp, errors.New("Chat is turned off in this room")
+		}
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckChatReaction(viewerId); rateLimitErr != nil {
+		return resp, errors.New("Please wait before reacting again")
+	}
+
+	vbeam.UseWriteTx(ctx)
+
+	// Re-read so concurrent reactions on the message aren't overwritten
+	msg = ChatMessage{}
+	vbolt.Read(ctx.Tx, ChatMessagesBkt, req.MessageId, &msg)
+	if msg.Id == 0 {
+		return resp, errors.New("Message not found")
+	}
+	msg.Reactions = toggleChatReaction(msg.Reactions, req.Emote, viewerId)
+	vbolt.Write(ctx.Tx, ChatMessagesBkt, msg.Id, &msg)
+	vbolt.TxCommit(ctx.Tx)
+
+	sseManager.BroadcastChatReaction(room.Id, msg.Id, msg.Reactions)
+
+	LogDebug(LogCategorySystem, "Chat reaction toggled", map[string]interface{}{
+		"roomId":    room.Id,
+		"messageId": msg.Id,
+		"emote":     req.Emote,
+		"userId":    user.Id,
+	})
+
+	resp.Reactions = reactionsForViewer(msg.Reactions, viewerId)
+	return
+}
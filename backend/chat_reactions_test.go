@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestChatRepliesAndReactions(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+
+	var otherRoom Room
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		_, otherRoom = createTestStudioAndRoom(tx)
+		vbolt.TxCommit(tx)
+	})
+
+	adminToken, _ := createTestToken(admin.Id)
+	memberToken, _ := createTestToken(member.Id)
+	guestToken := createTestGuestToken(t, db, adminToken, room.Id)
+
+	send := func(token string, roomId int, text string, replyToId int) (msg ChatMessage, err error) {
+		globalRateLimiter.Reset()
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var resp SendChatMessageResponse
+			resp, err = SendChatMessage(&vbeam.Context{Tx: tx, Token: token}, SendChatMessageRequest{RoomId: roomId, Text: text, ReplyToId: replyToId})
+			msg = resp.Message
+		})
+		return
+	}
+	react := func(token string, messageId int, emote string) (reactions []ChatReaction, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var resp ToggleChatReactionResponse
+			resp, err = ToggleChatReaction(&vbeam.Context{Tx: tx, Token: token}, ToggleChatReactionRequest{MessageId: messageId, Emote: emote})
+			reactions = resp.Reactions
+		})
+		return
+	}
+
+	// Replies link to a message in the same room
+	question, err := send(memberToken, room.Id, "When does part two start?", 0)
+	if err != nil {
+		t.Fatalf("SendChatMessage failed: %v", err)
+	}
+	answer, err := send(adminToken, room.Id, "Right after intermission", question.Id)
+	if err != nil {
+		t.Fatalf("SendChatMessage reply failed: %v", err)
+	}
+	if answer.ReplyToId != question.Id || answer.ReplyToName != member.Name {
+		t.Errorf("Expected a reply to %d by %q, got %d by %q", question.Id, member.Name, answer.ReplyToId, answer.ReplyToName)
+	}
+	if _, err := send(memberToken, otherRoom.Id, "hi", question.Id); err == nil {
+		t.Errorf("Expected a reply to another room's message to be refused")
+	}
+
+	// Reactions toggle per viewer and are counted across viewers
+	if _, err := react(memberToken, question.Id, "🙂"); err == nil {
+		t.Errorf("Expected an emote outside AllowedEmotes to be refused")
+	}
+	if _, err := react(memberToken, question.Id, "👍"); err != nil {
+		t.Fatalf("ToggleChatReaction failed: %v", err)
+	}
+	reactions, err := react(guestToken, question.Id, "👍")
+	if err != nil {
+		t.Fatalf("ToggleChatReaction as guest failed: %v", err)
+	}
+	if len(reactions) != 1 || reactions[0].Count != 2 || !reactions[0].Mine {
+		t.Errorf("Expected two 👍 including the guest's, got %+v", reactions)
+	}
+	react(memberToken, question.Id, "🔥")
+	reactions, _ = react(memberToken, question.Id, "👍")
+	if len(reactions) != 2 || reactions[0].Emote != "👍" || reactions[0].Count != 1 || reactions[0].Mine || !reactions[1].Mine {
+		t.Errorf("Expected the member's 👍 to be removed, got %+v", reactions)
+	}
+
+	var history GetChatHistoryResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		history, _ = GetChatHistory(&vbeam.Context{Tx: tx, Token: guestToken}, GetChatHistoryRequest{RoomId: room.Id})
+	})
+	if len(history.Messages) != 2 || history.Messages[1].ReplyToId != question.Id {
+		t.Fatalf("Unexpected history: %+v", history.Messages)
+	}
+	if got := history.Messages[0].Reactions; len(got) != 2 || !got[0].Mine || got[1].Mine {
+		t.Errorf("Expected history to mark the guest's own reaction, got %+v", got)
+	}
+
+	// Two reactions read the message before either is written; neither is lost
+	globalRateLimiter.Reset()
+	adminTx, _ := db.Begin(false)
+	guestTx, _ := db.Begin(false)
+	if _, err := ToggleChatReaction(&vbeam.Context{Tx: adminTx, Token: adminToken}, ToggleChatReactionRequest{MessageId: answer.Id, Emote: "👍"}); err != nil {
+		t.Fatalf("ToggleChatReaction failed: %v", err)
+	}
+	toggled, err := ToggleChatReaction(&vbeam.Context{Tx: guestTx, Token: guestToken}, ToggleChatReactionRequest{MessageId: answer.Id, Emote: "🔥"})
+	if err != nil {
+		t.Fatalf("ToggleChatReaction as guest failed: %v", err)
+	}
+	if len(toggled.Reactions) != 2 {
+		t.Errorf("Expected both concurrent reactions to be kept, got %+v", toggled.Reactions)
+	}
+
+	// Banned viewers can't react
+	globalRateLimiter.Reset()
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = RestrictChatViewer(&vbeam.Context{Tx: tx, Token: adminToken}, RestrictChatViewerRequest{MessageId: question.Id, Kind: ChatRestrictionBan})
+	})
+	if err != nil {
+		t.Fatalf("RestrictChatViewer failed: %v", err)
+	}
+	if _, err := react(memberToken, answer.Id, "❤️"); err == nil {
+		t.Errorf("Expected a banned viewer's reaction to be refused")
+	}
+}
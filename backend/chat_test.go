@@ -1,10 +1,13 @@
 package backend
 
 import (
+	"fmt"
 	"stream/cfg"
+	"strings"
 	"testing"
 	"time"
 
+	"go.hasen.dev/vbeam"
 	"go.hasen.dev/vbolt"
 )
 
@@ -118,3 +121,133 @@ func TestDeleteChatMessagesForRoom(t *testing.T) {
 		}
 	})
 }
+
+func TestGuestChatNames(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+
+	adminToken, _ := createTestToken(admin.Id)
+	generate := func(recipient string) (resp GenerateAccessCodeResponse, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = GenerateAccessCode(&vbeam.Context{Tx: tx, Token: adminToken}, GenerateAccessCodeRequest{
+				Type:            int(CodeTypeRoom),
+				TargetId:        room.Id,
+				DurationMinutes: 60,
+				RecipientName:   recipient,
+			})
+		})
+		return
+	}
+	// Recipient names are held to the rules of names viewers pick themselves
+	for _, bad := range []string{"<script>", "shit head", strings.Repeat("a", MAX_CHAT_DISPLAY_NAME_LENGTH+1)} {
+		if _, err := generate(bad); err == nil {
+			t.Errorf("Expected recipient name %q to be refused", bad)
+		}
+	}
+	generated, err := generate("  The Garcia   Family ")
+	if err != nil {
+		t.Fatalf("GenerateAccessCode failed: %v", err)
+	}
+
+	validated, err := validateAccessCodeLogic(db, generated.Code)
+	if err != nil {
+		t.Fatalf("validateAccessCodeLogic failed: %v", err)
+	}
+	guestToken := signTestGuestToken(t, validated)
+	guestCtx := func(tx *vbolt.Tx) *vbeam.Context { return &vbeam.Context{Tx: tx, Token: guestToken} }
+
+	send := func() (msg ChatMessage) {
+		globalRateLimiter.Reset()
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err := SendChatMessage(guestCtx(tx), SendChatMessageRequest{RoomId: room.Id, Text: "hello"})
+			if err != nil {
+				t.Fatalf("SendChatMessage failed: %v", err)
+			}
+			msg = resp.Message
+		})
+		return
+	}
+	setName := func(token string, name string) (err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err = SetChatDisplayName(&vbeam.Context{Tx: tx, Token: token}, SetChatDisplayNameRequest{Name: name})
+		})
+		return
+	}
+
+	// The recipient name pre-fills the session's chat name
+	if msg := send(); msg.UserName != "The Garcia Family" {
+		t.Errorf("Expected the recipient name, got %q", msg.UserName)
+	}
+
+	for _, bad := range []string{"x", "12345", "<b>Mom</b>", "shit head"} {
+		if err := setName(guestToken, bad); err == nil {
+			t.Errorf("Expected %q to be refused", bad)
+		}
+	}
+	if err := setName(adminToken, "Teacher"); err == nil {
+		t.Errorf("Expected signed-in users to be refused")
+	}
+	if err := setName(guestToken, "Maya's Mom"); err != nil {
+		t.Fatalf("SetChatDisplayName failed: %v", err)
+	}
+	if msg := send(); msg.UserName != "Maya's Mom" {
+		t.Errorf("Expected the chosen name, got %q", msg.UserName)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		history, err := GetChatHistory(guestCtx(tx), GetChatHistoryRequest{RoomId: room.Id})
+		if err != nil || !history.IsGuest || history.GuestName != "Maya's Mom" {
+			t.Errorf("Expected the guest's name in the history response, got %v %+v", err, history)
+		}
+	})
+}
+
+func TestChatHistoryPagination(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+
+	var ids []int
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		for i := 0; i < 7; i++ {
+			msg := ChatMessage{RoomId: room.Id, UserId: member.Id, UserName: member.Name, Text: fmt.Sprintf("message %d", i), Timestamp: time.Now()}
+			saveChatMessageTx(tx, &msg)
+			ids = append(ids, msg.Id)
+		}
+		vbolt.TxCommit(tx)
+	})
+
+	token, _ := createTestToken(member.Id)
+	history := func(req GetChatHistoryRequest) (resp GetChatHistoryResponse, err error) {
+		req.RoomId = room.Id
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = GetChatHistory(&vbeam.Context{Tx: tx, Token: token}, req)
+		})
+		return
+	}
+	expectPage := func(name string, req GetChatHistoryRequest, want []int, wantMore bool) {
+		t.Helper()
+		resp, err := history(req)
+		if err != nil {
+			t.Fatalf("%s: GetChatHistory failed: %v", name, err)
+		}
+		var got []int
+		for _, msg := range resp.Messages {
+			got = append(got, msg.Id)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) || resp.HasMore != wantMore {
+			t.Errorf("%s: expected %v (hasMore=%v), got %v (hasMore=%v)", name, want, wantMore, got, resp.HasMore)
+		}
+	}
+
+	// The latest page holds the newest messages, oldest first
+	expectPage("latest", GetChatHistoryRequest{Limit: 3}, ids[4:], true)
+	expectPage("before", GetChatHistoryRequest{Limit: 3, BeforeId: ids[4]}, ids[1:4], true)
+	expectPage("first", GetChatHistoryRequest{Limit: 3, BeforeId: ids[1]}, ids[:1], false)
+	expectPage("after", GetChatHistoryRequest{Limit: 3, AfterId: ids[1]}, ids[2:5], true)
+	expectPage("caught up", GetChatHistoryRequest{Limit: 3, AfterId: ids[4]}, ids[5:], false)
+	expectPage("all", GetChatHistoryRequest{}, ids, false)
+
+	if _, err := history(GetChatHistoryRequest{BeforeId: ids[4], AfterId: ids[1]}); err == nil {
+		t.Errorf("Expected both cursors together to be refused")
+	}
+}
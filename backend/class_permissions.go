@@ -35,7 +35,7 @@ func GrantClassPermission(ctx *vbeam.Context, req GrantClassPermissionRequest) (
 	}
 
 	// Check permission (Admin+ required)
-	if !HasStudioPermission(ctx.Tx, caller.Id, schedule.StudioId, StudioRoleAdmin) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, schedule.StudioId, CapManageSchedules) {
 		return resp, errors.New("admin permission required")
 	}
 
@@ -136,7 +136,7 @@ func RevokeClassPermission(ctx *vbeam.Context, req RevokeClassPermissionRequest)
 	}
 
 	// Check permission (Admin+ required)
-	if !HasStudioPermission(ctx.Tx, caller.Id, schedule.StudioId, StudioRoleAdmin) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, schedule.StudioId, CapManageSchedules) {
 		return resp, errors.New("admin permission required")
 	}
 
@@ -193,8 +193,8 @@ func ListClassPermissions(ctx *vbeam.Context, req ListClassPermissionsRequest) (
 		return resp, errors.New("schedule not found")
 	}
 
-	// Check permission (Admin+ required to list permissions)
-	if !HasStudioPermission(ctx.Tx, caller.Id, schedule.StudioId, StudioRoleAdmin) {
+	// Check permission (schedule management required to list permissions)
+	if !HasStudioCapability(ctx.Tx, caller.Id, schedule.StudioId, CapManageSchedules) {
 		return resp, errors.New("admin permission required")
 	}
 
@@ -0,0 +1,269 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"stream/cfg"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const MAX_CLASS_QUESTION_LENGTH = 300
+
+// QuestionStatus tracks a viewer question through the class
+type QuestionStatus int
+
+const (
+	QuestionStatusOpen      QuestionStatus = 0
+	QuestionStatusAnswered  QuestionStatus = 1
+	QuestionStatusDismissed QuestionStatus = 2 // Hidden from viewers
+)
+
+// ClassQuestion is a question a viewer sends the instructor during a stream,
+// kept apart from chat so it doesn't scroll away
+type ClassQuestion struct {
+	Id         int            `json:"id"`
+	RoomId     int            `json:"roomId"`
+	StreamId   int            `json:"streamId"` // Stream session it was asked during (0 if the room was offline)
+	UserId     int            `json:"userId"`   // -1 for code sessions
+	ViewerId   string         `json:"-"`        // chatViewerId of the asker
+	UserName   string         `json:"userName"`
+	Text       string         `json:"text"`
+	Status     QuestionStatus `json:"status"`
+	AskedAt    time.Time      `json:"askedAt"`
+	AnsweredAt time.Time      `json:"answeredAt"`
+	Mine       bool           `json:"mine"` // The caller asked it (not stored)
+}
+
+func PackClassQuestion(self *ClassQuestion, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.RoomId, buf)
+	vpack.Int(&self.StreamId, buf)
+	vpack.Int(&self.UserId, buf)
+	vpack.String(&self.ViewerId, buf)
+	vpack.String(&self.UserName, buf)
+	vpack.String(&self.Text, buf)
+	vpack.Int((*int)(&self.Status), buf)
+	vpack.Time(&self.AskedAt, buf)
+	vpack.Time(&self.AnsweredAt, buf)
+}
+
+// ClassQuestionsBkt stores viewer questions by ID
+var ClassQuestionsBkt = vbolt.Bucket(&cfg.Info, "class_questions", vpack.FInt, PackClassQuestion)
+
+// ClassQuestionsByRoomIdx finds a room's questions (term=roomId, target=questionId)
+var ClassQuestionsByRoomIdx = vbolt.Index(&cfg.Info, "class_questions_by_room", vpack.FInt, vpack.FInt)
+
+// ClassQuestionsByStreamIdx finds the questions asked during a stream session (term=streamId, target=questionId)
+var ClassQuestionsByStreamIdx = vbolt.Index(&cfg.Info, "class_questions_by_stream", vpack.FInt, vpack.FInt)
+
+// ClassQuestionsByViewerIdx finds a viewer's questions (term=viewerId, target=questionId)
+var ClassQuestionsByViewerIdx = vbolt.Index(&cfg.Info, "class_questions_by_viewer", vpack.StringZ, vpack.FInt)
+
+// deleteRoomQuestionsTx deletes all of a room's questions
+func deleteRoomQuestionsTx(tx *vbolt.Tx, roomId int) int {
+	var questionIds []int
+	vbolt.ReadTermTargets(tx, ClassQuestionsByRoomIdx, roomId, &questionIds, vbolt.Window{})
+	for _, questionId := range questionIds {
+		vbolt.SetTargetSingleTerm(tx, ClassQuestionsByRoomIdx, questionId, -1)
+		vbolt.SetTargetSingleTerm(tx, ClassQuestionsByStreamIdx, questionId, -1)
+		vbolt.SetTargetSingleTerm(tx, ClassQuestionsByViewerIdx, questionId, "")
+		vbolt.Delete(tx, ClassQuestionsBkt, questionId)
+	}
+	return len(questionIds)
+}
+
+// RegisterClassQuestionMethods registers viewer Q&A procedures
+func RegisterClassQuestionMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, AskClassQuestion)
+	vbeam.RegisterProc(app, SetClassQuestionStatus)
+	vbeam.RegisterProc(app, ListClassQuestions)
+}
+
+type AskClassQuestionRequest struct {
+	RoomId int    `json:"roomId"`
+	Text   string `json:"text"`
+}
+
+type ClassQuestionResponse struct {
+	Question ClassQuestion `json:"question"`
+}
+
+type SetClassQuestionStatusRequest struct {
+	QuestionId int            `json:"questionId"`
+	Status     QuestionStatus `json:"status"`
+}
+
+type ListClassQuestionsRequest struct {
+	RoomId int `json:"roomId"`
+}
+
+type ListClassQuestionsResponse struct {
+	Questions []ClassQuestion `json:"questions"` // This session's questions, oldest first
+	CanManage bool            `json:"canManage"` // May answer and dismiss (chat moderators)
+}
+
+// AskClassQuestion sends the instructor a question. Both JWT users and code
+// sessions can ask; silenced viewers and filtered text are refused.
+func AskClassQuestion(ctx *vbeam.Context, req AskClassQuestionRequest) (resp ClassQuestionResponse, err error) {
+	user, err := GetAuthUser(ctx)
+	if err != nil {
+		return resp, ErrAuthFailure
+	}
+
+	room := GetRoom(ctx.Tx, req.RoomId)
+	if room.Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+	if err := requireRoomAccess(ctx, user, room.Id); err != nil {
+		return resp, err
+	}
+
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return resp, errors.New("Question cannot be empty")
+	}
+	if utf8.RuneCountInString(text) > MAX_CLASS_QUESTION_LENGTH {
+		return resp, fmt.Errorf("Question must be %d characters or less", MAX_CLASS_QUESTION_LENGTH)
+	}
+
+	viewerId := chatViewerId(ctx, user)
+	if !canModerateChat(ctx.Tx, user, room.StudioId) {
+		if r := activeChatRestriction(ctx.Tx, room.StudioId, viewerId); r.Id > 0 {
+			return resp, chatRestrictionError(r)
+		}
+		if reason := filterChatText(getChatFilterSettings(ctx.Tx, room.StudioId), text); reason != "" {
+			return resp, errors.New("Your question was blocked by the chat filter")
+		}
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckClassQuestion(viewerId); rateLimitErr != nil {
+		return resp, errors.New("Please wait before asking another question")
+	}
+
+	userName := user.Name
+	if user.Id == -1 {
+		userName = codeSessionDisplayName(ctx.Tx, codeSessionTokenFromContext(ctx))
+	}
+
+	vbeam.UseWriteTx(ctx)
+	question := ClassQuestion{
+		Id:       vbolt.NextIntId(ctx.Tx, ClassQuestionsBkt),
+		RoomId:   room.Id,
+		StreamId: liveStream(ctx.Tx, room.Id).Id,
+		UserId:   user.Id,
+		ViewerId: viewerId,
+		UserName: userName,
+		Text:     text,
+		Status:   QuestionStatusOpen,
+		AskedAt:  time.Now(),
+	}
+	vbolt.Write(ctx.Tx, ClassQuestionsBkt, question.Id, &question)
+	vbolt.SetTargetSingleTerm(ctx.Tx, ClassQuestionsByRoomIdx, question.Id, room.Id)
+	vbolt.SetTargetSingleTerm(ctx.Tx, ClassQuestionsByViewerIdx, question.Id, viewerId)
+	if question.StreamId > 0 {
+		vbolt.SetTargetSingleTerm(ctx.Tx, ClassQuestionsByStreamIdx, question.Id, question.StreamId)
+	}
+	vbolt.TxCommit(ctx.Tx)
+
+	sseManager.BroadcastClassQuestion(room.Id, question)
+
+	LogDebug(LogCategorySystem, "Class question asked", map[string]interface{}{
+		"roomId":     room.Id,
+		"questionId": question.Id,
+		"userId":     user.Id,
+	})
+
+	question.Mine = true
+	resp.Question = question
+	return
+}
+
+// SetClassQuestionStatus marks a question answered, dismisses it, or reopens
+// it. Chat moderators only.
+func SetClassQuestionStatus(ctx *vbeam.Context, req SetClassQuestionStatusRequest) (resp ClassQuestionResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	var question ClassQuestion
+	vbolt.Read(ctx.Tx, ClassQuestionsBkt, req.QuestionId, &question)
+	if question.Id == 0 {
+		return resp, errors.New("Question not found")
+	}
+	room := GetRoom(ctx.Tx, question.RoomId)
+	if !canModerateChat(ctx.Tx, caller, room.StudioId) {
+		return resp, errors.New("Only chat moderators can answer questions")
+	}
+	switch req.Status {
+	case QuestionStatusOpen, QuestionStatusAnswered, QuestionStatusDismissed:
+	default:
+		return resp, errors.New("Invalid question status")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	question.Status = req.Status
+	question.AnsweredAt = time.Time{}
+	if req.Status == QuestionStatusAnswered {
+		question.AnsweredAt = time.Now()
+	}
+	vbolt.Write(ctx.Tx, ClassQuestionsBkt, question.Id, &question)
+	vbolt.TxCommit(ctx.Tx)
+
+	sseManager.BroadcastClassQuestion(question.RoomId, question)
+
+	LogInfo(LogCategorySystem, "Class question updated", map[string]interface{}{
+		"roomId":     question.RoomId,
+		"questionId": question.Id,
+		"status":     question.Status,
+		"updatedBy":  caller.Id,
+	})
+
+	resp.Question = question
+	return
+}
+
+// ListClassQuestions returns the questions from the room's current session.
+// Dismissed questions are only listed for moderators.
+func ListClassQuestions(ctx *vbeam.Context, req ListClassQuestionsRequest) (resp ListClassQuestionsResponse, err error) {
+	user, err := GetAuthUser(ctx)
+	if err != nil {
+		return resp, ErrAuthFailure
+	}
+
+	room := GetRoom(ctx.Tx, req.RoomId)
+	if room.Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+	if err := requireRoomAccess(ctx, user, room.Id); err != nil {
+		return resp, err
+	}
+
+	resp.CanManage = canModerateChat(ctx.Tx, user, room.StudioId)
+	viewerId := chatViewerId(ctx, user)
+	streamId := liveStream(ctx.Tx, room.Id).Id
+
+	var questionIds []int
+	vbolt.ReadTermTargets(ctx.Tx, ClassQuestionsByRoomIdx, room.Id, &questionIds, vbolt.Window{})
+	resp.Questions = []ClassQuestion{}
+	for _, id := range questionIds {
+		var question ClassQuestion
+		vbolt.Read(ctx.Tx, ClassQuestionsBkt, id, &question)
+		if question.Id == 0 || question.StreamId != streamId {
+			continue
+		}
+		if question.Status == QuestionStatusDismissed && !resp.CanManage {
+			continue
+		}
+		question.Mine = question.ViewerId == viewerId
+		resp.Questions = append(resp.Questions, question)
+	}
+	return
+}
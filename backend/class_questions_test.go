@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestClassQuestions(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+	other := addTestStudioMember(t, db, studio.Id, "other@test.com", StudioRoleMember)
+	outsider := createTestInvitee(t, db, "outsider@test.com", true)
+
+	var otherRoom Room
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		otherRoom = createTestRoom(tx, studio.Id, 2)
+		vbolt.TxCommit(tx)
+	})
+
+	adminToken, _ := createTestToken(admin.Id)
+	memberToken, _ := createTestToken(member.Id)
+	otherToken, _ := createTestToken(other.Id)
+	outsiderToken, _ := createTestToken(outsider.Id)
+	otherRoomGuestToken := createTestGuestToken(t, db, adminToken, otherRoom.Id)
+	ask := func(token string, text string) (question ClassQuestion, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var resp ClassQuestionResponse
+			resp, err = AskClassQuestion(&vbeam.Context{Tx: tx, Token: token}, AskClassQuestionRequest{RoomId: room.Id, Text: text})
+			question = resp.Question
+		})
+		return
+	}
+	setStatus := func(token string, questionId int, status QuestionStatus) (err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err = SetClassQuestionStatus(&vbeam.Context{Tx: tx, Token: token}, SetClassQuestionStatusRequest{QuestionId: questionId, Status: status})
+		})
+		return
+	}
+	list := func(token string) (resp ListClassQuestionsResponse) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var err error
+			resp, err = ListClassQuestions(&vbeam.Context{Tx: tx, Token: token}, ListClassQuestionsRequest{RoomId: room.Id})
+			if err != nil {
+				t.Fatalf("ListClassQuestions failed: %v", err)
+			}
+		})
+		return
+	}
+
+	// Viewers who can't see the room can't ask or read its questions,
+	// including a code session for another room of the studio
+	for _, token := range []string{outsiderToken, otherRoomGuestToken} {
+		if _, err := ask(token, "Can I join?"); err == nil {
+			t.Errorf("Expected a question without room access to be refused")
+		}
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err := ListClassQuestions(&vbeam.Context{Tx: tx, Token: token}, ListClassQuestionsRequest{RoomId: room.Id})
+			if err == nil {
+				t.Errorf("Expected listing questions without room access to be refused")
+			}
+		})
+	}
+
+	if _, err := ask(memberToken, "   "); err == nil {
+		t.Errorf("Expected an empty question to be refused")
+	}
+	if _, err := ask(memberToken, "This is bullshit"); err == nil {
+		t.Errorf("Expected the chat filter to apply to questions")
+	}
+	first, err := ask(memberToken, "Will the recital be recorded?")
+	if err != nil {
+		t.Fatalf("AskClassQuestion failed: %v", err)
+	}
+	if !first.Mine || first.Status != QuestionStatusOpen || first.UserName == "" {
+		t.Errorf("Unexpected question: %+v", first)
+	}
+	second, err := ask(otherToken, "Is there an intermission?")
+	if err != nil {
+		t.Fatalf("AskClassQuestion failed: %v", err)
+	}
+
+	// Only moderators answer and dismiss
+	if err := setStatus(memberToken, first.Id, QuestionStatusAnswered); err == nil {
+		t.Errorf("Expected members to be refused")
+	}
+	if err := setStatus(adminToken, first.Id, QuestionStatusAnswered); err != nil {
+		t.Fatalf("SetClassQuestionStatus failed: %v", err)
+	}
+	if err := setStatus(adminToken, second.Id, QuestionStatusDismissed); err != nil {
+		t.Fatalf("SetClassQuestionStatus failed: %v", err)
+	}
+
+	// Viewers don't see dismissed questions; moderators do
+	viewer := list(memberToken)
+	if len(viewer.Questions) != 1 || viewer.CanManage {
+		t.Fatalf("Expected one question for the viewer, got %+v", viewer)
+	}
+	if q := viewer.Questions[0]; !q.Mine || q.Status != QuestionStatusAnswered || q.AnsweredAt.IsZero() {
+		t.Errorf("Unexpected answered question: %+v", q)
+	}
+	if mod := list(adminToken); len(mod.Questions) != 2 || !mod.CanManage || mod.Questions[0].Mine {
+		t.Errorf("Expected both questions for the moderator, got %+v", mod)
+	}
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		deleteRoomQuestionsTx(tx, room.Id)
+		vbolt.TxCommit(tx)
+	})
+	if mod := list(adminToken); len(mod.Questions) != 0 {
+		t.Errorf("Expected questions to be deleted with the room")
+	}
+}
@@ -277,7 +277,7 @@ func CreateClassSchedule(ctx *vbeam.Context, req CreateClassScheduleRequest) (re
 	}
 
 	// Check permission (Admin+ required)
-	if !HasStudioPermission(ctx.Tx, caller.Id, room.StudioId, StudioRoleAdmin) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, room.StudioId, CapManageSchedules) {
 		return resp, errors.New("admin permission required")
 	}
 
@@ -439,7 +439,7 @@ func ListClassSchedules(ctx *vbeam.Context, req ListClassSchedulesRequest) (resp
 	}
 
 	// Check permission (Viewer+ required)
-	if !HasStudioPermission(ctx.Tx, caller.Id, studioId, StudioRoleViewer) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapViewStudio) {
 		return resp, errors.New("viewer permission required")
 	}
 
@@ -471,7 +471,7 @@ func GetScheduleDetails(ctx *vbeam.Context, req GetScheduleDetailsRequest) (resp
 	}
 
 	// Check permission (Viewer+ required)
-	if !HasStudioPermission(ctx.Tx, caller.Id, schedule.StudioId, StudioRoleViewer) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, schedule.StudioId, CapViewStudio) {
 		return resp, errors.New("viewer permission required")
 	}
 
@@ -763,7 +763,7 @@ func UpdateClassSchedule(ctx *vbeam.Context, req UpdateClassScheduleRequest) (re
 	}
 
 	// Check permission (Admin+ required)
-	if !HasStudioPermission(ctx.Tx, caller.Id, schedule.StudioId, StudioRoleAdmin) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, schedule.StudioId, CapManageSchedules) {
 		return resp, errors.New("admin permission required")
 	}
 
@@ -903,7 +903,7 @@ func DeleteClassSchedule(ctx *vbeam.Context, req DeleteClassScheduleRequest) (re
 	}
 
 	// Check permission (Admin+ required)
-	if !HasStudioPermission(ctx.Tx, caller.Id, schedule.StudioId, StudioRoleAdmin) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, schedule.StudioId, CapManageSchedules) {
 		return resp, errors.New("admin permission required")
 	}
 
@@ -952,7 +952,7 @@ func GetScheduleExecutionLogs(ctx *vbeam.Context, req GetScheduleExecutionLogsRe
 		}
 
 		// Check permission (Viewer+ required)
-		if !HasStudioPermission(ctx.Tx, caller.Id, schedule.StudioId, StudioRoleViewer) {
+		if !HasStudioCapability(ctx.Tx, caller.Id, schedule.StudioId, CapViewStudio) {
 			return resp, errors.New("viewer permission required")
 		}
 
@@ -967,7 +967,7 @@ func GetScheduleExecutionLogs(ctx *vbeam.Context, req GetScheduleExecutionLogsRe
 		}
 
 		// Check permission (Viewer+ required)
-		if !HasStudioPermission(ctx.Tx, caller.Id, room.StudioId, StudioRoleViewer) {
+		if !HasStudioCapability(ctx.Tx, caller.Id, room.StudioId, CapViewStudio) {
 			return resp, errors.New("viewer permission required")
 		}
 
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"sort"
 	"stream/cfg"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -37,6 +38,10 @@ type AccessCode struct {
 	MaxViewers int       `json:"maxViewers"` // 0=unlimited, >0=max concurrent
 	IsRevoked  bool      `json:"isRevoked"`  // Manual revocation flag
 	Label      string    `json:"label"`      // Optional description (e.g., "Physics 101 - Oct 18")
+	MaxDevices int       `json:"maxDevices"` // 0=no device binding, >0=first N devices to redeem own the code
+	ScheduleId int       `json:"scheduleId"` // 0=any time, >0=only admits viewers during this class schedule's occurrences
+
+	RecipientName string `json:"recipientName"` // Optional; pre-fills the chat name of sessions using this code
 }
 
 // CodeSession represents an active viewing session using an access code
@@ -48,6 +53,8 @@ type CodeSession struct {
 	GracePeriodUntil time.Time `json:"gracePeriodUntil,omitempty"` // Set when code expires (zero if not in grace period)
 	ClientIP         string    `json:"clientIP"`                   // For analytics/rate limiting
 	UserAgent        string    `json:"userAgent"`                  // For analytics
+	DeviceId         string    `json:"deviceId"`                   // Device that redeemed the code (empty if unknown)
+	DisplayName      string    `json:"displayName"`                // Chat name chosen by the viewer (empty shows as "Viewer")
 }
 
 // CodeAnalytics tracks usage statistics for an access code
@@ -63,7 +70,7 @@ type CodeAnalytics struct {
 // Packing functions for vbolt serialization
 
 func PackAccessCode(self *AccessCode, buf *vpack.Buffer) {
-	vpack.Version(1, buf)
+	version := vpack.Version(4, buf)
 	vpack.String(&self.Code, buf)
 	vpack.Int((*int)(&self.Type), buf)
 	vpack.Int(&self.TargetId, buf)
@@ -73,10 +80,25 @@ func PackAccessCode(self *AccessCode, buf *vpack.Buffer) {
 	vpack.Int(&self.MaxViewers, buf)
 	vpack.Bool(&self.IsRevoked, buf)
 	vpack.String(&self.Label, buf)
+
+	// Version 2: Device binding
+	if version >= 2 {
+		vpack.Int(&self.MaxDevices, buf)
+	}
+
+	// Version 3: Schedule-bound codes
+	if version >= 3 {
+		vpack.Int(&self.ScheduleId, buf)
+	}
+
+	// Version 4: Recipient name
+	if version >= 4 {
+		vpack.String(&self.RecipientName, buf)
+	}
 }
 
 func PackCodeSession(self *CodeSession, buf *vpack.Buffer) {
-	vpack.Version(1, buf)
+	version := vpack.Version(3, buf)
 	vpack.String(&self.Token, buf)
 	vpack.String(&self.Code, buf)
 	vpack.Time(&self.ConnectedAt, buf)
@@ -84,6 +106,16 @@ func PackCodeSession(self *CodeSession, buf *vpack.Buffer) {
 	vpack.Time(&self.GracePeriodUntil, buf)
 	vpack.String(&self.ClientIP, buf)
 	vpack.String(&self.UserAgent, buf)
+
+	// Version 2: Device binding
+	if version >= 2 {
+		vpack.String(&self.DeviceId, buf)
+	}
+
+	// Version 3: Guest chat name
+	if version >= 3 {
+		vpack.String(&self.DisplayName, buf)
+	}
 }
 
 func PackCodeAnalytics(self *CodeAnalytics, buf *vpack.Buffer) {
@@ -202,7 +234,10 @@ type GenerateAccessCodeRequest struct {
 	TargetId        int    `json:"targetId"`        // Room ID or Studio ID
 	DurationMinutes int    `json:"durationMinutes"` // How long code is valid
 	MaxViewers      int    `json:"maxViewers"`      // 0=unlimited
+	MaxDevices      int    `json:"maxDevices"`      // 0=no device binding
+	ScheduleId      int    `json:"scheduleId"`      // 0=any time, >0=only during this class (room codes only)
 	Label           string `json:"label"`           // Optional description
+	RecipientName   string `json:"recipientName"`   // Optional; the one person this code is for, shown as their chat name
 }
 
 type GenerateAccessCodeResponse struct {
@@ -258,6 +293,9 @@ type AccessCodeListItem struct {
 	ExpiresAt      time.Time `json:"expiresAt"`
 	IsRevoked      bool      `json:"isRevoked"`
 	IsExpired      bool      `json:"isExpired"`
+	ScheduleId     int       `json:"scheduleId"`   // 0 if not schedule-bound
+	ScheduleName   string    `json:"scheduleName"` // Class name for schedule-bound codes
+	RecipientName  string    `json:"recipientName"`
 	CurrentViewers int       `json:"currentViewers"`
 	TotalViews     int       `json:"totalViews"`
 }
@@ -367,20 +405,32 @@ func ValidateCodeSession(tx *vbolt.Tx, sessionToken string) (bool, CodeSession,
 		}
 	}
 
+	// Check class window (schedule-bound codes)
+	if !codeScheduleWindowOpen(tx, code, now) {
+		return false, session, code, "This code only works during class time"
+	}
+
 	// Code is valid and active
 	return true, session, code, ""
 }
 
-// CleanupOldAccessCodes removes access codes that have been expired for more than
-// the specified retention period. This prevents database bloat from accumulating
-// old codes and their associated data.
+// ACCESS_CODE_RETENTION_DAYS is how long an expired access code is kept
+// before StartOldCodeCleanup permanently deletes it
+const ACCESS_CODE_RETENTION_DAYS = 7
+
+// CleanupOldAccessCodes permanently deletes access codes that have been
+// expired for more than the specified retention period, so they can no
+// longer be looked up, reported on in code analytics or reused. Codes
+// without an expiry in the past are never touched. Watch records are kept
+// (see watch_audit.go).
 //
 // Returns the number of codes cleaned up.
 //
 // For each old code, this function:
 //   - Deletes the code entry from AccessCodesBkt
 //   - Deletes analytics from CodeAnalyticsBkt
-//   - Deletes any remaining sessions from CodeSessionsBkt
+//   - Deletes any remaining sessions from CodeSessionsBkt, and bound devices
+//   - Deletes the code's invite links
 //   - Removes the code from all indexes (room, studio, creator)
 func CleanupOldAccessCodes(db *vbolt.DB, retentionDays int) int {
 	cleanedCount := 0
@@ -422,6 +472,12 @@ func CleanupOldAccessCodes(db *vbolt.DB, retentionDays int) int {
 				vbolt.SetTargetSingleTerm(tx, SessionsByCodeIndex, token, "")
 			}
 
+			// Delete code sessions and bound devices
+			deleteCodeSessionsAndDevicesTx(tx, codeStr)
+
+			// Delete invite links for this code
+			deleteCodeInvitesTx(tx, codeStr)
+
 			// Remove from indexes
 			if code.Type == CodeTypeRoom {
 				vbolt.SetTargetSingleTerm(tx, CodesByRoomIdx, codeStr, -1)
@@ -433,6 +489,8 @@ func CleanupOldAccessCodes(db *vbolt.DB, retentionDays int) int {
 			cleanedCount++
 		}
 
+		vbolt.TxCommit(tx)
+
 		if cleanedCount > 0 {
 			LogInfo(LogCategorySystem, "Cleaned up old access codes", map[string]interface{}{
 				"codesDeleted":  cleanedCount,
@@ -445,10 +503,10 @@ func CleanupOldAccessCodes(db *vbolt.DB, retentionDays int) int {
 	return cleanedCount
 }
 
-// StartOldCodeCleanup starts a background goroutine that periodically
-// cleans up access codes that have been expired for more than 7 days
+// StartOldCodeCleanup starts a background goroutine that deletes access codes
+// expired for more than ACCESS_CODE_RETENTION_DAYS, on startup and then daily
 func StartOldCodeCleanup(db *vbolt.DB) {
-	const retentionDays = 7
+	const retentionDays = ACCESS_CODE_RETENTION_DAYS
 
 	LogInfo(LogCategorySystem, "Starting old code cleanup job", map[string]interface{}{
 		"frequency":     "daily",
@@ -478,7 +536,7 @@ func StartOldCodeCleanup(db *vbolt.DB) {
 //  2. For active sessions without grace period: sets GracePeriodUntil = now + 15min
 //  3. Broadcasts CODE_EXPIRED_GRACE_PERIOD event to affected rooms
 //  4. Finds sessions where GracePeriodUntil <= now (grace period ended)
-//  5. Disconnects those sessions (deletes from DB, decrements viewer count)
+//  5. Disconnects those sessions (deletes from DB, then drops presence and recounts viewers)
 //
 // Note: Grace period is per-session, not per-code, to handle cases where
 // a code expires while multiple people are watching.
@@ -486,6 +544,9 @@ func HandleExpiredCodes(db *vbolt.DB) int {
 	affectedSessions := 0
 	now := time.Now()
 
+	// Sessions whose grace period ended (map: code -> session tokens)
+	endedSessions := make(map[string][]string)
+
 	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
 		// Track codes that need SSE broadcasts (map: code -> roomIds)
 		codesToBroadcast := make(map[string][]int)
@@ -535,13 +596,8 @@ func HandleExpiredCodes(db *vbolt.DB) int {
 
 				// Case 2: Grace period has ended - disconnect session
 				if !session.GracePeriodUntil.IsZero() && session.GracePeriodUntil.Before(now) {
-					// Decrement viewer count
-					var analytics CodeAnalytics
-					vbolt.Read(tx, CodeAnalyticsBkt, session.Code, &analytics)
-					if analytics.CurrentViewers > 0 {
-						analytics.CurrentViewers--
-						vbolt.Write(tx, CodeAnalyticsBkt, session.Code, &analytics)
-					}
+					// Taken out of presence once this commits
+					endedSessions[session.Code] = append(endedSessions[session.Code], session.Token)
 
 					// Remove session from index
 					vbolt.SetTargetSingleTerm(tx, SessionsByCodeIndex, session.Token, "")
@@ -576,6 +632,10 @@ func HandleExpiredCodes(db *vbolt.DB) int {
 		}
 	})
 
+	for code, sessionTokens := range endedSessions {
+		dropCodeSessionsPresence(db, code, sessionTokens)
+	}
+
 	if affectedSessions > 0 {
 		LogInfo(LogCategorySystem, "Expired code handling completed", map[string]interface{}{
 			"affectedSessions": affectedSessions,
@@ -646,76 +706,89 @@ func validateAccessCodeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Identify the device (long-lived cookie) for device-bound codes
+	device := CodeDeviceInfo{
+		DeviceId:  getOrSetDeviceId(w, r),
+		ClientIP:  clientIP,
+		UserAgent: r.UserAgent(),
+	}
+
 	// Call validation logic
-	resp, err := validateAccessCodeLogic(appDb, req.Code)
+	resp, err := validateAccessCodeLogicForDevice(appDb, req.Code, device)
 	if err != nil {
 		vbeam.RespondError(w, err)
 		return
 	}
 
-	// If validation succeeded (no error), reset violation count and handle JWT/session
-	if err == nil {
-		// Reset violation tracking on successful validation
-		globalRateLimiter.ResetViolations("code_validation", clientIP)
-
-		// Check if user is already logged in (has existing JWT)
-		authCtx, authErr := GetAuthFromRequest(r, appDb)
-		isLoggedIn := (authErr == nil && authCtx.User.Id > 0)
+	// Reset violation tracking on successful validation
+	globalRateLimiter.ResetViolations("code_validation", clientIP)
 
-		if isLoggedIn {
-			// User is already logged in - store code session in UserCodeSessionsBkt
-			// Don't issue new JWT, keep existing one
-			vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
-				vbolt.Write(tx, UserCodeSessionsBkt, authCtx.User.Id, &resp.SessionToken)
-				vbolt.TxCommit(tx)
-			})
+	if err = attachCodeSession(w, r, req.Code, resp); err != nil {
+		vbeam.RespondError(w, err)
+		return
+	}
 
-			LogInfoWithRequest(r, LogCategoryAuth, "Logged-in user added code access", map[string]interface{}{
-				"userId":       authCtx.User.Id,
-				"code":         req.Code,
-				"sessionToken": resp.SessionToken,
-			})
-		} else {
-			// User is not logged in - issue JWT with userId=-1
-			expirationTime := resp.ExpiresAt
-			claims := &Claims{
-				UserId:       -1, // Anonymous code session
-				SessionToken: resp.SessionToken,
-				RegisteredClaims: jwt.RegisteredClaims{
-					ExpiresAt: jwt.NewNumericDate(expirationTime),
-				},
-			}
-			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-			tokenString, tokenErr := token.SignedString(jwtKey)
-			if tokenErr != nil {
-				LogErrorWithRequest(r, LogCategorySystem, "Failed to generate JWT for code session", map[string]interface{}{
-					"code":         req.Code,
-					"sessionToken": resp.SessionToken,
-					"error":        tokenErr.Error(),
-				})
-				vbeam.RespondError(w, errors.New("failed to generate session token"))
-				return
-			}
+	// Send JSON response
+	json.NewEncoder(w).Encode(resp)
+}
 
-			// Set authToken cookie
-			http.SetCookie(w, &http.Cookie{
-				Name:     "authToken",
-				Value:    tokenString,
-				Path:     "/",
-				HttpOnly: true,
-				MaxAge:   60 * 60 * 24, // 24 hours
-			})
+// attachCodeSession ties a freshly validated code session to the requester.
+// Logged-in users keep their JWT and get the session stored in UserCodeSessionsBkt;
+// anonymous users get an authToken cookie with userId=-1.
+func attachCodeSession(w http.ResponseWriter, r *http.Request, code string, resp ValidateAccessCodeResponse) error {
+	// Check if user is already logged in (has existing JWT)
+	authCtx, authErr := GetAuthFromRequest(r, appDb)
+	isLoggedIn := (authErr == nil && authCtx.User.Id > 0)
+
+	if isLoggedIn {
+		// User is already logged in - store code session in UserCodeSessionsBkt
+		// Don't issue new JWT, keep existing one
+		vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+			vbolt.Write(tx, UserCodeSessionsBkt, authCtx.User.Id, &resp.SessionToken)
+			vbolt.TxCommit(tx)
+		})
 
-			LogInfoWithRequest(r, LogCategoryAuth, "Anonymous user authenticated via code", map[string]interface{}{
-				"code":         req.Code,
-				"sessionToken": resp.SessionToken,
-			})
-		}
+		LogInfoWithRequest(r, LogCategoryAuth, "Logged-in user added code access", map[string]interface{}{
+			"userId":       authCtx.User.Id,
+			"code":         code,
+			"sessionToken": resp.SessionToken,
+		})
+		return nil
+	}
+
+	// User is not logged in - issue JWT with userId=-1
+	expirationTime := resp.ExpiresAt
+	claims := &Claims{
+		UserId:       -1, // Anonymous code session
+		SessionToken: resp.SessionToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+		},
+	}
+	tokenString, tokenErr := signJwt(claims)
+	if tokenErr != nil {
+		LogErrorWithRequest(r, LogCategorySystem, "Failed to generate JWT for code session", map[string]interface{}{
+			"code":         code,
+			"sessionToken": resp.SessionToken,
+			"error":        tokenErr.Error(),
+		})
+		return errors.New("failed to generate session token")
 	}
 
-	// Send JSON response
-	json.NewEncoder(w).Encode(resp)
+	// Set authToken cookie
+	http.SetCookie(w, &http.Cookie{
+		Name:     "authToken",
+		Value:    tokenString,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   60 * 60 * 24, // 24 hours
+	})
+
+	LogInfoWithRequest(r, LogCategoryAuth, "Anonymous user authenticated via code", map[string]interface{}{
+		"code":         code,
+		"sessionToken": resp.SessionToken,
+	})
+	return nil
 }
 
 func RegisterCodeAccessMethods(app *vbeam.Application) {
@@ -728,6 +801,8 @@ func RegisterCodeAccessMethods(app *vbeam.Application) {
 	vbeam.RegisterProc(app, RevokeAccessCode)
 	vbeam.RegisterProc(app, ListAccessCodes)
 	vbeam.RegisterProc(app, GetCodeAnalytics)
+	vbeam.RegisterProc(app, GetCodeDevices)
+	vbeam.RegisterProc(app, KickCodeSession)
 }
 
 // GenerateAccessCode creates a new temporary access code for room or studio viewing
@@ -763,6 +838,11 @@ func GenerateAccessCode(ctx *vbeam.Context, req GenerateAccessCodeRequest) (resp
 		return resp, errors.New("Max viewers cannot be negative")
 	}
 
+	// Validate max devices
+	if req.MaxDevices < 0 {
+		return resp, errors.New("Max devices cannot be negative")
+	}
+
 	// Validate label length
 	if len(req.Label) > 200 {
 		return resp, errors.New("Label is too long (max 200 characters)")
@@ -796,19 +876,34 @@ func GenerateAccessCode(ctx *vbeam.Context, req GenerateAccessCodeRequest) (resp
 
 	}
 
-	// Check if user has Admin+ permission for the studio
-	if !HasStudioPermission(ctx.Tx, caller.Id, studioId, StudioRoleAdmin) {
+	// Check if user can manage access codes for the studio
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapManageCodes) {
 		return resp, errors.New("Only studio admins can generate access codes")
 	}
 
-	vbeam.UseWriteTx(ctx)
-
-	// Generate unique code
-	code, err := generateUniqueCodeInDB(ctx.Tx)
+	// Validate recipient name (it becomes the chat name of the code's viewers)
+	recipientName, err := normalizeRecipientName(ctx.Tx, studioId, req.RecipientName)
 	if err != nil {
-		return resp, errors.New("Failed to generate unique code")
+		return
+	}
+
+	// Validate schedule binding (room codes only, schedule must be for that room)
+	if req.ScheduleId != 0 {
+		if req.Type != int(CodeTypeRoom) {
+			return resp, errors.New("Only room codes can be limited to a class schedule")
+		}
+		var schedule ClassSchedule
+		vbolt.Read(ctx.Tx, ClassSchedulesBkt, req.ScheduleId, &schedule)
+		if schedule.Id == 0 || !schedule.IsActive {
+			return resp, errors.New("Class schedule not found")
+		}
+		if schedule.RoomId != req.TargetId {
+			return resp, errors.New("Class schedule is not for this room")
+		}
 	}
 
+	vbeam.UseWriteTx(ctx)
+
 	// Calculate expiration time
 	now := time.Now()
 	var expiresAt time.Time
@@ -819,31 +914,79 @@ func GenerateAccessCode(ctx *vbeam.Context, req GenerateAccessCodeRequest) (resp
 		expiresAt = now.Add(time.Duration(req.DurationMinutes) * time.Minute)
 	}
 
-	// Create access code
-	accessCode := AccessCode{
-		Code:       code,
+	accessCode, err := createAccessCodeTx(ctx.Tx, AccessCode{
 		Type:       CodeType(req.Type),
 		TargetId:   req.TargetId,
 		CreatedBy:  caller.Id,
-		CreatedAt:  now,
 		ExpiresAt:  expiresAt,
 		MaxViewers: req.MaxViewers,
-		IsRevoked:  false,
+		MaxDevices: req.MaxDevices,
+		ScheduleId: req.ScheduleId,
 		Label:      req.Label,
+
+		RecipientName: recipientName,
+	})
+	if err != nil {
+		return resp, err
 	}
+	code := accessCode.Code
+
+	vbolt.TxCommit(ctx.Tx)
+
+	// Log code generation
+	LogInfo(LogCategorySystem, "Access code generated", map[string]interface{}{
+		"code":       code,
+		"type":       req.Type,
+		"targetId":   req.TargetId,
+		"target":     targetName,
+		"studioId":   studioId,
+		"duration":   req.DurationMinutes,
+		"expiresAt":  expiresAt,
+		"createdBy":  caller.Id,
+		"userEmail":  caller.Email,
+		"label":      req.Label,
+		"recipient":  recipientName,
+		"maxDevices": req.MaxDevices,
+		"scheduleId": req.ScheduleId,
+	})
+
+	// Build share URL
+	shareURL := fmt.Sprintf("/watch/%s", code)
+
+	resp.Code = code
+	resp.ExpiresAt = expiresAt
+	resp.ShareURL = shareURL
+	return
+}
+
+// createAccessCodeTx generates a unique code for the given template (type, target,
+// creator, limits and label), stores it with its indexes and initializes its analytics.
+// The caller is responsible for committing.
+func createAccessCodeTx(tx *vbolt.Tx, template AccessCode) (accessCode AccessCode, err error) {
+	// Generate unique code
+	code, err := generateUniqueCodeInDB(tx)
+	if err != nil {
+		return accessCode, errors.New("Failed to generate unique code")
+	}
+
+	// Create access code
+	accessCode = template
+	accessCode.Code = code
+	accessCode.CreatedAt = time.Now()
+	accessCode.IsRevoked = false
 
 	// Save to database
-	vbolt.Write(ctx.Tx, AccessCodesBkt, code, &accessCode)
+	vbolt.Write(tx, AccessCodesBkt, code, &accessCode)
 
 	// Add to appropriate index
-	if req.Type == int(CodeTypeRoom) {
-		vbolt.SetTargetSingleTerm(ctx.Tx, CodesByRoomIdx, code, req.TargetId)
+	if accessCode.Type == CodeTypeRoom {
+		vbolt.SetTargetSingleTerm(tx, CodesByRoomIdx, code, accessCode.TargetId)
 	} else {
-		vbolt.SetTargetSingleTerm(ctx.Tx, CodesByStudioIdx, code, req.TargetId)
+		vbolt.SetTargetSingleTerm(tx, CodesByStudioIdx, code, accessCode.TargetId)
 	}
 
 	// Add to creator index
-	vbolt.SetTargetSingleTerm(ctx.Tx, CodesByCreatorIdx, code, caller.Id)
+	vbolt.SetTargetSingleTerm(tx, CodesByCreatorIdx, code, accessCode.CreatedBy)
 
 	// Initialize analytics
 	analytics := CodeAnalytics{
@@ -854,36 +997,37 @@ func GenerateAccessCode(ctx *vbeam.Context, req GenerateAccessCodeRequest) (resp
 		PeakViewersAt:    time.Time{},
 		LastConnectionAt: time.Time{},
 	}
-	vbolt.Write(ctx.Tx, CodeAnalyticsBkt, code, &analytics)
-
-	vbolt.TxCommit(ctx.Tx)
-
-	// Log code generation
-	LogInfo(LogCategorySystem, "Access code generated", map[string]interface{}{
-		"code":      code,
-		"type":      req.Type,
-		"targetId":  req.TargetId,
-		"target":    targetName,
-		"studioId":  studioId,
-		"duration":  req.DurationMinutes,
-		"expiresAt": expiresAt,
-		"createdBy": caller.Id,
-		"userEmail": caller.Email,
-		"label":     req.Label,
-	})
+	vbolt.Write(tx, CodeAnalyticsBkt, code, &analytics)
 
-	// Build share URL
-	shareURL := fmt.Sprintf("/watch/%s", code)
+	return accessCode, nil
+}
 
-	resp.Code = code
-	resp.ExpiresAt = expiresAt
-	resp.ShareURL = shareURL
-	return
+// normalizeRecipientName checks the name a code is made out to like a name its
+// viewer would pick themselves: trimmed, length limited and through the
+// studio's chat filter. An empty name is allowed.
+func normalizeRecipientName(tx *vbolt.Tx, studioId int, name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil
+	}
+	name, err := normalizeChatDisplayName(name)
+	if err != nil {
+		return "", err
+	}
+	if filterChatText(getChatFilterSettings(tx, studioId), name) != "" {
+		return "", errors.New("That name isn't allowed in this chat")
+	}
+	return name, nil
 }
 
 // validateAccessCodeLogic contains the core validation logic for access codes.
 // This is extracted as a helper to allow both HTTP handler and procedure usage.
 func validateAccessCodeLogic(db *vbolt.DB, code string) (resp ValidateAccessCodeResponse, err error) {
+	return validateAccessCodeLogicForDevice(db, code, CodeDeviceInfo{})
+}
+
+// validateAccessCodeLogicForDevice validates a code redeemed from a specific device,
+// enforcing device binding and recording the device for the admin view
+func validateAccessCodeLogicForDevice(db *vbolt.DB, code string, device CodeDeviceInfo) (resp ValidateAccessCodeResponse, err error) {
 	// Validate code format (5 digits)
 	if len(code) != 5 {
 		return resp, errors.New("Invalid code format")
@@ -919,6 +1063,13 @@ func validateAccessCodeLogic(db *vbolt.DB, code string) (resp ValidateAccessCode
 			return
 		}
 
+		// Check class window (schedule-bound codes)
+		if !codeScheduleWindowOpen(tx, accessCode, now) {
+			err = errors.New("This code only works during class time")
+			validationFailed = true
+			return
+		}
+
 		// Check viewer limit (if set)
 		if accessCode.MaxViewers > 0 {
 			// Load current analytics to check viewer count
@@ -932,6 +1083,13 @@ func validateAccessCodeLogic(db *vbolt.DB, code string) (resp ValidateAccessCode
 			}
 		}
 
+		// Check device binding (if set)
+		if deviceErr := checkCodeDeviceTx(tx, accessCode, device.DeviceId); deviceErr != nil {
+			err = deviceErr
+			validationFailed = true
+			return
+		}
+
 		// Generate session token
 		var tokenErr error
 		sessionToken, tokenErr = generateSessionToken()
@@ -948,12 +1106,16 @@ func validateAccessCodeLogic(db *vbolt.DB, code string) (resp ValidateAccessCode
 			ConnectedAt:      now,
 			LastSeen:         now,
 			GracePeriodUntil: time.Time{}, // Not in grace period yet
-			ClientIP:         "",          // Will be set by middleware later
-			UserAgent:        "",          // Will be set by middleware later
+			ClientIP:         device.ClientIP,
+			UserAgent:        device.UserAgent,
+			DeviceId:         device.DeviceId,
+			DisplayName:      accessCode.RecipientName,
 		}
 		vbolt.Write(tx, CodeSessionsBkt, sessionToken, &session)
+		vbolt.SetTargetSingleTerm(tx, CodeSessionsByCodeIdx, sessionToken, accessCode.Code)
+		recordCodeDeviceTx(tx, accessCode.Code, device, now)
 
-		// Note: CodeSessions are NOT indexed in SessionsByCodeIndex
+		// Note: CodeSessions are indexed in CodeSessionsByCodeIdx, NOT in SessionsByCodeIndex
 		// Only ViewerSessions are indexed there (created when SSE connects)
 
 		// Update analytics
@@ -1028,6 +1190,11 @@ func ValidateAccessCode(ctx *vbeam.Context, req ValidateAccessCodeRequest) (resp
 		return resp, errors.New("Code has expired")
 	}
 
+	// Check class window (schedule-bound codes)
+	if !codeScheduleWindowOpen(ctx.Tx, accessCode, now) {
+		return resp, errors.New("This code only works during class time")
+	}
+
 	// Check viewer limit (if set)
 	if accessCode.MaxViewers > 0 {
 		// Count current viewers by counting active ViewerSessions for this code
@@ -1040,6 +1207,11 @@ func ValidateAccessCode(ctx *vbeam.Context, req ValidateAccessCodeRequest) (resp
 		}
 	}
 
+	// Check device binding (if set) - procedures have no device cookie
+	if err = checkCodeDeviceTx(ctx.Tx, accessCode, ""); err != nil {
+		return resp, err
+	}
+
 	vbeam.UseWriteTx(ctx)
 
 	// Generate session token
@@ -1057,10 +1229,12 @@ func ValidateAccessCode(ctx *vbeam.Context, req ValidateAccessCodeRequest) (resp
 		GracePeriodUntil: time.Time{}, // Not in grace period yet
 		ClientIP:         "",          // Will be set by middleware later
 		UserAgent:        "",          // Will be set by middleware later
+		DisplayName:      accessCode.RecipientName,
 	}
 	vbolt.Write(ctx.Tx, CodeSessionsBkt, sessionToken, &session)
+	vbolt.SetTargetSingleTerm(ctx.Tx, CodeSessionsByCodeIdx, sessionToken, accessCode.Code)
 
-	// Note: CodeSessions are NOT indexed in SessionsByCodeIndex
+	// Note: CodeSessions are indexed in CodeSessionsByCodeIdx, NOT in SessionsByCodeIndex
 	// Only ViewerSessions are indexed there (created when SSE connects)
 
 	// Update analytics
@@ -1152,6 +1326,13 @@ func GetCodeStreamAccess(ctx *vbeam.Context, req GetCodeStreamAccessRequest) (re
 		}
 	}
 
+	// Check class window (schedule-bound codes)
+	if !codeScheduleWindowOpen(ctx.Tx, accessCode, now) {
+		resp.Allowed = false
+		resp.Message = "This code only works during class time"
+		return
+	}
+
 	// Validate room access based on code type
 	if accessCode.Type == CodeTypeRoom {
 		// Room-specific code: verify roomId matches exactly
@@ -1226,28 +1407,53 @@ func RevokeAccessCode(ctx *vbeam.Context, req RevokeAccessCodeRequest) (resp Rev
 		return resp, errors.New("Authentication required")
 	}
 
-	if !HasStudioPermission(ctx.Tx, caller.Id, studioId, StudioRoleAdmin) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapManageCodes) {
 		return resp, errors.New("Admin permission required")
 	}
 
 	vbeam.UseWriteTx(ctx)
+	db := ctx.Tx.DB()
+
+	sessionTokens, roomIds, sessionsKilled := revokeAccessCodeTx(ctx.Tx, &accessCode, studioId)
+
+	vbolt.TxCommit(ctx.Tx)
+
+	dropCodeSessionsPresence(db, accessCode.Code, sessionTokens)
+	broadcastCodeRevoked(accessCode, sessionTokens, roomIds)
+
+	// Log revocation
+	LogInfo(LogCategorySystem, "Access code revoked", map[string]interface{}{
+		"code":           accessCode.Code,
+		"type":           accessCode.Type,
+		"targetId":       accessCode.TargetId,
+		"studioId":       studioId,
+		"sessionsKilled": sessionsKilled,
+		"revokedBy":      caller.Id,
+		"userEmail":      caller.Email,
+	})
 
+	resp.SessionsKilled = sessionsKilled
+	return
+}
+
+// revokeAccessCodeTx marks an access code as revoked and tears down all of its
+// viewer sessions. Returns the revoked session tokens and, for studio codes,
+// the studio's room IDs so the caller can drop their presence and broadcast
+// CODE_REVOKED after committing.
+func revokeAccessCodeTx(tx *vbolt.Tx, accessCode *AccessCode, studioId int) (sessionTokens []string, roomIds []int, sessionsKilled int) {
 	// Mark code as revoked
 	accessCode.IsRevoked = true
-	vbolt.Write(ctx.Tx, AccessCodesBkt, accessCode.Code, &accessCode)
+	vbolt.Write(tx, AccessCodesBkt, accessCode.Code, accessCode)
 
 	// Find all active viewer sessions for this code
 	var sessionKeys []string
-	vbolt.ReadTermTargets(ctx.Tx, SessionsByCodeIndex, accessCode.Code, &sessionKeys, vbolt.Window{})
+	vbolt.ReadTermTargets(tx, SessionsByCodeIndex, accessCode.Code, &sessionKeys, vbolt.Window{})
 
 	// Delete each viewer session
 	// Note: We also need to collect the session tokens for SSE broadcast
-	var sessionTokens []string
-	sessionsKilled := 0
 	for _, sessionKey := range sessionKeys {
-		var session ViewerSession
-		vbolt.Read(ctx.Tx, ViewerSessionsBkt, sessionKey, &session)
-		if session.SessionKey == "" {
+		session, ok := endViewerSessionTx(tx, sessionKey)
+		if !ok {
 			continue
 		}
 
@@ -1258,56 +1464,42 @@ func RevokeAccessCode(ctx *vbeam.Context, req RevokeAccessCodeRequest) (resp Rev
 			sessionTokens = append(sessionTokens, token)
 		}
 
-		// Decrement viewer counts for room and studio
-		room := GetRoom(ctx.Tx, session.RoomId)
-		if room.Id != 0 {
-			// Decrement room analytics
-			var roomAnalytics RoomAnalytics
-			vbolt.Read(ctx.Tx, RoomAnalyticsBkt, session.RoomId, &roomAnalytics)
-			if roomAnalytics.CurrentViewers > 0 {
-				roomAnalytics.CurrentViewers--
-				vbolt.Write(ctx.Tx, RoomAnalyticsBkt, session.RoomId, &roomAnalytics)
-			}
-
-			// Decrement studio analytics
-			var studioAnalytics StudioAnalytics
-			vbolt.Read(ctx.Tx, StudioAnalyticsBkt, room.StudioId, &studioAnalytics)
-			if studioAnalytics.CurrentViewers > 0 {
-				studioAnalytics.CurrentViewers--
-				vbolt.Write(ctx.Tx, StudioAnalyticsBkt, room.StudioId, &studioAnalytics)
-			}
-		}
-
-		// Decrement code analytics
-		var codeAnalytics CodeAnalytics
-		vbolt.Read(ctx.Tx, CodeAnalyticsBkt, session.Code, &codeAnalytics)
-		if codeAnalytics.CurrentViewers > 0 {
-			codeAnalytics.CurrentViewers--
-			vbolt.Write(ctx.Tx, CodeAnalyticsBkt, session.Code, &codeAnalytics)
-		}
-
-		// Remove from indexes
-		vbolt.SetTargetSingleTerm(ctx.Tx, SessionsByRoomIndex, sessionKey, -1)
-		vbolt.SetTargetSingleTerm(ctx.Tx, SessionsByCodeIndex, sessionKey, "-1")
-
-		// Delete the viewer session
-		vbolt.Delete(ctx.Tx, ViewerSessionsBkt, sessionKey)
-
 		sessionsKilled++
 	}
 
 	// For studio codes, fetch room IDs before committing transaction
-	var roomIds []int
 	if accessCode.Type == CodeTypeStudio {
-		vbolt.ReadTermTargets(ctx.Tx, RoomsByStudioIdx, studioId, &roomIds, vbolt.Window{})
+		vbolt.ReadTermTargets(tx, RoomsByStudioIdx, studioId, &roomIds, vbolt.Window{})
 	}
 
-	vbolt.TxCommit(ctx.Tx)
+	return
+}
+
+// endViewerSessionTx deletes a viewer session. The caller drops the viewer's
+// presence after committing (see dropCodeSessionsPresence), which recounts
+// room, studio and code analytics. Returns false if the session doesn't exist.
+func endViewerSessionTx(tx *vbolt.Tx, sessionKey string) (session ViewerSession, ok bool) {
+	vbolt.Read(tx, ViewerSessionsBkt, sessionKey, &session)
+	if session.SessionKey == "" {
+		return session, false
+	}
+
+	// Remove from indexes
+	vbolt.SetTargetSingleTerm(tx, SessionsByRoomIndex, sessionKey, -1)
+	vbolt.SetTargetSingleTerm(tx, SessionsByCodeIndex, sessionKey, "-1")
+	vbolt.SetTargetSingleTerm(tx, SessionsByViewerIndex, sessionKey, "")
+
+	// Delete the viewer session
+	vbolt.Delete(tx, ViewerSessionsBkt, sessionKey)
 
-	// Broadcast CODE_REVOKED event to affected viewers only
-	// Only viewers using THIS specific revoked code will receive the event
-	// For room codes: broadcast to that specific room
-	// For studio codes: broadcast to all rooms in the studio
+	return session, true
+}
+
+// broadcastCodeRevoked sends CODE_REVOKED to the viewers of a revoked code.
+// Only viewers using THIS specific revoked code will receive the event
+// For room codes: broadcast to that specific room
+// For studio codes: broadcast to all rooms in the studio
+func broadcastCodeRevoked(accessCode AccessCode, sessionTokens []string, roomIds []int) {
 	if accessCode.Type == CodeTypeRoom {
 		sseManager.BroadcastCodeRevoked(accessCode.TargetId, sessionTokens)
 	} else {
@@ -1316,20 +1508,6 @@ func RevokeAccessCode(ctx *vbeam.Context, req RevokeAccessCodeRequest) (resp Rev
 			sseManager.BroadcastCodeRevoked(roomId, sessionTokens)
 		}
 	}
-
-	// Log revocation
-	LogInfo(LogCategorySystem, "Access code revoked", map[string]interface{}{
-		"code":           accessCode.Code,
-		"type":           accessCode.Type,
-		"targetId":       accessCode.TargetId,
-		"studioId":       studioId,
-		"sessionsKilled": sessionsKilled,
-		"revokedBy":      caller.Id,
-		"userEmail":      caller.Email,
-	})
-
-	resp.SessionsKilled = sessionsKilled
-	return
 }
 
 // ListAccessCodes returns all access codes for a room or studio
@@ -1370,7 +1548,7 @@ func ListAccessCodes(ctx *vbeam.Context, req ListAccessCodesRequest) (resp ListA
 	}
 
 	// Check if user has Viewer+ permission for the studio
-	if !HasStudioPermission(ctx.Tx, caller.Id, studioId, StudioRoleViewer) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapViewStudio) {
 		targetType := map[bool]string{true: "room", false: "studio"}[req.Type == int(CodeTypeRoom)]
 		return resp, fmt.Errorf("You do not have permission to view access codes for this %s", targetType)
 	}
@@ -1411,9 +1589,16 @@ func ListAccessCodes(ctx *vbeam.Context, req ListAccessCodesRequest) (resp ListA
 			ExpiresAt:      accessCode.ExpiresAt,
 			IsRevoked:      accessCode.IsRevoked,
 			IsExpired:      now.After(accessCode.ExpiresAt),
+			ScheduleId:     accessCode.ScheduleId,
+			RecipientName:  accessCode.RecipientName,
 			CurrentViewers: currentViewers,
 			TotalViews:     analytics.TotalConnections,
 		}
+		if accessCode.ScheduleId != 0 {
+			var schedule ClassSchedule
+			vbolt.Read(ctx.Tx, ClassSchedulesBkt, accessCode.ScheduleId, &schedule)
+			item.ScheduleName = schedule.Name
+		}
 		items = append(items, item)
 	}
 
@@ -1492,7 +1677,7 @@ func GetCodeAnalytics(ctx *vbeam.Context, req GetCodeAnalyticsRequest) (resp Get
 	}
 
 	// Check admin permission
-	if !HasStudioPermission(ctx.Tx, caller.Id, studioId, StudioRoleAdmin) {
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapViewAnalytics) {
 		return resp, errors.New("Only studio admins can view code analytics")
 	}
 
@@ -3451,6 +3451,71 @@ func TestValidateCodeSession(t *testing.T) {
 	})
 }
 
+// Codes are deleted for good once they've been expired longer than the
+// retention window, along with their sessions and analytics
+func TestCleanupOldAccessCodes(t *testing.T) {
+	db := setupTestCodeDB(t)
+	defer db.Close()
+
+	var room Room
+	codes := make(map[string]string)
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		_, room = createTestStudioAndRoom(tx)
+		for name, expiresAt := range map[string]time.Time{
+			"active":  time.Now().Add(time.Hour),
+			"recent":  time.Now().Add(-(ACCESS_CODE_RETENTION_DAYS - 1) * 24 * time.Hour),
+			"expired": time.Now().Add(-(ACCESS_CODE_RETENTION_DAYS + 1) * 24 * time.Hour),
+		} {
+			code, err := createAccessCodeTx(tx, AccessCode{Type: CodeTypeRoom, TargetId: room.Id, CreatedBy: 1, ExpiresAt: expiresAt})
+			if err != nil {
+				t.Fatalf("createAccessCodeTx failed: %v", err)
+			}
+			codes[name] = code.Code
+
+			session := CodeSession{Token: "session-" + name, Code: code.Code, ConnectedAt: time.Now()}
+			vbolt.Write(tx, CodeSessionsBkt, session.Token, &session)
+			vbolt.SetTargetSingleTerm(tx, CodeSessionsByCodeIdx, session.Token, code.Code)
+			analytics := CodeAnalytics{Code: code.Code, TotalConnections: 1}
+			vbolt.Write(tx, CodeAnalyticsBkt, code.Code, &analytics)
+		}
+		vbolt.TxCommit(tx)
+	})
+
+	if cleaned := CleanupOldAccessCodes(db, ACCESS_CODE_RETENTION_DAYS); cleaned != 1 {
+		t.Fatalf("Expected 1 code cleaned up, got %d", cleaned)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		for name, code := range codes {
+			var accessCode AccessCode
+			var session CodeSession
+			var analytics CodeAnalytics
+			vbolt.Read(tx, AccessCodesBkt, code, &accessCode)
+			vbolt.Read(tx, CodeSessionsBkt, "session-"+name, &session)
+			vbolt.Read(tx, CodeAnalyticsBkt, code, &analytics)
+			kept := accessCode.Code != "" && session.Token != "" && analytics.Code != ""
+			gone := accessCode.Code == "" && session.Token == "" && analytics.Code == ""
+			if name == "expired" && !gone {
+				t.Errorf("Expected the code expired past the window to be deleted with its data")
+			}
+			if name != "expired" && !kept {
+				t.Errorf("Expected the %s code to be kept", name)
+			}
+		}
+
+		var roomCodes []string
+		vbolt.ReadTermTargets(tx, CodesByRoomIdx, room.Id, &roomCodes, vbolt.Window{})
+		if len(roomCodes) != 2 {
+			t.Errorf("Expected the deleted code to leave the room index, got %v", roomCodes)
+		}
+	})
+
+	// Running again deletes nothing more
+	if cleaned := CleanupOldAccessCodes(db, ACCESS_CODE_RETENTION_DAYS); cleaned != 0 {
+		t.Errorf("Expected nothing left to clean up, got %d", cleaned)
+	}
+}
+
 func TestCleanupInactiveSessions(t *testing.T) {
 	t.Skip("CleanupInactiveSessions function removed - functionality now handled by unified ViewerSession cleanup in analytics_test.go")
 }
@@ -4441,6 +4506,7 @@ func TestHandleExpiredCodes(t *testing.T) {
 		db := setupTestCodeDB(t)
 		defer db.Close()
 
+		var room Room
 		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
 			// Create studio and room
 			studio := Studio{
@@ -4453,7 +4519,7 @@ func TestHandleExpiredCodes(t *testing.T) {
 			}
 			vbolt.Write(tx, StudiosBkt, studio.Id, &studio)
 
-			room := Room{
+			room = Room{
 				Id:         vbolt.NextIntId(tx, RoomsBkt),
 				StudioId:   studio.Id,
 				RoomNumber: 1,
@@ -4502,6 +4568,7 @@ func TestHandleExpiredCodes(t *testing.T) {
 
 			vbolt.TxCommit(tx)
 		})
+		joinPresence(db, room, "code:session-token-1", "54321")
 
 		// Run the expiration handler
 		affectedCount := HandleExpiredCodes(db)
@@ -4837,3 +4904,140 @@ func TestHandleExpiredCodes(t *testing.T) {
 		}
 	})
 }
+
+// createTestCodeSchedule creates an active one-time class schedule for a room
+func createTestCodeSchedule(tx *vbolt.Tx, room Room, name string, start time.Time) ClassSchedule {
+	schedule := ClassSchedule{
+		Id:              vbolt.NextIntId(tx, ClassSchedulesBkt),
+		RoomId:          room.Id,
+		StudioId:        room.StudioId,
+		Name:            name,
+		IsRecurring:     false,
+		StartTime:       start,
+		EndTime:         start.Add(time.Hour),
+		PreRollMinutes:  5,
+		PostRollMinutes: 2,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+		IsActive:        true,
+	}
+	vbolt.Write(tx, ClassSchedulesBkt, schedule.Id, &schedule)
+	vbolt.SetTargetSingleTerm(tx, SchedulesByRoomIdx, schedule.Id, schedule.RoomId)
+	return schedule
+}
+
+func TestScheduleBoundAccessCode(t *testing.T) {
+	db := setupTestCodeDB(t)
+	defer db.Close()
+
+	admin, studio, room := createTestTicketAdmin(t, db)
+
+	var activeSchedule, laterSchedule, otherRoomSchedule ClassSchedule
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		now := time.Now()
+		activeSchedule = createTestCodeSchedule(tx, room, "Ballet 3", now.Add(-30*time.Minute))
+		laterSchedule = createTestCodeSchedule(tx, room, "Ballet 4", now.Add(6*time.Hour))
+
+		otherRoom := Room{
+			Id:         vbolt.NextIntId(tx, RoomsBkt),
+			StudioId:   studio.Id,
+			RoomNumber: 2,
+			Name:       "Other Room",
+			StreamKey:  "other-room-key",
+			Creation:   now,
+		}
+		vbolt.Write(tx, RoomsBkt, otherRoom.Id, &otherRoom)
+		otherRoomSchedule = createTestCodeSchedule(tx, otherRoom, "Tap 1", now.Add(-30*time.Minute))
+
+		vbolt.TxCommit(tx)
+	})
+
+	token, err := createTestToken(admin.Id)
+	if err != nil {
+		t.Fatalf("Failed to create test token: %v", err)
+	}
+
+	generate := func(req GenerateAccessCodeRequest) (resp GenerateAccessCodeResponse, err error) {
+		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+			ctx := &vbeam.Context{Tx: tx, Token: token}
+			resp, err = GenerateAccessCode(ctx, req)
+		})
+		return
+	}
+
+	t.Run("RejectsInvalidSchedules", func(t *testing.T) {
+		_, err := generate(GenerateAccessCodeRequest{Type: int(CodeTypeStudio), TargetId: studio.Id, DurationMinutes: 60, ScheduleId: activeSchedule.Id})
+		if err == nil {
+			t.Error("Expected studio code with schedule to be rejected")
+		}
+
+		_, err = generate(GenerateAccessCodeRequest{Type: int(CodeTypeRoom), TargetId: room.Id, DurationMinutes: 60, ScheduleId: otherRoomSchedule.Id})
+		if err == nil || !strings.Contains(err.Error(), "not for this room") {
+			t.Errorf("Expected schedule for another room to be rejected, got %v", err)
+		}
+
+		_, err = generate(GenerateAccessCodeRequest{Type: int(CodeTypeRoom), TargetId: room.Id, DurationMinutes: 60, ScheduleId: 99999})
+		if err == nil {
+			t.Error("Expected missing schedule to be rejected")
+		}
+	})
+
+	t.Run("AdmitsDuringClass", func(t *testing.T) {
+		resp, err := generate(GenerateAccessCodeRequest{Type: int(CodeTypeRoom), TargetId: room.Id, DurationMinutes: 60 * 24 * 90, ScheduleId: activeSchedule.Id})
+		if err != nil {
+			t.Fatalf("GenerateAccessCode failed: %v", err)
+		}
+
+		validateResp, err := validateAccessCodeLogic(db, resp.Code)
+		if err != nil {
+			t.Fatalf("Expected code to work during class: %v", err)
+		}
+
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			result := checkCodeAccessForRoom(tx, -1, validateResp.SessionToken, room.Id, studio.Id)
+			if !result.Allowed {
+				t.Error("Expected room access during class")
+			}
+		})
+
+		// Once the class is over (past post-roll and grace period), the session stops working
+		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+			schedule := activeSchedule
+			schedule.StartTime = time.Now().Add(-3 * time.Hour)
+			schedule.EndTime = time.Now().Add(-2 * time.Hour)
+			vbolt.Write(tx, ClassSchedulesBkt, schedule.Id, &schedule)
+			vbolt.TxCommit(tx)
+		})
+
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			result := checkCodeAccessForRoom(tx, -1, validateResp.SessionToken, room.Id, studio.Id)
+			if result.Allowed {
+				t.Error("Expected room access to be denied after class")
+			}
+			valid, _, _, reason := ValidateCodeSession(tx, validateResp.SessionToken)
+			if valid || !strings.Contains(reason, "class time") {
+				t.Errorf("Expected session to be invalid after class, got valid=%v reason=%q", valid, reason)
+			}
+		})
+	})
+
+	t.Run("RejectsOutsideClass", func(t *testing.T) {
+		resp, err := generate(GenerateAccessCodeRequest{Type: int(CodeTypeRoom), TargetId: room.Id, DurationMinutes: 60 * 24 * 90, ScheduleId: laterSchedule.Id})
+		if err != nil {
+			t.Fatalf("GenerateAccessCode failed: %v", err)
+		}
+
+		_, err = validateAccessCodeLogic(db, resp.Code)
+		if err == nil || !strings.Contains(err.Error(), "class time") {
+			t.Errorf("Expected code to be rejected outside class, got %v", err)
+		}
+
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var accessCode AccessCode
+			vbolt.Read(tx, AccessCodesBkt, resp.Code, &accessCode)
+			if accessCode.ScheduleId != laterSchedule.Id {
+				t.Errorf("ScheduleId mismatch: got %d, want %d", accessCode.ScheduleId, laterSchedule.Id)
+			}
+		})
+	})
+}
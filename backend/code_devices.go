@@ -0,0 +1,384 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"stream/cfg"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+// deviceCookieName holds a random long-lived device ID used for code device binding
+const deviceCookieName = "viewerDevice"
+
+// CodeDeviceInfo identifies the device redeeming a code
+type CodeDeviceInfo struct {
+	DeviceId  string
+	ClientIP  string
+	UserAgent string
+}
+
+// CodeDevice is a distinct device that has redeemed an access code
+type CodeDevice struct {
+	Code        string    `json:"code"`
+	DeviceId    string    `json:"deviceId"`
+	FirstSeenAt time.Time `json:"firstSeenAt"` // First redemption from this device
+	LastSeenAt  time.Time `json:"lastSeenAt"`  // Most recent redemption
+	ClientIP    string    `json:"clientIP"`    // IP of the most recent redemption
+	UserAgent   string    `json:"userAgent"`   // User agent of the most recent redemption
+	Redemptions int       `json:"redemptions"` // Number of times the code was redeemed from this device
+	IsBlocked   bool      `json:"isBlocked"`   // Blocked by an admin (frees its slot)
+}
+
+func PackCodeDevice(self *CodeDevice, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.String(&self.Code, buf)
+	vpack.String(&self.DeviceId, buf)
+	vpack.Time(&self.FirstSeenAt, buf)
+	vpack.Time(&self.LastSeenAt, buf)
+	vpack.String(&self.ClientIP, buf)
+	vpack.String(&self.UserAgent, buf)
+	vpack.Int(&self.Redemptions, buf)
+	vpack.Bool(&self.IsBlocked, buf)
+}
+
+// CodeDevicesBkt: "code:deviceId" (string) -> CodeDevice
+var CodeDevicesBkt = vbolt.Bucket(&cfg.Info, "code_devices", vpack.StringZ, PackCodeDevice)
+
+// DevicesByCodeIdx: code (term) -> "code:deviceId" (target)
+var DevicesByCodeIdx = vbolt.Index(&cfg.Info, "devices_by_code", vpack.StringZ, vpack.StringZ)
+
+// CodeSessionsByCodeIdx: code (term) -> sessionToken (target)
+// Find all code sessions created from a specific access code
+var CodeSessionsByCodeIdx = vbolt.Index(&cfg.Info, "code_sessions_by_code", vpack.StringZ, vpack.StringZ)
+
+func codeDeviceKey(code string, deviceId string) string {
+	return code + ":" + deviceId
+}
+
+// getOrSetDeviceId returns the request's device ID, issuing a new device cookie if absent
+func getOrSetDeviceId(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(deviceCookieName); err == nil && len(cookie.Value) >= 32 && len(cookie.Value) <= 64 {
+		return cookie.Value
+	}
+
+	deviceId, err := generateSessionToken()
+	if err != nil {
+		return ""
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     deviceCookieName,
+		Value:    deviceId,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   60 * 60 * 24 * 365, // 1 year
+	})
+	return deviceId
+}
+
+// listCodeDevices returns all devices that have redeemed a code
+func listCodeDevices(tx *vbolt.Tx, code string) []CodeDevice {
+	var keys []string
+	vbolt.ReadTermTargets(tx, DevicesByCodeIdx, code, &keys, vbolt.Window{})
+
+	devices := make([]CodeDevice, 0, len(keys))
+	for _, key := range keys {
+		var device CodeDevice
+		vbolt.Read(tx, CodeDevicesBkt, key, &device)
+		if device.DeviceId != "" {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// checkCodeDeviceTx refuses blocked devices on any code and enforces device
+// binding for codes with MaxDevices set. Devices that already own the code are
+// always allowed back in; a new device is only accepted while fewer than
+// MaxDevices non-blocked devices are bound.
+func checkCodeDeviceTx(tx *vbolt.Tx, accessCode AccessCode, deviceId string) error {
+	var device CodeDevice
+	if deviceId != "" {
+		vbolt.Read(tx, CodeDevicesBkt, codeDeviceKey(accessCode.Code, deviceId), &device)
+		if device.IsBlocked {
+			return errors.New("This device has been blocked from using this code")
+		}
+	}
+
+	if accessCode.MaxDevices <= 0 {
+		return nil
+	}
+
+	if deviceId == "" {
+		return errors.New("This code can only be used from a recognized device")
+	}
+	if device.DeviceId != "" {
+		return nil
+	}
+
+	bound := 0
+	for _, existing := range listCodeDevices(tx, accessCode.Code) {
+		if !existing.IsBlocked {
+			bound++
+		}
+	}
+	if bound >= accessCode.MaxDevices {
+		return fmt.Errorf("Code is already in use on the maximum number of devices (%d)", accessCode.MaxDevices)
+	}
+	return nil
+}
+
+// recordCodeDeviceTx creates or updates the device record for a redemption
+func recordCodeDeviceTx(tx *vbolt.Tx, code string, info CodeDeviceInfo, now time.Time) {
+	if info.DeviceId == "" {
+		return
+	}
+
+	key := codeDeviceKey(code, info.DeviceId)
+	var device CodeDevice
+	vbolt.Read(tx, CodeDevicesBkt, key, &device)
+	if device.DeviceId == "" {
+		device = CodeDevice{
+			Code:        code,
+			DeviceId:    info.DeviceId,
+			FirstSeenAt: now,
+		}
+		vbolt.SetTargetSingleTerm(tx, DevicesByCodeIdx, key, code)
+	}
+
+	device.LastSeenAt = now
+	device.ClientIP = info.ClientIP
+	device.UserAgent = info.UserAgent
+	device.Redemptions++
+	vbolt.Write(tx, CodeDevicesBkt, key, &device)
+}
+
+// deleteCodeSessionsAndDevicesTx removes all code sessions and device records of a code
+func deleteCodeSessionsAndDevicesTx(tx *vbolt.Tx, code string) {
+	var tokens []string
+	vbolt.ReadTermTargets(tx, CodeSessionsByCodeIdx, code, &tokens, vbolt.Window{})
+	for _, token := range tokens {
+		vbolt.Delete(tx, CodeSessionsBkt, token)
+		vbolt.SetTargetSingleTerm(tx, CodeSessionsByCodeIdx, token, "")
+	}
+
+	var keys []string
+	vbolt.ReadTermTargets(tx, DevicesByCodeIdx, code, &keys, vbolt.Window{})
+	for _, key := range keys {
+		vbolt.Delete(tx, CodeDevicesBkt, key)
+		vbolt.SetTargetSingleTerm(tx, DevicesByCodeIdx, key, "")
+	}
+}
+
+// codeStudioId returns the studio an access code belongs to (0 if its room is gone)
+func codeStudioId(tx *vbolt.Tx, accessCode AccessCode) int {
+	if accessCode.Type == CodeTypeRoom {
+		return GetRoom(tx, accessCode.TargetId).StudioId
+	}
+	return accessCode.TargetId
+}
+
+// API Request/Response types
+
+type GetCodeDevicesRequest struct {
+	Code string `json:"code"`
+}
+
+type CodeDeviceItem struct {
+	DeviceId       string    `json:"deviceId"`
+	FirstSeenAt    time.Time `json:"firstSeenAt"`
+	LastSeenAt     time.Time `json:"lastSeenAt"`
+	ClientIP       string    `json:"clientIP"` // Anonymized IP address
+	UserAgent      string    `json:"userAgent"`
+	Redemptions    int       `json:"redemptions"`
+	IsBlocked      bool      `json:"isBlocked"`
+	ActiveSessions int       `json:"activeSessions"` // Code sessions from this device that still exist
+}
+
+type CodeSessionItem struct {
+	Token       string    `json:"token"` // Used to kick the session
+	DeviceId    string    `json:"deviceId"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	LastSeen    time.Time `json:"lastSeen"`
+	ClientIP    string    `json:"clientIP"` // Anonymized IP address
+	UserAgent   string    `json:"userAgent"`
+	WatchingIn  []int     `json:"watchingIn"` // Room IDs with an open stream connection
+}
+
+type GetCodeDevicesResponse struct {
+	Code       string            `json:"code"`
+	MaxDevices int               `json:"maxDevices"`
+	MaxViewers int               `json:"maxViewers"`
+	Devices    []CodeDeviceItem  `json:"devices"`
+	Sessions   []CodeSessionItem `json:"sessions"`
+}
+
+type KickCodeSessionRequest struct {
+	SessionToken string `json:"sessionToken"`
+	BlockDevice  bool   `json:"blockDevice"` // Also block the session's device from redeeming the code again
+}
+
+type KickCodeSessionResponse struct {
+	SessionsKilled int `json:"sessionsKilled"` // Viewer connections terminated
+}
+
+// API Procedures
+
+// GetCodeDevices lists the devices and code sessions using an access code
+func GetCodeDevices(ctx *vbeam.Context, req GetCodeDevicesRequest) (resp GetCodeDevicesResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	var accessCode AccessCode
+	vbolt.Read(ctx.Tx, AccessCodesBkt, req.Code, &accessCode)
+	if accessCode.Code == "" {
+		return resp, errors.New("Access code not found")
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, codeStudioId(ctx.Tx, accessCode), CapManageCodes) {
+		return resp, errors.New("Admin permission required")
+	}
+
+	// Rooms each code session is currently connected to
+	watching := make(map[string][]int)
+	var viewerKeys []string
+	vbolt.ReadTermTargets(ctx.Tx, SessionsByCodeIndex, accessCode.Code, &viewerKeys, vbolt.Window{})
+	for _, key := range viewerKeys {
+		var viewer ViewerSession
+		vbolt.Read(ctx.Tx, ViewerSessionsBkt, key, &viewer)
+		if len(viewer.ViewerId) > 5 && viewer.ViewerId[:5] == "code:" {
+			token := viewer.ViewerId[5:]
+			watching[token] = append(watching[token], viewer.RoomId)
+		}
+	}
+
+	sessionsPerDevice := make(map[string]int)
+	var tokens []string
+	vbolt.ReadTermTargets(ctx.Tx, CodeSessionsByCodeIdx, accessCode.Code, &tokens, vbolt.Window{})
+	resp.Sessions = make([]CodeSessionItem, 0, len(tokens))
+	for _, token := range tokens {
+		var session CodeSession
+		vbolt.Read(ctx.Tx, CodeSessionsBkt, token, &session)
+		if session.Token == "" {
+			continue
+		}
+		sessionsPerDevice[session.DeviceId]++
+		resp.Sessions = append(resp.Sessions, CodeSessionItem{
+			Token:       session.Token,
+			DeviceId:    session.DeviceId,
+			ConnectedAt: session.ConnectedAt,
+			LastSeen:    session.LastSeen,
+			ClientIP:    anonymizeIP(session.ClientIP),
+			UserAgent:   session.UserAgent,
+			WatchingIn:  watching[session.Token],
+		})
+	}
+
+	devices := listCodeDevices(ctx.Tx, accessCode.Code)
+	resp.Devices = make([]CodeDeviceItem, 0, len(devices))
+	for _, device := range devices {
+		resp.Devices = append(resp.Devices, CodeDeviceItem{
+			DeviceId:       device.DeviceId,
+			FirstSeenAt:    device.FirstSeenAt,
+			LastSeenAt:     device.LastSeenAt,
+			ClientIP:       anonymizeIP(device.ClientIP),
+			UserAgent:      device.UserAgent,
+			Redemptions:    device.Redemptions,
+			IsBlocked:      device.IsBlocked,
+			ActiveSessions: sessionsPerDevice[device.DeviceId],
+		})
+	}
+
+	resp.Code = accessCode.Code
+	resp.MaxDevices = accessCode.MaxDevices
+	resp.MaxViewers = accessCode.MaxViewers
+	return
+}
+
+// KickCodeSession ends a single code session, disconnecting its viewers via CODE_REVOKED.
+// The code itself stays valid for everyone else.
+func KickCodeSession(ctx *vbeam.Context, req KickCodeSessionRequest) (resp KickCodeSessionResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	var session CodeSession
+	vbolt.Read(ctx.Tx, CodeSessionsBkt, req.SessionToken, &session)
+	if session.Token == "" {
+		return resp, errors.New("Session not found")
+	}
+
+	var accessCode AccessCode
+	vbolt.Read(ctx.Tx, AccessCodesBkt, session.Code, &accessCode)
+	if accessCode.Code == "" {
+		return resp, errors.New("Access code not found")
+	}
+
+	studioId := codeStudioId(ctx.Tx, accessCode)
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapManageCodes) {
+		return resp, errors.New("Admin permission required")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	db := ctx.Tx.DB()
+
+	// End the session's viewer connections
+	viewerId := "code:" + session.Token
+	var viewerKeys []string
+	vbolt.ReadTermTargets(ctx.Tx, SessionsByCodeIndex, accessCode.Code, &viewerKeys, vbolt.Window{})
+	for _, key := range viewerKeys {
+		var viewer ViewerSession
+		vbolt.Read(ctx.Tx, ViewerSessionsBkt, key, &viewer)
+		if viewer.ViewerId != viewerId {
+			continue
+		}
+		if _, ok := endViewerSessionTx(ctx.Tx, key); ok {
+			resp.SessionsKilled++
+		}
+	}
+
+	// Delete the code session so its JWT no longer grants access
+	vbolt.Delete(ctx.Tx, CodeSessionsBkt, session.Token)
+	vbolt.SetTargetSingleTerm(ctx.Tx, CodeSessionsByCodeIdx, session.Token, "")
+
+	if req.BlockDevice && session.DeviceId != "" {
+		key := codeDeviceKey(accessCode.Code, session.DeviceId)
+		var device CodeDevice
+		vbolt.Read(ctx.Tx, CodeDevicesBkt, key, &device)
+		if device.DeviceId != "" {
+			device.IsBlocked = true
+			vbolt.Write(ctx.Tx, CodeDevicesBkt, key, &device)
+		}
+	}
+
+	var roomIds []int
+	if accessCode.Type == CodeTypeStudio {
+		vbolt.ReadTermTargets(ctx.Tx, RoomsByStudioIdx, studioId, &roomIds, vbolt.Window{})
+	}
+
+	vbolt.TxCommit(ctx.Tx)
+
+	dropCodeSessionsPresence(db, accessCode.Code, []string{session.Token})
+	broadcastCodeRevoked(accessCode, []string{session.Token}, roomIds)
+
+	LogInfo(LogCategorySystem, "Code session kicked", map[string]interface{}{
+		"code":           accessCode.Code,
+		"deviceId":       session.DeviceId,
+		"blockDevice":    req.BlockDevice,
+		"sessionsKilled": resp.SessionsKilled,
+		"kickedBy":       caller.Id,
+		"userEmail":      caller.Email,
+	})
+	return
+}
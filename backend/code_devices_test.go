@@ -0,0 +1,282 @@
+package backend
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+// createTestDeviceBoundCode creates a room code bound to maxDevices devices
+func createTestDeviceBoundCode(t *testing.T, db *vbolt.DB, roomId int, maxDevices int) AccessCode {
+	var accessCode AccessCode
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		var err error
+		accessCode, err = createAccessCodeTx(tx, AccessCode{
+			Type:       CodeTypeRoom,
+			TargetId:   roomId,
+			CreatedBy:  1,
+			ExpiresAt:  time.Now().Add(2 * time.Hour),
+			MaxDevices: maxDevices,
+			Label:      "Family",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create code: %v", err)
+		}
+		vbolt.TxCommit(tx)
+	})
+	return accessCode
+}
+
+func TestPackAccessCodeMaxDevices(t *testing.T) {
+	db := setupTestCodeDB(t)
+	defer db.Close()
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		original := AccessCode{Code: "24680", TargetId: 1, MaxDevices: 3}
+		vbolt.Write(tx, AccessCodesBkt, original.Code, &original)
+
+		session := CodeSession{Token: "tok", Code: "24680", DeviceId: "device-a"}
+		vbolt.Write(tx, CodeSessionsBkt, session.Token, &session)
+
+		var retrieved AccessCode
+		vbolt.Read(tx, AccessCodesBkt, original.Code, &retrieved)
+		if retrieved.MaxDevices != 3 {
+			t.Errorf("MaxDevices mismatch: got %d, want 3", retrieved.MaxDevices)
+		}
+
+		var retrievedSession CodeSession
+		vbolt.Read(tx, CodeSessionsBkt, session.Token, &retrievedSession)
+		if retrievedSession.DeviceId != "device-a" {
+			t.Errorf("DeviceId mismatch: got %s, want device-a", retrievedSession.DeviceId)
+		}
+	})
+}
+
+func TestCodeDeviceBinding(t *testing.T) {
+	db := setupTestCodeDB(t)
+	defer db.Close()
+
+	var room Room
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		_, room = createTestStudioAndRoom(tx)
+		vbolt.TxCommit(tx)
+	})
+	accessCode := createTestDeviceBoundCode(t, db, room.Id, 2)
+
+	deviceA := CodeDeviceInfo{DeviceId: strings.Repeat("a", 43), ClientIP: "10.0.0.1", UserAgent: "Phone"}
+	deviceB := CodeDeviceInfo{DeviceId: strings.Repeat("b", 43), ClientIP: "10.0.0.2", UserAgent: "Laptop"}
+	deviceC := CodeDeviceInfo{DeviceId: strings.Repeat("c", 43), ClientIP: "10.0.0.3", UserAgent: "Tablet"}
+
+	if _, err := validateAccessCodeLogicForDevice(db, accessCode.Code, deviceA); err != nil {
+		t.Fatalf("Device A should redeem: %v", err)
+	}
+	if _, err := validateAccessCodeLogicForDevice(db, accessCode.Code, deviceB); err != nil {
+		t.Fatalf("Device B should redeem: %v", err)
+	}
+
+	// Third distinct device is rejected
+	_, err := validateAccessCodeLogicForDevice(db, accessCode.Code, deviceC)
+	if err == nil || !strings.Contains(err.Error(), "maximum number of devices") {
+		t.Errorf("Expected device limit error, got %v", err)
+	}
+
+	// Bound device can come back
+	if _, err := validateAccessCodeLogicForDevice(db, accessCode.Code, deviceA); err != nil {
+		t.Errorf("Bound device A should redeem again: %v", err)
+	}
+
+	// Unknown device is rejected for bound codes
+	if _, err := validateAccessCodeLogic(db, accessCode.Code); err == nil {
+		t.Errorf("Expected unidentified device to be rejected")
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		devices := listCodeDevices(tx, accessCode.Code)
+		if len(devices) != 2 {
+			t.Fatalf("Expected 2 devices, got %d", len(devices))
+		}
+		for _, device := range devices {
+			if device.DeviceId == deviceA.DeviceId && device.Redemptions != 2 {
+				t.Errorf("Expected device A to have 2 redemptions, got %d", device.Redemptions)
+			}
+		}
+	})
+}
+
+func TestCodeWithoutDeviceLimitRecordsDevices(t *testing.T) {
+	db := setupTestCodeDB(t)
+	defer db.Close()
+
+	var room Room
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		_, room = createTestStudioAndRoom(tx)
+		vbolt.TxCommit(tx)
+	})
+	accessCode := createTestDeviceBoundCode(t, db, room.Id, 0)
+
+	for _, id := range []string{"a", "b", "c"} {
+		device := CodeDeviceInfo{DeviceId: strings.Repeat(id, 43)}
+		if _, err := validateAccessCodeLogicForDevice(db, accessCode.Code, device); err != nil {
+			t.Fatalf("Unbound code should accept any device: %v", err)
+		}
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if devices := listCodeDevices(tx, accessCode.Code); len(devices) != 3 {
+			t.Errorf("Expected 3 recorded devices, got %d", len(devices))
+		}
+	})
+
+	// A device blocked by an admin is refused even without a device limit
+	blocked := CodeDeviceInfo{DeviceId: strings.Repeat("a", 43)}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		key := codeDeviceKey(accessCode.Code, blocked.DeviceId)
+		var device CodeDevice
+		vbolt.Read(tx, CodeDevicesBkt, key, &device)
+		device.IsBlocked = true
+		vbolt.Write(tx, CodeDevicesBkt, key, &device)
+		vbolt.TxCommit(tx)
+	})
+	if _, err := validateAccessCodeLogicForDevice(db, accessCode.Code, blocked); err == nil {
+		t.Errorf("Expected blocked device to be rejected")
+	}
+}
+
+func TestKickCodeSession(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	accessCode := createTestDeviceBoundCode(t, db, room.Id, 1)
+
+	owner := CodeDeviceInfo{DeviceId: strings.Repeat("o", 43), ClientIP: "10.0.0.1"}
+	sharer := CodeDeviceInfo{DeviceId: strings.Repeat("s", 43), ClientIP: "10.0.0.2"}
+
+	// The sharer redeems first and grabs the only slot
+	sharerResp, err := validateAccessCodeLogicForDevice(db, accessCode.Code, sharer)
+	if err != nil {
+		t.Fatalf("Sharer redemption failed: %v", err)
+	}
+	IncrementRoomViewerCount(db, room.Id, "code:"+sharerResp.SessionToken, accessCode.Code)
+
+	if _, err := validateAccessCodeLogicForDevice(db, accessCode.Code, owner); err == nil {
+		t.Fatalf("Expected owner to be blocked by device limit")
+	}
+
+	token, _ := createTestToken(admin.Id)
+
+	// Admin sees the device and its session
+	var devicesResp GetCodeDevicesResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: token}
+		devicesResp, err = GetCodeDevices(ctx, GetCodeDevicesRequest{Code: accessCode.Code})
+	})
+	if err != nil {
+		t.Fatalf("GetCodeDevices failed: %v", err)
+	}
+	if len(devicesResp.Devices) != 1 || devicesResp.Devices[0].ActiveSessions != 1 {
+		t.Fatalf("Expected 1 device with 1 session, got %+v", devicesResp.Devices)
+	}
+	if len(devicesResp.Sessions) != 1 || len(devicesResp.Sessions[0].WatchingIn) != 1 {
+		t.Fatalf("Expected 1 session watching 1 room, got %+v", devicesResp.Sessions)
+	}
+
+	// Kick and block the sharer
+	var kickResp KickCodeSessionResponse
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: token}
+		kickResp, err = KickCodeSession(ctx, KickCodeSessionRequest{
+			SessionToken: devicesResp.Sessions[0].Token,
+			BlockDevice:  true,
+		})
+	})
+	if err != nil {
+		t.Fatalf("KickCodeSession failed: %v", err)
+	}
+	if kickResp.SessionsKilled != 1 {
+		t.Errorf("Expected 1 viewer connection killed, got %d", kickResp.SessionsKilled)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		valid, _, _, _ := ValidateCodeSession(tx, sharerResp.SessionToken)
+		if valid {
+			t.Errorf("Kicked session should no longer be valid")
+		}
+		var analytics CodeAnalytics
+		vbolt.Read(tx, CodeAnalyticsBkt, accessCode.Code, &analytics)
+		if analytics.CurrentViewers != 0 {
+			t.Errorf("Expected 0 current viewers after kick, got %d", analytics.CurrentViewers)
+		}
+	})
+
+	// Blocked device can't come back, and its slot is free for the owner
+	if _, err := validateAccessCodeLogicForDevice(db, accessCode.Code, sharer); err == nil {
+		t.Errorf("Expected blocked device to be rejected")
+	}
+	if _, err := validateAccessCodeLogicForDevice(db, accessCode.Code, owner); err != nil {
+		t.Errorf("Expected owner to redeem after sharer was blocked: %v", err)
+	}
+
+	// Code itself is still valid
+	var code AccessCode
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		vbolt.Read(tx, AccessCodesBkt, accessCode.Code, &code)
+	})
+	if code.IsRevoked {
+		t.Errorf("Kicking a session should not revoke the code")
+	}
+}
+
+func TestValidateAccessCodeHandlerSetsDeviceCookie(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	var room Room
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		_, room = createTestStudioAndRoom(tx)
+		vbolt.TxCommit(tx)
+	})
+	accessCode := createTestDeviceBoundCode(t, db, room.Id, 1)
+
+	post := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		body := bytes.NewBufferString(`{"code":"` + accessCode.Code + `"}`)
+		req := httptest.NewRequest("POST", "/api/validate-access-code", body)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		validateAccessCodeHandler(w, req)
+		return w
+	}
+
+	first := post(nil)
+	var deviceCookie *http.Cookie
+	for _, c := range first.Result().Cookies() {
+		if c.Name == deviceCookieName {
+			deviceCookie = c
+		}
+	}
+	if deviceCookie == nil {
+		t.Fatalf("Expected device cookie to be set")
+	}
+
+	// Same device (cookie) is accepted again
+	second := post([]*http.Cookie{deviceCookie})
+	if second.Code != http.StatusOK || strings.Contains(second.Body.String(), "maximum number of devices") {
+		t.Errorf("Expected same device to be accepted, got %d %s", second.Code, second.Body.String())
+	}
+
+	// A new device (no cookie) is rejected
+	third := post(nil)
+	if !strings.Contains(third.Body.String(), "maximum number of devices") {
+		t.Errorf("Expected new device to be rejected, got %s", third.Body.String())
+	}
+}
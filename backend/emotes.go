@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
 )
 
 // RegisterEmoteMethods registers emote-related API procedures
@@ -11,13 +12,13 @@ func RegisterEmoteMethods(app *vbeam.Application) {
 	vbeam.RegisterProc(app, SendEmote)
 }
 
-// Allowed emotes for the reaction system
+// Default emotes for studios that haven't set their own (see studio_emotes.go)
 var AllowedEmotes = []string{"❤️", "👏", "🔥", "😂", "😮", "👍"}
 
 // SendEmoteRequest contains the emote data to broadcast
 type SendEmoteRequest struct {
 	RoomId int    `json:"roomId"` // Room ID where emote is sent
-	Emote  string `json:"emote"`  // Emoji character
+	Emote  string `json:"emote"`  // Emote code from the room's studio set
 }
 
 type SendEmoteResponse struct {
@@ -26,17 +27,17 @@ type SendEmoteResponse struct {
 // SendEmote handles emote reactions from viewers
 // Allows anonymous emote sending with rate limiting
 func SendEmote(ctx *vbeam.Context, req SendEmoteRequest) (resp SendEmoteResponse, err error) {
-	// Validate emote is in allowed list
-	if !isValidEmote(req.Emote) {
-		return resp, errors.New("Invalid emote. Allowed: ❤️, 👏, 🔥, 😂, 😮, 👍")
-	}
-
 	// Validate room exists
 	room := GetRoom(ctx.Tx, req.RoomId)
 	if room.Id == 0 {
 		return resp, errors.New("Room not found")
 	}
 
+	// Validate emote is in the studio's set
+	if _, ok := findStudioEmote(ctx.Tx, room.StudioId, req.Emote); !ok {
+		return resp, errors.New("That emote isn't available in this room")
+	}
+
 	// identifier for rate limiting
 	identifier := ctx.Token
 
@@ -50,6 +51,13 @@ func SendEmote(ctx *vbeam.Context, req SendEmoteRequest) (resp SendEmoteResponse
 		return resp, errors.New("Please wait before sending another emote")
 	}
 
+	// Count it toward the live stream's applause
+	if stream := liveStream(ctx.Tx, room.Id); stream.Id > 0 {
+		vbeam.UseWriteTx(ctx)
+		recordStreamEmoteTx(ctx.Tx, stream.Id, req.Emote)
+		vbolt.TxCommit(ctx.Tx)
+	}
+
 	// Broadcast emote to all viewers via SSE
 	sseManager.BroadcastEmote(req.RoomId, req.Emote)
 
@@ -60,13 +68,3 @@ func SendEmote(ctx *vbeam.Context, req SendEmoteRequest) (resp SendEmoteResponse
 
 	return resp, nil
 }
-
-// isValidEmote checks if the emote is in the allowed list
-func isValidEmote(emote string) bool {
-	for _, allowed := range AllowedEmotes {
-		if emote == allowed {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// BusEvent is an SSE event on its way to the viewers of a room. It goes
+// through the event bus so viewers connected to any app instance get it.
+type BusEvent struct {
+	RoomId        int             `json:"roomId"`
+	Id            int             `json:"id"` // Replay ID (0 for events that aren't replayed)
+	Name          string          `json:"name"`
+	Data          json.RawMessage `json:"data"`
+	SessionTokens []string        `json:"sessionTokens,omitempty"` // Only these code sessions (revocations)
+	ViewerId      string          `json:"viewerId,omitempty"`      // Only this viewer (chat restrictions)
+}
+
+// EventBus carries SSE events between app instances. Every instance
+// subscribes and delivers each event to its own connected clients, including
+// the instance that published it.
+type EventBus interface {
+	// NextEventID returns a replay ID that increases across all instances
+	NextEventID() (int, error)
+	Publish(event BusEvent) error
+	// Subscribe calls deliver for each event. If events may have been
+	// missed (the connection dropped and came back), it first calls gap
+	// with an ID newer than any of them.
+	Subscribe(deliver func(BusEvent), gap func(nextEventID int)) error
+	Close() error
+}
+
+// LocalEventBus delivers events within this process only. It's the default
+// for single-instance deployments, development and tests.
+type LocalEventBus struct {
+	mu       sync.Mutex
+	lastId   int
+	handlers []func(BusEvent)
+}
+
+// NewLocalEventBus creates an in-process bus. Event IDs start from the time
+// it was created, so IDs a browser kept from before a restart are never
+// mistaken for current ones.
+func NewLocalEventBus() *LocalEventBus {
+	return &LocalEventBus{lastId: int(time.Now().UnixMilli())}
+}
+
+func (b *LocalEventBus) NextEventID() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastId++
+	return b.lastId, nil
+}
+
+// Publish delivers the event before returning
+func (b *LocalEventBus) Publish(event BusEvent) error {
+	b.mu.Lock()
+	handlers := b.handlers
+	b.mu.Unlock()
+
+	for _, deliver := range handlers {
+		deliver(event)
+	}
+	return nil
+}
+
+// Subscribe never calls gap, as nothing is lost in process
+func (b *LocalEventBus) Subscribe(deliver func(BusEvent), gap func(nextEventID int)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, deliver)
+	return nil
+}
+
+func (b *LocalEventBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = nil
+	return nil
+}
+
+// SetupEventBus connects SSE broadcasts and viewer presence to Redis when
+// REDIS_URL is set, so several app instances behind a load balancer share
+// chat, emotes, status, revocations and viewer counts. Without it, events
+// stay in this process and presence stays in bolt.
+func SetupEventBus() error {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		LogInfo(LogCategorySystem, "SSE event bus: in-process (REDIS_URL not set)", nil)
+		return nil
+	}
+
+	bus, err := NewRedisEventBus(redisURL, os.Getenv("SSE_EVENT_CHANNEL"))
+	if err != nil {
+		return err
+	}
+	presence, err := NewRedisPresenceStore(redisURL, bus.Channel+":presence")
+	if err != nil {
+		bus.Close()
+		return err
+	}
+	if err := sseManager.UseEventBus(bus); err != nil {
+		bus.Close()
+		presence.Close()
+		return err
+	}
+	presenceStore = presence
+
+	LogInfo(LogCategorySystem, "SSE event bus and viewer presence: Redis", map[string]interface{}{
+		"addr":    bus.Addr,
+		"channel": bus.Channel,
+	})
+	return nil
+}
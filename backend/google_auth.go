@@ -114,11 +114,16 @@ func googleCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if userId > 0 {
-		// User exists, authenticate them
-		err = authenticateForUser(userId, w)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Authentication failed: %s", err.Error()), http.StatusInternalServerError)
-			return
+		// Accounts created before email verification pick it up from Google
+		if userInfo.VerifiedEmail {
+			vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+				user := GetUser(tx, userId)
+				if !user.EmailVerified {
+					user.EmailVerified = true
+					vbolt.Write(tx, UsersBkt, user.Id, &user)
+					vbolt.TxCommit(tx)
+				}
+			})
 		}
 	} else {
 		// User doesn't exist, create a new account
@@ -132,6 +137,12 @@ func googleCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		var user User
 		vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
 			user = AddUserTx(tx, createAccountRequest, []byte{})
+			// Google has already confirmed the address
+			if userInfo.VerifiedEmail {
+				user.EmailVerified = true
+				vbolt.Write(tx, UsersBkt, user.Id, &user)
+				acceptPendingInvitationsTx(tx, user)
+			}
 			vbolt.TxCommit(tx)
 		})
 
@@ -143,23 +154,19 @@ func googleCallbackHandler(w http.ResponseWriter, r *http.Request) {
 				"source": "google_oauth",
 			})
 
-			err = authenticateForUser(user.Id, w)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Authentication failed: %s", err.Error()), http.StatusInternalServerError)
-				return
-			}
+			userId = user.Id
 		} else {
 			http.Error(w, "Failed to create user account", http.StatusInternalServerError)
 			return
 		}
 	}
 
-	// Redirect to the dashboard after successful authentication
-	http.Redirect(w, r, "/dashboard", http.StatusFound)
+	// Sign in (or continue to the two-factor step) and go to the dashboard
+	finishExternalLogin(w, r, userId)
 }
 
 // authenticateForUser generates JWT tokens and sets cookies for the given user ID
-func authenticateForUser(userId int, w http.ResponseWriter) error {
+func authenticateForUser(userId int, w http.ResponseWriter, r *http.Request) error {
 	var user User
 	vbolt.WithReadTx(appDb, func(tx *vbolt.Tx) {
 		user = GetUser(tx, userId)
@@ -170,7 +177,7 @@ func authenticateForUser(userId int, w http.ResponseWriter) error {
 	}
 
 	// Generate and set JWT token
-	_, err := generateAuthJwt(user, w)
+	_, err := generateAuthJwt(user, w, r)
 	if err != nil {
 		return fmt.Errorf("failed to generate auth token: %v", err)
 	}
@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+// setupTestStudioRoom creates a test database with the test globals swapped
+// in, whose first user is the site admin, and a studio with one room
+func setupTestStudioRoom(t *testing.T) (db *vbolt.DB, siteAdmin User, studio Studio, room Room) {
+	db = setupTestTicketingDB(t)
+	t.Cleanup(func() { db.Close() })
+	setupTestTicketingGlobals(t, db)
+	globalRateLimiter.Reset()
+	t.Cleanup(globalRateLimiter.Reset)
+
+	siteAdmin = createTestInvitee(t, db, "siteadmin@test.com", true) // first user is the site admin
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		studio, room = createTestStudioAndRoom(tx)
+		vbolt.TxCommit(tx)
+	})
+	return
+}
+
+// addTestStudioMember creates a user with the role in the studio. An owner
+// also becomes the studio's OwnerId.
+func addTestStudioMember(t *testing.T, db *vbolt.DB, studioId int, email string, role StudioRole) User {
+	user := createTestInvitee(t, db, email, true)
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		addStudioMembershipTx(tx, user.Id, studioId, role)
+		if role == StudioRoleOwner {
+			studio := GetStudioById(tx, studioId)
+			studio.OwnerId = user.Id
+			vbolt.Write(tx, StudiosBkt, studio.Id, &studio)
+		}
+		vbolt.TxCommit(tx)
+	})
+	return user
+}
+
+// createTestRoom adds another room to a studio
+func createTestRoom(tx *vbolt.Tx, studioId int, roomNumber int) Room {
+	room := Room{
+		Id:         vbolt.NextIntId(tx, RoomsBkt),
+		StudioId:   studioId,
+		RoomNumber: roomNumber,
+		Name:       fmt.Sprintf("Test Room %d", roomNumber),
+		StreamKey:  fmt.Sprintf("test-key-%d", roomNumber),
+		Creation:   time.Now(),
+	}
+	vbolt.Write(tx, RoomsBkt, room.Id, &room)
+	vbolt.SetTargetSingleTerm(tx, RoomsByStudioIdx, room.Id, studioId)
+	return room
+}
+
+// createTestGuestToken redeems a new code for the room and returns the code
+// session's token
+func createTestGuestToken(t *testing.T, db *vbolt.DB, adminToken string, roomId int) string {
+	t.Helper()
+	var generated GenerateAccessCodeResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		generated, err = GenerateAccessCode(&vbeam.Context{Tx: tx, Token: adminToken}, GenerateAccessCodeRequest{
+			Type:            int(CodeTypeRoom),
+			TargetId:        roomId,
+			DurationMinutes: 60,
+		})
+	})
+	if err != nil {
+		t.Fatalf("GenerateAccessCode failed: %v", err)
+	}
+	validated, err := validateAccessCodeLogic(db, generated.Code)
+	if err != nil {
+		t.Fatalf("validateAccessCodeLogic failed: %v", err)
+	}
+	return signTestGuestToken(t, validated)
+}
+
+// signTestGuestToken signs the JWT a viewer gets for a redeemed code
+func signTestGuestToken(t *testing.T, validated ValidateAccessCodeResponse) string {
+	t.Helper()
+	guestToken, err := signJwt(&Claims{
+		UserId:           -1,
+		SessionToken:     validated.SessionToken,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(validated.ExpiresAt)},
+	})
+	if err != nil {
+		t.Fatalf("signJwt failed: %v", err)
+	}
+	return guestToken
+}
@@ -77,10 +77,10 @@ func StartIngestHandler(db *vbolt.DB) http.HandlerFunc {
 			return
 		}
 
-		// Check permissions - require Admin or higher on studio
+		// Check permissions - require camera control in the studio
 		var hasPermission bool
 		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
-			hasPermission = HasStudioPermission(tx, authCtx.User.Id, room.StudioId, StudioRoleAdmin)
+			hasPermission = HasStudioCapability(tx, authCtx.User.Id, room.StudioId, CapControlCameras)
 		})
 
 		if !hasPermission {
@@ -178,10 +178,10 @@ func StopIngestHandler(db *vbolt.DB) http.HandlerFunc {
 			return
 		}
 
-		// Check permissions - require Admin or higher on studio
+		// Check permissions - require camera control in the studio
 		var hasPermission bool
 		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
-			hasPermission = HasStudioPermission(tx, authCtx.User.Id, room.StudioId, StudioRoleAdmin)
+			hasPermission = HasStudioCapability(tx, authCtx.User.Id, room.StudioId, CapControlCameras)
 		})
 
 		if !hasPermission {
@@ -262,10 +262,10 @@ func IngestStatusHandler(db *vbolt.DB) http.HandlerFunc {
 			return
 		}
 
-		// Check permissions - require Viewer or higher on studio
+		// Check permissions - require studio view access
 		var hasPermission bool
 		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
-			hasPermission = HasStudioPermission(tx, authCtx.User.Id, room.StudioId, StudioRoleViewer)
+			hasPermission = HasStudioCapability(tx, authCtx.User.Id, room.StudioId, CapViewStudio)
 		})
 
 		if !hasPermission {
@@ -347,10 +347,10 @@ func StudioCameraStatusHandler(db *vbolt.DB) http.HandlerFunc {
 			return
 		}
 
-		// Check permissions - require Viewer or higher on studio
+		// Check permissions - require studio view access
 		var hasPermission bool
 		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
-			hasPermission = HasStudioPermission(tx, authCtx.User.Id, studioId, StudioRoleViewer)
+			hasPermission = HasStudioCapability(tx, authCtx.User.Id, studioId, CapViewStudio)
 		})
 
 		if !hasPermission {
@@ -0,0 +1,490 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"stream/cfg"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+// InviteLink is a signed, shareable link that redeems an access code in one click.
+// Either wraps an existing code or a code minted for the invite (direct room grant),
+// so the code's expiry, MaxViewers, device and schedule rules still apply.
+type InviteLink struct {
+	Id           int       `json:"id"`
+	StudioId     int       `json:"studioId"`
+	Code         string    `json:"code"`        // Access code redeemed by this link
+	Label        string    `json:"label"`       // Optional description (e.g., "Grandma")
+	SingleUse    bool      `json:"singleUse"`   // Only the first device to redeem can use the link
+	ExpiresAt    time.Time `json:"expiresAt"`   // Same as the code's expiry
+	IsRevoked    bool      `json:"isRevoked"`   // Link revoked (the code itself stays valid)
+	UsedByDevice string    `json:"-"`           // Device that claimed a single-use link
+	Redemptions  int       `json:"redemptions"` // Successful redemptions
+	LastUsedAt   time.Time `json:"lastUsedAt"`  // Most recent redemption
+	CreatedBy    int       `json:"createdBy"`   // User ID
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func PackInviteLink(self *InviteLink, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.String(&self.Code, buf)
+	vpack.String(&self.Label, buf)
+	vpack.Bool(&self.SingleUse, buf)
+	vpack.Time(&self.ExpiresAt, buf)
+	vpack.Bool(&self.IsRevoked, buf)
+	vpack.String(&self.UsedByDevice, buf)
+	vpack.Int(&self.Redemptions, buf)
+	vpack.Time(&self.LastUsedAt, buf)
+	vpack.Int(&self.CreatedBy, buf)
+	vpack.Time(&self.CreatedAt, buf)
+}
+
+// InviteLinksBkt: inviteId (int) -> InviteLink
+var InviteLinksBkt = vbolt.Bucket(&cfg.Info, "invite_links", vpack.FInt, PackInviteLink)
+
+// InvitesByStudioIdx: studioId (term) -> inviteId (target)
+var InvitesByStudioIdx = vbolt.Index(&cfg.Info, "invites_by_studio", vpack.FInt, vpack.FInt)
+
+// InvitesByCodeIdx: code (term) -> inviteId (target)
+// Used to clean up invites when their code is deleted
+var InvitesByCodeIdx = vbolt.Index(&cfg.Info, "invites_by_code", vpack.StringZ, vpack.FInt)
+
+// inviteClaims is the signed payload of an invite link
+type inviteClaims struct {
+	InviteId int `json:"inviteId"`
+	jwt.RegisteredClaims
+}
+
+// inviteSigningKey derives a separate key from jwtKey so invite tokens
+// can never be accepted as auth tokens (and vice versa)
+func inviteSigningKey() []byte {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte("invite-links"))
+	return mac.Sum(nil)
+}
+
+// signInviteToken returns the token embedded in an invite's URL.
+// It only depends on the invite ID and expiry, so the same link can be shown again later.
+func signInviteToken(invite InviteLink) (string, error) {
+	claims := &inviteClaims{
+		InviteId: invite.Id,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(invite.ExpiresAt),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(inviteSigningKey())
+}
+
+// parseInviteToken verifies an invite token and returns the invite ID
+func parseInviteToken(tokenString string) (int, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &inviteClaims{}, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return inviteSigningKey(), nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, errors.New("This invite link has expired")
+		}
+		return 0, errors.New("Invalid invite link")
+	}
+
+	claims, ok := token.Claims.(*inviteClaims)
+	if !ok || !token.Valid || claims.InviteId <= 0 {
+		return 0, errors.New("Invalid invite link")
+	}
+	return claims.InviteId, nil
+}
+
+func inviteURL(invite InviteLink) (string, error) {
+	token, err := signInviteToken(invite)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/invite/%s", cfg.SiteURL, token), nil
+}
+
+// claimInviteTx checks an invite can be redeemed from this device and records the redemption.
+// claimed is true if this call bound a single-use invite to the device.
+func claimInviteTx(tx *vbolt.Tx, inviteId int, deviceId string, now time.Time) (invite InviteLink, claimed bool, err error) {
+	vbolt.Read(tx, InviteLinksBkt, inviteId, &invite)
+	if invite.Id == 0 {
+		return invite, false, errors.New("Invalid invite link")
+	}
+	if invite.IsRevoked {
+		return invite, false, errors.New("This invite link has been revoked")
+	}
+	if now.After(invite.ExpiresAt) {
+		return invite, false, errors.New("This invite link has expired")
+	}
+
+	if invite.SingleUse {
+		if deviceId == "" {
+			return invite, false, errors.New("This invite link can only be opened in a browser")
+		}
+		if invite.UsedByDevice != "" && invite.UsedByDevice != deviceId {
+			return invite, false, errors.New("This invite link has already been used")
+		}
+		if invite.UsedByDevice == "" {
+			invite.UsedByDevice = deviceId
+			claimed = true
+		}
+	}
+
+	invite.Redemptions++
+	invite.LastUsedAt = now
+	vbolt.Write(tx, InviteLinksBkt, invite.Id, &invite)
+	return invite, claimed, nil
+}
+
+// releaseInviteTx undoes a redemption recorded by claimInviteTx when the code itself rejected the viewer
+func releaseInviteTx(tx *vbolt.Tx, inviteId int, claimed bool) {
+	var invite InviteLink
+	vbolt.Read(tx, InviteLinksBkt, inviteId, &invite)
+	if invite.Id == 0 {
+		return
+	}
+	if invite.Redemptions > 0 {
+		invite.Redemptions--
+	}
+	if claimed {
+		invite.UsedByDevice = ""
+	}
+	vbolt.Write(tx, InviteLinksBkt, invite.Id, &invite)
+}
+
+// deleteCodeInvitesTx removes all invite links for a code (called when the code is deleted)
+func deleteCodeInvitesTx(tx *vbolt.Tx, code string) {
+	var inviteIds []int
+	vbolt.ReadTermTargets(tx, InvitesByCodeIdx, code, &inviteIds, vbolt.Window{})
+	for _, inviteId := range inviteIds {
+		vbolt.Delete(tx, InviteLinksBkt, inviteId)
+		vbolt.SetTargetSingleTerm(tx, InvitesByCodeIdx, inviteId, "")
+		vbolt.SetTargetSingleTerm(tx, InvitesByStudioIdx, inviteId, -1)
+	}
+}
+
+// API Request/Response types
+
+type CreateInviteLinkRequest struct {
+	Code            string `json:"code"`            // Existing code to share ("" to mint a room code)
+	RoomId          int    `json:"roomId"`          // Direct room grant (when Code is empty)
+	DurationMinutes int    `json:"durationMinutes"` // Direct room grant: -1 = never expires
+	MaxViewers      int    `json:"maxViewers"`      // Direct room grant: 0 = unlimited
+	SingleUse       bool   `json:"singleUse"`
+	Label           string `json:"label"`
+}
+
+type CreateInviteLinkResponse struct {
+	Invite InviteLink `json:"invite"`
+	URL    string     `json:"url"`
+}
+
+type ListInviteLinksRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type InviteLinkItem struct {
+	Invite InviteLink `json:"invite"`
+	URL    string     `json:"url"`
+	IsUsed bool       `json:"isUsed"` // Single-use link already claimed by a device
+}
+
+type ListInviteLinksResponse struct {
+	Invites []InviteLinkItem `json:"invites"`
+}
+
+type RevokeInviteLinkRequest struct {
+	InviteId int `json:"inviteId"`
+}
+
+type RevokeInviteLinkResponse struct {
+	Success bool `json:"success"`
+}
+
+type RedeemInviteRequest struct {
+	Token string `json:"token"`
+}
+
+// RegisterInviteMethods registers invite link procedures and the redemption handler
+func RegisterInviteMethods(app *vbeam.Application) {
+	// Redemption needs to set cookies like code validation
+	app.HandleFunc("/api/redeem-invite", redeemInviteHandler)
+
+	vbeam.RegisterProc(app, CreateInviteLink)
+	vbeam.RegisterProc(app, ListInviteLinks)
+	vbeam.RegisterProc(app, RevokeInviteLink)
+}
+
+// CreateInviteLink creates a one-click invite for an existing code, or mints a room code for it
+func CreateInviteLink(ctx *vbeam.Context, req CreateInviteLinkRequest) (resp CreateInviteLinkResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckCodeGeneration(caller.Id); rateLimitErr != nil {
+		return resp, errors.New(rateLimitErr.Error())
+	}
+
+	if len(req.Label) > 200 {
+		return resp, errors.New("Label is too long (max 200 characters)")
+	}
+
+	now := time.Now()
+	var accessCode AccessCode
+	var studioId int
+
+	if req.Code != "" {
+		// Share an existing code
+		vbolt.Read(ctx.Tx, AccessCodesBkt, req.Code, &accessCode)
+		if accessCode.Code == "" {
+			return resp, errors.New("Access code not found")
+		}
+		studioId = codeStudioId(ctx.Tx, accessCode)
+		if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapManageCodes) {
+			return resp, errors.New("Only studio admins can create invite links")
+		}
+		if accessCode.IsRevoked {
+			return resp, errors.New("Access code has been revoked")
+		}
+		if now.After(accessCode.ExpiresAt) {
+			return resp, errors.New("Access code has expired")
+		}
+	} else {
+		// Direct room grant
+		room := GetRoom(ctx.Tx, req.RoomId)
+		if room.Id == 0 {
+			return resp, errors.New("Room not found")
+		}
+		studioId = room.StudioId
+		if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapManageCodes) {
+			return resp, errors.New("Only studio admins can create invite links")
+		}
+		if req.DurationMinutes <= 0 && req.DurationMinutes != -1 {
+			return resp, errors.New("Duration value invalid")
+		}
+		if req.MaxViewers < 0 {
+			return resp, errors.New("Max viewers cannot be negative")
+		}
+	}
+
+	vbeam.UseWriteTx(ctx)
+
+	if req.Code == "" {
+		expiresAt := now.AddDate(100, 0, 0) // "Never expires"
+		if req.DurationMinutes != -1 {
+			expiresAt = now.Add(time.Duration(req.DurationMinutes) * time.Minute)
+		}
+
+		template := AccessCode{
+			Type:       CodeTypeRoom,
+			TargetId:   req.RoomId,
+			CreatedBy:  caller.Id,
+			ExpiresAt:  expiresAt,
+			MaxViewers: req.MaxViewers,
+			Label:      req.Label,
+		}
+		if req.SingleUse {
+			// Single-use grants are also bound to the redeeming device
+			template.MaxDevices = 1
+		}
+
+		accessCode, err = createAccessCodeTx(ctx.Tx, template)
+		if err != nil {
+			return
+		}
+	}
+
+	invite := InviteLink{
+		Id:        vbolt.NextIntId(ctx.Tx, InviteLinksBkt),
+		StudioId:  studioId,
+		Code:      accessCode.Code,
+		Label:     req.Label,
+		SingleUse: req.SingleUse,
+		ExpiresAt: accessCode.ExpiresAt,
+		CreatedBy: caller.Id,
+		CreatedAt: now,
+	}
+
+	url, signErr := inviteURL(invite)
+	if signErr != nil {
+		return resp, errors.New("Failed to sign invite link")
+	}
+
+	vbolt.Write(ctx.Tx, InviteLinksBkt, invite.Id, &invite)
+	vbolt.SetTargetSingleTerm(ctx.Tx, InvitesByStudioIdx, invite.Id, studioId)
+	vbolt.SetTargetSingleTerm(ctx.Tx, InvitesByCodeIdx, invite.Id, invite.Code)
+
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Invite link created", map[string]interface{}{
+		"inviteId":  invite.Id,
+		"code":      invite.Code,
+		"studioId":  studioId,
+		"singleUse": invite.SingleUse,
+		"createdBy": caller.Id,
+	})
+
+	resp.Invite = invite
+	resp.URL = url
+	return
+}
+
+// ListInviteLinks returns a studio's invite links, newest first
+func ListInviteLinks(ctx *vbeam.Context, req ListInviteLinksRequest) (resp ListInviteLinksResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, req.StudioId, CapManageCodes) {
+		return resp, errors.New("Admin permission required")
+	}
+
+	var inviteIds []int
+	vbolt.ReadTermTargets(ctx.Tx, InvitesByStudioIdx, req.StudioId, &inviteIds, vbolt.Window{})
+
+	resp.Invites = make([]InviteLinkItem, 0, len(inviteIds))
+	for i := len(inviteIds) - 1; i >= 0; i-- {
+		var invite InviteLink
+		vbolt.Read(ctx.Tx, InviteLinksBkt, inviteIds[i], &invite)
+		if invite.Id == 0 {
+			continue
+		}
+
+		url, _ := inviteURL(invite)
+		resp.Invites = append(resp.Invites, InviteLinkItem{
+			Invite: invite,
+			URL:    url,
+			IsUsed: invite.SingleUse && invite.UsedByDevice != "",
+		})
+	}
+	return
+}
+
+// RevokeInviteLink disables an invite link without revoking its code
+func RevokeInviteLink(ctx *vbeam.Context, req RevokeInviteLinkRequest) (resp RevokeInviteLinkResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	var invite InviteLink
+	vbolt.Read(ctx.Tx, InviteLinksBkt, req.InviteId, &invite)
+	if invite.Id == 0 {
+		return resp, errors.New("Invite link not found")
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, invite.StudioId, CapManageCodes) {
+		return resp, errors.New("Admin permission required")
+	}
+
+	if invite.IsRevoked {
+		return resp, errors.New("Invite link is already revoked")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	invite.IsRevoked = true
+	vbolt.Write(ctx.Tx, InviteLinksBkt, invite.Id, &invite)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Invite link revoked", map[string]interface{}{
+		"inviteId":  invite.Id,
+		"code":      invite.Code,
+		"revokedBy": caller.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+// redeemInviteHandler redeems an invite link and starts a code session, like validateAccessCodeHandler.
+// Uses its own rate limit without backoff: tokens are signed, so they can't be guessed.
+func redeemInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		vbeam.RespondError(w, errors.New("redeem-invite must be POST"))
+		return
+	}
+
+	var req RedeemInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		vbeam.RespondError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	clientIP := getClientIP(r)
+	if err := globalRateLimiter.CheckInviteRedemption(clientIP); err != nil {
+		LogWarnWithRequest(r, LogCategorySystem, "Rate limit exceeded for invite redemption", map[string]interface{}{
+			"clientIP": clientIP,
+		})
+		vbeam.RespondError(w, err)
+		return
+	}
+
+	inviteId, err := parseInviteToken(req.Token)
+	if err != nil {
+		vbeam.RespondError(w, err)
+		return
+	}
+
+	device := CodeDeviceInfo{
+		DeviceId:  getOrSetDeviceId(w, r),
+		ClientIP:  clientIP,
+		UserAgent: r.UserAgent(),
+	}
+
+	var invite InviteLink
+	var claimed bool
+	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+		invite, claimed, err = claimInviteTx(tx, inviteId, device.DeviceId, time.Now())
+		if err == nil {
+			vbolt.TxCommit(tx)
+		}
+	})
+	if err != nil {
+		vbeam.RespondError(w, err)
+		return
+	}
+
+	// The code's own rules (expiry, MaxViewers, devices, class window) still apply
+	resp, err := validateAccessCodeLogicForDevice(appDb, invite.Code, device)
+	if err != nil {
+		vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+			releaseInviteTx(tx, invite.Id, claimed)
+			vbolt.TxCommit(tx)
+		})
+		vbeam.RespondError(w, err)
+		return
+	}
+
+	if err = attachCodeSession(w, r, invite.Code, resp); err != nil {
+		vbeam.RespondError(w, err)
+		return
+	}
+
+	LogInfoWithRequest(r, LogCategoryAuth, "Invite link redeemed", map[string]interface{}{
+		"inviteId":     invite.Id,
+		"code":         invite.Code,
+		"sessionToken": resp.SessionToken,
+	})
+
+	json.NewEncoder(w).Encode(resp)
+}
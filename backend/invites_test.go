@@ -0,0 +1,229 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func createTestInvite(t *testing.T, db *vbolt.DB, adminId int, req CreateInviteLinkRequest) CreateInviteLinkResponse {
+	token, err := createTestToken(adminId)
+	if err != nil {
+		t.Fatalf("Failed to create test token: %v", err)
+	}
+
+	var resp CreateInviteLinkResponse
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: token}
+		resp, err = CreateInviteLink(ctx, req)
+	})
+	if err != nil {
+		t.Fatalf("CreateInviteLink failed: %v", err)
+	}
+	return resp
+}
+
+// inviteTokenFromURL extracts the signed token from an invite URL
+func inviteTokenFromURL(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+// postTestRedeemInvite redeems an invite token, optionally with a device cookie
+func postTestRedeemInvite(token string, deviceCookie *http.Cookie) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(RedeemInviteRequest{Token: token})
+	req := httptest.NewRequest("POST", "/api/redeem-invite", bytes.NewReader(body))
+	if deviceCookie != nil {
+		req.AddCookie(deviceCookie)
+	}
+	w := httptest.NewRecorder()
+	redeemInviteHandler(w, req)
+	return w
+}
+
+func findTestCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestPackInviteLink(t *testing.T) {
+	db := setupTestCodeDB(t)
+	defer db.Close()
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		original := InviteLink{
+			Id:           1,
+			StudioId:     2,
+			Code:         "12345",
+			Label:        "Grandma",
+			SingleUse:    true,
+			UsedByDevice: "device-a",
+			Redemptions:  3,
+		}
+		vbolt.Write(tx, InviteLinksBkt, original.Id, &original)
+
+		var retrieved InviteLink
+		vbolt.Read(tx, InviteLinksBkt, original.Id, &retrieved)
+		if retrieved.Code != original.Code || retrieved.Label != original.Label {
+			t.Errorf("Invite mismatch: got %+v", retrieved)
+		}
+		if !retrieved.SingleUse || retrieved.UsedByDevice != "device-a" || retrieved.Redemptions != 3 {
+			t.Errorf("Invite usage mismatch: got %+v", retrieved)
+		}
+	})
+}
+
+func TestInviteLinkRedemption(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	accessCode := createTestDeviceBoundCode(t, db, room.Id, 0)
+
+	resp := createTestInvite(t, db, admin.Id, CreateInviteLinkRequest{Code: accessCode.Code, Label: "Family"})
+	if resp.Invite.Code != accessCode.Code {
+		t.Fatalf("Expected invite for code %s, got %s", accessCode.Code, resp.Invite.Code)
+	}
+	if !strings.Contains(resp.URL, "/invite/") {
+		t.Fatalf("Expected invite URL, got %s", resp.URL)
+	}
+	token := inviteTokenFromURL(resp.URL)
+
+	w := postTestRedeemInvite(token, nil)
+	var validateResp ValidateAccessCodeResponse
+	json.Unmarshal(w.Body.Bytes(), &validateResp)
+	if validateResp.SessionToken == "" || validateResp.RedirectTo == "" {
+		t.Fatalf("Expected redemption to start a session, got %s", w.Body.String())
+	}
+	if findTestCookie(w, "authToken") == nil {
+		t.Errorf("Expected authToken cookie to be set")
+	}
+
+	// Tampered and auth tokens are rejected
+	tampered := postTestRedeemInvite(token[:len(token)-2]+"xx", nil)
+	if !strings.Contains(tampered.Body.String(), "Invalid invite link") {
+		t.Errorf("Expected tampered token to be rejected, got %s", tampered.Body.String())
+	}
+	authToken, _ := createTestToken(admin.Id)
+	if w := postTestRedeemInvite(authToken, nil); !strings.Contains(w.Body.String(), "Invalid invite link") {
+		t.Errorf("Expected auth token to be rejected as invite, got %s", w.Body.String())
+	}
+
+	// Revoked invites stop working
+	adminToken, _ := createTestToken(admin.Id)
+	var err error
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: adminToken}
+		_, err = RevokeInviteLink(ctx, RevokeInviteLinkRequest{InviteId: resp.Invite.Id})
+	})
+	if err != nil {
+		t.Fatalf("RevokeInviteLink failed: %v", err)
+	}
+	if w := postTestRedeemInvite(token, nil); !strings.Contains(w.Body.String(), "revoked") {
+		t.Errorf("Expected revoked invite to be rejected, got %s", w.Body.String())
+	}
+}
+
+func TestSingleUseInviteLink(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	admin, studio, room := createTestTicketAdmin(t, db)
+
+	// Direct room grant, single-use
+	resp := createTestInvite(t, db, admin.Id, CreateInviteLinkRequest{
+		RoomId:          room.Id,
+		DurationMinutes: 60,
+		MaxViewers:      2,
+		SingleUse:       true,
+		Label:           "Grandpa",
+	})
+	token := inviteTokenFromURL(resp.URL)
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		var accessCode AccessCode
+		vbolt.Read(tx, AccessCodesBkt, resp.Invite.Code, &accessCode)
+		if accessCode.Type != CodeTypeRoom || accessCode.TargetId != room.Id {
+			t.Errorf("Expected room code for room %d, got %+v", room.Id, accessCode)
+		}
+		if accessCode.MaxDevices != 1 || accessCode.MaxViewers != 2 {
+			t.Errorf("Expected single-use grant to bind 1 device with 2 viewers, got %+v", accessCode)
+		}
+	})
+
+	first := postTestRedeemInvite(token, nil)
+	deviceCookie := findTestCookie(first, deviceCookieName)
+	if deviceCookie == nil || !strings.Contains(first.Body.String(), "sessionToken") {
+		t.Fatalf("Expected first redemption to succeed, got %s", first.Body.String())
+	}
+
+	// Same device can come back, another device can't
+	if again := postTestRedeemInvite(token, deviceCookie); !strings.Contains(again.Body.String(), "sessionToken") {
+		t.Errorf("Expected same device to redeem again, got %s", again.Body.String())
+	}
+	if other := postTestRedeemInvite(token, nil); !strings.Contains(other.Body.String(), "already been used") {
+		t.Errorf("Expected other device to be rejected, got %s", other.Body.String())
+	}
+
+	// Admin sees the invite as used
+	adminToken, _ := createTestToken(admin.Id)
+	var listResp ListInviteLinksResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: adminToken}
+		listResp, err = ListInviteLinks(ctx, ListInviteLinksRequest{StudioId: studio.Id})
+	})
+	if err != nil {
+		t.Fatalf("ListInviteLinks failed: %v", err)
+	}
+	if len(listResp.Invites) != 1 || !listResp.Invites[0].IsUsed || listResp.Invites[0].Invite.Redemptions != 2 {
+		t.Errorf("Expected 1 used invite with 2 redemptions, got %+v", listResp.Invites)
+	}
+	if listResp.Invites[0].URL != resp.URL {
+		t.Errorf("Expected listed URL to match created URL")
+	}
+}
+
+func TestSingleUseInviteNotClaimedWhenCodeRejects(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	accessCode := createTestDeviceBoundCode(t, db, room.Id, 0)
+	resp := createTestInvite(t, db, admin.Id, CreateInviteLinkRequest{Code: accessCode.Code, SingleUse: true})
+	token := inviteTokenFromURL(resp.URL)
+
+	// Stream at capacity: code rejects the viewer
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		code := accessCode
+		code.MaxViewers = 1
+		vbolt.Write(tx, AccessCodesBkt, code.Code, &code)
+		analytics := CodeAnalytics{Code: code.Code, CurrentViewers: 1}
+		vbolt.Write(tx, CodeAnalyticsBkt, code.Code, &analytics)
+		vbolt.TxCommit(tx)
+	})
+
+	if w := postTestRedeemInvite(token, nil); !strings.Contains(w.Body.String(), "capacity") {
+		t.Fatalf("Expected capacity error, got %s", w.Body.String())
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		var invite InviteLink
+		vbolt.Read(tx, InviteLinksBkt, resp.Invite.Id, &invite)
+		if invite.UsedByDevice != "" || invite.Redemptions != 0 {
+			t.Errorf("Expected failed redemption not to claim the invite, got %+v", invite)
+		}
+	})
+}
@@ -0,0 +1,434 @@
+package backend
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"stream/cfg"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const (
+	JWT_ALG_HS256 = "HS256"
+	JWT_ALG_EDDSA = "EdDSA"
+	JWT_ALG_RS256 = "RS256"
+
+	// Retired keys keep verifying tokens this long before they're pruned. Auth
+	// JWTs live 24h; anonymous code sessions older than this re-enter their code.
+	JWT_KEY_RETENTION = 30 * 24 * time.Hour
+
+	JWT_KEY_ROTATION_CHECK_INTERVAL = time.Hour
+)
+
+// SigningKey is one key in the JWT keyring. Tokens name the key that signed
+// them in the "kid" header; exactly one key is active (RetiredAt is zero) and
+// signs new tokens, retired keys only verify.
+type SigningKey struct {
+	Kid       string    `json:"kid"`
+	Algorithm string    `json:"algorithm"`
+	Secret    []byte    `json:"-"` // HMAC secret, or PKCS#8 private key for EdDSA/RS256
+	CreatedAt time.Time `json:"createdAt"`
+	RetiredAt time.Time `json:"retiredAt"`
+}
+
+func PackSigningKey(self *SigningKey, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.String(&self.Kid, buf)
+	vpack.String(&self.Algorithm, buf)
+	vpack.ByteSlice(&self.Secret, buf)
+	vpack.Time(&self.CreatedAt, buf)
+	vpack.Time(&self.RetiredAt, buf)
+}
+
+// kid => signing key
+var SigningKeysBkt = vbolt.Bucket(&cfg.Info, "jwt_signing_keys", vpack.StringZ, PackSigningKey)
+
+// Signing configuration, from JWT_SIGNING_ALG and JWT_KEY_ROTATION_DAYS
+var jwtSigningAlg = JWT_ALG_HS256
+var jwtKeyRotationInterval time.Duration // 0 = only rotate on demand
+
+type jwtRingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// jwtKeyring is the in-memory copy of SigningKeysBkt used to sign and verify
+// tokens. While it's empty, tokens are signed and verified with the legacy
+// jwtKey (no kid), which is what tests and fresh setups rely on.
+type jwtKeyring struct {
+	active      *jwtRingKey
+	keys        map[string]jwtRingKey
+	legacyUntil time.Time // kid-less tokens are accepted until then (zero = always)
+}
+
+var jwtKeys jwtKeyring
+var jwtKeysMu sync.RWMutex
+
+// SetupJWTKeys reads the signing configuration and loads the keyring, creating
+// a key when there is none yet or the configured algorithm changed
+func SetupJWTKeys(db *vbolt.DB) error {
+	if alg := os.Getenv("JWT_SIGNING_ALG"); alg != "" {
+		if !validJwtAlgorithm(alg) {
+			return fmt.Errorf("unsupported JWT_SIGNING_ALG %q", alg)
+		}
+		jwtSigningAlg = alg
+	}
+	if days := os.Getenv("JWT_KEY_ROTATION_DAYS"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid JWT_KEY_ROTATION_DAYS %q", days)
+		}
+		jwtKeyRotationInterval = time.Duration(n) * 24 * time.Hour
+	}
+
+	return refreshJWTKeys(db)
+}
+
+// StartJWTKeyRotation starts a background job that rotates the signing key
+// once it's older than JWT_KEY_ROTATION_DAYS. It also reloads the keyring, so
+// a rotation made elsewhere is picked up.
+func StartJWTKeyRotation(db *vbolt.DB) {
+	LogInfo(LogCategorySystem, "Starting JWT key rotation job", map[string]interface{}{
+		"interval": JWT_KEY_ROTATION_CHECK_INTERVAL.String(),
+	})
+
+	go func() {
+		ticker := time.NewTicker(JWT_KEY_ROTATION_CHECK_INTERVAL)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := refreshJWTKeys(db); err != nil {
+				LogErrorSimple(LogCategorySystem, "JWT key rotation failed", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
+	}()
+}
+
+// refreshJWTKeys loads the keyring from the database, rotating first if
+// there's no active key in the configured algorithm or it's past the rotation
+// interval
+func refreshJWTKeys(db *vbolt.DB) (err error) {
+	var active SigningKey
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		active = activeSigningKeyTx(tx)
+	})
+
+	now := time.Now()
+	due := active.Kid == "" || active.Algorithm != jwtSigningAlg ||
+		(jwtKeyRotationInterval > 0 && now.Sub(active.CreatedAt) >= jwtKeyRotationInterval)
+	if !due {
+		var ring jwtKeyring
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			ring, err = loadJWTKeyringTx(tx)
+		})
+		if err == nil {
+			setJWTKeyring(ring)
+		}
+		return
+	}
+
+	key, err := generateSigningKey(jwtSigningAlg, now)
+	if err != nil {
+		return
+	}
+	var ring jwtKeyring
+	var pruned int
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		pruned = rotateSigningKeyTx(tx, key, now)
+		ring, err = loadJWTKeyringTx(tx)
+		if err == nil {
+			vbolt.TxCommit(tx)
+		}
+	})
+	if err != nil {
+		return
+	}
+	setJWTKeyring(ring)
+
+	LogInfo(LogCategoryAuth, "JWT signing key rotated", map[string]interface{}{
+		"kid":       key.Kid,
+		"algorithm": key.Algorithm,
+		"pruned":    pruned,
+	})
+	return
+}
+
+func validJwtAlgorithm(alg string) bool {
+	return alg == JWT_ALG_HS256 || alg == JWT_ALG_EDDSA || alg == JWT_ALG_RS256
+}
+
+// generateSigningKey creates a new active key for the given algorithm
+func generateSigningKey(alg string, now time.Time) (key SigningKey, err error) {
+	key.Kid, err = generateToken(8)
+	if err != nil {
+		return
+	}
+	key.Algorithm = alg
+	key.CreatedAt = now
+
+	switch alg {
+	case JWT_ALG_HS256:
+		key.Secret = make([]byte, 32)
+		_, err = rand.Read(key.Secret)
+	case JWT_ALG_EDDSA:
+		var priv ed25519.PrivateKey
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+		if err == nil {
+			key.Secret, err = x509.MarshalPKCS8PrivateKey(priv)
+		}
+	case JWT_ALG_RS256:
+		var priv *rsa.PrivateKey
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+		if err == nil {
+			key.Secret, err = x509.MarshalPKCS8PrivateKey(priv)
+		}
+	default:
+		err = fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	return
+}
+
+// parseSigningKey turns a stored key into the values jwt needs to sign and verify
+func parseSigningKey(key SigningKey) (ringKey jwtRingKey, err error) {
+	ringKey.kid = key.Kid
+	switch key.Algorithm {
+	case JWT_ALG_HS256:
+		ringKey.method = jwt.SigningMethodHS256
+		ringKey.signKey = key.Secret
+		ringKey.verifyKey = key.Secret
+		return
+	case JWT_ALG_EDDSA, JWT_ALG_RS256:
+	default:
+		return ringKey, fmt.Errorf("unsupported signing algorithm %q", key.Algorithm)
+	}
+
+	priv, err := x509.ParsePKCS8PrivateKey(key.Secret)
+	if err != nil {
+		return
+	}
+	switch priv := priv.(type) {
+	case ed25519.PrivateKey:
+		if key.Algorithm == JWT_ALG_EDDSA {
+			ringKey.method = jwt.SigningMethodEdDSA
+			ringKey.signKey = priv
+			ringKey.verifyKey = priv.Public()
+			return
+		}
+	case *rsa.PrivateKey:
+		if key.Algorithm == JWT_ALG_RS256 {
+			ringKey.method = jwt.SigningMethodRS256
+			ringKey.signKey = priv
+			ringKey.verifyKey = &priv.PublicKey
+			return
+		}
+	}
+	return ringKey, fmt.Errorf("key %s doesn't match algorithm %s", key.Kid, key.Algorithm)
+}
+
+func activeSigningKeyTx(tx *vbolt.Tx) (active SigningKey) {
+	vbolt.IterateAll(tx, SigningKeysBkt, func(kid string, key SigningKey) bool {
+		if key.RetiredAt.IsZero() {
+			active = key
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// rotateSigningKeyTx makes key the active key, retires the previous one and
+// deletes keys retired longer than JWT_KEY_RETENTION ago
+func rotateSigningKeyTx(tx *vbolt.Tx, key SigningKey, now time.Time) (pruned int) {
+	var keys []SigningKey
+	vbolt.IterateAll(tx, SigningKeysBkt, func(kid string, existing SigningKey) bool {
+		keys = append(keys, existing)
+		return true
+	})
+
+	for _, existing := range keys {
+		if existing.RetiredAt.IsZero() {
+			existing.RetiredAt = now
+			vbolt.Write(tx, SigningKeysBkt, existing.Kid, &existing)
+		} else if now.Sub(existing.RetiredAt) > JWT_KEY_RETENTION {
+			vbolt.Delete(tx, SigningKeysBkt, existing.Kid)
+			pruned++
+		}
+	}
+
+	vbolt.Write(tx, SigningKeysBkt, key.Kid, &key)
+	return
+}
+
+// loadJWTKeyringTx builds the in-memory keyring from SigningKeysBkt
+func loadJWTKeyringTx(tx *vbolt.Tx) (ring jwtKeyring, err error) {
+	ring.keys = make(map[string]jwtRingKey)
+	var oldest time.Time
+	vbolt.IterateAll(tx, SigningKeysBkt, func(kid string, key SigningKey) bool {
+		ringKey, parseErr := parseSigningKey(key)
+		if parseErr != nil {
+			err = parseErr
+			return false
+		}
+		ring.keys[kid] = ringKey
+		if key.RetiredAt.IsZero() {
+			active := ringKey
+			ring.active = &active
+		}
+		if oldest.IsZero() || key.CreatedAt.Before(oldest) {
+			oldest = key.CreatedAt
+		}
+		return true
+	})
+
+	// Tokens signed before the keyring existed are honoured like a retired key
+	if !oldest.IsZero() {
+		ring.legacyUntil = oldest.Add(JWT_KEY_RETENTION)
+	}
+	return
+}
+
+func setJWTKeyring(ring jwtKeyring) {
+	jwtKeysMu.Lock()
+	jwtKeys = ring
+	jwtKeysMu.Unlock()
+}
+
+// signJwt signs claims with the active key, naming it in the kid header
+func signJwt(claims jwt.Claims) (string, error) {
+	jwtKeysMu.RLock()
+	active := jwtKeys.active
+	jwtKeysMu.RUnlock()
+
+	if active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+	}
+	token := jwt.NewWithClaims(active.method, claims)
+	token.Header["kid"] = active.kid
+	return token.SignedString(active.signKey)
+}
+
+// jwtKeyFunc is the jwt.Keyfunc for every token we issue: it picks the
+// verification key named by kid, or the legacy jwtKey for kid-less tokens
+func jwtKeyFunc(token *jwt.Token) (any, error) {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		if !jwtKeys.legacyUntil.IsZero() && time.Now().After(jwtKeys.legacyUntil) {
+			return nil, errors.New("legacy signing key retired")
+		}
+		return jwtKey, nil
+	}
+
+	key, ok := jwtKeys.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verifyKey, nil
+}
+
+func RegisterJWTKeyMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, ListSigningKeys)
+	vbeam.RegisterProc(app, RotateSigningKeys)
+}
+
+// Request/Response types
+type ListSigningKeysResponse struct {
+	Keys         []SigningKey `json:"keys"` // newest first
+	Algorithm    string       `json:"algorithm"`
+	RotationDays int          `json:"rotationDays"` // 0 = manual rotation only
+}
+
+type RotateSigningKeysResponse struct {
+	Kid    string `json:"kid"`
+	Pruned int    `json:"pruned"`
+}
+
+// ListSigningKeys shows the JWT keyring. Only accessible by site admins.
+func ListSigningKeys(ctx *vbeam.Context, req Empty) (resp ListSigningKeysResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+	if caller.Role != RoleSiteAdmin {
+		return resp, errors.New("Only site admins can manage signing keys")
+	}
+
+	resp.Keys = []SigningKey{}
+	vbolt.IterateAll(ctx.Tx, SigningKeysBkt, func(kid string, key SigningKey) bool {
+		resp.Keys = append(resp.Keys, key)
+		return true
+	})
+	sort.Slice(resp.Keys, func(i, j int) bool {
+		return resp.Keys[i].CreatedAt.After(resp.Keys[j].CreatedAt)
+	})
+	resp.Algorithm = jwtSigningAlg
+	resp.RotationDays = int(jwtKeyRotationInterval / (24 * time.Hour))
+	return
+}
+
+// RotateSigningKeys starts signing with a new key right away. Tokens signed by
+// the previous key stay valid until they expire. Only accessible by site admins.
+func RotateSigningKeys(ctx *vbeam.Context, req Empty) (resp RotateSigningKeysResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+	if caller.Role != RoleSiteAdmin {
+		return resp, errors.New("Only site admins can manage signing keys")
+	}
+
+	now := time.Now()
+	key, keyErr := generateSigningKey(jwtSigningAlg, now)
+	if keyErr != nil {
+		LogErrorSimple(LogCategorySystem, "Failed to generate JWT signing key", map[string]interface{}{
+			"error": keyErr.Error(),
+		})
+		return resp, errors.New("Failed to generate signing key")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	resp.Pruned = rotateSigningKeyTx(ctx.Tx, key, now)
+	ring, ringErr := loadJWTKeyringTx(ctx.Tx)
+	vbolt.TxCommit(ctx.Tx)
+	if ringErr != nil {
+		LogErrorSimple(LogCategorySystem, "Failed to reload JWT keyring", map[string]interface{}{
+			"error": ringErr.Error(),
+		})
+	} else {
+		setJWTKeyring(ring)
+	}
+
+	LogInfo(LogCategoryAuth, "JWT signing key rotated", map[string]interface{}{
+		"kid":       key.Kid,
+		"algorithm": key.Algorithm,
+		"pruned":    resp.Pruned,
+		"rotatedBy": caller.Id,
+	})
+
+	resp.Kid = key.Kid
+	return
+}
@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+// useTestJWTKeyring restores the legacy-only keyring once the test is done
+func useTestJWTKeyring(t *testing.T, alg string) {
+	originalAlg := jwtSigningAlg
+	jwtSigningAlg = alg
+	t.Cleanup(func() {
+		jwtSigningAlg = originalAlg
+		setJWTKeyring(jwtKeyring{})
+	})
+}
+
+func signTestClaims(t *testing.T, userId int) string {
+	token, err := signJwt(&Claims{
+		UserId: userId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("signJwt failed: %v", err)
+	}
+	return token
+}
+
+func testTokenKid(t *testing.T, tokenString string) (kid string, alg string) {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
+	if err != nil {
+		t.Fatalf("Failed to decode token: %v", err)
+	}
+	kid, _ = token.Header["kid"].(string)
+	return kid, token.Method.Alg()
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+	useTestJWTKeyring(t, JWT_ALG_EDDSA)
+
+	user := createTestInvitee(t, db, "admin@test.com", true)
+	user.Role = RoleSiteAdmin
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		vbolt.Write(tx, UsersBkt, user.Id, &user)
+		vbolt.TxCommit(tx)
+	})
+	legacyToken, _ := createTestToken(user.Id)
+
+	if err := refreshJWTKeys(db); err != nil {
+		t.Fatalf("refreshJWTKeys failed: %v", err)
+	}
+	firstToken := signTestClaims(t, user.Id)
+	firstKid, alg := testTokenKid(t, firstToken)
+	if firstKid == "" || alg != JWT_ALG_EDDSA {
+		t.Fatalf("Expected an EdDSA token with a kid, got %q %s", firstKid, alg)
+	}
+
+	// Tokens from before the keyring keep working
+	authOk := func(token string) bool {
+		var err error
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err = GetAuthUser(&vbeam.Context{Tx: tx, Token: token})
+		})
+		return err == nil
+	}
+	if !authOk(legacyToken) || !authOk(firstToken) {
+		t.Fatalf("Expected legacy and keyring tokens to verify")
+	}
+
+	// A token naming the EdDSA key but signed with the HMAC secret is rejected
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserId: user.Id})
+	forged.Header["kid"] = firstKid
+	forgedString, _ := forged.SignedString(jwtKey)
+	if authOk(forgedString) {
+		t.Errorf("Expected algorithm mismatch to be rejected")
+	}
+
+	// Rotating doesn't log anyone out
+	var rotated RotateSigningKeysResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		rotated, err = RotateSigningKeys(&vbeam.Context{Tx: tx, Token: firstToken}, Empty{})
+	})
+	if err != nil || rotated.Kid == firstKid {
+		t.Fatalf("RotateSigningKeys failed: %v %+v", err, rotated)
+	}
+	secondToken := signTestClaims(t, user.Id)
+	if kid, _ := testTokenKid(t, secondToken); kid != rotated.Kid {
+		t.Errorf("Expected new tokens to use the rotated key, got %q", kid)
+	}
+	if !authOk(firstToken) || !authOk(secondToken) {
+		t.Errorf("Expected tokens from both keys to verify during rotation")
+	}
+
+	var keys ListSigningKeysResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		keys, err = ListSigningKeys(&vbeam.Context{Tx: tx, Token: secondToken}, Empty{})
+	})
+	if err != nil || len(keys.Keys) != 2 || keys.Keys[0].Kid != rotated.Kid || keys.Keys[1].RetiredAt.IsZero() {
+		t.Fatalf("Unexpected keyring: %v %+v", err, keys)
+	}
+
+	// Once the retention window passes, the retired key and the legacy secret stop verifying
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		for _, key := range keys.Keys {
+			vbolt.Read(tx, SigningKeysBkt, key.Kid, &key)
+			key.CreatedAt = key.CreatedAt.Add(-2 * JWT_KEY_RETENTION)
+			if !key.RetiredAt.IsZero() {
+				key.RetiredAt = key.RetiredAt.Add(-2 * JWT_KEY_RETENTION)
+			}
+			vbolt.Write(tx, SigningKeysBkt, key.Kid, &key)
+		}
+		vbolt.TxCommit(tx)
+	})
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		rotated, err = RotateSigningKeys(&vbeam.Context{Tx: tx, Token: secondToken}, Empty{})
+	})
+	if err != nil || rotated.Pruned != 1 {
+		t.Fatalf("Expected the old key to be pruned: %v %+v", err, rotated)
+	}
+	if authOk(firstToken) || authOk(legacyToken) {
+		t.Errorf("Expected pruned and legacy keys to be rejected")
+	}
+	if !authOk(secondToken) {
+		t.Errorf("Expected the recently retired key to still verify")
+	}
+
+	// Only site admins manage keys
+	other := createTestInvitee(t, db, "other@test.com", true)
+	otherToken := signTestClaims(t, other.Id)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = RotateSigningKeys(&vbeam.Context{Tx: tx, Token: otherToken}, Empty{})
+	})
+	if err == nil {
+		t.Errorf("Expected non-admin rotation to be refused")
+	}
+}
+
+func TestJWTKeyAlgorithms(t *testing.T) {
+	for _, alg := range []string{JWT_ALG_HS256, JWT_ALG_EDDSA, JWT_ALG_RS256} {
+		key, err := generateSigningKey(alg, time.Now())
+		if err != nil {
+			t.Fatalf("generateSigningKey(%s) failed: %v", alg, err)
+		}
+		ringKey, err := parseSigningKey(key)
+		if err != nil || ringKey.method.Alg() != alg {
+			t.Fatalf("parseSigningKey(%s) failed: %v", alg, err)
+		}
+	}
+
+	key, _ := generateSigningKey(JWT_ALG_EDDSA, time.Now())
+	key.Algorithm = JWT_ALG_RS256
+	if _, err := parseSigningKey(key); err == nil {
+		t.Errorf("Expected key/algorithm mismatch to be rejected")
+	}
+}
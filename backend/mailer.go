@@ -0,0 +1,143 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MailMessage is a plain-text email
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional email
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+// appMailer is the mailer used by the application (nil if email is not configured)
+var appMailer Mailer
+
+// SMTPMailer sends mail through an SMTP server using PLAIN auth
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg MailMessage) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	data, err := m.format(msg, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{msg.To}, data)
+}
+
+// format renders the message with its headers. Names that end up in the
+// subject come from users, so line breaks are flattened there and the
+// subject is encoded; addresses with line breaks are refused outright.
+func (m *SMTPMailer) format(msg MailMessage, now time.Time) ([]byte, error) {
+	if strings.ContainsAny(m.From, "\r\n") || strings.ContainsAny(msg.To, "\r\n") {
+		return nil, errors.New("Email address contains a line break")
+	}
+	subject := strings.Join(strings.Fields(msg.Subject), " ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
+
+// MemoryMailer keeps sent messages in memory (used for tests and local development)
+type MemoryMailer struct {
+	mu        sync.Mutex
+	Messages  []MailMessage
+	FailSends bool
+}
+
+func (m *MemoryMailer) Send(msg MailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.FailSends {
+		return errors.New("memory: send failed")
+	}
+	m.Messages = append(m.Messages, msg)
+	return nil
+}
+
+// Sent returns a copy of all messages sent so far
+func (m *MemoryMailer) Sent() []MailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MailMessage(nil), m.Messages...)
+}
+
+// SetupMailer initializes the SMTP mailer from environment variables
+func SetupMailer() error {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return errors.New("SMTP not configured. Set SMTP_HOST and SMTP_FROM environment variables")
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	appMailer = &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+	return nil
+}
+
+// sendMail sends a message through the configured mailer, logging the outcome
+func sendMail(msg MailMessage) error {
+	if appMailer == nil {
+		LogWarn(LogCategorySystem, "Email not sent: mailer not configured", map[string]interface{}{
+			"to":      msg.To,
+			"subject": msg.Subject,
+		})
+		return errors.New("Email is not configured")
+	}
+
+	if err := appMailer.Send(msg); err != nil {
+		LogErrorSimple(LogCategorySystem, "Failed to send email", map[string]interface{}{
+			"to":      msg.To,
+			"subject": msg.Subject,
+			"error":   err.Error(),
+		})
+		return err
+	}
+
+	LogInfo(LogCategorySystem, "Email sent", map[string]interface{}{
+		"to":      msg.To,
+		"subject": msg.Subject,
+	})
+	return nil
+}
@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSMTPMailerFormat(t *testing.T) {
+	m := &SMTPMailer{Host: "localhost", Port: "25", From: "tickets@example.com"}
+
+	// A studio or product name with a line break can't add headers
+	name := "Spring Recital\r\nBcc: everyone@example.com"
+	data, err := m.format(MailMessage{
+		To:      "buyer@example.com",
+		Subject: fmt.Sprintf("Your ticket for %s", name),
+		Body:    "See you there",
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("format failed: %v", err)
+	}
+	headers, _, _ := strings.Cut(string(data), "\r\n\r\n")
+	var subject string
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("Expected no injected header, got %q", headers)
+		}
+		if value, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject = value
+		}
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	if err != nil {
+		t.Fatalf("DecodeHeader failed: %v", err)
+	}
+	if decoded != "Your ticket for Spring Recital Bcc: everyone@example.com" {
+		t.Errorf("Expected the subject on one line, got %q", decoded)
+	}
+
+	// Non-ASCII subjects are encoded
+	data, _ = m.format(MailMessage{To: "buyer@example.com", Subject: "Billet pour Café Concert"}, time.Now())
+	if !strings.Contains(string(data), "Subject: =?UTF-8?q?") {
+		t.Errorf("Expected an encoded subject, got %q", data)
+	}
+
+	// Addresses with line breaks are refused
+	if _, err := m.format(MailMessage{To: "buyer@example.com\r\nBcc: everyone@example.com", Subject: "Hi"}, time.Now()); err == nil {
+		t.Errorf("Expected a recipient with a line break to be refused")
+	}
+}
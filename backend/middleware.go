@@ -44,12 +44,7 @@ func AuthenticateRequest(r *http.Request) (User, error) {
 	}
 
 	// Parse and validate JWT token
-	jwtToken, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	jwtToken, err := jwt.ParseWithClaims(token, &Claims{}, jwtKeyFunc)
 
 	if err != nil || !jwtToken.Valid {
 		return user, errors.New("invalid token")
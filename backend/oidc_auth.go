@@ -0,0 +1,575 @@
+package backend
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"stream/cfg"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+	"golang.org/x/oauth2"
+)
+
+// OIDCProvider is a generic OpenID Connect identity provider (Microsoft, Apple,
+// a school's IdP, ...). Endpoints and signing keys come from the issuer's
+// discovery document.
+type OIDCProvider struct {
+	Name         string // URL-safe identifier, e.g. "microsoft"
+	DisplayName  string // Shown on the login button
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	ResponseMode string // Optional, e.g. "form_post" for Apple
+
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]any // kid => *rsa.PublicKey or *ecdsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcJWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// oidcIdentity is what we take from a verified ID token
+type oidcIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type oidcIDClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"` // bool, or "true"/"false" (Apple)
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// oidcStateClaims is kept in a short-lived signed cookie between the login
+// redirect and the callback
+type oidcStateClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+const (
+	oidcStateCookieName = "oidcState"
+	oidcStateTTL        = 10 * time.Minute
+	oidcKeysMinRefresh  = 1 * time.Minute
+)
+
+// name => provider
+var oidcProviders = map[string]*OIDCProvider{}
+
+// "provider:subject" => user id
+var OIDCIdentitiesBkt = vbolt.Bucket(&cfg.Info, "oidc_identities", vpack.StringZ, vpack.Int)
+
+// user id => "provider:subject" keys (for cleaning up a user's links)
+var OIDCIdentitiesByUserIdx = vbolt.Index(&cfg.Info, "oidc_identities_by_user", vpack.FInt, vpack.StringZ)
+
+// SetupOIDCProviders configures providers from the environment:
+//
+//	OIDC_PROVIDERS=microsoft,school
+//	OIDC_MICROSOFT_ISSUER, OIDC_MICROSOFT_CLIENT_ID, OIDC_MICROSOFT_CLIENT_SECRET
+//	OIDC_MICROSOFT_NAME (optional display name), OIDC_MICROSOFT_SCOPES (optional, space separated)
+//	OIDC_MICROSOFT_RESPONSE_MODE (optional, "form_post" for Apple)
+//
+// All providers share the redirect URL <SITE_URL>/api/oidc/callback.
+func SetupOIDCProviders() error {
+	names := strings.TrimSpace(os.Getenv("OIDC_PROVIDERS"))
+	if names == "" {
+		return nil
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := &OIDCProvider{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "NAME"),
+			Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			ResponseMode: os.Getenv(prefix + "RESPONSE_MODE"),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return fmt.Errorf("OIDC provider %s needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		if provider.DisplayName == "" {
+			provider.DisplayName = name
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Fields(scopes)
+		}
+		registerOIDCProvider(provider)
+	}
+	return nil
+}
+
+func registerOIDCProvider(provider *OIDCProvider) {
+	if provider.httpClient == nil {
+		provider.httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(provider.Scopes) == 0 {
+		provider.Scopes = []string{"openid", "email", "profile"}
+	}
+	oidcProviders[provider.Name] = provider
+}
+
+func (p *OIDCProvider) getJSON(url string, target any) error {
+	resp, err := p.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// getDiscovery fetches and caches the issuer's discovery document
+func (p *OIDCProvider) getDiscovery() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc oidcDiscovery
+	if err := p.getJSON(p.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %v", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", doc.Issuer, p.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// signingKey returns the issuer's public key for kid, refetching the key set
+// (at most once a minute) when the kid is unknown, so key rotation just works
+func (p *OIDCProvider) signingKey(kid string) (any, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcKeysMinRefresh {
+		return nil, errors.New("unknown signing key")
+	}
+
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := p.getJSON(doc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %v", err)
+	}
+	p.keysFetchedAt = time.Now()
+	p.keys = make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if key, keyErr := parseOIDCJWK(jwk); keyErr == nil {
+			p.keys[jwk.Kid] = key
+		}
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+func parseOIDCJWK(jwk oidcJWK) (any, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+}
+
+func (p *OIDCProvider) oauthConfig(doc *oidcDiscovery) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  cfg.SiteURL + "/api/oidc/callback",
+		Scopes:       p.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  doc.AuthorizationEndpoint,
+			TokenURL: doc.TokenEndpoint,
+		},
+	}
+}
+
+// verifyIDToken checks the ID token's signature, issuer, audience, expiry and nonce
+func (p *OIDCProvider) verifyIDToken(rawToken string, nonce string) (identity oidcIdentity, err error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return
+	}
+
+	token, err := jwt.ParseWithClaims(rawToken, &oidcIDClaims{}, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return identity, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	claims, ok := token.Claims.(*oidcIDClaims)
+	if !ok || !token.Valid {
+		return identity, errors.New("invalid ID token")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return identity, errors.New("ID token nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return identity, errors.New("ID token has no subject")
+	}
+
+	identity.Subject = claims.Subject
+	identity.Email = strings.TrimSpace(claims.Email)
+	identity.Name = claims.Name
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+	return identity, nil
+}
+
+// oidcStateKey derives a separate key from jwtKey so login state cookies can
+// never be accepted as auth tokens
+func oidcStateKey() []byte {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte("oidc-login-state"))
+	return mac.Sum(nil)
+}
+
+func parseOIDCState(r *http.Request) (claims oidcStateClaims, err error) {
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil || cookie.Value == "" {
+		return claims, errors.New("login session expired, please try again")
+	}
+	token, err := jwt.ParseWithClaims(cookie.Value, &claims, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return oidcStateKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return claims, errors.New("login session expired, please try again")
+	}
+	return claims, nil
+}
+
+// setOIDCStateCookie stores the login state. Providers using form_post (Apple)
+// post the callback cross-site, which needs SameSite=None over HTTPS.
+func setOIDCStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	cookie := &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    value,
+		Path:     "/api/oidc",
+		HttpOnly: true,
+		MaxAge:   maxAge,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if strings.HasPrefix(cfg.SiteURL, "https://") {
+		cookie.Secure = true
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(w, cookie)
+}
+
+// oidcLoginHandler redirects to the provider's authorization endpoint (/api/login/oidc?provider=name)
+func oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider := oidcProviders[r.URL.Query().Get("provider")]
+	if provider == nil {
+		http.Error(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+
+	doc, err := provider.getDiscovery()
+	if err != nil {
+		LogErrorWithRequest(r, LogCategoryAuth, "OIDC discovery failed", map[string]interface{}{
+			"provider": provider.Name,
+			"error":    err.Error(),
+		})
+		http.Error(w, "Login provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	state, err := generateToken(16)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := generateToken(16)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	claims := &oidcStateClaims{
+		Provider: provider.Name,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcStateTTL)),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(oidcStateKey())
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	setOIDCStateCookie(w, signed, int(oidcStateTTL.Seconds()))
+
+	opts := []oauth2.AuthCodeOption{
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	}
+	if provider.ResponseMode != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("response_mode", provider.ResponseMode))
+	}
+	http.Redirect(w, r, provider.oauthConfig(doc).AuthCodeURL(state, opts...), http.StatusTemporaryRedirect)
+}
+
+// oidcCallbackHandler completes the login for every provider (/api/oidc/callback)
+func oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	stateClaims, err := parseOIDCState(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// State is single use
+	setOIDCStateCookie(w, "", -1)
+
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("state")), []byte(stateClaims.State)) != 1 {
+		http.Error(w, "Invalid OAuth state", http.StatusBadRequest)
+		return
+	}
+
+	provider := oidcProviders[stateClaims.Provider]
+	if provider == nil {
+		http.Error(w, "Unknown login provider", http.StatusBadRequest)
+		return
+	}
+
+	if providerErr := r.FormValue("error"); providerErr != "" {
+		LogWarnWithRequest(r, LogCategoryAuth, "OIDC provider returned an error", map[string]interface{}{
+			"provider": provider.Name,
+			"error":    providerErr,
+		})
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	doc, err := provider.getDiscovery()
+	if err != nil {
+		http.Error(w, "Login provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, provider.httpClient)
+	token, err := provider.oauthConfig(doc).Exchange(ctx, r.FormValue("code"), oauth2.VerifierOption(stateClaims.Verifier))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Code exchange failed: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		http.Error(w, "Login provider did not return an ID token", http.StatusBadGateway)
+		return
+	}
+
+	identity, err := provider.verifyIDToken(rawIDToken, stateClaims.Nonce)
+	if err != nil {
+		LogWarnWithRequest(r, LogCategoryAuth, "OIDC ID token rejected", map[string]interface{}{
+			"provider": provider.Name,
+			"error":    err.Error(),
+		})
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	var user User
+	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+		user, err = resolveOIDCUserTx(tx, provider.Name, identity)
+		if err == nil {
+			vbolt.TxCommit(tx)
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	LogInfoWithRequest(r, LogCategoryAuth, "User login via OIDC", map[string]interface{}{
+		"userId":   user.Id,
+		"provider": provider.Name,
+	})
+
+	finishExternalLogin(w, r, user.Id)
+}
+
+// resolveOIDCUserTx finds the account for a provider identity. Identities seen
+// before map straight to their user; otherwise the provider must vouch for the
+// email address, which then links an existing account or creates a new one.
+func resolveOIDCUserTx(tx *vbolt.Tx, providerName string, identity oidcIdentity) (User, error) {
+	linkKey := providerName + ":" + identity.Subject
+
+	var userId int
+	vbolt.Read(tx, OIDCIdentitiesBkt, linkKey, &userId)
+	if userId != 0 {
+		if user := GetUser(tx, userId); user.Id != 0 {
+			return user, nil
+		}
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return User{}, errors.New("Your account with this provider has no verified email address")
+	}
+
+	var user User
+	if userId = GetUserId(tx, identity.Email); userId != 0 {
+		user = GetUser(tx, userId)
+		if !user.EmailVerified {
+			// Nobody proved they own this address when the account was created, so
+			// whoever set its password may not be the person signing in now
+			vbolt.Write(tx, PasswdBkt, user.Id, &[]byte{})
+			DeleteUserRefreshTokens(tx, user.Id)
+		}
+		LogInfo(LogCategoryAuth, "Linked OIDC identity to existing account", map[string]interface{}{
+			"userId":   user.Id,
+			"provider": providerName,
+		})
+	} else {
+		name := identity.Name
+		if name == "" {
+			name = strings.Split(identity.Email, "@")[0]
+		}
+		user = AddUserTx(tx, CreateAccountRequest{Name: name, Email: identity.Email}, []byte{})
+		LogInfo(LogCategoryAuth, "User account created via OIDC", map[string]interface{}{
+			"userId": user.Id,
+			"email":  identity.Email,
+			"source": providerName,
+		})
+	}
+
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		vbolt.Write(tx, UsersBkt, user.Id, &user)
+		acceptPendingInvitationsTx(tx, user)
+	}
+
+	vbolt.Write(tx, OIDCIdentitiesBkt, linkKey, &user.Id)
+	vbolt.SetTargetSingleTerm(tx, OIDCIdentitiesByUserIdx, linkKey, user.Id)
+	return user, nil
+}
+
+type LoginProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type ListLoginProvidersResponse struct {
+	Google    bool            `json:"google"`
+	Providers []LoginProvider `json:"providers"`
+}
+
+// ListLoginProviders returns the configured external login options for the login page
+func ListLoginProviders(ctx *vbeam.Context, req Empty) (resp ListLoginProvidersResponse, err error) {
+	resp.Google = oauthConf != nil
+	resp.Providers = make([]LoginProvider, 0, len(oidcProviders))
+	for _, provider := range oidcProviders {
+		resp.Providers = append(resp.Providers, LoginProvider{
+			Name:        provider.Name,
+			DisplayName: provider.DisplayName,
+		})
+	}
+	sort.Slice(resp.Providers, func(i, j int) bool {
+		return resp.Providers[i].DisplayName < resp.Providers[j].DisplayName
+	})
+	return
+}
@@ -0,0 +1,284 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.hasen.dev/vbolt"
+)
+
+// fakeOIDCIssuer is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that checks PKCE and returns an ID token for the configured user
+type fakeOIDCIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string // code_challenge from the last authorize redirect
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newFakeOIDCIssuer(t *testing.T) *fakeOIDCIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	f := &fakeOIDCIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != f.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   f.server.URL,
+			"aud":   "test-client",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": f.nonce,
+		}
+		for k, v := range f.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test-key"
+		idToken, _ := token.SignedString(f.key)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// setupTestOIDCProvider registers a provider for the fake issuer
+func setupTestOIDCProvider(t *testing.T, issuer *fakeOIDCIssuer) {
+	original := oidcProviders
+	oidcProviders = map[string]*OIDCProvider{}
+	registerOIDCProvider(&OIDCProvider{
+		Name:        "school",
+		DisplayName: "School Login",
+		Issuer:      issuer.server.URL,
+		ClientID:    "test-client",
+		httpClient:  issuer.server.Client(),
+	})
+	t.Cleanup(func() { oidcProviders = original })
+}
+
+// runTestOIDCLogin walks through the redirect and callback, returning the callback response
+func runTestOIDCLogin(t *testing.T, issuer *fakeOIDCIssuer, claims jwt.MapClaims, tamper func(nonce string) string) *httptest.ResponseRecorder {
+	loginW := httptest.NewRecorder()
+	oidcLoginHandler(loginW, httptest.NewRequest("GET", "/api/login/oidc?provider=school", nil))
+	if loginW.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect, got %d %s", loginW.Code, loginW.Body.String())
+	}
+
+	location, _ := url.Parse(loginW.Header().Get("Location"))
+	query := location.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("nonce") == "" {
+		t.Fatalf("Expected PKCE and nonce in authorize URL, got %s", location)
+	}
+
+	issuer.mu.Lock()
+	issuer.challenge = query.Get("code_challenge")
+	issuer.nonce = query.Get("nonce")
+	if tamper != nil {
+		issuer.nonce = tamper(issuer.nonce)
+	}
+	issuer.claims = claims
+	issuer.mu.Unlock()
+
+	stateCookie := findTestCookie(loginW, oidcStateCookieName)
+	callback := httptest.NewRequest("GET", "/api/oidc/callback?code=abc&state="+url.QueryEscape(query.Get("state")), nil)
+	callback.AddCookie(stateCookie)
+	w := httptest.NewRecorder()
+	oidcCallbackHandler(w, callback)
+	return w
+}
+
+func TestOIDCLoginCreatesAndLinksAccounts(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+	issuer := newFakeOIDCIssuer(t)
+	setupTestOIDCProvider(t, issuer)
+
+	// New verified identity creates an account
+	w := runTestOIDCLogin(t, issuer, jwt.MapClaims{
+		"sub":            "student-1",
+		"email":          "student@school.edu",
+		"email_verified": true,
+		"name":           "Student One",
+	}, nil)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("Expected redirect to dashboard, got %d %s", w.Code, w.Body.String())
+	}
+	if findTestCookie(w, "authToken") == nil || findTestCookie(w, "refreshToken") == nil {
+		t.Errorf("Expected auth and refresh cookies")
+	}
+
+	var studentId int
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		studentId = GetUserId(tx, "student@school.edu")
+		if user := GetUser(tx, studentId); user.Name != "Student One" || !user.EmailVerified {
+			t.Errorf("Unexpected OIDC user: %+v", user)
+		}
+	})
+
+	// Existing password account with the same verified email ("true" string, as Apple sends it) is linked
+	var existing User
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		existing = AddUserTx(tx, CreateAccountRequest{Name: "Teacher", Email: "teacher@school.edu"}, []byte("hash"))
+		existing.EmailVerified = true
+		vbolt.Write(tx, UsersBkt, existing.Id, &existing)
+		vbolt.TxCommit(tx)
+	})
+	runTestOIDCLogin(t, issuer, jwt.MapClaims{
+		"sub":            "teacher-1",
+		"email":          "teacher@school.edu",
+		"email_verified": "true",
+	}, nil)
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		var linkedId int
+		vbolt.Read(tx, OIDCIdentitiesBkt, "school:teacher-1", &linkedId)
+		if linkedId != existing.Id {
+			t.Errorf("Expected identity linked to user %d, got %d", existing.Id, linkedId)
+		}
+		if string(GetPassHash(tx, existing.Id)) != "hash" {
+			t.Errorf("Linking a verified account should keep its password")
+		}
+	})
+
+	// Once linked, the subject logs in even if the email changes at the provider
+	w = runTestOIDCLogin(t, issuer, jwt.MapClaims{"sub": "student-1", "email": "renamed@school.edu"}, nil)
+	if w.Code != http.StatusFound {
+		t.Errorf("Expected linked identity to log in, got %d %s", w.Code, w.Body.String())
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if GetUserId(tx, "renamed@school.edu") != 0 {
+			t.Errorf("Linked login should not create a new account")
+		}
+	})
+}
+
+func TestOIDCLoginRejectsBadResponses(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+	issuer := newFakeOIDCIssuer(t)
+	setupTestOIDCProvider(t, issuer)
+
+	// Unverified email can't create or link an account
+	w := runTestOIDCLogin(t, issuer, jwt.MapClaims{
+		"sub":            "anon",
+		"email":          "someone@school.edu",
+		"email_verified": false,
+	}, nil)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "verified email") {
+		t.Errorf("Expected unverified email to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	// ID token minted for another login attempt (nonce mismatch)
+	w = runTestOIDCLogin(t, issuer, jwt.MapClaims{
+		"sub":            "replay",
+		"email":          "replay@school.edu",
+		"email_verified": true,
+	}, func(nonce string) string { return "other-" + nonce })
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected nonce mismatch to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Callback without the state cookie, or with the wrong state
+	w = httptest.NewRecorder()
+	oidcCallbackHandler(w, httptest.NewRequest("GET", "/api/oidc/callback?code=abc&state=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected missing state cookie to be rejected, got %d", w.Code)
+	}
+
+	loginW := httptest.NewRecorder()
+	oidcLoginHandler(loginW, httptest.NewRequest("GET", "/api/login/oidc?provider=school", nil))
+	callback := httptest.NewRequest("GET", "/api/oidc/callback?code=abc&state=forged", nil)
+	callback.AddCookie(findTestCookie(loginW, oidcStateCookieName))
+	w = httptest.NewRecorder()
+	oidcCallbackHandler(w, callback)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "state") {
+		t.Errorf("Expected forged state to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if GetUserId(tx, "someone@school.edu") != 0 || GetUserId(tx, "replay@school.edu") != 0 {
+			t.Errorf("Rejected logins should not create accounts")
+		}
+	})
+}
+
+func TestOIDCLinkingUnverifiedAccountClearsPassword(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	var squatter User
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		squatter = AddUserTx(tx, CreateAccountRequest{Name: "Squatter", Email: "owner@school.edu"}, []byte("hash"))
+		CreateRefreshToken(tx, squatter.Id, time.Hour, "", "")
+		vbolt.TxCommit(tx)
+	})
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		user, err := resolveOIDCUserTx(tx, "school", oidcIdentity{Subject: "owner", Email: "owner@school.edu", EmailVerified: true})
+		if err != nil || user.Id != squatter.Id || !user.EmailVerified {
+			t.Fatalf("Expected link to existing account, got %v %+v", err, user)
+		}
+		if len(GetPassHash(tx, squatter.Id)) != 0 {
+			t.Errorf("Expected password of unverified account to be cleared")
+		}
+		var tokenIds []int
+		vbolt.ReadTermTargets(tx, RefreshTokenByUserIndex, squatter.Id, &tokenIds, vbolt.Window{})
+		if len(tokenIds) != 0 {
+			t.Errorf("Expected existing sessions to be revoked")
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PaymentEventType identifies the webhook events the ticketing system acts on
+type PaymentEventType int
+
+const (
+	PaymentEventIgnored           PaymentEventType = 0 // Event we don't handle
+	PaymentEventCheckoutCompleted PaymentEventType = 1 // Buyer paid for a checkout session
+	PaymentEventRefunded          PaymentEventType = 2 // Payment was fully refunded (e.g. from the provider dashboard)
+)
+
+// CheckoutParams describes a single-ticket checkout
+type CheckoutParams struct {
+	ProductId   int
+	ProductName string
+	AmountCents int
+	Currency    string
+	BuyerEmail  string
+	SuccessURL  string
+	CancelURL   string
+}
+
+// CheckoutSession is the provider-side checkout the buyer is redirected to
+type CheckoutSession struct {
+	SessionId string // Provider checkout session ID
+	URL       string // Hosted payment page
+}
+
+// PaymentEvent is a verified, provider-agnostic webhook event
+type PaymentEvent struct {
+	Type       PaymentEventType `json:"type"`
+	SessionId  string           `json:"sessionId"`  // Checkout session ID (checkout completed)
+	PaymentRef string           `json:"paymentRef"` // Provider payment ID (used for refunds)
+}
+
+// PaymentProvider abstracts the payment processor used for ticket sales
+type PaymentProvider interface {
+	Name() string
+	CreateCheckout(params CheckoutParams) (CheckoutSession, error)
+	// ParseWebhook verifies the request signature and decodes the event
+	ParseWebhook(r *http.Request) (PaymentEvent, error)
+	// Refund fully refunds a payment. Calls with the same idempotency key
+	// refund once, so a retry after a lost response can't refund twice.
+	Refund(paymentRef string, idempotencyKey string) error
+}
+
+// paymentProvider is the provider used by the application (nil if payments are not configured)
+var paymentProvider PaymentProvider
+
+// SetupPayments initializes the Stripe payment provider from environment variables
+func SetupPayments() error {
+	secretKey := os.Getenv("STRIPE_SECRET_KEY")
+	webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+
+	if secretKey == "" || webhookSecret == "" {
+		return errors.New("Stripe credentials not configured. Set STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET environment variables")
+	}
+
+	paymentProvider = &StripeProvider{
+		SecretKey:     secretKey,
+		WebhookSecret: webhookSecret,
+		APIBase:       "https://api.stripe.com",
+		Client:        &http.Client{Timeout: 15 * time.Second},
+	}
+	return nil
+}
+
+// Stripe
+
+// stripeSignatureTolerance is how old a webhook signature timestamp may be
+const stripeSignatureTolerance = 5 * time.Minute
+
+// StripeProvider implements PaymentProvider using Stripe Checkout
+type StripeProvider struct {
+	SecretKey     string
+	WebhookSecret string
+	APIBase       string
+	Client        *http.Client
+}
+
+func (s *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// stripePost sends a form-encoded POST to the Stripe API and decodes the JSON
+// response. Stripe replays the first response to requests sharing a non-empty
+// idempotency key.
+func (s *StripeProvider) stripePost(path string, form url.Values, idempotencyKey string, out interface{}) error {
+	req, err := http.NewRequest("POST", s.APIBase+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.SecretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var stripeErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &stripeErr)
+		if stripeErr.Error.Message != "" {
+			return fmt.Errorf("stripe: %s", stripeErr.Error.Message)
+		}
+		return fmt.Errorf("stripe: unexpected status %d", res.StatusCode)
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+func (s *StripeProvider) CreateCheckout(params CheckoutParams) (session CheckoutSession, err error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", params.SuccessURL)
+	form.Set("cancel_url", params.CancelURL)
+	form.Set("customer_email", params.BuyerEmail)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", params.Currency)
+	form.Set("line_items[0][price_data][unit_amount]", strconv.Itoa(params.AmountCents))
+	form.Set("line_items[0][price_data][product_data][name]", params.ProductName)
+	form.Set("metadata[productId]", strconv.Itoa(params.ProductId))
+
+	var result struct {
+		Id  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err = s.stripePost("/v1/checkout/sessions", form, "", &result); err != nil {
+		return
+	}
+
+	session.SessionId = result.Id
+	session.URL = result.URL
+	return
+}
+
+func (s *StripeProvider) Refund(paymentRef string, idempotencyKey string) error {
+	form := url.Values{}
+	form.Set("payment_intent", paymentRef)
+
+	var result struct {
+		Id string `json:"id"`
+	}
+	return s.stripePost("/v1/refunds", form, "refund-"+idempotencyKey, &result)
+}
+
+// verifyStripeSignature checks a Stripe-Signature header ("t=...,v1=...") against the payload
+func verifyStripeSignature(payload []byte, header string, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+
+	if timestamp == "" || len(signatures) == 0 {
+		return errors.New("Missing webhook signature")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("Invalid webhook signature timestamp")
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return errors.New("Webhook signature timestamp outside tolerance")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return errors.New("Invalid webhook signature")
+}
+
+func (s *StripeProvider) ParseWebhook(r *http.Request) (event PaymentEvent, err error) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return
+	}
+
+	if err = verifyStripeSignature(payload, r.Header.Get("Stripe-Signature"), s.WebhookSecret, time.Now()); err != nil {
+		return
+	}
+
+	var stripeEvent struct {
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				Id             string `json:"id"`
+				PaymentIntent  string `json:"payment_intent"`
+				PaymentStatus  string `json:"payment_status"`
+				Amount         int    `json:"amount"`
+				AmountRefunded int    `json:"amount_refunded"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err = json.Unmarshal(payload, &stripeEvent); err != nil {
+		return
+	}
+
+	obj := stripeEvent.Data.Object
+	switch stripeEvent.Type {
+	case "checkout.session.completed":
+		if obj.PaymentStatus == "paid" {
+			event.Type = PaymentEventCheckoutCompleted
+			event.SessionId = obj.Id
+			event.PaymentRef = obj.PaymentIntent
+		}
+	case "charge.refunded":
+		// Only full refunds revoke the ticket
+		if obj.AmountRefunded >= obj.Amount {
+			event.Type = PaymentEventRefunded
+			event.PaymentRef = obj.PaymentIntent
+		}
+	}
+	return
+}
+
+// Fake provider (tests and local development)
+
+// FakePaymentProvider records checkouts and refunds in memory.
+// Its webhook body is a PaymentEvent encoded as JSON, with no signature.
+type FakePaymentProvider struct {
+	mu          sync.Mutex
+	nextId      int
+	Checkouts   []CheckoutParams
+	Refunds     []string
+	FailRefunds bool
+	// LoseRefundResponses issues refunds but reports them as failed, like a
+	// response lost on the way back
+	LoseRefundResponses bool
+	refundKeys          map[string]bool
+}
+
+func (f *FakePaymentProvider) Name() string {
+	return "fake"
+}
+
+func (f *FakePaymentProvider) CreateCheckout(params CheckoutParams) (CheckoutSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextId++
+	f.Checkouts = append(f.Checkouts, params)
+	sessionId := fmt.Sprintf("fake_cs_%d", f.nextId)
+	return CheckoutSession{
+		SessionId: sessionId,
+		URL:       "/fake-checkout/" + sessionId,
+	}, nil
+}
+
+func (f *FakePaymentProvider) ParseWebhook(r *http.Request) (event PaymentEvent, err error) {
+	err = json.NewDecoder(r.Body).Decode(&event)
+	return
+}
+
+func (f *FakePaymentProvider) Refund(paymentRef string, idempotencyKey string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.FailRefunds {
+		return errors.New("fake: refund failed")
+	}
+	if f.refundKeys == nil {
+		f.refundKeys = make(map[string]bool)
+	}
+	if !f.refundKeys[idempotencyKey] {
+		f.refundKeys[idempotencyKey] = true
+		f.Refunds = append(f.Refunds, paymentRef)
+	}
+	if f.LoseRefundResponses {
+		return errors.New("fake: refund response lost")
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"stream/cfg"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const MAX_POLL_QUESTION_LENGTH = 200
+const MAX_POLL_OPTION_LENGTH = 80
+const MIN_POLL_OPTIONS = 2
+const MAX_POLL_OPTIONS = 6
+
+// Poll is a quick multiple-choice question an instructor runs during a class,
+// such as "Which piece next?". Tallies are kept on the poll so viewers can
+// see them update live.
+type Poll struct {
+	Id         int       `json:"id"`
+	RoomId     int       `json:"roomId"`
+	StreamId   int       `json:"streamId"` // Stream session it ran during (0 if the room was offline)
+	Question   string    `json:"question"`
+	Options    []string  `json:"options"`
+	Votes      []int     `json:"votes"` // Vote count for each option
+	TotalVotes int       `json:"totalVotes"`
+	IsClosed   bool      `json:"isClosed"`
+	CreatedBy  int       `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ClosedAt   time.Time `json:"closedAt"`
+	MyVote     int       `json:"myVote"` // Option the caller chose, -1 if none (not stored)
+}
+
+func PackPoll(self *Poll, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.RoomId, buf)
+	vpack.Int(&self.StreamId, buf)
+	vpack.String(&self.Question, buf)
+	vpack.Slice(&self.Options, vpack.String, buf)
+	vpack.Slice(&self.Votes, vpack.Int, buf)
+	vpack.Int(&self.TotalVotes, buf)
+	vpack.Bool(&self.IsClosed, buf)
+	vpack.Int(&self.CreatedBy, buf)
+	vpack.Time(&self.CreatedAt, buf)
+	vpack.Time(&self.ClosedAt, buf)
+}
+
+// PollVote is one viewer's choice on a poll. Viewers are identified like chat
+// restrictions, by chatViewerId.
+type PollVote struct {
+	PollId   int       `json:"pollId"`
+	ViewerId string    `json:"-"`
+	Option   int       `json:"option"`
+	VotedAt  time.Time `json:"votedAt"`
+}
+
+func PackPollVote(self *PollVote, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.PollId, buf)
+	vpack.String(&self.ViewerId, buf)
+	vpack.Int(&self.Option, buf)
+	vpack.Time(&self.VotedAt, buf)
+}
+
+// PollsBkt stores polls by ID
+var PollsBkt = vbolt.Bucket(&cfg.Info, "polls", vpack.FInt, PackPoll)
+
+// PollsByRoomIdx finds a room's polls (term=roomId, target=pollId)
+var PollsByRoomIdx = vbolt.Index(&cfg.Info, "polls_by_room", vpack.FInt, vpack.FInt)
+
+// PollsByStreamIdx finds the polls run during a stream session (term=streamId, target=pollId)
+var PollsByStreamIdx = vbolt.Index(&cfg.Info, "polls_by_stream", vpack.FInt, vpack.FInt)
+
+// PollVotesBkt stores votes by pollVoteKey, so each viewer has at most one per poll
+var PollVotesBkt = vbolt.Bucket(&cfg.Info, "poll_votes", vpack.StringZ, PackPollVote)
+
+// PollVotesByPollIdx finds a poll's votes (term=pollId, target=vote key)
+var PollVotesByPollIdx = vbolt.Index(&cfg.Info, "poll_votes_by_poll", vpack.FInt, vpack.StringZ)
+
+// PollVotesByViewerIdx finds a viewer's votes (term=viewerId, target=vote key)
+var PollVotesByViewerIdx = vbolt.Index(&cfg.Info, "poll_votes_by_viewer", vpack.StringZ, vpack.StringZ)
+
+func pollVoteKey(pollId int, viewerId string) string {
+	return fmt.Sprintf("%d|%s", pollId, viewerId)
+}
+
+// sessionPolls returns the polls run during the room's current session (the
+// live stream, or while offline), newest first
+func sessionPolls(tx *vbolt.Tx, roomId int) []Poll {
+	streamId := liveStream(tx, roomId).Id
+	var pollIds []int
+	vbolt.ReadTermTargets(tx, PollsByRoomIdx, roomId, &pollIds, vbolt.Window{})
+	polls := []Poll{}
+	for i := len(pollIds) - 1; i >= 0; i-- {
+		var poll Poll
+		vbolt.Read(tx, PollsBkt, pollIds[i], &poll)
+		if poll.Id != 0 && poll.StreamId == streamId {
+			polls = append(polls, poll)
+		}
+	}
+	return polls
+}
+
+// pollForViewer sets MyVote from the viewer's stored vote
+func pollForViewer(tx *vbolt.Tx, poll Poll, viewerId string) Poll {
+	poll.MyVote = -1
+	var vote PollVote
+	if vbolt.Read(tx, PollVotesBkt, pollVoteKey(poll.Id, viewerId), &vote) {
+		poll.MyVote = vote.Option
+	}
+	return poll
+}
+
+// deleteRoomPollsTx deletes all of a room's polls and their votes
+func deleteRoomPollsTx(tx *vbolt.Tx, roomId int) int {
+	var pollIds []int
+	vbolt.ReadTermTargets(tx, PollsByRoomIdx, roomId, &pollIds, vbolt.Window{})
+	for _, pollId := range pollIds {
+		var voteKeys []string
+		vbolt.ReadTermTargets(tx, PollVotesByPollIdx, pollId, &voteKeys, vbolt.Window{})
+		for _, key := range voteKeys {
+			vbolt.SetTargetSingleTerm(tx, PollVotesByPollIdx, key, -1)
+			vbolt.SetTargetSingleTerm(tx, PollVotesByViewerIdx, key, "")
+			vbolt.Delete(tx, PollVotesBkt, key)
+		}
+		vbolt.SetTargetSingleTerm(tx, PollsByRoomIdx, pollId, -1)
+		vbolt.SetTargetSingleTerm(tx, PollsByStreamIdx, pollId, -1)
+		vbolt.Delete(tx, PollsBkt, pollId)
+	}
+	return len(pollIds)
+}
+
+// RegisterPollMethods registers live class poll procedures
+func RegisterPollMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, CreatePoll)
+	vbeam.RegisterProc(app, ClosePoll)
+	vbeam.RegisterProc(app, VotePoll)
+	vbeam.RegisterProc(app, ListRoomPolls)
+}
+
+type CreatePollRequest struct {
+	RoomId   int      `json:"roomId"`
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+}
+
+type PollResponse struct {
+	Poll Poll `json:"poll"`
+}
+
+type ClosePollRequest struct {
+	PollId int `json:"pollId"`
+}
+
+type VotePollRequest struct {
+	PollId int `json:"pollId"`
+	Option int `json:"option"` // Index into the poll's options
+}
+
+type ListRoomPollsRequest struct {
+	RoomId int `json:"roomId"`
+}
+
+type ListRoomPollsResponse struct {
+	Polls     []Poll `json:"polls"`     // This session's polls, newest first
+	CanManage bool   `json:"canManage"` // May create and close polls (chat moderators)
+}
+
+// CreatePoll starts a poll in a room and shows it to everyone watching.
+// Chat moderators only; a room runs one poll at a time.
+func CreatePoll(ctx *vbeam.Context, req CreatePollRequest) (resp PollResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	room := GetRoom(ctx.Tx, req.RoomId)
+	if room.Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+	if !canModerateChat(ctx.Tx, caller, room.StudioId) {
+		return resp, errors.New("Only chat moderators can run polls")
+	}
+
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return resp, errors.New("Poll question is required")
+	}
+	if utf8.RuneCountInString(question) > MAX_POLL_QUESTION_LENGTH {
+		return resp, fmt.Errorf("Poll question must be %d characters or less", MAX_POLL_QUESTION_LENGTH)
+	}
+	options := []string{}
+	for _, option := range req.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		if utf8.RuneCountInString(option) > MAX_POLL_OPTION_LENGTH {
+			return resp, fmt.Errorf("Poll options must be %d characters or less", MAX_POLL_OPTION_LENGTH)
+		}
+		options = append(options, option)
+	}
+	if len(options) < MIN_POLL_OPTIONS || len(options) > MAX_POLL_OPTIONS {
+		return resp, fmt.Errorf("Polls need between %d and %d options", MIN_POLL_OPTIONS, MAX_POLL_OPTIONS)
+	}
+
+	for _, poll := range sessionPolls(ctx.Tx, room.Id) {
+		if !poll.IsClosed {
+			return resp, errors.New("Close the current poll before starting another")
+		}
+	}
+
+	vbeam.UseWriteTx(ctx)
+	poll := Poll{
+		Id:        vbolt.NextIntId(ctx.Tx, PollsBkt),
+		RoomId:    room.Id,
+		StreamId:  liveStream(ctx.Tx, room.Id).Id,
+		Question:  question,
+		Options:   options,
+		Votes:     make([]int, len(options)),
+		CreatedBy: caller.Id,
+		CreatedAt: time.Now(),
+	}
+	vbolt.Write(ctx.Tx, PollsBkt, poll.Id, &poll)
+	vbolt.SetTargetSingleTerm(ctx.Tx, PollsByRoomIdx, poll.Id, room.Id)
+	if poll.StreamId > 0 {
+		vbolt.SetTargetSingleTerm(ctx.Tx, PollsByStreamIdx, poll.Id, poll.StreamId)
+	}
+	vbolt.TxCommit(ctx.Tx)
+
+	poll.MyVote = -1
+	sseManager.BroadcastPoll(room.Id, poll)
+
+	LogInfo(LogCategorySystem, "Poll created", map[string]interface{}{
+		"roomId":    room.Id,
+		"pollId":    poll.Id,
+		"streamId":  poll.StreamId,
+		"options":   len(options),
+		"createdBy": caller.Id,
+	})
+
+	resp.Poll = poll
+	return
+}
+
+// ClosePoll stops voting on a poll and sends the final tally
+func ClosePoll(ctx *vbeam.Context, req ClosePollRequest) (resp PollResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	var poll Poll
+	vbolt.Read(ctx.Tx, PollsBkt, req.PollId, &poll)
+	if poll.Id == 0 {
+		return resp, errors.New("Poll not found")
+	}
+	room := GetRoom(ctx.Tx, poll.RoomId)
+	if !canModerateChat(ctx.Tx, caller, room.StudioId) {
+		return resp, errors.New("Only chat moderators can run polls")
+	}
+	if poll.IsClosed {
+		return resp, errors.New("Poll is already closed")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	poll.IsClosed = true
+	poll.ClosedAt = time.Now()
+	vbolt.Write(ctx.Tx, PollsBkt, poll.Id, &poll)
+	vbolt.TxCommit(ctx.Tx)
+
+	poll.MyVote = -1
+	sseManager.BroadcastPoll(poll.RoomId, poll)
+
+	LogInfo(LogCategorySystem, "Poll closed", map[string]interface{}{
+		"roomId":     poll.RoomId,
+		"pollId":     poll.Id,
+		"totalVotes": poll.TotalVotes,
+		"closedBy":   caller.Id,
+	})
+
+	resp.Poll = poll
+	return
+}
+
+// VotePoll records the caller's choice on an open poll. Each user, or device
+// on an access code, has one vote; voting again moves it to the new option.
+func VotePoll(ctx *vbeam.Context, req VotePollRequest) (resp PollResponse, err error) {
+	user, err := GetAuthUser(ctx)
+	if err != nil {
+		return resp, ErrAuthFailure
+	}
+
+	var poll Poll
+	vbolt.Read(ctx.Tx, PollsBkt, req.PollId, &poll)
+	if poll.Id == 0 {
+		return resp, errors.New("Poll not found")
+	}
+	if poll.IsClosed {
+		return resp, errors.New("This poll has closed")
+	}
+	if req.Option < 0 || req.Option >= len(poll.Options) {
+		return resp, errors.New("Invalid poll option")
+	}
+	if err := requireRoomAccess(ctx, user, poll.RoomId); err != nil {
+		return resp, err
+	}
+	room := GetRoom(ctx.Tx, poll.RoomId)
+	viewerId := chatViewerId(ctx, user)
+	if r := activeChatRestriction(ctx.Tx, room.StudioId, viewerId); r.Id > 0 {
+		return resp, chatRestrictionError(r)
+	}
+
+	key := pollVoteKey(poll.Id, viewerId)
+	var vote PollVote
+	hadVote := vbolt.Read(ctx.Tx, PollVotesBkt, key, &vote)
+	if hadVote && vote.Option == req.Option {
+		resp.Poll = pollForViewer(ctx.Tx, poll, viewerId)
+		return
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckPollVote(viewerId); rateLimitErr != nil {
+		return resp, errors.New("Please wait before voting again")
+	}
+
+	vbeam.UseWriteTx(ctx)
+
+	// Other viewers may have voted since the read, so tally from fresh copies
+	poll = Poll{}
+	vbolt.Read(ctx.Tx, PollsBkt, req.PollId, &poll)
+	if poll.Id == 0 {
+		return resp, errors.New("Poll not found")
+	}
+	if poll.IsClosed {
+		return resp, errors.New("This poll has closed")
+	}
+	vote = PollVote{}
+	hadVote = vbolt.Read(ctx.Tx, PollVotesBkt, key, &vote)
+	if hadVote && vote.Option == req.Option {
+		resp.Poll = pollForViewer(ctx.Tx, poll, viewerId)
+		return
+	}
+	if hadVote && vote.Option < len(poll.Votes) {
+		poll.Votes[vote.Option]--
+	} else {
+		poll.TotalVotes++
+	}
+	poll.Votes[req.Option]++
+	vote = PollVote{PollId: poll.Id, ViewerId: viewerId, Option: req.Option, VotedAt: time.Now()}
+	vbolt.Write(ctx.Tx, PollVotesBkt, key, &vote)
+	vbolt.SetTargetSingleTerm(ctx.Tx, PollVotesByPollIdx, key, poll.Id)
+	vbolt.SetTargetSingleTerm(ctx.Tx, PollVotesByViewerIdx, key, viewerId)
+	vbolt.Write(ctx.Tx, PollsBkt, poll.Id, &poll)
+	vbolt.TxCommit(ctx.Tx)
+
+	poll.MyVote = -1
+	sseManager.BroadcastPoll(poll.RoomId, poll)
+
+	LogDebug(LogCategorySystem, "Poll vote recorded", map[string]interface{}{
+		"roomId":  poll.RoomId,
+		"pollId":  poll.Id,
+		"option":  req.Option,
+		"changed": hadVote,
+		"userId":  user.Id,
+	})
+
+	poll.MyVote = req.Option
+	resp.Poll = poll
+	return
+}
+
+// ListRoomPolls returns the polls from the room's current session with the
+// caller's votes. Both JWT users and code sessions can list polls.
+func ListRoomPolls(ctx *vbeam.Context, req ListRoomPollsRequest) (resp ListRoomPollsResponse, err error) {
+	user, err := GetAuthUser(ctx)
+	if err != nil {
+		return resp, ErrAuthFailure
+	}
+
+	room := GetRoom(ctx.Tx, req.RoomId)
+	if room.Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+	if err := requireRoomAccess(ctx, user, room.Id); err != nil {
+		return resp, err
+	}
+
+	viewerId := chatViewerId(ctx, user)
+	resp.Polls = sessionPolls(ctx.Tx, room.Id)
+	for i, poll := range resp.Polls {
+		resp.Polls[i] = pollForViewer(ctx.Tx, poll, viewerId)
+	}
+	resp.CanManage = canModerateChat(ctx.Tx, user, room.StudioId)
+	return
+}
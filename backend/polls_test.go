@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestPolls(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+	outsider := createTestInvitee(t, db, "outsider@test.com", true)
+
+	var otherRoom Room
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		otherRoom = createTestRoom(tx, studio.Id, 2)
+		vbolt.TxCommit(tx)
+	})
+
+	adminToken, _ := createTestToken(admin.Id)
+	memberToken, _ := createTestToken(member.Id)
+	guestToken := createTestGuestToken(t, db, adminToken, room.Id)
+	otherRoomGuestToken := createTestGuestToken(t, db, adminToken, otherRoom.Id)
+	outsiderToken, _ := createTestToken(outsider.Id)
+
+	create := func(token string, question string, options ...string) (poll Poll, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var resp PollResponse
+			resp, err = CreatePoll(&vbeam.Context{Tx: tx, Token: token}, CreatePollRequest{RoomId: room.Id, Question: question, Options: options})
+			poll = resp.Poll
+		})
+		return
+	}
+	vote := func(token string, pollId int, option int) (poll Poll, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var resp PollResponse
+			resp, err = VotePoll(&vbeam.Context{Tx: tx, Token: token}, VotePollRequest{PollId: pollId, Option: option})
+			poll = resp.Poll
+		})
+		return
+	}
+	closePoll := func(token string, pollId int) (poll Poll, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var resp PollResponse
+			resp, err = ClosePoll(&vbeam.Context{Tx: tx, Token: token}, ClosePollRequest{PollId: pollId})
+			poll = resp.Poll
+		})
+		return
+	}
+	list := func(token string) (resp ListRoomPollsResponse, err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = ListRoomPolls(&vbeam.Context{Tx: tx, Token: token}, ListRoomPollsRequest{RoomId: room.Id})
+		})
+		return
+	}
+
+	// Only moderators run polls, and polls need a few real options
+	if _, err := create(memberToken, "Which piece next?", "Waltz", "Tango"); err == nil {
+		t.Errorf("Expected members to be refused")
+	}
+	if _, err := create(adminToken, "Which piece next?", "Waltz", "  "); err == nil {
+		t.Errorf("Expected a poll with one option to be refused")
+	}
+	poll, err := create(adminToken, "  Which piece next? ", "Waltz", "Tango", "Polka")
+	if err != nil {
+		t.Fatalf("CreatePoll failed: %v", err)
+	}
+	if poll.Question != "Which piece next?" || len(poll.Votes) != 3 || poll.MyVote != -1 {
+		t.Errorf("Unexpected poll: %+v", poll)
+	}
+	if _, err := create(adminToken, "Another?", "Yes", "No"); err == nil {
+		t.Errorf("Expected a second open poll to be refused")
+	}
+
+	// One vote per user or code session; voting again moves it
+	if _, err := vote(memberToken, poll.Id, 3); err == nil {
+		t.Errorf("Expected an out of range option to be refused")
+	}
+	if _, err := vote(memberToken, poll.Id, 0); err != nil {
+		t.Fatalf("VotePoll failed: %v", err)
+	}
+	if _, err := vote(guestToken, poll.Id, 0); err != nil {
+		t.Fatalf("Guest VotePoll failed: %v", err)
+	}
+	// Viewers who can't see the room can't vote or list its polls, including
+	// a code session for another room of the studio
+	for _, token := range []string{outsiderToken, otherRoomGuestToken} {
+		if _, err := vote(token, poll.Id, 0); err == nil {
+			t.Errorf("Expected a vote without room access to be refused")
+		}
+		if _, err := list(token); err == nil {
+			t.Errorf("Expected listing polls without room access to be refused")
+		}
+	}
+	poll, err = vote(memberToken, poll.Id, 1)
+	if err != nil {
+		t.Fatalf("VotePoll (change) failed: %v", err)
+	}
+	if poll.TotalVotes != 2 || poll.Votes[0] != 1 || poll.Votes[1] != 1 || poll.MyVote != 1 {
+		t.Errorf("Unexpected tally after changing a vote: %+v", poll)
+	}
+
+	// Two votes read the same tally before either is written (the guest moving
+	// theirs); neither is lost
+	adminTx, _ := db.Begin(false)
+	guestTx, _ := db.Begin(false)
+	if _, err := VotePoll(&vbeam.Context{Tx: adminTx, Token: adminToken}, VotePollRequest{PollId: poll.Id, Option: 0}); err != nil {
+		t.Fatalf("VotePoll failed: %v", err)
+	}
+	if _, err := VotePoll(&vbeam.Context{Tx: guestTx, Token: guestToken}, VotePollRequest{PollId: poll.Id, Option: 1}); err != nil {
+		t.Fatalf("Guest VotePoll failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		vbolt.Read(tx, PollsBkt, poll.Id, &poll)
+	})
+	if poll.TotalVotes != 3 || poll.Votes[0] != 1 || poll.Votes[1] != 2 {
+		t.Errorf("Expected concurrent votes to both count, got %+v", poll.Votes)
+	}
+
+	resp, err := list(guestToken)
+	if err != nil {
+		t.Fatalf("ListRoomPolls failed: %v", err)
+	}
+	if len(resp.Polls) != 1 || resp.Polls[0].MyVote != 1 || resp.CanManage {
+		t.Errorf("Unexpected guest poll list: %+v", resp)
+	}
+
+	// Closed polls keep their tally but take no more votes
+	if _, err := closePoll(memberToken, poll.Id); err == nil {
+		t.Errorf("Expected members to be refused closing a poll")
+	}
+	closed, err := closePoll(adminToken, poll.Id)
+	if err != nil {
+		t.Fatalf("ClosePoll failed: %v", err)
+	}
+	if !closed.IsClosed || closed.TotalVotes != 3 {
+		t.Errorf("Unexpected closed poll: %+v", closed)
+	}
+	if _, err := vote(guestToken, poll.Id, 2); err == nil {
+		t.Errorf("Expected votes on a closed poll to be refused")
+	}
+	if _, err := create(adminToken, "Another?", "Yes", "No"); err != nil {
+		t.Errorf("Expected a new poll once the last one closed: %v", err)
+	}
+
+	// Deleting the room removes its polls and votes
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		deleteRoomPollsTx(tx, room.Id)
+		vbolt.TxCommit(tx)
+	})
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		var voteKeys []string
+		vbolt.ReadTermTargets(tx, PollVotesByPollIdx, poll.Id, &voteKeys, vbolt.Window{})
+		if len(voteKeys) != 0 || len(sessionPolls(tx, room.Id)) != 0 {
+			t.Errorf("Expected polls and votes to be deleted")
+		}
+	})
+}
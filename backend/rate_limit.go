@@ -2,6 +2,7 @@ package backend
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -33,6 +34,7 @@ type RateLimitInfo struct {
 	Limited           bool
 	RetryAfterSeconds int
 	ViolationCount    int
+	Escalated         bool // this check started a new lockout
 	Message           string
 }
 
@@ -186,6 +188,7 @@ func (rl *RateLimiter) CheckLimitWithBackoff(limitType, identifier string, limit
 			Limited:           true,
 			RetryAfterSeconds: waitSeconds,
 			ViolationCount:    violation.count,
+			Escalated:         true,
 			Message: fmt.Sprintf("Rate limit exceeded. Try again in %d seconds (attempt %d of escalating lockout)",
 				waitSeconds, violation.count),
 		}
@@ -208,6 +211,35 @@ func (rl *RateLimiter) ResetViolations(limitType, identifier string) {
 	delete(rl.violations, key)
 }
 
+// ResetLimit clears both the attempts and the violations for a key
+func (rl *RateLimiter) ResetLimit(limitType, identifier string) {
+	key := fmt.Sprintf("%s:%s", limitType, identifier)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	delete(rl.entries, key)
+	delete(rl.violations, key)
+}
+
+// LockedOut returns the identifiers of a limit type that are currently locked
+// out by backoff, with when each lockout ends
+func (rl *RateLimiter) LockedOut(limitType string) map[string]time.Time {
+	prefix := limitType + ":"
+	now := time.Now()
+
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	locked := make(map[string]time.Time)
+	for key, violation := range rl.violations {
+		if strings.HasPrefix(key, prefix) && now.Before(violation.lockedUntil) {
+			locked[strings.TrimPrefix(key, prefix)] = violation.lockedUntil
+		}
+	}
+	return locked
+}
+
 // CheckCodeValidation checks rate limit for access code validation attempts
 // Limit: 5 attempts per IP per minute, with exponential backoff on violations
 func (rl *RateLimiter) CheckCodeValidation(ipAddress string) error {
@@ -241,6 +273,89 @@ func (rl *RateLimiter) CheckChatSend(identifier string) error {
 	return rl.CheckLimit("chat_send", identifier, 1, 2*time.Second)
 }
 
+// CheckChatReaction checks rate limit for reacting to chat messages
+// Limit: 10 reactions per 10 seconds per viewer
+func (rl *RateLimiter) CheckChatReaction(viewerID string) error {
+	return rl.CheckLimit("chat_reaction", viewerID, 10, 10*time.Second)
+}
+
+// CheckPollVote checks rate limit for voting (or changing a vote) in polls
+// Limit: 5 votes per 10 seconds per viewer
+func (rl *RateLimiter) CheckPollVote(viewerID string) error {
+	return rl.CheckLimit("poll_vote", viewerID, 5, 10*time.Second)
+}
+
+// CheckClassQuestion checks rate limit for asking questions during a class
+// Limit: 3 questions per minute per viewer
+func (rl *RateLimiter) CheckClassQuestion(viewerID string) error {
+	return rl.CheckLimit("class_question", viewerID, 3, time.Minute)
+}
+
+// CheckChatSlowMode checks a room's slow mode for one sender
+// Limit: 1 message per the room's slow mode interval per viewer
+func (rl *RateLimiter) CheckChatSlowMode(roomID int, viewerID string, seconds int) error {
+	return rl.CheckLimit("chat_slow_mode", fmt.Sprintf("%d:%s", roomID, viewerID), 1, time.Duration(seconds)*time.Second)
+}
+
+// CheckTicketCheckout checks rate limit for starting ticket checkouts
+// Limit: 5 checkouts per buyer email per 10 minutes
+func (rl *RateLimiter) CheckTicketCheckout(email string) error {
+	return rl.CheckLimit("ticket_checkout", email, 5, 10*time.Minute)
+}
+
+// CheckInviteRedemption checks rate limit for redeeming invite links
+// Limit: 20 redemptions per IP per minute (no backoff, invite tokens are signed)
+func (rl *RateLimiter) CheckInviteRedemption(ipAddress string) error {
+	return rl.CheckLimit("invite_redemption", ipAddress, 20, 1*time.Minute)
+}
+
+// CheckPasswordResetRequest checks rate limit for password reset emails
+// Limit: 3 requests per email per hour
+func (rl *RateLimiter) CheckPasswordResetRequest(email string) error {
+	return rl.CheckLimit("password_reset", email, 3, 1*time.Hour)
+}
+
+// CheckVerificationEmail checks rate limit for resending verification emails
+// Limit: 3 emails per user per hour
+func (rl *RateLimiter) CheckVerificationEmail(userID int) error {
+	return rl.CheckLimit("verification_email", fmt.Sprintf("%d", userID), 3, 1*time.Hour)
+}
+
+// CheckStudioInvitation checks rate limit for sending studio invitations
+// Limit: 20 invitations per user per hour
+func (rl *RateLimiter) CheckStudioInvitation(userID int) error {
+	return rl.CheckLimit("studio_invitation", fmt.Sprintf("%d", userID), 20, 1*time.Hour)
+}
+
+// CheckTwoFactorAttempt checks rate limit for two-factor code attempts
+// Limit: 5 attempts per user per 5 minutes
+func (rl *RateLimiter) CheckTwoFactorAttempt(userID int) error {
+	return rl.CheckLimit("two_factor_attempt", fmt.Sprintf("%d", userID), 5, 5*time.Minute)
+}
+
+// CheckLoginAccount checks rate limit for password login attempts on an account
+// Limit: 5 attempts per email per 15 minutes, with exponential backoff on violations
+func (rl *RateLimiter) CheckLoginAccount(email string) *RateLimitInfo {
+	return rl.CheckLimitWithBackoff("login_account", email, 5, 15*time.Minute)
+}
+
+// CheckLoginIP checks rate limit for password login attempts from an IP
+// Limit: 20 attempts per IP per 15 minutes, with exponential backoff on violations
+func (rl *RateLimiter) CheckLoginIP(ipAddress string) *RateLimitInfo {
+	return rl.CheckLimitWithBackoff("login_ip", ipAddress, 20, 15*time.Minute)
+}
+
+// ResetLoginAccount clears an account's login attempts and lockout
+// Called after a successful login or an admin unlock
+func (rl *RateLimiter) ResetLoginAccount(email string) {
+	rl.ResetLimit("login_account", email)
+}
+
+// LockedLoginAccounts returns the emails currently locked out of password login
+func (rl *RateLimiter) LockedLoginAccounts() map[string]time.Time {
+	return rl.LockedOut("login_account")
+}
+
 // cleanupLoop runs periodically to remove expired entries and free memory
 func (rl *RateLimiter) cleanupLoop() {
 	ticker := time.NewTicker(5 * time.Minute)
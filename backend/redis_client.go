@@ -0,0 +1,302 @@
+package backend
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redisDialTimeout    = 5 * time.Second
+	redisCommandTimeout = 5 * time.Second
+	redisMaxBackoff     = 30 * time.Second
+	redisPingInterval   = 15 * time.Second
+)
+
+// redisClient runs commands on one Redis connection, reconnecting when it
+// breaks. It speaks just enough of the Redis protocol for the event bus and
+// the presence store.
+type redisClient struct {
+	Addr string
+
+	useTLS   bool
+	username string
+	password string
+	db       int
+
+	mu     sync.Mutex // Guards the command connection
+	conn   *redisConn
+	closed chan struct{}
+}
+
+// newRedisClient connects to the server in redisURL
+// (redis://[user:password@]host:port[/db], or rediss:// for TLS)
+func newRedisClient(redisURL string) (*redisClient, error) {
+	u, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid REDIS_URL: unsupported scheme %q", u.Scheme)
+	}
+
+	c := &redisClient{
+		Addr:   u.Host,
+		useTLS: u.Scheme == "rediss",
+		closed: make(chan struct{}),
+	}
+	if u.Port() == "" {
+		c.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		c.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL: bad database %q", db)
+		}
+	}
+
+	// Connect now so a bad address or password shows up at startup
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn, err = c.dial(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *redisClient) Close() error {
+	select {
+	case <-c.closed:
+		return nil
+	default:
+		close(c.closed)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return nil
+}
+
+func (c *redisClient) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// do runs a command, reconnecting and retrying once if the connection broke
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.isClosed() {
+			return nil, errors.New("redis: client closed")
+		}
+		if c.conn == nil {
+			conn, err := c.dial()
+			if err != nil {
+				return nil, err
+			}
+			c.conn = conn
+		}
+
+		reply, err := c.conn.command(args...)
+		var replyErr redisError
+		if err == nil || errors.As(err, &replyErr) {
+			return reply, err
+		}
+		// Network error: drop the connection and try a fresh one
+		c.conn.Close()
+		c.conn = nil
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// pipeline sends several commands in one round trip and returns their
+// replies in order. Error replies come back as redisError values.
+func (c *redisClient) pipeline(commands [][]string) ([]interface{}, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.isClosed() {
+			return nil, errors.New("redis: client closed")
+		}
+		if c.conn == nil {
+			conn, err := c.dial()
+			if err != nil {
+				return nil, err
+			}
+			c.conn = conn
+		}
+
+		replies, err := c.conn.pipeline(commands)
+		if err == nil {
+			return replies, nil
+		}
+		// Network error: drop the connection and try a fresh one
+		c.conn.Close()
+		c.conn = nil
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// dial connects and authenticates
+func (c *redisClient) dial() (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var netConn net.Conn
+	var err error
+	if c.useTLS {
+		host, _, _ := net.SplitHostPort(c.Addr)
+		netConn, err = tls.DialWithDialer(dialer, "tcp", c.Addr, &tls.Config{ServerName: host})
+	} else {
+		netConn, err = dialer.Dial("tcp", c.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := conn.command(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.command("SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis select: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// redisError is an error reply from the server (as opposed to a network error)
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisConn is one connection speaking RESP
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// command sends a command and reads its reply
+func (c *redisConn) command(args ...string) (interface{}, error) {
+	c.SetDeadline(time.Now().Add(redisCommandTimeout))
+	if err := c.write(args...); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// pipeline sends commands and then reads all their replies
+func (c *redisConn) pipeline(commands [][]string) ([]interface{}, error) {
+	c.SetDeadline(time.Now().Add(redisCommandTimeout))
+	for _, args := range commands {
+		if err := c.write(args...); err != nil {
+			return nil, err
+		}
+	}
+	replies := make([]interface{}, len(commands))
+	for i := range replies {
+		reply, err := c.readReply()
+		var replyErr redisError
+		if errors.As(err, &replyErr) {
+			reply = replyErr
+		} else if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// write sends a command without waiting for the reply
+func (c *redisConn) write(args ...string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(c.Conn, sb.String())
+	return err
+}
+
+// readReply reads one reply: a string, int64, []interface{}, nil or redisError
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2) // Includes the trailing \r\n
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,230 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const DEFAULT_SSE_EVENT_CHANNEL = "stream:sse"
+
+// RedisEventBus shares SSE events between app instances over Redis pub/sub.
+// Event IDs come from an INCR counter so they line up on every instance and
+// a viewer can resume on any of them.
+type RedisEventBus struct {
+	*redisClient
+	Channel string
+
+	// The subscription is pinged this often, and given up on when nothing
+	// arrives for twice as long, so a half-open connection is noticed
+	pingInterval time.Duration
+
+	subMu       sync.Mutex
+	subConn     *redisConn
+	subscribers []busSubscriber
+}
+
+// busSubscriber is one Subscribe call's callbacks
+type busSubscriber struct {
+	deliver func(BusEvent)
+	gap     func(nextEventID int)
+}
+
+// NewRedisEventBus connects to the server in redisURL (see newRedisClient)
+// and publishes on channel (DEFAULT_SSE_EVENT_CHANNEL when empty).
+func NewRedisEventBus(redisURL string, channel string) (*RedisEventBus, error) {
+	if channel == "" {
+		channel = DEFAULT_SSE_EVENT_CHANNEL
+	}
+	client, err := newRedisClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisEventBus{
+		redisClient:  client,
+		Channel:      channel,
+		pingInterval: redisPingInterval,
+	}, nil
+}
+
+func (b *RedisEventBus) counterKey() string {
+	return b.Channel + ":event_id"
+}
+
+func (b *RedisEventBus) NextEventID() (int, error) {
+	reply, err := b.do("INCR", b.counterKey())
+	if err != nil {
+		return 0, err
+	}
+	id, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected INCR reply %v", reply)
+	}
+	return int(id), nil
+}
+
+func (b *RedisEventBus) Publish(event BusEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = b.do("PUBLISH", b.Channel, string(payload))
+	return err
+}
+
+// Subscribe adds a handler for the channel's events. The first call opens the
+// subscription and returns once Redis has confirmed it; after that a
+// background goroutine receives events and reconnects when the connection
+// drops, calling gap since events published meanwhile are lost. Handlers run
+// on that goroutine, one event at a time.
+func (b *RedisEventBus) Subscribe(deliver func(BusEvent), gap func(nextEventID int)) error {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	if b.subConn == nil {
+		conn, err := b.subscribe()
+		if err != nil {
+			return err
+		}
+		b.subConn = conn
+		go b.receive(conn)
+	}
+	b.subscribers = append(b.subscribers, busSubscriber{deliver: deliver, gap: gap})
+	return nil
+}
+
+func (b *RedisEventBus) Close() error {
+	b.redisClient.Close()
+
+	b.subMu.Lock()
+	if b.subConn != nil {
+		b.subConn.Close()
+	}
+	b.subMu.Unlock()
+	return nil
+}
+
+// subscribe opens a connection subscribed to the channel
+func (b *RedisEventBus) subscribe() (*redisConn, error) {
+	conn, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.command("SUBSCRIBE", b.Channel); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("redis subscribe: %w", err)
+	}
+	return conn, nil
+}
+
+// receive hands incoming events to the handlers until the bus is closed,
+// resubscribing with backoff whenever the connection drops. Events published
+// while disconnected are missed, so handlers are told of the gap with an ID
+// taken after resubscribing, which is newer than any of the missed ones.
+func (b *RedisEventBus) receive(conn *redisConn) {
+	backoff := time.Second
+	for {
+		stop := make(chan struct{})
+		go b.keepAlive(conn, stop)
+		err := b.readEvents(conn)
+		close(stop)
+		conn.Close()
+		if b.isClosed() {
+			return
+		}
+		LogWarn(LogCategorySystem, "Redis event bus subscription lost", map[string]interface{}{
+			"addr":  b.Addr,
+			"error": err.Error(),
+		})
+
+		var nextEventID int
+		for {
+			select {
+			case <-b.closed:
+				return
+			case <-time.After(backoff):
+			}
+
+			conn, err = b.subscribe()
+			if err == nil {
+				if nextEventID, err = b.NextEventID(); err == nil {
+					break
+				}
+				conn.Close()
+			}
+			backoff = min(backoff*2, redisMaxBackoff)
+		}
+		backoff = time.Second
+
+		b.subMu.Lock()
+		b.subConn = conn
+		b.subMu.Unlock()
+		if b.isClosed() {
+			conn.Close()
+			return
+		}
+		LogInfo(LogCategorySystem, "Redis event bus resubscribed", map[string]interface{}{
+			"addr":        b.Addr,
+			"nextEventId": nextEventID,
+		})
+
+		for _, sub := range b.currentSubscribers() {
+			if sub.gap != nil {
+				sub.gap(nextEventID)
+			}
+		}
+	}
+}
+
+func (b *RedisEventBus) currentSubscribers() []busSubscriber {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+	return b.subscribers
+}
+
+// keepAlive pings the subscription until stop is closed. Replies arrive as
+// pong messages, which keep readEvents' read deadline from running out.
+func (b *RedisEventBus) keepAlive(conn *redisConn, stop chan struct{}) {
+	ticker := time.NewTicker(b.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		conn.SetWriteDeadline(time.Now().Add(redisCommandTimeout))
+		if err := conn.write("PING"); err != nil {
+			conn.Close() // Unblocks readEvents
+			return
+		}
+	}
+}
+
+func (b *RedisEventBus) readEvents(conn *redisConn) error {
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * b.pingInterval))
+		reply, err := conn.readReply()
+		if err != nil {
+			return err
+		}
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 3 || parts[0] != "message" {
+			continue // Subscription confirmations and the like
+		}
+		payload, _ := parts[2].(string)
+
+		var event BusEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			LogWarn(LogCategorySystem, "Ignoring malformed SSE bus event", map[string]interface{}{
+				"error": err.Error(),
+			})
+			continue
+		}
+
+		for _, sub := range b.currentSubscribers() {
+			sub.deliver(event)
+		}
+	}
+}
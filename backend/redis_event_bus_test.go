@@ -0,0 +1,398 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a local server speaking just the Redis commands RedisEventBus
+// uses, with real pub/sub fan-out between its connections
+type fakeRedisValue struct {
+	data      string
+	expiresAt time.Time
+}
+
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	counters map[string]int
+	values   map[string]fakeRedisValue
+	sets     map[string]map[string]bool
+	subs     map[string][]net.Conn
+	conns    []net.Conn
+	silent   map[net.Conn]bool
+}
+
+func startFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	f := &fakeRedis{listener: listener, counters: map[string]int{}, values: map[string]fakeRedisValue{}, sets: map[string]map[string]bool{}, subs: map[string][]net.Conn{}, silent: map[net.Conn]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns = append(f.conns, conn)
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func (f *fakeRedis) URL() string {
+	return "redis://" + f.listener.Addr().String()
+}
+
+// get returns a value that hasn't expired
+func (f *fakeRedis) get(key string) (string, bool) {
+	value, ok := f.values[key]
+	if !ok || (!value.expiresAt.IsZero() && !value.expiresAt.After(time.Now())) {
+		delete(f.values, key)
+		return "", false
+	}
+	return value.data, true
+}
+
+// expire drops values as if their TTL had run out
+func (f *fakeRedis) expire(keys ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		delete(f.values, key)
+	}
+}
+
+// dropConnections disconnects every client, as a Redis restart would
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+	f.subs = map[string][]net.Conn{}
+}
+
+// silenceSubscriptions stops answering subscribed clients without closing
+// their connections, like a network path that died without a reset
+func (f *fakeRedis) silenceSubscriptions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, subs := range f.subs {
+		for _, conn := range subs {
+			f.silent[conn] = true
+		}
+	}
+	f.subs = map[string][]net.Conn{}
+}
+
+func (f *fakeRedis) Close() {
+	f.listener.Close()
+	f.dropConnections()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	c := &redisConn{Conn: conn, reader: bufio.NewReader(conn)}
+	for {
+		reply, err := c.readReply()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		parts, _ := reply.([]interface{})
+		args := make([]string, len(parts))
+		for i, part := range parts {
+			args[i], _ = part.(string)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		f.mu.Lock()
+		if f.silent[conn] {
+			f.mu.Unlock()
+			continue
+		}
+		switch strings.ToUpper(args[0]) {
+		case "INCR":
+			f.counters[args[1]]++
+			fmt.Fprintf(conn, ":%d\r\n", f.counters[args[1]])
+		case "PUBLISH":
+			message := fmt.Sprintf("*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(args[1]), args[1], len(args[2]), args[2])
+			for _, sub := range f.subs[args[1]] {
+				fmt.Fprint(sub, message)
+			}
+			fmt.Fprintf(conn, ":%d\r\n", len(f.subs[args[1]]))
+		case "SUBSCRIBE":
+			f.subs[args[1]] = append(f.subs[args[1]], conn)
+			fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+		case "SET":
+			value := fakeRedisValue{data: args[2]}
+			if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+				ms, _ := strconv.Atoi(args[4])
+				value.expiresAt = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			f.values[args[1]] = value
+			fmt.Fprint(conn, "+OK\r\n")
+		case "GET":
+			value, ok := f.get(args[1])
+			writeFakeBulk(conn, value, ok)
+		case "MGET":
+			fmt.Fprintf(conn, "*%d\r\n", len(args)-1)
+			for _, key := range args[1:] {
+				value, ok := f.get(key)
+				writeFakeBulk(conn, value, ok)
+			}
+		case "DEL":
+			deleted := 0
+			if _, ok := f.get(args[1]); ok {
+				delete(f.values, args[1])
+				deleted = 1
+			}
+			fmt.Fprintf(conn, ":%d\r\n", deleted)
+		case "SADD":
+			if f.sets[args[1]] == nil {
+				f.sets[args[1]] = map[string]bool{}
+			}
+			added := 0
+			for _, member := range args[2:] {
+				if !f.sets[args[1]][member] {
+					f.sets[args[1]][member] = true
+					added++
+				}
+			}
+			fmt.Fprintf(conn, ":%d\r\n", added)
+		case "SREM":
+			removed := 0
+			for _, member := range args[2:] {
+				if f.sets[args[1]][member] {
+					delete(f.sets[args[1]], member)
+					removed++
+				}
+			}
+			fmt.Fprintf(conn, ":%d\r\n", removed)
+		case "SMEMBERS":
+			fmt.Fprintf(conn, "*%d\r\n", len(f.sets[args[1]]))
+			for member := range f.sets[args[1]] {
+				writeFakeBulk(conn, member, true)
+			}
+		case "SCARD":
+			fmt.Fprintf(conn, ":%d\r\n", len(f.sets[args[1]]))
+		case "PING":
+			// Subscribed connections get a pong message (they're all subscribed here)
+			fmt.Fprint(conn, "*2\r\n$4\r\npong\r\n$0\r\n\r\n")
+		default:
+			fmt.Fprint(conn, "-ERR unknown command\r\n")
+		}
+		f.mu.Unlock()
+	}
+}
+
+func writeFakeBulk(conn net.Conn, value string, ok bool) {
+	if !ok {
+		fmt.Fprint(conn, "$-1\r\n")
+		return
+	}
+	fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+}
+
+// syncWriter is an SSE client writer that's safe to read while the bus
+// delivers to it from another goroutine
+type syncWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *syncWriter) Header() http.Header        { return http.Header{} }
+func (w *syncWriter) WriteHeader(statusCode int) {}
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *syncWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRedisEventBus(t *testing.T) {
+	server := startFakeRedis(t)
+
+	// Two app instances sharing the bus
+	newInstance := func() *SSEManager {
+		bus, err := NewRedisEventBus(server.URL(), "test:sse")
+		if err != nil {
+			t.Fatalf("NewRedisEventBus failed: %v", err)
+		}
+		m := newSSEManager()
+		if err := m.UseEventBus(bus); err != nil {
+			t.Fatalf("UseEventBus failed: %v", err)
+		}
+		t.Cleanup(func() { bus.Close() })
+		return m
+	}
+	a := newInstance()
+	b := newInstance()
+
+	newClient := func(sessionToken string, viewerId string) (*SSEClient, *syncWriter) {
+		w := &syncWriter{}
+		return &SSEClient{RoomID: 1, Writer: w, Done: make(chan bool), SessionToken: sessionToken, ViewerId: viewerId}, w
+	}
+	onA, onAWriter := newClient("", "user:1")
+	guestOnB, guestOnBWriter := newClient("session-1", "code:session-1")
+	otherOnB, otherOnBWriter := newClient("session-2", "code:session-2")
+	a.AddClient(1, onA)
+	b.AddClient(1, guestOnB)
+	b.AddClient(1, otherOnB)
+	for _, client := range []*SSEClient{onA, guestOnB, otherOnB} {
+		pumpTestClient(t, client)
+	}
+
+	// A chat message sent through one instance reaches viewers on both
+	a.BroadcastChatMessage(1, ChatMessage{Id: 1, RoomId: 1, Text: "hello everyone"})
+	waitFor(t, "chat on both instances", func() bool {
+		return strings.Contains(onAWriter.String(), "hello everyone") &&
+			strings.Contains(guestOnBWriter.String(), "hello everyone") &&
+			strings.Contains(otherOnBWriter.String(), "hello everyone")
+	})
+
+	// Both instances keep it for replay under the same ID, so a viewer can
+	// resume on either
+	waitFor(t, "replay history", func() bool {
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return a.history[1] != nil && b.history[1] != nil
+	})
+	a.mu.RLock()
+	eventId := a.history[1].events[0].Id
+	a.mu.RUnlock()
+	b.mu.RLock()
+	if b.history[1].events[0].Id != eventId || b.lastEventID != eventId {
+		t.Errorf("Expected instance B to record event %d, got %+v", eventId, b.history[1].events)
+	}
+	b.mu.RUnlock()
+	if !strings.Contains(guestOnBWriter.String(), fmt.Sprintf("id: %d\n", eventId)) {
+		t.Errorf("Expected the event to carry its shared ID, got %q", guestOnBWriter.String())
+	}
+	b.BroadcastEmote(1, "👍")
+	waitFor(t, "emote from B on A", func() bool {
+		return strings.Contains(onAWriter.String(), "👍")
+	})
+	if _, ok := a.AddClientSince(1, &SSEClient{RoomID: 1, Writer: &syncWriter{}, Done: make(chan bool)}, eventId); !ok {
+		t.Errorf("Expected an ID issued through the other instance to be resumable")
+	}
+
+	// Revocations and restrictions only reach their targets, wherever they are
+	a.BroadcastCodeRevoked(1, []string{"session-1"})
+	a.BroadcastChatRestricted(1, ChatRestriction{Id: 1, ViewerId: "code:session-2", Kind: ChatRestrictionTimeout})
+	waitFor(t, "targeted events", func() bool {
+		return strings.Contains(guestOnBWriter.String(), "code_revoked") &&
+			strings.Contains(otherOnBWriter.String(), "chat_restricted")
+	})
+	if strings.Contains(otherOnBWriter.String(), "code_revoked") || strings.Contains(onAWriter.String(), "code_revoked") {
+		t.Errorf("Expected only the revoked session to be told")
+	}
+	if strings.Contains(guestOnBWriter.String(), "chat_restricted") || strings.Contains(onAWriter.String(), "chat_restricted") {
+		t.Errorf("Expected only the restricted viewer to be told")
+	}
+
+	// Both instances reconnect after losing Redis. Events published in
+	// between were missed, so connected viewers reload and IDs from before
+	// can't be resumed
+	server.dropConnections()
+	waitFor(t, "status after reconnecting", func() bool {
+		a.BroadcastRoomStatus(1, true, true)
+		return strings.Contains(guestOnBWriter.String(), "event: status")
+	})
+	waitFor(t, "resync on both instances", func() bool {
+		return strings.Contains(onAWriter.String(), "event: resync") &&
+			strings.Contains(guestOnBWriter.String(), "event: resync")
+	})
+	for name, m := range map[string]*SSEManager{"A": a, "B": b} {
+		missed, ok := m.AddClientSince(1, &SSEClient{RoomID: 1, Writer: &syncWriter{}, Done: make(chan bool)}, eventId)
+		if ok {
+			t.Errorf("Expected instance %s not to resume from before the gap, replayed %d", name, len(missed))
+		}
+	}
+}
+
+func TestRedisEventBusHalfOpen(t *testing.T) {
+	server := startFakeRedis(t)
+
+	bus, err := NewRedisEventBus(server.URL(), "test:sse")
+	if err != nil {
+		t.Fatalf("NewRedisEventBus failed: %v", err)
+	}
+	t.Cleanup(func() { bus.Close() })
+	bus.pingInterval = 50 * time.Millisecond
+	m := newSSEManager()
+	if err := m.UseEventBus(bus); err != nil {
+		t.Fatalf("UseEventBus failed: %v", err)
+	}
+	w := &syncWriter{}
+	client := &SSEClient{RoomID: 1, Writer: w, Done: make(chan bool)}
+	m.AddClient(1, client)
+	pumpTestClient(t, client)
+
+	// Pings keep a quiet subscription alive
+	time.Sleep(5 * bus.pingInterval)
+	if strings.Contains(w.String(), "event: resync") {
+		t.Fatalf("Expected a healthy subscription to stay up, got %q", w.String())
+	}
+
+	// A connection that stops answering is noticed and replaced
+	server.silenceSubscriptions()
+	waitFor(t, "resync after the connection went silent", func() bool {
+		return strings.Contains(w.String(), "event: resync")
+	})
+	waitFor(t, "emote after resubscribing", func() bool {
+		m.BroadcastEmote(1, "👋")
+		return strings.Contains(w.String(), "👋")
+	})
+}
+
+func TestRedisEventBusBadURL(t *testing.T) {
+	if _, err := NewRedisEventBus("http://localhost:6379", ""); err == nil {
+		t.Errorf("Expected a non-redis URL to be refused")
+	}
+	if _, err := NewRedisEventBus("redis://localhost:6379/abc", ""); err == nil {
+		t.Errorf("Expected a bad database number to be refused")
+	}
+
+	// Nothing listening
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close()
+	if _, err := NewRedisEventBus("redis://"+addr, ""); err == nil {
+		t.Errorf("Expected an unreachable server to fail at startup")
+	}
+}
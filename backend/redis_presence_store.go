@@ -0,0 +1,257 @@
+package backend
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"go.hasen.dev/vbolt"
+)
+
+// RedisPresenceStore keeps viewer presence in Redis so every instance sees
+// the same entries. Each entry is a key that Redis expires a TTL after the
+// entry itself does, which leaves the heartbeat time to recount its room and
+// code first. Sets index the keys by room and code, and list the rooms and
+// codes that have any; keys Redis expired are pruned from them on read.
+// Each method batches its commands into one or two round trips.
+type RedisPresenceStore struct {
+	client *redisClient
+	prefix string
+}
+
+// NewRedisPresenceStore connects to the server in redisURL and keeps keys
+// under prefix
+func NewRedisPresenceStore(redisURL string, prefix string) (*RedisPresenceStore, error) {
+	client, err := newRedisClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisPresenceStore{client: client, prefix: prefix}, nil
+}
+
+func (s *RedisPresenceStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisPresenceStore) entryKey(key string) string {
+	return s.prefix + ":entry:" + key
+}
+
+func (s *RedisPresenceStore) roomSetKey(roomId int) string {
+	return s.prefix + ":room:" + strconv.Itoa(roomId)
+}
+
+func (s *RedisPresenceStore) codeSetKey(code string) string {
+	return s.prefix + ":code:" + code
+}
+
+func (s *RedisPresenceStore) roomsKey() string {
+	return s.prefix + ":rooms"
+}
+
+func (s *RedisPresenceStore) codesKey() string {
+	return s.prefix + ":codes"
+}
+
+// run sends commands in one round trip, logging failures. Presence is best
+// effort: counts repair themselves on the next heartbeat once Redis is back.
+func (s *RedisPresenceStore) run(commands [][]string) []interface{} {
+	replies, err := s.client.pipeline(commands)
+	if err != nil {
+		LogWarn(LogCategorySystem, "Redis presence commands failed", map[string]interface{}{
+			"command": commands[0][0],
+			"count":   len(commands),
+			"error":   err.Error(),
+		})
+		return make([]interface{}, len(commands))
+	}
+	return replies
+}
+
+func (s *RedisPresenceStore) Read(db *vbolt.DB, key string) (presence ViewerPresence) {
+	replies := s.run([][]string{{"GET", s.entryKey(key)}})
+	if data, ok := replies[0].(string); ok {
+		json.Unmarshal([]byte(data), &presence)
+	}
+	return
+}
+
+func (s *RedisPresenceStore) Write(db *vbolt.DB, entries []ViewerPresence) {
+	var commands [][]string
+	for _, p := range entries {
+		data, err := json.Marshal(p)
+		if err != nil {
+			continue
+		}
+		ttl := time.Until(p.ExpiresAt) + PRESENCE_TTL
+		commands = append(commands,
+			[]string{"SET", s.entryKey(p.Key), string(data), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)},
+			[]string{"SADD", s.roomSetKey(p.RoomId), p.Key},
+			[]string{"SADD", s.roomsKey(), strconv.Itoa(p.RoomId)},
+		)
+		if p.Code != "" {
+			commands = append(commands,
+				[]string{"SADD", s.codeSetKey(p.Code), p.Key},
+				[]string{"SADD", s.codesKey(), p.Code},
+			)
+		}
+	}
+	if len(commands) > 0 {
+		s.run(commands)
+	}
+}
+
+func (s *RedisPresenceStore) Delete(db *vbolt.DB, entries []ViewerPresence) {
+	var removals []setRemoval
+	var commands [][]string
+	for _, p := range entries {
+		commands = append(commands, []string{"DEL", s.entryKey(p.Key)})
+		removals = append(removals, setRemoval{s.roomSetKey(p.RoomId), p.Key, s.roomsKey(), strconv.Itoa(p.RoomId)})
+		if p.Code != "" {
+			removals = append(removals, setRemoval{s.codeSetKey(p.Code), p.Key, s.codesKey(), p.Code})
+		}
+	}
+	if len(commands) > 0 {
+		s.run(commands)
+	}
+	s.removeFromSets(removals)
+}
+
+func (s *RedisPresenceStore) RoomsPresence(db *vbolt.DB, roomIds []int) map[int][]ViewerPresence {
+	sets := make([]presenceSet, len(roomIds))
+	for i, roomId := range roomIds {
+		sets[i] = presenceSet{s.roomSetKey(roomId), s.roomsKey(), strconv.Itoa(roomId)}
+	}
+	result := make(map[int][]ViewerPresence)
+	for i, entries := range s.readSets(sets) {
+		result[roomIds[i]] = entries
+	}
+	return result
+}
+
+func (s *RedisPresenceStore) CodesPresence(db *vbolt.DB, codes []string) map[string][]ViewerPresence {
+	sets := make([]presenceSet, len(codes))
+	for i, code := range codes {
+		sets[i] = presenceSet{s.codeSetKey(code), s.codesKey(), code}
+	}
+	result := make(map[string][]ViewerPresence)
+	for i, entries := range s.readSets(sets) {
+		result[codes[i]] = entries
+	}
+	return result
+}
+
+func (s *RedisPresenceStore) Terms(db *vbolt.DB) (roomIds []int, codes []string) {
+	replies := s.run([][]string{{"SMEMBERS", s.roomsKey()}, {"SMEMBERS", s.codesKey()}})
+	for _, member := range replyStrings(replies[0]) {
+		if roomId, err := strconv.Atoi(member); err == nil {
+			roomIds = append(roomIds, roomId)
+		}
+	}
+	codes = replyStrings(replies[1])
+	return
+}
+
+// presenceSet is a room's or code's set of entry keys, and its member in the
+// list of rooms or codes
+type presenceSet struct {
+	key     string
+	listKey string
+	member  string
+}
+
+// readSets reads the entries of several sets: one round trip for the sets'
+// keys and one for the entries. Keys Redis has expired are pruned.
+func (s *RedisPresenceStore) readSets(sets []presenceSet) [][]ViewerPresence {
+	result := make([][]ViewerPresence, len(sets))
+	if len(sets) == 0 {
+		return result
+	}
+	var commands [][]string
+	for _, set := range sets {
+		commands = append(commands, []string{"SMEMBERS", set.key})
+	}
+	setKeys := make([][]string, len(sets))
+	mget := []string{"MGET"}
+	for i, reply := range s.run(commands) {
+		setKeys[i] = replyStrings(reply)
+		for _, key := range setKeys[i] {
+			mget = append(mget, s.entryKey(key))
+		}
+	}
+	if len(mget) == 1 {
+		return result
+	}
+	values, _ := s.run([][]string{mget})[0].([]interface{})
+	if len(values) != len(mget)-1 {
+		return result
+	}
+
+	var gone []setRemoval
+	next := 0
+	for i, set := range sets {
+		for _, key := range setKeys[i] {
+			data, ok := values[next].(string)
+			next++
+			if !ok {
+				gone = append(gone, setRemoval{set.key, key, set.listKey, set.member})
+				continue
+			}
+			var presence ViewerPresence
+			if json.Unmarshal([]byte(data), &presence) == nil {
+				result[i] = append(result[i], presence)
+			}
+		}
+	}
+	s.removeFromSets(gone)
+	return result
+}
+
+// setRemoval takes an entry key out of a set
+type setRemoval struct {
+	setKey  string
+	key     string
+	listKey string
+	member  string
+}
+
+// removeFromSets removes keys from their sets, then takes sets left empty off
+// their list. A writer racing the emptying puts it back on its next heartbeat.
+func (s *RedisPresenceStore) removeFromSets(removals []setRemoval) {
+	if len(removals) == 0 {
+		return
+	}
+	var commands [][]string
+	emptied := make(map[string]presenceSet)
+	for _, r := range removals {
+		commands = append(commands, []string{"SREM", r.setKey, r.key})
+		emptied[r.setKey] = presenceSet{r.setKey, r.listKey, r.member}
+	}
+	var candidates []presenceSet
+	for _, set := range emptied {
+		candidates = append(candidates, set)
+		commands = append(commands, []string{"SCARD", set.key})
+	}
+	replies := s.run(commands)
+
+	var cleanup [][]string
+	for i, set := range candidates {
+		if remaining, ok := replies[len(removals)+i].(int64); ok && remaining == 0 {
+			cleanup = append(cleanup, []string{"SREM", set.listKey, set.member})
+		}
+	}
+	if len(cleanup) > 0 {
+		s.run(cleanup)
+	}
+}
+
+// replyStrings reads an array reply of strings
+func replyStrings(reply interface{}) (result []string) {
+	items, _ := reply.([]interface{})
+	for _, item := range items {
+		if str, ok := item.(string); ok {
+			result = append(result, str)
+		}
+	}
+	return
+}
@@ -3,6 +3,7 @@ package backend
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"stream/cfg"
 	"time"
 
@@ -10,25 +11,48 @@ import (
 	"go.hasen.dev/vpack"
 )
 
-// RefreshToken represents a long-lived token for persistent login
+const (
+	REFRESH_TOKEN_TTL = 30 * 24 * time.Hour
+
+	// A just-rotated token is still honoured this long, so two tabs refreshing
+	// at the same moment don't look like token theft
+	REFRESH_TOKEN_REUSE_GRACE = 30 * time.Second
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// RefreshToken represents a long-lived token for persistent login. Each record is
+// one signed-in device: the token string is rotated on every refresh but the Id
+// stays the same, and auth JWTs carry it as the session id.
 type RefreshToken struct {
 	Id         int       `json:"id"`
 	UserId     int       `json:"userId"`
 	Token      string    `json:"token"`
 	ExpiresAt  time.Time `json:"expiresAt"`
-	CreatedAt  time.Time `json:"createdAt"`
+	CreatedAt  time.Time `json:"createdAt"` // when the device signed in
 	LastUsedAt time.Time `json:"lastUsedAt"`
+
+	UserAgent     string    `json:"userAgent"`
+	IPAddress     string    `json:"ipAddress"`
+	PreviousToken string    `json:"-"` // token replaced by the last rotation
+	RotatedAt     time.Time `json:"rotatedAt"`
 }
 
 // PackRefreshToken serializes a RefreshToken for vbolt storage
 func PackRefreshToken(self *RefreshToken, buf *vpack.Buffer) {
-	vpack.Version(1, buf)
+	version := vpack.Version(2, buf)
 	vpack.Int(&self.Id, buf)
 	vpack.Int(&self.UserId, buf)
 	vpack.String(&self.Token, buf)
 	vpack.Time(&self.ExpiresAt, buf)
 	vpack.Time(&self.CreatedAt, buf)
 	vpack.Time(&self.LastUsedAt, buf)
+	if version >= 2 {
+		vpack.String(&self.UserAgent, buf)
+		vpack.String(&self.IPAddress, buf)
+		vpack.String(&self.PreviousToken, buf)
+		vpack.Time(&self.RotatedAt, buf)
+	}
 }
 
 // Buckets for refresh token storage
@@ -40,6 +64,12 @@ var RefreshTokenByTokenBkt = vbolt.Bucket(&cfg.Info, "refresh_tokens_by_token",
 // user id => token ids (for tracking user's tokens)
 var RefreshTokenByUserIndex = vbolt.Index(&cfg.Info, "refresh_tokens_by_user", vpack.FInt, vpack.FInt)
 
+// rotated-away token string => token id, to detect a stolen token being replayed
+var RotatedRefreshTokensBkt = vbolt.Bucket(&cfg.Info, "rotated_refresh_tokens", vpack.StringZ, vpack.Int)
+
+// token id => rotated-away token strings (deleted along with the token)
+var RotatedRefreshTokensByIdIdx = vbolt.Index(&cfg.Info, "rotated_refresh_tokens_by_id", vpack.FInt, vpack.StringZ)
+
 // generateRefreshToken creates a cryptographically secure random token string
 func generateRefreshToken() (string, error) {
 	b := make([]byte, 32) // 32 bytes = 64 hex characters
@@ -50,8 +80,9 @@ func generateRefreshToken() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-// CreateRefreshToken generates and stores a new refresh token for a user
-func CreateRefreshToken(tx *vbolt.Tx, userId int, expiryDuration time.Duration) (RefreshToken, error) {
+// CreateRefreshToken generates and stores a new refresh token for a user, recording
+// the device it was issued to
+func CreateRefreshToken(tx *vbolt.Tx, userId int, expiryDuration time.Duration, userAgent string, ipAddress string) (RefreshToken, error) {
 	tokenString, err := generateRefreshToken()
 	if err != nil {
 		return RefreshToken{}, err
@@ -64,6 +95,8 @@ func CreateRefreshToken(tx *vbolt.Tx, userId int, expiryDuration time.Duration)
 		ExpiresAt:  time.Now().Add(expiryDuration),
 		CreatedAt:  time.Now(),
 		LastUsedAt: time.Now(),
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
 	}
 
 	// Store token in database
@@ -93,16 +126,18 @@ func GetRefreshTokenByToken(tx *vbolt.Tx, tokenString string) (RefreshToken, boo
 	return token, token.Id != 0
 }
 
-// UpdateRefreshTokenLastUsed updates the LastUsedAt timestamp for a token
-func UpdateRefreshTokenLastUsed(tx *vbolt.Tx, tokenId int) {
-	var token RefreshToken
-	vbolt.Read(tx, RefreshTokenBkt, tokenId, &token)
-	if token.Id == 0 {
-		return
-	}
+// deleteRefreshTokenTx removes a token along with its rotated-away predecessors
+func deleteRefreshTokenTx(tx *vbolt.Tx, token RefreshToken) {
+	vbolt.Delete(tx, RefreshTokenBkt, token.Id)
+	vbolt.Delete(tx, RefreshTokenByTokenBkt, token.Token)
+	vbolt.SetTargetSingleTerm(tx, RefreshTokenByUserIndex, token.Id, -1)
 
-	token.LastUsedAt = time.Now()
-	vbolt.Write(tx, RefreshTokenBkt, token.Id, &token)
+	var rotated []string
+	vbolt.ReadTermTargets(tx, RotatedRefreshTokensByIdIdx, token.Id, &rotated, vbolt.Window{})
+	for _, old := range rotated {
+		vbolt.Delete(tx, RotatedRefreshTokensBkt, old)
+		vbolt.SetTargetSingleTerm(tx, RotatedRefreshTokensByIdIdx, old, -1)
+	}
 }
 
 // DeleteRefreshToken removes a refresh token from the database
@@ -119,10 +154,7 @@ func DeleteRefreshToken(tx *vbolt.Tx, tokenString string) {
 		return
 	}
 
-	// Remove from all buckets and indexes
-	vbolt.Delete(tx, RefreshTokenBkt, token.Id)
-	vbolt.Delete(tx, RefreshTokenByTokenBkt, token.Token)
-	vbolt.SetTargetSingleTerm(tx, RefreshTokenByUserIndex, token.Id, -1)
+	deleteRefreshTokenTx(tx, token)
 
 	// Log token deletion (logout)
 	LogInfo(LogCategoryAuth, "Refresh token deleted", map[string]interface{}{
@@ -144,10 +176,7 @@ func DeleteUserRefreshTokens(tx *vbolt.Tx, userId int) {
 			continue
 		}
 
-		// Remove from all buckets and indexes
-		vbolt.Delete(tx, RefreshTokenBkt, token.Id)
-		vbolt.Delete(tx, RefreshTokenByTokenBkt, token.Token)
-		vbolt.SetTargetSingleTerm(tx, RefreshTokenByUserIndex, token.Id, -1)
+		deleteRefreshTokenTx(tx, token)
 		tokenCount++
 	}
 
@@ -160,6 +189,74 @@ func DeleteUserRefreshTokens(tx *vbolt.Tx, userId int) {
 	}
 }
 
+// RotateRefreshToken replaces the token string of an existing session and
+// remembers the old one so a replay of it can be detected
+func RotateRefreshToken(tx *vbolt.Tx, token RefreshToken, userAgent string, ipAddress string) (RefreshToken, error) {
+	tokenString, err := generateRefreshToken()
+	if err != nil {
+		return token, err
+	}
+
+	vbolt.Delete(tx, RefreshTokenByTokenBkt, token.Token)
+	vbolt.Write(tx, RotatedRefreshTokensBkt, token.Token, &token.Id)
+	vbolt.SetTargetSingleTerm(tx, RotatedRefreshTokensByIdIdx, token.Token, token.Id)
+
+	token.PreviousToken = token.Token
+	token.Token = tokenString
+	token.RotatedAt = time.Now()
+	token.LastUsedAt = time.Now()
+	token.UserAgent = userAgent
+	token.IPAddress = ipAddress
+	vbolt.Write(tx, RefreshTokenBkt, token.Id, &token)
+	vbolt.Write(tx, RefreshTokenByTokenBkt, token.Token, &token.Id)
+	return token, nil
+}
+
+// UseRefreshToken validates a presented token and rotates it. Presenting a token
+// that was already rotated away means it was copied, so the whole session is
+// revoked and ErrRefreshTokenReused returned. The caller commits.
+func UseRefreshToken(tx *vbolt.Tx, tokenString string, userAgent string, ipAddress string) (RefreshToken, error) {
+	token, found := GetRefreshTokenByToken(tx, tokenString)
+	if found {
+		if token.ExpiresAt.Before(time.Now()) {
+			deleteRefreshTokenTx(tx, token)
+			return RefreshToken{}, errors.New("refresh token expired")
+		}
+		return RotateRefreshToken(tx, token, userAgent, ipAddress)
+	}
+
+	var tokenId int
+	vbolt.Read(tx, RotatedRefreshTokensBkt, tokenString, &tokenId)
+	if tokenId == 0 {
+		return RefreshToken{}, errors.New("invalid refresh token")
+	}
+	vbolt.Read(tx, RefreshTokenBkt, tokenId, &token)
+	if token.Id == 0 {
+		return RefreshToken{}, errors.New("invalid refresh token")
+	}
+
+	// Another tab refreshed a moment ago; hand back the current token
+	if token.PreviousToken == tokenString && time.Since(token.RotatedAt) < REFRESH_TOKEN_REUSE_GRACE {
+		return token, nil
+	}
+
+	deleteRefreshTokenTx(tx, token)
+	LogWarn(LogCategoryAuth, "Rotated refresh token reused, session revoked", map[string]interface{}{
+		"userId":    token.UserId,
+		"sessionId": token.Id,
+		"ipAddress": ipAddress,
+	})
+	return RefreshToken{}, ErrRefreshTokenReused
+}
+
+// refreshSessionActiveTx reports whether the session an auth JWT was issued for
+// still exists, so revoking a session signs it out immediately
+func refreshSessionActiveTx(tx *vbolt.Tx, sessionId int, userId int) bool {
+	var token RefreshToken
+	vbolt.Read(tx, RefreshTokenBkt, sessionId, &token)
+	return token.Id != 0 && token.UserId == userId
+}
+
 // ValidateRefreshToken checks if a token is valid (exists and not expired)
 func ValidateRefreshToken(tx *vbolt.Tx, tokenString string) (RefreshToken, bool) {
 	token, found := GetRefreshTokenByToken(tx, tokenString)
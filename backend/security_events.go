@@ -0,0 +1,316 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"stream/cfg"
+	"strings"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const (
+	SecurityEventLoginFailed     = "login_failed"
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+
+	SECURITY_EVENT_RETENTION        = 90 * 24 * time.Hour
+	SECURITY_EVENT_CLEANUP_INTERVAL = 24 * time.Hour
+	SECURITY_EVENT_DEFAULT_LIMIT    = 100
+)
+
+// SecurityEvent records a failed login, lockout or unlock. UserId is 0 when
+// someone tried to sign in with an email that has no account.
+type SecurityEvent struct {
+	Id        int       `json:"id"`
+	Type      string    `json:"type"`
+	UserId    int       `json:"userId"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	Detail    string    `json:"detail"`
+	ActorId   int       `json:"actorId"` // admin who unlocked the account
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func PackSecurityEvent(self *SecurityEvent, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.String(&self.Type, buf)
+	vpack.Int(&self.UserId, buf)
+	vpack.String(&self.Email, buf)
+	vpack.String(&self.IPAddress, buf)
+	vpack.String(&self.UserAgent, buf)
+	vpack.String(&self.Detail, buf)
+	vpack.Int(&self.ActorId, buf)
+	vpack.Time(&self.CreatedAt, buf)
+}
+
+var SecurityEventsBkt = vbolt.Bucket(&cfg.Info, "security_events", vpack.FInt, PackSecurityEvent)
+
+// userId (term) => eventId (target)
+var SecurityEventsByUserIdx = vbolt.Index(&cfg.Info, "security_events_by_user", vpack.FInt, vpack.FInt)
+
+func RegisterSecurityEventMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, ListSecurityEvents)
+	vbeam.RegisterProc(app, UnlockAccount)
+}
+
+func recordSecurityEventTx(tx *vbolt.Tx, event SecurityEvent) SecurityEvent {
+	event.Id = vbolt.NextIntId(tx, SecurityEventsBkt)
+	event.CreatedAt = time.Now()
+	vbolt.Write(tx, SecurityEventsBkt, event.Id, &event)
+	if event.UserId > 0 {
+		vbolt.SetTargetSingleTerm(tx, SecurityEventsByUserIdx, event.Id, event.UserId)
+	}
+	return event
+}
+
+// recordLoginEvent stores an event for a password login request
+func recordLoginEvent(r *http.Request, eventType string, userId int, email string, detail string) {
+	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+		recordSecurityEventTx(tx, SecurityEvent{
+			Type:      eventType,
+			UserId:    userId,
+			Email:     email,
+			IPAddress: getClientIP(r),
+			UserAgent: r.UserAgent(),
+			Detail:    detail,
+		})
+		vbolt.TxCommit(tx)
+	})
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// throttleLogin applies the per-IP and per-account login limits. It responds
+// with 429 and returns true when the attempt is refused.
+func throttleLogin(w http.ResponseWriter, r *http.Request, user User, email string) bool {
+	info := globalRateLimiter.CheckLoginIP(getClientIP(r))
+	if !info.Limited {
+		info = globalRateLimiter.CheckLoginAccount(email)
+		if info.Limited && info.Escalated {
+			lockAccountForLogin(r, user, email, info)
+		}
+	}
+	if !info.Limited {
+		return false
+	}
+
+	LogWarnWithRequest(r, LogCategoryAuth, "Login attempt throttled", map[string]interface{}{
+		"email":             email,
+		"violationCount":    info.ViolationCount,
+		"retryAfterSeconds": info.RetryAfterSeconds,
+	})
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", info.RetryAfterSeconds))
+	http.Error(w, "Too many sign-in attempts. Try again in "+formatRetryAfter(info.RetryAfterSeconds)+".", http.StatusTooManyRequests)
+	return true
+}
+
+// lockAccountForLogin records a new lockout and, the first time, emails the
+// account owner
+func lockAccountForLogin(r *http.Request, user User, email string, info *RateLimitInfo) {
+	detail := fmt.Sprintf("locked for %s (lockout #%d)", formatRetryAfter(info.RetryAfterSeconds), info.ViolationCount)
+	recordLoginEvent(r, SecurityEventAccountLocked, user.Id, email, detail)
+
+	LogWarnWithRequest(r, LogCategoryAuth, "Account locked after failed logins", map[string]interface{}{
+		"userId":         user.Id,
+		"email":          email,
+		"violationCount": info.ViolationCount,
+	})
+
+	if user.Id > 0 && info.ViolationCount == 1 {
+		sendAccountLockedEmail(user, getClientIP(r))
+	}
+}
+
+func formatRetryAfter(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+	minutes := (seconds + 59) / 60
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
+func sendAccountLockedEmail(user User, ipAddress string) error {
+	body := fmt.Sprintf(`Hi %s,
+
+There were too many failed attempts to sign in to your account, most
+recently from %s, so password sign-in is paused for a while.
+
+If this was you, wait a few minutes and try again, or reset your password:
+
+    %s/forgot-password
+
+If it wasn't you, none of those attempts got in, but consider changing your
+password and turning on two-factor authentication.
+`, user.Name, ipAddress, cfg.SiteURL)
+
+	return sendMail(MailMessage{
+		To:      user.Email,
+		Subject: "Sign-in attempts paused on your account",
+		Body:    body,
+	})
+}
+
+// StartSecurityEventCleanup starts a background goroutine that deletes
+// security events older than SECURITY_EVENT_RETENTION
+func StartSecurityEventCleanup(db *vbolt.DB) {
+	LogInfo(LogCategorySystem, "Starting security event cleanup job", map[string]interface{}{
+		"interval":  SECURITY_EVENT_CLEANUP_INTERVAL.String(),
+		"retention": SECURITY_EVENT_RETENTION.String(),
+	})
+
+	go func() {
+		ticker := time.NewTicker(SECURITY_EVENT_CLEANUP_INTERVAL)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			cutoff := time.Now().Add(-SECURITY_EVENT_RETENTION)
+			var expired []SecurityEvent
+			vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+				vbolt.IterateAll(tx, SecurityEventsBkt, func(id int, event SecurityEvent) bool {
+					if !event.CreatedAt.Before(cutoff) {
+						return false // ids are in creation order
+					}
+					expired = append(expired, event)
+					return true
+				})
+			})
+			if len(expired) == 0 {
+				continue
+			}
+
+			vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+				for _, event := range expired {
+					vbolt.SetTargetSingleTerm(tx, SecurityEventsByUserIdx, event.Id, -1)
+					vbolt.Delete(tx, SecurityEventsBkt, event.Id)
+				}
+				vbolt.TxCommit(tx)
+			})
+
+			LogInfo(LogCategorySystem, "Deleted old security events", map[string]interface{}{
+				"count": len(expired),
+			})
+		}
+	}()
+}
+
+// Request/Response types
+type ListSecurityEventsRequest struct {
+	UserId int `json:"userId"` // 0 = all users
+	Limit  int `json:"limit"`
+}
+
+type LockedAccount struct {
+	UserId      int       `json:"userId"` // 0 if no account has this email
+	Email       string    `json:"email"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+type ListSecurityEventsResponse struct {
+	Events         []SecurityEvent `json:"events"` // newest first
+	LockedAccounts []LockedAccount `json:"lockedAccounts"`
+}
+
+type UnlockAccountRequest struct {
+	Email string `json:"email"`
+}
+
+type UnlockAccountResponse struct {
+	Success bool `json:"success"`
+}
+
+// ListSecurityEvents returns recent failed logins, lockouts and unlocks, plus
+// the accounts locked out right now. Only accessible by site admins.
+func ListSecurityEvents(ctx *vbeam.Context, req ListSecurityEventsRequest) (resp ListSecurityEventsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+	if caller.Role != RoleSiteAdmin {
+		return resp, errors.New("Only site admins can view security events")
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 500 {
+		limit = SECURITY_EVENT_DEFAULT_LIMIT
+	}
+
+	resp.Events = []SecurityEvent{}
+	if req.UserId > 0 {
+		var eventIds []int
+		vbolt.ReadTermTargets(ctx.Tx, SecurityEventsByUserIdx, req.UserId, &eventIds, vbolt.Window{})
+		for i := len(eventIds) - 1; i >= 0 && len(resp.Events) < limit; i-- {
+			var event SecurityEvent
+			if vbolt.Read(ctx.Tx, SecurityEventsBkt, eventIds[i], &event) {
+				resp.Events = append(resp.Events, event)
+			}
+		}
+	} else {
+		vbolt.IterateAllReverse(ctx.Tx, SecurityEventsBkt, func(id int, event SecurityEvent) bool {
+			resp.Events = append(resp.Events, event)
+			return len(resp.Events) < limit
+		})
+	}
+
+	resp.LockedAccounts = []LockedAccount{}
+	for email, lockedUntil := range globalRateLimiter.LockedLoginAccounts() {
+		resp.LockedAccounts = append(resp.LockedAccounts, LockedAccount{
+			UserId:      GetUserId(ctx.Tx, email),
+			Email:       email,
+			LockedUntil: lockedUntil,
+		})
+	}
+	sort.Slice(resp.LockedAccounts, func(i, j int) bool {
+		return resp.LockedAccounts[i].Email < resp.LockedAccounts[j].Email
+	})
+	return
+}
+
+// UnlockAccount lifts a password login lockout. Only accessible by site admins.
+func UnlockAccount(ctx *vbeam.Context, req UnlockAccountRequest) (resp UnlockAccountResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+	if caller.Role != RoleSiteAdmin {
+		return resp, errors.New("Only site admins can unlock accounts")
+	}
+
+	email := normalizeLoginEmail(req.Email)
+	if email == "" {
+		return resp, errors.New("Email is required")
+	}
+	userId := GetUserId(ctx.Tx, email)
+
+	globalRateLimiter.ResetLoginAccount(email)
+
+	vbeam.UseWriteTx(ctx)
+	recordSecurityEventTx(ctx.Tx, SecurityEvent{
+		Type:    SecurityEventAccountUnlocked,
+		UserId:  userId,
+		Email:   email,
+		ActorId: caller.Id,
+	})
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategoryAuth, "Account unlocked", map[string]interface{}{
+		"userId":     userId,
+		"email":      email,
+		"unlockedBy": caller.Id,
+	})
+
+	resp.Success = true
+	return
+}
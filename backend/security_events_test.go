@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func postTestLoginFrom(email string, password string, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"`+email+`","password":"`+password+`"}`))
+	req.Header.Set("X-Forwarded-For", ip)
+	w := httptest.NewRecorder()
+	loginHandler(w, req)
+	return w
+}
+
+func TestLoginLockout(t *testing.T) {
+	db, admin, _, _ := setupTestStudioRoom(t)
+	mailer := appMailer.(*MemoryMailer)
+	adminToken, _ := createTestToken(admin.Id)
+	user := createTestInvitee(t, db, "victim@test.com", true)
+	setTestPassword(t, db, user.Id, "password123")
+
+	// A successful login clears earlier failures
+	for i := 0; i < 4; i++ {
+		postTestLoginFrom(user.Email, "wrong", "203.0.113.1")
+	}
+	if w := postTestLoginFrom(user.Email, "password123", "203.0.113.1"); w.Code != http.StatusOK {
+		t.Fatalf("Expected login to succeed, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Five failures from different IPs, then the account is locked
+	for i := 0; i < 5; i++ {
+		if w := postTestLoginFrom(user.Email, "wrong", "198.51.100."+strconv.Itoa(i)); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected failed login, got %d", w.Code)
+		}
+	}
+	w := postTestLoginFrom(user.Email, "password123", "198.51.100.50")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected lockout, got %d %s", w.Code, w.Body.String())
+	}
+	postTestLoginFrom(user.Email, "password123", "198.51.100.51")
+
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != user.Email || !strings.Contains(sent[0].Body, "198.51.100.50") {
+		t.Fatalf("Expected one lockout notification, got %+v", sent)
+	}
+
+	var events ListSecurityEventsResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		events, err = ListSecurityEvents(&vbeam.Context{Tx: tx, Token: adminToken}, ListSecurityEventsRequest{UserId: user.Id})
+	})
+	if err != nil || len(events.Events) != 10 || events.Events[0].Type != SecurityEventAccountLocked {
+		t.Fatalf("Unexpected security events: %v %+v", err, events.Events)
+	}
+	if events.Events[1].Type != SecurityEventLoginFailed || events.Events[1].IPAddress != "198.51.100.4" {
+		t.Errorf("Expected failed login with its IP, got %+v", events.Events[1])
+	}
+	if len(events.LockedAccounts) != 1 || events.LockedAccounts[0].UserId != user.Id {
+		t.Errorf("Expected the account to be listed as locked, got %+v", events.LockedAccounts)
+	}
+
+	// Only site admins can unlock
+	userToken, _ := createTestToken(user.Id)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = UnlockAccount(&vbeam.Context{Tx: tx, Token: userToken}, UnlockAccountRequest{Email: user.Email})
+	})
+	if err == nil {
+		t.Errorf("Expected non-admin unlock to be refused")
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = UnlockAccount(&vbeam.Context{Tx: tx, Token: adminToken}, UnlockAccountRequest{Email: user.Email})
+	})
+	if err != nil {
+		t.Fatalf("UnlockAccount failed: %v", err)
+	}
+	if w := postTestLoginFrom(user.Email, "password123", "198.51.100.52"); w.Code != http.StatusOK {
+		t.Errorf("Expected login after unlock, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLoginThrottledByIP(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	// Spraying many accounts from one address hits the per-IP limit
+	for i := 0; i < 20; i++ {
+		if w := postTestLoginFrom("nobody"+strconv.Itoa(i)+"@test.com", "guess", "192.0.2.9"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected failed login, got %d", w.Code)
+		}
+	}
+	if w := postTestLoginFrom("another@test.com", "guess", "192.0.2.9"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected IP to be throttled, got %d", w.Code)
+	}
+	if w := postTestLoginFrom("another@test.com", "guess", "192.0.2.10"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected other IPs to be unaffected, got %d", w.Code)
+	}
+
+	var failed int
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		vbolt.IterateAll(tx, SecurityEventsBkt, func(id int, event SecurityEvent) bool {
+			if event.Type == SecurityEventLoginFailed && event.UserId == 0 {
+				failed++
+			}
+			return true
+		})
+	})
+	if failed != 21 {
+		t.Errorf("Expected failed logins for unknown emails to be recorded, got %d", failed)
+	}
+}
@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func RegisterSessionMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, ListSessions)
+	vbeam.RegisterProc(app, RevokeSession)
+	vbeam.RegisterProc(app, SignOutEverywhere)
+}
+
+// Request/Response types
+type DeviceSession struct {
+	Id         int       `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"` // the session making this request
+}
+
+type ListSessionsResponse struct {
+	Sessions []DeviceSession `json:"sessions"`
+}
+
+type RevokeSessionRequest struct {
+	SessionId int `json:"sessionId"`
+}
+
+type RevokeSessionResponse struct {
+	Success bool `json:"success"`
+}
+
+type SignOutEverywhereResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// currentSessionId returns the refresh token session the caller's JWT belongs to
+func currentSessionId(ctx *vbeam.Context) int {
+	var claims Claims
+	token, err := jwt.ParseWithClaims(ctx.Token, &claims, jwtKeyFunc)
+	if err != nil || !token.Valid {
+		return 0
+	}
+	return claims.RefreshId
+}
+
+// ListSessions returns the caller's signed-in devices, most recently used first
+func ListSessions(ctx *vbeam.Context, req Empty) (resp ListSessionsResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	currentId := currentSessionId(ctx)
+
+	var tokenIds []int
+	vbolt.ReadTermTargets(ctx.Tx, RefreshTokenByUserIndex, user.Id, &tokenIds, vbolt.Window{})
+
+	resp.Sessions = []DeviceSession{}
+	for _, tokenId := range tokenIds {
+		var token RefreshToken
+		vbolt.Read(ctx.Tx, RefreshTokenBkt, tokenId, &token)
+		if token.Id == 0 || token.ExpiresAt.Before(time.Now()) {
+			continue
+		}
+		resp.Sessions = append(resp.Sessions, DeviceSession{
+			Id:         token.Id,
+			UserAgent:  token.UserAgent,
+			IPAddress:  token.IPAddress,
+			CreatedAt:  token.CreatedAt,
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+			Current:    token.Id == currentId,
+		})
+	}
+
+	sort.Slice(resp.Sessions, func(i, j int) bool {
+		return resp.Sessions[i].LastUsedAt.After(resp.Sessions[j].LastUsedAt)
+	})
+	return
+}
+
+// RevokeSession signs one of the caller's devices out
+func RevokeSession(ctx *vbeam.Context, req RevokeSessionRequest) (resp RevokeSessionResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	var token RefreshToken
+	vbolt.Read(ctx.Tx, RefreshTokenBkt, req.SessionId, &token)
+	if token.Id == 0 || token.UserId != user.Id {
+		return resp, errors.New("Session not found")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	deleteRefreshTokenTx(ctx.Tx, token)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategoryAuth, "Session revoked", map[string]interface{}{
+		"userId":    user.Id,
+		"sessionId": token.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+// SignOutEverywhere revokes all of the caller's sessions, including this one
+func SignOutEverywhere(ctx *vbeam.Context, req Empty) (resp SignOutEverywhereResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	var tokenIds []int
+	vbolt.ReadTermTargets(ctx.Tx, RefreshTokenByUserIndex, user.Id, &tokenIds, vbolt.Window{})
+
+	vbeam.UseWriteTx(ctx)
+	DeleteUserRefreshTokens(ctx.Tx, user.Id)
+	vbolt.TxCommit(ctx.Tx)
+
+	resp.Revoked = len(tokenIds)
+	return
+}
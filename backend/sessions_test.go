@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+// loginTestDevice signs in through loginHandler with the given user agent and
+// returns the auth and refresh cookies
+func loginTestDevice(t *testing.T, email string, userAgent string) (*http.Cookie, *http.Cookie) {
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"`+email+`","password":"password123"}`))
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	w := httptest.NewRecorder()
+	loginHandler(w, req)
+	auth, refresh := findTestCookie(w, "authToken"), findTestCookie(w, "refreshToken")
+	if auth == nil || refresh == nil {
+		t.Fatalf("Expected login cookies, got %d %s", w.Code, w.Body.String())
+	}
+	return auth, refresh
+}
+
+func postTestRefresh(refresh *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/refresh", nil)
+	req.Header.Set("User-Agent", "Laptop Browser")
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+	req.AddCookie(refresh)
+	w := httptest.NewRecorder()
+	refreshTokenHandler(w, req)
+	return w
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	user := createTestInvitee(t, db, "rotate@test.com", true)
+	setTestPassword(t, db, user.Id, "password123")
+	auth, refresh := loginTestDevice(t, user.Email, "Laptop Browser")
+
+	w := postTestRefresh(refresh)
+	rotated := findTestCookie(w, "refreshToken")
+	if w.Code != http.StatusOK || rotated == nil || rotated.Value == refresh.Value {
+		t.Fatalf("Expected a rotated refresh token, got %d %s", w.Code, w.Body.String())
+	}
+
+	var session RefreshToken
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		session, _ = GetRefreshTokenByToken(tx, rotated.Value)
+	})
+	// Same session, now showing where it was last used from
+	if session.UserAgent != "Laptop Browser" || session.IPAddress != "198.51.100.9" || session.PreviousToken != refresh.Value {
+		t.Errorf("Unexpected session after rotation: %+v", session)
+	}
+
+	// A second tab refreshing with the old token right away gets the current one
+	w = postTestRefresh(refresh)
+	if w.Code != http.StatusOK || findTestCookie(w, "refreshToken").Value != rotated.Value {
+		t.Errorf("Expected concurrent refresh to reuse the current token, got %d", w.Code)
+	}
+
+	// Later replay of the old token means it leaked: the session is revoked
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		session.RotatedAt = time.Now().Add(-time.Minute)
+		vbolt.Write(tx, RefreshTokenBkt, session.Id, &session)
+		vbolt.TxCommit(tx)
+	})
+	if w := postTestRefresh(refresh); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected reused token to be rejected, got %d", w.Code)
+	}
+	if w := postTestRefresh(rotated); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the whole session to be revoked, got %d", w.Code)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if _, err := GetAuthUser(&vbeam.Context{Tx: tx, Token: auth.Value}); err == nil {
+			t.Errorf("Expected JWT of a revoked session to stop working")
+		}
+		var rotatedId int
+		vbolt.Read(tx, RotatedRefreshTokensBkt, refresh.Value, &rotatedId)
+		if rotatedId != 0 {
+			t.Errorf("Expected rotated tokens to be cleaned up with the session")
+		}
+	})
+}
+
+func TestSessionManagement(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	user := createTestInvitee(t, db, "devices@test.com", true)
+	setTestPassword(t, db, user.Id, "password123")
+	other := createTestInvitee(t, db, "other@test.com", true)
+	otherToken, _ := createTestToken(other.Id)
+
+	laptopAuth, _ := loginTestDevice(t, user.Email, "Laptop Browser")
+	phoneAuth, _ := loginTestDevice(t, user.Email, "Phone Browser")
+
+	var list ListSessionsResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		list, err = ListSessions(&vbeam.Context{Tx: tx, Token: laptopAuth.Value}, Empty{})
+	})
+	if err != nil || len(list.Sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %v %+v", err, list.Sessions)
+	}
+	var phoneSession DeviceSession
+	for _, session := range list.Sessions {
+		if session.Current != (session.UserAgent == "Laptop Browser") {
+			t.Errorf("Expected only the laptop session to be current: %+v", session)
+		}
+		if session.UserAgent == "Phone Browser" {
+			phoneSession = session
+		}
+	}
+
+	// Other users can't revoke it
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = RevokeSession(&vbeam.Context{Tx: tx, Token: otherToken}, RevokeSessionRequest{SessionId: phoneSession.Id})
+	})
+	if err == nil {
+		t.Errorf("Expected revoking another user's session to fail")
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = RevokeSession(&vbeam.Context{Tx: tx, Token: laptopAuth.Value}, RevokeSessionRequest{SessionId: phoneSession.Id})
+	})
+	if err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if _, err := GetAuthUser(&vbeam.Context{Tx: tx, Token: phoneAuth.Value}); err == nil {
+			t.Errorf("Expected revoked phone session to be signed out")
+		}
+		if _, err := GetAuthUser(&vbeam.Context{Tx: tx, Token: laptopAuth.Value}); err != nil {
+			t.Errorf("Expected laptop session to stay signed in: %v", err)
+		}
+	})
+
+	var signOut SignOutEverywhereResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		signOut, err = SignOutEverywhere(&vbeam.Context{Tx: tx, Token: laptopAuth.Value}, Empty{})
+	})
+	if err != nil || signOut.Revoked != 1 {
+		t.Fatalf("SignOutEverywhere failed: %v %+v", err, signOut)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if _, err := GetAuthUser(&vbeam.Context{Tx: tx, Token: laptopAuth.Value}); err == nil {
+			t.Errorf("Expected every session to be signed out")
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -17,18 +18,150 @@ type SSEClient struct {
 	Writer       http.ResponseWriter
 	Done         chan bool
 	SessionToken string // Code session token (empty for JWT authenticated users)
+	ViewerId     string // "user:<id>" or "code:<sessionToken>", used to reach one viewer
+
+	queue    chan string   // Live events waiting for the connection's handler to write them
+	dropped  chan struct{} // Closed when the client fell too far behind
+	dropOnce sync.Once
+}
+
+// SSE_CLIENT_QUEUE_SIZE is how many live events a client can fall behind
+// before it's dropped. The browser reconnects and resumes from replay.
+const SSE_CLIENT_QUEUE_SIZE = 64
+
+// SSE_WRITE_TIMEOUT bounds each write, so a stalled connection is closed
+// instead of holding its handler forever
+const SSE_WRITE_TIMEOUT = 10 * time.Second
+
+// send queues a message for the client without waiting on its connection.
+// A client whose queue is full is dropped rather than slowing the others.
+func (c *SSEClient) send(message string) bool {
+	select {
+	case c.queue <- message:
+		return true
+	default:
+		c.dropOnce.Do(func() { close(c.dropped) })
+		return false
+	}
+}
+
+// write writes messages straight to the connection and flushes them. Only
+// the connection's handler writes, after registering the client.
+func (c *SSEClient) write(messages ...string) error {
+	rc := http.NewResponseController(c.Writer)
+	rc.SetWriteDeadline(time.Now().Add(SSE_WRITE_TIMEOUT)) // Not supported by every writer
+	for _, message := range messages {
+		if _, err := fmt.Fprint(c.Writer, message); err != nil {
+			return err
+		}
+	}
+	flushSSE(c.Writer)
+	return nil
+}
+
+// pump writes queued messages and keepalives until closed is, the client is
+// dropped or a write fails. Events queued while the handler wrote the replay
+// follow it in order.
+func (c *SSEClient) pump(closed <-chan struct{}, keepalive <-chan time.Time) {
+	for {
+		var err error
+		select {
+		case <-closed:
+			return
+		case <-c.dropped:
+			LogWarn(LogCategorySystem, "SSE client fell behind, dropping it", map[string]interface{}{
+				"roomId":   c.RoomID,
+				"viewerId": c.ViewerId,
+			})
+			return
+		case message := <-c.queue:
+			messages := []string{message}
+			for len(c.queue) > 0 {
+				messages = append(messages, <-c.queue)
+			}
+			err = c.write(messages...)
+		case <-keepalive:
+			err = c.write(": keepalive\n\n")
+		}
+		if err != nil {
+			return
+		}
+	}
 }
 
-// SSEManager manages all active SSE connections
+// SSE_REPLAY_BUFFER_SIZE is how many recent events per room are kept for
+// viewers reconnecting with Last-Event-ID
+const SSE_REPLAY_BUFFER_SIZE = 200
+
+// sseEvent is a broadcast event kept for replay
+type sseEvent struct {
+	Id   int
+	Name string
+	Data []byte
+}
+
+// format renders the event with its ID so browsers send it back as Last-Event-ID
+func (e sseEvent) format() string {
+	return fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Name, e.Data)
+}
+
+// sseRoomHistory holds a room's recent replayable events
+type sseRoomHistory struct {
+	events  []sseEvent
+	dropped int // ID of the newest event pushed out of the buffer
+}
+
+// SSEManager manages the SSE connections to this app instance. Broadcasts go
+// through its event bus, so with a shared bus every instance delivers them to
+// its own viewers.
 type SSEManager struct {
 	mu sync.RWMutex
 	// Map: roomId -> list of clients watching that room
 	clients map[int][]*SSEClient
+	// Map: roomId -> recent events for Last-Event-ID replay
+	history map[int]*sseRoomHistory
+	// Event IDs start from when this instance joined the bus, so IDs a
+	// browser kept from before a restart are never mistaken for current ones
+	firstEventID int
+	lastEventID  int
+	bus          EventBus
 }
 
 // Global SSE manager instance
-var sseManager = &SSEManager{
-	clients: make(map[int][]*SSEClient),
+var sseManager = newSSEManager()
+
+func newSSEManager() *SSEManager {
+	m := &SSEManager{
+		clients: make(map[int][]*SSEClient),
+		history: make(map[int]*sseRoomHistory),
+	}
+	m.UseEventBus(NewLocalEventBus())
+	return m
+}
+
+// UseEventBus switches broadcasts to the given bus and closes the previous
+// one. Replay history starts over, since event IDs come from the new bus.
+func (m *SSEManager) UseEventBus(bus EventBus) error {
+	start, err := bus.NextEventID()
+	if err != nil {
+		return fmt.Errorf("event bus: %w", err)
+	}
+	if err := bus.Subscribe(m.deliver, m.resync); err != nil {
+		return fmt.Errorf("event bus: %w", err)
+	}
+
+	m.mu.Lock()
+	previous := m.bus
+	m.bus = bus
+	m.history = make(map[int]*sseRoomHistory)
+	m.firstEventID = start
+	m.lastEventID = start
+	m.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
 }
 
 // AddClient adds a client to a room's subscriber list
@@ -36,6 +169,45 @@ func (m *SSEManager) AddClient(roomID int, client *SSEClient) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.addClientLocked(roomID, client)
+}
+
+// AddClientSince adds a client resuming from lastEventID and returns the
+// room's events it missed. Both happen under one lock so every event is
+// either replayed or broadcast to the client, never both. Broadcasts queue up
+// while the caller writes the replay, so the client gets everything in
+// order. ok is false when the missed events are no longer
+// buffered (older than the buffer or from before a server restart) and the
+// client has to reload instead.
+func (m *SSEManager) AddClientSince(roomID int, client *SSEClient, lastEventID int) (missed []sseEvent, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.addClientLocked(roomID, client)
+
+	history := m.history[roomID]
+	if lastEventID < m.firstEventID || lastEventID > m.lastEventID {
+		return nil, false
+	}
+	if history == nil {
+		return nil, true
+	}
+	if lastEventID < history.dropped {
+		return nil, false
+	}
+	for _, event := range history.events {
+		if event.Id > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+	return missed, true
+}
+
+func (m *SSEManager) addClientLocked(roomID int, client *SSEClient) {
+	if client.queue == nil {
+		client.queue = make(chan string, SSE_CLIENT_QUEUE_SIZE)
+		client.dropped = make(chan struct{})
+	}
 	if m.clients[roomID] == nil {
 		m.clients[roomID] = []*SSEClient{}
 	}
@@ -71,40 +243,175 @@ func (m *SSEManager) RemoveClient(roomID int, client *SSEClient) {
 	})
 }
 
-// GetCurrentViewerCounts returns a map of roomId -> actual viewer count
-// Used for manual recalculation and troubleshooting
-func (m *SSEManager) GetCurrentViewerCounts() map[int]int {
+// publish sends an event to the room's viewers on every instance. Replayed
+// events get an ID from the bus so any instance can resume them. If the bus
+// is unreachable the event still reaches this instance's viewers.
+func (m *SSEManager) publish(event BusEvent, replay bool) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	bus := m.bus
+	m.mu.RUnlock()
 
-	counts := make(map[int]int)
-	for roomID, clients := range m.clients {
-		counts[roomID] = len(clients)
+	if replay {
+		id, err := bus.NextEventID()
+		if err != nil {
+			LogWarn(LogCategorySystem, "Failed to get SSE event ID", map[string]interface{}{
+				"event": event.Name,
+				"error": err.Error(),
+			})
+			m.deliver(event) // sent without an ID, so not replayed
+			return
+		}
+		event.Id = id
 	}
-	return counts
+
+	if err := bus.Publish(event); err != nil {
+		LogWarn(LogCategorySystem, "Failed to publish SSE event", map[string]interface{}{
+			"roomId": event.RoomId,
+			"event":  event.Name,
+			"error":  err.Error(),
+		})
+		m.deliver(event)
+	}
+}
+
+// resync starts replay over after the bus missed events. IDs from before
+// nextEventID can't be resumed anymore, and viewers connected here are told
+// to reload what they missed.
+func (m *SSEManager) resync(nextEventID int) {
+	m.mu.Lock()
+	m.history = make(map[int]*sseRoomHistory)
+	m.firstEventID = nextEventID
+	m.lastEventID = nextEventID
+	var clients []*SSEClient
+	for _, roomClients := range m.clients {
+		clients = append(clients, roomClients...)
+	}
+	m.mu.Unlock()
+
+	message := resyncMessage()
+	for _, client := range clients {
+		select {
+		case <-client.Done:
+			continue
+		default:
+			client.send(message)
+		}
+	}
+
+	LogWarn(LogCategorySystem, "SSE events missed, viewers resyncing", map[string]interface{}{
+		"nextEventId": nextEventID,
+		"clients":     len(clients),
+	})
+}
+
+// resyncMessage asks a client to reload chat and Q&A, for when the events it
+// missed can't be replayed
+func resyncMessage() string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"timestamp": time.Now().Unix(),
+	})
+	return fmt.Sprintf("event: resync\ndata: %s\n\n", data)
+}
+
+// deliver queues an event from the bus for this instance's matching clients.
+// Events with an ID are kept for replay, even when no one here is watching,
+// so a viewer whose only connection dropped still catches up. Clients that
+// connect afterwards get the event through replay instead.
+func (m *SSEManager) deliver(event BusEvent) {
+	m.mu.Lock()
+	message := fmt.Sprintf("event: %s\ndata: %s\n\n", event.Name, event.Data)
+	if event.Id > 0 {
+		recorded := sseEvent{Id: event.Id, Name: event.Name, Data: event.Data}
+		message = recorded.format()
+
+		history := m.history[event.RoomId]
+		if history == nil {
+			history = &sseRoomHistory{}
+			m.history[event.RoomId] = history
+		}
+		history.events = append(history.events, recorded)
+		if over := len(history.events) - SSE_REPLAY_BUFFER_SIZE; over > 0 {
+			history.dropped = history.events[over-1].Id
+			history.events = history.events[over:]
+		}
+		if event.Id > m.lastEventID {
+			m.lastEventID = event.Id
+		}
+	}
+	clients := m.clients[event.RoomId]
+	m.mu.Unlock()
+
+	if len(clients) == 0 {
+		return // No one watching here
+	}
+
+	sent := 0
+	for _, client := range clients {
+		if !event.targets(client) {
+			continue
+		}
+
+		select {
+		case <-client.Done:
+			// Client already disconnected
+			continue
+		default:
+			if client.send(message) {
+				sent++
+			}
+		}
+	}
+
+	LogDebug(LogCategorySystem, "Delivered SSE event", map[string]interface{}{
+		"roomId":  event.RoomId,
+		"event":   event.Name,
+		"clients": sent,
+	})
+}
+
+// targets reports whether a client should get the event. Revocations only
+// reach the revoked code sessions and restrictions only the restricted viewer.
+func (e BusEvent) targets(client *SSEClient) bool {
+	if e.SessionTokens != nil {
+		// Skip JWT-authenticated users (they have empty SessionToken)
+		if client.SessionToken == "" {
+			return false
+		}
+		for _, token := range e.SessionTokens {
+			if client.SessionToken == token {
+				return true
+			}
+		}
+		return false
+	}
+	if e.ViewerId != "" {
+		return client.ViewerId == e.ViewerId
+	}
+	return true
+}
+
+// viewerConnection identifies a viewer watching a room
+type viewerConnection struct {
+	RoomId       int
+	ViewerId     string
+	SessionToken string // Empty for JWT authenticated users
 }
 
-// GetCodeSessionCounts returns a map of code -> count of active code sessions
-// Used for manual recalculation and troubleshooting
-func (m *SSEManager) GetCodeSessionCounts() map[string]int {
+// viewerConnections counts this instance's open connections per viewer and
+// room, for the presence heartbeat
+func (m *SSEManager) viewerConnections() map[viewerConnection]int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	counts := make(map[string]int)
-
-	// Iterate through all rooms and their clients
-	for _, clients := range m.clients {
+	counts := make(map[viewerConnection]int)
+	for roomID, clients := range m.clients {
 		for _, client := range clients {
-			// Only count code sessions (SessionToken is set for code sessions)
-			if client.SessionToken != "" {
-				// We need to lookup the code from the session token
-				// This requires database access, so we'll count sessions by token
-				// and let the admin procedure map tokens to codes
-				counts[client.SessionToken]++
+			if client.ViewerId == "" {
+				continue
 			}
+			counts[viewerConnection{RoomId: roomID, ViewerId: client.ViewerId, SessionToken: client.SessionToken}]++
 		}
 	}
-
 	return counts
 }
 
@@ -124,14 +431,6 @@ func flushSSE(w http.ResponseWriter) {
 
 // BroadcastRoomStatus sends a status update to all clients watching a room
 func (m *SSEManager) BroadcastRoomStatus(roomID int, isActive bool, isHlsReady bool) {
-	m.mu.RLock()
-	clients := m.clients[roomID]
-	m.mu.RUnlock()
-
-	if len(clients) == 0 {
-		return // No one watching
-	}
-
 	event := map[string]interface{}{
 		"isActive":   isActive,
 		"isHlsReady": isHlsReady,
@@ -139,39 +438,18 @@ func (m *SSEManager) BroadcastRoomStatus(roomID int, isActive bool, isHlsReady b
 	}
 
 	data, _ := json.Marshal(event)
-	message := fmt.Sprintf("event: status\ndata: %s\n\n", data)
 
 	LogDebug(LogCategorySystem, "Broadcasting SSE update", map[string]interface{}{
 		"roomId":     roomID,
 		"isActive":   isActive,
 		"isHlsReady": isHlsReady,
-		"clients":    len(clients),
 	})
 
-	// Send to all connected clients
-	for _, client := range clients {
-		select {
-		case <-client.Done:
-			// Client already disconnected
-			continue
-		default:
-			if _, err := fmt.Fprint(client.Writer, message); err == nil {
-				flushSSE(client.Writer)
-			}
-		}
-	}
+	m.publish(BusEvent{RoomId: roomID, Name: "status", Data: data}, true)
 }
 
 // BroadcastStreamReady notifies clients that HLS segments are ready for playback
 func (m *SSEManager) BroadcastStreamReady(roomID int, studioID int) {
-	m.mu.RLock()
-	clients := m.clients[roomID]
-	m.mu.RUnlock()
-
-	if len(clients) == 0 {
-		return // No one watching
-	}
-
 	event := map[string]interface{}{
 		"roomId":    roomID,
 		"studioId":  studioID,
@@ -179,36 +457,19 @@ func (m *SSEManager) BroadcastStreamReady(roomID int, studioID int) {
 	}
 
 	data, _ := json.Marshal(event)
-	message := fmt.Sprintf("event: stream_ready\ndata: %s\n\n", data)
 
 	LogDebug(LogCategorySystem, "Broadcasting stream_ready event", map[string]interface{}{
-		"roomId":  roomID,
-		"clients": len(clients),
+		"roomId": roomID,
 	})
 
-	// Send to all connected clients
-	for _, client := range clients {
-		select {
-		case <-client.Done:
-			// Client already disconnected
-			continue
-		default:
-			if _, err := fmt.Fprint(client.Writer, message); err == nil {
-				flushSSE(client.Writer)
-			}
-		}
-	}
+	m.publish(BusEvent{RoomId: roomID, Name: "stream_ready", Data: data}, true)
 }
 
 // BroadcastCodeRevoked notifies specific clients that their access code has been revoked
 // Only sends to clients whose SessionToken matches one of the revokedSessionTokens
 func (m *SSEManager) BroadcastCodeRevoked(roomID int, revokedSessionTokens []string) {
-	m.mu.RLock()
-	clients := m.clients[roomID]
-	m.mu.RUnlock()
-
-	if len(clients) == 0 {
-		return // No one watching
+	if len(revokedSessionTokens) == 0 {
+		return // No one to notify
 	}
 
 	event := map[string]interface{}{
@@ -217,60 +478,17 @@ func (m *SSEManager) BroadcastCodeRevoked(roomID int, revokedSessionTokens []str
 	}
 
 	data, _ := json.Marshal(event)
-	message := fmt.Sprintf("event: code_revoked\ndata: %s\n\n", data)
-
-	affectedClients := 0
-
-	// Send only to clients whose session token was revoked
-	for _, client := range clients {
-		// Skip JWT-authenticated users (they have empty SessionToken)
-		if client.SessionToken == "" {
-			continue
-		}
-
-		// Check if this client's session was revoked
-		isRevoked := false
-		for _, token := range revokedSessionTokens {
-			if client.SessionToken == token {
-				isRevoked = true
-				break
-			}
-		}
-
-		if !isRevoked {
-			continue // Not affected by this revocation
-		}
-
-		// Send CODE_REVOKED event to this specific client
-		select {
-		case <-client.Done:
-			// Client already disconnected
-			continue
-		default:
-			if _, err := fmt.Fprint(client.Writer, message); err == nil {
-				flushSSE(client.Writer)
-				affectedClients++
-			}
-		}
-	}
 
 	LogInfo(LogCategorySystem, "Broadcasting CODE_REVOKED event", map[string]interface{}{
 		"roomId":          roomID,
-		"totalClients":    len(clients),
-		"affectedClients": affectedClients,
+		"revokedSessions": len(revokedSessionTokens),
 	})
+
+	m.publish(BusEvent{RoomId: roomID, Name: "code_revoked", Data: data, SessionTokens: revokedSessionTokens}, false)
 }
 
 // BroadcastCodeExpiredGracePeriod notifies clients that their code has expired and they're in grace period
 func (m *SSEManager) BroadcastCodeExpiredGracePeriod(roomID int, gracePeriodMinutes int) {
-	m.mu.RLock()
-	clients := m.clients[roomID]
-	m.mu.RUnlock()
-
-	if len(clients) == 0 {
-		return // No one watching
-	}
-
 	event := map[string]interface{}{
 		"message":            fmt.Sprintf("Access code expired. Grace period: %d minutes remaining", gracePeriodMinutes),
 		"gracePeriodMinutes": gracePeriodMinutes,
@@ -278,114 +496,51 @@ func (m *SSEManager) BroadcastCodeExpiredGracePeriod(roomID int, gracePeriodMinu
 	}
 
 	data, _ := json.Marshal(event)
-	message := fmt.Sprintf("event: code_expired_grace\ndata: %s\n\n", data)
 
 	LogInfo(LogCategorySystem, "Broadcasting CODE_EXPIRED_GRACE_PERIOD event", map[string]interface{}{
 		"roomId":             roomID,
 		"gracePeriodMinutes": gracePeriodMinutes,
-		"clients":            len(clients),
 	})
 
-	// Send to all connected clients
-	for _, client := range clients {
-		select {
-		case <-client.Done:
-			// Client already disconnected
-			continue
-		default:
-			if _, err := fmt.Fprint(client.Writer, message); err == nil {
-				flushSSE(client.Writer)
-			}
-		}
-	}
+	m.publish(BusEvent{RoomId: roomID, Name: "code_expired_grace", Data: data}, false)
 }
 
 // BroadcastViewerCount sends the updated viewer count to all clients watching a room
 func (m *SSEManager) BroadcastViewerCount(roomID int, count int) {
-	m.mu.RLock()
-	clients := m.clients[roomID]
-	m.mu.RUnlock()
-
-	if len(clients) == 0 {
-		return // No one watching
-	}
-
 	event := map[string]interface{}{
 		"count":     count,
 		"timestamp": time.Now().Unix(),
 	}
 
 	data, _ := json.Marshal(event)
-	message := fmt.Sprintf("event: viewer_count\ndata: %s\n\n", data)
 
 	LogDebug(LogCategorySystem, "Broadcasting viewer count update", map[string]interface{}{
-		"roomId":  roomID,
-		"count":   count,
-		"clients": len(clients),
+		"roomId": roomID,
+		"count":  count,
 	})
 
-	// Send to all connected clients
-	for _, client := range clients {
-		select {
-		case <-client.Done:
-			// Client already disconnected
-			continue
-		default:
-			if _, err := fmt.Fprint(client.Writer, message); err == nil {
-				flushSSE(client.Writer)
-			}
-		}
-	}
+	m.publish(BusEvent{RoomId: roomID, Name: "viewer_count", Data: data}, false)
 }
 
 // BroadcastEmote sends an emote reaction to all clients watching a room
 func (m *SSEManager) BroadcastEmote(roomID int, emote string) {
-	m.mu.RLock()
-	clients := m.clients[roomID]
-	m.mu.RUnlock()
-
-	if len(clients) == 0 {
-		return // No one watching
-	}
-
 	event := map[string]interface{}{
 		"emote":     emote,
 		"timestamp": time.Now().Unix(),
 	}
 
 	data, _ := json.Marshal(event)
-	message := fmt.Sprintf("event: emote\ndata: %s\n\n", data)
 
 	LogDebug(LogCategorySystem, "Broadcasting emote", map[string]interface{}{
-		"roomId":  roomID,
-		"emote":   emote,
-		"clients": len(clients),
+		"roomId": roomID,
+		"emote":  emote,
 	})
 
-	// Send to all connected clients
-	for _, client := range clients {
-		select {
-		case <-client.Done:
-			// Client already disconnected
-			continue
-		default:
-			if _, err := fmt.Fprint(client.Writer, message); err == nil {
-				flushSSE(client.Writer)
-			}
-		}
-	}
+	m.publish(BusEvent{RoomId: roomID, Name: "emote", Data: data}, true)
 }
 
 // BroadcastChatMessage sends a new chat message to all viewers of a room
 func (m *SSEManager) BroadcastChatMessage(roomID int, message ChatMessage) {
-	m.mu.RLock()
-	clients := m.clients[roomID]
-	m.mu.RUnlock()
-
-	if len(clients) == 0 {
-		return // No one watching
-	}
-
 	// Serialize message (without session token for privacy)
 	messageCopy := message
 	messageCopy.SessionToken = ""
@@ -397,66 +552,161 @@ func (m *SSEManager) BroadcastChatMessage(roomID int, message ChatMessage) {
 		return
 	}
 
-	// Format SSE event
-	sseMessage := fmt.Sprintf("event: chat_message\ndata: %s\n\n", data)
-
 	LogDebug(LogCategorySystem, "Broadcasting chat message", map[string]interface{}{
 		"roomId":    roomID,
 		"messageId": message.Id,
 		"userName":  message.UserName,
-		"clients":   len(clients),
 	})
 
-	// Send to all connected clients
-	for _, client := range clients {
-		select {
-		case <-client.Done:
-			// Client already disconnected
-			continue
-		default:
-			if _, err := fmt.Fprint(client.Writer, sseMessage); err == nil {
-				flushSSE(client.Writer)
-			}
-		}
+	m.publish(BusEvent{RoomId: roomID, Name: "chat_message", Data: data}, true)
+}
+
+// BroadcastChatMessageDeleted tells viewers to drop a message removed by a moderator
+func (m *SSEManager) BroadcastChatMessageDeleted(roomID int, messageID int) {
+	event := map[string]interface{}{
+		"messageId": messageID,
+		"timestamp": time.Now().Unix(),
 	}
+
+	data, _ := json.Marshal(event)
+
+	LogDebug(LogCategorySystem, "Broadcasting chat message deletion", map[string]interface{}{
+		"roomId":    roomID,
+		"messageId": messageID,
+	})
+
+	m.publish(BusEvent{RoomId: roomID, Name: "chat_message_deleted", Data: data}, true)
 }
 
-// BroadcastTranscoderError notifies viewers that the transcoder has failed
-func (m *SSEManager) BroadcastTranscoderError(roomID int, errorMsg string) {
-	m.mu.RLock()
-	clients := m.clients[roomID]
-	m.mu.RUnlock()
+// BroadcastChatReaction sends a message's updated reaction counts
+func (m *SSEManager) BroadcastChatReaction(roomID int, messageID int, reactions []ChatReaction) {
+	event := map[string]interface{}{
+		"messageId": messageID,
+		"reactions": reactions,
+		"timestamp": time.Now().Unix(),
+	}
 
-	if len(clients) == 0 {
-		return // No one watching
+	data, _ := json.Marshal(event)
+
+	LogDebug(LogCategorySystem, "Broadcasting chat reaction", map[string]interface{}{
+		"roomId":    roomID,
+		"messageId": messageID,
+	})
+
+	m.publish(BusEvent{RoomId: roomID, Name: "chat_reaction", Data: data}, true)
+}
+
+// BroadcastChatPinned sends a room's pinned announcement (empty text when unpinned)
+func (m *SSEManager) BroadcastChatPinned(roomID int, pin ChatPinnedAnnouncement) {
+	data, _ := json.Marshal(pin)
+
+	LogDebug(LogCategorySystem, "Broadcasting pinned announcement", map[string]interface{}{
+		"roomId": roomID,
+		"pinned": pin.Text != "",
+	})
+
+	m.publish(BusEvent{RoomId: roomID, Name: "chat_pinned", Data: data}, true)
+}
+
+// BroadcastPoll sends a poll's current state and tallies (new, voted on or closed)
+func (m *SSEManager) BroadcastPoll(roomID int, poll Poll) {
+	data, _ := json.Marshal(poll)
+
+	LogDebug(LogCategorySystem, "Broadcasting poll", map[string]interface{}{
+		"roomId": roomID,
+		"pollId": poll.Id,
+		"closed": poll.IsClosed,
+	})
+
+	m.publish(BusEvent{RoomId: roomID, Name: "poll", Data: data}, true)
+}
+
+// BroadcastClassQuestion sends a new or updated viewer question. Dismissed
+// questions are sent too so clients can drop them.
+func (m *SSEManager) BroadcastClassQuestion(roomID int, question ClassQuestion) {
+	data, _ := json.Marshal(question)
+
+	LogDebug(LogCategorySystem, "Broadcasting class question", map[string]interface{}{
+		"roomId":     roomID,
+		"questionId": question.Id,
+		"status":     question.Status,
+	})
+
+	m.publish(BusEvent{RoomId: roomID, Name: "question", Data: data}, true)
+}
+
+// BroadcastChatSettings sends a room's new slow mode and chat on/off state
+func (m *SSEManager) BroadcastChatSettings(roomID int, settings ChatRoomSettings) {
+	event := map[string]interface{}{
+		"slowModeSeconds": settings.SlowModeSeconds,
+		"chatDisabled":    settings.ChatDisabled,
+		"timestamp":       time.Now().Unix(),
+	}
+
+	data, _ := json.Marshal(event)
+
+	LogDebug(LogCategorySystem, "Broadcasting chat settings", map[string]interface{}{
+		"roomId":          roomID,
+		"slowModeSeconds": settings.SlowModeSeconds,
+		"chatDisabled":    settings.ChatDisabled,
+	})
+
+	m.publish(BusEvent{RoomId: roomID, Name: "chat_settings", Data: data}, true)
+}
+
+// BroadcastChatRestricted notifies a timed-out or banned viewer
+// Only sends to clients whose ViewerId matches the restriction
+func (m *SSEManager) BroadcastChatRestricted(roomID int, restriction ChatRestriction) {
+	if restriction.ViewerId == "" {
+		return // No one to notify
 	}
 
+	event := map[string]interface{}{
+		"kind":      restriction.Kind,
+		"reason":    restriction.Reason,
+		"expiresAt": restriction.ExpiresAt,
+		"timestamp": time.Now().Unix(),
+	}
+
+	data, _ := json.Marshal(event)
+
+	LogDebug(LogCategorySystem, "Broadcasting chat restriction", map[string]interface{}{
+		"roomId":        roomID,
+		"restrictionId": restriction.Id,
+	})
+
+	m.publish(BusEvent{RoomId: roomID, Name: "chat_restricted", Data: data, ViewerId: restriction.ViewerId}, false)
+}
+
+// BroadcastTranscoderError notifies viewers that the transcoder has failed
+func (m *SSEManager) BroadcastTranscoderError(roomID int, errorMsg string) {
 	event := map[string]interface{}{
 		"error":     errorMsg,
 		"timestamp": time.Now().Unix(),
 	}
 
 	data, _ := json.Marshal(event)
-	message := fmt.Sprintf("event: transcoder_error\ndata: %s\n\n", data)
 
 	LogWarn(LogCategorySystem, "Broadcasting transcoder error", map[string]interface{}{
-		"roomId":  roomID,
-		"error":   errorMsg,
-		"clients": len(clients),
+		"roomId": roomID,
+		"error":  errorMsg,
 	})
 
-	// Send to all connected clients
-	for _, client := range clients {
-		select {
-		case <-client.Done:
-			// Client already disconnected
-			continue
-		default:
-			if _, err := fmt.Fprint(client.Writer, message); err == nil {
-				flushSSE(client.Writer)
-			}
-		}
+	m.publish(BusEvent{RoomId: roomID, Name: "transcoder_error", Data: data}, false)
+}
+
+// parseLastEventID reads the ID of the last event a reconnecting client saw,
+// from the Last-Event-ID header or the lastEventId query param
+func parseLastEventID(r *http.Request) int {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 0 {
+		return 0
 	}
+	return id
 }
 
 // MakeStreamRoomEventsHandler creates an HTTP handler for SSE connections
@@ -573,17 +823,53 @@ func MakeStreamRoomEventsHandler(db *vbolt.DB) http.HandlerFunc {
 		} else {
 			viewerId = "user:" + fmt.Sprintf("%d", authCtx.User.Id)
 		}
-
-		// Register client
-		sseManager.AddClient(roomID, client)
+		client.ViewerId = viewerId
+
+		// Register client, picking up any events it missed while reconnecting.
+		// Browsers send Last-Event-ID on their own when EventSource reconnects;
+		// the query param covers clients that open a fresh connection.
+		lastEventID := parseLastEventID(r)
+		var missed []sseEvent
+		resumed := true
+		if lastEventID > 0 {
+			missed, resumed = sseManager.AddClientSince(roomID, client, lastEventID)
+		} else {
+			sseManager.AddClient(roomID, client)
+		}
 
 		IncrementRoomViewerCount(db, roomID, viewerId, accessCode)
 
+		// Audit how long code viewers stay connected
+		var watchRecordId int
+		if authCtx.CodeSession != nil {
+			watchRecordId = startCodeWatchRecord(db, roomID, *authCtx.CodeSession)
+		}
+
 		defer func() {
 			DecrementRoomViewerCount(db, roomID, viewerId, accessCode)
+			endCodeWatchRecord(db, watchRecordId)
 			sseManager.RemoveClient(roomID, client)
 		}()
 
+		// Replay missed events, or ask the client to reload if they're gone
+		var replay []string
+		if resumed {
+			for _, event := range missed {
+				replay = append(replay, event.format())
+			}
+		} else {
+			replay = append(replay, resyncMessage())
+		}
+		client.write(replay...)
+		if lastEventID > 0 {
+			LogDebug(LogCategorySystem, "SSE client resumed", map[string]interface{}{
+				"roomId":      roomID,
+				"lastEventId": lastEventID,
+				"replayed":    len(missed),
+				"resync":      !resumed,
+			})
+		}
+
 		// Send initial status immediately
 		initialEvent := map[string]interface{}{
 			"isActive":   room.IsActive,
@@ -591,26 +877,24 @@ func MakeStreamRoomEventsHandler(db *vbolt.DB) http.HandlerFunc {
 			"timestamp":  time.Now().Unix(),
 		}
 		initialData, _ := json.Marshal(initialEvent)
-		fmt.Fprintf(w, "event: status\ndata: %s\n\n", initialData)
-		flushSSE(w)
+		initial := []string{fmt.Sprintf("event: status\ndata: %s\n\n", initialData)}
+
+		// And the room's pinned announcement, if there is one
+		var pin ChatPinnedAnnouncement
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			pin = getChatPinnedAnnouncement(tx, roomID)
+		})
+		if pin.Text != "" {
+			pinData, _ := json.Marshal(pin)
+			initial = append(initial, fmt.Sprintf("event: chat_pinned\ndata: %s\n\n", pinData))
+		}
+		client.write(initial...)
 
-		// Keep connection alive with periodic pings
+		// Write broadcasts as they're queued, with periodic keepalives, until
+		// the client disconnects or falls behind
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
-
-		// Wait for disconnect
-		notify := r.Context().Done()
-		for {
-			select {
-			case <-notify:
-				// Client disconnected
-				close(client.Done)
-				return
-			case <-ticker.C:
-				// Send keepalive comment
-				fmt.Fprintf(w, ": keepalive\n\n")
-				flushSSE(w)
-			}
-		}
+		client.pump(r.Context().Done(), ticker.C)
+		close(client.Done)
 	}
 }
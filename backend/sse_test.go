@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"stream/cfg"
+	"strings"
 	"testing"
 	"time"
 
@@ -695,6 +696,7 @@ func TestViewerCountTracking(t *testing.T) {
 		var sessionToken string
 		var code string
 		var codeExpiresAt time.Time
+		var room Room
 
 		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
 			studio := Studio{
@@ -706,7 +708,7 @@ func TestViewerCountTracking(t *testing.T) {
 			}
 			vbolt.Write(tx, StudiosBkt, studio.Id, &studio)
 
-			room := Room{
+			room = Room{
 				Id:         vbolt.NextIntId(tx, RoomsBkt),
 				StudioId:   studio.Id,
 				RoomNumber: 1,
@@ -743,7 +745,7 @@ func TestViewerCountTracking(t *testing.T) {
 			}
 			vbolt.Write(tx, CodeSessionsBkt, sessionToken, &session)
 
-			// Set initial analytics with 3 viewers
+			// Set initial analytics with 3 viewers already watching
 			analytics := CodeAnalytics{
 				Code:             code,
 				CurrentViewers:   3,
@@ -753,6 +755,9 @@ func TestViewerCountTracking(t *testing.T) {
 
 			vbolt.TxCommit(tx)
 		})
+		for i := 0; i < 3; i++ {
+			joinPresence(db, room, fmt.Sprintf("code:other-%d", i), code)
+		}
 
 		// Verify initial viewer count
 		var beforeViewers int
@@ -1368,3 +1373,115 @@ func TestSSEPeakViewersTracking(t *testing.T) {
 		t.Fatal("SSE handler did not finish in time")
 	}
 }
+
+func TestSSEEventReplay(t *testing.T) {
+	m := newSSEManager()
+	newClient := func() *SSEClient {
+		return &SSEClient{RoomID: 1, Writer: httptest.NewRecorder(), Done: make(chan bool)}
+	}
+
+	m.BroadcastChatMessage(1, ChatMessage{Id: 1, RoomId: 1, Text: "first"})
+	m.BroadcastEmote(1, "👍")
+	m.BroadcastViewerCount(1, 3) // not replayed
+	m.BroadcastEmote(2, "🎉")     // other room
+	first := m.history[1].events[0]
+
+	resumed := newClient()
+	missed, ok := m.AddClientSince(1, resumed, first.Id)
+	if !ok || len(missed) != 1 || missed[0].Name != "emote" {
+		t.Fatalf("Expected the emote to be replayed, got %+v (ok=%v)", missed, ok)
+	}
+	if formatted := missed[0].format(); formatted != fmt.Sprintf("id: %d\nevent: emote\ndata: %s\n\n", missed[0].Id, missed[0].Data) {
+		t.Errorf("Unexpected event format %q", formatted)
+	}
+
+	// Live events wait in the queue while the replay is written, then follow
+	// it with their ID too
+	m.BroadcastChatMessage(1, ChatMessage{Id: 2, RoomId: 1, Text: "second"})
+	body := &syncWriter{}
+	resumed.Writer = body
+	resumed.write(missed[0].format())
+	pumpTestClient(t, resumed)
+	waitFor(t, "the live event after the replay", func() bool {
+		return strings.Contains(body.String(), "second")
+	})
+	if expected := missed[0].format() + fmt.Sprintf("id: %d\nevent: chat_message\n", m.lastEventID); !strings.HasPrefix(body.String(), expected) {
+		t.Errorf("Expected the replay and then the live event, got %q", body.String())
+	}
+	m.BroadcastEmote(1, "🎉")
+	waitFor(t, "live events once the replay is written", func() bool {
+		return strings.Contains(body.String(), "🎉")
+	})
+
+	// An ID from before the server started can't be resumed
+	if _, ok := m.AddClientSince(1, newClient(), first.Id-100); ok {
+		t.Errorf("Expected an ID from an earlier run to need a reload")
+	}
+
+	// Neither can a gap longer than the buffer
+	for i := 0; i < SSE_REPLAY_BUFFER_SIZE; i++ {
+		m.BroadcastEmote(1, "👏")
+	}
+	if len(m.history[1].events) != SSE_REPLAY_BUFFER_SIZE {
+		t.Errorf("Expected the buffer to be capped at %d, got %d", SSE_REPLAY_BUFFER_SIZE, len(m.history[1].events))
+	}
+	if _, ok := m.AddClientSince(1, newClient(), first.Id); ok {
+		t.Errorf("Expected a gap longer than the buffer to need a reload")
+	}
+	missed, ok = m.AddClientSince(1, newClient(), m.lastEventID)
+	if !ok || len(missed) != 0 {
+		t.Errorf("Expected a caught-up client to get nothing, got %d (ok=%v)", len(missed), ok)
+	}
+}
+
+// A client that stops reading is dropped once its queue fills, without
+// holding up delivery to the others
+func TestSSESlowClientDropped(t *testing.T) {
+	m := newSSEManager()
+	stalled := &SSEClient{RoomID: 1, Writer: &syncWriter{}, Done: make(chan bool)}
+	m.AddClient(1, stalled) // Its handler never writes
+	w := &syncWriter{}
+	reading := &SSEClient{RoomID: 1, Writer: w, Done: make(chan bool)}
+	m.AddClient(1, reading)
+	pumpTestClient(t, reading)
+
+	for i := 0; i <= SSE_CLIENT_QUEUE_SIZE; i++ {
+		m.BroadcastEmote(1, fmt.Sprintf("emote-%d", i))
+		waitFor(t, "the reading client to keep up", func() bool { return len(reading.queue) == 0 })
+	}
+	select {
+	case <-stalled.dropped:
+	default:
+		t.Errorf("Expected the stalled client to be dropped")
+	}
+	waitFor(t, "every event on the reading client", func() bool {
+		return strings.Contains(w.String(), fmt.Sprintf("emote-%d\"", SSE_CLIENT_QUEUE_SIZE))
+	})
+
+	// The handler of a dropped client returns so the browser reconnects
+	finished := make(chan struct{})
+	go func() {
+		stalled.pump(nil, nil)
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the dropped client's handler to return")
+	}
+}
+
+// pumpTestClient writes a registered client's queued events until the test
+// ends, as the connection's handler would
+func pumpTestClient(t *testing.T, client *SSEClient) {
+	closed := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		client.pump(closed, nil)
+		close(finished)
+	}()
+	t.Cleanup(func() {
+		close(closed)
+		<-finished
+	})
+}
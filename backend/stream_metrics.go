@@ -65,6 +65,9 @@ func ReportStreamMetrics(ctx *vbeam.Context, req ReportStreamMetricsRequest) (re
 	// Update studio analytics
 	updateStudioPerformanceMetrics(ctx.Tx, room.StudioId, req.Metrics)
 
+	// Attribute watch time to the code session's watch record (code viewers only)
+	recordCodeWatchSecondsTx(ctx.Tx, codeSessionTokenFromContext(ctx), req.Metrics.RoomId, req.Metrics.WatchSeconds)
+
 	vbolt.TxCommit(ctx.Tx)
 
 	return resp, nil
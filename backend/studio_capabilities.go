@@ -0,0 +1,473 @@
+package backend
+
+import (
+	"errors"
+	"sort"
+	"stream/cfg"
+	"strings"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+// StudioCapability is one thing a member may do in a studio. The built-in roles
+// map to default capability sets; custom roles pick their own.
+type StudioCapability int
+
+const (
+	CapViewStudio      StudioCapability = 0 // See rooms, schedules, codes and camera status
+	CapControlCameras  StudioCapability = 1 // Start/stop ingest and edit camera settings
+	CapManageCodes     StudioCapability = 2 // Generate and revoke access codes, kick devices
+	CapViewAnalytics   StudioCapability = 3 // Code analytics and watch reports
+	CapManageSchedules StudioCapability = 4 // Class schedules and class permissions
+	CapModerateChat    StudioCapability = 5 // Remove messages and silence viewers
+)
+
+const MAX_CUSTOM_ROLE_NAME = 50
+
+type StudioCapabilityInfo struct {
+	Capability  StudioCapability `json:"capability"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+}
+
+// StudioCapabilities lists every capability in display order
+var StudioCapabilities = []StudioCapabilityInfo{
+	{CapViewStudio, "View studio", "See rooms, class schedules, access codes and camera status"},
+	{CapControlCameras, "Control cameras", "Start and stop camera ingest and edit camera settings"},
+	{CapManageCodes, "Manage access codes", "Generate and revoke access codes and sign out devices"},
+	{CapViewAnalytics, "View analytics", "See access code analytics and watch reports"},
+	{CapManageSchedules, "Manage schedules", "Create and edit class schedules and class permissions"},
+	{CapModerateChat, "Moderate chat", "Remove chat messages and silence viewers"},
+}
+
+func isValidStudioCapability(capability StudioCapability) bool {
+	for _, info := range StudioCapabilities {
+		if info.Capability == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func allStudioCapabilities() []StudioCapability {
+	caps := make([]StudioCapability, 0, len(StudioCapabilities))
+	for _, info := range StudioCapabilities {
+		caps = append(caps, info.Capability)
+	}
+	return caps
+}
+
+// DefaultStudioCapabilities returns what a built-in role may do
+func DefaultStudioCapabilities(role StudioRole) []StudioCapability {
+	if role >= StudioRoleAdmin {
+		return allStudioCapabilities()
+	}
+	if role >= StudioRoleViewer {
+		return []StudioCapability{CapViewStudio}
+	}
+	return nil
+}
+
+// StudioCustomRole is a named capability set defined by a studio's owner. A
+// member with a custom role keeps their built-in role for member management;
+// the custom role replaces its capabilities.
+type StudioCustomRole struct {
+	Id           int                `json:"id"`
+	StudioId     int                `json:"studioId"`
+	Name         string             `json:"name"`
+	Capabilities []StudioCapability `json:"capabilities"`
+	CreatedBy    int                `json:"createdBy"`
+	CreatedAt    time.Time          `json:"createdAt"`
+}
+
+func packStudioCapability(self *StudioCapability, buf *vpack.Buffer) {
+	vpack.Int((*int)(self), buf)
+}
+
+func PackStudioCustomRole(self *StudioCustomRole, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.String(&self.Name, buf)
+	vpack.Slice(&self.Capabilities, packStudioCapability, buf)
+	vpack.Int(&self.CreatedBy, buf)
+	vpack.Time(&self.CreatedAt, buf)
+}
+
+var StudioCustomRolesBkt = vbolt.Bucket(&cfg.Info, "studio_custom_roles", vpack.FInt, PackStudioCustomRole)
+
+// studioId (term) -> customRoleId (target)
+var CustomRolesByStudioIdx = vbolt.Index(&cfg.Info, "custom_roles_by_studio", vpack.FInt, vpack.FInt)
+
+func getStudioCustomRole(tx *vbolt.Tx, roleId int) (role StudioCustomRole) {
+	vbolt.Read(tx, StudioCustomRolesBkt, roleId, &role)
+	return
+}
+
+func listStudioCustomRoles(tx *vbolt.Tx, studioId int) []StudioCustomRole {
+	var roleIds []int
+	vbolt.ReadTermTargets(tx, CustomRolesByStudioIdx, studioId, &roleIds, vbolt.Window{})
+	roles := []StudioCustomRole{}
+	for _, roleId := range roleIds {
+		if role := getStudioCustomRole(tx, roleId); role.Id > 0 {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// getStudioMembershipTx returns the user's membership in a studio and its id, or
+// a zero id if they aren't a member
+func getStudioMembershipTx(tx *vbolt.Tx, userId int, studioId int) (membershipId int, membership StudioMembership) {
+	for _, id := range userMembershipIdsTx(tx, userId) {
+		m := GetMembership(tx, id)
+		if m.StudioId == studioId {
+			return id, m
+		}
+	}
+	return 0, StudioMembership{}
+}
+
+// membershipCapabilitiesTx resolves a membership's capabilities from its custom
+// role, falling back to the built-in role's defaults. Owners can do everything.
+func membershipCapabilitiesTx(tx *vbolt.Tx, membership StudioMembership) []StudioCapability {
+	if membership.Role == StudioRoleOwner {
+		return allStudioCapabilities()
+	}
+	if membership.CustomRoleId > 0 {
+		role := getStudioCustomRole(tx, membership.CustomRoleId)
+		if role.Id > 0 && role.StudioId == membership.StudioId {
+			return withCapability(role.Capabilities, CapViewStudio)
+		}
+	}
+	return DefaultStudioCapabilities(membership.Role)
+}
+
+// setMembershipRole changes a membership's built-in role. Owners ignore custom
+// roles, so one doesn't survive becoming or stepping down as owner; otherwise a
+// demoted owner would pick up whatever role they held before.
+func setMembershipRole(membership *StudioMembership, role StudioRole) {
+	if (membership.Role == StudioRoleOwner) != (role == StudioRoleOwner) {
+		membership.CustomRoleId = 0
+	}
+	membership.Role = role
+}
+
+// memberRoleName is the custom role's name if one is assigned, otherwise the
+// built-in role's
+func memberRoleName(tx *vbolt.Tx, membership StudioMembership) string {
+	if membership.CustomRoleId > 0 && membership.Role != StudioRoleOwner {
+		if role := getStudioCustomRole(tx, membership.CustomRoleId); role.Id > 0 {
+			return role.Name
+		}
+	}
+	return GetStudioRoleName(membership.Role)
+}
+
+func withCapability(caps []StudioCapability, capability StudioCapability) []StudioCapability {
+	for _, c := range caps {
+		if c == capability {
+			return caps
+		}
+	}
+	return append([]StudioCapability{capability}, caps...)
+}
+
+func hasCapability(caps []StudioCapability, capability StudioCapability) bool {
+	for _, c := range caps {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStudioCapabilities returns what the user may do in a studio. Site admins
+// can do everything; non-members nothing.
+func GetStudioCapabilities(tx *vbolt.Tx, userId int, studioId int) []StudioCapability {
+	if GetUser(tx, userId).Role == RoleSiteAdmin {
+		return allStudioCapabilities()
+	}
+	membershipId, membership := getStudioMembershipTx(tx, userId, studioId)
+	if membershipId == 0 {
+		return []StudioCapability{}
+	}
+	return membershipCapabilitiesTx(tx, membership)
+}
+
+// HasStudioCapability checks if a user may do something in a studio
+func HasStudioCapability(tx *vbolt.Tx, userId int, studioId int, capability StudioCapability) bool {
+	return hasCapability(GetStudioCapabilities(tx, userId, studioId), capability)
+}
+
+// normalizeCapabilities validates, de-duplicates and sorts a capability list
+func normalizeCapabilities(caps []StudioCapability) ([]StudioCapability, error) {
+	seen := make(map[StudioCapability]bool)
+	result := []StudioCapability{}
+	for _, c := range caps {
+		if !isValidStudioCapability(c) {
+			return nil, errors.New("Unknown capability")
+		}
+		if !seen[c] {
+			seen[c] = true
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return withCapability(result, CapViewStudio), nil
+}
+
+// RegisterStudioCapabilityMethods registers custom studio role procedures
+func RegisterStudioCapabilityMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, ListStudioRoles)
+	vbeam.RegisterProc(app, SaveStudioCustomRole)
+	vbeam.RegisterProc(app, DeleteStudioCustomRole)
+	vbeam.RegisterProc(app, SetMemberCustomRole)
+}
+
+// Request/Response types
+type ListStudioRolesRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type BuiltinStudioRole struct {
+	Role         StudioRole         `json:"role"`
+	Name         string             `json:"name"`
+	Capabilities []StudioCapability `json:"capabilities"`
+}
+
+type ListStudioRolesResponse struct {
+	Capabilities   []StudioCapabilityInfo `json:"capabilities"`
+	BuiltinRoles   []BuiltinStudioRole    `json:"builtinRoles"`
+	CustomRoles    []StudioCustomRole     `json:"customRoles"`
+	MyCapabilities []StudioCapability     `json:"myCapabilities"`
+}
+
+type SaveStudioCustomRoleRequest struct {
+	StudioId     int                `json:"studioId"`
+	RoleId       int                `json:"roleId"` // 0 to create a new role
+	Name         string             `json:"name"`
+	Capabilities []StudioCapability `json:"capabilities"`
+}
+
+type SaveStudioCustomRoleResponse struct {
+	Role StudioCustomRole `json:"role"`
+}
+
+type DeleteStudioCustomRoleRequest struct {
+	RoleId int `json:"roleId"`
+}
+
+type DeleteStudioCustomRoleResponse struct {
+	MembersReset int `json:"membersReset"` // members moved back to their built-in role
+}
+
+type SetMemberCustomRoleRequest struct {
+	StudioId     int `json:"studioId"`
+	UserId       int `json:"userId"`
+	CustomRoleId int `json:"customRoleId"` // 0 to use the built-in role's defaults
+}
+
+type SetMemberCustomRoleResponse struct {
+	Membership StudioMembership `json:"membership"`
+}
+
+// API Procedures
+
+// ListStudioRoles returns the capability catalogue, the built-in role defaults
+// and the studio's custom roles. Any member can see them.
+func ListStudioRoles(ctx *vbeam.Context, req ListStudioRolesRequest) (resp ListStudioRolesResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, req.StudioId, CapViewStudio) {
+		return resp, errors.New("You are not a member of this studio")
+	}
+
+	resp.Capabilities = StudioCapabilities
+	for _, role := range []StudioRole{StudioRoleViewer, StudioRoleMember, StudioRoleAdmin, StudioRoleOwner} {
+		resp.BuiltinRoles = append(resp.BuiltinRoles, BuiltinStudioRole{
+			Role:         role,
+			Name:         GetStudioRoleName(role),
+			Capabilities: DefaultStudioCapabilities(role),
+		})
+	}
+	resp.CustomRoles = listStudioCustomRoles(ctx.Tx, req.StudioId)
+	resp.MyCapabilities = GetStudioCapabilities(ctx.Tx, caller.Id, req.StudioId)
+	return
+}
+
+// SaveStudioCustomRole creates or updates a custom role. Only studio owners can
+// define roles.
+func SaveStudioCustomRole(ctx *vbeam.Context, req SaveStudioCustomRoleRequest) (resp SaveStudioCustomRoleResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	studio := GetStudioById(ctx.Tx, req.StudioId)
+	if studio.Id == 0 {
+		return resp, errors.New("Studio not found")
+	}
+	if !HasStudioPermission(ctx.Tx, caller.Id, studio.Id, StudioRoleOwner) {
+		return resp, errors.New("Only studio owners can manage roles")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return resp, errors.New("Role name is required")
+	}
+	if len(name) > MAX_CUSTOM_ROLE_NAME {
+		return resp, errors.New("Role name is too long")
+	}
+	caps, err := normalizeCapabilities(req.Capabilities)
+	if err != nil {
+		return
+	}
+
+	role := StudioCustomRole{
+		StudioId:  studio.Id,
+		CreatedBy: caller.Id,
+		CreatedAt: time.Now(),
+	}
+	if req.RoleId > 0 {
+		role = getStudioCustomRole(ctx.Tx, req.RoleId)
+		if role.Id == 0 || role.StudioId != studio.Id {
+			return resp, errors.New("Role not found")
+		}
+	}
+	for _, existing := range listStudioCustomRoles(ctx.Tx, studio.Id) {
+		if existing.Id != role.Id && strings.EqualFold(existing.Name, name) {
+			return resp, errors.New("A role with that name already exists")
+		}
+	}
+	for _, builtin := range []StudioRole{StudioRoleViewer, StudioRoleMember, StudioRoleAdmin, StudioRoleOwner} {
+		if strings.EqualFold(GetStudioRoleName(builtin), name) {
+			return resp, errors.New("That name is used by a built-in role")
+		}
+	}
+
+	vbeam.UseWriteTx(ctx)
+	if role.Id == 0 {
+		role.Id = vbolt.NextIntId(ctx.Tx, StudioCustomRolesBkt)
+		vbolt.SetTargetSingleTerm(ctx.Tx, CustomRolesByStudioIdx, role.Id, studio.Id)
+	}
+	role.Name = name
+	role.Capabilities = caps
+	vbolt.Write(ctx.Tx, StudioCustomRolesBkt, role.Id, &role)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio custom role saved", map[string]interface{}{
+		"studioId":     studio.Id,
+		"roleId":       role.Id,
+		"name":         role.Name,
+		"capabilities": role.Capabilities,
+		"savedBy":      caller.Id,
+	})
+
+	resp.Role = role
+	return
+}
+
+// DeleteStudioCustomRole removes a custom role. Members who had it go back to
+// their built-in role's capabilities.
+func DeleteStudioCustomRole(ctx *vbeam.Context, req DeleteStudioCustomRoleRequest) (resp DeleteStudioCustomRoleResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	role := getStudioCustomRole(ctx.Tx, req.RoleId)
+	if role.Id == 0 {
+		return resp, errors.New("Role not found")
+	}
+	if !HasStudioPermission(ctx.Tx, caller.Id, role.StudioId, StudioRoleOwner) {
+		return resp, errors.New("Only studio owners can manage roles")
+	}
+
+	var membershipIds []int
+	vbolt.ReadTermTargets(ctx.Tx, MembershipByStudioIdx, role.StudioId, &membershipIds, vbolt.Window{})
+
+	vbeam.UseWriteTx(ctx)
+	for _, membershipId := range membershipIds {
+		membership := GetMembership(ctx.Tx, membershipId)
+		if membership.CustomRoleId == role.Id {
+			membership.CustomRoleId = 0
+			vbolt.Write(ctx.Tx, MembershipBkt, membershipId, &membership)
+			resp.MembersReset++
+		}
+	}
+	vbolt.SetTargetSingleTerm(ctx.Tx, CustomRolesByStudioIdx, role.Id, -1)
+	vbolt.Delete(ctx.Tx, StudioCustomRolesBkt, role.Id)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio custom role deleted", map[string]interface{}{
+		"studioId":     role.StudioId,
+		"roleId":       role.Id,
+		"name":         role.Name,
+		"membersReset": resp.MembersReset,
+		"deletedBy":    caller.Id,
+	})
+	return
+}
+
+// SetMemberCustomRole gives a member a custom role, or clears it. Admins can only
+// hand out capabilities they have themselves, and only owners can change admins.
+func SetMemberCustomRole(ctx *vbeam.Context, req SetMemberCustomRoleRequest) (resp SetMemberCustomRoleResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	studio := GetStudioById(ctx.Tx, req.StudioId)
+	if studio.Id == 0 {
+		return resp, errors.New("Studio not found")
+	}
+	if !HasStudioPermission(ctx.Tx, caller.Id, studio.Id, StudioRoleAdmin) {
+		return resp, errors.New("Only studio admins can assign roles")
+	}
+
+	membershipId, membership := getStudioMembershipTx(ctx.Tx, req.UserId, studio.Id)
+	if membershipId == 0 {
+		return resp, errors.New("User is not a member of this studio")
+	}
+	if membership.Role == StudioRoleOwner {
+		return resp, errors.New("Owners always have every capability")
+	}
+	isOwner := HasStudioPermission(ctx.Tx, caller.Id, studio.Id, StudioRoleOwner)
+	if membership.Role >= StudioRoleAdmin && !isOwner {
+		return resp, errors.New("Only studio owners can change an admin's role")
+	}
+
+	if req.CustomRoleId > 0 {
+		role := getStudioCustomRole(ctx.Tx, req.CustomRoleId)
+		if role.Id == 0 || role.StudioId != studio.Id {
+			return resp, errors.New("Role not found")
+		}
+		callerCaps := GetStudioCapabilities(ctx.Tx, caller.Id, studio.Id)
+		for _, c := range role.Capabilities {
+			if !hasCapability(callerCaps, c) {
+				return resp, errors.New("You can't assign a role with capabilities you don't have")
+			}
+		}
+	}
+
+	vbeam.UseWriteTx(ctx)
+	membership.CustomRoleId = req.CustomRoleId
+	vbolt.Write(ctx.Tx, MembershipBkt, membershipId, &membership)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio member custom role set", map[string]interface{}{
+		"studioId":     studio.Id,
+		"userId":       req.UserId,
+		"customRoleId": req.CustomRoleId,
+		"updatedBy":    caller.Id,
+	})
+
+	resp.Membership = membership
+	return
+}
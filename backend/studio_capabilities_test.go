@@ -0,0 +1,210 @@
+package backend
+
+import (
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestStudioCustomRoles(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	owner := addTestStudioMember(t, db, studio.Id, "owner@test.com", StudioRoleOwner)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+
+	can := func(userId int, capability StudioCapability) (ok bool) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			ok = HasStudioCapability(tx, userId, studio.Id, capability)
+		})
+		return
+	}
+
+	// Built-in roles keep their old access
+	if !can(admin.Id, CapManageCodes) || !can(member.Id, CapViewStudio) || can(member.Id, CapManageCodes) {
+		t.Fatalf("Expected default capabilities to match the built-in roles")
+	}
+
+	save := func(callerId int, req SaveStudioCustomRoleRequest) (resp SaveStudioCustomRoleResponse, err error) {
+		token, _ := createTestToken(callerId)
+		req.StudioId = studio.Id
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = SaveStudioCustomRole(&vbeam.Context{Tx: tx, Token: token}, req)
+		})
+		return
+	}
+	assign := func(callerId int, userId int, roleId int) (err error) {
+		token, _ := createTestToken(callerId)
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err = SetMemberCustomRole(&vbeam.Context{Tx: tx, Token: token}, SetMemberCustomRoleRequest{StudioId: studio.Id, UserId: userId, CustomRoleId: roleId})
+		})
+		return
+	}
+
+	if _, err := save(admin.Id, SaveStudioCustomRoleRequest{Name: "Camera Operator", Capabilities: []StudioCapability{CapControlCameras}}); err == nil {
+		t.Errorf("Expected only owners to define roles")
+	}
+	if _, err := save(owner.Id, SaveStudioCustomRoleRequest{Name: "Admin"}); err == nil {
+		t.Errorf("Expected a built-in role name to be refused")
+	}
+	if _, err := save(owner.Id, SaveStudioCustomRoleRequest{Name: "Broken", Capabilities: []StudioCapability{99}}); err == nil {
+		t.Errorf("Expected an unknown capability to be refused")
+	}
+
+	operator, err := save(owner.Id, SaveStudioCustomRoleRequest{Name: "Camera Operator", Capabilities: []StudioCapability{CapControlCameras, CapControlCameras}})
+	if err != nil {
+		t.Fatalf("SaveStudioCustomRole failed: %v", err)
+	}
+	if caps := operator.Role.Capabilities; len(caps) != 2 || caps[0] != CapViewStudio || caps[1] != CapControlCameras {
+		t.Errorf("Expected capabilities to be normalized, got %v", caps)
+	}
+	if _, err := save(owner.Id, SaveStudioCustomRoleRequest{Name: "camera operator"}); err == nil {
+		t.Errorf("Expected duplicate role names to be refused")
+	}
+
+	if err := assign(admin.Id, member.Id, operator.Role.Id); err != nil {
+		t.Fatalf("SetMemberCustomRole failed: %v", err)
+	}
+	if !can(member.Id, CapControlCameras) || can(member.Id, CapManageCodes) {
+		t.Errorf("Expected the member to get exactly the custom role's capabilities")
+	}
+	if err := assign(admin.Id, owner.Id, operator.Role.Id); err == nil {
+		t.Errorf("Expected the owner's capabilities to be fixed")
+	}
+
+	// Narrowing an admin is for owners, and then admins lose what the role lacks
+	if err := assign(admin.Id, admin.Id, operator.Role.Id); err == nil {
+		t.Errorf("Expected admins to be refused changing an admin")
+	}
+	if err := assign(owner.Id, admin.Id, operator.Role.Id); err != nil {
+		t.Fatalf("SetMemberCustomRole failed: %v", err)
+	}
+	if can(admin.Id, CapManageCodes) {
+		t.Errorf("Expected the custom role to replace the admin defaults")
+	}
+
+	// Invite links and tickets mint codes, so they need CapManageCodes too
+	adminToken, _ := createTestToken(admin.Id)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: adminToken}
+		if _, err := CreateInviteLink(ctx, CreateInviteLinkRequest{RoomId: room.Id, DurationMinutes: 60}); err == nil {
+			t.Errorf("Expected an admin without CapManageCodes to be refused invite links")
+		}
+		if _, err := CreateTicketProduct(ctx, CreateTicketProductRequest{Type: int(CodeTypeRoom), TargetId: room.Id, Name: "Recital"}); err == nil {
+			t.Errorf("Expected an admin without CapManageCodes to be refused ticket products")
+		}
+	})
+
+	moderator, _ := save(owner.Id, SaveStudioCustomRoleRequest{Name: "Moderator", Capabilities: []StudioCapability{CapModerateChat}})
+	if err := assign(admin.Id, member.Id, moderator.Role.Id); err == nil {
+		t.Errorf("Expected admins to be refused handing out capabilities they lack")
+	}
+
+	token, _ := createTestToken(member.Id)
+	var dashboard GetStudioDashboardResponse
+	var roles ListStudioRolesResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: token}
+		dashboard, _ = GetStudioDashboard(ctx, GetStudioDashboardRequest{StudioId: studio.Id})
+		roles, err = ListStudioRoles(ctx, ListStudioRolesRequest{StudioId: studio.Id})
+	})
+	if err != nil || len(roles.CustomRoles) != 2 || len(roles.BuiltinRoles) != 4 {
+		t.Fatalf("ListStudioRoles failed: %v %+v", err, roles)
+	}
+	if len(dashboard.MyCapabilities) != 2 {
+		t.Errorf("Expected the dashboard to report the member's capabilities, got %v", dashboard.MyCapabilities)
+	}
+	for _, m := range dashboard.Members {
+		if m.UserId == member.Id && m.RoleName != "Camera Operator" {
+			t.Errorf("Expected the custom role name in the member list, got %q", m.RoleName)
+		}
+	}
+
+	// Deleting a role puts its members back on their defaults
+	ownerToken, _ := createTestToken(owner.Id)
+	var deleted DeleteStudioCustomRoleResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		deleted, err = DeleteStudioCustomRole(&vbeam.Context{Tx: tx, Token: ownerToken}, DeleteStudioCustomRoleRequest{RoleId: operator.Role.Id})
+	})
+	if err != nil || deleted.MembersReset != 2 {
+		t.Fatalf("DeleteStudioCustomRole failed: %v %+v", err, deleted)
+	}
+	if !can(admin.Id, CapManageCodes) || can(member.Id, CapControlCameras) {
+		t.Errorf("Expected members to return to their default capabilities")
+	}
+}
+
+// A custom role doesn't survive becoming owner, so stepping down again lands on
+// the built-in role's defaults rather than the role held before
+func TestStudioOwnerRoleClearsCustomRole(t *testing.T) {
+	db, _, studio, _ := setupTestStudioRoom(t)
+	owner := addTestStudioMember(t, db, studio.Id, "owner@test.com", StudioRoleOwner)
+	promoted := addTestStudioMember(t, db, studio.Id, "promoted@test.com", StudioRoleMember)
+	transferred := addTestStudioMember(t, db, studio.Id, "transferred@test.com", StudioRoleAdmin)
+	ownerToken, _ := createTestToken(owner.Id)
+
+	customRoleId := func(userId int) (roleId int) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, membership := getStudioMembershipTx(tx, userId, studio.Id)
+			roleId = membership.CustomRoleId
+		})
+		return
+	}
+	can := func(userId int, capability StudioCapability) (ok bool) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			ok = HasStudioCapability(tx, userId, studio.Id, capability)
+		})
+		return
+	}
+
+	var operator SaveStudioCustomRoleResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		operator, err = SaveStudioCustomRole(&vbeam.Context{Tx: tx, Token: ownerToken}, SaveStudioCustomRoleRequest{StudioId: studio.Id, Name: "Camera Operator", Capabilities: []StudioCapability{CapControlCameras}})
+	})
+	if err != nil {
+		t.Fatalf("SaveStudioCustomRole failed: %v", err)
+	}
+	for _, userId := range []int{promoted.Id, transferred.Id} {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err = SetMemberCustomRole(&vbeam.Context{Tx: tx, Token: ownerToken}, SetMemberCustomRoleRequest{StudioId: studio.Id, UserId: userId, CustomRoleId: operator.Role.Id})
+		})
+		if err != nil {
+			t.Fatalf("SetMemberCustomRole failed: %v", err)
+		}
+	}
+
+	// Promoted to owner and demoted again through the member list
+	setRole := func(role StudioRole) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err = UpdateStudioMemberRole(&vbeam.Context{Tx: tx, Token: ownerToken}, UpdateStudioMemberRoleRequest{StudioId: studio.Id, UserId: promoted.Id, NewRole: role})
+		})
+		if err != nil {
+			t.Fatalf("UpdateStudioMemberRole failed: %v", err)
+		}
+	}
+	setRole(StudioRoleOwner)
+	if customRoleId(promoted.Id) != 0 {
+		t.Errorf("Expected the custom role to be cleared on becoming owner")
+	}
+	setRole(StudioRoleMember)
+	if can(promoted.Id, CapControlCameras) || !can(promoted.Id, CapViewStudio) {
+		t.Errorf("Expected the demoted member to have the member defaults")
+	}
+
+	// Ownership handed over and back leaves the admin with the admin defaults
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		transferStudioOwnershipTx(tx, GetStudioById(tx, studio.Id), transferred.Id)
+		vbolt.TxCommit(tx)
+	})
+	if customRoleId(transferred.Id) != 0 {
+		t.Errorf("Expected the custom role to be cleared on taking ownership")
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		transferStudioOwnershipTx(tx, GetStudioById(tx, studio.Id), owner.Id)
+		vbolt.TxCommit(tx)
+	})
+	if !can(transferred.Id, CapManageCodes) {
+		t.Errorf("Expected the previous owner to have the admin defaults")
+	}
+}
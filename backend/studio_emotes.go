@@ -0,0 +1,518 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"stream/cfg"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const MAX_STUDIO_EMOTES = 24
+const MAX_EMOTE_IMAGE_BYTES = 64 * 1024
+const MAX_EMOTE_IMAGE_PIXELS = 128 // Width and height
+
+// emoteDir holds uploaded emote images as <studioId>/<hash>.<ext>
+var emoteDir = cfg.EmoteDir
+
+var emoteNamePattern = regexp.MustCompile(`^[a-z0-9_]{2,20}$`)
+
+var emoteImageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/jpeg": ".jpg",
+}
+
+// StudioEmote is one emote viewers can send. Unicode emotes are the emoji
+// itself; uploaded images use a ":name:" code and are served from /emotes/.
+type StudioEmote struct {
+	Code     string `json:"code"`
+	ImageURL string `json:"imageUrl"` // Empty for unicode emotes
+}
+
+// StudioEmoteSet is a studio's own emotes. Studios without a record use
+// AllowedEmotes.
+type StudioEmoteSet struct {
+	StudioId  int           `json:"studioId"`
+	Emotes    []StudioEmote `json:"emotes"`
+	UpdatedBy int           `json:"updatedBy"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// EmoteCount is how many times one emote was sent
+type EmoteCount struct {
+	Code  string `json:"code"`
+	Count int    `json:"count"`
+}
+
+// StreamEmoteCounts tallies emotes sent during a stream session
+type StreamEmoteCounts struct {
+	StreamId int          `json:"streamId"`
+	Counts   []EmoteCount `json:"counts"`
+}
+
+func PackStudioEmote(self *StudioEmote, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.String(&self.Code, buf)
+	vpack.String(&self.ImageURL, buf)
+}
+
+func PackStudioEmoteSet(self *StudioEmoteSet, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.Slice(&self.Emotes, PackStudioEmote, buf)
+	vpack.Int(&self.UpdatedBy, buf)
+	vpack.Time(&self.UpdatedAt, buf)
+}
+
+func PackEmoteCount(self *EmoteCount, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.String(&self.Code, buf)
+	vpack.Int(&self.Count, buf)
+}
+
+func PackStreamEmoteCounts(self *StreamEmoteCounts, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.StreamId, buf)
+	vpack.Slice(&self.Counts, PackEmoteCount, buf)
+}
+
+// StudioEmoteSetsBkt stores emote sets by studio ID
+var StudioEmoteSetsBkt = vbolt.Bucket(&cfg.Info, "studio_emote_sets", vpack.FInt, PackStudioEmoteSet)
+
+// StreamEmoteCountsBkt stores emote tallies by stream ID
+var StreamEmoteCountsBkt = vbolt.Bucket(&cfg.Info, "stream_emote_counts", vpack.FInt, PackStreamEmoteCounts)
+
+func defaultStudioEmotes() []StudioEmote {
+	emotes := make([]StudioEmote, len(AllowedEmotes))
+	for i, emote := range AllowedEmotes {
+		emotes[i] = StudioEmote{Code: emote}
+	}
+	return emotes
+}
+
+// studioEmotes returns the emotes a studio's viewers can send
+func studioEmotes(tx *vbolt.Tx, studioId int) []StudioEmote {
+	var set StudioEmoteSet
+	vbolt.Read(tx, StudioEmoteSetsBkt, studioId, &set)
+	if len(set.Emotes) == 0 {
+		return defaultStudioEmotes()
+	}
+	return set.Emotes
+}
+
+func findStudioEmote(tx *vbolt.Tx, studioId int, code string) (StudioEmote, bool) {
+	for _, emote := range studioEmotes(tx, studioId) {
+		if emote.Code == code {
+			return emote, true
+		}
+	}
+	return StudioEmote{}, false
+}
+
+// isValidUnicodeEmote accepts a short emoji sequence: no letters, spaces or
+// control characters, and at least one symbol outside ASCII
+func isValidUnicodeEmote(code string) bool {
+	if code == "" || utf8.RuneCountInString(code) > 8 {
+		return false
+	}
+	hasSymbol := false
+	for _, r := range code {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+		if r >= 0x2000 {
+			hasSymbol = true
+		}
+	}
+	return hasSymbol
+}
+
+// recordStreamEmoteTx counts one emote sent during a stream
+func recordStreamEmoteTx(tx *vbolt.Tx, streamId int, code string) {
+	var tally StreamEmoteCounts
+	vbolt.Read(tx, StreamEmoteCountsBkt, streamId, &tally)
+	tally.StreamId = streamId
+	found := false
+	for i := range tally.Counts {
+		if tally.Counts[i].Code == code {
+			tally.Counts[i].Count++
+			found = true
+			break
+		}
+	}
+	if !found {
+		tally.Counts = append(tally.Counts, EmoteCount{Code: code, Count: 1})
+	}
+	vbolt.Write(tx, StreamEmoteCountsBkt, streamId, &tally)
+}
+
+// decodeEmoteImage checks an uploaded image (base64, optionally a data URL)
+// and returns its bytes and file extension
+func decodeEmoteImage(imageData string) (data []byte, ext string, err error) {
+	if i := strings.Index(imageData, ","); strings.HasPrefix(imageData, "data:") && i >= 0 {
+		imageData = imageData[i+1:]
+	}
+	data, err = base64.StdEncoding.DecodeString(imageData)
+	if err != nil || len(data) == 0 {
+		return nil, "", errors.New("Invalid image data")
+	}
+	if len(data) > MAX_EMOTE_IMAGE_BYTES {
+		return nil, "", fmt.Errorf("Emote images must be %d KB or smaller", MAX_EMOTE_IMAGE_BYTES/1024)
+	}
+	ext, ok := emoteImageTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, "", errors.New("Emote images must be PNG, GIF or JPEG")
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errors.New("Invalid image data")
+	}
+	if config.Width > MAX_EMOTE_IMAGE_PIXELS || config.Height > MAX_EMOTE_IMAGE_PIXELS {
+		return nil, "", fmt.Errorf("Emote images must be at most %dx%d pixels", MAX_EMOTE_IMAGE_PIXELS, MAX_EMOTE_IMAGE_PIXELS)
+	}
+	return data, ext, nil
+}
+
+// emoteImagePath maps an emote image URL to its file, or "" if it isn't one
+func emoteImagePath(imageURL string) string {
+	rel := strings.TrimPrefix(imageURL, "/emotes/")
+	if rel == imageURL || rel == "" {
+		return ""
+	}
+	return filepath.Join(emoteDir, filepath.FromSlash(rel))
+}
+
+// RegisterEmoteFileServer serves uploaded emote images at /emotes/<studioId>/<file>.
+// File names are content hashes, so they can be cached indefinitely.
+func RegisterEmoteFileServer(app *vbeam.Application) {
+	fileServer := http.StripPrefix("/emotes/", http.FileServer(http.Dir(emoteDir)))
+	app.HandleFunc("/emotes/", func(w http.ResponseWriter, r *http.Request) {
+		path := emoteImagePath(r.URL.Path)
+		if path == "" || filepath.Ext(path) == "" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// RegisterStudioEmoteMethods registers emote set procedures
+func RegisterStudioEmoteMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, GetRoomEmotes)
+	vbeam.RegisterProc(app, GetStudioEmoteSet)
+	vbeam.RegisterProc(app, UpdateStudioEmoteSet)
+	vbeam.RegisterProc(app, UploadStudioEmote)
+	vbeam.RegisterProc(app, ListStreamApplause)
+}
+
+type GetRoomEmotesRequest struct {
+	RoomId int `json:"roomId"`
+}
+
+type GetRoomEmotesResponse struct {
+	Emotes []StudioEmote `json:"emotes"`
+}
+
+type GetStudioEmoteSetRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type GetStudioEmoteSetResponse struct {
+	Emotes    []StudioEmote `json:"emotes"`
+	IsDefault bool          `json:"isDefault"` // The studio hasn't customized its emotes
+}
+
+type UpdateStudioEmoteSetRequest struct {
+	StudioId int           `json:"studioId"`
+	Emotes   []StudioEmote `json:"emotes"` // Empty to go back to the default set
+}
+
+type UpdateStudioEmoteSetResponse struct {
+	Emotes []StudioEmote `json:"emotes"`
+}
+
+type UploadStudioEmoteRequest struct {
+	StudioId  int    `json:"studioId"`
+	Name      string `json:"name"`      // Lowercase letters, digits and _; sent as ":name:"
+	ImageData string `json:"imageData"` // Base64 PNG, GIF or JPEG (a data URL is fine)
+}
+
+type UploadStudioEmoteResponse struct {
+	Emote  StudioEmote   `json:"emote"`
+	Emotes []StudioEmote `json:"emotes"`
+}
+
+type ListStreamApplauseRequest struct {
+	StudioId int `json:"studioId"`
+	Limit    int `json:"limit"` // Default: 20
+}
+
+type StreamApplause struct {
+	Stream   Stream       `json:"stream"`
+	RoomName string       `json:"roomName"`
+	Total    int          `json:"total"`
+	Counts   []EmoteCount `json:"counts"` // Most sent first
+}
+
+type ListStreamApplauseResponse struct {
+	Streams []StreamApplause `json:"streams"`
+	Emotes  []StudioEmote    `json:"emotes"` // For showing image emotes
+}
+
+// emoteSetAdmin checks the caller may change a studio's emotes
+func emoteSetAdmin(ctx *vbeam.Context, studioId int) (caller User, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return caller, errors.New("Authentication required")
+	}
+	if GetStudioById(ctx.Tx, studioId).Id == 0 {
+		return caller, errors.New("Studio not found")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapModerateChat) {
+		return caller, errors.New("Only chat moderators can change emotes")
+	}
+	return caller, nil
+}
+
+// GetRoomEmotes returns the emotes viewers of a room can send
+// Both JWT users and code sessions can read them
+func GetRoomEmotes(ctx *vbeam.Context, req GetRoomEmotesRequest) (resp GetRoomEmotesResponse, err error) {
+	if _, err := GetAuthUser(ctx); err != nil {
+		return resp, ErrAuthFailure
+	}
+	room := GetRoom(ctx.Tx, req.RoomId)
+	if room.Id == 0 {
+		return resp, errors.New("Room not found")
+	}
+	resp.Emotes = studioEmotes(ctx.Tx, room.StudioId)
+	return
+}
+
+// GetStudioEmoteSet returns a studio's emotes for its settings page
+func GetStudioEmoteSet(ctx *vbeam.Context, req GetStudioEmoteSetRequest) (resp GetStudioEmoteSetResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, req.StudioId, CapViewStudio) {
+		return resp, errors.New("Access denied")
+	}
+
+	var set StudioEmoteSet
+	vbolt.Read(ctx.Tx, StudioEmoteSetsBkt, req.StudioId, &set)
+	resp.IsDefault = len(set.Emotes) == 0
+	resp.Emotes = studioEmotes(ctx.Tx, req.StudioId)
+	return
+}
+
+// UpdateStudioEmoteSet replaces a studio's emotes. Image emotes must already
+// be uploaded; images dropped from the set are deleted.
+func UpdateStudioEmoteSet(ctx *vbeam.Context, req UpdateStudioEmoteSetRequest) (resp UpdateStudioEmoteSetResponse, err error) {
+	caller, err := emoteSetAdmin(ctx, req.StudioId)
+	if err != nil {
+		return
+	}
+	if len(req.Emotes) > MAX_STUDIO_EMOTES {
+		return resp, fmt.Errorf("Studios can have at most %d emotes", MAX_STUDIO_EMOTES)
+	}
+
+	current := studioEmotes(ctx.Tx, req.StudioId)
+	images := make(map[string]StudioEmote)
+	for _, emote := range current {
+		if emote.ImageURL != "" {
+			images[emote.Code] = emote
+		}
+	}
+
+	emotes := make([]StudioEmote, 0, len(req.Emotes))
+	seen := make(map[string]bool)
+	for _, emote := range req.Emotes {
+		code := strings.TrimSpace(emote.Code)
+		if seen[code] {
+			return resp, fmt.Errorf("%s is in the list twice", code)
+		}
+		seen[code] = true
+		if image, ok := images[code]; ok {
+			emotes = append(emotes, image)
+			continue
+		}
+		if !isValidUnicodeEmote(code) {
+			return resp, fmt.Errorf("%q isn't an emoji or an uploaded emote", code)
+		}
+		emotes = append(emotes, StudioEmote{Code: code})
+	}
+
+	vbeam.UseWriteTx(ctx)
+	if len(emotes) == 0 {
+		vbolt.Delete(ctx.Tx, StudioEmoteSetsBkt, req.StudioId)
+	} else {
+		set := StudioEmoteSet{
+			StudioId:  req.StudioId,
+			Emotes:    emotes,
+			UpdatedBy: caller.Id,
+			UpdatedAt: time.Now(),
+		}
+		vbolt.Write(ctx.Tx, StudioEmoteSetsBkt, req.StudioId, &set)
+	}
+	vbolt.TxCommit(ctx.Tx)
+
+	// Drop images no emote uses any more (the same file can back two names)
+	kept := make(map[string]bool)
+	for _, emote := range emotes {
+		kept[emote.ImageURL] = true
+	}
+	for _, image := range images {
+		if !kept[image.ImageURL] {
+			os.Remove(emoteImagePath(image.ImageURL))
+		}
+	}
+
+	LogInfo(LogCategorySystem, "Studio emotes updated", map[string]interface{}{
+		"studioId":  req.StudioId,
+		"emotes":    len(emotes),
+		"updatedBy": caller.Id,
+	})
+
+	resp.Emotes = emotes
+	if len(emotes) == 0 {
+		resp.Emotes = defaultStudioEmotes()
+	}
+	return
+}
+
+// UploadStudioEmote stores a small image and adds it to the studio's emotes
+func UploadStudioEmote(ctx *vbeam.Context, req UploadStudioEmoteRequest) (resp UploadStudioEmoteResponse, err error) {
+	caller, err := emoteSetAdmin(ctx, req.StudioId)
+	if err != nil {
+		return
+	}
+
+	name := strings.ToLower(strings.Trim(strings.TrimSpace(req.Name), ":"))
+	if !emoteNamePattern.MatchString(name) {
+		return resp, errors.New("Emote names must be 2-20 lowercase letters, numbers or _")
+	}
+	code := ":" + name + ":"
+	emotes := studioEmotes(ctx.Tx, req.StudioId)
+	if _, exists := findStudioEmote(ctx.Tx, req.StudioId, code); exists {
+		return resp, fmt.Errorf("There is already an emote called %s", code)
+	}
+	if len(emotes) >= MAX_STUDIO_EMOTES {
+		return resp, fmt.Errorf("Studios can have at most %d emotes", MAX_STUDIO_EMOTES)
+	}
+	data, ext, err := decodeEmoteImage(req.ImageData)
+	if err != nil {
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	fileName := hex.EncodeToString(sum[:8]) + ext
+	studioDir := filepath.Join(emoteDir, strconv.Itoa(req.StudioId))
+	if err = os.MkdirAll(studioDir, 0o755); err == nil {
+		err = os.WriteFile(filepath.Join(studioDir, fileName), data, 0o644)
+	}
+	if err != nil {
+		LogErrorSimple(LogCategorySystem, "Failed to save emote image", map[string]interface{}{
+			"studioId": req.StudioId,
+			"error":    err.Error(),
+		})
+		return resp, errors.New("Failed to save the image")
+	}
+
+	emote := StudioEmote{
+		Code:     code,
+		ImageURL: fmt.Sprintf("/emotes/%d/%s", req.StudioId, fileName),
+	}
+	set := StudioEmoteSet{
+		StudioId:  req.StudioId,
+		Emotes:    append(emotes, emote),
+		UpdatedBy: caller.Id,
+		UpdatedAt: time.Now(),
+	}
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.Write(ctx.Tx, StudioEmoteSetsBkt, req.StudioId, &set)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio emote uploaded", map[string]interface{}{
+		"studioId":   req.StudioId,
+		"code":       code,
+		"bytes":      len(data),
+		"uploadedBy": caller.Id,
+	})
+
+	resp.Emote = emote
+	resp.Emotes = set.Emotes
+	return
+}
+
+// ListStreamApplause returns emote tallies for a studio's recent streams,
+// newest first, for a post-class applause meter
+func ListStreamApplause(ctx *vbeam.Context, req ListStreamApplauseRequest) (resp ListStreamApplauseResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+	if !HasStudioCapability(ctx.Tx, caller.Id, req.StudioId, CapViewStudio) {
+		return resp, errors.New("Access denied")
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var streamIds []int
+	vbolt.ReadTermTargets(ctx.Tx, StreamsByStudioIdx, req.StudioId, &streamIds, vbolt.Window{})
+	roomNames := make(map[int]string)
+	resp.Streams = []StreamApplause{}
+	for i := len(streamIds) - 1; i >= 0 && len(resp.Streams) < limit; i-- {
+		var tally StreamEmoteCounts
+		vbolt.Read(ctx.Tx, StreamEmoteCountsBkt, streamIds[i], &tally)
+		if len(tally.Counts) == 0 {
+			continue
+		}
+		stream := GetStream(ctx.Tx, streamIds[i])
+		if stream.Id == 0 {
+			continue
+		}
+		if _, ok := roomNames[stream.RoomId]; !ok {
+			roomNames[stream.RoomId] = GetRoom(ctx.Tx, stream.RoomId).Name
+		}
+		applause := StreamApplause{
+			Stream:   stream,
+			RoomName: roomNames[stream.RoomId],
+			Counts:   tally.Counts,
+		}
+		sort.SliceStable(applause.Counts, func(a, b int) bool {
+			return applause.Counts[a].Count > applause.Counts[b].Count
+		})
+		for _, count := range applause.Counts {
+			applause.Total += count.Count
+		}
+		resp.Streams = append(resp.Streams, applause)
+	}
+	resp.Emotes = studioEmotes(ctx.Tx, req.StudioId)
+	return
+}
@@ -0,0 +1,146 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func testEmoteImage(t *testing.T, size int) string {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestStudioEmotes(t *testing.T) {
+	db, _, studio, room := setupTestStudioRoom(t)
+	admin := addTestStudioMember(t, db, studio.Id, "admin@test.com", StudioRoleAdmin)
+	member := addTestStudioMember(t, db, studio.Id, "member@test.com", StudioRoleMember)
+
+	originalDir := emoteDir
+	emoteDir = t.TempDir()
+	defer func() { emoteDir = originalDir }()
+
+	adminToken, _ := createTestToken(admin.Id)
+	memberToken, _ := createTestToken(member.Id)
+	call := func(token string, fn func(ctx *vbeam.Context) error) (err error) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			err = fn(&vbeam.Context{Tx: tx, Token: token})
+		})
+		return
+	}
+	update := func(token string, codes ...string) (emotes []StudioEmote, err error) {
+		req := UpdateStudioEmoteSetRequest{StudioId: room.StudioId}
+		for _, code := range codes {
+			req.Emotes = append(req.Emotes, StudioEmote{Code: code})
+		}
+		err = call(token, func(ctx *vbeam.Context) error {
+			resp, err := UpdateStudioEmoteSet(ctx, req)
+			emotes = resp.Emotes
+			return err
+		})
+		return
+	}
+	upload := func(name string, imageData string) (resp UploadStudioEmoteResponse, err error) {
+		err = call(adminToken, func(ctx *vbeam.Context) (err error) {
+			resp, err = UploadStudioEmote(ctx, UploadStudioEmoteRequest{StudioId: room.StudioId, Name: name, ImageData: imageData})
+			return
+		})
+		return
+	}
+	sendEmote := func(emote string) error {
+		globalRateLimiter.Reset()
+		return call(memberToken, func(ctx *vbeam.Context) error {
+			_, err := SendEmote(ctx, SendEmoteRequest{RoomId: room.Id, Emote: emote})
+			return err
+		})
+	}
+
+	// Studios start with the default emotes
+	if err := sendEmote("👏"); err != nil {
+		t.Errorf("Expected a default emote to be accepted: %v", err)
+	}
+
+	if _, err := update(memberToken, "🩰"); err == nil {
+		t.Errorf("Expected members to be refused")
+	}
+	for _, bad := range []string{"abc", "", ":bow:"} {
+		if _, err := update(adminToken, "🩰", bad); err == nil {
+			t.Errorf("Expected %q to be refused", bad)
+		}
+	}
+	if emotes, err := update(adminToken, "🩰", "👏"); err != nil || len(emotes) != 2 {
+		t.Fatalf("UpdateStudioEmoteSet failed: %v (%+v)", err, emotes)
+	}
+	if err := sendEmote("🔥"); err == nil {
+		t.Errorf("Expected an emote outside the studio's set to be refused")
+	}
+
+	// Uploaded images become ":name:" emotes served from /emotes/
+	if _, err := upload("big", testEmoteImage(t, MAX_EMOTE_IMAGE_PIXELS+1)); err == nil {
+		t.Errorf("Expected an oversized image to be refused")
+	}
+	if _, err := upload("Not OK!", testEmoteImage(t, 32)); err == nil {
+		t.Errorf("Expected an invalid name to be refused")
+	}
+	uploaded, err := upload("bow", testEmoteImage(t, 32))
+	if err != nil {
+		t.Fatalf("UploadStudioEmote failed: %v", err)
+	}
+	if uploaded.Emote.Code != ":bow:" || len(uploaded.Emotes) != 3 {
+		t.Errorf("Unexpected upload: %+v", uploaded)
+	}
+	imagePath := emoteImagePath(uploaded.Emote.ImageURL)
+	if _, err := os.Stat(imagePath); err != nil {
+		t.Fatalf("Expected the image at %s: %v", imagePath, err)
+	}
+	if _, err := upload("bow", testEmoteImage(t, 16)); err == nil {
+		t.Errorf("Expected a duplicate name to be refused")
+	}
+
+	// Emotes sent during a stream count toward its applause
+	var stream Stream
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		stream = startStreamTx(tx, room)
+		vbolt.TxCommit(tx)
+	})
+	for _, emote := range []string{"👏", ":bow:", "👏"} {
+		if err := sendEmote(emote); err != nil {
+			t.Fatalf("SendEmote(%s) failed: %v", emote, err)
+		}
+	}
+	var applause ListStreamApplauseResponse
+	call(memberToken, func(ctx *vbeam.Context) (err error) {
+		applause, err = ListStreamApplause(ctx, ListStreamApplauseRequest{StudioId: room.StudioId})
+		return
+	})
+	if len(applause.Streams) != 1 || applause.Streams[0].Stream.Id != stream.Id || applause.Streams[0].Total != 3 {
+		t.Fatalf("Unexpected applause: %+v", applause.Streams)
+	}
+	if top := applause.Streams[0].Counts[0]; top.Code != "👏" || top.Count != 2 {
+		t.Errorf("Expected 👏 to lead with 2, got %+v", top)
+	}
+
+	// Dropping an image emote deletes its file; an empty set restores the defaults
+	if _, err := update(adminToken, "🩰", ":bow:"); err != nil {
+		t.Fatalf("Keeping the uploaded emote failed: %v", err)
+	}
+	if _, err := os.Stat(imagePath); err != nil {
+		t.Errorf("Expected the kept image to stay: %v", err)
+	}
+	emotes, err := update(adminToken)
+	if err != nil || len(emotes) != len(AllowedEmotes) {
+		t.Fatalf("Resetting emotes failed: %v (%+v)", err, emotes)
+	}
+	if _, err := os.Stat(imagePath); !os.IsNotExist(err) {
+		t.Errorf("Expected the dropped image to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,537 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"stream/cfg"
+	"strings"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const STUDIO_INVITATION_TTL = 7 * 24 * time.Hour
+
+// StudioInvitation is a pending studio membership for an email address. It becomes a
+// StudioMembership when the invitee accepts, or automatically when they sign up
+// with that address.
+type StudioInvitation struct {
+	Id        int        `json:"id"`
+	StudioId  int        `json:"studioId"`
+	Email     string     `json:"email"` // Normalized (trimmed, lower case)
+	Role      StudioRole `json:"role"`
+	TokenHash string     `json:"-"` // SHA-256 of the token in the emailed link
+	InvitedBy int        `json:"invitedBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+}
+
+func PackStudioInvitation(self *StudioInvitation, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.String(&self.Email, buf)
+	vpack.Int((*int)(&self.Role), buf)
+	vpack.String(&self.TokenHash, buf)
+	vpack.Int(&self.InvitedBy, buf)
+	vpack.Time(&self.CreatedAt, buf)
+	vpack.Time(&self.ExpiresAt, buf)
+}
+
+var StudioInvitationsBkt = vbolt.Bucket(&cfg.Info, "studio_invitations", vpack.FInt, PackStudioInvitation)
+
+// token hash => invitation id
+var StudioInvitationTokensBkt = vbolt.Bucket(&cfg.Info, "studio_invitation_tokens", vpack.StringZ, vpack.Int)
+
+// studioId (term) -> invitationId (target)
+var InvitationsByStudioIdx = vbolt.Index(&cfg.Info, "invitations_by_studio", vpack.FInt, vpack.FInt)
+
+// normalized email (term) -> invitationId (target)
+var InvitationsByEmailIdx = vbolt.Index(&cfg.Info, "invitations_by_email", vpack.StringZ, vpack.FInt)
+
+func normalizeInviteEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (inv *StudioInvitation) isExpired(now time.Time) bool {
+	return now.After(inv.ExpiresAt)
+}
+
+func deleteStudioInvitationTx(tx *vbolt.Tx, inv StudioInvitation) {
+	vbolt.Delete(tx, StudioInvitationsBkt, inv.Id)
+	vbolt.Delete(tx, StudioInvitationTokensBkt, inv.TokenHash)
+	vbolt.SetTargetSingleTerm(tx, InvitationsByStudioIdx, inv.Id, -1)
+	vbolt.SetTargetSingleTerm(tx, InvitationsByEmailIdx, inv.Id, "")
+}
+
+// invitationsForEmail returns all invitations (including expired) sent to an address
+func invitationsForEmail(tx *vbolt.Tx, email string) []StudioInvitation {
+	var ids []int
+	vbolt.ReadTermTargets(tx, InvitationsByEmailIdx, normalizeInviteEmail(email), &ids, vbolt.Window{})
+
+	invitations := make([]StudioInvitation, 0, len(ids))
+	for _, id := range ids {
+		var inv StudioInvitation
+		vbolt.Read(tx, StudioInvitationsBkt, id, &inv)
+		if inv.Id != 0 {
+			invitations = append(invitations, inv)
+		}
+	}
+	return invitations
+}
+
+func invitationByToken(tx *vbolt.Tx, token string) (inv StudioInvitation) {
+	var id int
+	vbolt.Read(tx, StudioInvitationTokensBkt, hashAccountToken(token), &id)
+	if id != 0 {
+		vbolt.Read(tx, StudioInvitationsBkt, id, &inv)
+	}
+	return
+}
+
+// acceptStudioInvitationTx turns an invitation into a membership and deletes it.
+// Returns false if the user was already a member (the invitation is still removed).
+func acceptStudioInvitationTx(tx *vbolt.Tx, inv StudioInvitation, userId int) (StudioMembership, bool) {
+	deleteStudioInvitationTx(tx, inv)
+	if GetUserStudioRole(tx, userId, inv.StudioId) != -1 {
+		return StudioMembership{}, false
+	}
+	return addStudioMembershipTx(tx, userId, inv.StudioId, inv.Role), true
+}
+
+// acceptPendingInvitationsTx converts every unexpired invitation for the user's email
+// into a membership. Only call this once the user has proven they own the address.
+func acceptPendingInvitationsTx(tx *vbolt.Tx, user User) int {
+	now := time.Now()
+	accepted := 0
+	for _, inv := range invitationsForEmail(tx, user.Email) {
+		if inv.isExpired(now) {
+			continue
+		}
+		if _, ok := acceptStudioInvitationTx(tx, inv, user.Id); ok {
+			accepted++
+			LogInfo(LogCategorySystem, "Studio invitation accepted", map[string]interface{}{
+				"invitationId": inv.Id,
+				"studioId":     inv.StudioId,
+				"userId":       user.Id,
+				"role":         GetStudioRoleName(inv.Role),
+				"automatic":    true,
+			})
+		}
+	}
+	return accepted
+}
+
+// verifyEmailByInviteTokenTx marks a new account as verified when it was created
+// from an invitation link sent to the same address
+func verifyEmailByInviteTokenTx(tx *vbolt.Tx, user *User, token string) bool {
+	if token == "" || user.EmailVerified {
+		return false
+	}
+	inv := invitationByToken(tx, token)
+	if inv.Id == 0 || inv.isExpired(time.Now()) || inv.Email != normalizeInviteEmail(user.Email) {
+		return false
+	}
+	user.EmailVerified = true
+	vbolt.Write(tx, UsersBkt, user.Id, user)
+	return true
+}
+
+// Request/Response types
+type InviteStudioMemberRequest struct {
+	StudioId int        `json:"studioId"`
+	Email    string     `json:"email"`
+	Role     StudioRole `json:"role"`
+}
+
+type InviteStudioMemberResponse struct {
+	Invitation StudioInvitation `json:"invitation"`
+}
+
+type ListStudioInvitationsRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type StudioInvitationItem struct {
+	StudioInvitation
+	RoleName      string `json:"roleName"`
+	InvitedByName string `json:"invitedByName"`
+	IsExpired     bool   `json:"isExpired"`
+}
+
+type ListStudioInvitationsResponse struct {
+	Invitations []StudioInvitationItem `json:"invitations"`
+}
+
+type RevokeStudioInvitationRequest struct {
+	InvitationId int `json:"invitationId"`
+}
+
+type RevokeStudioInvitationResponse struct {
+	Success bool `json:"success"`
+}
+
+type GetStudioInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+type GetStudioInvitationResponse struct {
+	InvitationId int        `json:"invitationId"`
+	StudioName   string     `json:"studioName"`
+	Email        string     `json:"email"`
+	Role         StudioRole `json:"role"`
+	RoleName     string     `json:"roleName"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	IsExpired    bool       `json:"isExpired"`
+}
+
+type MyStudioInvitation struct {
+	Id         int        `json:"id"`
+	StudioId   int        `json:"studioId"`
+	StudioName string     `json:"studioName"`
+	Role       StudioRole `json:"role"`
+	RoleName   string     `json:"roleName"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+}
+
+type ListMyStudioInvitationsResponse struct {
+	Invitations []MyStudioInvitation `json:"invitations"`
+}
+
+// Invitations are answered either from the emailed link (Token) or, for a verified
+// account with the invited address, by id
+type RespondStudioInvitationRequest struct {
+	InvitationId int    `json:"invitationId"`
+	Token        string `json:"token"`
+}
+
+type AcceptStudioInvitationResponse struct {
+	Membership StudioMembership `json:"membership"`
+}
+
+type DeclineStudioInvitationResponse struct {
+	Success bool `json:"success"`
+}
+
+// API Procedures
+
+func InviteStudioMember(ctx *vbeam.Context, req InviteStudioMemberRequest) (resp InviteStudioMemberResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	studio := GetStudioById(ctx.Tx, req.StudioId)
+	if studio.Id == 0 {
+		return resp, errors.New("Studio not found")
+	}
+
+	if !HasStudioPermission(ctx.Tx, caller.Id, studio.Id, StudioRoleAdmin) {
+		return resp, errors.New("Only studio admins can invite members")
+	}
+
+	email := normalizeInviteEmail(req.Email)
+	if email == "" || !strings.Contains(email, "@") {
+		return resp, errors.New("A valid email address is required")
+	}
+
+	if req.Role < StudioRoleViewer || req.Role > StudioRoleOwner {
+		return resp, errors.New("Invalid role")
+	}
+	if req.Role == StudioRoleOwner && !HasStudioPermission(ctx.Tx, caller.Id, studio.Id, StudioRoleOwner) {
+		return resp, errors.New("Only studio owners can invite other owners")
+	}
+
+	if userId := GetUserId(ctx.Tx, strings.TrimSpace(req.Email)); userId != 0 {
+		if GetUserStudioRole(ctx.Tx, userId, studio.Id) != -1 {
+			return resp, errors.New("User is already a member of this studio")
+		}
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckStudioInvitation(caller.Id); rateLimitErr != nil {
+		return resp, rateLimitErr
+	}
+
+	token, tokenErr := generateToken(32)
+	if tokenErr != nil {
+		return resp, errors.New("Failed to create invitation")
+	}
+
+	vbeam.UseWriteTx(ctx)
+
+	// Re-inviting an address replaces its earlier invitation to this studio
+	for _, existing := range invitationsForEmail(ctx.Tx, email) {
+		if existing.StudioId == studio.Id {
+			deleteStudioInvitationTx(ctx.Tx, existing)
+		}
+	}
+
+	now := time.Now()
+	inv := StudioInvitation{
+		Id:        vbolt.NextIntId(ctx.Tx, StudioInvitationsBkt),
+		StudioId:  studio.Id,
+		Email:     email,
+		Role:      req.Role,
+		TokenHash: hashAccountToken(token),
+		InvitedBy: caller.Id,
+		CreatedAt: now,
+		ExpiresAt: now.Add(STUDIO_INVITATION_TTL),
+	}
+	vbolt.Write(ctx.Tx, StudioInvitationsBkt, inv.Id, &inv)
+	vbolt.Write(ctx.Tx, StudioInvitationTokensBkt, inv.TokenHash, &inv.Id)
+	vbolt.SetTargetSingleTerm(ctx.Tx, InvitationsByStudioIdx, inv.Id, studio.Id)
+	vbolt.SetTargetSingleTerm(ctx.Tx, InvitationsByEmailIdx, inv.Id, inv.Email)
+	vbolt.TxCommit(ctx.Tx)
+
+	sendStudioInvitationEmail(inv, studio, caller, token)
+
+	LogInfo(LogCategorySystem, "Studio invitation sent", map[string]interface{}{
+		"invitationId": inv.Id,
+		"studioId":     studio.Id,
+		"studioName":   studio.Name,
+		"email":        inv.Email,
+		"role":         GetStudioRoleName(inv.Role),
+		"invitedBy":    caller.Id,
+	})
+
+	resp.Invitation = inv
+	return
+}
+
+func ListStudioInvitations(ctx *vbeam.Context, req ListStudioInvitationsRequest) (resp ListStudioInvitationsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	studio := GetStudioById(ctx.Tx, req.StudioId)
+	if studio.Id == 0 {
+		return resp, errors.New("Studio not found")
+	}
+
+	if !HasStudioPermission(ctx.Tx, caller.Id, studio.Id, StudioRoleAdmin) {
+		return resp, errors.New("Only studio admins can view invitations")
+	}
+
+	var ids []int
+	vbolt.ReadTermTargets(ctx.Tx, InvitationsByStudioIdx, studio.Id, &ids, vbolt.Window{})
+
+	now := time.Now()
+	resp.Invitations = make([]StudioInvitationItem, 0, len(ids))
+	for _, id := range ids {
+		var inv StudioInvitation
+		vbolt.Read(ctx.Tx, StudioInvitationsBkt, id, &inv)
+		if inv.Id == 0 {
+			continue
+		}
+		resp.Invitations = append(resp.Invitations, StudioInvitationItem{
+			StudioInvitation: inv,
+			RoleName:         GetStudioRoleName(inv.Role),
+			InvitedByName:    GetUser(ctx.Tx, inv.InvitedBy).Name,
+			IsExpired:        inv.isExpired(now),
+		})
+	}
+
+	// Newest first
+	sort.Slice(resp.Invitations, func(i, j int) bool {
+		return resp.Invitations[i].CreatedAt.After(resp.Invitations[j].CreatedAt)
+	})
+	return
+}
+
+func RevokeStudioInvitation(ctx *vbeam.Context, req RevokeStudioInvitationRequest) (resp RevokeStudioInvitationResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	var inv StudioInvitation
+	vbolt.Read(ctx.Tx, StudioInvitationsBkt, req.InvitationId, &inv)
+	if inv.Id == 0 {
+		return resp, errors.New("Invitation not found")
+	}
+
+	if !HasStudioPermission(ctx.Tx, caller.Id, inv.StudioId, StudioRoleAdmin) {
+		return resp, errors.New("Only studio admins can revoke invitations")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	deleteStudioInvitationTx(ctx.Tx, inv)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio invitation revoked", map[string]interface{}{
+		"invitationId": inv.Id,
+		"studioId":     inv.StudioId,
+		"email":        inv.Email,
+		"revokedBy":    caller.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+// GetStudioInvitation previews an invitation from its emailed link (no login required)
+func GetStudioInvitation(ctx *vbeam.Context, req GetStudioInvitationRequest) (resp GetStudioInvitationResponse, err error) {
+	inv := invitationByToken(ctx.Tx, req.Token)
+	if inv.Id == 0 {
+		return resp, errors.New("This invitation is no longer valid")
+	}
+
+	studio := GetStudioById(ctx.Tx, inv.StudioId)
+	resp.InvitationId = inv.Id
+	resp.StudioName = studio.Name
+	resp.Email = inv.Email
+	resp.Role = inv.Role
+	resp.RoleName = GetStudioRoleName(inv.Role)
+	resp.ExpiresAt = inv.ExpiresAt
+	resp.IsExpired = inv.isExpired(time.Now())
+	return
+}
+
+// ListMyStudioInvitations returns pending invitations for the caller's verified email
+func ListMyStudioInvitations(ctx *vbeam.Context, req Empty) (resp ListMyStudioInvitationsResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	resp.Invitations = []MyStudioInvitation{}
+	if !caller.EmailVerified {
+		return
+	}
+
+	now := time.Now()
+	for _, inv := range invitationsForEmail(ctx.Tx, caller.Email) {
+		if inv.isExpired(now) {
+			continue
+		}
+		resp.Invitations = append(resp.Invitations, MyStudioInvitation{
+			Id:         inv.Id,
+			StudioId:   inv.StudioId,
+			StudioName: GetStudioById(ctx.Tx, inv.StudioId).Name,
+			Role:       inv.Role,
+			RoleName:   GetStudioRoleName(inv.Role),
+			ExpiresAt:  inv.ExpiresAt,
+		})
+	}
+	return
+}
+
+// callerStudioInvitation loads the invitation the caller is answering and checks it
+// was sent to them. Answering from the emailed link also proves the address.
+func callerStudioInvitation(tx *vbolt.Tx, caller User, req RespondStudioInvitationRequest) (inv StudioInvitation, viaLink bool, err error) {
+	if req.Token != "" {
+		inv = invitationByToken(tx, req.Token)
+		viaLink = true
+	} else {
+		vbolt.Read(tx, StudioInvitationsBkt, req.InvitationId, &inv)
+	}
+
+	if inv.Id == 0 {
+		return inv, viaLink, errors.New("Invitation not found")
+	}
+	if inv.Email != normalizeInviteEmail(caller.Email) {
+		return inv, viaLink, errors.New("This invitation was sent to a different email address")
+	}
+	if !viaLink && !caller.EmailVerified {
+		return inv, viaLink, errors.New("Verify your email address to answer this invitation")
+	}
+	if inv.isExpired(time.Now()) {
+		return inv, viaLink, errors.New("This invitation has expired")
+	}
+	return inv, viaLink, nil
+}
+
+func AcceptStudioInvitation(ctx *vbeam.Context, req RespondStudioInvitationRequest) (resp AcceptStudioInvitationResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	inv, viaLink, err := callerStudioInvitation(ctx.Tx, caller, req)
+	if err != nil {
+		return
+	}
+
+	vbeam.UseWriteTx(ctx)
+	if viaLink && !caller.EmailVerified {
+		caller.EmailVerified = true
+		vbolt.Write(ctx.Tx, UsersBkt, caller.Id, &caller)
+	}
+
+	membership, ok := acceptStudioInvitationTx(ctx.Tx, inv, caller.Id)
+	vbolt.TxCommit(ctx.Tx)
+
+	if !ok {
+		return resp, errors.New("You are already a member of this studio")
+	}
+
+	LogInfo(LogCategorySystem, "Studio invitation accepted", map[string]interface{}{
+		"invitationId": inv.Id,
+		"studioId":     inv.StudioId,
+		"userId":       caller.Id,
+		"role":         GetStudioRoleName(inv.Role),
+	})
+
+	resp.Membership = membership
+	return
+}
+
+func DeclineStudioInvitation(ctx *vbeam.Context, req RespondStudioInvitationRequest) (resp DeclineStudioInvitationResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	inv, _, err := callerStudioInvitation(ctx.Tx, caller, req)
+	if err != nil {
+		return
+	}
+
+	vbeam.UseWriteTx(ctx)
+	deleteStudioInvitationTx(ctx.Tx, inv)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio invitation declined", map[string]interface{}{
+		"invitationId": inv.Id,
+		"studioId":     inv.StudioId,
+		"userId":       caller.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+func sendStudioInvitationEmail(inv StudioInvitation, studio Studio, inviter User, token string) error {
+	body := fmt.Sprintf(`Hi,
+
+%s invited you to join %s as a %s.
+
+Accept the invitation here:
+
+    %s/studio-invite/%s
+
+If you don't have an account yet, sign up with this email address (%s)
+and you'll be added automatically. The invitation expires on %s.
+`, inviter.Name, studio.Name, GetStudioRoleName(inv.Role), cfg.SiteURL, token, inv.Email, inv.ExpiresAt.UTC().Format("Jan 2, 2006"))
+
+	return sendMail(MailMessage{
+		To:      inv.Email,
+		Subject: fmt.Sprintf("You're invited to join %s", studio.Name),
+		Body:    body,
+	})
+}
+
+// RegisterStudioInvitationMethods registers studio invitation procedures
+func RegisterStudioInvitationMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, InviteStudioMember)
+	vbeam.RegisterProc(app, ListStudioInvitations)
+	vbeam.RegisterProc(app, RevokeStudioInvitation)
+	vbeam.RegisterProc(app, GetStudioInvitation)
+	vbeam.RegisterProc(app, ListMyStudioInvitations)
+	vbeam.RegisterProc(app, AcceptStudioInvitation)
+	vbeam.RegisterProc(app, DeclineStudioInvitation)
+}
@@ -0,0 +1,237 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func inviteTestMember(t *testing.T, db *vbolt.DB, adminId int, studioId int, email string, role StudioRole) InviteStudioMemberResponse {
+	token, _ := createTestToken(adminId)
+	var resp InviteStudioMemberResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		resp, err = InviteStudioMember(&vbeam.Context{Tx: tx, Token: token}, InviteStudioMemberRequest{
+			StudioId: studioId,
+			Email:    email,
+			Role:     role,
+		})
+	})
+	if err != nil {
+		t.Fatalf("InviteStudioMember failed: %v", err)
+	}
+	return resp
+}
+
+func createTestInvitee(t *testing.T, db *vbolt.DB, email string, verified bool) User {
+	var user User
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		user = AddUserTx(tx, CreateAccountRequest{Name: "Invitee", Email: email}, []byte{})
+		user.EmailVerified = verified
+		vbolt.Write(tx, UsersBkt, user.Id, &user)
+		vbolt.TxCommit(tx)
+	})
+	return user
+}
+
+func TestStudioInvitationSignupWithLink(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	_, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, studio, _ := createTestTicketAdmin(t, db)
+	inviteTestMember(t, db, admin.Id, studio.Id, " New.Member@Test.com ", StudioRoleMember)
+
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != "new.member@test.com" {
+		t.Fatalf("Expected invitation email, got %+v", sent)
+	}
+	token := tokenFromMail(t, sent[0], "/studio-invite/")
+
+	var err error
+	var preview GetStudioInvitationResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		preview, err = GetStudioInvitation(&vbeam.Context{Tx: tx}, GetStudioInvitationRequest{Token: token})
+	})
+	if err != nil || preview.StudioName != studio.Name || preview.Role != StudioRoleMember {
+		t.Fatalf("Unexpected invitation preview: %v %+v", err, preview)
+	}
+
+	var createResp CreateAccountResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		createResp, err = CreateAccount(&vbeam.Context{Tx: tx}, CreateAccountRequest{
+			Name:            "New Member",
+			Email:           "new.member@test.com",
+			Password:        "password123",
+			ConfirmPassword: "password123",
+			InviteToken:     token,
+		})
+	})
+	if err != nil {
+		t.Fatalf("CreateAccount failed: %v", err)
+	}
+	if !createResp.Auth.EmailVerified || !createResp.Auth.CanManageStudios {
+		t.Errorf("Expected verified account with studio access, got %+v", createResp.Auth)
+	}
+	if len(mailer.Sent()) != 1 {
+		t.Errorf("Expected no verification email for an invited signup, got %d emails", len(mailer.Sent()))
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if role := GetUserStudioRole(tx, createResp.Auth.Id, studio.Id); role != StudioRoleMember {
+			t.Errorf("Expected Member role, got %d", role)
+		}
+		if len(invitationsForEmail(tx, "new.member@test.com")) != 0 {
+			t.Errorf("Expected invitation to be consumed")
+		}
+	})
+}
+
+func TestStudioInvitationConvertedOnVerification(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	_, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, studio, _ := createTestTicketAdmin(t, db)
+	inviteTestMember(t, db, admin.Id, studio.Id, "later@test.com", StudioRoleViewer)
+
+	// Signing up without the link leaves the invitation pending until the address is verified
+	var err error
+	var createResp CreateAccountResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		createResp, err = CreateAccount(&vbeam.Context{Tx: tx}, CreateAccountRequest{
+			Name:            "Later",
+			Email:           "later@test.com",
+			Password:        "password123",
+			ConfirmPassword: "password123",
+		})
+	})
+	if err != nil {
+		t.Fatalf("CreateAccount failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if GetUserStudioRole(tx, createResp.Auth.Id, studio.Id) != -1 {
+			t.Errorf("Unverified signup should not join the studio yet")
+		}
+	})
+
+	sent := mailer.Sent()
+	verifyToken := tokenFromMail(t, sent[len(sent)-1], "/verify-email/")
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = VerifyEmail(&vbeam.Context{Tx: tx}, VerifyEmailRequest{Token: verifyToken})
+	})
+	if err != nil {
+		t.Fatalf("VerifyEmail failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if role := GetUserStudioRole(tx, createResp.Auth.Id, studio.Id); role != StudioRoleViewer {
+			t.Errorf("Expected Viewer role after verification, got %d", role)
+		}
+	})
+}
+
+func TestStudioInvitationAcceptDeclineRevoke(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	_, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, studio, _ := createTestTicketAdmin(t, db)
+	adminToken, _ := createTestToken(admin.Id)
+
+	unverified := createTestInvitee(t, db, "unverified@test.com", false)
+	verified := createTestInvitee(t, db, "verified@test.com", true)
+	other := createTestInvitee(t, db, "other@test.com", true)
+
+	first := inviteTestMember(t, db, admin.Id, studio.Id, unverified.Email, StudioRoleMember)
+	linkToken := tokenFromMail(t, mailer.Sent()[0], "/studio-invite/")
+	second := inviteTestMember(t, db, admin.Id, studio.Id, verified.Email, StudioRoleViewer)
+
+	respond := func(user User, req RespondStudioInvitationRequest, accept bool) error {
+		token, _ := createTestToken(user.Id)
+		var err error
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			ctx := &vbeam.Context{Tx: tx, Token: token}
+			if accept {
+				_, err = AcceptStudioInvitation(ctx, req)
+			} else {
+				_, err = DeclineStudioInvitation(ctx, req)
+			}
+		})
+		return err
+	}
+
+	// Someone else can't answer the invitation
+	if err := respond(other, RespondStudioInvitationRequest{InvitationId: second.Invitation.Id}, true); err == nil || !strings.Contains(err.Error(), "different email") {
+		t.Errorf("Expected different email error, got %v", err)
+	}
+
+	// Unverified accounts need the emailed link
+	if err := respond(unverified, RespondStudioInvitationRequest{InvitationId: first.Invitation.Id}, true); err == nil || !strings.Contains(err.Error(), "Verify") {
+		t.Errorf("Expected verification required error, got %v", err)
+	}
+	if err := respond(unverified, RespondStudioInvitationRequest{Token: linkToken}, true); err != nil {
+		t.Fatalf("Accept via link failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if GetUserStudioRole(tx, unverified.Id, studio.Id) != StudioRoleMember {
+			t.Errorf("Expected invitee to join as Member")
+		}
+		if !GetUser(tx, unverified.Id).EmailVerified {
+			t.Errorf("Expected accepting via link to verify the email")
+		}
+	})
+
+	// Verified account sees and declines its invitation
+	verifiedToken, _ := createTestToken(verified.Id)
+	var mine ListMyStudioInvitationsResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		mine, _ = ListMyStudioInvitations(&vbeam.Context{Tx: tx, Token: verifiedToken}, Empty{})
+	})
+	if len(mine.Invitations) != 1 || mine.Invitations[0].StudioName != studio.Name {
+		t.Fatalf("Expected 1 pending invitation, got %+v", mine.Invitations)
+	}
+	if err := respond(verified, RespondStudioInvitationRequest{InvitationId: second.Invitation.Id}, false); err != nil {
+		t.Fatalf("Decline failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if GetUserStudioRole(tx, verified.Id, studio.Id) != -1 {
+			t.Errorf("Declined invitation should not add membership")
+		}
+	})
+
+	// Admin revokes an invitation; expired ones can't be accepted
+	third := inviteTestMember(t, db, admin.Id, studio.Id, other.Email, StudioRoleViewer)
+	var listResp ListStudioInvitationsResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		listResp, err = ListStudioInvitations(&vbeam.Context{Tx: tx, Token: adminToken}, ListStudioInvitationsRequest{StudioId: studio.Id})
+	})
+	if err != nil || len(listResp.Invitations) != 1 || listResp.Invitations[0].Email != other.Email {
+		t.Fatalf("Expected 1 pending invitation for studio, got %v %+v", err, listResp.Invitations)
+	}
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		inv := third.Invitation
+		inv.ExpiresAt = time.Now().Add(-time.Minute)
+		vbolt.Write(tx, StudioInvitationsBkt, inv.Id, &inv)
+		vbolt.TxCommit(tx)
+	})
+	if err := respond(other, RespondStudioInvitationRequest{InvitationId: third.Invitation.Id}, true); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected expired error, got %v", err)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = RevokeStudioInvitation(&vbeam.Context{Tx: tx, Token: adminToken}, RevokeStudioInvitationRequest{InvitationId: third.Invitation.Id})
+	})
+	if err != nil {
+		t.Fatalf("RevokeStudioInvitation failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if len(invitationsForEmail(tx, other.Email)) != 0 {
+			t.Errorf("Expected revoked invitation to be removed")
+		}
+	})
+}
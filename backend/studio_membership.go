@@ -42,16 +42,21 @@ type StudioMembership struct {
 	StudioId int        `json:"studioId"`
 	Role     StudioRole `json:"role"`
 	JoinedAt time.Time  `json:"joinedAt"`
+
+	CustomRoleId int `json:"customRoleId"` // replaces the role's default capabilities when set
 }
 
 // Packing function for vbolt serialization
 
 func PackStudioMembership(self *StudioMembership, buf *vpack.Buffer) {
-	vpack.Version(1, buf)
+	version := vpack.Version(2, buf)
 	vpack.Int(&self.UserId, buf)
 	vpack.Int(&self.StudioId, buf)
 	vpack.Int((*int)(&self.Role), buf)
 	vpack.Time(&self.JoinedAt, buf)
+	if version >= 2 {
+		vpack.Int(&self.CustomRoleId, buf)
+	}
 }
 
 // Buckets and indexes for membership storage
@@ -106,6 +111,22 @@ func HasStudioPermission(tx *vbolt.Tx, userId int, studioId int, minRole StudioR
 	return userRole >= minRole
 }
 
+// addStudioMembershipTx creates and indexes a membership. Callers check that the
+// user isn't already a member.
+func addStudioMembershipTx(tx *vbolt.Tx, userId int, studioId int, role StudioRole) StudioMembership {
+	membership := StudioMembership{
+		UserId:   userId,
+		StudioId: studioId,
+		Role:     role,
+		JoinedAt: time.Now(),
+	}
+	membershipId := vbolt.NextIntId(tx, MembershipBkt)
+	vbolt.Write(tx, MembershipBkt, membershipId, &membership)
+	vbolt.SetTargetSingleTerm(tx, MembershipByUserIdx, membershipId, userId)
+	vbolt.SetTargetSingleTerm(tx, MembershipByStudioIdx, membershipId, studioId)
+	return membership
+}
+
 // ListUserStudios returns all studios the user is a member of
 func ListUserStudios(tx *vbolt.Tx, userId int) []Studio {
 	var studios []Studio
@@ -267,16 +288,7 @@ func AddStudioMember(ctx *vbeam.Context, req AddStudioMemberRequest) (resp AddSt
 	vbeam.UseWriteTx(ctx)
 
 	// Create membership
-	membership := StudioMembership{
-		UserId:   targetUserId,
-		StudioId: studio.Id,
-		Role:     req.Role,
-		JoinedAt: time.Now(),
-	}
-	membershipId := vbolt.NextIntId(ctx.Tx, MembershipBkt)
-	vbolt.Write(ctx.Tx, MembershipBkt, membershipId, &membership)
-	vbolt.SetTargetSingleTerm(ctx.Tx, MembershipByUserIdx, membershipId, targetUserId)
-	vbolt.SetTargetSingleTerm(ctx.Tx, MembershipByStudioIdx, membershipId, studio.Id)
+	membership := addStudioMembershipTx(ctx.Tx, targetUserId, studio.Id, req.Role)
 
 	vbolt.TxCommit(ctx.Tx)
 
@@ -420,7 +432,7 @@ func UpdateStudioMemberRole(ctx *vbeam.Context, req UpdateStudioMemberRoleReques
 		membership := GetMembership(ctx.Tx, membershipId)
 		if membership.StudioId == studio.Id {
 			// Update role
-			membership.Role = req.NewRole
+			setMembershipRole(&membership, req.NewRole)
 			vbolt.Write(ctx.Tx, MembershipBkt, membershipId, &membership)
 			updatedMembership = membership
 			break
@@ -478,7 +490,7 @@ func ListStudioMembersAPI(ctx *vbeam.Context, req ListStudioMembersRequest) (res
 				StudioMembership: membership,
 				UserName:         user.Name,
 				UserEmail:        user.Email,
-				RoleName:         GetStudioRoleName(membership.Role),
+				RoleName:         memberRoleName(ctx.Tx, membership),
 			})
 		}
 	}
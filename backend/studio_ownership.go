@@ -0,0 +1,385 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"stream/cfg"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+const OWNERSHIP_TRANSFER_TTL = 7 * 24 * time.Hour
+
+// OwnershipTransferStatus tracks an ownership handoff from offer to answer
+type OwnershipTransferStatus int
+
+const (
+	OwnershipTransferPending   OwnershipTransferStatus = 0 // Waiting for the new owner
+	OwnershipTransferAccepted  OwnershipTransferStatus = 1 // New owner confirmed, ownership moved
+	OwnershipTransferDeclined  OwnershipTransferStatus = 2 // New owner said no
+	OwnershipTransferCancelled OwnershipTransferStatus = 3 // Owner withdrew or replaced the offer
+	OwnershipTransferAssigned  OwnershipTransferStatus = 4 // Owner picked a successor when deleting their account
+)
+
+// OwnershipTransfer is an offer to hand a studio to another member. Records are
+// kept after they're answered as the studio's ownership history.
+type OwnershipTransfer struct {
+	Id          int                     `json:"id"`
+	StudioId    int                     `json:"studioId"`
+	FromUserId  int                     `json:"fromUserId"`
+	ToUserId    int                     `json:"toUserId"`
+	Status      OwnershipTransferStatus `json:"status"`
+	CreatedAt   time.Time               `json:"createdAt"`
+	ExpiresAt   time.Time               `json:"expiresAt"`
+	RespondedAt time.Time               `json:"respondedAt"`
+}
+
+func PackOwnershipTransfer(self *OwnershipTransfer, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.Int(&self.FromUserId, buf)
+	vpack.Int(&self.ToUserId, buf)
+	vpack.Int((*int)(&self.Status), buf)
+	vpack.Time(&self.CreatedAt, buf)
+	vpack.Time(&self.ExpiresAt, buf)
+	vpack.Time(&self.RespondedAt, buf)
+}
+
+var OwnershipTransfersBkt = vbolt.Bucket(&cfg.Info, "ownership_transfers", vpack.FInt, PackOwnershipTransfer)
+
+// studioId (term) -> transferId (target)
+var OwnershipTransfersByStudioIdx = vbolt.Index(&cfg.Info, "ownership_transfers_by_studio", vpack.FInt, vpack.FInt)
+
+func (t *OwnershipTransfer) isOpen(now time.Time) bool {
+	return t.Status == OwnershipTransferPending && !now.After(t.ExpiresAt)
+}
+
+// recordOwnershipTransferTx stores a new transfer record
+func recordOwnershipTransferTx(tx *vbolt.Tx, transfer OwnershipTransfer) OwnershipTransfer {
+	transfer.Id = vbolt.NextIntId(tx, OwnershipTransfersBkt)
+	transfer.CreatedAt = time.Now()
+	if transfer.Status != OwnershipTransferPending {
+		transfer.RespondedAt = transfer.CreatedAt
+	}
+	vbolt.Write(tx, OwnershipTransfersBkt, transfer.Id, &transfer)
+	vbolt.SetTargetSingleTerm(tx, OwnershipTransfersByStudioIdx, transfer.Id, transfer.StudioId)
+	return transfer
+}
+
+func studioOwnershipTransfersTx(tx *vbolt.Tx, studioId int) (transfers []OwnershipTransfer) {
+	var transferIds []int
+	vbolt.ReadTermTargets(tx, OwnershipTransfersByStudioIdx, studioId, &transferIds, vbolt.Window{})
+	for _, transferId := range transferIds {
+		var transfer OwnershipTransfer
+		if vbolt.Read(tx, OwnershipTransfersBkt, transferId, &transfer) {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return
+}
+
+// transferStudioOwnershipTx makes newOwnerId, who must already be a member, the
+// studio's owner. The previous owner stays on as an admin.
+func transferStudioOwnershipTx(tx *vbolt.Tx, studio Studio, newOwnerId int) {
+	var membershipIds []int
+	vbolt.ReadTermTargets(tx, MembershipByStudioIdx, studio.Id, &membershipIds, vbolt.Window{})
+	for _, membershipId := range membershipIds {
+		membership := GetMembership(tx, membershipId)
+		switch membership.UserId {
+		case newOwnerId:
+			setMembershipRole(&membership, StudioRoleOwner)
+		case studio.OwnerId:
+			setMembershipRole(&membership, StudioRoleAdmin)
+		default:
+			continue
+		}
+		vbolt.Write(tx, MembershipBkt, membershipId, &membership)
+	}
+
+	studio.OwnerId = newOwnerId
+	vbolt.Write(tx, StudiosBkt, studio.Id, &studio)
+}
+
+func sendOwnershipTransferEmail(studio Studio, from User, to User, transfer OwnershipTransfer) error {
+	body := fmt.Sprintf(`Hi %s,
+
+%s would like to make you the owner of %s. As owner you'll have full
+control of the studio, including deleting it, and %s will stay on as an
+admin.
+
+Review the request here:
+
+    %s/studio/%d
+
+The request expires on %s.
+`, to.Name, from.Name, studio.Name, from.Name, cfg.SiteURL, studio.Id, transfer.ExpiresAt.UTC().Format("Jan 2, 2006"))
+
+	return sendMail(MailMessage{
+		To:      to.Email,
+		Subject: fmt.Sprintf("Take over ownership of %s?", studio.Name),
+		Body:    body,
+	})
+}
+
+// RegisterStudioOwnershipMethods registers studio ownership transfer procedures
+func RegisterStudioOwnershipMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, OfferStudioOwnership)
+	vbeam.RegisterProc(app, CancelOwnershipTransfer)
+	vbeam.RegisterProc(app, AcceptOwnershipTransfer)
+	vbeam.RegisterProc(app, DeclineOwnershipTransfer)
+	vbeam.RegisterProc(app, ListOwnershipTransfers)
+}
+
+// Request/Response types
+type OfferStudioOwnershipRequest struct {
+	StudioId   int `json:"studioId"`
+	NewOwnerId int `json:"newOwnerId"`
+}
+
+type OfferStudioOwnershipResponse struct {
+	Transfer OwnershipTransfer `json:"transfer"`
+}
+
+type OwnershipTransferRequest struct {
+	TransferId int `json:"transferId"`
+}
+
+type OwnershipTransferResponse struct {
+	Success bool `json:"success"`
+}
+
+type ListOwnershipTransfersRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type OwnershipTransferItem struct {
+	OwnershipTransfer
+	FromName   string `json:"fromName"`
+	ToName     string `json:"toName"`
+	IsExpired  bool   `json:"isExpired"`
+	CanRespond bool   `json:"canRespond"` // open and offered to the caller
+	CanCancel  bool   `json:"canCancel"`  // open and offered by the caller
+}
+
+type ListOwnershipTransfersResponse struct {
+	Transfers []OwnershipTransferItem `json:"transfers"` // newest first
+}
+
+// API Procedures
+
+// OfferStudioOwnership asks another member to take over the studio. Any earlier
+// open offer is cancelled.
+func OfferStudioOwnership(ctx *vbeam.Context, req OfferStudioOwnershipRequest) (resp OfferStudioOwnershipResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	studio := GetStudioById(ctx.Tx, req.StudioId)
+	if studio.Id == 0 {
+		return resp, errors.New("Studio not found")
+	}
+	if studio.OwnerId != caller.Id {
+		return resp, errors.New("Only the studio owner can transfer ownership")
+	}
+	if req.NewOwnerId == caller.Id {
+		return resp, errors.New("You already own this studio")
+	}
+	newOwner := GetUser(ctx.Tx, req.NewOwnerId)
+	if newOwner.Id == 0 || GetUserStudioRole(ctx.Tx, newOwner.Id, studio.Id) == -1 {
+		return resp, errors.New("The new owner must be a member of the studio")
+	}
+
+	now := time.Now()
+	var open []OwnershipTransfer
+	for _, transfer := range studioOwnershipTransfersTx(ctx.Tx, studio.Id) {
+		if transfer.isOpen(now) {
+			open = append(open, transfer)
+		}
+	}
+
+	vbeam.UseWriteTx(ctx)
+	for _, transfer := range open {
+		transfer.Status = OwnershipTransferCancelled
+		transfer.RespondedAt = now
+		vbolt.Write(ctx.Tx, OwnershipTransfersBkt, transfer.Id, &transfer)
+	}
+	resp.Transfer = recordOwnershipTransferTx(ctx.Tx, OwnershipTransfer{
+		StudioId:   studio.Id,
+		FromUserId: caller.Id,
+		ToUserId:   newOwner.Id,
+		Status:     OwnershipTransferPending,
+		ExpiresAt:  now.Add(OWNERSHIP_TRANSFER_TTL),
+	})
+	vbolt.TxCommit(ctx.Tx)
+
+	sendOwnershipTransferEmail(studio, caller, newOwner, resp.Transfer)
+
+	LogInfo(LogCategorySystem, "Studio ownership transfer offered", map[string]interface{}{
+		"transferId": resp.Transfer.Id,
+		"studioId":   studio.Id,
+		"fromUserId": caller.Id,
+		"toUserId":   newOwner.Id,
+	})
+	return
+}
+
+// openOwnershipTransfer loads a transfer that's still waiting for an answer
+func openOwnershipTransfer(tx *vbolt.Tx, transferId int) (transfer OwnershipTransfer, err error) {
+	vbolt.Read(tx, OwnershipTransfersBkt, transferId, &transfer)
+	if transfer.Id == 0 {
+		return transfer, errors.New("Transfer not found")
+	}
+	if transfer.Status != OwnershipTransferPending {
+		return transfer, errors.New("This transfer has already been answered")
+	}
+	if !transfer.isOpen(time.Now()) {
+		return transfer, errors.New("This transfer has expired")
+	}
+	return transfer, nil
+}
+
+func CancelOwnershipTransfer(ctx *vbeam.Context, req OwnershipTransferRequest) (resp OwnershipTransferResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	transfer, err := openOwnershipTransfer(ctx.Tx, req.TransferId)
+	if err != nil {
+		return
+	}
+	if transfer.FromUserId != caller.Id {
+		return resp, errors.New("Only the owner who offered this transfer can cancel it")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	transfer.Status = OwnershipTransferCancelled
+	transfer.RespondedAt = time.Now()
+	vbolt.Write(ctx.Tx, OwnershipTransfersBkt, transfer.Id, &transfer)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio ownership transfer cancelled", map[string]interface{}{
+		"transferId": transfer.Id,
+		"studioId":   transfer.StudioId,
+	})
+
+	resp.Success = true
+	return
+}
+
+// AcceptOwnershipTransfer makes the caller the studio's owner. The studio and
+// both memberships change in one transaction.
+func AcceptOwnershipTransfer(ctx *vbeam.Context, req OwnershipTransferRequest) (resp OwnershipTransferResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	transfer, err := openOwnershipTransfer(ctx.Tx, req.TransferId)
+	if err != nil {
+		return
+	}
+	if transfer.ToUserId != caller.Id {
+		return resp, errors.New("This transfer was offered to someone else")
+	}
+
+	studio := GetStudioById(ctx.Tx, transfer.StudioId)
+	if studio.Id == 0 {
+		return resp, errors.New("Studio not found")
+	}
+	if studio.OwnerId != transfer.FromUserId {
+		return resp, errors.New("The studio's owner has changed since this transfer was offered")
+	}
+	if GetUserStudioRole(ctx.Tx, caller.Id, studio.Id) == -1 {
+		return resp, errors.New("You are no longer a member of this studio")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	transferStudioOwnershipTx(ctx.Tx, studio, caller.Id)
+	transfer.Status = OwnershipTransferAccepted
+	transfer.RespondedAt = time.Now()
+	vbolt.Write(ctx.Tx, OwnershipTransfersBkt, transfer.Id, &transfer)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio ownership transferred", map[string]interface{}{
+		"transferId": transfer.Id,
+		"studioId":   studio.Id,
+		"studioName": studio.Name,
+		"fromUserId": transfer.FromUserId,
+		"toUserId":   caller.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+func DeclineOwnershipTransfer(ctx *vbeam.Context, req OwnershipTransferRequest) (resp OwnershipTransferResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	transfer, err := openOwnershipTransfer(ctx.Tx, req.TransferId)
+	if err != nil {
+		return
+	}
+	if transfer.ToUserId != caller.Id {
+		return resp, errors.New("This transfer was offered to someone else")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	transfer.Status = OwnershipTransferDeclined
+	transfer.RespondedAt = time.Now()
+	vbolt.Write(ctx.Tx, OwnershipTransfersBkt, transfer.Id, &transfer)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Studio ownership transfer declined", map[string]interface{}{
+		"transferId": transfer.Id,
+		"studioId":   transfer.StudioId,
+		"userId":     caller.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+// ListOwnershipTransfers returns the studio's ownership history to admins. Other
+// members only see an open offer made to them.
+func ListOwnershipTransfers(ctx *vbeam.Context, req ListOwnershipTransfersRequest) (resp ListOwnershipTransfersResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil || caller.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	role := GetUserStudioRole(ctx.Tx, caller.Id, req.StudioId)
+	if role == -1 && caller.Role != RoleSiteAdmin {
+		return resp, errors.New("You are not a member of this studio")
+	}
+	seeAll := role >= StudioRoleAdmin || caller.Role == RoleSiteAdmin
+
+	now := time.Now()
+	transfers := studioOwnershipTransfersTx(ctx.Tx, req.StudioId)
+	resp.Transfers = []OwnershipTransferItem{}
+	for i := len(transfers) - 1; i >= 0; i-- {
+		transfer := transfers[i]
+		open := transfer.isOpen(now)
+		canRespond := open && transfer.ToUserId == caller.Id
+		if !seeAll && !canRespond {
+			continue
+		}
+		resp.Transfers = append(resp.Transfers, OwnershipTransferItem{
+			OwnershipTransfer: transfer,
+			FromName:          GetUser(ctx.Tx, transfer.FromUserId).Name,
+			ToName:            GetUser(ctx.Tx, transfer.ToUserId).Name,
+			IsExpired:         transfer.Status == OwnershipTransferPending && !open,
+			CanRespond:        canRespond,
+			CanCancel:         open && transfer.FromUserId == caller.Id,
+		})
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestStudioOwnershipTransfer(t *testing.T) {
+	db, _, studio, _ := setupTestStudioRoom(t)
+	mailer := appMailer.(*MemoryMailer)
+	owner := addTestStudioMember(t, db, studio.Id, "owner@test.com", StudioRoleOwner)
+	first := addTestStudioMember(t, db, studio.Id, "first@test.com", StudioRoleMember)
+	second := addTestStudioMember(t, db, studio.Id, "second@test.com", StudioRoleViewer)
+	outsider := createTestInvitee(t, db, "outsider@test.com", true)
+
+	offer := func(callerId int, newOwnerId int) (resp OfferStudioOwnershipResponse, err error) {
+		token, _ := createTestToken(callerId)
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = OfferStudioOwnership(&vbeam.Context{Tx: tx, Token: token}, OfferStudioOwnershipRequest{StudioId: studio.Id, NewOwnerId: newOwnerId})
+		})
+		return
+	}
+	respond := func(proc func(*vbeam.Context, OwnershipTransferRequest) (OwnershipTransferResponse, error), callerId int, transferId int) (err error) {
+		token, _ := createTestToken(callerId)
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			_, err = proc(&vbeam.Context{Tx: tx, Token: token}, OwnershipTransferRequest{TransferId: transferId})
+		})
+		return
+	}
+
+	if _, err := offer(first.Id, second.Id); err == nil {
+		t.Errorf("Expected non-owner offer to be refused")
+	}
+	if _, err := offer(owner.Id, outsider.Id); err == nil {
+		t.Errorf("Expected offer to a non-member to be refused")
+	}
+
+	// A second offer replaces the first
+	firstOffer, err := offer(owner.Id, first.Id)
+	if err != nil {
+		t.Fatalf("OfferStudioOwnership failed: %v", err)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != first.Email || !strings.Contains(sent[0].Body, studio.Name) {
+		t.Fatalf("Expected an offer email, got %+v", sent)
+	}
+	secondOffer, err := offer(owner.Id, second.Id)
+	if err != nil {
+		t.Fatalf("OfferStudioOwnership failed: %v", err)
+	}
+	if err := respond(AcceptOwnershipTransfer, first.Id, firstOffer.Transfer.Id); err == nil {
+		t.Errorf("Expected the replaced offer to be closed")
+	}
+
+	// Only the recipient can answer
+	if err := respond(AcceptOwnershipTransfer, first.Id, secondOffer.Transfer.Id); err == nil {
+		t.Errorf("Expected someone else's offer to be refused")
+	}
+	if err := respond(AcceptOwnershipTransfer, second.Id, secondOffer.Transfer.Id); err != nil {
+		t.Fatalf("AcceptOwnershipTransfer failed: %v", err)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if GetStudioById(tx, studio.Id).OwnerId != second.Id {
+			t.Errorf("Expected studio owner to change")
+		}
+		if GetUserStudioRole(tx, second.Id, studio.Id) != StudioRoleOwner || GetUserStudioRole(tx, owner.Id, studio.Id) != StudioRoleAdmin {
+			t.Errorf("Expected the new owner to be Owner and the old owner Admin")
+		}
+	})
+	if err := respond(DeclineOwnershipTransfer, second.Id, secondOffer.Transfer.Id); err == nil {
+		t.Errorf("Expected an answered transfer to stay closed")
+	}
+
+	// Only the owner who made an offer can withdraw it
+	pending, _ := offer(second.Id, first.Id)
+	if err := respond(CancelOwnershipTransfer, first.Id, pending.Transfer.Id); err == nil {
+		t.Errorf("Expected only the offering owner to cancel")
+	}
+
+	list := func(callerId int) ListOwnershipTransfersResponse {
+		token, _ := createTestToken(callerId)
+		var resp ListOwnershipTransfersResponse
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = ListOwnershipTransfers(&vbeam.Context{Tx: tx, Token: token}, ListOwnershipTransfersRequest{StudioId: studio.Id})
+		})
+		if err != nil {
+			t.Fatalf("ListOwnershipTransfers failed: %v", err)
+		}
+		return resp
+	}
+	history := list(owner.Id).Transfers
+	if len(history) != 3 || history[0].Status != OwnershipTransferPending || history[1].Status != OwnershipTransferAccepted || history[2].Status != OwnershipTransferCancelled {
+		t.Fatalf("Unexpected ownership history: %+v", history)
+	}
+	if history[1].FromName != owner.Name || history[1].RespondedAt.IsZero() {
+		t.Errorf("Expected the accepted transfer to record who and when, got %+v", history[1])
+	}
+	if history[0].CanCancel || history[0].CanRespond {
+		t.Errorf("Expected the old owner to see someone else's offer read-only, got %+v", history[0])
+	}
+	if mine := list(first.Id).Transfers; len(mine) != 1 || mine[0].Id != pending.Transfer.Id || !mine[0].CanRespond {
+		t.Errorf("Expected members to see only their open offer, got %+v", mine)
+	}
+
+	if err := respond(DeclineOwnershipTransfer, first.Id, pending.Transfer.Id); err != nil {
+		t.Fatalf("DeclineOwnershipTransfer failed: %v", err)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		if GetStudioById(tx, studio.Id).OwnerId != second.Id {
+			t.Errorf("Expected declining to leave ownership alone")
+		}
+	})
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"stream/cfg"
 	"time"
 
@@ -157,6 +160,48 @@ func GetStream(tx *vbolt.Tx, streamId int) (stream Stream) {
 	return
 }
 
+// liveStream returns the room's stream session that hasn't ended yet, if any
+func liveStream(tx *vbolt.Tx, roomId int) (stream Stream) {
+	var streamIds []int
+	vbolt.ReadTermTargets(tx, StreamsByRoomIdx, roomId, &streamIds, vbolt.Window{})
+	if len(streamIds) == 0 {
+		return
+	}
+	// Stream ids grow over time, so only the newest can still be live
+	stream = GetStream(tx, streamIds[len(streamIds)-1])
+	if !stream.EndTime.IsZero() {
+		stream = Stream{}
+	}
+	return
+}
+
+// startStreamTx records a new stream session for the room, ending any session
+// left open by a publisher that dropped without unpublishing
+func startStreamTx(tx *vbolt.Tx, room Room) Stream {
+	endStreamTx(tx, room.Id)
+	stream := Stream{
+		Id:        vbolt.NextIntId(tx, StreamsBkt),
+		StudioId:  room.StudioId,
+		RoomId:    room.Id,
+		Title:     room.Name,
+		StartTime: time.Now(),
+	}
+	vbolt.Write(tx, StreamsBkt, stream.Id, &stream)
+	vbolt.SetTargetSingleTerm(tx, StreamsByStudioIdx, stream.Id, stream.StudioId)
+	vbolt.SetTargetSingleTerm(tx, StreamsByRoomIdx, stream.Id, stream.RoomId)
+	return stream
+}
+
+// endStreamTx marks the room's live stream session as ended
+func endStreamTx(tx *vbolt.Tx, roomId int) Stream {
+	stream := liveStream(tx, roomId)
+	if stream.Id != 0 {
+		stream.EndTime = time.Now()
+		vbolt.Write(tx, StreamsBkt, stream.Id, &stream)
+	}
+	return stream
+}
+
 // ListStudioRooms returns all rooms for a studio
 func ListStudioRooms(tx *vbolt.Tx, studioId int) []Room {
 	var rooms []Room
@@ -236,6 +281,8 @@ type GetStudioDashboardResponse struct {
 	MyRoleName string              `json:"myRoleName"`
 	Rooms      []RoomWithStudio    `json:"rooms,omitempty"`
 	Members    []MemberWithDetails `json:"members,omitempty"`
+
+	MyCapabilities []StudioCapability `json:"myCapabilities"`
 }
 
 type UpdateStudioRequest struct {
@@ -613,7 +660,7 @@ func GetStudioDashboard(ctx *vbeam.Context, req GetStudioDashboardRequest) (resp
 				StudioMembership: membership,
 				UserName:         user.Name,
 				UserEmail:        user.Email,
-				RoleName:         GetStudioRoleName(membership.Role),
+				RoleName:         memberRoleName(ctx.Tx, membership),
 			})
 		}
 	}
@@ -623,6 +670,7 @@ func GetStudioDashboard(ctx *vbeam.Context, req GetStudioDashboardRequest) (resp
 	resp.MyRoleName = GetStudioRoleName(access.Role)
 	resp.Rooms = rooms
 	resp.Members = members
+	resp.MyCapabilities = GetStudioCapabilities(ctx.Tx, caller.Id, studio.Id)
 	return
 }
 
@@ -700,6 +748,7 @@ func DeleteStudio(ctx *vbeam.Context, req DeleteStudioRequest) (resp DeleteStudi
 	}
 
 	vbeam.UseWriteTx(ctx)
+	db := ctx.Tx.DB()
 
 	// Cascade delete all related data
 
@@ -724,6 +773,11 @@ func DeleteStudio(ctx *vbeam.Context, req DeleteStudioRequest) (resp DeleteStudi
 		// Delete room analytics
 		vbolt.Delete(ctx.Tx, RoomAnalyticsBkt, room.Id)
 
+		// Delete pinned chat announcement, polls and questions
+		vbolt.Delete(ctx.Tx, ChatPinsBkt, room.Id)
+		deleteRoomPollsTx(ctx.Tx, room.Id)
+		deleteRoomQuestionsTx(ctx.Tx, room.Id)
+
 		// Remove stream key lookup
 		vbolt.Delete(ctx.Tx, RoomStreamKeyBkt, room.StreamKey)
 
@@ -750,7 +804,9 @@ func DeleteStudio(ctx *vbeam.Context, req DeleteStudioRequest) (resp DeleteStudi
 			// Unindex from both studio and room indexes
 			vbolt.SetTargetSingleTerm(ctx.Tx, StreamsByStudioIdx, streamId, -1)
 			vbolt.SetTargetSingleTerm(ctx.Tx, StreamsByRoomIdx, streamId, -1)
-			// Delete stream
+			// Delete stream, its chat archive and emote tally
+			deleteStreamChatTx(ctx.Tx, streamId)
+			vbolt.Delete(ctx.Tx, StreamEmoteCountsBkt, streamId)
 			vbolt.Delete(ctx.Tx, StreamsBkt, streamId)
 		}
 	}
@@ -773,11 +829,21 @@ func DeleteStudio(ctx *vbeam.Context, req DeleteStudioRequest) (resp DeleteStudi
 		}
 	}
 
-	// 6. Delete the studio itself
+	// 6. Delete the studio itself and its emotes
+	vbolt.Delete(ctx.Tx, StudioEmoteSetsBkt, studio.Id)
 	vbolt.Delete(ctx.Tx, StudiosBkt, studio.Id)
 
 	vbolt.TxCommit(ctx.Tx)
 
+	// Nobody is watching the deleted rooms
+	var roomIds []int
+	for _, room := range rooms {
+		roomIds = append(roomIds, room.Id)
+	}
+	dropRoomPresence(db, roomIds)
+
+	os.RemoveAll(filepath.Join(emoteDir, strconv.Itoa(studio.Id)))
+
 	// Log studio deletion
 	LogInfo(LogCategorySystem, "Studio deleted", map[string]interface{}{
 		"studioId":           studio.Id,
@@ -910,12 +976,7 @@ func GetRoomDetails(ctx *vbeam.Context, req GetRoomDetailsRequest) (resp GetRoom
 	// For anonymous users, extract session token from JWT
 	var anonymousSessionToken string
 	if caller.Id == -1 {
-		token, tokenErr := jwt.ParseWithClaims(ctx.Token, &Claims{}, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("unexpected signing method")
-			}
-			return jwtKey, nil
-		})
+		token, tokenErr := jwt.ParseWithClaims(ctx.Token, &Claims{}, jwtKeyFunc)
 
 		if tokenErr == nil && token.Valid {
 			if claims, ok := token.Claims.(*Claims); ok {
@@ -1019,12 +1080,7 @@ func ListMyAccessibleRooms(ctx *vbeam.Context, req ListMyAccessibleRoomsRequest)
 
 	if caller.Id == -1 {
 		// Anonymous user - extract session token from JWT
-		token, tokenErr := jwt.ParseWithClaims(ctx.Token, &Claims{}, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("unexpected signing method")
-			}
-			return jwtKey, nil
-		})
+		token, tokenErr := jwt.ParseWithClaims(ctx.Token, &Claims{}, jwtKeyFunc)
 
 		if tokenErr == nil && token.Valid {
 			if claims, ok := token.Claims.(*Claims); ok && claims.UserId == -1 {
@@ -1138,12 +1194,7 @@ func GetStudioRoomsForCodeSession(ctx *vbeam.Context, req GetStudioRoomsForCodeS
 	}
 
 	// Parse JWT to get session token
-	token, tokenErr := jwt.ParseWithClaims(ctx.Token, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	token, tokenErr := jwt.ParseWithClaims(ctx.Token, &Claims{}, jwtKeyFunc)
 
 	if tokenErr != nil || !token.Valid {
 		LogErrorSimple(LogCategorySystem, "JWT parsing failed in GetStudioRoomsForCodeSession", map[string]interface{}{
@@ -1445,53 +1496,18 @@ func cleanupCodeSessions(tx *vbolt.Tx, code string) {
 	}
 }
 
-// cleanupViewerSessionsForRoom removes all viewer sessions for a room
-// and properly decrements viewer counts in analytics
+// cleanupViewerSessionsForRoom removes all viewer sessions for a room. The
+// caller drops the room's presence after committing (see dropRoomPresence).
 // Returns the number of sessions cleaned up
 func cleanupViewerSessionsForRoom(tx *vbolt.Tx, roomId int) int {
 	var sessionKeys []string
 	vbolt.ReadTermTargets(tx, SessionsByRoomIndex, roomId, &sessionKeys, vbolt.Window{})
 
-	room := GetRoom(tx, roomId)
-
 	for _, sessionKey := range sessionKeys {
-		var session ViewerSession
-		vbolt.Read(tx, ViewerSessionsBkt, sessionKey, &session)
-		if session.SessionKey == "" {
-			continue
-		}
-
-		// Decrement room analytics
-		var roomAnalytics RoomAnalytics
-		vbolt.Read(tx, RoomAnalyticsBkt, roomId, &roomAnalytics)
-		if roomAnalytics.CurrentViewers > 0 {
-			roomAnalytics.CurrentViewers--
-			vbolt.Write(tx, RoomAnalyticsBkt, roomId, &roomAnalytics)
-		}
-
-		// Decrement studio analytics
-		if room.StudioId > 0 {
-			var studioAnalytics StudioAnalytics
-			vbolt.Read(tx, StudioAnalyticsBkt, room.StudioId, &studioAnalytics)
-			if studioAnalytics.CurrentViewers > 0 {
-				studioAnalytics.CurrentViewers--
-				vbolt.Write(tx, StudioAnalyticsBkt, room.StudioId, &studioAnalytics)
-			}
-		}
-
-		// Decrement code analytics if this is a code session
-		if session.Code != "" {
-			var codeAnalytics CodeAnalytics
-			vbolt.Read(tx, CodeAnalyticsBkt, session.Code, &codeAnalytics)
-			if codeAnalytics.CurrentViewers > 0 {
-				codeAnalytics.CurrentViewers--
-				vbolt.Write(tx, CodeAnalyticsBkt, session.Code, &codeAnalytics)
-			}
-		}
-
 		// Remove from indexes
 		vbolt.SetTargetSingleTerm(tx, SessionsByRoomIndex, sessionKey, -1)
 		vbolt.SetTargetSingleTerm(tx, SessionsByCodeIndex, sessionKey, "")
+		vbolt.SetTargetSingleTerm(tx, SessionsByViewerIndex, sessionKey, "")
 
 		// Delete the viewer session
 		vbolt.Delete(tx, ViewerSessionsBkt, sessionKey)
@@ -1530,6 +1546,7 @@ func DeleteRoom(ctx *vbeam.Context, req DeleteRoomRequest) (resp DeleteRoomRespo
 	}
 
 	vbeam.UseWriteTx(ctx)
+	db := ctx.Tx.DB()
 
 	// Cascade delete all related data
 
@@ -1542,7 +1559,9 @@ func DeleteRoom(ctx *vbeam.Context, req DeleteRoomRequest) (resp DeleteRoomRespo
 			// Unindex from both room and studio indexes
 			vbolt.SetTargetSingleTerm(ctx.Tx, StreamsByRoomIdx, streamId, -1)
 			vbolt.SetTargetSingleTerm(ctx.Tx, StreamsByStudioIdx, streamId, -1)
-			// Delete stream
+			// Delete stream, its chat archive and emote tally
+			deleteStreamChatTx(ctx.Tx, streamId)
+			vbolt.Delete(ctx.Tx, StreamEmoteCountsBkt, streamId)
 			vbolt.Delete(ctx.Tx, StreamsBkt, streamId)
 		}
 	}
@@ -1559,8 +1578,11 @@ func DeleteRoom(ctx *vbeam.Context, req DeleteRoomRequest) (resp DeleteRoomRespo
 	// 5. Delete camera configuration if exists
 	DeleteCameraConfigData(ctx.Tx, room.Id)
 
-	// 6. Delete room analytics
+	// 6. Delete room analytics, pinned chat announcement, polls and questions
 	vbolt.Delete(ctx.Tx, RoomAnalyticsBkt, room.Id)
+	vbolt.Delete(ctx.Tx, ChatPinsBkt, room.Id)
+	deleteRoomPollsTx(ctx.Tx, room.Id)
+	deleteRoomQuestionsTx(ctx.Tx, room.Id)
 
 	// 7. Unindex room from studio
 	vbolt.SetTargetSingleTerm(ctx.Tx, RoomsByStudioIdx, room.Id, -1)
@@ -1570,6 +1592,9 @@ func DeleteRoom(ctx *vbeam.Context, req DeleteRoomRequest) (resp DeleteRoomRespo
 
 	vbolt.TxCommit(ctx.Tx)
 
+	// Nobody is watching a deleted room
+	dropRoomPresence(db, []int{room.Id})
+
 	// Log room deletion
 	LogInfo(LogCategorySystem, "Room deleted", map[string]interface{}{
 		"roomId":          room.Id,
@@ -1699,10 +1724,11 @@ func ValidateStreamKey(ctx *vbeam.Context, req SRSAuthCallback) (resp SRSAuthRes
 		return
 	}
 
-	// Stream key is valid, mark room as active
+	// Stream key is valid, mark room as active and start a stream session
 	vbeam.UseWriteTx(ctx)
 	room.IsActive = true
 	vbolt.Write(ctx.Tx, RoomsBkt, room.Id, &room)
+	startStreamTx(ctx.Tx, room)
 	vbolt.TxCommit(ctx.Tx)
 
 	RecordStreamStart(appDb, room.Id, room.StudioId)
@@ -1748,18 +1774,20 @@ func HandleStreamUnpublish(ctx *vbeam.Context, req SRSAuthCallback) (resp SRSAut
 	room := GetRoomByStreamKey(ctx.Tx, streamKey)
 
 	if room.Id > 0 {
-		// Mark room as inactive
+		// Mark room as inactive and end its stream session
 		vbeam.UseWriteTx(ctx)
 		room.IsActive = false
 		room.IsHlsReady = false
 		vbolt.Write(ctx.Tx, RoomsBkt, room.Id, &room)
+		endStreamTx(ctx.Tx, room.Id)
 		vbolt.TxCommit(ctx.Tx)
 
 		RecordStreamStop(appDb, room.Id, room.StudioId)
 
-		// Delete all chat messages for this room (messages are ephemeral)
+		// Clear the live chat; the stream's messages stay in its archive
+		// unless the studio doesn't keep chat
 		vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
-			DeleteChatMessagesForRoom(tx, room.Id)
+			archiveRoomChatTx(tx, room)
 			vbolt.TxCommit(tx)
 		})
 
@@ -1817,6 +1845,8 @@ func ResetAllRoomStreaming(db *vbolt.DB) {
 			if room.IsActive {
 				room.IsActive = false
 				vbolt.Write(tx, RoomsBkt, roomId, &room)
+				endStreamTx(tx, roomId)
+				archiveRoomChatTx(tx, room)
 				resetCount++
 
 				LogInfo(LogCategorySystem, "RESET room streaming state on startup", map[string]interface{}{
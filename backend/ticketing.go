@@ -0,0 +1,1046 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"stream/cfg"
+	"strings"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+// TicketPurchaseStatus tracks a purchase through payment and refund
+type TicketPurchaseStatus int
+
+const (
+	TicketPurchasePending  TicketPurchaseStatus = 0 // Checkout started, not paid yet
+	TicketPurchasePaid     TicketPurchaseStatus = 1 // Paid, access code minted
+	TicketPurchaseRefunded TicketPurchaseStatus = 2 // Refunded, access code revoked
+)
+
+// TicketProduct is a paid ticket for an event (room or studio access)
+type TicketProduct struct {
+	Id          int      `json:"id"`
+	StudioId    int      `json:"studioId"`    // Owning studio (for permissions)
+	Type        CodeType `json:"type"`        // Type of access code minted (room or studio)
+	TargetId    int      `json:"targetId"`    // Room ID or Studio ID
+	Name        string   `json:"name"`        // e.g., "Spring Recital 2026"
+	Description string   `json:"description"` // Shown on the purchase page
+
+	PriceCents int    `json:"priceCents"` // Price in the smallest currency unit
+	Currency   string `json:"currency"`   // ISO currency code, e.g., "usd"
+	Capacity   int    `json:"capacity"`   // 0=unlimited, >0=max tickets sold
+	SoldCount  int    `json:"soldCount"`  // Paid, non-refunded tickets
+
+	SaleStartsAt    time.Time `json:"saleStartsAt"`    // Tickets can be bought from this time
+	SaleEndsAt      time.Time `json:"saleEndsAt"`      // ... until this time
+	AccessExpiresAt time.Time `json:"accessExpiresAt"` // Expiry of the minted access codes
+	MaxViewers      int       `json:"maxViewers"`      // MaxViewers of each minted code (0=unlimited)
+
+	IsActive  bool      `json:"isActive"` // Inactive products can't be purchased
+	CreatedBy int       `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TicketPurchase records one ticket bought through the payment provider
+type TicketPurchase struct {
+	Id          int                  `json:"id"`
+	ProductId   int                  `json:"productId"`
+	StudioId    int                  `json:"studioId"`
+	BuyerEmail  string               `json:"buyerEmail"`
+	BuyerName   string               `json:"buyerName"`
+	AmountCents int                  `json:"amountCents"`
+	Currency    string               `json:"currency"`
+	Provider    string               `json:"provider"`   // e.g., "stripe"
+	SessionId   string               `json:"sessionId"`  // Provider checkout session ID
+	PaymentRef  string               `json:"paymentRef"` // Provider payment ID (set once paid)
+	Status      TicketPurchaseStatus `json:"status"`
+	Code        string               `json:"code"` // Minted access code (set once paid)
+	CreatedAt   time.Time            `json:"createdAt"`
+	PaidAt      time.Time            `json:"paidAt"`
+	RefundedAt  time.Time            `json:"refundedAt"`
+	EmailedAt   time.Time            `json:"emailedAt"`  // When the code was last emailed (zero if never delivered)
+	EmailError  string               `json:"emailError"` // Why the last attempt to email the code failed
+}
+
+// Packing functions for vbolt serialization
+
+func PackTicketProduct(self *TicketProduct, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.Int((*int)(&self.Type), buf)
+	vpack.Int(&self.TargetId, buf)
+	vpack.String(&self.Name, buf)
+	vpack.String(&self.Description, buf)
+	vpack.Int(&self.PriceCents, buf)
+	vpack.String(&self.Currency, buf)
+	vpack.Int(&self.Capacity, buf)
+	vpack.Int(&self.SoldCount, buf)
+	vpack.Time(&self.SaleStartsAt, buf)
+	vpack.Time(&self.SaleEndsAt, buf)
+	vpack.Time(&self.AccessExpiresAt, buf)
+	vpack.Int(&self.MaxViewers, buf)
+	vpack.Bool(&self.IsActive, buf)
+	vpack.Int(&self.CreatedBy, buf)
+	vpack.Time(&self.CreatedAt, buf)
+}
+
+func PackTicketPurchase(self *TicketPurchase, buf *vpack.Buffer) {
+	version := vpack.Version(2, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.Int(&self.ProductId, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.String(&self.BuyerEmail, buf)
+	vpack.String(&self.BuyerName, buf)
+	vpack.Int(&self.AmountCents, buf)
+	vpack.String(&self.Currency, buf)
+	vpack.String(&self.Provider, buf)
+	vpack.String(&self.SessionId, buf)
+	vpack.String(&self.PaymentRef, buf)
+	vpack.Int((*int)(&self.Status), buf)
+	vpack.String(&self.Code, buf)
+	vpack.Time(&self.CreatedAt, buf)
+	vpack.Time(&self.PaidAt, buf)
+	vpack.Time(&self.RefundedAt, buf)
+	if version >= 2 {
+		vpack.Time(&self.EmailedAt, buf)
+		vpack.String(&self.EmailError, buf)
+	}
+}
+
+// Buckets for entity storage
+
+// TicketProductsBkt: productId (int) -> TicketProduct
+var TicketProductsBkt = vbolt.Bucket(&cfg.Info, "ticket_products", vpack.FInt, PackTicketProduct)
+
+// TicketPurchasesBkt: purchaseId (int) -> TicketPurchase
+var TicketPurchasesBkt = vbolt.Bucket(&cfg.Info, "ticket_purchases", vpack.FInt, PackTicketPurchase)
+
+// TicketPurchaseBySessionBkt: checkout session ID (string) -> purchaseId
+var TicketPurchaseBySessionBkt = vbolt.Bucket(&cfg.Info, "ticket_purchase_by_session", vpack.StringZ, vpack.Int)
+
+// TicketPurchaseByPaymentBkt: payment ref (string) -> purchaseId
+var TicketPurchaseByPaymentBkt = vbolt.Bucket(&cfg.Info, "ticket_purchase_by_payment", vpack.StringZ, vpack.Int)
+
+// Indexes for relationship queries
+
+// TicketProductsByStudioIdx: studioId (term) -> productId (target)
+var TicketProductsByStudioIdx = vbolt.Index(&cfg.Info, "ticket_products_by_studio", vpack.FInt, vpack.FInt)
+
+// TicketPurchasesByProductIdx: productId (term) -> purchaseId (target)
+var TicketPurchasesByProductIdx = vbolt.Index(&cfg.Info, "ticket_purchases_by_product", vpack.FInt, vpack.FInt)
+
+// Database helpers
+
+func GetTicketProduct(tx *vbolt.Tx, productId int) (product TicketProduct) {
+	vbolt.Read(tx, TicketProductsBkt, productId, &product)
+	return
+}
+
+func GetTicketPurchase(tx *vbolt.Tx, purchaseId int) (purchase TicketPurchase) {
+	vbolt.Read(tx, TicketPurchasesBkt, purchaseId, &purchase)
+	return
+}
+
+// resolveCodeTarget validates a room/studio code target and returns its studio ID and name
+func resolveCodeTarget(tx *vbolt.Tx, codeType CodeType, targetId int) (studioId int, targetName string, err error) {
+	switch codeType {
+	case CodeTypeRoom:
+		room := GetRoom(tx, targetId)
+		if room.Id == 0 {
+			return 0, "", errors.New("Room not found")
+		}
+		return room.StudioId, room.Name, nil
+	case CodeTypeStudio:
+		studio := GetStudioById(tx, targetId)
+		if studio.Id == 0 {
+			return 0, "", errors.New("Studio not found")
+		}
+		return studio.Id, studio.Name, nil
+	default:
+		return 0, "", errors.New("Invalid code type (must be 0 for room or 1 for studio)")
+	}
+}
+
+// ticketsRemaining returns how many tickets can still be sold (-1 for unlimited)
+func ticketsRemaining(product TicketProduct) int {
+	if product.Capacity <= 0 {
+		return -1
+	}
+	remaining := product.Capacity - product.SoldCount
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// checkTicketOnSale returns an error if the product can't be bought right now
+func checkTicketOnSale(product TicketProduct, now time.Time) error {
+	if !product.IsActive {
+		return errors.New("Tickets are not available")
+	}
+	if now.Before(product.SaleStartsAt) {
+		return errors.New("Ticket sales have not started yet")
+	}
+	if !now.Before(product.SaleEndsAt) {
+		return errors.New("Ticket sales have ended")
+	}
+	if ticketsRemaining(product) == 0 {
+		return errors.New("Tickets are sold out")
+	}
+	return nil
+}
+
+// validateTicketProductFields checks the editable fields shared by create and update
+func validateTicketProductFields(name string, priceCents int, capacity int, saleStartsAt, saleEndsAt, accessExpiresAt time.Time, maxViewers int) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("Ticket name is required")
+	}
+	if len(name) > 200 {
+		return errors.New("Ticket name is too long (max 200 characters)")
+	}
+	if priceCents <= 0 {
+		return errors.New("Price must be greater than zero")
+	}
+	if capacity < 0 {
+		return errors.New("Capacity cannot be negative")
+	}
+	if maxViewers < 0 {
+		return errors.New("Max viewers cannot be negative")
+	}
+	if saleStartsAt.IsZero() || saleEndsAt.IsZero() {
+		return errors.New("Sale window is required")
+	}
+	if !saleEndsAt.After(saleStartsAt) {
+		return errors.New("Sale end must be after sale start")
+	}
+	if accessExpiresAt.Before(saleEndsAt) {
+		return errors.New("Access expiry must not be before the end of the sale")
+	}
+	return nil
+}
+
+// API Request/Response types
+
+type CreateTicketProductRequest struct {
+	Type            int       `json:"type"`     // 0=room, 1=studio
+	TargetId        int       `json:"targetId"` // Room ID or Studio ID
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	PriceCents      int       `json:"priceCents"`
+	Currency        string    `json:"currency"` // Defaults to "usd"
+	Capacity        int       `json:"capacity"` // 0=unlimited
+	SaleStartsAt    time.Time `json:"saleStartsAt"`
+	SaleEndsAt      time.Time `json:"saleEndsAt"`
+	AccessExpiresAt time.Time `json:"accessExpiresAt"`
+	MaxViewers      int       `json:"maxViewers"` // Per ticket, 0=unlimited
+}
+
+type CreateTicketProductResponse struct {
+	Product TicketProduct `json:"product"`
+}
+
+type UpdateTicketProductRequest struct {
+	ProductId       int       `json:"productId"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	PriceCents      int       `json:"priceCents"`
+	Capacity        int       `json:"capacity"`
+	SaleStartsAt    time.Time `json:"saleStartsAt"`
+	SaleEndsAt      time.Time `json:"saleEndsAt"`
+	AccessExpiresAt time.Time `json:"accessExpiresAt"`
+	MaxViewers      int       `json:"maxViewers"`
+	IsActive        bool      `json:"isActive"`
+}
+
+type UpdateTicketProductResponse struct {
+	Product TicketProduct `json:"product"`
+}
+
+type ListTicketProductsRequest struct {
+	StudioId int `json:"studioId"`
+}
+
+type ListTicketProductsResponse struct {
+	Products []TicketProduct `json:"products"`
+}
+
+type GetTicketInfoRequest struct {
+	ProductId int `json:"productId"`
+}
+
+type GetTicketInfoResponse struct {
+	Id           int       `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	TargetName   string    `json:"targetName"`
+	PriceCents   int       `json:"priceCents"`
+	Currency     string    `json:"currency"`
+	SaleStartsAt time.Time `json:"saleStartsAt"`
+	SaleEndsAt   time.Time `json:"saleEndsAt"`
+	Remaining    int       `json:"remaining"` // -1 for unlimited
+	OnSale       bool      `json:"onSale"`
+	Message      string    `json:"message,omitempty"` // Why it can't be bought, if not on sale
+}
+
+type PurchaseTicketRequest struct {
+	ProductId  int    `json:"productId"`
+	BuyerEmail string `json:"buyerEmail"`
+	BuyerName  string `json:"buyerName"`
+}
+
+type PurchaseTicketResponse struct {
+	CheckoutURL string `json:"checkoutURL"`
+}
+
+type ListTicketPurchasesRequest struct {
+	ProductId int `json:"productId"`
+}
+
+type ListTicketPurchasesResponse struct {
+	Purchases []TicketPurchase `json:"purchases"`
+}
+
+type RefundTicketPurchaseRequest struct {
+	PurchaseId int `json:"purchaseId"`
+}
+
+type RefundTicketPurchaseResponse struct {
+	SessionsKilled int `json:"sessionsKilled"`
+}
+
+type ResendTicketEmailRequest struct {
+	PurchaseId int `json:"purchaseId"`
+}
+
+type ResendTicketEmailResponse struct {
+	EmailedAt time.Time `json:"emailedAt"`
+}
+
+// RegisterTicketingMethods registers ticketing procedures and the payment webhook
+func RegisterTicketingMethods(app *vbeam.Application) {
+	// Provider webhook (no auth - verified by the provider's signature)
+	app.HandleFunc("/api/tickets/webhook", ticketWebhookHandler)
+
+	vbeam.RegisterProc(app, CreateTicketProduct)
+	vbeam.RegisterProc(app, UpdateTicketProduct)
+	vbeam.RegisterProc(app, ListTicketProducts)
+	vbeam.RegisterProc(app, GetTicketInfo)
+	vbeam.RegisterProc(app, PurchaseTicket)
+	vbeam.RegisterProc(app, ListTicketPurchases)
+	vbeam.RegisterProc(app, RefundTicketPurchase)
+	vbeam.RegisterProc(app, ResendTicketEmail)
+
+	if err := SetupPayments(); err != nil {
+		log.Printf("Payments setup failed: %v", err)
+		log.Println("Ticket sales will not be available. Set STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET to enable.")
+	}
+}
+
+// API Procedures
+
+// CreateTicketProduct creates a paid ticket for a room or studio
+func CreateTicketProduct(ctx *vbeam.Context, req CreateTicketProductRequest) (resp CreateTicketProductResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	studioId, _, err := resolveCodeTarget(ctx.Tx, CodeType(req.Type), req.TargetId)
+	if err != nil {
+		return resp, err
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapManageCodes) {
+		return resp, errors.New("Only studio admins can manage tickets")
+	}
+
+	err = validateTicketProductFields(req.Name, req.PriceCents, req.Capacity, req.SaleStartsAt, req.SaleEndsAt, req.AccessExpiresAt, req.MaxViewers)
+	if err != nil {
+		return resp, err
+	}
+
+	currency := strings.ToLower(strings.TrimSpace(req.Currency))
+	if currency == "" {
+		currency = "usd"
+	}
+	if len(currency) != 3 {
+		return resp, errors.New("Invalid currency")
+	}
+
+	vbeam.UseWriteTx(ctx)
+
+	product := TicketProduct{
+		Id:              vbolt.NextIntId(ctx.Tx, TicketProductsBkt),
+		StudioId:        studioId,
+		Type:            CodeType(req.Type),
+		TargetId:        req.TargetId,
+		Name:            strings.TrimSpace(req.Name),
+		Description:     req.Description,
+		PriceCents:      req.PriceCents,
+		Currency:        currency,
+		Capacity:        req.Capacity,
+		SaleStartsAt:    req.SaleStartsAt,
+		SaleEndsAt:      req.SaleEndsAt,
+		AccessExpiresAt: req.AccessExpiresAt,
+		MaxViewers:      req.MaxViewers,
+		IsActive:        true,
+		CreatedBy:       caller.Id,
+		CreatedAt:       time.Now(),
+	}
+	vbolt.Write(ctx.Tx, TicketProductsBkt, product.Id, &product)
+	vbolt.SetTargetSingleTerm(ctx.Tx, TicketProductsByStudioIdx, product.Id, studioId)
+
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Ticket product created", map[string]interface{}{
+		"productId":  product.Id,
+		"studioId":   studioId,
+		"name":       product.Name,
+		"priceCents": product.PriceCents,
+		"currency":   product.Currency,
+		"capacity":   product.Capacity,
+		"createdBy":  caller.Id,
+	})
+
+	resp.Product = product
+	return
+}
+
+// UpdateTicketProduct edits a ticket product. Target and currency are fixed once created.
+func UpdateTicketProduct(ctx *vbeam.Context, req UpdateTicketProductRequest) (resp UpdateTicketProductResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	product := GetTicketProduct(ctx.Tx, req.ProductId)
+	if product.Id == 0 {
+		return resp, errors.New("Ticket not found")
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, product.StudioId, CapManageCodes) {
+		return resp, errors.New("Only studio admins can manage tickets")
+	}
+
+	err = validateTicketProductFields(req.Name, req.PriceCents, req.Capacity, req.SaleStartsAt, req.SaleEndsAt, req.AccessExpiresAt, req.MaxViewers)
+	if err != nil {
+		return resp, err
+	}
+
+	if req.Capacity > 0 && req.Capacity < product.SoldCount {
+		return resp, errors.New("Capacity cannot be lower than the number of tickets sold")
+	}
+
+	vbeam.UseWriteTx(ctx)
+
+	product.Name = strings.TrimSpace(req.Name)
+	product.Description = req.Description
+	product.PriceCents = req.PriceCents
+	product.Capacity = req.Capacity
+	product.SaleStartsAt = req.SaleStartsAt
+	product.SaleEndsAt = req.SaleEndsAt
+	product.AccessExpiresAt = req.AccessExpiresAt
+	product.MaxViewers = req.MaxViewers
+	product.IsActive = req.IsActive
+	vbolt.Write(ctx.Tx, TicketProductsBkt, product.Id, &product)
+
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Ticket product updated", map[string]interface{}{
+		"productId": product.Id,
+		"studioId":  product.StudioId,
+		"isActive":  product.IsActive,
+		"updatedBy": caller.Id,
+	})
+
+	resp.Product = product
+	return
+}
+
+// ListTicketProducts returns all ticket products of a studio
+func ListTicketProducts(ctx *vbeam.Context, req ListTicketProductsRequest) (resp ListTicketProductsResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, req.StudioId, CapManageCodes) {
+		return resp, errors.New("Only studio admins can manage tickets")
+	}
+
+	var productIds []int
+	vbolt.ReadTermTargets(ctx.Tx, TicketProductsByStudioIdx, req.StudioId, &productIds, vbolt.Window{})
+
+	resp.Products = make([]TicketProduct, 0, len(productIds))
+	for _, productId := range productIds {
+		product := GetTicketProduct(ctx.Tx, productId)
+		if product.Id != 0 {
+			resp.Products = append(resp.Products, product)
+		}
+	}
+	return
+}
+
+// GetTicketInfo returns the public details of a ticket (no authentication required)
+func GetTicketInfo(ctx *vbeam.Context, req GetTicketInfoRequest) (resp GetTicketInfoResponse, err error) {
+	product := GetTicketProduct(ctx.Tx, req.ProductId)
+	if product.Id == 0 || !product.IsActive {
+		return resp, errors.New("Ticket not found")
+	}
+
+	_, targetName, _ := resolveCodeTarget(ctx.Tx, product.Type, product.TargetId)
+
+	resp.Id = product.Id
+	resp.Name = product.Name
+	resp.Description = product.Description
+	resp.TargetName = targetName
+	resp.PriceCents = product.PriceCents
+	resp.Currency = product.Currency
+	resp.SaleStartsAt = product.SaleStartsAt
+	resp.SaleEndsAt = product.SaleEndsAt
+	resp.Remaining = ticketsRemaining(product)
+
+	if saleErr := checkTicketOnSale(product, time.Now()); saleErr != nil {
+		resp.Message = saleErr.Error()
+	} else {
+		resp.OnSale = true
+	}
+	return
+}
+
+// PurchaseTicket starts a checkout with the payment provider (no authentication required).
+// The access code is minted when the provider's webhook confirms payment.
+func PurchaseTicket(ctx *vbeam.Context, req PurchaseTicketRequest) (resp PurchaseTicketResponse, err error) {
+	if paymentProvider == nil {
+		return resp, errors.New("Ticket sales are not available")
+	}
+
+	buyerEmail := strings.ToLower(strings.TrimSpace(req.BuyerEmail))
+	if addr, parseErr := mail.ParseAddress(buyerEmail); parseErr != nil || addr.Address != buyerEmail {
+		return resp, errors.New("A valid email address is required")
+	}
+	buyerName := strings.TrimSpace(req.BuyerName)
+	if len(buyerName) > 100 {
+		return resp, errors.New("Name is too long (max 100 characters)")
+	}
+
+	product := GetTicketProduct(ctx.Tx, req.ProductId)
+	if product.Id == 0 {
+		return resp, errors.New("Ticket not found")
+	}
+	if err = checkTicketOnSale(product, time.Now()); err != nil {
+		return resp, err
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckTicketCheckout(buyerEmail); rateLimitErr != nil {
+		LogWarn(LogCategorySystem, "Rate limit exceeded for ticket checkout", map[string]interface{}{
+			"productId":  product.Id,
+			"buyerEmail": buyerEmail,
+		})
+		return resp, errors.New(rateLimitErr.Error())
+	}
+
+	// Create the provider checkout before opening a write transaction so we
+	// don't hold the database lock during a network call
+	session, checkoutErr := paymentProvider.CreateCheckout(CheckoutParams{
+		ProductId:   product.Id,
+		ProductName: product.Name,
+		AmountCents: product.PriceCents,
+		Currency:    product.Currency,
+		BuyerEmail:  buyerEmail,
+		SuccessURL:  fmt.Sprintf("%s/tickets/%d/success", cfg.SiteURL, product.Id),
+		CancelURL:   fmt.Sprintf("%s/tickets/%d", cfg.SiteURL, product.Id),
+	})
+	if checkoutErr != nil {
+		LogErrorSimple(LogCategorySystem, "Failed to create ticket checkout", map[string]interface{}{
+			"productId": product.Id,
+			"provider":  paymentProvider.Name(),
+			"error":     checkoutErr.Error(),
+		})
+		return resp, errors.New("Failed to start checkout")
+	}
+
+	vbeam.UseWriteTx(ctx)
+
+	purchase := TicketPurchase{
+		Id:          vbolt.NextIntId(ctx.Tx, TicketPurchasesBkt),
+		ProductId:   product.Id,
+		StudioId:    product.StudioId,
+		BuyerEmail:  buyerEmail,
+		BuyerName:   buyerName,
+		AmountCents: product.PriceCents,
+		Currency:    product.Currency,
+		Provider:    paymentProvider.Name(),
+		SessionId:   session.SessionId,
+		Status:      TicketPurchasePending,
+		CreatedAt:   time.Now(),
+	}
+	vbolt.Write(ctx.Tx, TicketPurchasesBkt, purchase.Id, &purchase)
+	vbolt.Write(ctx.Tx, TicketPurchaseBySessionBkt, session.SessionId, &purchase.Id)
+	vbolt.SetTargetSingleTerm(ctx.Tx, TicketPurchasesByProductIdx, purchase.Id, product.Id)
+
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Ticket checkout started", map[string]interface{}{
+		"productId":  product.Id,
+		"purchaseId": purchase.Id,
+		"buyerEmail": buyerEmail,
+		"provider":   purchase.Provider,
+	})
+
+	resp.CheckoutURL = session.URL
+	return
+}
+
+// ListTicketPurchases returns all purchases of a ticket product
+func ListTicketPurchases(ctx *vbeam.Context, req ListTicketPurchasesRequest) (resp ListTicketPurchasesResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	product := GetTicketProduct(ctx.Tx, req.ProductId)
+	if product.Id == 0 {
+		return resp, errors.New("Ticket not found")
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, product.StudioId, CapManageCodes) {
+		return resp, errors.New("Only studio admins can manage tickets")
+	}
+
+	var purchaseIds []int
+	vbolt.ReadTermTargets(ctx.Tx, TicketPurchasesByProductIdx, product.Id, &purchaseIds, vbolt.Window{})
+
+	resp.Purchases = make([]TicketPurchase, 0, len(purchaseIds))
+	for _, purchaseId := range purchaseIds {
+		purchase := GetTicketPurchase(ctx.Tx, purchaseId)
+		if purchase.Id != 0 {
+			resp.Purchases = append(resp.Purchases, purchase)
+		}
+	}
+	return
+}
+
+// RefundTicketPurchase refunds a paid ticket through the provider and revokes its access code
+func RefundTicketPurchase(ctx *vbeam.Context, req RefundTicketPurchaseRequest) (resp RefundTicketPurchaseResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	purchase := GetTicketPurchase(ctx.Tx, req.PurchaseId)
+	if purchase.Id == 0 {
+		return resp, errors.New("Purchase not found")
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, purchase.StudioId, CapManageCodes) {
+		return resp, errors.New("Only studio admins can manage tickets")
+	}
+
+	if purchase.Status != TicketPurchasePaid {
+		return resp, errors.New("Only paid tickets can be refunded")
+	}
+
+	if paymentProvider == nil {
+		return resp, errors.New("Ticket sales are not available")
+	}
+
+	if refundErr := paymentProvider.Refund(purchase.PaymentRef, purchase.PaymentRef); refundErr != nil {
+		LogErrorSimple(LogCategorySystem, "Ticket refund failed", map[string]interface{}{
+			"purchaseId": purchase.Id,
+			"provider":   purchase.Provider,
+			"error":      refundErr.Error(),
+		})
+		return resp, errors.New("Refund failed")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	db := ctx.Tx.DB()
+
+	// The provider's refund webhook or a second click may have got here first
+	purchase = GetTicketPurchase(ctx.Tx, req.PurchaseId)
+	if purchase.Status != TicketPurchasePaid {
+		return resp, errors.New("Only paid tickets can be refunded")
+	}
+
+	accessCode, sessionTokens, roomIds, sessionsKilled := refundTicketPurchaseTx(ctx.Tx, &purchase)
+	product := GetTicketProduct(ctx.Tx, purchase.ProductId)
+
+	vbolt.TxCommit(ctx.Tx)
+
+	if accessCode.Code != "" {
+		dropCodeSessionsPresence(db, accessCode.Code, sessionTokens)
+		broadcastCodeRevoked(accessCode, sessionTokens, roomIds)
+	}
+	sendTicketRefundEmail(purchase, product)
+
+	LogInfo(LogCategorySystem, "Ticket refunded", map[string]interface{}{
+		"purchaseId":     purchase.Id,
+		"productId":      purchase.ProductId,
+		"code":           purchase.Code,
+		"sessionsKilled": sessionsKilled,
+		"refundedBy":     caller.Id,
+	})
+
+	resp.SessionsKilled = sessionsKilled
+	return
+}
+
+// ResendTicketEmail emails a paid ticket's access code to the buyer again,
+// e.g. after the first email failed. Studio admins and the buyer's own
+// account can resend it.
+func ResendTicketEmail(ctx *vbeam.Context, req ResendTicketEmailRequest) (resp ResendTicketEmailResponse, err error) {
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	purchase := GetTicketPurchase(ctx.Tx, req.PurchaseId)
+	if purchase.Id == 0 {
+		return resp, errors.New("Purchase not found")
+	}
+
+	isBuyer := caller.Id > 0 && strings.EqualFold(caller.Email, purchase.BuyerEmail)
+	if !isBuyer && !HasStudioCapability(ctx.Tx, caller.Id, purchase.StudioId, CapManageCodes) {
+		return resp, errors.New("Only studio admins can manage tickets")
+	}
+
+	if purchase.Status != TicketPurchasePaid {
+		return resp, errors.New("Only paid tickets can be emailed")
+	}
+
+	product := GetTicketProduct(ctx.Tx, purchase.ProductId)
+	if sendErr := sendTicketEmail(purchase, product); sendErr != nil {
+		vbeam.UseWriteTx(ctx)
+		recordTicketEmailTx(ctx.Tx, purchase.Id, sendErr)
+		vbolt.TxCommit(ctx.Tx)
+		return resp, errors.New("Failed to send the ticket email")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	purchase = recordTicketEmailTx(ctx.Tx, purchase.Id, nil)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategorySystem, "Ticket email resent", map[string]interface{}{
+		"purchaseId": purchase.Id,
+		"resentBy":   caller.Id,
+	})
+
+	resp.EmailedAt = purchase.EmailedAt
+	return
+}
+
+// Fulfilment and refunds
+
+// refundTicketPurchaseTx marks a paid purchase as refunded, frees its seat and
+// revokes its access code through the same path as RevokeAccessCode.
+// The caller commits and then calls dropCodeSessionsPresence and broadcastCodeRevoked.
+func refundTicketPurchaseTx(tx *vbolt.Tx, purchase *TicketPurchase) (accessCode AccessCode, sessionTokens []string, roomIds []int, sessionsKilled int) {
+	purchase.Status = TicketPurchaseRefunded
+	purchase.RefundedAt = time.Now()
+	vbolt.Write(tx, TicketPurchasesBkt, purchase.Id, purchase)
+
+	product := GetTicketProduct(tx, purchase.ProductId)
+	if product.Id != 0 && product.SoldCount > 0 {
+		product.SoldCount--
+		vbolt.Write(tx, TicketProductsBkt, product.Id, &product)
+	}
+
+	if purchase.Code == "" {
+		return
+	}
+	vbolt.Read(tx, AccessCodesBkt, purchase.Code, &accessCode)
+	if accessCode.Code == "" || accessCode.IsRevoked {
+		return AccessCode{}, nil, nil, 0
+	}
+
+	sessionTokens, roomIds, sessionsKilled = revokeAccessCodeTx(tx, &accessCode, purchase.StudioId)
+	return
+}
+
+// handleTicketCheckoutCompleted mints the access code for a paid checkout and emails it.
+// Repeated deliveries of the same event are ignored. An error means the buyer
+// was charged but has neither a code nor a refund yet; the purchase stays
+// pending so the provider's retry of the webhook can finish the job.
+func handleTicketCheckoutCompleted(db *vbolt.DB, event PaymentEvent) error {
+	var purchase TicketPurchase
+	var product TicketProduct
+	var soldOut bool
+	var alreadyHandled bool
+	var codeErr error
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		var purchaseId int
+		vbolt.Read(tx, TicketPurchaseBySessionBkt, event.SessionId, &purchaseId)
+		purchase = GetTicketPurchase(tx, purchaseId)
+		if purchase.Id == 0 || purchase.Status != TicketPurchasePending {
+			alreadyHandled = true
+			return
+		}
+
+		product = GetTicketProduct(tx, purchase.ProductId)
+		purchase.PaymentRef = event.PaymentRef
+		purchase.PaidAt = time.Now()
+		vbolt.Write(tx, TicketPurchaseByPaymentBkt, event.PaymentRef, &purchase.Id)
+
+		// Capacity is checked again here since several checkouts can be open at once.
+		// The purchase is only marked refunded once the provider confirms the refund.
+		if product.Id == 0 || ticketsRemaining(product) == 0 {
+			soldOut = true
+			vbolt.Write(tx, TicketPurchasesBkt, purchase.Id, &purchase)
+			vbolt.TxCommit(tx)
+			return
+		}
+
+		label := fmt.Sprintf("Ticket: %s - %s", product.Name, purchase.BuyerEmail)
+		if len(label) > 200 {
+			label = label[:200]
+		}
+		// The buyer's name pre-fills their chat name, if it's usable as one
+		recipientName, _ := normalizeRecipientName(tx, product.StudioId, purchase.BuyerName)
+		var accessCode AccessCode
+		accessCode, codeErr = createAccessCodeTx(tx, AccessCode{
+			Type:       product.Type,
+			TargetId:   product.TargetId,
+			CreatedBy:  product.CreatedBy,
+			ExpiresAt:  product.AccessExpiresAt,
+			MaxViewers: product.MaxViewers,
+			Label:      label,
+
+			RecipientName: recipientName,
+		})
+		if codeErr != nil {
+			return
+		}
+
+		purchase.Status = TicketPurchasePaid
+		purchase.Code = accessCode.Code
+		vbolt.Write(tx, TicketPurchasesBkt, purchase.Id, &purchase)
+
+		product.SoldCount++
+		vbolt.Write(tx, TicketProductsBkt, product.Id, &product)
+
+		vbolt.TxCommit(tx)
+	})
+
+	if codeErr != nil {
+		LogErrorSimple(LogCategorySystem, "Failed to mint access code for ticket", map[string]interface{}{
+			"purchaseId": purchase.Id,
+			"error":      codeErr.Error(),
+		})
+		return codeErr
+	}
+
+	if alreadyHandled {
+		return nil
+	}
+
+	if soldOut {
+		LogWarn(LogCategorySystem, "Ticket sold out after payment, refunding", map[string]interface{}{
+			"purchaseId": purchase.Id,
+			"productId":  purchase.ProductId,
+		})
+		// Keyed by the payment so the webhook's retries can't refund it twice
+		if refundErr := paymentProvider.Refund(purchase.PaymentRef, purchase.PaymentRef); refundErr != nil {
+			LogErrorSimple(LogCategorySystem, "Ticket refund failed", map[string]interface{}{
+				"purchaseId": purchase.Id,
+				"provider":   purchase.Provider,
+				"error":      refundErr.Error(),
+			})
+			return refundErr
+		}
+
+		refunded := false
+		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+			purchase = GetTicketPurchase(tx, purchase.Id)
+			if purchase.Status != TicketPurchasePending {
+				return
+			}
+			refunded = true
+			purchase.Status = TicketPurchaseRefunded
+			purchase.RefundedAt = time.Now()
+			vbolt.Write(tx, TicketPurchasesBkt, purchase.Id, &purchase)
+			vbolt.TxCommit(tx)
+		})
+		if refunded {
+			sendTicketRefundEmail(purchase, product)
+		}
+		return nil
+	}
+
+	LogInfo(LogCategorySystem, "Ticket purchased", map[string]interface{}{
+		"purchaseId": purchase.Id,
+		"productId":  purchase.ProductId,
+		"code":       purchase.Code,
+		"buyerEmail": purchase.BuyerEmail,
+	})
+
+	deliverTicketEmail(db, purchase, product)
+	return nil
+}
+
+// handleTicketRefunded revokes the ticket of a payment refunded outside the app
+// (e.g. from the provider dashboard). Refunds issued by RefundTicketPurchase are already handled.
+func handleTicketRefunded(db *vbolt.DB, event PaymentEvent) {
+	var purchase TicketPurchase
+	var product TicketProduct
+	var accessCode AccessCode
+	var sessionTokens []string
+	var roomIds []int
+	var sessionsKilled int
+	var refunded bool
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		var purchaseId int
+		vbolt.Read(tx, TicketPurchaseByPaymentBkt, event.PaymentRef, &purchaseId)
+		purchase = GetTicketPurchase(tx, purchaseId)
+		if purchase.Id == 0 || purchase.Status != TicketPurchasePaid {
+			return
+		}
+
+		refunded = true
+		accessCode, sessionTokens, roomIds, sessionsKilled = refundTicketPurchaseTx(tx, &purchase)
+		product = GetTicketProduct(tx, purchase.ProductId)
+		vbolt.TxCommit(tx)
+	})
+
+	if !refunded {
+		return
+	}
+
+	if accessCode.Code != "" {
+		dropCodeSessionsPresence(db, accessCode.Code, sessionTokens)
+		broadcastCodeRevoked(accessCode, sessionTokens, roomIds)
+	}
+	sendTicketRefundEmail(purchase, product)
+
+	LogInfo(LogCategorySystem, "Ticket refunded by provider", map[string]interface{}{
+		"purchaseId":     purchase.Id,
+		"productId":      purchase.ProductId,
+		"code":           purchase.Code,
+		"sessionsKilled": sessionsKilled,
+	})
+}
+
+// ticketWebhookHandler receives payment events from the provider
+func ticketWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if paymentProvider == nil {
+		http.Error(w, "Payments not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	event, err := paymentProvider.ParseWebhook(r)
+	if err != nil {
+		LogWarnWithRequest(r, LogCategorySystem, "Rejected payment webhook", map[string]interface{}{
+			"provider": paymentProvider.Name(),
+			"error":    err.Error(),
+		})
+		http.Error(w, "Invalid webhook", http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case PaymentEventCheckoutCompleted:
+		if err := handleTicketCheckoutCompleted(appDb, event); err != nil {
+			// A 5xx makes the provider deliver the event again later
+			http.Error(w, "Failed to process payment", http.StatusInternalServerError)
+			return
+		}
+	case PaymentEventRefunded:
+		handleTicketRefunded(appDb, event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"received": true})
+}
+
+// Emails
+
+// deliverTicketEmail emails the buyer their code and records whether it went
+// out, so a failed delivery shows up in the purchase list and can be resent
+func deliverTicketEmail(db *vbolt.DB, purchase TicketPurchase, product TicketProduct) {
+	sendErr := sendTicketEmail(purchase, product)
+	if sendErr != nil {
+		LogErrorSimple(LogCategorySystem, "Failed to email ticket code", map[string]interface{}{
+			"purchaseId": purchase.Id,
+			"error":      sendErr.Error(),
+		})
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		recordTicketEmailTx(tx, purchase.Id, sendErr)
+		vbolt.TxCommit(tx)
+	})
+}
+
+// recordTicketEmailTx stores the outcome of emailing a purchase's code
+func recordTicketEmailTx(tx *vbolt.Tx, purchaseId int, sendErr error) (purchase TicketPurchase) {
+	purchase = GetTicketPurchase(tx, purchaseId)
+	if purchase.Id == 0 {
+		return
+	}
+	if sendErr != nil {
+		purchase.EmailError = sendErr.Error()
+	} else {
+		purchase.EmailedAt = time.Now()
+		purchase.EmailError = ""
+	}
+	vbolt.Write(tx, TicketPurchasesBkt, purchase.Id, &purchase)
+	return
+}
+
+func sendTicketEmail(purchase TicketPurchase, product TicketProduct) error {
+	greeting := "Hi,"
+	if purchase.BuyerName != "" {
+		greeting = fmt.Sprintf("Hi %s,", purchase.BuyerName)
+	}
+
+	body := fmt.Sprintf(`%s
+
+Thanks for your purchase! Your access code for %s is:
+
+    %s
+
+Watch here: %s/watch/%s
+
+The code is valid until %s.
+Please don't share it - it's tied to your ticket.
+`, greeting, product.Name, purchase.Code, cfg.SiteURL, purchase.Code, product.AccessExpiresAt.UTC().Format("Jan 2, 2006 15:04 MST"))
+
+	return sendMail(MailMessage{
+		To:      purchase.BuyerEmail,
+		Subject: fmt.Sprintf("Your ticket for %s", product.Name),
+		Body:    body,
+	})
+}
+
+func sendTicketRefundEmail(purchase TicketPurchase, product TicketProduct) {
+	body := fmt.Sprintf(`Your ticket for %s has been refunded.
+
+Any access code issued for this ticket no longer works.
+`, product.Name)
+
+	sendMail(MailMessage{
+		To:      purchase.BuyerEmail,
+		Subject: fmt.Sprintf("Refund for %s", product.Name),
+		Body:    body,
+	})
+}
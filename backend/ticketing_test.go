@@ -0,0 +1,599 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"stream/cfg"
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func setupTestTicketingDB(t *testing.T) *vbolt.DB {
+	dbPath := t.TempDir() + "/test_ticketing.db"
+	db := vbolt.Open(dbPath)
+	vbolt.InitBuckets(db, &cfg.Info)
+	return db
+}
+
+// setupTestTicketingGlobals swaps in the fake provider, in-memory mailer and test DB
+func setupTestTicketingGlobals(t *testing.T, db *vbolt.DB) (*FakePaymentProvider, *MemoryMailer) {
+	originalDb := appDb
+	originalProvider := paymentProvider
+	originalMailer := appMailer
+	originalKey := jwtKey
+
+	provider := &FakePaymentProvider{}
+	mailer := &MemoryMailer{}
+	appDb = db
+	paymentProvider = provider
+	appMailer = mailer
+	jwtKey = []byte("test-secret-key-for-jwt-testing")
+	globalRateLimiter.Reset()
+
+	t.Cleanup(func() {
+		appDb = originalDb
+		paymentProvider = originalProvider
+		appMailer = originalMailer
+		jwtKey = originalKey
+	})
+	return provider, mailer
+}
+
+// createTestTicketAdmin creates a user with Admin role in a new studio with one room
+func createTestTicketAdmin(t *testing.T, db *vbolt.DB) (User, Studio, Room) {
+	var admin User
+	var studio Studio
+	var room Room
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		admin = User{
+			Id:       vbolt.NextIntId(tx, UsersBkt),
+			Name:     "Ticket Admin",
+			Email:    "admin@test.com",
+			Role:     RoleUser,
+			Creation: time.Now(),
+		}
+		vbolt.Write(tx, UsersBkt, admin.Id, &admin)
+		vbolt.Write(tx, EmailBkt, admin.Email, &admin.Id)
+
+		studio, room = createTestStudioAndRoom(tx)
+
+		membershipId := vbolt.NextIntId(tx, MembershipBkt)
+		membership := StudioMembership{
+			UserId:   admin.Id,
+			StudioId: studio.Id,
+			Role:     StudioRoleAdmin,
+			JoinedAt: time.Now(),
+		}
+		vbolt.Write(tx, MembershipBkt, membershipId, &membership)
+		vbolt.SetTargetSingleTerm(tx, MembershipByUserIdx, membershipId, admin.Id)
+		vbolt.SetTargetSingleTerm(tx, MembershipByStudioIdx, membershipId, studio.Id)
+
+		vbolt.TxCommit(tx)
+	})
+	return admin, studio, room
+}
+
+func createTestTicketProduct(t *testing.T, db *vbolt.DB, adminId int, roomId int, capacity int) TicketProduct {
+	token, err := createTestToken(adminId)
+	if err != nil {
+		t.Fatalf("Failed to create test token: %v", err)
+	}
+
+	var resp CreateTicketProductResponse
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: token}
+		resp, err = CreateTicketProduct(ctx, CreateTicketProductRequest{
+			Type:            int(CodeTypeRoom),
+			TargetId:        roomId,
+			Name:            "Spring Recital",
+			PriceCents:      1500,
+			Capacity:        capacity,
+			SaleStartsAt:    time.Now().Add(-time.Hour),
+			SaleEndsAt:      time.Now().Add(24 * time.Hour),
+			AccessExpiresAt: time.Now().Add(48 * time.Hour),
+			MaxViewers:      1,
+		})
+	})
+	if err != nil {
+		t.Fatalf("CreateTicketProduct failed: %v", err)
+	}
+	return resp.Product
+}
+
+func purchaseTestTicket(t *testing.T, db *vbolt.DB, productId int, email string) error {
+	var err error
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx}
+		_, err = PurchaseTicket(ctx, PurchaseTicketRequest{
+			ProductId:  productId,
+			BuyerEmail: email,
+			BuyerName:  "Parent",
+		})
+	})
+	return err
+}
+
+func postTestPaymentWebhook(t *testing.T, event PaymentEvent) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(event)
+	req := httptest.NewRequest("POST", "/api/tickets/webhook", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	ticketWebhookHandler(w, req)
+	return w
+}
+
+func TestPackTicketPurchase(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		original := TicketPurchase{
+			Id:          1,
+			ProductId:   2,
+			StudioId:    3,
+			BuyerEmail:  "buyer@test.com",
+			BuyerName:   "Buyer",
+			AmountCents: 1500,
+			Currency:    "usd",
+			Provider:    "stripe",
+			SessionId:   "cs_123",
+			PaymentRef:  "pi_123",
+			Status:      TicketPurchasePaid,
+			Code:        "12345",
+			CreatedAt:   time.Now().Truncate(time.Second),
+			PaidAt:      time.Now().Truncate(time.Second),
+			EmailedAt:   time.Now().Truncate(time.Second),
+			EmailError:  "mailbox full",
+		}
+		vbolt.Write(tx, TicketPurchasesBkt, original.Id, &original)
+
+		retrieved := GetTicketPurchase(tx, original.Id)
+		if retrieved.BuyerEmail != original.BuyerEmail || retrieved.PaymentRef != original.PaymentRef {
+			t.Errorf("Purchase mismatch: got %+v", retrieved)
+		}
+		if retrieved.Status != TicketPurchasePaid {
+			t.Errorf("Expected status Paid, got %d", retrieved.Status)
+		}
+		if !retrieved.PaidAt.Equal(original.PaidAt) {
+			t.Errorf("PaidAt mismatch: got %v, want %v", retrieved.PaidAt, original.PaidAt)
+		}
+		if !retrieved.EmailedAt.Equal(original.EmailedAt) || retrieved.EmailError != original.EmailError {
+			t.Errorf("Email status mismatch: got %v %q", retrieved.EmailedAt, retrieved.EmailError)
+		}
+	})
+}
+
+func TestCreateTicketProductValidation(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	token, _ := createTestToken(admin.Id)
+
+	tests := []struct {
+		name    string
+		req     CreateTicketProductRequest
+		wantErr string
+	}{
+		{
+			name:    "ZeroPrice",
+			req:     CreateTicketProductRequest{Type: 0, TargetId: room.Id, Name: "Free", PriceCents: 0},
+			wantErr: "Price must be greater than zero",
+		},
+		{
+			name: "SaleEndsBeforeStart",
+			req: CreateTicketProductRequest{
+				Type: 0, TargetId: room.Id, Name: "Bad window", PriceCents: 100,
+				SaleStartsAt: time.Now(), SaleEndsAt: time.Now().Add(-time.Hour), AccessExpiresAt: time.Now(),
+			},
+			wantErr: "Sale end must be after sale start",
+		},
+		{
+			name:    "MissingRoom",
+			req:     CreateTicketProductRequest{Type: 0, TargetId: 9999, Name: "No room", PriceCents: 100},
+			wantErr: "Room not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+				ctx := &vbeam.Context{Tx: tx, Token: token}
+				_, err = CreateTicketProduct(ctx, tt.req)
+			})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("NonAdminDenied", func(t *testing.T) {
+		var outsider User
+		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+			outsider = User{Id: vbolt.NextIntId(tx, UsersBkt), Name: "Outsider", Email: "out@test.com"}
+			vbolt.Write(tx, UsersBkt, outsider.Id, &outsider)
+			vbolt.TxCommit(tx)
+		})
+		outsiderToken, _ := createTestToken(outsider.Id)
+
+		var err error
+		vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+			ctx := &vbeam.Context{Tx: tx, Token: outsiderToken}
+			_, err = CreateTicketProduct(ctx, CreateTicketProductRequest{
+				Type: 0, TargetId: room.Id, Name: "Recital", PriceCents: 100,
+				SaleStartsAt: time.Now(), SaleEndsAt: time.Now().Add(time.Hour), AccessExpiresAt: time.Now().Add(time.Hour),
+			})
+		})
+		if err == nil || err.Error() != "Only studio admins can manage tickets" {
+			t.Errorf("Expected permission error, got %v", err)
+		}
+	})
+}
+
+func TestTicketPurchaseFlow(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	provider, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	product := createTestTicketProduct(t, db, admin.Id, room.Id, 0)
+
+	if err := purchaseTestTicket(t, db, product.Id, "Buyer@Test.com"); err != nil {
+		t.Fatalf("PurchaseTicket failed: %v", err)
+	}
+	if len(provider.Checkouts) != 1 {
+		t.Fatalf("Expected 1 checkout, got %d", len(provider.Checkouts))
+	}
+	if provider.Checkouts[0].AmountCents != 1500 || provider.Checkouts[0].BuyerEmail != "buyer@test.com" {
+		t.Errorf("Unexpected checkout params: %+v", provider.Checkouts[0])
+	}
+
+	// Payment completes
+	w := postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_1", PaymentRef: "pi_1"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from webhook, got %d", w.Code)
+	}
+
+	var purchase TicketPurchase
+	var accessCode AccessCode
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		purchase = GetTicketPurchase(tx, 1)
+		vbolt.Read(tx, AccessCodesBkt, purchase.Code, &accessCode)
+		product = GetTicketProduct(tx, product.Id)
+	})
+
+	if purchase.Status != TicketPurchasePaid {
+		t.Fatalf("Expected purchase to be paid, got status %d", purchase.Status)
+	}
+	if accessCode.Code == "" || accessCode.TargetId != room.Id || accessCode.MaxViewers != 1 {
+		t.Errorf("Expected minted room code with MaxViewers 1, got %+v", accessCode)
+	}
+	if !accessCode.ExpiresAt.Equal(product.AccessExpiresAt) {
+		t.Errorf("Expected code to expire at %v, got %v", product.AccessExpiresAt, accessCode.ExpiresAt)
+	}
+	if product.SoldCount != 1 {
+		t.Errorf("Expected SoldCount 1, got %d", product.SoldCount)
+	}
+
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != "buyer@test.com" || !strings.Contains(sent[0].Body, accessCode.Code) {
+		t.Fatalf("Expected ticket email containing the code, got %+v", sent)
+	}
+	if purchase.EmailedAt.IsZero() || purchase.EmailError != "" {
+		t.Errorf("Expected the email to be recorded as sent, got %v %q", purchase.EmailedAt, purchase.EmailError)
+	}
+
+	// Duplicate webhook delivery is ignored
+	postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_1", PaymentRef: "pi_1"})
+	if len(mailer.Sent()) != 1 {
+		t.Errorf("Expected duplicate webhook to be ignored, got %d emails", len(mailer.Sent()))
+	}
+
+	// The minted code validates like any other
+	if _, err := validateAccessCodeLogic(db, accessCode.Code); err != nil {
+		t.Errorf("Expected minted code to validate, got %v", err)
+	}
+}
+
+func TestTicketSaleWindowAndCapacity(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	provider, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	product := createTestTicketProduct(t, db, admin.Id, room.Id, 1)
+
+	// Two checkouts are started before either is paid
+	if err := purchaseTestTicket(t, db, product.Id, "first@test.com"); err != nil {
+		t.Fatalf("First purchase failed: %v", err)
+	}
+	if err := purchaseTestTicket(t, db, product.Id, "second@test.com"); err != nil {
+		t.Fatalf("Second purchase failed: %v", err)
+	}
+
+	postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_1", PaymentRef: "pi_1"})
+	postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_2", PaymentRef: "pi_2"})
+
+	var second TicketPurchase
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		second = GetTicketPurchase(tx, 2)
+	})
+	if second.Status != TicketPurchaseRefunded || second.Code != "" {
+		t.Errorf("Expected oversold purchase to be refunded without a code, got %+v", second)
+	}
+	if len(provider.Refunds) != 1 || provider.Refunds[0] != "pi_2" {
+		t.Errorf("Expected refund of pi_2, got %v", provider.Refunds)
+	}
+	if len(mailer.Sent()) != 2 {
+		t.Errorf("Expected ticket email and refund email, got %d", len(mailer.Sent()))
+	}
+
+	// Sold out now
+	err := purchaseTestTicket(t, db, product.Id, "third@test.com")
+	if err == nil || err.Error() != "Tickets are sold out" {
+		t.Errorf("Expected sold out error, got %v", err)
+	}
+
+	// Sale window closed
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		p := GetTicketProduct(tx, product.Id)
+		p.Capacity = 0
+		p.SaleEndsAt = time.Now().Add(-time.Minute)
+		vbolt.Write(tx, TicketProductsBkt, p.Id, &p)
+		vbolt.TxCommit(tx)
+	})
+	err = purchaseTestTicket(t, db, product.Id, "late@test.com")
+	if err == nil || err.Error() != "Ticket sales have ended" {
+		t.Errorf("Expected sale ended error, got %v", err)
+	}
+}
+
+func TestTicketSoldOutRefundRetried(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	provider, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	product := createTestTicketProduct(t, db, admin.Id, room.Id, 1)
+	purchaseTestTicket(t, db, product.Id, "first@test.com")
+	purchaseTestTicket(t, db, product.Id, "second@test.com")
+	postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_1", PaymentRef: "pi_1"})
+
+	// The refund of the oversold ticket fails, so the provider must retry
+	provider.FailRefunds = true
+	w := postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_2", PaymentRef: "pi_2"})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a 5xx so the webhook is retried, got %d", w.Code)
+	}
+	var second TicketPurchase
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		second = GetTicketPurchase(tx, 2)
+	})
+	if second.Status != TicketPurchasePending {
+		t.Errorf("Expected the purchase to stay pending until refunded, got %d", second.Status)
+	}
+	if len(mailer.Sent()) != 1 {
+		t.Errorf("Expected no refund email before the refund, got %d emails", len(mailer.Sent()))
+	}
+
+	// The refund goes through but its response is lost, so the webhook is
+	// retried again
+	provider.FailRefunds = false
+	provider.LoseRefundResponses = true
+	w = postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_2", PaymentRef: "pi_2"})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a 5xx when the refund's outcome is unknown, got %d", w.Code)
+	}
+
+	// The retry completes it without refunding the payment twice
+	provider.LoseRefundResponses = false
+	w = postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_2", PaymentRef: "pi_2"})
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the retry to succeed, got %d", w.Code)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		second = GetTicketPurchase(tx, 2)
+	})
+	if second.Status != TicketPurchaseRefunded || second.Code != "" {
+		t.Errorf("Expected the retry to refund the purchase, got %+v", second)
+	}
+	if len(provider.Refunds) != 1 || provider.Refunds[0] != "pi_2" {
+		t.Errorf("Expected refund of pi_2, got %v", provider.Refunds)
+	}
+	if len(mailer.Sent()) != 2 {
+		t.Errorf("Expected the refund email, got %d emails", len(mailer.Sent()))
+	}
+}
+
+func TestRefundTicketPurchase(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	provider, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	product := createTestTicketProduct(t, db, admin.Id, room.Id, 0)
+
+	purchaseTestTicket(t, db, product.Id, "buyer@test.com")
+	postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_1", PaymentRef: "pi_1"})
+
+	var purchase TicketPurchase
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		purchase = GetTicketPurchase(tx, 1)
+	})
+
+	// A viewer is watching with the ticket's code
+	validateResp, err := validateAccessCodeLogic(db, purchase.Code)
+	if err != nil {
+		t.Fatalf("Code validation failed: %v", err)
+	}
+	IncrementRoomViewerCount(db, room.Id, "code:"+validateResp.SessionToken, purchase.Code)
+
+	token, _ := createTestToken(admin.Id)
+	var resp RefundTicketPurchaseResponse
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: token}
+		resp, err = RefundTicketPurchase(ctx, RefundTicketPurchaseRequest{PurchaseId: purchase.Id})
+	})
+	if err != nil {
+		t.Fatalf("RefundTicketPurchase failed: %v", err)
+	}
+	if resp.SessionsKilled != 1 {
+		t.Errorf("Expected 1 session killed, got %d", resp.SessionsKilled)
+	}
+	if len(provider.Refunds) != 1 || provider.Refunds[0] != "pi_1" {
+		t.Errorf("Expected provider refund of pi_1, got %v", provider.Refunds)
+	}
+
+	var accessCode AccessCode
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		purchase = GetTicketPurchase(tx, purchase.Id)
+		vbolt.Read(tx, AccessCodesBkt, purchase.Code, &accessCode)
+		product = GetTicketProduct(tx, product.Id)
+	})
+	if purchase.Status != TicketPurchaseRefunded {
+		t.Errorf("Expected refunded status, got %d", purchase.Status)
+	}
+	if !accessCode.IsRevoked {
+		t.Errorf("Expected ticket code to be revoked")
+	}
+	if product.SoldCount != 0 {
+		t.Errorf("Expected SoldCount 0 after refund, got %d", product.SoldCount)
+	}
+
+	// The provider's own refund notification arrives afterwards and is ignored
+	emailsBefore := len(mailer.Sent())
+	postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventRefunded, PaymentRef: "pi_1"})
+	if len(mailer.Sent()) != emailsBefore {
+		t.Errorf("Expected refund webhook for an already refunded ticket to be ignored")
+	}
+
+	// Refunding twice fails
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: token}
+		_, err = RefundTicketPurchase(ctx, RefundTicketPurchaseRequest{PurchaseId: purchase.Id})
+	})
+	if err == nil || err.Error() != "Only paid tickets can be refunded" {
+		t.Errorf("Expected error on second refund, got %v", err)
+	}
+}
+
+func TestTicketRefundedByProvider(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	_, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	product := createTestTicketProduct(t, db, admin.Id, room.Id, 0)
+
+	purchaseTestTicket(t, db, product.Id, "buyer@test.com")
+	postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_1", PaymentRef: "pi_1"})
+	postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventRefunded, PaymentRef: "pi_1"})
+
+	var purchase TicketPurchase
+	var accessCode AccessCode
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		purchase = GetTicketPurchase(tx, 1)
+		vbolt.Read(tx, AccessCodesBkt, purchase.Code, &accessCode)
+	})
+	if purchase.Status != TicketPurchaseRefunded || !accessCode.IsRevoked {
+		t.Errorf("Expected provider refund to revoke the ticket, got status %d revoked=%v", purchase.Status, accessCode.IsRevoked)
+	}
+	if len(mailer.Sent()) != 2 {
+		t.Errorf("Expected ticket and refund emails, got %d", len(mailer.Sent()))
+	}
+}
+
+func TestVerifyStripeSignature(t *testing.T) {
+	payload := []byte(`{"type":"checkout.session.completed"}`)
+	secret := "whsec_test"
+	now := time.Unix(1700000000, 0)
+
+	sign := func(ts string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ts + "." + string(payload)))
+		return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	if err := verifyStripeSignature(payload, sign("1700000000"), secret, now); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := verifyStripeSignature(payload, sign("1700000000"), "wrong", now); err == nil {
+		t.Errorf("Expected failure with wrong secret")
+	}
+	if err := verifyStripeSignature(payload, sign("1699999000"), secret, now); err == nil {
+		t.Errorf("Expected failure for old timestamp")
+	}
+	if err := verifyStripeSignature(payload, "", secret, now); err == nil {
+		t.Errorf("Expected failure for missing header")
+	}
+}
+
+func TestResendTicketEmail(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	_, mailer := setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+	product := createTestTicketProduct(t, db, admin.Id, room.Id, 0)
+	buyer := createTestInvitee(t, db, "buyer@test.com", true)
+	stranger := createTestInvitee(t, db, "stranger@test.com", true)
+
+	// The ticket email fails to send; the purchase still completes
+	mailer.FailSends = true
+	purchaseTestTicket(t, db, product.Id, "buyer@test.com")
+	if w := postTestPaymentWebhook(t, PaymentEvent{Type: PaymentEventCheckoutCompleted, SessionId: "fake_cs_1", PaymentRef: "pi_1"}); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from webhook, got %d", w.Code)
+	}
+	var purchase TicketPurchase
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		purchase = GetTicketPurchase(tx, 1)
+	})
+	if purchase.Status != TicketPurchasePaid || !purchase.EmailedAt.IsZero() || purchase.EmailError == "" {
+		t.Fatalf("Expected a paid purchase with a failed email, got %+v", purchase)
+	}
+
+	resend := func(userId int) (resp ResendTicketEmailResponse, err error) {
+		token, _ := createTestToken(userId)
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			resp, err = ResendTicketEmail(&vbeam.Context{Tx: tx, Token: token}, ResendTicketEmailRequest{PurchaseId: purchase.Id})
+		})
+		return
+	}
+
+	// A failed resend is recorded too
+	if _, err := resend(admin.Id); err == nil {
+		t.Errorf("Expected the resend to report the failure")
+	}
+	mailer.FailSends = false
+
+	if _, err := resend(stranger.Id); err == nil {
+		t.Errorf("Expected others to be refused")
+	}
+	resp, err := resend(admin.Id)
+	if err != nil || resp.EmailedAt.IsZero() {
+		t.Fatalf("ResendTicketEmail failed: %v", err)
+	}
+	if _, err := resend(buyer.Id); err != nil {
+		t.Errorf("Expected the buyer to be able to resend: %v", err)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 2 || sent[0].To != "buyer@test.com" || !strings.Contains(sent[0].Body, purchase.Code) {
+		t.Errorf("Expected the code to be emailed twice, got %+v", sent)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		purchase = GetTicketPurchase(tx, purchase.Id)
+	})
+	if purchase.EmailedAt.IsZero() || purchase.EmailError != "" {
+		t.Errorf("Expected the delivery to be recorded, got %v %q", purchase.EmailedAt, purchase.EmailError)
+	}
+}
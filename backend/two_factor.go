@@ -0,0 +1,706 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"stream/cfg"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+// TOTP parameters (RFC 6238 defaults, which every authenticator app supports)
+const (
+	TOTP_PERIOD     = 30
+	TOTP_DIGITS     = 6
+	TOTP_SKEW_STEPS = 1 // accept one step either side for clock drift
+
+	TWO_FACTOR_ISSUER         = "Releve"
+	TWO_FACTOR_RECOVERY_CODES = 10
+	TWO_FACTOR_LOGIN_TTL      = 10 * time.Minute
+)
+
+const twoFactorLoginCookieName = "twoFactorLogin"
+
+// TwoFactor holds a user's TOTP secret and recovery codes. Secrets are kept out of
+// JSON so they never show up in admin tooling.
+type TwoFactor struct {
+	UserId        int       `json:"userId"`
+	Enabled       bool      `json:"enabled"`
+	Secret        string    `json:"-"`        // base32 TOTP secret
+	PendingSecret string    `json:"-"`        // secret shown during setup, until a code confirms it
+	RecoveryCodes []string  `json:"-"`        // SHA-256 hashes of unused recovery codes
+	LastStep      int       `json:"lastStep"` // last accepted time step, so a code can't be replayed
+	EnabledAt     time.Time `json:"enabledAt"`
+}
+
+func PackTwoFactor(self *TwoFactor, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.UserId, buf)
+	vpack.Bool(&self.Enabled, buf)
+	vpack.String(&self.Secret, buf)
+	vpack.String(&self.PendingSecret, buf)
+	vpack.Slice(&self.RecoveryCodes, vpack.String, buf)
+	vpack.Int(&self.LastStep, buf)
+	vpack.Time(&self.EnabledAt, buf)
+}
+
+// user id => TwoFactor
+var TwoFactorBkt = vbolt.Bucket(&cfg.Info, "two_factor", vpack.FInt, PackTwoFactor)
+
+// TwoFactorPolicy lists the roles that must use two-factor authentication.
+// Configured with REQUIRE_2FA_ROLES, e.g. "site_admin,studio_owner".
+type TwoFactorPolicy struct {
+	SiteAdmins   bool
+	StreamAdmins bool
+	StudioOwners bool
+	StudioAdmins bool // also covers owners
+}
+
+var twoFactorPolicy TwoFactorPolicy
+
+// SetupTwoFactorPolicy reads REQUIRE_2FA_ROLES
+func SetupTwoFactorPolicy() error {
+	policy, err := parseTwoFactorPolicy(os.Getenv("REQUIRE_2FA_ROLES"))
+	twoFactorPolicy = policy
+	return err
+}
+
+func parseTwoFactorPolicy(value string) (policy TwoFactorPolicy, err error) {
+	for _, role := range strings.Split(value, ",") {
+		switch strings.TrimSpace(strings.ToLower(role)) {
+		case "":
+		case "site_admin":
+			policy.SiteAdmins = true
+		case "stream_admin":
+			policy.StreamAdmins = true
+		case "studio_owner":
+			policy.StudioOwners = true
+		case "studio_admin":
+			policy.StudioAdmins = true
+		default:
+			err = fmt.Errorf("unknown role %q in REQUIRE_2FA_ROLES", role)
+		}
+	}
+	return
+}
+
+// twoFactorRequiredTx reports whether the policy forces this user to use 2FA
+func twoFactorRequiredTx(tx *vbolt.Tx, user User) bool {
+	if user.Role == RoleSiteAdmin && twoFactorPolicy.SiteAdmins {
+		return true
+	}
+	if user.Role == RoleStreamAdmin && twoFactorPolicy.StreamAdmins {
+		return true
+	}
+	if !twoFactorPolicy.StudioOwners && !twoFactorPolicy.StudioAdmins {
+		return false
+	}
+
+	var membershipIds []int
+	vbolt.ReadTermTargets(tx, MembershipByUserIdx, user.Id, &membershipIds, vbolt.Window{})
+	for _, membershipId := range membershipIds {
+		membership := GetMembership(tx, membershipId)
+		if membership.Role == StudioRoleOwner && twoFactorPolicy.StudioOwners {
+			return true
+		}
+		if membership.Role >= StudioRoleAdmin && twoFactorPolicy.StudioAdmins {
+			return true
+		}
+	}
+	return false
+}
+
+func getTwoFactor(tx *vbolt.Tx, userId int) (record TwoFactor) {
+	vbolt.Read(tx, TwoFactorBkt, userId, &record)
+	return
+}
+
+func generateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// totpCode computes the RFC 6238 code for a time step
+func totpCode(secret string, step int64) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTP_DIGITS, value%1000000), nil
+}
+
+// matchTOTP returns the time step the code belongs to, or 0 if it doesn't match
+func matchTOTP(secret string, code string, now time.Time) int {
+	current := now.Unix() / TOTP_PERIOD
+	for i := -TOTP_SKEW_STEPS; i <= TOTP_SKEW_STEPS; i++ {
+		step := current + int64(i)
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return int(step)
+		}
+	}
+	return 0
+}
+
+// totpURI is the otpauth:// link authenticator apps import (usually via QR code)
+func totpURI(user User, secret string) string {
+	label := url.PathEscape(TWO_FACTOR_ISSUER + ":" + user.Email)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", TWO_FACTOR_ISSUER)
+	query.Set("digits", fmt.Sprint(TOTP_DIGITS))
+	query.Set("period", fmt.Sprint(TOTP_PERIOD))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func normalizeTwoFactorCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ReplaceAll(code, "-", "")
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeTwoFactorCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes returns display codes (xxxxx-xxxxx) and their hashes
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < TWO_FACTOR_RECOVERY_CODES; i++ {
+		token, tokenErr := generateToken(5)
+		if tokenErr != nil {
+			return nil, nil, tokenErr
+		}
+		code := token[:5] + "-" + token[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return
+}
+
+// useTwoFactorCode checks a TOTP code (or, if allowed, a recovery code) against
+// an enabled record and marks it used so it can't be replayed. The caller saves
+// the record.
+func useTwoFactorCode(record *TwoFactor, code string, allowRecovery bool) bool {
+	code = normalizeTwoFactorCode(code)
+	if code == "" {
+		return false
+	}
+
+	if len(code) == TOTP_DIGITS {
+		step := matchTOTP(record.Secret, code, time.Now())
+		if step == 0 || step <= record.LastStep {
+			return false
+		}
+		record.LastStep = step
+		return true
+	}
+
+	if !allowRecovery {
+		return false
+	}
+	hash := hashRecoveryCode(code)
+	for i, stored := range record.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			record.RecoveryCodes = append(record.RecoveryCodes[:i], record.RecoveryCodes[i+1:]...)
+			LogInfo(LogCategoryAuth, "Recovery code used", map[string]interface{}{
+				"userId":         record.UserId,
+				"codesRemaining": len(record.RecoveryCodes),
+			})
+			return true
+		}
+	}
+	return false
+}
+
+// startTwoFactorSetup puts a fresh pending secret on the record. The caller saves it.
+func startTwoFactorSetup(user User, record *TwoFactor) (resp BeginTwoFactorSetupResponse, err error) {
+	if record.Enabled {
+		return resp, errors.New("Two-factor authentication is already enabled")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return resp, errors.New("Failed to generate secret")
+	}
+	record.UserId = user.Id
+	record.PendingSecret = secret
+
+	resp.Secret = secret
+	resp.OtpauthUrl = totpURI(user, secret)
+	return
+}
+
+// finishTwoFactorSetup enables 2FA once the user proves their app has the
+// pending secret, returning the recovery codes to show once. The caller saves
+// the record.
+func finishTwoFactorSetup(record *TwoFactor, code string) (codes []string, err error) {
+	if record.Enabled {
+		return nil, errors.New("Two-factor authentication is already enabled")
+	}
+	if record.PendingSecret == "" {
+		return nil, errors.New("Start two-factor setup first")
+	}
+
+	step := matchTOTP(record.PendingSecret, normalizeTwoFactorCode(code), time.Now())
+	if step == 0 {
+		return nil, errors.New("Invalid code")
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, errors.New("Failed to generate recovery codes")
+	}
+
+	record.Enabled = true
+	record.Secret = record.PendingSecret
+	record.PendingSecret = ""
+	record.RecoveryCodes = hashes
+	record.LastStep = step
+	record.EnabledAt = time.Now()
+	return codes, nil
+}
+
+// Pending login
+//
+// After the password (or Google/OIDC) step, users with 2FA get a short-lived
+// signed cookie instead of a session. /api/login/2fa exchanges it plus a code
+// for the normal auth cookies.
+
+type twoFactorLoginClaims struct {
+	UserId int `json:"userId"`
+	jwt.RegisteredClaims
+}
+
+// twoFactorLoginKey derives a separate key from jwtKey so a pending login can
+// never be accepted as an auth token
+func twoFactorLoginKey() []byte {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte("two-factor-login"))
+	return mac.Sum(nil)
+}
+
+func setTwoFactorLoginCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorLoginCookieName,
+		Value:    value,
+		Path:     "/api/login/2fa",
+		HttpOnly: true,
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(cfg.SiteURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// beginTwoFactorLogin checks whether the user needs a second step. If so it sets
+// the pending-login cookie and returns true; the caller must not issue a session.
+func beginTwoFactorLogin(w http.ResponseWriter, user User) (pending bool, setupRequired bool, err error) {
+	var record TwoFactor
+	var required bool
+	vbolt.WithReadTx(appDb, func(tx *vbolt.Tx) {
+		record = getTwoFactor(tx, user.Id)
+		required = twoFactorRequiredTx(tx, user)
+	})
+	if !record.Enabled && !required {
+		return false, false, nil
+	}
+
+	claims := twoFactorLoginClaims{
+		UserId: user.Id,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TWO_FACTOR_LOGIN_TTL)),
+		},
+	}
+	value, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(twoFactorLoginKey())
+	if err != nil {
+		return false, false, err
+	}
+	setTwoFactorLoginCookie(w, value, int(TWO_FACTOR_LOGIN_TTL.Seconds()))
+	return true, !record.Enabled, nil
+}
+
+// pendingTwoFactorUser returns the user from the pending-login cookie
+func pendingTwoFactorUser(r *http.Request) (user User, err error) {
+	cookie, err := r.Cookie(twoFactorLoginCookieName)
+	if err != nil || cookie.Value == "" {
+		return user, errors.New("Sign-in expired, please sign in again")
+	}
+	var claims twoFactorLoginClaims
+	token, err := jwt.ParseWithClaims(cookie.Value, &claims, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return twoFactorLoginKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return user, errors.New("Sign-in expired, please sign in again")
+	}
+
+	vbolt.WithReadTx(appDb, func(tx *vbolt.Tx) {
+		user = GetUser(tx, claims.UserId)
+	})
+	if user.Id == 0 {
+		return user, errors.New("Sign-in expired, please sign in again")
+	}
+	return user, nil
+}
+
+// finishExternalLogin completes a Google or OIDC callback: users with 2FA go on
+// to the second step, everyone else is signed in and sent to the dashboard
+func finishExternalLogin(w http.ResponseWriter, r *http.Request, userId int) {
+	var user User
+	vbolt.WithReadTx(appDb, func(tx *vbolt.Tx) {
+		user = GetUser(tx, userId)
+	})
+	if user.Id == 0 {
+		http.Error(w, "Authentication failed: user not found", http.StatusInternalServerError)
+		return
+	}
+
+	pending, _, err := beginTwoFactorLogin(w, user)
+	if err != nil {
+		http.Error(w, "Authentication failed", http.StatusInternalServerError)
+		return
+	}
+	if pending {
+		http.Redirect(w, r, "/two-factor", http.StatusFound)
+		return
+	}
+
+	if err = authenticateForUser(user.Id, w, r); err != nil {
+		http.Error(w, fmt.Sprintf("Authentication failed: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/dashboard", http.StatusFound)
+}
+
+type TwoFactorLoginRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorLoginStatus struct {
+	Email         string `json:"email"`
+	SetupRequired bool   `json:"setupRequired"`
+}
+
+// twoFactorLoginHandler is the second login step (/api/login/2fa).
+// GET reports what the pending login needs; POST checks a code and issues the
+// session. Users who must enrol confirm their new secret here instead.
+func twoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := pendingTwoFactorUser(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "GET" {
+		var record TwoFactor
+		vbolt.WithReadTx(appDb, func(tx *vbolt.Tx) {
+			record = getTwoFactor(tx, user.Id)
+		})
+		json.NewEncoder(w).Encode(TwoFactorLoginStatus{Email: user.Email, SetupRequired: !record.Enabled})
+		return
+	}
+	if r.Method != "POST" {
+		vbeam.RespondError(w, errors.New("2FA login call must be POST"))
+		return
+	}
+
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckTwoFactorAttempt(user.Id); rateLimitErr != nil {
+		http.Error(w, rateLimitErr.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	var recoveryCodes []string
+	var ok bool
+	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+		record := getTwoFactor(tx, user.Id)
+		if record.Enabled {
+			ok = useTwoFactorCode(&record, req.Code, true)
+		} else {
+			// Required by policy but not set up yet: this confirms the new secret
+			recoveryCodes, err = finishTwoFactorSetup(&record, req.Code)
+			ok = err == nil
+		}
+		if ok {
+			vbolt.Write(tx, TwoFactorBkt, user.Id, &record)
+			vbolt.TxCommit(tx)
+		}
+	})
+	if !ok {
+		LogWarnWithRequest(r, LogCategoryAuth, "Invalid two-factor code", map[string]interface{}{
+			"userId": user.Id,
+		})
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	setTwoFactorLoginCookie(w, "", -1)
+
+	migratedSession, _ := migrateAnonymousCodeSession(r, user.Id)
+	if migratedSession != "" {
+		LogInfoWithRequest(r, LogCategoryAuth, "Migrated code session during login", map[string]interface{}{
+			"userId":       user.Id,
+			"sessionToken": migratedSession,
+		})
+	}
+
+	token, err := generateAuthJwt(user, w, r)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	LogInfoWithRequest(r, LogCategoryAuth, "User login successful", map[string]interface{}{
+		"userId":    user.Id,
+		"email":     user.Email,
+		"twoFactor": true,
+	})
+
+	var resp AuthResponse
+	vbolt.WithReadTx(appDb, func(tx *vbolt.Tx) {
+		resp = GetAuthResponseFromUser(tx, user)
+	})
+	json.NewEncoder(w).Encode(LoginResponse{Token: token, Auth: resp, RecoveryCodes: recoveryCodes})
+}
+
+// twoFactorLoginSetupHandler (/api/login/2fa/setup) issues a secret to users the
+// policy requires to enrol before they can finish signing in
+func twoFactorLoginSetupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		vbeam.RespondError(w, errors.New("2FA setup call must be POST"))
+		return
+	}
+	user, err := pendingTwoFactorUser(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var resp BeginTwoFactorSetupResponse
+	vbolt.WithWriteTx(appDb, func(tx *vbolt.Tx) {
+		record := getTwoFactor(tx, user.Id)
+		resp, err = startTwoFactorSetup(user, &record)
+		if err == nil {
+			vbolt.Write(tx, TwoFactorBkt, user.Id, &record)
+			vbolt.TxCommit(tx)
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func RegisterTwoFactorMethods(app *vbeam.Application) {
+	vbeam.RegisterProc(app, GetTwoFactorStatus)
+	vbeam.RegisterProc(app, BeginTwoFactorSetup)
+	vbeam.RegisterProc(app, ConfirmTwoFactorSetup)
+	vbeam.RegisterProc(app, DisableTwoFactor)
+	vbeam.RegisterProc(app, RegenerateRecoveryCodes)
+}
+
+// Request/Response types
+type TwoFactorStatusResponse struct {
+	Enabled           bool      `json:"enabled"`
+	Required          bool      `json:"required"` // the user's role requires 2FA
+	RecoveryCodesLeft int       `json:"recoveryCodesLeft"`
+	EnabledAt         time.Time `json:"enabledAt"`
+}
+
+type BeginTwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthUrl string `json:"otpauthUrl"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type DisableTwoFactorResponse struct {
+	Success bool `json:"success"`
+}
+
+// GetTwoFactorStatus returns the caller's 2FA state
+func GetTwoFactorStatus(ctx *vbeam.Context, req Empty) (resp TwoFactorStatusResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	record := getTwoFactor(ctx.Tx, user.Id)
+	resp.Enabled = record.Enabled
+	resp.Required = twoFactorRequiredTx(ctx.Tx, user)
+	resp.RecoveryCodesLeft = len(record.RecoveryCodes)
+	resp.EnabledAt = record.EnabledAt
+	return
+}
+
+// BeginTwoFactorSetup generates a secret for the caller's authenticator app.
+// 2FA isn't enabled until ConfirmTwoFactorSetup checks a code from it.
+func BeginTwoFactorSetup(ctx *vbeam.Context, req Empty) (resp BeginTwoFactorSetupResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	record := getTwoFactor(ctx.Tx, user.Id)
+	resp, err = startTwoFactorSetup(user, &record)
+	if err != nil {
+		return
+	}
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.Write(ctx.Tx, TwoFactorBkt, user.Id, &record)
+	vbolt.TxCommit(ctx.Tx)
+	return
+}
+
+// ConfirmTwoFactorSetup enables 2FA and returns recovery codes, shown only once
+func ConfirmTwoFactorSetup(ctx *vbeam.Context, req TwoFactorCodeRequest) (resp RecoveryCodesResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckTwoFactorAttempt(user.Id); rateLimitErr != nil {
+		return resp, rateLimitErr
+	}
+
+	record := getTwoFactor(ctx.Tx, user.Id)
+	resp.RecoveryCodes, err = finishTwoFactorSetup(&record, req.Code)
+	if err != nil {
+		return
+	}
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.Write(ctx.Tx, TwoFactorBkt, user.Id, &record)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategoryAuth, "Two-factor authentication enabled", map[string]interface{}{
+		"userId": user.Id,
+	})
+	return
+}
+
+// DisableTwoFactor turns 2FA off after checking a current code or recovery code.
+// Users whose role requires 2FA can't disable it.
+func DisableTwoFactor(ctx *vbeam.Context, req TwoFactorCodeRequest) (resp DisableTwoFactorResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	if twoFactorRequiredTx(ctx.Tx, user) {
+		return resp, errors.New("Two-factor authentication is required for your role")
+	}
+
+	record := getTwoFactor(ctx.Tx, user.Id)
+	if !record.Enabled {
+		return resp, errors.New("Two-factor authentication is not enabled")
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckTwoFactorAttempt(user.Id); rateLimitErr != nil {
+		return resp, rateLimitErr
+	}
+
+	if !useTwoFactorCode(&record, req.Code, true) {
+		return resp, errors.New("Invalid code")
+	}
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.Delete(ctx.Tx, TwoFactorBkt, user.Id)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategoryAuth, "Two-factor authentication disabled", map[string]interface{}{
+		"userId": user.Id,
+	})
+
+	resp.Success = true
+	return
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a current TOTP code
+func RegenerateRecoveryCodes(ctx *vbeam.Context, req TwoFactorCodeRequest) (resp RecoveryCodesResponse, err error) {
+	user, authErr := GetAuthUser(ctx)
+	if authErr != nil || user.Id <= 0 {
+		return resp, errors.New("Authentication required")
+	}
+
+	record := getTwoFactor(ctx.Tx, user.Id)
+	if !record.Enabled {
+		return resp, errors.New("Two-factor authentication is not enabled")
+	}
+
+	if rateLimitErr := globalRateLimiter.CheckTwoFactorAttempt(user.Id); rateLimitErr != nil {
+		return resp, rateLimitErr
+	}
+
+	if !useTwoFactorCode(&record, req.Code, false) {
+		return resp, errors.New("Invalid code")
+	}
+
+	codes, hashes, genErr := generateRecoveryCodes()
+	if genErr != nil {
+		return resp, errors.New("Failed to generate recovery codes")
+	}
+	record.RecoveryCodes = hashes
+
+	vbeam.UseWriteTx(ctx)
+	vbolt.Write(ctx.Tx, TwoFactorBkt, user.Id, &record)
+	vbolt.TxCommit(ctx.Tx)
+
+	LogInfo(LogCategoryAuth, "Recovery codes regenerated", map[string]interface{}{
+		"userId": user.Id,
+	})
+
+	resp.RecoveryCodes = codes
+	return
+}
@@ -0,0 +1,221 @@
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setTestPassword(t *testing.T, db *vbolt.DB, userId int, password string) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		vbolt.Write(tx, PasswdBkt, userId, &hash)
+		vbolt.TxCommit(tx)
+	})
+}
+
+func postTestLogin(email string, password string) *httptest.ResponseRecorder {
+	body := `{"email":"` + email + `","password":"` + password + `"}`
+	w := httptest.NewRecorder()
+	loginHandler(w, httptest.NewRequest("POST", "/api/login", strings.NewReader(body)))
+	return w
+}
+
+func postTestTwoFactorCode(pending *http.Cookie, code string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/login/2fa", strings.NewReader(`{"code":"`+code+`"}`))
+	req.AddCookie(pending)
+	w := httptest.NewRecorder()
+	twoFactorLoginHandler(w, req)
+	return w
+}
+
+// currentTestTOTP returns the code for the current step plus offset
+func currentTestTOTP(t *testing.T, secret string, offset int64) string {
+	code, err := totpCode(secret, time.Now().Unix()/TOTP_PERIOD+offset)
+	if err != nil {
+		t.Fatalf("totpCode failed: %v", err)
+	}
+	return code
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vector (SHA-1, T=59), truncated to 6 digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // base32("12345678901234567890")
+	code, err := totpCode(secret, 59/TOTP_PERIOD)
+	if err != nil || code != "287082" {
+		t.Errorf("Expected 287082, got %s (%v)", code, err)
+	}
+
+	now := time.Unix(59, 0)
+	if step := matchTOTP(secret, "287082", now.Add(TOTP_PERIOD*time.Second)); step != 1 {
+		t.Errorf("Expected previous step to be accepted for clock drift, got %d", step)
+	}
+	if step := matchTOTP(secret, "287082", now.Add(3*TOTP_PERIOD*time.Second)); step != 0 {
+		t.Errorf("Expected old code to be rejected, got step %d", step)
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	admin, _, _ := createTestTicketAdmin(t, db)
+	setTestPassword(t, db, admin.Id, "password123")
+	token, _ := createTestToken(admin.Id)
+
+	// Enrol through the account procs
+	var setup BeginTwoFactorSetupResponse
+	var codes RecoveryCodesResponse
+	var err error
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		setup, err = BeginTwoFactorSetup(&vbeam.Context{Tx: tx, Token: token}, Empty{})
+	})
+	if err != nil || !strings.Contains(setup.OtpauthUrl, "secret="+setup.Secret) {
+		t.Fatalf("BeginTwoFactorSetup failed: %v %+v", err, setup)
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		codes, err = ConfirmTwoFactorSetup(&vbeam.Context{Tx: tx, Token: token}, TwoFactorCodeRequest{Code: currentTestTOTP(t, setup.Secret, 0)})
+	})
+	if err != nil || len(codes.RecoveryCodes) != TWO_FACTOR_RECOVERY_CODES {
+		t.Fatalf("ConfirmTwoFactorSetup failed: %v %+v", err, codes)
+	}
+
+	// Password alone no longer issues a session
+	w := postTestLogin(admin.Email, "password123")
+	var loginResp LoginResponse
+	json.NewDecoder(w.Body).Decode(&loginResp)
+	if w.Code != http.StatusOK || !loginResp.TwoFactorRequired || loginResp.TwoFactorSetupRequired || loginResp.Token != "" {
+		t.Fatalf("Expected second step to be required, got %d %+v", w.Code, loginResp)
+	}
+	if findTestCookie(w, "authToken") != nil {
+		t.Errorf("Expected no auth cookie before the second step")
+	}
+	pending := findTestCookie(w, twoFactorLoginCookieName)
+	if pending == nil {
+		t.Fatalf("Expected pending login cookie")
+	}
+
+	// The pending cookie isn't an auth token
+	if _, err := GetAuthUser(&vbeam.Context{Token: pending.Value}); err == nil {
+		t.Errorf("Pending login cookie must not authenticate")
+	}
+
+	// Wrong code, then the code already used during setup (replay)
+	if w := postTestTwoFactorCode(pending, "000000"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected wrong code to be rejected, got %d", w.Code)
+	}
+	if w := postTestTwoFactorCode(pending, currentTestTOTP(t, setup.Secret, 0)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected replayed code to be rejected, got %d", w.Code)
+	}
+
+	w = postTestTwoFactorCode(pending, currentTestTOTP(t, setup.Secret, 1))
+	if w.Code != http.StatusOK || findTestCookie(w, "authToken") == nil || findTestCookie(w, "refreshToken") == nil {
+		t.Fatalf("Expected session after valid code, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Recovery codes work once
+	globalRateLimiter.Reset()
+	if w := postTestTwoFactorCode(pending, strings.ToUpper(codes.RecoveryCodes[0])); w.Code != http.StatusOK {
+		t.Errorf("Expected recovery code to be accepted, got %d %s", w.Code, w.Body.String())
+	}
+	if w := postTestTwoFactorCode(pending, codes.RecoveryCodes[0]); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected used recovery code to be rejected, got %d", w.Code)
+	}
+
+	var status TwoFactorStatusResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		status, _ = GetTwoFactorStatus(&vbeam.Context{Tx: tx, Token: token}, Empty{})
+	})
+	if !status.Enabled || status.RecoveryCodesLeft != TWO_FACTOR_RECOVERY_CODES-1 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	// Disabling needs a valid code
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = DisableTwoFactor(&vbeam.Context{Tx: tx, Token: token}, TwoFactorCodeRequest{Code: "123456"})
+	})
+	if err == nil {
+		t.Errorf("Expected disable with wrong code to fail")
+	}
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = DisableTwoFactor(&vbeam.Context{Tx: tx, Token: token}, TwoFactorCodeRequest{Code: codes.RecoveryCodes[1]})
+	})
+	if err != nil {
+		t.Fatalf("DisableTwoFactor failed: %v", err)
+	}
+	if w := postTestLogin(admin.Email, "password123"); findTestCookie(w, "authToken") == nil {
+		t.Errorf("Expected password login to issue a session once 2FA is disabled")
+	}
+}
+
+func TestTwoFactorRequiredByPolicy(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	original := twoFactorPolicy
+	t.Cleanup(func() { twoFactorPolicy = original })
+	policy, err := parseTwoFactorPolicy("studio_admin, site_admin")
+	if err != nil || !policy.StudioAdmins || !policy.SiteAdmins || policy.StudioOwners {
+		t.Fatalf("Unexpected policy: %v %+v", err, policy)
+	}
+	if _, err := parseTwoFactorPolicy("studio_janitor"); err == nil {
+		t.Errorf("Expected unknown role to be rejected")
+	}
+	twoFactorPolicy = policy
+
+	admin, _, _ := createTestTicketAdmin(t, db)
+	setTestPassword(t, db, admin.Id, "password123")
+	viewer := createTestInvitee(t, db, "viewer@test.com", true)
+	setTestPassword(t, db, viewer.Id, "password123")
+
+	// Roles outside the policy sign in as before
+	if w := postTestLogin(viewer.Email, "password123"); findTestCookie(w, "authToken") == nil {
+		t.Errorf("Expected viewer to sign in without 2FA")
+	}
+
+	// Studio admin without 2FA has to enrol before getting a session
+	w := postTestLogin(admin.Email, "password123")
+	var loginResp LoginResponse
+	json.NewDecoder(w.Body).Decode(&loginResp)
+	if !loginResp.TwoFactorRequired || !loginResp.TwoFactorSetupRequired || findTestCookie(w, "authToken") != nil {
+		t.Fatalf("Expected enrolment to be required, got %+v", loginResp)
+	}
+	pending := findTestCookie(w, twoFactorLoginCookieName)
+
+	setupReq := httptest.NewRequest("POST", "/api/login/2fa/setup", nil)
+	setupReq.AddCookie(pending)
+	setupW := httptest.NewRecorder()
+	twoFactorLoginSetupHandler(setupW, setupReq)
+	var setup BeginTwoFactorSetupResponse
+	json.NewDecoder(setupW.Body).Decode(&setup)
+	if setupW.Code != http.StatusOK || setup.Secret == "" {
+		t.Fatalf("Expected setup secret, got %d %s", setupW.Code, setupW.Body.String())
+	}
+
+	w = postTestTwoFactorCode(pending, currentTestTOTP(t, setup.Secret, 0))
+	json.NewDecoder(w.Body).Decode(&loginResp)
+	if w.Code != http.StatusOK || findTestCookie(w, "authToken") == nil || len(loginResp.RecoveryCodes) != TWO_FACTOR_RECOVERY_CODES {
+		t.Fatalf("Expected enrolment to finish the login, got %d %+v", w.Code, loginResp)
+	}
+
+	// Required 2FA can't be turned off
+	token, _ := createTestToken(admin.Id)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		_, err = DisableTwoFactor(&vbeam.Context{Tx: tx, Token: token}, TwoFactorCodeRequest{Code: loginResp.RecoveryCodes[0]})
+	})
+	if err == nil || !strings.Contains(err.Error(), "required") {
+		t.Errorf("Expected disable to be refused, got %v", err)
+	}
+}
@@ -24,6 +24,7 @@ const (
 func RegisterUserMethods(app *vbeam.Application) {
 	vbeam.RegisterProc(app, CreateAccount)
 	vbeam.RegisterProc(app, GetAuthContext)
+	vbeam.RegisterProc(app, ListLoginProviders)
 	vbeam.RegisterProc(app, ListAllUsers)
 	vbeam.RegisterProc(app, UpdateUserRole)
 }
@@ -34,6 +35,7 @@ type CreateAccountRequest struct {
 	Email           string `json:"email"`
 	Password        string `json:"password"`
 	ConfirmPassword string `json:"confirmPassword"`
+	InviteToken     string `json:"inviteToken"` // Optional: token from a studio invitation link
 }
 
 type LoginRequest struct {
@@ -49,6 +51,11 @@ type CreateAccountResponse struct {
 type LoginResponse struct {
 	Token string       `json:"token,omitempty"`
 	Auth  AuthResponse `json:"auth,omitempty"`
+
+	// Set instead of Token when the password was accepted but a second factor is needed
+	TwoFactorRequired      bool     `json:"twoFactorRequired,omitempty"`
+	TwoFactorSetupRequired bool     `json:"twoFactorSetupRequired,omitempty"`
+	RecoveryCodes          []string `json:"recoveryCodes,omitempty"` // issued when setup completes during login
 }
 
 type AuthResponse struct {
@@ -59,6 +66,7 @@ type AuthResponse struct {
 	IsStreamAdmin    bool     `json:"isStreamAdmin"`    // Quick check for stream admin or higher
 	IsSiteAdmin      bool     `json:"isSiteAdmin"`      // Quick check for site admin
 	CanManageStudios bool     `json:"canManageStudios"` // Has Member+ role in any studio
+	EmailVerified    bool     `json:"emailVerified"`
 }
 
 // Database types
@@ -69,17 +77,22 @@ type User struct {
 	Role      UserRole  `json:"role"`
 	Creation  time.Time `json:"creation"`
 	LastLogin time.Time `json:"lastLogin"`
+
+	EmailVerified bool `json:"emailVerified"`
 }
 
 // Packing functions for vbolt serialization
 func PackUser(self *User, buf *vpack.Buffer) {
-	vpack.Version(1, buf)
+	version := vpack.Version(2, buf)
 	vpack.Int(&self.Id, buf)
 	vpack.String(&self.Name, buf)
 	vpack.String(&self.Email, buf)
 	vpack.Int((*int)(&self.Role), buf)
 	vpack.Time(&self.Creation, buf)
 	vpack.Time(&self.LastLogin, buf)
+	if version >= 2 {
+		vpack.Bool(&self.EmailVerified, buf)
+	}
 }
 
 // Buckets for vbolt database storage
@@ -148,6 +161,7 @@ func GetAuthResponseFromUser(tx *vbolt.Tx, user User) AuthResponse {
 		IsStreamAdmin:    user.Role >= RoleStreamAdmin, // Stream admin or site admin
 		IsSiteAdmin:      user.Role == RoleSiteAdmin,   // Site admin only
 		CanManageStudios: canManageStudios,
+		EmailVerified:    user.EmailVerified,
 	}
 }
 
@@ -177,9 +191,7 @@ func CreateAccount(ctx *vbeam.Context, req CreateAccountRequest) (resp CreateAcc
 	// Check if request has anonymous code session (userId=-1) and migrate it
 	if len(ctx.Token) > 0 {
 		// Parse JWT to check for anonymous session
-		token, parseErr := jwt.ParseWithClaims(ctx.Token, &Claims{}, func(token *jwt.Token) (any, error) {
-			return jwtKey, nil
-		})
+		token, parseErr := jwt.ParseWithClaims(ctx.Token, &Claims{}, jwtKeyFunc)
 
 		if parseErr == nil && token.Valid {
 			if claims, ok := token.Claims.(*Claims); ok && claims.UserId == -1 && claims.SessionToken != "" {
@@ -194,6 +206,17 @@ func CreateAccount(ctx *vbeam.Context, req CreateAccountRequest) (resp CreateAcc
 		}
 	}
 
+	// Signing up from an invitation link proves the address, so pending
+	// studio invitations can be accepted right away
+	var verifyToken string
+	var verifyErr error
+	if verifyEmailByInviteTokenTx(ctx.Tx, &user, req.InviteToken) {
+		acceptPendingInvitationsTx(ctx.Tx, user)
+	} else {
+		// Ask the new user to confirm their address
+		verifyToken, verifyErr = createAccountTokenTx(ctx.Tx, user, AccountTokenVerifyEmail, VERIFY_EMAIL_TOKEN_TTL)
+	}
+
 	resp.Auth = GetAuthResponseFromUser(ctx.Tx, user)
 	vbolt.TxCommit(ctx.Tx)
 
@@ -203,6 +226,10 @@ func CreateAccount(ctx *vbeam.Context, req CreateAccountRequest) (resp CreateAcc
 		"email":  req.Email,
 	})
 
+	if verifyToken != "" && verifyErr == nil {
+		sendVerificationEmail(user, verifyToken)
+	}
+
 	return
 }
 
@@ -0,0 +1,655 @@
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"stream/cfg"
+	"sync"
+	"time"
+
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+// Viewer presence is the source of truth for CurrentViewers. Each app
+// instance keeps an entry per viewer and room it's serving and refreshes it
+// on a heartbeat; entries that stop being refreshed (the instance crashed or
+// lost its connections) expire after PRESENCE_TTL. Counts are recomputed from
+// the live entries, so they repair themselves instead of drifting. Entries
+// live in bolt, or in Redis when instances share it (see SetupEventBus).
+const (
+	PRESENCE_HEARTBEAT_INTERVAL = 15 * time.Second
+	PRESENCE_TTL                = 45 * time.Second // Three missed heartbeats
+)
+
+// ViewerPresence is one instance's open connections for a viewer in a room
+type ViewerPresence struct {
+	Key         string    `json:"key"` // instanceId|viewerId|roomId
+	InstanceId  string    `json:"instanceId"`
+	ViewerId    string    `json:"viewerId"` // "user:<id>" or "code:<sessionToken>"
+	RoomId      int       `json:"roomId"`
+	StudioId    int       `json:"studioId"`
+	Code        string    `json:"code"`        // Access code for code sessions
+	Connections int       `json:"connections"` // Open SSE connections (tabs)
+	HeartbeatAt time.Time `json:"heartbeatAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+func PackViewerPresence(self *ViewerPresence, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.String(&self.Key, buf)
+	vpack.String(&self.InstanceId, buf)
+	vpack.String(&self.ViewerId, buf)
+	vpack.Int(&self.RoomId, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.String(&self.Code, buf)
+	vpack.Int(&self.Connections, buf)
+	vpack.Time(&self.HeartbeatAt, buf)
+	vpack.Time(&self.ExpiresAt, buf)
+}
+
+// ViewerPresenceBkt: presence key (string) -> ViewerPresence
+var ViewerPresenceBkt = vbolt.Bucket(&cfg.Info, "viewer_presence", vpack.StringZ, PackViewerPresence)
+
+// PresenceByRoomIdx: Term=roomId, Target=presence key
+var PresenceByRoomIdx = vbolt.Index(&cfg.Info, "presence_by_room", vpack.FInt, vpack.StringZ)
+
+// PresenceByCodeIdx: Term=code, Target=presence key
+var PresenceByCodeIdx = vbolt.Index(&cfg.Info, "presence_by_code", vpack.StringZ, vpack.StringZ)
+
+// presenceInstanceId tells this process's entries apart from other instances'
+// and from those left behind by an earlier run
+var presenceInstanceId = newPresenceInstanceId()
+
+func newPresenceInstanceId() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+func presenceKey(instanceId string, viewerId string, roomId int) string {
+	return fmt.Sprintf("%s|%s|%d", instanceId, viewerId, roomId)
+}
+
+// PresenceStore holds the presence entries of every instance. It's only used
+// outside bolt transactions: the bolt store opens its own, and a shared store
+// must not hold up the writer lock with network round trips.
+type PresenceStore interface {
+	Read(db *vbolt.DB, key string) ViewerPresence
+	Write(db *vbolt.DB, entries []ViewerPresence)
+	Delete(db *vbolt.DB, entries []ViewerPresence)
+	RoomsPresence(db *vbolt.DB, roomIds []int) map[int][]ViewerPresence
+	CodesPresence(db *vbolt.DB, codes []string) map[string][]ViewerPresence
+	// Terms lists the rooms and codes that have entries, expired or not
+	Terms(db *vbolt.DB) (roomIds []int, codes []string)
+}
+
+var presenceStore PresenceStore = boltPresenceStore{}
+
+// boltPresenceStore keeps presence in ViewerPresenceBkt. It's the default for
+// single-instance deployments, development and tests.
+type boltPresenceStore struct{}
+
+func (boltPresenceStore) Read(db *vbolt.DB, key string) (presence ViewerPresence) {
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		vbolt.Read(tx, ViewerPresenceBkt, key, &presence)
+	})
+	return
+}
+
+func (boltPresenceStore) Write(db *vbolt.DB, entries []ViewerPresence) {
+	if len(entries) == 0 {
+		return
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		for _, p := range entries {
+			vbolt.Write(tx, ViewerPresenceBkt, p.Key, &p)
+			vbolt.SetTargetSingleTerm(tx, PresenceByRoomIdx, p.Key, p.RoomId)
+			if p.Code != "" {
+				vbolt.SetTargetSingleTerm(tx, PresenceByCodeIdx, p.Key, p.Code)
+			}
+		}
+		vbolt.TxCommit(tx)
+	})
+}
+
+func (boltPresenceStore) Delete(db *vbolt.DB, entries []ViewerPresence) {
+	if len(entries) == 0 {
+		return
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		for _, p := range entries {
+			vbolt.Delete(tx, ViewerPresenceBkt, p.Key)
+			vbolt.SetTargetSingleTerm(tx, PresenceByRoomIdx, p.Key, -1)
+			if p.Code != "" {
+				vbolt.SetTargetSingleTerm(tx, PresenceByCodeIdx, p.Key, "")
+			}
+		}
+		vbolt.TxCommit(tx)
+	})
+}
+
+func (boltPresenceStore) RoomsPresence(db *vbolt.DB, roomIds []int) map[int][]ViewerPresence {
+	result := make(map[int][]ViewerPresence)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		for _, roomId := range roomIds {
+			var keys []string
+			vbolt.ReadTermTargets(tx, PresenceByRoomIdx, roomId, &keys, vbolt.Window{})
+			result[roomId] = readBoltPresence(tx, keys)
+		}
+	})
+	return result
+}
+
+func (boltPresenceStore) CodesPresence(db *vbolt.DB, codes []string) map[string][]ViewerPresence {
+	result := make(map[string][]ViewerPresence)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		for _, code := range codes {
+			var keys []string
+			vbolt.ReadTermTargets(tx, PresenceByCodeIdx, code, &keys, vbolt.Window{})
+			result[code] = readBoltPresence(tx, keys)
+		}
+	})
+	return result
+}
+
+func (boltPresenceStore) Terms(db *vbolt.DB) (roomIds []int, codes []string) {
+	seenRooms := make(map[int]bool)
+	seenCodes := make(map[string]bool)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		vbolt.IterateAll(tx, ViewerPresenceBkt, func(key string, presence ViewerPresence) bool {
+			if !seenRooms[presence.RoomId] {
+				seenRooms[presence.RoomId] = true
+				roomIds = append(roomIds, presence.RoomId)
+			}
+			if presence.Code != "" && !seenCodes[presence.Code] {
+				seenCodes[presence.Code] = true
+				codes = append(codes, presence.Code)
+			}
+			return true
+		})
+	})
+	return
+}
+
+func readBoltPresence(tx *vbolt.Tx, keys []string) (entries []ViewerPresence) {
+	for _, key := range keys {
+		var presence ViewerPresence
+		vbolt.Read(tx, ViewerPresenceBkt, key, &presence)
+		if presence.Key != "" {
+			entries = append(entries, presence)
+		}
+	}
+	return
+}
+
+// presenceMu serializes this instance's presence changes and the recounts
+// that follow them, so its own entries' connection counts aren't lost to
+// concurrent read-modify-writes and an older count never overwrites a newer one
+var presenceMu sync.Mutex
+
+// livePresenceCount sums the connections of entries that haven't expired
+func livePresenceCount(entries []ViewerPresence, now time.Time) (count int) {
+	for _, presence := range entries {
+		if presence.ExpiresAt.After(now) {
+			count += presence.Connections
+		}
+	}
+	return
+}
+
+// presenceRecount is a set of rooms, studios and codes whose CurrentViewers
+// should be recomputed from presence. Rooms bring their studio along.
+type presenceRecount struct {
+	rooms   map[int]bool
+	studios map[int]bool
+	codes   map[string]bool
+}
+
+func newPresenceRecount() presenceRecount {
+	return presenceRecount{rooms: map[int]bool{}, studios: map[int]bool{}, codes: map[string]bool{}}
+}
+
+// add takes in the room, studio and code an entry counts towards
+func (r presenceRecount) add(p ViewerPresence) {
+	r.rooms[p.RoomId] = true
+	if p.StudioId != 0 {
+		r.studios[p.StudioId] = true
+	}
+	if p.Code != "" {
+		r.codes[p.Code] = true
+	}
+}
+
+// viewerCounts is live presence tallied for a presenceRecount
+type viewerCounts struct {
+	rooms   map[int]int
+	studios map[int]int
+	codes   map[string]int
+}
+
+// countViewers tallies live presence for a recount with one batched read of
+// the affected studios' rooms and one of the codes
+func countViewers(db *vbolt.DB, recount presenceRecount, now time.Time) viewerCounts {
+	studioRooms := make(map[int][]int)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		for roomId := range recount.rooms {
+			if room := GetRoom(tx, roomId); room.Id != 0 {
+				recount.studios[room.StudioId] = true
+			}
+		}
+		for studioId := range recount.studios {
+			for _, room := range ListStudioRooms(tx, studioId) {
+				studioRooms[studioId] = append(studioRooms[studioId], room.Id)
+			}
+		}
+	})
+
+	var roomIds []int
+	for roomId := range recount.rooms {
+		roomIds = append(roomIds, roomId)
+	}
+	for _, ids := range studioRooms {
+		roomIds = append(roomIds, ids...)
+	}
+	var codes []string
+	for code := range recount.codes {
+		codes = append(codes, code)
+	}
+	roomEntries := presenceStore.RoomsPresence(db, roomIds)
+	codeEntries := presenceStore.CodesPresence(db, codes)
+
+	counts := viewerCounts{rooms: map[int]int{}, studios: map[int]int{}, codes: map[string]int{}}
+	for roomId := range recount.rooms {
+		counts.rooms[roomId] = livePresenceCount(roomEntries[roomId], now)
+	}
+	for studioId := range recount.studios {
+		counts.studios[studioId] = 0
+		for _, roomId := range studioRooms[studioId] {
+			counts.studios[studioId] += livePresenceCount(roomEntries[roomId], now)
+		}
+	}
+	for _, code := range codes {
+		counts.codes[code] = livePresenceCount(codeEntries[code], now)
+	}
+	return counts
+}
+
+// writeViewerCountsTx stores counts as CurrentViewers, skipping rooms, studios
+// and codes that no longer exist and writing only what changed. Returns the
+// rooms whose count changed.
+func writeViewerCountsTx(tx *vbolt.Tx, counts viewerCounts, now time.Time) (changedRooms map[int]int) {
+	changedRooms = make(map[int]int)
+	for roomId, count := range counts.rooms {
+		if GetRoom(tx, roomId).Id == 0 {
+			continue
+		}
+		var analytics RoomAnalytics
+		vbolt.Read(tx, RoomAnalyticsBkt, roomId, &analytics)
+		if analytics.CurrentViewers == count && (analytics.RoomId != 0 || count == 0) {
+			continue
+		}
+		analytics.RoomId = roomId
+		setRoomCurrentViewers(&analytics, count, now)
+		vbolt.Write(tx, RoomAnalyticsBkt, roomId, &analytics)
+		changedRooms[roomId] = count
+	}
+
+	for studioId, count := range counts.studios {
+		if GetStudioById(tx, studioId).Id == 0 {
+			continue
+		}
+		var analytics StudioAnalytics
+		vbolt.Read(tx, StudioAnalyticsBkt, studioId, &analytics)
+		if analytics.CurrentViewers == count && (analytics.StudioId != 0 || count == 0) {
+			continue
+		}
+		analytics.StudioId = studioId
+		analytics.CurrentViewers = count
+		vbolt.Write(tx, StudioAnalyticsBkt, studioId, &analytics)
+	}
+
+	for code, count := range counts.codes {
+		var accessCode AccessCode
+		vbolt.Read(tx, AccessCodesBkt, code, &accessCode)
+		if accessCode.Code == "" {
+			continue
+		}
+		var analytics CodeAnalytics
+		vbolt.Read(tx, CodeAnalyticsBkt, code, &analytics)
+		if analytics.CurrentViewers == count && (analytics.Code != "" || count == 0) {
+			continue
+		}
+		analytics.Code = code
+		setCodeCurrentViewers(&analytics, count, now)
+		vbolt.Write(tx, CodeAnalyticsBkt, code, &analytics)
+	}
+	return
+}
+
+// recountViewers recomputes and stores CurrentViewers for a recount. The
+// presence reads happen before the write transaction opens. Call with
+// presenceMu held.
+func recountViewers(db *vbolt.DB, recount presenceRecount, now time.Time) (counts viewerCounts, changedRooms map[int]int) {
+	counts = countViewers(db, recount, now)
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		changedRooms = writeViewerCountsTx(tx, counts, now)
+		vbolt.TxCommit(tx)
+	})
+	return
+}
+
+func setRoomCurrentViewers(analytics *RoomAnalytics, count int, now time.Time) {
+	analytics.CurrentViewers = count
+	if count > analytics.PeakViewers {
+		analytics.PeakViewers = count
+		analytics.PeakViewersAt = now
+	}
+}
+
+func setCodeCurrentViewers(analytics *CodeAnalytics, count int, now time.Time) {
+	analytics.CurrentViewers = count
+	if count > analytics.PeakViewers {
+		analytics.PeakViewers = count
+		analytics.PeakViewersAt = now
+	}
+}
+
+// joinPresence records a new connection from this instance and returns the
+// room's recounted viewers
+func joinPresence(db *vbolt.DB, room Room, viewerId string, code string) int {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+
+	now := time.Now()
+	presence := presenceStore.Read(db, presenceKey(presenceInstanceId, viewerId, room.Id))
+	if presence.Key == "" || presence.ExpiresAt.Before(now) {
+		presence = ViewerPresence{
+			Key:        presenceKey(presenceInstanceId, viewerId, room.Id),
+			InstanceId: presenceInstanceId,
+			ViewerId:   viewerId,
+			RoomId:     room.Id,
+			StudioId:   room.StudioId,
+			Code:       code,
+		}
+	}
+	presence.Connections++
+	presence.HeartbeatAt = now
+	presence.ExpiresAt = now.Add(PRESENCE_TTL)
+	presenceStore.Write(db, []ViewerPresence{presence})
+
+	recount := newPresenceRecount()
+	recount.add(presence)
+	counts, _ := recountViewers(db, recount, now)
+	return counts.rooms[room.Id]
+}
+
+// leavePresence records a closed connection from this instance and returns
+// the room's recounted viewers. accessCode is recounted too, in case the
+// entry was already gone.
+func leavePresence(db *vbolt.DB, roomId int, viewerId string, accessCode string) int {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+
+	recount := newPresenceRecount()
+	recount.rooms[roomId] = true
+	if accessCode != "" {
+		recount.codes[accessCode] = true
+	}
+
+	presence := presenceStore.Read(db, presenceKey(presenceInstanceId, viewerId, roomId))
+	if presence.Key != "" {
+		recount.add(presence)
+		presence.Connections--
+		if presence.Connections <= 0 {
+			presenceStore.Delete(db, []ViewerPresence{presence})
+		} else {
+			presenceStore.Write(db, []ViewerPresence{presence})
+		}
+	}
+
+	counts, _ := recountViewers(db, recount, time.Now())
+	return counts.rooms[roomId]
+}
+
+// dropPresence removes the matching entries of the given rooms from every
+// instance, recounts and broadcasts what changed. Call after committing
+// whatever ended the viewers' access.
+func dropPresence(db *vbolt.DB, roomIds []int, codes []string, match func(ViewerPresence) bool) {
+	if len(roomIds) == 0 && len(codes) == 0 {
+		return
+	}
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+
+	recount := newPresenceRecount()
+	var dropped []ViewerPresence
+	for _, entries := range presenceStore.RoomsPresence(db, roomIds) {
+		for _, presence := range entries {
+			if match(presence) {
+				dropped = append(dropped, presence)
+			}
+		}
+	}
+	for _, entries := range presenceStore.CodesPresence(db, codes) {
+		for _, presence := range entries {
+			if match(presence) {
+				dropped = append(dropped, presence)
+			}
+		}
+	}
+	for _, roomId := range roomIds {
+		recount.rooms[roomId] = true
+	}
+	for _, code := range codes {
+		recount.codes[code] = true
+	}
+	for _, presence := range dropped {
+		recount.add(presence)
+	}
+	presenceStore.Delete(db, dropped)
+
+	_, changedRooms := recountViewers(db, recount, time.Now())
+	for roomId, count := range changedRooms {
+		sseManager.BroadcastViewerCount(roomId, count)
+	}
+}
+
+// dropRoomPresence removes everyone from deleted rooms
+func dropRoomPresence(db *vbolt.DB, roomIds []int) {
+	dropPresence(db, roomIds, nil, func(ViewerPresence) bool { return true })
+}
+
+// dropCodeSessionsPresence removes code sessions whose access ended (revoked,
+// ended by an admin, or past their grace period) from every room
+func dropCodeSessionsPresence(db *vbolt.DB, code string, sessionTokens []string) {
+	if len(sessionTokens) == 0 {
+		return
+	}
+	ended := make(map[string]bool)
+	for _, token := range sessionTokens {
+		ended["code:"+token] = true
+	}
+	dropPresence(db, nil, []string{code}, func(p ViewerPresence) bool {
+		return ended[p.ViewerId]
+	})
+}
+
+// presenceCounted is what the previous heartbeat counted, so rooms and codes
+// whose last entry went away are still brought down to zero
+var presenceCounted struct {
+	rooms map[int]bool
+	codes map[string]bool
+}
+
+// RefreshViewerPresence is the presence heartbeat. It refreshes this
+// instance's entries from its open SSE connections, drops expired entries
+// from any instance, and brings the CurrentViewers counts of rooms and codes
+// with presence now or on the previous heartbeat in line with what is left,
+// broadcasting rooms whose count changed.
+func RefreshViewerPresence(db *vbolt.DB) {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+
+	now := time.Now()
+	connections := sseManager.viewerConnections()
+
+	// This instance's connections, as they are right now
+	var mine []ViewerPresence
+	live := make(map[string]bool)
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		for conn, count := range connections {
+			room := GetRoom(tx, conn.RoomId)
+			if room.Id == 0 {
+				continue
+			}
+			code := ""
+			if conn.SessionToken != "" {
+				// Code sessions ended by expiry or revocation no longer count,
+				// even while their connection lingers
+				var session CodeSession
+				vbolt.Read(tx, CodeSessionsBkt, conn.SessionToken, &session)
+				var accessCode AccessCode
+				vbolt.Read(tx, AccessCodesBkt, session.Code, &accessCode)
+				if session.Token == "" || accessCode.IsRevoked {
+					continue
+				}
+				code = session.Code
+			}
+			presence := ViewerPresence{
+				Key:         presenceKey(presenceInstanceId, conn.ViewerId, room.Id),
+				InstanceId:  presenceInstanceId,
+				ViewerId:    conn.ViewerId,
+				RoomId:      room.Id,
+				StudioId:    room.StudioId,
+				Code:        code,
+				Connections: count,
+				HeartbeatAt: now,
+				ExpiresAt:   now.Add(PRESENCE_TTL),
+			}
+			mine = append(mine, presence)
+			live[presence.Key] = true
+		}
+	})
+	presenceStore.Write(db, mine)
+
+	// The rooms and codes to recount
+	recount := newPresenceRecount()
+	roomIds, codes := presenceStore.Terms(db)
+	for roomId := range presenceCounted.rooms {
+		roomIds = append(roomIds, roomId)
+	}
+	for _, roomId := range roomIds {
+		recount.rooms[roomId] = true
+	}
+	for _, code := range codes {
+		recount.codes[code] = true
+	}
+	for code := range presenceCounted.codes {
+		recount.codes[code] = true
+	}
+
+	// Drop what's stale
+	var stale []ViewerPresence
+	for _, entries := range presenceStore.RoomsPresence(db, roomIds) {
+		for _, presence := range entries {
+			isGone := presence.InstanceId == presenceInstanceId && !live[presence.Key]
+			if isGone || !presence.ExpiresAt.After(now) {
+				stale = append(stale, presence)
+				recount.add(presence)
+			}
+		}
+	}
+	presenceStore.Delete(db, stale)
+
+	// Bring stored counts in line
+	counts, changedRooms := recountViewers(db, recount, now)
+	presenceCounted.rooms = make(map[int]bool)
+	presenceCounted.codes = make(map[string]bool)
+	for roomId, count := range counts.rooms {
+		if count > 0 {
+			presenceCounted.rooms[roomId] = true
+		}
+	}
+	for code, count := range counts.codes {
+		if count > 0 {
+			presenceCounted.codes[code] = true
+		}
+	}
+
+	for roomId, count := range changedRooms {
+		sseManager.BroadcastViewerCount(roomId, count)
+	}
+
+	if len(stale) > 0 || len(changedRooms) > 0 {
+		LogDebug(LogCategorySystem, "Viewer presence refreshed", map[string]interface{}{
+			"instanceId":   presenceInstanceId,
+			"expired":      len(stale),
+			"roomsChanged": len(changedRooms),
+		})
+	}
+}
+
+// ResetStaleViewerCounts recounts every room, studio and code whose stored
+// CurrentViewers isn't zero. At startup that zeroes counts left over from a
+// crash or restart, or from before presence moved to another store, except
+// for viewers other instances are still serving. The heartbeat only revisits
+// rooms and codes with presence, so without this such counts would stick.
+func ResetStaleViewerCounts(db *vbolt.DB) {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+
+	recount := newPresenceRecount()
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		vbolt.IterateAll(tx, RoomAnalyticsBkt, func(roomId int, analytics RoomAnalytics) bool {
+			if analytics.CurrentViewers != 0 {
+				recount.rooms[roomId] = true
+			}
+			return true
+		})
+		vbolt.IterateAll(tx, StudioAnalyticsBkt, func(studioId int, analytics StudioAnalytics) bool {
+			if analytics.CurrentViewers != 0 {
+				recount.studios[studioId] = true
+			}
+			return true
+		})
+		vbolt.IterateAll(tx, CodeAnalyticsBkt, func(code string, analytics CodeAnalytics) bool {
+			if analytics.CurrentViewers != 0 {
+				recount.codes[code] = true
+			}
+			return true
+		})
+	})
+
+	_, changedRooms := recountViewers(db, recount, time.Now())
+	if len(changedRooms) > 0 {
+		LogInfo(LogCategorySystem, "Reset stale viewer counts", map[string]interface{}{
+			"rooms": len(changedRooms),
+		})
+	}
+}
+
+// StartViewerPresence starts the presence heartbeat, after zeroing counts
+// left over from a crash (see ResetStaleViewerCounts)
+func StartViewerPresence(db *vbolt.DB) {
+	LogInfo(LogCategorySystem, "Starting viewer presence heartbeat", map[string]interface{}{
+		"instanceId": presenceInstanceId,
+		"interval":   PRESENCE_HEARTBEAT_INTERVAL.String(),
+		"ttl":        PRESENCE_TTL.String(),
+	})
+
+	go func() {
+		ResetStaleViewerCounts(db)
+		RefreshViewerPresence(db)
+
+		ticker := time.NewTicker(PRESENCE_HEARTBEAT_INTERVAL)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			RefreshViewerPresence(db)
+		}
+	}()
+}
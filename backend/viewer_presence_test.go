@@ -0,0 +1,229 @@
+package backend
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbolt"
+)
+
+func TestViewerPresence(t *testing.T) {
+	db := setupTestAnalyticsDB(t)
+	defer db.Close()
+	testViewerPresence(t, db)
+}
+
+// With Redis, entries live there while the counts they feed stay in bolt
+func TestViewerPresenceRedis(t *testing.T) {
+	server := startFakeRedis(t)
+	store, err := NewRedisPresenceStore(server.URL(), "test:presence")
+	if err != nil {
+		t.Fatalf("NewRedisPresenceStore failed: %v", err)
+	}
+	presenceStore = store
+	defer func() {
+		presenceStore = boltPresenceStore{}
+		store.Close()
+	}()
+
+	db := setupTestAnalyticsDB(t)
+	defer db.Close()
+	testViewerPresence(t, db)
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		vbolt.IterateAll(tx, ViewerPresenceBkt, func(key string, presence ViewerPresence) bool {
+			t.Errorf("Expected no presence in bolt, found %s", key)
+			return true
+		})
+	})
+
+	// Entries Redis expired on its own are pruned and their room counted down
+	room := Room{Id: 11, StudioId: 1, Name: "Second Room", Creation: time.Now()}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		vbolt.Write(tx, RoomsBkt, room.Id, &room)
+		vbolt.SetTargetSingleTerm(tx, RoomsByStudioIdx, room.Id, room.StudioId)
+		vbolt.TxCommit(tx)
+	})
+	store.Write(db, []ViewerPresence{{
+		Key:         presenceKey("other", "user:6", room.Id),
+		InstanceId:  "other",
+		ViewerId:    "user:6",
+		RoomId:      room.Id,
+		StudioId:    room.StudioId,
+		Connections: 2,
+		ExpiresAt:   time.Now().Add(PRESENCE_TTL),
+	}})
+	roomCount := func() (count int) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var analytics RoomAnalytics
+			vbolt.Read(tx, RoomAnalyticsBkt, room.Id, &analytics)
+			count = analytics.CurrentViewers
+		})
+		return
+	}
+	RefreshViewerPresence(db)
+	if count := roomCount(); count != 2 {
+		t.Errorf("Expected the other instance's viewer in the second room, got %d", count)
+	}
+
+	server.expire("test:presence:entry:" + presenceKey("other", "user:6", room.Id))
+	RefreshViewerPresence(db)
+	if count := roomCount(); count != 0 {
+		t.Errorf("Expected the expired entry to stop counting, got %d", count)
+	}
+	if roomIds, _ := store.Terms(nil); len(roomIds) != 0 {
+		t.Errorf("Expected no rooms listed once their entries expired, got %v", roomIds)
+	}
+}
+
+// Counts left behind by a crash are zeroed at startup, even for rooms and
+// codes no heartbeat will see again, while live presence is kept
+func TestResetStaleViewerCounts(t *testing.T) {
+	db := setupTestAnalyticsDB(t)
+	defer db.Close()
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		studio := Studio{Id: 1, Name: "Test Studio", OwnerId: 100, Creation: time.Now()}
+		vbolt.Write(tx, StudiosBkt, studio.Id, &studio)
+		for _, roomId := range []int{10, 11} {
+			room := Room{Id: roomId, StudioId: studio.Id, Name: "Test Room", Creation: time.Now()}
+			vbolt.Write(tx, RoomsBkt, room.Id, &room)
+			vbolt.SetTargetSingleTerm(tx, RoomsByStudioIdx, room.Id, studio.Id)
+			analytics := RoomAnalytics{RoomId: room.Id, CurrentViewers: 7}
+			vbolt.Write(tx, RoomAnalyticsBkt, room.Id, &analytics)
+		}
+		studioAnalytics := StudioAnalytics{StudioId: studio.Id, CurrentViewers: 14}
+		vbolt.Write(tx, StudioAnalyticsBkt, studio.Id, &studioAnalytics)
+		code := AccessCode{Code: "12345", Type: CodeTypeRoom, TargetId: 10, ExpiresAt: time.Now().Add(time.Hour)}
+		vbolt.Write(tx, AccessCodesBkt, code.Code, &code)
+		codeAnalytics := CodeAnalytics{Code: code.Code, CurrentViewers: 4}
+		vbolt.Write(tx, CodeAnalyticsBkt, code.Code, &codeAnalytics)
+		vbolt.TxCommit(tx)
+	})
+
+	// Another instance is still serving one viewer in room 11
+	presenceStore.Write(db, []ViewerPresence{{
+		Key:         presenceKey("other", "user:1", 11),
+		InstanceId:  "other",
+		ViewerId:    "user:1",
+		RoomId:      11,
+		StudioId:    1,
+		Connections: 1,
+		ExpiresAt:   time.Now().Add(PRESENCE_TTL),
+	}})
+	defer presenceStore.Delete(db, presenceStore.RoomsPresence(db, []int{11})[11])
+
+	ResetStaleViewerCounts(db)
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		var stale, live RoomAnalytics
+		vbolt.Read(tx, RoomAnalyticsBkt, 10, &stale)
+		vbolt.Read(tx, RoomAnalyticsBkt, 11, &live)
+		var studioAnalytics StudioAnalytics
+		vbolt.Read(tx, StudioAnalyticsBkt, 1, &studioAnalytics)
+		var codeAnalytics CodeAnalytics
+		vbolt.Read(tx, CodeAnalyticsBkt, "12345", &codeAnalytics)
+		if stale.CurrentViewers != 0 || codeAnalytics.CurrentViewers != 0 {
+			t.Errorf("Expected counts without presence to be zeroed, got room=%d code=%d", stale.CurrentViewers, codeAnalytics.CurrentViewers)
+		}
+		if live.CurrentViewers != 1 || studioAnalytics.CurrentViewers != 1 {
+			t.Errorf("Expected live presence to be kept, got room=%d studio=%d", live.CurrentViewers, studioAnalytics.CurrentViewers)
+		}
+	})
+}
+
+func testViewerPresence(t *testing.T, db *vbolt.DB) {
+	t.Helper()
+
+	var room Room
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		studio := Studio{Id: 1, Name: "Test Studio", OwnerId: 100, Creation: time.Now()}
+		vbolt.Write(tx, StudiosBkt, studio.Id, &studio)
+		room = Room{Id: 10, StudioId: studio.Id, Name: "Test Room", Creation: time.Now()}
+		vbolt.Write(tx, RoomsBkt, room.Id, &room)
+		vbolt.SetTargetSingleTerm(tx, RoomsByStudioIdx, room.Id, studio.Id)
+		vbolt.TxCommit(tx)
+	})
+
+	counts := func() (roomCount int, studioCount int) {
+		vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+			var analytics RoomAnalytics
+			vbolt.Read(tx, RoomAnalyticsBkt, room.Id, &analytics)
+			var studioAnalytics StudioAnalytics
+			vbolt.Read(tx, StudioAnalyticsBkt, room.StudioId, &studioAnalytics)
+			roomCount, studioCount = analytics.CurrentViewers, studioAnalytics.CurrentViewers
+		})
+		return
+	}
+
+	// Each connection counts, including a viewer's second tab
+	IncrementRoomViewerCount(db, room.Id, "user:1", "")
+	IncrementRoomViewerCount(db, room.Id, "user:1", "")
+	IncrementRoomViewerCount(db, room.Id, "user:2", "")
+	if roomCount, studioCount := counts(); roomCount != 3 || studioCount != 3 {
+		t.Errorf("Expected 3 viewers, got room=%d studio=%d", roomCount, studioCount)
+	}
+	DecrementRoomViewerCount(db, room.Id, "user:1", "")
+	DecrementRoomViewerCount(db, room.Id, "user:2", "")
+	if roomCount, _ := counts(); roomCount != 1 {
+		t.Errorf("Expected user:1's other tab to still count, got %d", roomCount)
+	}
+	DecrementRoomViewerCount(db, room.Id, "user:1", "")
+
+	// Another instance serving two viewers, and one that crashed with three
+	// whose entries were never cleaned up, plus a count left to drift
+	now := time.Now()
+	var entries []ViewerPresence
+	for _, presence := range []ViewerPresence{
+		{InstanceId: "other", ViewerId: "user:3", Connections: 2, ExpiresAt: now.Add(PRESENCE_TTL)},
+		{InstanceId: "crashed", ViewerId: "user:4", Connections: 3, ExpiresAt: now.Add(-time.Second)},
+	} {
+		presence.Key = presenceKey(presence.InstanceId, presence.ViewerId, room.Id)
+		presence.RoomId = room.Id
+		presence.StudioId = room.StudioId
+		entries = append(entries, presence)
+	}
+	presenceStore.Write(db, entries)
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		analytics := RoomAnalytics{RoomId: room.Id, CurrentViewers: 42}
+		vbolt.Write(tx, RoomAnalyticsBkt, room.Id, &analytics)
+		vbolt.TxCommit(tx)
+	})
+
+	// The heartbeat counts this instance's open connections, keeps the other
+	// instance's live entry and expires the crashed one's
+	client := &SSEClient{RoomID: room.Id, Writer: httptest.NewRecorder(), Done: make(chan bool), ViewerId: "user:5"}
+	sseManager.AddClient(room.Id, client)
+	RefreshViewerPresence(db)
+	if roomCount, studioCount := counts(); roomCount != 3 || studioCount != 3 {
+		t.Errorf("Expected 3 viewers after the heartbeat, got room=%d studio=%d", roomCount, studioCount)
+	}
+	mine := presenceStore.Read(db, presenceKey(presenceInstanceId, "user:5", room.Id))
+	if mine.Connections != 1 || !mine.ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected a live entry for this instance's viewer, got %+v", mine)
+	}
+	if crashed := presenceStore.Read(db, presenceKey("crashed", "user:4", room.Id)); crashed.Key != "" {
+		t.Errorf("Expected the crashed instance's entry to be expired")
+	}
+
+	// Once the connection is gone the next heartbeat drops it
+	sseManager.RemoveClient(room.Id, client)
+	RefreshViewerPresence(db)
+	if roomCount, _ := counts(); roomCount != 2 {
+		t.Errorf("Expected only the other instance's viewers, got %d", roomCount)
+	}
+
+	// Deleting the room clears its presence
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		cleanupViewerSessionsForRoom(tx, room.Id)
+		vbolt.TxCommit(tx)
+	})
+	dropRoomPresence(db, []int{room.Id})
+	if len(presenceStore.RoomsPresence(db, []int{room.Id})[room.Id]) != 0 {
+		t.Errorf("Expected the room's presence to be cleared")
+	}
+	if _, studioCount := counts(); studioCount != 0 {
+		t.Errorf("Expected the studio count to drop to 0, got %d", studioCount)
+	}
+}
@@ -0,0 +1,463 @@
+package backend
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"stream/cfg"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+	"go.hasen.dev/vpack"
+)
+
+// WATCH_RECORD_MAX_OPEN caps how long a record that was never closed (e.g. server restart)
+// counts as connected
+const WATCH_RECORD_MAX_OPEN = 12 * time.Hour
+
+// CodeWatchRecord is one SSE connection made with an access code (connect -> disconnect).
+// Records are kept after CleanupOldAccessCodes deletes the code, so the code's label,
+// studio and class are copied in at connect time.
+type CodeWatchRecord struct {
+	Id             int       `json:"id"`
+	Code           string    `json:"code"`
+	CodeLabel      string    `json:"codeLabel"`      // Snapshot of the code's label
+	SessionToken   string    `json:"-"`              // CodeSession token
+	DeviceId       string    `json:"-"`              // Device that redeemed the code
+	ClientIP       string    `json:"-"`              // IP at redemption
+	StudioId       int       `json:"studioId"`       // For permission checks after the code is gone
+	RoomId         int       `json:"roomId"`         // Room watched
+	ScheduleId     int       `json:"scheduleId"`     // Class in session at connect time (0 if none)
+	ConnectedAt    time.Time `json:"connectedAt"`    // SSE connect
+	DisconnectedAt time.Time `json:"disconnectedAt"` // SSE disconnect (zero while connected)
+	WatchSeconds   int       `json:"watchSeconds"`   // Playback time reported by the player (StreamMetrics)
+}
+
+func PackCodeWatchRecord(self *CodeWatchRecord, buf *vpack.Buffer) {
+	vpack.Version(1, buf)
+	vpack.Int(&self.Id, buf)
+	vpack.String(&self.Code, buf)
+	vpack.String(&self.CodeLabel, buf)
+	vpack.String(&self.SessionToken, buf)
+	vpack.String(&self.DeviceId, buf)
+	vpack.String(&self.ClientIP, buf)
+	vpack.Int(&self.StudioId, buf)
+	vpack.Int(&self.RoomId, buf)
+	vpack.Int(&self.ScheduleId, buf)
+	vpack.Time(&self.ConnectedAt, buf)
+	vpack.Time(&self.DisconnectedAt, buf)
+	vpack.Int(&self.WatchSeconds, buf)
+}
+
+// CodeWatchRecordsBkt: recordId (int) -> CodeWatchRecord
+var CodeWatchRecordsBkt = vbolt.Bucket(&cfg.Info, "code_watch_records", vpack.FInt, PackCodeWatchRecord)
+
+// WatchRecordsByCodeIdx: code (term) -> recordId (target)
+var WatchRecordsByCodeIdx = vbolt.Index(&cfg.Info, "watch_records_by_code", vpack.StringZ, vpack.FInt)
+
+// WatchRecordsByScheduleIdx: scheduleId (term) -> recordId (target)
+var WatchRecordsByScheduleIdx = vbolt.Index(&cfg.Info, "watch_records_by_schedule", vpack.FInt, vpack.FInt)
+
+// WatchRecordsBySessionIdx: code session token (term) -> recordId (target)
+// Used to attribute player-reported watch time to the session's connection
+var WatchRecordsBySessionIdx = vbolt.Index(&cfg.Info, "watch_records_by_session", vpack.StringZ, vpack.FInt)
+
+// connectedSeconds returns how long the record's SSE connection was open
+func (record CodeWatchRecord) connectedSeconds(now time.Time) int {
+	end := record.DisconnectedAt
+	if end.IsZero() {
+		end = now
+		if end.Sub(record.ConnectedAt) > WATCH_RECORD_MAX_OPEN {
+			end = record.ConnectedAt.Add(WATCH_RECORD_MAX_OPEN)
+		}
+	}
+	if end.Before(record.ConnectedAt) {
+		return 0
+	}
+	return int(end.Sub(record.ConnectedAt).Seconds())
+}
+
+// activeScheduleForRoom returns the class in session in a room right now (0 if none).
+// Schedule-bound codes are attributed to their own class.
+func activeScheduleForRoom(tx *vbolt.Tx, roomId int, accessCode AccessCode, now time.Time) int {
+	if accessCode.ScheduleId != 0 {
+		return accessCode.ScheduleId
+	}
+
+	var scheduleIds []int
+	vbolt.ReadTermTargets(tx, SchedulesByRoomIdx, roomId, &scheduleIds, vbolt.Window{})
+	for _, scheduleId := range scheduleIds {
+		var schedule ClassSchedule
+		vbolt.Read(tx, ClassSchedulesBkt, scheduleId, &schedule)
+		if schedule.Id != 0 && schedule.IsActive && scheduleWindowOpen(&schedule, now) {
+			return schedule.Id
+		}
+	}
+	return 0
+}
+
+// startCodeWatchRecord opens a watch record when a code session connects to a room's SSE stream
+func startCodeWatchRecord(db *vbolt.DB, roomId int, session CodeSession) (recordId int) {
+	now := time.Now()
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		var accessCode AccessCode
+		vbolt.Read(tx, AccessCodesBkt, session.Code, &accessCode)
+		if accessCode.Code == "" {
+			return
+		}
+
+		record := CodeWatchRecord{
+			Id:           vbolt.NextIntId(tx, CodeWatchRecordsBkt),
+			Code:         accessCode.Code,
+			CodeLabel:    accessCode.Label,
+			SessionToken: session.Token,
+			DeviceId:     session.DeviceId,
+			ClientIP:     session.ClientIP,
+			StudioId:     GetRoom(tx, roomId).StudioId,
+			RoomId:       roomId,
+			ScheduleId:   activeScheduleForRoom(tx, roomId, accessCode, now),
+			ConnectedAt:  now,
+		}
+		vbolt.Write(tx, CodeWatchRecordsBkt, record.Id, &record)
+		vbolt.SetTargetSingleTerm(tx, WatchRecordsByCodeIdx, record.Id, record.Code)
+		vbolt.SetTargetSingleTerm(tx, WatchRecordsBySessionIdx, record.Id, record.SessionToken)
+		if record.ScheduleId != 0 {
+			vbolt.SetTargetSingleTerm(tx, WatchRecordsByScheduleIdx, record.Id, record.ScheduleId)
+		}
+
+		vbolt.TxCommit(tx)
+		recordId = record.Id
+	})
+	return
+}
+
+// endCodeWatchRecord closes a watch record when the SSE connection ends
+func endCodeWatchRecord(db *vbolt.DB, recordId int) {
+	if recordId == 0 {
+		return
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		var record CodeWatchRecord
+		vbolt.Read(tx, CodeWatchRecordsBkt, recordId, &record)
+		if record.Id == 0 || !record.DisconnectedAt.IsZero() {
+			return
+		}
+		record.DisconnectedAt = time.Now()
+		vbolt.Write(tx, CodeWatchRecordsBkt, record.Id, &record)
+		vbolt.TxCommit(tx)
+	})
+}
+
+// recordCodeWatchSecondsTx adds player-reported watch time to the session's latest
+// connection to the room. Returns false if the session has no watch record there.
+func recordCodeWatchSecondsTx(tx *vbolt.Tx, sessionToken string, roomId int, watchSeconds int) bool {
+	if sessionToken == "" || watchSeconds <= 0 {
+		return false
+	}
+
+	var recordIds []int
+	vbolt.ReadTermTargets(tx, WatchRecordsBySessionIdx, sessionToken, &recordIds, vbolt.Window{})
+	for i := len(recordIds) - 1; i >= 0; i-- {
+		var record CodeWatchRecord
+		vbolt.Read(tx, CodeWatchRecordsBkt, recordIds[i], &record)
+		if record.Id == 0 || record.RoomId != roomId {
+			continue
+		}
+		record.WatchSeconds += watchSeconds
+		vbolt.Write(tx, CodeWatchRecordsBkt, record.Id, &record)
+		return true
+	}
+	return false
+}
+
+func readWatchRecords(tx *vbolt.Tx, recordIds []int) []CodeWatchRecord {
+	records := make([]CodeWatchRecord, 0, len(recordIds))
+	for _, recordId := range recordIds {
+		var record CodeWatchRecord
+		vbolt.Read(tx, CodeWatchRecordsBkt, recordId, &record)
+		if record.Id != 0 {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ConnectedAt.Before(records[j].ConnectedAt)
+	})
+	return records
+}
+
+// API Request/Response types
+
+// WatchSummary aggregates watch records
+type WatchSummary struct {
+	Sessions         int       `json:"sessions"`         // Distinct code sessions (redemptions)
+	Connections      int       `json:"connections"`      // SSE connections
+	ConnectedSeconds int       `json:"connectedSeconds"` // Total time connected
+	WatchSeconds     int       `json:"watchSeconds"`     // Total player-reported watch time
+	FirstConnectedAt time.Time `json:"firstConnectedAt"`
+	LastConnectedAt  time.Time `json:"lastConnectedAt"`
+}
+
+// WatchSessionRow is the watch time of a single code session
+type WatchSessionRow struct {
+	SessionId        string    `json:"sessionId"` // Short, non-secret session identifier
+	Code             string    `json:"code"`
+	ClientIP         string    `json:"clientIP"` // Anonymized IP address
+	Connections      int       `json:"connections"`
+	FirstConnectedAt time.Time `json:"firstConnectedAt"`
+	LastConnectedAt  time.Time `json:"lastConnectedAt"`
+	ConnectedSeconds int       `json:"connectedSeconds"`
+	WatchSeconds     int       `json:"watchSeconds"`
+	IsConnected      bool      `json:"isConnected"`
+}
+
+// CodeWatchRow is the watch time of a single code within a class
+type CodeWatchRow struct {
+	Code             string `json:"code"`
+	Label            string `json:"label"`
+	Sessions         int    `json:"sessions"`
+	ConnectedSeconds int    `json:"connectedSeconds"`
+	WatchSeconds     int    `json:"watchSeconds"`
+}
+
+type GetCodeWatchReportRequest struct {
+	Code string `json:"code"`
+}
+
+type GetCodeWatchReportResponse struct {
+	Code        string            `json:"code"`
+	Label       string            `json:"label"`
+	CodeDeleted bool              `json:"codeDeleted"` // Code was removed by cleanup; history is kept
+	Summary     WatchSummary      `json:"summary"`
+	Sessions    []WatchSessionRow `json:"sessions"`
+}
+
+type GetClassWatchReportRequest struct {
+	ScheduleId int `json:"scheduleId"`
+}
+
+type GetClassWatchReportResponse struct {
+	ScheduleId   int               `json:"scheduleId"`
+	ScheduleName string            `json:"scheduleName"`
+	Summary      WatchSummary      `json:"summary"`
+	Codes        []CodeWatchRow    `json:"codes"`
+	Sessions     []WatchSessionRow `json:"sessions"`
+}
+
+func watchSessionId(sessionToken string) string {
+	if len(sessionToken) > 8 {
+		return sessionToken[:8]
+	}
+	return sessionToken
+}
+
+// summarizeWatchRecords aggregates records (sorted by ConnectedAt) overall and per code session
+func summarizeWatchRecords(records []CodeWatchRecord, now time.Time) (summary WatchSummary, sessions []WatchSessionRow) {
+	sessions = make([]WatchSessionRow, 0)
+	sessionIndex := make(map[string]int)
+
+	for _, record := range records {
+		connected := record.connectedSeconds(now)
+
+		summary.Connections++
+		summary.ConnectedSeconds += connected
+		summary.WatchSeconds += record.WatchSeconds
+		if summary.FirstConnectedAt.IsZero() {
+			summary.FirstConnectedAt = record.ConnectedAt
+		}
+		summary.LastConnectedAt = record.ConnectedAt
+
+		i, ok := sessionIndex[record.SessionToken]
+		if !ok {
+			i = len(sessions)
+			sessionIndex[record.SessionToken] = i
+			sessions = append(sessions, WatchSessionRow{
+				SessionId:        watchSessionId(record.SessionToken),
+				Code:             record.Code,
+				ClientIP:         anonymizeIP(record.ClientIP),
+				FirstConnectedAt: record.ConnectedAt,
+			})
+		}
+		row := &sessions[i]
+		row.Connections++
+		row.LastConnectedAt = record.ConnectedAt
+		row.ConnectedSeconds += connected
+		row.WatchSeconds += record.WatchSeconds
+		if record.DisconnectedAt.IsZero() && connected < int(WATCH_RECORD_MAX_OPEN.Seconds()) {
+			row.IsConnected = true
+		}
+	}
+
+	summary.Sessions = len(sessions)
+	return
+}
+
+// GetCodeWatchReport returns per-session watch time for a code, including codes already
+// removed by CleanupOldAccessCodes
+func GetCodeWatchReport(ctx *vbeam.Context, req GetCodeWatchReportRequest) (resp GetCodeWatchReportResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	records, studioId, label, codeDeleted, err := codeWatchRecords(ctx.Tx, req.Code)
+	if err != nil {
+		return
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, studioId, CapViewAnalytics) {
+		return resp, errors.New("Only studio admins can view watch reports")
+	}
+
+	resp.Code = req.Code
+	resp.Label = label
+	resp.CodeDeleted = codeDeleted
+	resp.Summary, resp.Sessions = summarizeWatchRecords(records, time.Now())
+	return
+}
+
+// codeWatchRecords loads a code's watch records along with the studio and label,
+// falling back to the records themselves when the code was deleted
+func codeWatchRecords(tx *vbolt.Tx, code string) (records []CodeWatchRecord, studioId int, label string, codeDeleted bool, err error) {
+	var recordIds []int
+	vbolt.ReadTermTargets(tx, WatchRecordsByCodeIdx, code, &recordIds, vbolt.Window{})
+	records = readWatchRecords(tx, recordIds)
+
+	var accessCode AccessCode
+	vbolt.Read(tx, AccessCodesBkt, code, &accessCode)
+	if accessCode.Code != "" {
+		return records, codeStudioId(tx, accessCode), accessCode.Label, false, nil
+	}
+
+	if len(records) == 0 {
+		return records, 0, "", true, errors.New("No watch history for this code")
+	}
+	last := records[len(records)-1]
+	return records, last.StudioId, last.CodeLabel, true, nil
+}
+
+// GetClassWatchReport returns watch time for a class, per code and per session
+func GetClassWatchReport(ctx *vbeam.Context, req GetClassWatchReportRequest) (resp GetClassWatchReportResponse, err error) {
+	// Check authentication
+	caller, authErr := GetAuthUser(ctx)
+	if authErr != nil {
+		return resp, errors.New("Authentication required")
+	}
+
+	var schedule ClassSchedule
+	vbolt.Read(ctx.Tx, ClassSchedulesBkt, req.ScheduleId, &schedule)
+	if schedule.Id == 0 {
+		return resp, errors.New("Class schedule not found")
+	}
+
+	if !HasStudioCapability(ctx.Tx, caller.Id, schedule.StudioId, CapViewAnalytics) {
+		return resp, errors.New("Only studio admins can view watch reports")
+	}
+
+	var recordIds []int
+	vbolt.ReadTermTargets(ctx.Tx, WatchRecordsByScheduleIdx, schedule.Id, &recordIds, vbolt.Window{})
+	records := readWatchRecords(ctx.Tx, recordIds)
+
+	now := time.Now()
+	resp.ScheduleId = schedule.Id
+	resp.ScheduleName = schedule.Name
+	resp.Summary, resp.Sessions = summarizeWatchRecords(records, now)
+
+	// Per-code totals
+	resp.Codes = make([]CodeWatchRow, 0)
+	codeIndex := make(map[string]int)
+	for _, record := range records {
+		i, ok := codeIndex[record.Code]
+		if !ok {
+			i = len(resp.Codes)
+			codeIndex[record.Code] = i
+			resp.Codes = append(resp.Codes, CodeWatchRow{Code: record.Code, Label: record.CodeLabel})
+		}
+		resp.Codes[i].ConnectedSeconds += record.connectedSeconds(now)
+		resp.Codes[i].WatchSeconds += record.WatchSeconds
+	}
+	for _, session := range resp.Sessions {
+		resp.Codes[codeIndex[session.Code]].Sessions++
+	}
+	return
+}
+
+// watchReportExportHandler exports raw watch records as CSV for a code (?code=) or class (?scheduleId=)
+func watchReportExportHandler(w http.ResponseWriter, r *http.Request) {
+	authCtx, authErr := GetAuthFromRequest(r, appDb)
+	if authErr != nil || authCtx.User.Id <= 0 {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	scheduleId, _ := strconv.Atoi(r.URL.Query().Get("scheduleId"))
+
+	var records []CodeWatchRecord
+	var filename string
+	var err error
+	vbolt.WithReadTx(appDb, func(tx *vbolt.Tx) {
+		var studioId int
+		if code != "" {
+			records, studioId, _, _, err = codeWatchRecords(tx, code)
+			filename = fmt.Sprintf("watch-report-code-%s.csv", code)
+		} else {
+			var schedule ClassSchedule
+			vbolt.Read(tx, ClassSchedulesBkt, scheduleId, &schedule)
+			if schedule.Id == 0 {
+				err = errors.New("Class schedule not found")
+				return
+			}
+			var recordIds []int
+			vbolt.ReadTermTargets(tx, WatchRecordsByScheduleIdx, schedule.Id, &recordIds, vbolt.Window{})
+			records = readWatchRecords(tx, recordIds)
+			studioId = schedule.StudioId
+			filename = fmt.Sprintf("watch-report-class-%d.csv", schedule.Id)
+		}
+		if err == nil && !HasStudioCapability(tx, authCtx.User.Id, studioId, CapViewAnalytics) {
+			err = errors.New("Only studio admins can export watch reports")
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	now := time.Now()
+	out := csv.NewWriter(w)
+	out.Write([]string{"code", "label", "session", "client_ip", "room_id", "schedule_id", "connected_at", "disconnected_at", "connected_seconds", "watch_seconds"})
+	for _, record := range records {
+		disconnectedAt := ""
+		if !record.DisconnectedAt.IsZero() {
+			disconnectedAt = record.DisconnectedAt.UTC().Format(time.RFC3339)
+		}
+		out.Write([]string{
+			record.Code,
+			record.CodeLabel,
+			watchSessionId(record.SessionToken),
+			anonymizeIP(record.ClientIP),
+			strconv.Itoa(record.RoomId),
+			strconv.Itoa(record.ScheduleId),
+			record.ConnectedAt.UTC().Format(time.RFC3339),
+			disconnectedAt,
+			strconv.Itoa(record.connectedSeconds(now)),
+			strconv.Itoa(record.WatchSeconds),
+		})
+	}
+	out.Flush()
+}
+
+// RegisterWatchAuditMethods registers watch report procedures and the CSV export
+func RegisterWatchAuditMethods(app *vbeam.Application) {
+	app.HandleFunc("/api/watch-report/export", watchReportExportHandler)
+
+	vbeam.RegisterProc(app, GetCodeWatchReport)
+	vbeam.RegisterProc(app, GetClassWatchReport)
+}
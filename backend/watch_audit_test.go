@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.hasen.dev/vbeam"
+	"go.hasen.dev/vbolt"
+)
+
+func TestPackCodeWatchRecord(t *testing.T) {
+	db := setupTestCodeDB(t)
+	defer db.Close()
+
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		now := time.Now().Truncate(time.Second)
+		original := CodeWatchRecord{
+			Id:             1,
+			Code:           "12345",
+			CodeLabel:      "Family",
+			SessionToken:   "session-token",
+			DeviceId:       "device-a",
+			ClientIP:       "10.0.0.1",
+			StudioId:       2,
+			RoomId:         3,
+			ScheduleId:     4,
+			ConnectedAt:    now,
+			DisconnectedAt: now.Add(time.Hour),
+			WatchSeconds:   3000,
+		}
+		vbolt.Write(tx, CodeWatchRecordsBkt, original.Id, &original)
+
+		var retrieved CodeWatchRecord
+		vbolt.Read(tx, CodeWatchRecordsBkt, original.Id, &retrieved)
+		if retrieved.SessionToken != original.SessionToken || retrieved.ScheduleId != 4 || retrieved.WatchSeconds != 3000 {
+			t.Errorf("Record mismatch: got %+v", retrieved)
+		}
+		if retrieved.connectedSeconds(now) != 3600 {
+			t.Errorf("Expected 3600 connected seconds, got %d", retrieved.connectedSeconds(now))
+		}
+	})
+}
+
+func TestCodeWatchAudit(t *testing.T) {
+	db := setupTestTicketingDB(t)
+	defer db.Close()
+	setupTestTicketingGlobals(t, db)
+
+	admin, _, room := createTestTicketAdmin(t, db)
+
+	var schedule ClassSchedule
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		schedule = createTestCodeSchedule(tx, room, "Ballet 3", time.Now().Add(-30*time.Minute))
+		vbolt.TxCommit(tx)
+	})
+
+	accessCode := createTestDeviceBoundCode(t, db, room.Id, 0)
+	device := CodeDeviceInfo{DeviceId: strings.Repeat("a", 43), ClientIP: "10.0.0.1"}
+	validateResp, err := validateAccessCodeLogicForDevice(db, accessCode.Code, device)
+	if err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+
+	var session CodeSession
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		vbolt.Read(tx, CodeSessionsBkt, validateResp.SessionToken, &session)
+	})
+
+	// Connect, back-date the connection by 10 minutes, then disconnect
+	recordId := startCodeWatchRecord(db, room.Id, session)
+	if recordId == 0 {
+		t.Fatalf("Expected watch record to be created")
+	}
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		var record CodeWatchRecord
+		vbolt.Read(tx, CodeWatchRecordsBkt, recordId, &record)
+		record.ConnectedAt = record.ConnectedAt.Add(-10 * time.Minute)
+		vbolt.Write(tx, CodeWatchRecordsBkt, record.Id, &record)
+		vbolt.TxCommit(tx)
+	})
+	endCodeWatchRecord(db, recordId)
+
+	// The player reports watch time as the code session
+	sessionJWT, _ := createCodeSessionToken(session.Token, accessCode.ExpiresAt)
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: sessionJWT}
+		_, err = ReportStreamMetrics(ctx, ReportStreamMetricsRequest{
+			Metrics: StreamMetrics{RoomId: room.Id, StartupSucceeded: true, WatchSeconds: 540},
+		})
+	})
+	if err != nil {
+		t.Fatalf("ReportStreamMetrics failed: %v", err)
+	}
+
+	adminToken, _ := createTestToken(admin.Id)
+
+	var codeReport GetCodeWatchReportResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: adminToken}
+		codeReport, err = GetCodeWatchReport(ctx, GetCodeWatchReportRequest{Code: accessCode.Code})
+	})
+	if err != nil {
+		t.Fatalf("GetCodeWatchReport failed: %v", err)
+	}
+	if codeReport.Summary.Sessions != 1 || len(codeReport.Sessions) != 1 {
+		t.Fatalf("Expected 1 session, got %+v", codeReport)
+	}
+	row := codeReport.Sessions[0]
+	if row.ConnectedSeconds < 600 || row.ConnectedSeconds > 610 {
+		t.Errorf("Expected ~600 connected seconds, got %d", row.ConnectedSeconds)
+	}
+	if row.WatchSeconds != 540 {
+		t.Errorf("Expected 540 watch seconds, got %d", row.WatchSeconds)
+	}
+	if row.IsConnected || row.ClientIP != "10.0.0.xxx" {
+		t.Errorf("Unexpected session row: %+v", row)
+	}
+
+	var classReport GetClassWatchReportResponse
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: adminToken}
+		classReport, err = GetClassWatchReport(ctx, GetClassWatchReportRequest{ScheduleId: schedule.Id})
+	})
+	if err != nil {
+		t.Fatalf("GetClassWatchReport failed: %v", err)
+	}
+	if len(classReport.Codes) != 1 || classReport.Codes[0].Code != accessCode.Code || classReport.Codes[0].Sessions != 1 {
+		t.Errorf("Expected class report for code %s, got %+v", accessCode.Code, classReport.Codes)
+	}
+
+	// The report survives the code being cleaned up
+	vbolt.WithWriteTx(db, func(tx *vbolt.Tx) {
+		code := accessCode
+		code.ExpiresAt = time.Now().Add(-60 * 24 * time.Hour)
+		vbolt.Write(tx, AccessCodesBkt, code.Code, &code)
+		vbolt.TxCommit(tx)
+	})
+	if cleaned := CleanupOldAccessCodes(db, 30); cleaned != 1 {
+		t.Fatalf("Expected 1 code cleaned up, got %d", cleaned)
+	}
+
+	vbolt.WithReadTx(db, func(tx *vbolt.Tx) {
+		ctx := &vbeam.Context{Tx: tx, Token: adminToken}
+		codeReport, err = GetCodeWatchReport(ctx, GetCodeWatchReportRequest{Code: accessCode.Code})
+	})
+	if err != nil {
+		t.Fatalf("GetCodeWatchReport after cleanup failed: %v", err)
+	}
+	if !codeReport.CodeDeleted || codeReport.Label != "Family" || codeReport.Summary.WatchSeconds != 540 {
+		t.Errorf("Expected retained report for deleted code, got %+v", codeReport)
+	}
+
+	// CSV export
+	req := httptest.NewRequest("GET", "/api/watch-report/export?code="+accessCode.Code, nil)
+	req.Header.Set("Cookie", "authToken="+adminToken)
+	w := httptest.NewRecorder()
+	watchReportExportHandler(w, req)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "code,label,session") {
+		t.Fatalf("Expected header and 1 row, got %q", w.Body.String())
+	}
+	if !strings.Contains(lines[1], accessCode.Code+",Family,") || !strings.HasSuffix(lines[1], ",540") {
+		t.Errorf("Unexpected CSV row: %s", lines[1])
+	}
+}
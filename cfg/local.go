@@ -8,4 +8,5 @@ const StaticDir = ".serve/static/"
 const SiteURL = "http://localhost:3000"
 const SiteRoot = "localhost"
 const HLSBaseDir = ".serve/hls"
+const EmoteDir = ".serve/emotes"
 const SRSRTMPBase = "rtmp://localhost:1935/live"
@@ -8,4 +8,5 @@ const StaticDir = "static/"
 const SiteURL = "https://releve.live"
 const SiteRoot = "releve.live"
 const HLSBaseDir = "/var/www/hls"
+const EmoteDir = "/srv/apps/releve/shared/emotes"
 const SRSRTMPBase = "rtmp://localhost:1935/live"
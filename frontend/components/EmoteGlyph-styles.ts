@@ -0,0 +1,10 @@
+import { block } from "vlens/css";
+
+// Uploaded emote images scale with the surrounding text
+block(`
+.emote-image {
+  height: 1.25em;
+  width: auto;
+  vertical-align: middle;
+}
+`);
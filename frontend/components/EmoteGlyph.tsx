@@ -0,0 +1,24 @@
+import * as preact from "preact";
+import * as server from "../server";
+import "./EmoteGlyph-styles";
+
+export type EmoteGlyphProps = {
+  code: string;
+  emotes: server.StudioEmote[]; // The studio's set, for image emotes
+};
+
+// Shows an emote: uploaded images as <img>, emoji as text
+export function EmoteGlyph(props: EmoteGlyphProps): preact.ComponentChild {
+  const emote = props.emotes.find((e) => e.code === props.code);
+  if (emote && emote.imageUrl) {
+    return (
+      <img
+        className="emote-image"
+        src={emote.imageUrl}
+        alt={emote.code}
+        title={emote.code}
+      />
+    );
+  }
+  return props.code;
+}
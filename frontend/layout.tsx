@@ -87,6 +87,9 @@ const ViewerHeaderNav = (): preact.ComponentChild => (
     <a href="/dashboard" className="nav-link">
       Streams
     </a>
+    <a href="/security" className="nav-link">
+      Security
+    </a>
     <button onClick={handleLogout} className="logout-button">
       Logout
     </button>
@@ -98,6 +101,9 @@ const StudioManagerHeaderNav = (): preact.ComponentChild => (
     <a href="/studios" className="nav-link">
       Studios
     </a>
+    <a href="/security" className="nav-link">
+      Security
+    </a>
     <button onClick={handleLogout} className="logout-button">
       Logout
     </button>
@@ -112,6 +118,9 @@ const SiteAdminHeaderNav = (): preact.ComponentChild => (
     <a href="/site-admin" className="nav-link">
       Admin
     </a>
+    <a href="/security" className="nav-link">
+      Security
+    </a>
     <button onClick={handleLogout} className="logout-button">
       Logout
     </button>
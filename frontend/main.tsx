@@ -9,6 +9,27 @@ async function main() {
       "/create-account",
       () => import("@app/pages/auth/create-account"),
     ),
+    vlens.routeHandler(
+      "/forgot-password",
+      () => import("@app/pages/auth/forgot-password"),
+    ),
+    vlens.routeHandler(
+      "/reset-password",
+      () => import("@app/pages/auth/reset-password"),
+    ),
+    vlens.routeHandler(
+      "/verify-email",
+      () => import("@app/pages/auth/verify-email"),
+    ),
+    vlens.routeHandler(
+      "/two-factor",
+      () => import("@app/pages/auth/two-factor"),
+    ),
+    vlens.routeHandler("/security", () => import("@app/pages/auth/security")),
+    vlens.routeHandler(
+      "/studio-invite",
+      () => import("@app/pages/auth/studio-invite"),
+    ),
     vlens.routeHandler(
       "/dashboard",
       () => import("@app/pages/dashboard/dashboard"),
@@ -29,6 +50,7 @@ async function main() {
     ),
     vlens.routeHandler("/stream", () => import("@app/pages/stream/stream")),
     vlens.routeHandler("/watch", () => import("@app/pages/watch/watch")),
+    vlens.routeHandler("/invite", () => import("@app/pages/invite/invite")),
     vlens.routeHandler("/", () => import("@app/pages/home/home")),
   ]);
 }
@@ -52,19 +52,31 @@ export function view(
   }
 
   const form = useCreateAccountForm();
+  // Studio invitation links send people here as /create-account/<token>
+  const inviteToken = extractTokenFromRoute(route, prefix);
 
   return (
     <div>
       <Header />
       <main className="create-account-container">
-        <CreateAccountPage form={form} />
+        <CreateAccountPage form={form} inviteToken={inviteToken} />
       </main>
       <Footer />
     </div>
   );
 }
 
-async function onCreateAccountClicked(form: CreateAccountForm, event: Event) {
+function extractTokenFromRoute(route: string, prefix: string): string {
+  const afterPrefix = route.substring(prefix.length);
+  const parts = afterPrefix.split("/").filter((p) => p.length > 0);
+  return parts.length > 0 ? parts[0] : "";
+}
+
+async function onCreateAccountClicked(
+  form: CreateAccountForm,
+  inviteToken: string,
+  event: Event,
+) {
   event.preventDefault();
   form.loading = true;
   form.error = "";
@@ -91,6 +103,7 @@ async function onCreateAccountClicked(form: CreateAccountForm, event: Event) {
     email: form.email,
     password: form.password,
     confirmPassword: form.confirmPassword,
+    inviteToken: inviteToken,
   });
 
   form.loading = false;
@@ -127,9 +140,10 @@ function onGoogleSignup() {
 
 interface CreateAccountPageProps {
   form: CreateAccountForm;
+  inviteToken: string;
 }
 
-const CreateAccountPage = ({ form }: CreateAccountPageProps) => (
+const CreateAccountPage = ({ form, inviteToken }: CreateAccountPageProps) => (
   <div className="create-account-page">
     <div className="auth-card">
       <div className="auth-header">
@@ -155,7 +169,11 @@ const CreateAccountPage = ({ form }: CreateAccountPageProps) => (
 
         <form
           className="auth-form"
-          onSubmit={vlens.cachePartial(onCreateAccountClicked, form)}
+          onSubmit={vlens.cachePartial(
+            onCreateAccountClicked,
+            form,
+            inviteToken,
+          )}
         >
           <div className="form-group">
             <label htmlFor="name">Full Name</label>
@@ -0,0 +1,115 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as rpc from "vlens/rpc";
+import * as server from "../../server";
+import { Header, Footer } from "../../layout";
+import "../../styles/global";
+import "./login-styles";
+
+type Data = {};
+
+type ForgotPasswordForm = {
+  email: string;
+  error: string;
+  loading: boolean;
+  sent: boolean;
+};
+
+const useForgotPasswordForm = vlens.declareHook(
+  (): ForgotPasswordForm => ({
+    email: "",
+    error: "",
+    loading: false,
+    sent: false,
+  }),
+);
+
+export async function fetch(route: string, prefix: string) {
+  return rpc.ok<Data>({});
+}
+
+export function view(
+  route: string,
+  prefix: string,
+  data: Data,
+): preact.ComponentChild {
+  const form = useForgotPasswordForm();
+  return (
+    <div>
+      <Header />
+      <main className="login-container">
+        <div className="login-page">
+          <div className="auth-card">
+            <div className="auth-header">
+              <h1>Reset Password</h1>
+              <p>We'll email you a link to choose a new password</p>
+            </div>
+
+            {form.error && <div className="error-message">{form.error}</div>}
+
+            {form.sent ? (
+              <div className="success-message">
+                If an account exists for {form.email}, a reset link is on its
+                way. The link expires in 1 hour.
+              </div>
+            ) : (
+              <form
+                className="auth-form"
+                onSubmit={vlens.cachePartial(onSubmit, form)}
+              >
+                <div className="form-group">
+                  <label htmlFor="email">Email Address</label>
+                  <input
+                    type="email"
+                    id="email"
+                    placeholder="Enter your email"
+                    {...vlens.attrsBindInput(vlens.ref(form, "email"))}
+                    required
+                    disabled={form.loading}
+                  />
+                </div>
+
+                <button
+                  type="submit"
+                  className="btn btn-primary btn-large auth-submit"
+                  disabled={form.loading}
+                >
+                  {form.loading ? "Sending..." : "Send Reset Link"}
+                </button>
+              </form>
+            )}
+
+            <div className="auth-footer">
+              <p>
+                Remembered it?
+                <a href="/login" className="auth-link">
+                  Sign in
+                </a>
+              </p>
+            </div>
+          </div>
+        </div>
+      </main>
+      <Footer />
+    </div>
+  );
+}
+
+async function onSubmit(form: ForgotPasswordForm, event: Event) {
+  event.preventDefault();
+  form.loading = true;
+  form.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.RequestPasswordReset({
+    email: form.email,
+  });
+
+  form.loading = false;
+  if (err || !resp) {
+    form.error = err || "Failed to send reset link";
+  } else {
+    form.sent = true;
+  }
+  vlens.scheduleRedraw();
+}
@@ -128,6 +128,81 @@ block(`
 }
 `);
 
+block(`
+.totp-secret,
+.recovery-codes {
+  display: block;
+  padding: 12px;
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  background: var(--surface);
+  font-family: monospace;
+  font-size: 1rem;
+  letter-spacing: 0.05em;
+  word-break: break-all;
+}
+`);
+
+block(`
+.recovery-codes {
+  margin: 0;
+  columns: 2;
+  text-align: center;
+}
+`);
+
+block(`
+.auth-card + .auth-card {
+  margin-top: 24px;
+}
+`);
+
+block(`
+.session-list {
+  list-style: none;
+  margin: 0 0 20px;
+  padding: 0;
+  display: flex;
+  flex-direction: column;
+  gap: 12px;
+}
+`);
+
+block(`
+.session-item {
+  padding: 12px;
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  display: flex;
+  flex-direction: column;
+  gap: 6px;
+  align-items: flex-start;
+}
+`);
+
+block(`
+.session-device {
+  display: flex;
+  gap: 8px;
+  align-items: center;
+  word-break: break-word;
+}
+`);
+
+block(`
+.session-current {
+  font-size: 0.8rem;
+  color: var(--accent);
+}
+`);
+
+block(`
+.session-meta {
+  font-size: 0.85rem;
+  color: var(--text-secondary);
+}
+`);
+
 block(`
 @media (max-width: 480px) {
   .login-container {
@@ -9,6 +9,7 @@ import "./login-styles";
 
 type Data = {
   authId: number;
+  providers: server.LoginProvider[];
 };
 
 type LoginForm = {
@@ -32,9 +33,11 @@ const useLoginForm = vlens.declareHook(
 export async function fetch(route: string, prefix: string) {
   // Check if user is already authenticated
   let [authResp, authErr] = await server.GetAuthContext({});
+  let [providersResp, providersErr] = await server.ListLoginProviders({});
 
   return rpc.ok<Data>({
     authId: authResp?.id || 0,
+    providers: providersResp?.providers || [],
   });
 }
 
@@ -54,7 +57,7 @@ export function view(
     <div>
       <Header />
       <main className="login-container">
-        <LoginPage form={form} />
+        <LoginPage form={form} providers={data.providers} />
       </main>
       <Footer />
     </div>
@@ -93,7 +96,10 @@ async function onLoginClicked(form: LoginForm, event: Event) {
     const result = await res.json();
     form.loading = false;
 
-    if (result.token && result.auth) {
+    if (result.twoFactorRequired) {
+      // Password accepted; the code (or 2FA setup) is the next step
+      window.location.href = "/two-factor";
+    } else if (result.token && result.auth) {
       // Redirect to dashboard on success
       window.location.href = "/dashboard";
     } else {
@@ -122,11 +128,17 @@ function onGoogleLogin() {
   window.location.href = "/api/login/google";
 }
 
+function onProviderLogin(name: string) {
+  // Redirect to the generic OpenID Connect endpoint
+  window.location.href = `/api/login/oidc?provider=${encodeURIComponent(name)}`;
+}
+
 interface LoginPageProps {
   form: LoginForm;
+  providers: server.LoginProvider[];
 }
 
-const LoginPage = ({ form }: LoginPageProps) => (
+const LoginPage = ({ form, providers }: LoginPageProps) => (
   <div className="login-page">
     <div className="auth-card">
       <div className="auth-header">
@@ -146,6 +158,17 @@ const LoginPage = ({ form }: LoginPageProps) => (
           Continue with Google
         </button>
 
+        {providers.map((provider) => (
+          <button
+            key={provider.name}
+            className="btn btn-google"
+            disabled={form.loading}
+            onClick={() => onProviderLogin(provider.name)}
+          >
+            Continue with {provider.displayName}
+          </button>
+        ))}
+
         <div className="auth-divider">
           <span>or</span>
         </div>
@@ -187,6 +210,9 @@ const LoginPage = ({ form }: LoginPageProps) => (
               />
               <span className="checkbox-text">Remember me</span>
             </label>
+            <a href="/forgot-password" className="auth-link">
+              Forgot password?
+            </a>
           </div>
 
           <button
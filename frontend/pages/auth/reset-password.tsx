@@ -0,0 +1,149 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as rpc from "vlens/rpc";
+import * as server from "../../server";
+import { Header, Footer } from "../../layout";
+import "../../styles/global";
+import "./login-styles";
+
+type Data = {};
+
+type ResetPasswordForm = {
+  password: string;
+  confirmPassword: string;
+  error: string;
+  loading: boolean;
+  done: boolean;
+};
+
+const useResetPasswordForm = vlens.declareHook(
+  (routeKey: string): ResetPasswordForm => ({
+    password: "",
+    confirmPassword: "",
+    error: "",
+    loading: false,
+    done: false,
+  }),
+);
+
+export async function fetch(route: string, prefix: string) {
+  return rpc.ok<Data>({});
+}
+
+export function view(
+  route: string,
+  prefix: string,
+  data: Data,
+): preact.ComponentChild {
+  // Extract token from URL (e.g., /reset-password/<token>)
+  const token = extractTokenFromRoute(route, prefix);
+  const form = useResetPasswordForm(route);
+
+  return (
+    <div>
+      <Header />
+      <main className="login-container">
+        <div className="login-page">
+          <div className="auth-card">
+            <div className="auth-header">
+              <h1>Choose a New Password</h1>
+              <p>You'll be signed out of all other devices</p>
+            </div>
+
+            {!token && (
+              <div className="error-message">
+                This reset link is incomplete.
+              </div>
+            )}
+            {form.error && <div className="error-message">{form.error}</div>}
+
+            {form.done ? (
+              <div className="success-message">
+                Your password has been changed.{" "}
+                <a href="/login" className="auth-link">
+                  Sign in
+                </a>
+              </div>
+            ) : (
+              <form
+                className="auth-form"
+                onSubmit={vlens.cachePartial(onSubmit, form, token)}
+              >
+                <div className="form-group">
+                  <label htmlFor="password">New Password</label>
+                  <input
+                    type="password"
+                    id="password"
+                    placeholder="At least 8 characters"
+                    {...vlens.attrsBindInput(vlens.ref(form, "password"))}
+                    required
+                    disabled={form.loading || !token}
+                  />
+                </div>
+
+                <div className="form-group">
+                  <label htmlFor="confirmPassword">Confirm Password</label>
+                  <input
+                    type="password"
+                    id="confirmPassword"
+                    placeholder="Repeat your new password"
+                    {...vlens.attrsBindInput(
+                      vlens.ref(form, "confirmPassword"),
+                    )}
+                    required
+                    disabled={form.loading || !token}
+                  />
+                </div>
+
+                <button
+                  type="submit"
+                  className="btn btn-primary btn-large auth-submit"
+                  disabled={form.loading || !token}
+                >
+                  {form.loading ? "Saving..." : "Set Password"}
+                </button>
+              </form>
+            )}
+
+            <div className="auth-footer">
+              <p>
+                Link expired?
+                <a href="/forgot-password" className="auth-link">
+                  Request a new one
+                </a>
+              </p>
+            </div>
+          </div>
+        </div>
+      </main>
+      <Footer />
+    </div>
+  );
+}
+
+function extractTokenFromRoute(route: string, prefix: string): string {
+  const afterPrefix = route.substring(prefix.length);
+  const parts = afterPrefix.split("/").filter((p) => p.length > 0);
+  return parts.length > 0 ? parts[0] : "";
+}
+
+async function onSubmit(form: ResetPasswordForm, token: string, event: Event) {
+  event.preventDefault();
+  form.loading = true;
+  form.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.ResetPassword({
+    token: token,
+    password: form.password,
+    confirmPassword: form.confirmPassword,
+  });
+
+  form.loading = false;
+  if (err || !resp) {
+    form.error = err || "Failed to reset password";
+  } else {
+    form.done = true;
+  }
+  vlens.scheduleRedraw();
+}
@@ -0,0 +1,517 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as rpc from "vlens/rpc";
+import * as core from "vlens/core";
+import * as server from "../../server";
+import { Header, Footer } from "../../layout";
+import "../../styles/global";
+import "./login-styles";
+
+type OwnedStudio = {
+  studio: server.StudioWithRole;
+  members: server.MemberWithDetails[]; // everyone but the caller
+};
+
+type Data = {
+  authId: number;
+  status: server.TwoFactorStatusResponse | null;
+  sessions: server.DeviceSession[];
+  ownedStudios: OwnedStudio[];
+  error: string;
+};
+
+type SecurityState = {
+  code: string;
+  secret: string;
+  otpauthUrl: string;
+  recoveryCodes: string[];
+  enabled: boolean | null; // overrides the fetched status after a change
+  revokedSessions: number[];
+  deletePassword: string;
+  deleteEmail: string;
+  newOwners: Record<number, number>; // studioId -> new owner's userId
+  error: string;
+  loading: boolean;
+};
+
+const useSecurityState = vlens.declareHook(
+  (): SecurityState => ({
+    code: "",
+    secret: "",
+    otpauthUrl: "",
+    recoveryCodes: [],
+    enabled: null,
+    revokedSessions: [],
+    deletePassword: "",
+    deleteEmail: "",
+    newOwners: {},
+    error: "",
+    loading: false,
+  }),
+);
+
+export async function fetch(route: string, prefix: string) {
+  let [authResp, authErr] = await server.GetAuthContext({});
+  if (!authResp || authResp.id <= 0) {
+    return rpc.ok<Data>({
+      authId: 0,
+      status: null,
+      sessions: [],
+      ownedStudios: [],
+      error: "",
+    });
+  }
+
+  let [statusResp, statusErr] = await server.GetTwoFactorStatus({});
+  let [sessionsResp, sessionsErr] = await server.ListSessions({});
+  let [studiosResp, studiosErr] = await server.ListMyStudios({});
+
+  // Owned studios need a new owner before the account can be deleted
+  const ownedStudios: OwnedStudio[] = [];
+  for (const studio of studiosResp?.studios || []) {
+    if (studio.ownerId !== authResp.id) {
+      continue;
+    }
+    let [membersResp] = await server.ListStudioMembersAPI({
+      studioId: studio.id,
+    });
+    ownedStudios.push({
+      studio,
+      members: (membersResp?.members || []).filter(
+        (m) => m.userId !== authResp.id,
+      ),
+    });
+  }
+
+  return rpc.ok<Data>({
+    authId: authResp.id,
+    status: statusResp || null,
+    sessions: sessionsResp?.sessions || [],
+    ownedStudios,
+    error: statusErr || sessionsErr || studiosErr || "",
+  });
+}
+
+export function view(
+  route: string,
+  prefix: string,
+  data: Data,
+): preact.ComponentChild {
+  if (data.authId <= 0) {
+    core.setRoute("/login");
+    return <div></div>;
+  }
+
+  const state = useSecurityState();
+  const status = data.status;
+  const enabled = state.enabled ?? status?.enabled ?? false;
+
+  let content: preact.ComponentChild;
+  if (state.recoveryCodes.length > 0) {
+    content = (
+      <div className="auth-methods">
+        <div className="success-message">
+          Save these recovery codes somewhere safe. Each one can be used once
+          if you lose your device, and they won't be shown again.
+        </div>
+        <pre className="recovery-codes">{state.recoveryCodes.join("\n")}</pre>
+        <button
+          className="btn btn-primary btn-large auth-submit"
+          onClick={() => {
+            state.recoveryCodes = [];
+            vlens.scheduleRedraw();
+          }}
+        >
+          Done
+        </button>
+      </div>
+    );
+  } else if (enabled) {
+    content = (
+      <form className="auth-form" onSubmit={(e) => e.preventDefault()}>
+        <div className="success-message">
+          Two-factor authentication is on.
+          {status && state.enabled === null && (
+            <> {status.recoveryCodesLeft} recovery codes left.</>
+          )}
+        </div>
+        <CodeInput state={state} label="Current Code" />
+        <button
+          type="button"
+          className="btn btn-primary btn-large auth-submit"
+          disabled={state.loading}
+          onClick={() => onRegenerate(state)}
+        >
+          New Recovery Codes
+        </button>
+        {status?.required ? (
+          <p>Your role requires two-factor authentication.</p>
+        ) : (
+          <button
+            type="button"
+            className="btn btn-secondary"
+            disabled={state.loading}
+            onClick={() => onDisable(state)}
+          >
+            Turn Off
+          </button>
+        )}
+      </form>
+    );
+  } else if (state.secret) {
+    content = (
+      <form
+        className="auth-form"
+        onSubmit={vlens.cachePartial(onConfirm, state)}
+      >
+        <div className="form-group">
+          <p>
+            Add this key to an authenticator app, then enter the 6-digit code it
+            shows.
+          </p>
+          <code className="totp-secret">{state.secret}</code>
+          <a href={state.otpauthUrl} className="auth-link">
+            Open in authenticator app
+          </a>
+        </div>
+        <CodeInput state={state} label="Authentication Code" />
+        <button
+          type="submit"
+          className="btn btn-primary btn-large auth-submit"
+          disabled={state.loading}
+        >
+          {state.loading ? "Verifying..." : "Turn On"}
+        </button>
+      </form>
+    );
+  } else {
+    content = (
+      <div className="auth-methods">
+        <p>
+          Protect your account with a code from an authenticator app each time
+          you sign in.
+        </p>
+        <button
+          className="btn btn-primary btn-large auth-submit"
+          disabled={state.loading}
+          onClick={() => onBeginSetup(state)}
+        >
+          Set Up Two-Factor Authentication
+        </button>
+      </div>
+    );
+  }
+
+  return (
+    <div>
+      <Header />
+      <main className="login-container">
+        <div className="login-page">
+          <div className="auth-card">
+            <div className="auth-header">
+              <h1>Account Security</h1>
+              <p>Two-factor authentication</p>
+            </div>
+
+            {(state.error || data.error) && (
+              <div className="error-message">{state.error || data.error}</div>
+            )}
+            {content}
+          </div>
+
+          <div className="auth-card">
+            <div className="auth-header">
+              <h1>Signed-in Devices</h1>
+              <p>Sign out anywhere you don't recognise</p>
+            </div>
+
+            <ul className="session-list">
+              {data.sessions
+                .filter((s) => !state.revokedSessions.includes(s.id))
+                .map((session) => (
+                  <li key={session.id} className="session-item">
+                    <div className="session-device">
+                      <strong>{session.userAgent || "Unknown device"}</strong>
+                      {session.current && (
+                        <span className="session-current">This device</span>
+                      )}
+                    </div>
+                    <div className="session-meta">
+                      {session.ipAddress} · last active{" "}
+                      {new Date(session.lastUsedAt).toLocaleString()}
+                    </div>
+                    {!session.current && (
+                      <button
+                        className="btn btn-secondary btn-sm"
+                        disabled={state.loading}
+                        onClick={() => onRevokeSession(state, session.id)}
+                      >
+                        Sign Out
+                      </button>
+                    )}
+                  </li>
+                ))}
+            </ul>
+
+            <button
+              className="btn btn-secondary auth-submit"
+              disabled={state.loading}
+              onClick={() => onSignOutEverywhere(state)}
+            >
+              Sign Out Everywhere
+            </button>
+          </div>
+
+          <div className="auth-card">
+            <div className="auth-header">
+              <h1>Your Data</h1>
+              <p>Download a copy or delete your account</p>
+            </div>
+
+            <button
+              className="btn btn-secondary auth-submit"
+              disabled={state.loading}
+              onClick={() => onExportData(state)}
+            >
+              Export My Data
+            </button>
+
+            <form
+              className="auth-form"
+              onSubmit={vlens.cachePartial(onDeleteAccount, state, data)}
+            >
+              <p>
+                Deleting your account removes your studio memberships and
+                signs you out everywhere. Your chat messages stay in room
+                history as "Deleted User".
+              </p>
+              {data.ownedStudios.map(({ studio, members }) => (
+                <div key={studio.id} className="form-group">
+                  <label htmlFor={`new-owner-${studio.id}`}>
+                    New owner of {studio.name}
+                  </label>
+                  {members.length > 0 ? (
+                    <select
+                      id={`new-owner-${studio.id}`}
+                      value={state.newOwners[studio.id] || 0}
+                      onChange={(e) => {
+                        const target = e.target as HTMLSelectElement;
+                        state.newOwners[studio.id] = Number(target.value);
+                        vlens.scheduleRedraw();
+                      }}
+                      disabled={state.loading}
+                    >
+                      <option value={0}>Choose a member</option>
+                      {members.map((m) => (
+                        <option key={m.userId} value={m.userId}>
+                          {m.userName} ({m.userEmail})
+                        </option>
+                      ))}
+                    </select>
+                  ) : (
+                    <p>
+                      {studio.name} has no other members. Delete the studio
+                      first.
+                    </p>
+                  )}
+                </div>
+              ))}
+              <div className="form-group">
+                <label htmlFor="delete-password">Password</label>
+                <input
+                  type="password"
+                  id="delete-password"
+                  autoComplete="current-password"
+                  {...vlens.attrsBindInput(vlens.ref(state, "deletePassword"))}
+                  disabled={state.loading}
+                />
+              </div>
+              <div className="form-group">
+                <label htmlFor="delete-email">Type your email to confirm</label>
+                <input
+                  type="email"
+                  id="delete-email"
+                  {...vlens.attrsBindInput(vlens.ref(state, "deleteEmail"))}
+                  disabled={state.loading}
+                />
+              </div>
+              <button
+                type="submit"
+                className="btn btn-danger auth-submit"
+                disabled={state.loading}
+              >
+                Delete My Account
+              </button>
+            </form>
+          </div>
+        </div>
+      </main>
+      <Footer />
+    </div>
+  );
+}
+
+const CodeInput = ({
+  state,
+  label,
+}: {
+  state: SecurityState;
+  label: string;
+}) => (
+  <div className="form-group">
+    <label htmlFor="code">{label}</label>
+    <input
+      type="text"
+      id="code"
+      autoComplete="one-time-code"
+      placeholder="123456"
+      {...vlens.attrsBindInput(vlens.ref(state, "code"))}
+      disabled={state.loading}
+    />
+  </div>
+);
+
+function startRequest(state: SecurityState) {
+  state.loading = true;
+  state.error = "";
+  vlens.scheduleRedraw();
+}
+
+async function onBeginSetup(state: SecurityState) {
+  startRequest(state);
+  const [resp, err] = await server.BeginTwoFactorSetup({});
+  state.loading = false;
+  if (resp) {
+    state.secret = resp.secret;
+    state.otpauthUrl = resp.otpauthUrl;
+  } else {
+    state.error = err || "Failed to start setup";
+  }
+  vlens.scheduleRedraw();
+}
+
+async function onConfirm(state: SecurityState, event: Event) {
+  event.preventDefault();
+  startRequest(state);
+  const [resp, err] = await server.ConfirmTwoFactorSetup({ code: state.code });
+  state.loading = false;
+  if (resp) {
+    state.enabled = true;
+    state.secret = "";
+    state.code = "";
+    state.recoveryCodes = resp.recoveryCodes;
+  } else {
+    state.error = err || "Invalid code";
+  }
+  vlens.scheduleRedraw();
+}
+
+async function onRegenerate(state: SecurityState) {
+  startRequest(state);
+  const [resp, err] = await server.RegenerateRecoveryCodes({
+    code: state.code,
+  });
+  state.loading = false;
+  if (resp) {
+    state.code = "";
+    state.recoveryCodes = resp.recoveryCodes;
+  } else {
+    state.error = err || "Invalid code";
+  }
+  vlens.scheduleRedraw();
+}
+
+async function onDisable(state: SecurityState) {
+  if (!confirm("Turn off two-factor authentication?")) {
+    return;
+  }
+  startRequest(state);
+  const [resp, err] = await server.DisableTwoFactor({ code: state.code });
+  state.loading = false;
+  if (resp) {
+    state.enabled = false;
+    state.code = "";
+  } else {
+    state.error = err || "Invalid code";
+  }
+  vlens.scheduleRedraw();
+}
+
+async function onRevokeSession(state: SecurityState, sessionId: number) {
+  startRequest(state);
+  const [resp, err] = await server.RevokeSession({ sessionId });
+  state.loading = false;
+  if (resp) {
+    state.revokedSessions.push(sessionId);
+  } else {
+    state.error = err || "Failed to sign out device";
+  }
+  vlens.scheduleRedraw();
+}
+
+async function onSignOutEverywhere(state: SecurityState) {
+  if (!confirm("Sign out of every device, including this one?")) {
+    return;
+  }
+  startRequest(state);
+  const [resp, err] = await server.SignOutEverywhere({});
+  if (!resp) {
+    state.loading = false;
+    state.error = err || "Failed to sign out";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  // Clear this browser's cookies too
+  const nativeFetch = window.fetch.bind(window);
+  await nativeFetch("/api/logout", { method: "POST" });
+  window.location.href = "/login";
+}
+
+async function onExportData(state: SecurityState) {
+  startRequest(state);
+  const [resp, err] = await server.ExportAccountData({});
+  state.loading = false;
+  if (!resp) {
+    state.error = err || "Failed to export data";
+    vlens.scheduleRedraw();
+    return;
+  }
+  vlens.scheduleRedraw();
+
+  const blob = new Blob([JSON.stringify(resp, null, 2)], {
+    type: "application/json",
+  });
+  const url = URL.createObjectURL(blob);
+  const link = document.createElement("a");
+  link.href = url;
+  link.download = "account-data.json";
+  link.click();
+  URL.revokeObjectURL(url);
+}
+
+async function onDeleteAccount(state: SecurityState, data: Data, event: Event) {
+  event.preventDefault();
+  if (!confirm("Permanently delete your account? This can't be undone.")) {
+    return;
+  }
+  startRequest(state);
+  const [resp, err] = await server.DeleteAccount({
+    password: state.deletePassword,
+    confirmEmail: state.deleteEmail,
+    transfers: data.ownedStudios.map(({ studio }) => ({
+      studioId: studio.id,
+      newOwnerId: state.newOwners[studio.id] || 0,
+    })),
+  });
+  if (!resp) {
+    state.loading = false;
+    state.error = err || "Failed to delete account";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  const nativeFetch = window.fetch.bind(window);
+  await nativeFetch("/api/logout", { method: "POST" });
+  window.location.href = "/";
+}
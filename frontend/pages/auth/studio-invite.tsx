@@ -0,0 +1,177 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as rpc from "vlens/rpc";
+import * as core from "vlens/core";
+import * as server from "../../server";
+import { Header, Footer } from "../../layout";
+import "../../styles/global";
+import "./login-styles";
+
+type Data = {
+  authId: number;
+  authEmail: string;
+  token: string;
+  invitation: server.GetStudioInvitationResponse | null;
+  error: string;
+};
+
+type RespondState = {
+  loading: boolean;
+  declined: boolean;
+  error: string;
+};
+
+const useRespondState = vlens.declareHook(
+  (routeKey: string): RespondState => ({
+    loading: false,
+    declined: false,
+    error: "",
+  }),
+);
+
+export async function fetch(route: string, prefix: string) {
+  // Extract token from URL (e.g., /studio-invite/<token>)
+  const token = extractTokenFromRoute(route, prefix);
+
+  let [authResp, authErr] = await server.GetAuthContext({});
+  let [inviteResp, inviteErr] = await server.GetStudioInvitation({ token });
+
+  return rpc.ok<Data>({
+    authId: authResp?.id || 0,
+    authEmail: authResp?.email || "",
+    token: token,
+    invitation: inviteResp || null,
+    error: inviteErr || "",
+  });
+}
+
+export function view(
+  route: string,
+  prefix: string,
+  data: Data,
+): preact.ComponentChild {
+  const state = useRespondState(route);
+  const invitation = data.invitation;
+  const signedIn = data.authId > 0;
+  const emailMatches =
+    signedIn &&
+    invitation !== null &&
+    data.authEmail.toLowerCase() === invitation.email;
+
+  let content: preact.ComponentChild;
+  if (!invitation) {
+    content = (
+      <div className="error-message">
+        {data.error || "This invitation is no longer valid"}
+      </div>
+    );
+  } else if (invitation.isExpired) {
+    content = (
+      <div className="error-message">
+        This invitation has expired. Ask the studio for a new one.
+      </div>
+    );
+  } else if (state.declined) {
+    content = (
+      <div className="success-message">You declined this invitation.</div>
+    );
+  } else if (!signedIn) {
+    content = (
+      <div className="auth-methods">
+        <a
+          href={`/create-account/${data.token}`}
+          className="btn btn-primary btn-large auth-submit"
+        >
+          Create Account
+        </a>
+        <p>
+          Sign up with {invitation.email} and you'll be added automatically.
+          Already have an account?
+          <a href="/login" className="auth-link">
+            Sign in
+          </a>{" "}
+          and open this link again.
+        </p>
+      </div>
+    );
+  } else if (!emailMatches) {
+    content = (
+      <div className="error-message">
+        This invitation was sent to {invitation.email}. Sign in with that
+        address to accept it.
+      </div>
+    );
+  } else {
+    content = (
+      <div className="auth-methods">
+        <button
+          className="btn btn-primary btn-large auth-submit"
+          disabled={state.loading}
+          onClick={() => respond(state, data.token, true)}
+        >
+          {state.loading ? "Joining..." : "Accept Invitation"}
+        </button>
+        <button
+          className="btn btn-secondary"
+          disabled={state.loading}
+          onClick={() => respond(state, data.token, false)}
+        >
+          Decline
+        </button>
+      </div>
+    );
+  }
+
+  return (
+    <div>
+      <Header />
+      <main className="login-container">
+        <div className="login-page">
+          <div className="auth-card">
+            <div className="auth-header">
+              <h1>Studio Invitation</h1>
+              {invitation && (
+                <p>
+                  You're invited to join {invitation.studioName} as a{" "}
+                  {invitation.roleName}
+                </p>
+              )}
+            </div>
+
+            {state.error && <div className="error-message">{state.error}</div>}
+            {content}
+          </div>
+        </div>
+      </main>
+      <Footer />
+    </div>
+  );
+}
+
+function extractTokenFromRoute(route: string, prefix: string): string {
+  const afterPrefix = route.substring(prefix.length);
+  const parts = afterPrefix.split("/").filter((p) => p.length > 0);
+  return parts.length > 0 ? parts[0] : "";
+}
+
+async function respond(state: RespondState, token: string, accept: boolean) {
+  state.loading = true;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  const req = { invitationId: 0, token: token };
+  const [resp, err] = accept
+    ? await server.AcceptStudioInvitation(req)
+    : await server.DeclineStudioInvitation(req);
+
+  state.loading = false;
+  if (err) {
+    state.error = err;
+  } else if (accept) {
+    core.setRoute("/studios");
+    return;
+  } else {
+    state.declined = true;
+  }
+  vlens.scheduleRedraw();
+}
@@ -0,0 +1,211 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as rpc from "vlens/rpc";
+import { Header, Footer } from "../../layout";
+import "../../styles/global";
+import "./login-styles";
+
+type Data = {
+  email: string;
+  setupRequired: boolean;
+  secret: string;
+  otpauthUrl: string;
+  error: string;
+};
+
+type TwoFactorForm = {
+  code: string;
+  error: string;
+  loading: boolean;
+  recoveryCodes: string[];
+};
+
+const useTwoFactorForm = vlens.declareHook(
+  (): TwoFactorForm => ({
+    code: "",
+    error: "",
+    loading: false,
+    recoveryCodes: [],
+  }),
+);
+
+export async function fetch(route: string, prefix: string) {
+  const data: Data = {
+    email: "",
+    setupRequired: false,
+    secret: "",
+    otpauthUrl: "",
+    error: "",
+  };
+
+  // The pending login lives in an HttpOnly cookie set by /api/login or the
+  // Google/OIDC callback
+  const nativeFetch = window.fetch.bind(window);
+  try {
+    const statusRes = await nativeFetch("/api/login/2fa");
+    if (!statusRes.ok) {
+      data.error = (await statusRes.text()) || "Sign-in expired";
+      return rpc.ok<Data>(data);
+    }
+    const status = await statusRes.json();
+    data.email = status.email;
+    data.setupRequired = status.setupRequired;
+
+    if (data.setupRequired) {
+      const setupRes = await nativeFetch("/api/login/2fa/setup", {
+        method: "POST",
+      });
+      if (!setupRes.ok) {
+        data.error = (await setupRes.text()) || "Failed to start setup";
+        return rpc.ok<Data>(data);
+      }
+      const setup = await setupRes.json();
+      data.secret = setup.secret;
+      data.otpauthUrl = setup.otpauthUrl;
+    }
+  } catch (error) {
+    data.error = "Network error. Please try again.";
+  }
+
+  return rpc.ok<Data>(data);
+}
+
+export function view(
+  route: string,
+  prefix: string,
+  data: Data,
+): preact.ComponentChild {
+  const form = useTwoFactorForm();
+
+  let content: preact.ComponentChild;
+  if (data.error) {
+    content = (
+      <div className="auth-methods">
+        <div className="error-message">{data.error}</div>
+        <a href="/login" className="btn btn-primary btn-large auth-submit">
+          Back to Sign In
+        </a>
+      </div>
+    );
+  } else if (form.recoveryCodes.length > 0) {
+    content = (
+      <div className="auth-methods">
+        <div className="success-message">
+          Two-factor authentication is on. Save these recovery codes somewhere
+          safe; each one can be used once if you lose your device.
+        </div>
+        <pre className="recovery-codes">{form.recoveryCodes.join("\n")}</pre>
+        <a href="/dashboard" className="btn btn-primary btn-large auth-submit">
+          Continue
+        </a>
+      </div>
+    );
+  } else {
+    content = (
+      <form className="auth-form" onSubmit={vlens.cachePartial(onSubmit, form)}>
+        {data.setupRequired && (
+          <div className="form-group">
+            <p>
+              Your role requires two-factor authentication. Add this key to an
+              authenticator app, then enter the 6-digit code it shows.
+            </p>
+            <code className="totp-secret">{data.secret}</code>
+            <a href={data.otpauthUrl} className="auth-link">
+              Open in authenticator app
+            </a>
+          </div>
+        )}
+
+        <div className="form-group">
+          <label htmlFor="code">
+            {data.setupRequired
+              ? "Authentication Code"
+              : "Authentication or Recovery Code"}
+          </label>
+          <input
+            type="text"
+            id="code"
+            inputMode={data.setupRequired ? "numeric" : "text"}
+            autoComplete="one-time-code"
+            placeholder={
+              data.setupRequired ? "123456" : "123456 or recovery code"
+            }
+            {...vlens.attrsBindInput(vlens.ref(form, "code"))}
+            required
+            disabled={form.loading}
+          />
+        </div>
+
+        <button
+          type="submit"
+          className="btn btn-primary btn-large auth-submit"
+          disabled={form.loading}
+        >
+          {form.loading ? "Verifying..." : "Verify"}
+        </button>
+      </form>
+    );
+  }
+
+  return (
+    <div>
+      <Header />
+      <main className="login-container">
+        <div className="login-page">
+          <div className="auth-card">
+            <div className="auth-header">
+              <h1>Two-Factor Authentication</h1>
+              {data.email && <p>Signing in as {data.email}</p>}
+            </div>
+
+            {form.error && <div className="error-message">{form.error}</div>}
+            {content}
+          </div>
+        </div>
+      </main>
+      <Footer />
+    </div>
+  );
+}
+
+async function onSubmit(form: TwoFactorForm, event: Event) {
+  event.preventDefault();
+  form.loading = true;
+  form.error = "";
+  vlens.scheduleRedraw();
+
+  const nativeFetch = window.fetch.bind(window);
+  try {
+    const res = await nativeFetch("/api/login/2fa", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ code: form.code }),
+    });
+
+    if (!res.ok) {
+      form.error = (await res.text()) || "Verification failed";
+      form.loading = false;
+      vlens.scheduleRedraw();
+      return;
+    }
+
+    const result = await res.json();
+    form.loading = false;
+    if (result.recoveryCodes && result.recoveryCodes.length > 0) {
+      // Setup just finished; show the codes before moving on
+      form.recoveryCodes = result.recoveryCodes;
+    } else if (result.token && result.auth) {
+      window.location.href = "/dashboard";
+      return;
+    } else {
+      form.error = "Invalid response from server";
+    }
+  } catch (error) {
+    form.loading = false;
+    form.error = "Network error. Please try again.";
+  }
+
+  vlens.scheduleRedraw();
+}
@@ -0,0 +1,94 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as rpc from "vlens/rpc";
+import * as server from "../../server";
+import { Header, Footer } from "../../layout";
+import "../../styles/global";
+import "./login-styles";
+
+type Data = {};
+
+type VerifyState = {
+  started: boolean;
+  verified: boolean;
+  email: string;
+  error: string;
+};
+
+const useVerifyState = vlens.declareHook(
+  (routeKey: string): VerifyState => ({
+    started: false,
+    verified: false,
+    email: "",
+    error: "",
+  }),
+);
+
+export async function fetch(route: string, prefix: string) {
+  return rpc.ok<Data>({});
+}
+
+export function view(
+  route: string,
+  prefix: string,
+  data: Data,
+): preact.ComponentChild {
+  // Extract token from URL (e.g., /verify-email/<token>)
+  const token = extractTokenFromRoute(route, prefix);
+  const state = useVerifyState(route);
+
+  // Verify once per page load
+  if (token && !state.started) {
+    state.started = true;
+    setTimeout(() => verifyEmail(state, token), 0);
+  }
+
+  const error = token ? state.error : "This verification link is incomplete.";
+
+  return (
+    <div>
+      <Header />
+      <main className="login-container">
+        <div className="login-page">
+          <div className="auth-card">
+            <div className="auth-header">
+              <h1>Verify Email</h1>
+            </div>
+
+            {error ? (
+              <div className="error-message">{error}</div>
+            ) : state.verified ? (
+              <div className="success-message">
+                {state.email} is verified.{" "}
+                <a href="/dashboard" className="auth-link">
+                  Continue
+                </a>
+              </div>
+            ) : (
+              <p>Verifying your email...</p>
+            )}
+          </div>
+        </div>
+      </main>
+      <Footer />
+    </div>
+  );
+}
+
+function extractTokenFromRoute(route: string, prefix: string): string {
+  const afterPrefix = route.substring(prefix.length);
+  const parts = afterPrefix.split("/").filter((p) => p.length > 0);
+  return parts.length > 0 ? parts[0] : "";
+}
+
+async function verifyEmail(state: VerifyState, token: string) {
+  const [resp, err] = await server.VerifyEmail({ token: token });
+
+  if (err || !resp) {
+    state.error = err || "This verification link is not valid";
+  } else {
+    state.verified = true;
+    state.email = resp.email;
+  }
+  vlens.scheduleRedraw();
+}
@@ -148,6 +148,52 @@ export async function fetch(route: string, prefix: string) {
   });
 }
 
+type VerifyBannerState = {
+  sending: boolean;
+  message: string;
+};
+
+const useVerifyBanner = vlens.declareHook(
+  (): VerifyBannerState => ({
+    sending: false,
+    message: "",
+  }),
+);
+
+async function resendVerificationEmail(state: VerifyBannerState) {
+  state.sending = true;
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.SendVerificationEmail({});
+
+  state.sending = false;
+  state.message = err || "Verification email sent. Check your inbox.";
+  vlens.scheduleRedraw();
+}
+
+const VerifyEmailBanner = () => {
+  const state = useVerifyBanner();
+  return (
+    <div className="expiration-banner">
+      <div className="expiration-content">
+        <span className="expiration-icon">✉️</span>
+        <span className="expiration-text">
+          {state.message || "Please verify your email address."}
+        </span>
+        {!state.message && (
+          <button
+            className="btn btn-secondary btn-sm"
+            disabled={state.sending}
+            onClick={vlens.cachePartial(resendVerificationEmail, state)}
+          >
+            {state.sending ? "Sending..." : "Resend Email"}
+          </button>
+        )}
+      </div>
+    </div>
+  );
+};
+
 export function view(
   route: string,
   prefix: string,
@@ -198,6 +244,9 @@ export function view(
             </div>
           )}
 
+        {/* Email verification reminder for logged-in users */}
+        {auth && auth.id > 0 && !auth.emailVerified && <VerifyEmailBanner />}
+
         <div className="dashboard-content">
           <h1 className="dashboard-title">My Streams</h1>
           <p className="dashboard-description">
@@ -0,0 +1,101 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as rpc from "vlens/rpc";
+import * as core from "vlens/core";
+import { Header, Footer } from "../../layout";
+import "../../styles/global";
+import "../home/home-styles";
+
+type Data = {};
+
+type InviteState = {
+  started: boolean;
+  error: string;
+};
+
+const useInviteState = vlens.declareHook(
+  (routeKey: string): InviteState => ({
+    started: false,
+    error: "",
+  }),
+);
+
+export async function fetch(route: string, prefix: string) {
+  return rpc.ok<Data>({});
+}
+
+export function view(
+  route: string,
+  prefix: string,
+  data: Data,
+): preact.ComponentChild {
+  // Extract token from URL (e.g., /invite/<signed token>)
+  const token = extractTokenFromRoute(route, prefix);
+  const state = useInviteState(route);
+
+  // Redeem once per page load
+  if (token && !state.started) {
+    state.started = true;
+    setTimeout(() => redeemInvite(state, token), 0);
+  }
+
+  const error = token ? state.error : "This invite link is incomplete.";
+
+  return (
+    <div>
+      <Header />
+      <main className="landing-container">
+        <div className="landing-card">
+          <h1 className="landing-title">Join Stream</h1>
+          {error ? (
+            <>
+              <div className="landing-error">{error}</div>
+              <div className="landing-help">
+                <p>
+                  Ask the stream owner for a new link, or{" "}
+                  <a href="/">enter an access code</a>.
+                </p>
+              </div>
+            </>
+          ) : (
+            <p className="landing-description">Opening your invite...</p>
+          )}
+        </div>
+      </main>
+      <Footer />
+    </div>
+  );
+}
+
+function extractTokenFromRoute(route: string, prefix: string): string {
+  const afterPrefix = route.substring(prefix.length);
+  const parts = afterPrefix.split("/").filter((p) => p.length > 0);
+  return parts.length > 0 ? parts[0] : "";
+}
+
+async function redeemInvite(state: InviteState, token: string) {
+  const nativeFetch = window.fetch.bind(window);
+  try {
+    const res = await nativeFetch("/api/redeem-invite", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({
+        token: token,
+      }),
+    });
+
+    const data = await res.json();
+
+    if (data.redirectTo) {
+      core.setRoute(data.redirectTo);
+      return;
+    }
+
+    state.error = data.error || "This invite link is not valid";
+  } catch (err) {
+    state.error = "Failed to open invite. Please try again.";
+  }
+  vlens.scheduleRedraw();
+}
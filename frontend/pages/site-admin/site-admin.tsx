@@ -72,8 +72,16 @@ async function confirmDeleteStudio(modal: DeleteStudioModal) {
 }
 
 // ===== Page Navigation State =====
+type AdminSection =
+  | "studios"
+  | "users"
+  | "logs"
+  | "performance"
+  | "security"
+  | "keys";
+
 type PageState = {
-  activeSection: "studios" | "users" | "logs" | "performance";
+  activeSection: AdminSection;
 };
 
 const usePageState = vlens.declareHook(
@@ -82,10 +90,7 @@ const usePageState = vlens.declareHook(
   }),
 );
 
-function setActiveSection(
-  state: PageState,
-  section: "studios" | "users" | "logs" | "performance",
-) {
+function setActiveSection(state: PageState, section: AdminSection) {
   state.activeSection = section;
   vlens.scheduleRedraw();
 }
@@ -250,6 +255,148 @@ function getLevelClass(level: string): string {
   }
 }
 
+// ===== Security Events =====
+type SecurityState = {
+  events: server.SecurityEvent[];
+  lockedAccounts: server.LockedAccount[];
+  loaded: boolean;
+  loading: boolean;
+  error: string;
+};
+
+const useSecurityState = vlens.declareHook(
+  (): SecurityState => ({
+    events: [],
+    lockedAccounts: [],
+    loaded: false,
+    loading: false,
+    error: "",
+  }),
+);
+
+async function loadSecurityEvents(state: SecurityState) {
+  state.loading = true;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.ListSecurityEvents({ userId: 0, limit: 0 });
+
+  state.loading = false;
+  state.loaded = true;
+
+  if (err || !resp) {
+    state.error = err || "Failed to load security events";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  state.events = resp.events || [];
+  state.lockedAccounts = resp.lockedAccounts || [];
+  vlens.scheduleRedraw();
+}
+
+async function unlockAccount(state: SecurityState, email: string) {
+  state.loading = true;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.UnlockAccount({ email });
+
+  if (err || !resp) {
+    state.loading = false;
+    state.error = err || "Failed to unlock account";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  await loadSecurityEvents(state);
+}
+
+function getSecurityEventLabel(type: string): string {
+  switch (type) {
+    case "login_failed":
+      return "Failed sign-in";
+    case "account_locked":
+      return "Account locked";
+    case "account_unlocked":
+      return "Account unlocked";
+    default:
+      return type;
+  }
+}
+
+// ===== JWT Signing Keys =====
+type SigningKeysState = {
+  keys: server.SigningKey[];
+  algorithm: string;
+  rotationDays: number;
+  loaded: boolean;
+  loading: boolean;
+  error: string;
+};
+
+const useSigningKeysState = vlens.declareHook(
+  (): SigningKeysState => ({
+    keys: [],
+    algorithm: "",
+    rotationDays: 0,
+    loaded: false,
+    loading: false,
+    error: "",
+  }),
+);
+
+async function loadSigningKeys(state: SigningKeysState) {
+  state.loading = true;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.ListSigningKeys({});
+
+  state.loading = false;
+  state.loaded = true;
+
+  if (err || !resp) {
+    state.error = err || "Failed to load signing keys";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  state.keys = resp.keys || [];
+  state.algorithm = resp.algorithm;
+  state.rotationDays = resp.rotationDays;
+  vlens.scheduleRedraw();
+}
+
+async function rotateSigningKeys(state: SigningKeysState) {
+  if (
+    !confirm(
+      "Start signing with a new key? Existing sessions stay signed in until their tokens expire.",
+    )
+  ) {
+    return;
+  }
+
+  state.loading = true;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.RotateSigningKeys({});
+
+  if (err || !resp) {
+    state.loading = false;
+    state.error = err || "Failed to rotate signing keys";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  await loadSigningKeys(state);
+}
+
+function isRetired(key: server.SigningKey): boolean {
+  return new Date(key.retiredAt).getFullYear() > 1;
+}
+
 // ===== Performance Metrics =====
 type PerformanceState = {
   siteWide: server.SitePerformanceMetrics | null;
@@ -393,6 +540,8 @@ export function view(
   const performanceState = usePerformanceState();
   const logsState = useLogsState();
   const pageState = usePageState();
+  const securityState = useSecurityState();
+  const keysState = useSigningKeysState();
 
   // Check permissions
   if (!data || !data.studios) {
@@ -473,6 +622,28 @@ export function view(
             >
               Performance
             </button>
+            <button
+              className={`nav-tab ${pageState.activeSection === "security" ? "active" : ""}`}
+              onClick={() => {
+                setActiveSection(pageState, "security");
+                if (!securityState.loaded && !securityState.loading) {
+                  loadSecurityEvents(securityState);
+                }
+              }}
+            >
+              Security
+            </button>
+            <button
+              className={`nav-tab ${pageState.activeSection === "keys" ? "active" : ""}`}
+              onClick={() => {
+                setActiveSection(pageState, "keys");
+                if (!keysState.loaded && !keysState.loading) {
+                  loadSigningKeys(keysState);
+                }
+              }}
+            >
+              Signing Keys
+            </button>
           </div>
 
           {/* Studios Management Section */}
@@ -1111,6 +1282,174 @@ export function view(
               )}
             </div>
           )}
+
+          {/* Security Events Section */}
+          {pageState.activeSection === "security" && (
+            <div className="admin-section">
+              <div className="section-header">
+                <h2 className="section-title">Sign-in Security</h2>
+                <button
+                  className="btn btn-primary btn-sm"
+                  onClick={() => loadSecurityEvents(securityState)}
+                  disabled={securityState.loading}
+                >
+                  {securityState.loading ? "Refreshing..." : "Refresh"}
+                </button>
+              </div>
+
+              {securityState.error && (
+                <div className="error-message">{securityState.error}</div>
+              )}
+
+              <h3>Locked Accounts</h3>
+              {securityState.lockedAccounts.length === 0 ? (
+                <div className="empty-state">
+                  <p>No accounts are locked out right now.</p>
+                </div>
+              ) : (
+                <div className="users-table-container">
+                  <table className="admin-table">
+                    <thead>
+                      <tr>
+                        <th>Email</th>
+                        <th>Locked Until</th>
+                        <th>Actions</th>
+                      </tr>
+                    </thead>
+                    <tbody>
+                      {securityState.lockedAccounts.map((account) => (
+                        <tr key={account.email}>
+                          <td>
+                            {account.email}
+                            {account.userId === 0 && (
+                              <span className="text-muted"> (no account)</span>
+                            )}
+                          </td>
+                          <td>
+                            {new Date(account.lockedUntil).toLocaleString()}
+                          </td>
+                          <td className="actions-cell">
+                            <button
+                              className="btn btn-secondary btn-sm"
+                              disabled={securityState.loading}
+                              onClick={() =>
+                                unlockAccount(securityState, account.email)
+                              }
+                            >
+                              Unlock
+                            </button>
+                          </td>
+                        </tr>
+                      ))}
+                    </tbody>
+                  </table>
+                </div>
+              )}
+
+              <h3>Recent Events</h3>
+              {securityState.events.length === 0 ? (
+                <div className="empty-state">
+                  <p>No failed sign-ins recorded.</p>
+                </div>
+              ) : (
+                <div className="users-table-container">
+                  <table className="admin-table">
+                    <thead>
+                      <tr>
+                        <th>Time</th>
+                        <th>Event</th>
+                        <th>Email</th>
+                        <th>IP Address</th>
+                        <th>Details</th>
+                      </tr>
+                    </thead>
+                    <tbody>
+                      {securityState.events.map((event) => (
+                        <tr key={event.id}>
+                          <td>{new Date(event.createdAt).toLocaleString()}</td>
+                          <td>{getSecurityEventLabel(event.type)}</td>
+                          <td>{event.email}</td>
+                          <td>{event.ipAddress || "—"}</td>
+                          <td>{event.detail}</td>
+                        </tr>
+                      ))}
+                    </tbody>
+                  </table>
+                </div>
+              )}
+            </div>
+          )}
+
+          {/* JWT Signing Keys Section */}
+          {pageState.activeSection === "keys" && (
+            <div className="admin-section">
+              <div className="section-header">
+                <h2 className="section-title">Signing Keys</h2>
+                <button
+                  className="btn btn-primary btn-sm"
+                  onClick={() => rotateSigningKeys(keysState)}
+                  disabled={keysState.loading}
+                >
+                  {keysState.loading ? "Working..." : "Rotate Now"}
+                </button>
+              </div>
+
+              {keysState.error && (
+                <div className="error-message">{keysState.error}</div>
+              )}
+
+              {keysState.loaded && (
+                <p className="site-admin-description">
+                  New tokens are signed with {keysState.algorithm}.{" "}
+                  {keysState.rotationDays > 0
+                    ? `Keys rotate automatically every ${keysState.rotationDays} days.`
+                    : "Keys only rotate when you rotate them here."}{" "}
+                  Retired keys keep verifying existing tokens for 30 days.
+                </p>
+              )}
+
+              {keysState.loading && keysState.keys.length === 0 ? (
+                <div className="loading-state">Loading signing keys...</div>
+              ) : (
+                <div className="users-table-container">
+                  <table className="admin-table">
+                    <thead>
+                      <tr>
+                        <th>Key ID</th>
+                        <th>Algorithm</th>
+                        <th>Status</th>
+                        <th>Created</th>
+                        <th>Retired</th>
+                      </tr>
+                    </thead>
+                    <tbody>
+                      {keysState.keys.map((key) => (
+                        <tr key={key.kid}>
+                          <td>
+                            <code>{key.kid}</code>
+                          </td>
+                          <td>{key.algorithm}</td>
+                          <td>
+                            {isRetired(key) ? (
+                              <span className="text-muted">Retired</span>
+                            ) : (
+                              <span className="role-badge role-0">Active</span>
+                            )}
+                          </td>
+                          <td>{new Date(key.createdAt).toLocaleString()}</td>
+                          <td>
+                            {isRetired(key)
+                              ? new Date(key.retiredAt).toLocaleString()
+                              : "—"}
+                          </td>
+                        </tr>
+                      ))}
+                    </tbody>
+                  </table>
+                </div>
+              )}
+            </div>
+          )}
         </div>
       </main>
 
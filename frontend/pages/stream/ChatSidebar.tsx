@@ -1,34 +1,70 @@
 import * as preact from "preact";
 import * as vlens from "vlens";
 import * as server from "../../server";
+import { EmoteGlyph } from "../../components/EmoteGlyph";
 
 type ChatMessage = server.ChatMessage;
+type SendHandler = (text: string, replyToId: number) => void;
 
 type ChatSidebarProps = {
   id: string;
   roomId: number;
   messages: ChatMessage[];
-  onSendMessage: (text: string) => void;
+  emotes: server.StudioEmote[]; // Reactions use the room's studio emotes
+  hasMore: boolean; // Older messages can be loaded
+  isLoadingOlder: boolean;
+  onLoadOlder: () => void;
+  settings: server.ChatRoomSettings | null;
+  canModerate: boolean;
+  canPin: boolean; // Chat moderators can set the pinned announcement
+  pin: server.ChatPinnedAnnouncement | null;
+  onPinChange: (pin: server.ChatPinnedAnnouncement) => void;
+  onReactionsChange: (
+    messageId: number,
+    reactions: server.ChatReaction[],
+  ) => void;
+  notice: string; // Why the last message was refused, if it was
+  isGuest: boolean; // Code session, chatting under a chosen name
+  guestName: string;
+  onGuestNameChange: (name: string) => void;
+  onSendMessage: SendHandler;
+  qa: preact.ComponentChild; // Polls and questions, shown in the Q&A tab
+  hasOpenPoll: boolean;
   onClose?: () => void; // For mobile
 };
 
 type ChatState = {
+  tab: "chat" | "qa";
   messageText: string;
+  moderationError: string;
+  isEditingName: boolean;
+  nameDraft: string;
+  nameError: string;
+  replyTo: ChatMessage | null;
+  reactingTo: number; // Message whose reaction picker is open (0 = none)
+  isEditingPin: boolean;
+  pinDraft: string;
   scrollContainerRef: HTMLDivElement | null;
   onScrollContainerRef: (el: HTMLDivElement | null) => void;
   isAtBottom: boolean;
   shouldAutoScroll: boolean;
   onScroll: (e: Event) => void;
-  handleSend: () => void;
-  handleKeyDown: (
-    e: KeyboardEvent,
-    onSendMessage: (text: string) => void,
-  ) => void;
+  handleSend: (onSendMessage: SendHandler) => void;
+  handleKeyDown: (e: KeyboardEvent, onSendMessage: SendHandler) => void;
 };
 
 const useChatState = vlens.declareHook((): ChatState => {
   const state: ChatState = {
+    tab: "chat",
     messageText: "",
+    moderationError: "",
+    isEditingName: false,
+    nameDraft: "",
+    nameError: "",
+    replyTo: null,
+    reactingTo: 0,
+    isEditingPin: false,
+    pinDraft: "",
     scrollContainerRef: null,
     isAtBottom: true,
     shouldAutoScroll: true,
@@ -52,9 +88,14 @@ const useChatState = vlens.declareHook((): ChatState => {
       state.shouldAutoScroll = state.isAtBottom;
     },
 
-    handleSend: () => {
-      // Just clear the input and scroll
+    handleSend: (onSendMessage) => {
+      const text = state.messageText.trim();
+      if (text.length === 0 || text.length > 500) return;
+      onSendMessage(text, state.replyTo ? state.replyTo.id : 0);
+
+      // Clear the input and scroll
       state.messageText = "";
+      state.replyTo = null;
       vlens.scheduleRedraw();
 
       // Scroll to bottom after sending
@@ -69,17 +110,10 @@ const useChatState = vlens.declareHook((): ChatState => {
       }
     },
 
-    handleKeyDown: (
-      e: KeyboardEvent,
-      onSendMessage: (text: string) => void,
-    ) => {
+    handleKeyDown: (e: KeyboardEvent, onSendMessage: SendHandler) => {
       if (e.key === "Enter" && !e.shiftKey) {
         e.preventDefault();
-        const text = state.messageText.trim();
-        if (text.length > 0 && text.length <= 500) {
-          onSendMessage(text);
-          state.handleSend();
-        }
+        state.handleSend(onSendMessage);
       }
     },
   };
@@ -87,6 +121,124 @@ const useChatState = vlens.declareHook((): ChatState => {
   return state;
 });
 
+// ===== Moderation =====
+const SLOW_MODE_OPTIONS = [0, 5, 30, 60, 120];
+const TIMEOUT_MINUTES = 10;
+
+async function deleteMessage(state: ChatState, messageId: number) {
+  const [resp, err] = await server.DeleteChatMessage({ messageId });
+  state.moderationError = err || "";
+  vlens.scheduleRedraw();
+}
+
+async function restrictAuthor(
+  state: ChatState,
+  msg: ChatMessage,
+  kind: server.ChatRestrictionKind,
+) {
+  const action =
+    kind === server.ChatRestrictionBan
+      ? `Ban ${msg.userName} from chat in this studio?`
+      : `Time out ${msg.userName} for ${TIMEOUT_MINUTES} minutes?`;
+  if (!confirm(action)) {
+    return;
+  }
+  const [resp, err] = await server.RestrictChatViewer({
+    messageId: msg.id,
+    kind,
+    durationMinutes: TIMEOUT_MINUTES,
+    reason: "",
+    deleteMessage: true,
+  });
+  state.moderationError = err || "";
+  vlens.scheduleRedraw();
+}
+
+async function updateSettings(
+  state: ChatState,
+  roomId: number,
+  slowModeSeconds: number,
+  chatDisabled: boolean,
+) {
+  const [resp, err] = await server.UpdateChatSettings({
+    roomId,
+    slowModeSeconds,
+    chatDisabled,
+  });
+  state.moderationError = err || "";
+  vlens.scheduleRedraw();
+}
+
+// ===== Replies, reactions and pins =====
+async function toggleReaction(
+  state: ChatState,
+  msg: ChatMessage,
+  emote: string,
+  onReactionsChange: ChatSidebarProps["onReactionsChange"],
+) {
+  state.reactingTo = 0;
+  const [resp, err] = await server.ToggleChatReaction({
+    messageId: msg.id,
+    emote,
+  });
+  state.moderationError = err || "";
+  if (resp) {
+    onReactionsChange(msg.id, resp.reactions || []);
+  }
+  vlens.scheduleRedraw();
+}
+
+async function savePin(
+  state: ChatState,
+  roomId: number,
+  text: string,
+  onPinChange: ChatSidebarProps["onPinChange"],
+) {
+  const [resp, err] = await server.SetChatPinnedAnnouncement({ roomId, text });
+  state.moderationError = err || "";
+  if (resp) {
+    state.isEditingPin = false;
+    onPinChange(resp.pin);
+  }
+  vlens.scheduleRedraw();
+}
+
+// Short quote of the message being replied to, if it's still loaded
+function replyExcerpt(messages: ChatMessage[], msg: ChatMessage): string {
+  const original = messages.find((m) => m.id === msg.replyToId);
+  if (!original) return msg.replyToName;
+  const text =
+    original.text.length > 60
+      ? original.text.slice(0, 60) + "..."
+      : original.text;
+  return `${msg.replyToName}: ${text}`;
+}
+
+// ===== Guest name =====
+function startEditingName(state: ChatState, guestName: string) {
+  state.isEditingName = true;
+  state.nameDraft = guestName;
+  state.nameError = "";
+  vlens.scheduleRedraw();
+}
+
+async function saveGuestName(
+  state: ChatState,
+  onGuestNameChange: (name: string) => void,
+) {
+  const [resp, err] = await server.SetChatDisplayName({
+    name: state.nameDraft,
+  });
+  if (err || !resp) {
+    state.nameError = err || "Failed to set name";
+  } else {
+    state.isEditingName = false;
+    state.nameError = "";
+    onGuestNameChange(resp.name);
+  }
+  vlens.scheduleRedraw();
+}
+
 // Helper function to format timestamp
 function formatTimestamp(timestamp: string | Date): string {
   const date = new Date(timestamp);
@@ -116,11 +268,34 @@ export function ChatSidebar(props: ChatSidebarProps) {
   const charLimit = 500;
   const isOverLimit = charCount > charLimit;
 
+  const slowMode = props.settings?.slowModeSeconds || 0;
+  const chatDisabled = props.settings?.chatDisabled || false;
+  const canType = !chatDisabled || props.canModerate;
+
   return (
     <div className="chat-sidebar">
       {/* Header */}
       <div className="chat-header">
-        <span className="chat-title">Chat</span>
+        <div className="chat-tabs">
+          <button
+            className={`chat-tab ${state.tab === "chat" ? "active" : ""}`}
+            onClick={() => {
+              state.tab = "chat";
+              vlens.scheduleRedraw();
+            }}
+          >
+            Chat
+          </button>
+          <button
+            className={`chat-tab ${state.tab === "qa" ? "active" : ""}`}
+            onClick={() => {
+              state.tab = "qa";
+              vlens.scheduleRedraw();
+            }}
+          >
+            {props.hasOpenPoll ? "Q&A •" : "Q&A"}
+          </button>
+        </div>
         {props.onClose && (
           <button
             className="chat-close-btn"
@@ -132,61 +307,346 @@ export function ChatSidebar(props: ChatSidebarProps) {
         )}
       </div>
 
-      {/* Messages */}
-      <div
-        className="chat-messages"
-        ref={state.onScrollContainerRef}
-        onScroll={state.onScroll}
-      >
-        {props.messages.length === 0 ? (
-          <div className="chat-empty">
-            <p>No messages yet</p>
-            <p className="chat-empty-hint">Be the first to say something!</p>
+      {/* Pinned announcement */}
+      {state.isEditingPin ? (
+        <div className="chat-guest-bar">
+          <input
+            type="text"
+            className="chat-guest-input"
+            placeholder="Announcement"
+            maxLength={200}
+            {...vlens.attrsBindInput(vlens.ref(state, "pinDraft"))}
+          />
+          <button
+            className="chat-mod-btn"
+            onClick={() =>
+              savePin(state, props.roomId, state.pinDraft, props.onPinChange)
+            }
+          >
+            Pin
+          </button>
+          <button
+            className="chat-mod-btn"
+            onClick={() => {
+              state.isEditingPin = false;
+              vlens.scheduleRedraw();
+            }}
+          >
+            Cancel
+          </button>
+        </div>
+      ) : (
+        (props.pin || props.canPin) && (
+          <div className="chat-pinned">
+            <span className="chat-pinned-text">
+              {props.pin ? `📌 ${props.pin.text}` : "No pinned announcement"}
+            </span>
+            {props.canPin && (
+              <button
+                className="chat-mod-btn"
+                onClick={() => {
+                  state.isEditingPin = true;
+                  state.pinDraft = props.pin ? props.pin.text : "";
+                  vlens.scheduleRedraw();
+                }}
+              >
+                {props.pin ? "Edit" : "Pin"}
+              </button>
+            )}
+            {props.canPin && props.pin && (
+              <button
+                className="chat-mod-btn"
+                onClick={() =>
+                  savePin(state, props.roomId, "", props.onPinChange)
+                }
+              >
+                Unpin
+              </button>
+            )}
+          </div>
+        )
+      )}
+
+      {/* Moderator controls */}
+      {props.canModerate && (
+        <div className="chat-mod-bar">
+          <select
+            className="chat-mod-select"
+            value={slowMode}
+            onChange={(e) =>
+              updateSettings(
+                state,
+                props.roomId,
+                Number((e.target as HTMLSelectElement).value),
+                chatDisabled,
+              )
+            }
+          >
+            {SLOW_MODE_OPTIONS.map((seconds) => (
+              <option key={seconds} value={seconds}>
+                {seconds ? `Slow mode: ${seconds}s` : "Slow mode: off"}
+              </option>
+            ))}
+          </select>
+          <button
+            className="chat-mod-btn"
+            onClick={() =>
+              updateSettings(state, props.roomId, slowMode, !chatDisabled)
+            }
+          >
+            {chatDisabled ? "Turn chat on" : "Turn chat off"}
+          </button>
+        </div>
+      )}
+      {state.moderationError && (
+        <div className="chat-notice">{state.moderationError}</div>
+      )}
+
+      {/* Guest name */}
+      {props.isGuest &&
+        (state.isEditingName ? (
+          <div className="chat-guest-bar">
+            <input
+              type="text"
+              className="chat-guest-input"
+              placeholder="Your name"
+              maxLength={32}
+              {...vlens.attrsBindInput(vlens.ref(state, "nameDraft"))}
+              onKeyDown={(e) => {
+                if (e.key === "Enter") {
+                  saveGuestName(state, props.onGuestNameChange);
+                }
+              }}
+            />
+            <button
+              className="chat-mod-btn"
+              onClick={() => saveGuestName(state, props.onGuestNameChange)}
+            >
+              Save
+            </button>
+            <button
+              className="chat-mod-btn"
+              onClick={() => {
+                state.isEditingName = false;
+                state.nameError = "";
+                vlens.scheduleRedraw();
+              }}
+            >
+              Cancel
+            </button>
           </div>
         ) : (
-          props.messages.map((msg) => (
-            <div key={msg.id} className="chat-message">
-              <div className="chat-message-header">
-                <span className="chat-username">{msg.userName}</span>
-                <span className="chat-timestamp">
-                  {formatTimestamp(msg.timestamp)}
-                </span>
+          <div className="chat-guest-bar">
+            <span>Chatting as</span>
+            <span className="chat-guest-name">
+              {props.guestName || "Viewer"}
+            </span>
+            <button
+              className="chat-mod-btn"
+              onClick={() => startEditingName(state, props.guestName)}
+            >
+              {props.guestName ? "Change name" : "Set your name"}
+            </button>
+          </div>
+        ))}
+      {state.nameError && (
+        <div className="chat-notice">{state.nameError}</div>
+      )}
+
+      {state.tab === "qa" ? (
+        props.qa
+      ) : (
+        <>
+          {/* Messages */}
+          <div
+            className="chat-messages"
+            ref={state.onScrollContainerRef}
+            onScroll={state.onScroll}
+          >
+            {props.hasMore && (
+              <button
+                className="chat-load-older"
+                disabled={props.isLoadingOlder}
+                onClick={props.onLoadOlder}
+              >
+                {props.isLoadingOlder ? "Loading..." : "Load earlier messages"}
+              </button>
+            )}
+            {props.messages.length === 0 ? (
+              <div className="chat-empty">
+                <p>No messages yet</p>
+                <p className="chat-empty-hint">
+                  Be the first to say something!
+                </p>
               </div>
-              <div className="chat-message-text">{msg.text}</div>
-            </div>
-          ))
-        )}
-      </div>
+            ) : (
+              props.messages.map((msg) => (
+                <div key={msg.id} className="chat-message">
+                  <div className="chat-message-header">
+                    <span className="chat-username">{msg.userName}</span>
+                    <span className="chat-timestamp">
+                      {formatTimestamp(msg.timestamp)}
+                    </span>
+                  </div>
+                  {msg.replyToId > 0 && (
+                    <div className="chat-reply-context">
+                      ↩ {replyExcerpt(props.messages, msg)}
+                    </div>
+                  )}
+                  <div className="chat-message-text">{msg.text}</div>
+                  <div className="chat-reactions">
+                    {(msg.reactions || []).map((reaction) => (
+                      <button
+                        key={reaction.emote}
+                        className={`chat-reaction ${
+                          reaction.mine ? "mine" : ""
+                        }`}
+                        onClick={() =>
+                          toggleReaction(
+                            state,
+                            msg,
+                            reaction.emote,
+                            props.onReactionsChange,
+                          )
+                        }
+                      >
+                        <EmoteGlyph
+                          code={reaction.emote}
+                          emotes={props.emotes}
+                        />{" "}
+                        {reaction.count}
+                      </button>
+                    ))}
+                    {state.reactingTo === msg.id &&
+                      props.emotes.map(({ code }) => (
+                        <button
+                          key={code}
+                          className="chat-reaction"
+                          onClick={() =>
+                            toggleReaction(
+                              state,
+                              msg,
+                              code,
+                              props.onReactionsChange,
+                            )
+                          }
+                        >
+                          <EmoteGlyph code={code} emotes={props.emotes} />
+                        </button>
+                      ))}
+                    <button
+                      className="chat-mod-btn"
+                      onClick={() => {
+                        state.reactingTo =
+                          state.reactingTo === msg.id ? 0 : msg.id;
+                        vlens.scheduleRedraw();
+                      }}
+                    >
+                      {state.reactingTo === msg.id ? "✕" : "React"}
+                    </button>
+                    {canType && (
+                      <button
+                        className="chat-mod-btn"
+                        onClick={() => {
+                          state.replyTo = msg;
+                          vlens.scheduleRedraw();
+                        }}
+                      >
+                        Reply
+                      </button>
+                    )}
+                  </div>
+                  {props.canModerate && (
+                    <div className="chat-message-actions">
+                      <button
+                        className="chat-mod-btn"
+                        onClick={() => deleteMessage(state, msg.id)}
+                      >
+                        Delete
+                      </button>
+                      <button
+                        className="chat-mod-btn"
+                        onClick={() =>
+                          restrictAuthor(
+                            state,
+                            msg,
+                            server.ChatRestrictionTimeout,
+                          )
+                        }
+                      >
+                        Timeout
+                      </button>
+                      <button
+                        className="chat-mod-btn"
+                        onClick={() =>
+                          restrictAuthor(state, msg, server.ChatRestrictionBan)
+                        }
+                      >
+                        Ban
+                      </button>
+                    </div>
+                  )}
+                </div>
+              ))
+            )}
+          </div>
 
-      {/* Input */}
-      <div className="chat-input-container">
-        <div className="chat-input-wrapper">
-          <textarea
-            className="chat-input"
-            placeholder="Send a message..."
-            maxLength={charLimit}
-            rows={2}
-            {...vlens.attrsBindInput(vlens.ref(state, "messageText"))}
-            onKeyDown={(e) => state.handleKeyDown(e, props.onSendMessage)}
-          />
-          <div className={`chat-char-count ${isOverLimit ? "over-limit" : ""}`}>
-            {charCount}/{charLimit}
+          {/* Input */}
+          {(chatDisabled || slowMode > 0 || props.notice) && (
+            <div className="chat-notice">
+              {props.notice ||
+                (chatDisabled
+                  ? "Chat is turned off"
+                  : `Slow mode: one message every ${slowMode}s`)}
+            </div>
+          )}
+          {state.replyTo && (
+            <div className="chat-guest-bar">
+              <span className="chat-guest-name">
+                Replying to {state.replyTo.userName}
+              </span>
+              <button
+                className="chat-mod-btn"
+                onClick={() => {
+                  state.replyTo = null;
+                  vlens.scheduleRedraw();
+                }}
+              >
+                Cancel
+              </button>
+            </div>
+          )}
+          <div className="chat-input-container">
+            <div className="chat-input-wrapper">
+              <textarea
+                className="chat-input"
+                placeholder={
+                  canType ? "Send a message..." : "Chat is turned off"
+                }
+                maxLength={charLimit}
+                disabled={!canType}
+                rows={2}
+                {...vlens.attrsBindInput(vlens.ref(state, "messageText"))}
+                onKeyDown={(e) => state.handleKeyDown(e, props.onSendMessage)}
+              />
+              <div
+                className={`chat-char-count ${isOverLimit ? "over-limit" : ""}`}
+              >
+                {charCount}/{charLimit}
+              </div>
+            </div>
+            <button
+              className="chat-send-btn"
+              onClick={() => state.handleSend(props.onSendMessage)}
+              disabled={
+                !canType || state.messageText.trim().length === 0 || isOverLimit
+              }
+            >
+              Send
+            </button>
           </div>
-        </div>
-        <button
-          className="chat-send-btn"
-          onClick={() => {
-            const text = state.messageText.trim();
-            if (text.length > 0 && text.length <= 500) {
-              props.onSendMessage(text);
-              state.handleSend();
-            }
-          }}
-          disabled={state.messageText.trim().length === 0 || isOverLimit}
-        >
-          Send
-        </button>
-      </div>
+        </>
+      )}
     </div>
   );
 }
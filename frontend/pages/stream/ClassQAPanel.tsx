@@ -0,0 +1,375 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as server from "../../server";
+
+type ClassQAPanelProps = {
+  roomId: number;
+  polls: server.Poll[]; // This session's polls, newest first
+  questions: server.ClassQuestion[];
+  canManage: boolean; // Chat moderators run polls and answer questions
+  onPollChange: (poll: server.Poll) => void;
+  onQuestionChange: (question: server.ClassQuestion) => void;
+};
+
+type QAState = {
+  error: string;
+  isCreatingPoll: boolean;
+  pollQuestion: string;
+  pollOptions: string[];
+  questionText: string;
+  isAsking: boolean;
+};
+
+// Mirrors MIN_POLL_OPTIONS, MAX_POLL_OPTIONS and MAX_CLASS_QUESTION_LENGTH
+const MIN_POLL_OPTIONS = 2;
+const MAX_POLL_OPTIONS = 6;
+const MAX_QUESTION_LENGTH = 300;
+
+const useQAState = vlens.declareHook(
+  (): QAState => ({
+    error: "",
+    isCreatingPoll: false,
+    pollQuestion: "",
+    pollOptions: ["", ""],
+    questionText: "",
+    isAsking: false,
+  }),
+);
+
+// ===== Polls =====
+async function createPoll(state: QAState, props: ClassQAPanelProps) {
+  const [resp, err] = await server.CreatePoll({
+    roomId: props.roomId,
+    question: state.pollQuestion,
+    options: state.pollOptions,
+  });
+  state.error = err || "";
+  if (resp) {
+    state.isCreatingPoll = false;
+    state.pollQuestion = "";
+    state.pollOptions = ["", ""];
+    props.onPollChange(resp.poll);
+  }
+  vlens.scheduleRedraw();
+}
+
+async function closePoll(
+  state: QAState,
+  poll: server.Poll,
+  onPollChange: ClassQAPanelProps["onPollChange"],
+) {
+  const [resp, err] = await server.ClosePoll({ pollId: poll.id });
+  state.error = err || "";
+  if (resp) {
+    resp.poll.myVote = poll.myVote;
+    onPollChange(resp.poll);
+  }
+  vlens.scheduleRedraw();
+}
+
+async function vote(
+  state: QAState,
+  poll: server.Poll,
+  option: number,
+  onPollChange: ClassQAPanelProps["onPollChange"],
+) {
+  const [resp, err] = await server.VotePoll({ pollId: poll.id, option });
+  state.error = err || "";
+  if (resp) {
+    onPollChange(resp.poll);
+  }
+  vlens.scheduleRedraw();
+}
+
+function percent(poll: server.Poll, option: number): number {
+  if (poll.totalVotes === 0) return 0;
+  return Math.round((poll.votes[option] / poll.totalVotes) * 100);
+}
+
+// ===== Questions =====
+async function askQuestion(state: QAState, props: ClassQAPanelProps) {
+  const text = state.questionText.trim();
+  if (!text || state.isAsking) return;
+  state.isAsking = true;
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.AskClassQuestion({
+    roomId: props.roomId,
+    text,
+  });
+  state.isAsking = false;
+  state.error = err || "";
+  if (resp) {
+    state.questionText = "";
+    props.onQuestionChange(resp.question);
+  }
+  vlens.scheduleRedraw();
+}
+
+async function setQuestionStatus(
+  state: QAState,
+  question: server.ClassQuestion,
+  status: server.QuestionStatus,
+  onQuestionChange: ClassQAPanelProps["onQuestionChange"],
+) {
+  const [resp, err] = await server.SetClassQuestionStatus({
+    questionId: question.id,
+    status,
+  });
+  state.error = err || "";
+  if (resp) {
+    resp.question.mine = question.mine;
+    onQuestionChange(resp.question);
+  }
+  vlens.scheduleRedraw();
+}
+
+function isAnswered(question: server.ClassQuestion): boolean {
+  return question.status === server.QuestionStatusAnswered;
+}
+
+function statusLabel(status: server.QuestionStatus): string {
+  switch (status) {
+    case server.QuestionStatusAnswered:
+      return "Answered";
+    case server.QuestionStatusDismissed:
+      return "Dismissed";
+    default:
+      return "";
+  }
+}
+
+function PollCard(props: {
+  poll: server.Poll;
+  state: QAState;
+  canManage: boolean;
+  onPollChange: ClassQAPanelProps["onPollChange"];
+}) {
+  const { poll, state } = props;
+  return (
+    <div className="qa-poll">
+      <div className="chat-message-header">
+        <span className="chat-username">{poll.question}</span>
+        <span className="chat-timestamp">
+          {poll.isClosed ? "Closed" : "Open"} · {poll.totalVotes} votes
+        </span>
+      </div>
+      {poll.options.map((option, i) => (
+        <button
+          key={i}
+          className={`qa-poll-option ${poll.myVote === i ? "mine" : ""}`}
+          disabled={poll.isClosed}
+          onClick={() => vote(state, poll, i, props.onPollChange)}
+        >
+          <span
+            className="qa-poll-bar"
+            style={{ width: `${percent(poll, i)}%` }}
+          />
+          <span className="qa-poll-label">{option}</span>
+          <span className="qa-poll-count">{percent(poll, i)}%</span>
+        </button>
+      ))}
+      {props.canManage && !poll.isClosed && (
+        <div className="chat-message-actions">
+          <button
+            className="chat-mod-btn"
+            onClick={() => closePoll(state, poll, props.onPollChange)}
+          >
+            Close poll
+          </button>
+        </div>
+      )}
+    </div>
+  );
+}
+
+function PollForm(props: { state: QAState; panel: ClassQAPanelProps }) {
+  const { state } = props;
+  return (
+    <div className="qa-poll">
+      <input
+        type="text"
+        className="chat-guest-input"
+        placeholder="Question, e.g. Which piece next?"
+        maxLength={200}
+        {...vlens.attrsBindInput(vlens.ref(state, "pollQuestion"))}
+      />
+      {state.pollOptions.map((option, i) => (
+        <input
+          key={i}
+          type="text"
+          className="chat-guest-input"
+          placeholder={`Option ${i + 1}`}
+          maxLength={80}
+          value={option}
+          onInput={(e) => {
+            state.pollOptions[i] = (e.target as HTMLInputElement).value;
+          }}
+        />
+      ))}
+      <div className="chat-message-actions">
+        {state.pollOptions.length < MAX_POLL_OPTIONS && (
+          <button
+            className="chat-mod-btn"
+            onClick={() => {
+              state.pollOptions.push("");
+              vlens.scheduleRedraw();
+            }}
+          >
+            Add option
+          </button>
+        )}
+        <button
+          className="chat-mod-btn"
+          onClick={() => createPoll(state, props.panel)}
+        >
+          Start poll
+        </button>
+        <button
+          className="chat-mod-btn"
+          onClick={() => {
+            state.isCreatingPoll = false;
+            vlens.scheduleRedraw();
+          }}
+        >
+          Cancel
+        </button>
+      </div>
+    </div>
+  );
+}
+
+export function ClassQAPanel(props: ClassQAPanelProps) {
+  const state = useQAState();
+  const hasOpenPoll = props.polls.some((p) => !p.isClosed);
+
+  return (
+    <>
+      <div className="chat-messages">
+        {state.error && <div className="chat-notice">{state.error}</div>}
+
+        {/* Polls */}
+        {props.canManage &&
+          (state.isCreatingPoll ? (
+            <PollForm state={state} panel={props} />
+          ) : (
+            !hasOpenPoll && (
+              <button
+                className="chat-load-older"
+                onClick={() => {
+                  state.isCreatingPoll = true;
+                  state.pollOptions = Array(MIN_POLL_OPTIONS).fill("");
+                  vlens.scheduleRedraw();
+                }}
+              >
+                New poll
+              </button>
+            )
+          ))}
+        {props.polls.map((poll) => (
+          <PollCard
+            key={poll.id}
+            poll={poll}
+            state={state}
+            canManage={props.canManage}
+            onPollChange={props.onPollChange}
+          />
+        ))}
+
+        {/* Questions */}
+        {props.questions.length === 0 && props.polls.length === 0 && (
+          <div className="chat-empty">
+            <p>No questions yet</p>
+            <p className="chat-empty-hint">Ask the instructor below</p>
+          </div>
+        )}
+        {props.questions.map((question) => (
+          <div
+            key={question.id}
+            className={`chat-message ${isAnswered(question) ? "answered" : ""}`}
+          >
+            <div className="chat-message-header">
+              <span className="chat-username">
+                {question.mine ? "You" : question.userName}
+              </span>
+              <span className="chat-timestamp">
+                {statusLabel(question.status)}
+              </span>
+            </div>
+            <div className="chat-message-text">{question.text}</div>
+            {props.canManage && (
+              <div className="chat-message-actions">
+                {question.status !== server.QuestionStatusAnswered && (
+                  <button
+                    className="chat-mod-btn"
+                    onClick={() =>
+                      setQuestionStatus(
+                        state,
+                        question,
+                        server.QuestionStatusAnswered,
+                        props.onQuestionChange,
+                      )
+                    }
+                  >
+                    Answered
+                  </button>
+                )}
+                {question.status !== server.QuestionStatusDismissed && (
+                  <button
+                    className="chat-mod-btn"
+                    onClick={() =>
+                      setQuestionStatus(
+                        state,
+                        question,
+                        server.QuestionStatusDismissed,
+                        props.onQuestionChange,
+                      )
+                    }
+                  >
+                    Dismiss
+                  </button>
+                )}
+                {question.status !== server.QuestionStatusOpen && (
+                  <button
+                    className="chat-mod-btn"
+                    onClick={() =>
+                      setQuestionStatus(
+                        state,
+                        question,
+                        server.QuestionStatusOpen,
+                        props.onQuestionChange,
+                      )
+                    }
+                  >
+                    Reopen
+                  </button>
+                )}
+              </div>
+            )}
+          </div>
+        ))}
+
+      </div>
+
+      {/* Ask */}
+      <div className="chat-input-container">
+        <div className="chat-input-wrapper">
+          <textarea
+            className="chat-input"
+            placeholder="Ask the instructor a question..."
+            maxLength={MAX_QUESTION_LENGTH}
+            rows={2}
+            {...vlens.attrsBindInput(vlens.ref(state, "questionText"))}
+          />
+        </div>
+        <button
+          className="chat-send-btn"
+          onClick={() => askQuestion(state, props)}
+          disabled={state.isAsking || state.questionText.trim().length === 0}
+        >
+          Ask
+        </button>
+      </div>
+    </>
+  );
+}
@@ -1,16 +1,16 @@
 import * as preact from "preact";
 import * as vlens from "vlens";
+import * as server from "../../server";
+import { EmoteGlyph } from "../../components/EmoteGlyph";
 
 type EmotePickerProps = {
   id: string; // Required for vlens caching
   controlsVisible: boolean; // Show/hide with video controls
+  emotes: server.StudioEmote[]; // The room's studio emote set
   onLocalEmote: (emote: string) => void; // Immediate local display
   onSendEmote: (emote: string) => void; // Broadcast to all viewers
 };
 
-// Allowed emotes matching backend
-const EMOTES = ["❤️", "👏", "🔥", "😂", "😮", "👍"];
-
 type EmotePickerState = {
   // Track if any emote was recently clicked (for visual feedback)
   lastClickedEmote: string | null;
@@ -143,25 +143,28 @@ export function EmotePicker(props: EmotePickerProps) {
       )}
 
       <div className="emote-buttons">
-        {EMOTES.map((emote) => (
+        {props.emotes.map(({ code }) => (
           <button
-            key={emote}
-            className={`emote-btn ${state.lastClickedEmote === emote ? "emote-btn-clicked" : ""} ${isInCooldown ? "emote-btn-cooldown" : ""}`}
-            onClick={() => state.onEmoteClick(emote)}
+            key={code}
+            className={`emote-btn ${state.lastClickedEmote === code ? "emote-btn-clicked" : ""} ${isInCooldown ? "emote-btn-cooldown" : ""}`}
+            onClick={() => state.onEmoteClick(code)}
             title={
               isInCooldown
-                ? `${emote} (queued for broadcast)`
-                : `Send ${emote} reaction`
+                ? `${code} (queued for broadcast)`
+                : `Send ${code} reaction`
             }
           >
-            {emote}
+            <EmoteGlyph code={code} emotes={props.emotes} />
           </button>
         ))}
       </div>
 
       {/* Queue indicator */}
       {state.queuedEmote && (
-        <div className="emote-queue-indicator">Queued: {state.queuedEmote}</div>
+        <div className="emote-queue-indicator">
+          Queued:{" "}
+          <EmoteGlyph code={state.queuedEmote} emotes={props.emotes} />
+        </div>
       )}
     </div>
   );
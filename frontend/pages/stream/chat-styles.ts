@@ -69,6 +69,26 @@ block(`
 }
 `);
 
+block(`
+.chat-load-older {
+  align-self: center;
+  padding: 0.25rem 0.75rem;
+  font-size: 0.75rem;
+  background: transparent;
+  color: var(--text-secondary);
+  border: 1px solid var(--border);
+  border-radius: 4px;
+  cursor: pointer;
+}
+`);
+
+block(`
+.chat-load-older:disabled {
+  cursor: default;
+  opacity: 0.6;
+}
+`);
+
 // Individual message
 block(`
 .chat-message {
@@ -112,6 +132,255 @@ block(`
 }
 `);
 
+// Moderation
+block(`
+.chat-mod-bar {
+  display: flex;
+  gap: 0.5rem;
+  align-items: center;
+  padding: 0.5rem 1rem;
+  border-bottom: 1px solid var(--border);
+}
+`);
+
+block(`
+.chat-mod-select {
+  flex: 1;
+  padding: 0.25rem;
+  font-size: 0.75rem;
+  background: var(--background);
+  color: var(--text);
+  border: 1px solid var(--border);
+  border-radius: 4px;
+}
+`);
+
+block(`
+.chat-mod-btn {
+  padding: 0.125rem 0.5rem;
+  font-size: 0.75rem;
+  background: transparent;
+  color: var(--text-secondary);
+  border: 1px solid var(--border);
+  border-radius: 4px;
+  cursor: pointer;
+}
+`);
+
+block(`
+.chat-mod-btn:hover {
+  color: var(--text);
+  border-color: var(--text-secondary);
+}
+`);
+
+block(`
+.chat-message-actions {
+  display: flex;
+  gap: 0.25rem;
+  margin-top: 0.25rem;
+}
+`);
+
+// Replies and reactions
+block(`
+.chat-reply-context {
+  font-size: 0.75rem;
+  color: var(--text-secondary);
+  border-left: 2px solid var(--border);
+  padding-left: 0.5rem;
+  overflow: hidden;
+  text-overflow: ellipsis;
+  white-space: nowrap;
+}
+`);
+
+block(`
+.chat-reactions {
+  display: flex;
+  flex-wrap: wrap;
+  gap: 0.25rem;
+  align-items: center;
+}
+`);
+
+block(`
+.chat-reaction {
+  padding: 0.125rem 0.375rem;
+  font-size: 0.75rem;
+  background: transparent;
+  color: var(--text);
+  border: 1px solid var(--border);
+  border-radius: 999px;
+  cursor: pointer;
+}
+`);
+
+block(`
+.chat-reaction.mine {
+  border-color: #3b82f6;
+  background: rgba(59, 130, 246, 0.15);
+}
+`);
+
+// Pinned announcement
+block(`
+.chat-pinned {
+  display: flex;
+  gap: 0.5rem;
+  align-items: center;
+  padding: 0.5rem 1rem;
+  font-size: 0.875rem;
+  background: var(--background);
+  border-bottom: 1px solid var(--border);
+}
+`);
+
+block(`
+.chat-pinned-text {
+  flex: 1;
+  color: var(--text);
+  word-break: break-word;
+}
+`);
+
+// Chat / Q&A tabs
+block(`
+.chat-tabs {
+  display: flex;
+  gap: 0.25rem;
+}
+`);
+
+block(`
+.chat-tab {
+  padding: 0.25rem 0.75rem;
+  font-size: 0.875rem;
+  font-weight: 600;
+  background: transparent;
+  color: var(--text-secondary);
+  border: 1px solid transparent;
+  border-radius: 4px;
+  cursor: pointer;
+}
+`);
+
+block(`
+.chat-tab.active {
+  color: var(--text);
+  border-color: var(--border);
+}
+`);
+
+// Polls and questions
+block(`
+.qa-poll {
+  display: flex;
+  flex-direction: column;
+  gap: 0.375rem;
+  padding: 0.75rem;
+  border: 1px solid var(--border);
+  border-radius: 8px;
+}
+`);
+
+block(`
+.qa-poll-option {
+  position: relative;
+  display: flex;
+  justify-content: space-between;
+  gap: 0.5rem;
+  padding: 0.375rem 0.5rem;
+  font-size: 0.875rem;
+  text-align: left;
+  background: var(--background);
+  color: var(--text);
+  border: 1px solid var(--border);
+  border-radius: 4px;
+  cursor: pointer;
+  overflow: hidden;
+}
+`);
+
+block(`
+.qa-poll-option.mine {
+  border-color: #3b82f6;
+}
+`);
+
+block(`
+.qa-poll-option:disabled {
+  cursor: default;
+}
+`);
+
+block(`
+.qa-poll-bar {
+  position: absolute;
+  top: 0;
+  bottom: 0;
+  left: 0;
+  background: rgba(59, 130, 246, 0.15);
+  transition: width 0.3s;
+}
+`);
+
+block(`
+.qa-poll-label,
+.qa-poll-count {
+  position: relative;
+}
+`);
+
+block(`
+.chat-message.answered {
+  opacity: 0.6;
+}
+`);
+
+// Guest name
+block(`
+.chat-guest-bar {
+  display: flex;
+  gap: 0.5rem;
+  align-items: center;
+  padding: 0.5rem 1rem;
+  font-size: 0.75rem;
+  color: var(--text-secondary);
+  border-bottom: 1px solid var(--border);
+}
+`);
+
+block(`
+.chat-guest-name {
+  flex: 1;
+  color: var(--text);
+  font-weight: 600;
+}
+`);
+
+block(`
+.chat-guest-input {
+  flex: 1;
+  padding: 0.25rem;
+  font-size: 0.75rem;
+  background: var(--background);
+  color: var(--text);
+  border: 1px solid var(--border);
+  border-radius: 4px;
+}
+`);
+
+block(`
+.chat-notice {
+  padding: 0.5rem 1rem;
+  font-size: 0.75rem;
+  color: var(--text-secondary);
+  background: var(--background);
+  border-top: 1px solid var(--border);
+}
+`);
+
 // Empty state
 block(`
 .chat-empty {
@@ -7,6 +7,8 @@ import { VideoControls } from "./VideoControls";
 import { EmotePicker } from "./EmotePicker";
 import { StatsOverlay, type StreamStats } from "./StatsOverlay";
 import { ChatSidebar } from "./ChatSidebar";
+import { ClassQAPanel } from "./ClassQAPanel";
+import { EmoteGlyph } from "../../components/EmoteGlyph";
 import "./stream-styles";
 import "./chat-styles";
 
@@ -16,7 +18,7 @@ type ChatMessage = server.ChatMessage;
 // Emote animation for floating effects
 type EmoteAnimation = {
   id: string; // Unique ID for key
-  emote: string; // Emoji character
+  emote: string; // Emote code (emoji or :name:)
   x: number; // Horizontal position (percentage)
   timestamp: number; // When it was created
 };
@@ -119,6 +121,7 @@ type StreamState = {
   viewerCount: number;
   activeEmotes: EmoteAnimation[];
   emoteCounter: number; // Counter for unique emote IDs
+  roomEmotes: server.StudioEmote[]; // The room's studio emote set
   ignoreNextEmote: string | null; // Ignore next SSE emote matching this (our own emote coming back)
   controlsVisible: boolean;
   controlsAutoHideTimer: number | null;
@@ -170,10 +173,34 @@ type StreamState = {
 
   // Chat state
   chatMessages: ChatMessage[];
+  chatSettings: server.ChatRoomSettings | null;
+  canModerateChat: boolean;
+  canPinChat: boolean;
+  chatPin: server.ChatPinnedAnnouncement | null;
+  isChatGuest: boolean;
+  chatGuestName: string;
+  chatNotice: string;
   isChatVisible: boolean;
+  chatHasMore: boolean; // Older messages exist on the server
+  isLoadingOlderChat: boolean;
+  loadChatHistory: () => void;
+  loadOlderChat: () => void;
   addChatMessage: (msg: ChatMessage) => void;
-  sendChatMessage: (text: string) => void;
+  removeChatMessage: (messageId: number) => void;
+  sendChatMessage: (text: string, replyToId: number) => void;
+  setChatReactions: (
+    messageId: number,
+    reactions: server.ChatReaction[],
+  ) => void;
   toggleChat: () => void;
+
+  // Polls and questions
+  polls: server.Poll[]; // This session's polls, newest first
+  classQuestions: server.ClassQuestion[];
+  canManageClassQA: boolean;
+  loadClassQA: () => void;
+  setPoll: (poll: server.Poll) => void;
+  setClassQuestion: (question: server.ClassQuestion) => void;
 };
 
 type OrientationState = {
@@ -313,9 +340,23 @@ const useStreamPlayer = vlens.declareHook((): StreamState => {
     metricsReportInterval: null,
     metricsReported: false,
 
+    roomEmotes: [],
+
     // Chat state - Initialize
     chatMessages: [],
+    chatSettings: null,
+    canModerateChat: false,
+    canPinChat: false,
+    chatPin: null,
+    isChatGuest: false,
+    chatGuestName: "",
+    chatNotice: "",
     isChatVisible: true, // Default visible on desktop
+    chatHasMore: false,
+    isLoadingOlderChat: false,
+    polls: [],
+    classQuestions: [],
+    canManageClassQA: false,
 
     onVideoRef: (el: HTMLVideoElement | null) => {
       // If same element, do nothing (avoid re-processing during re-renders)
@@ -487,23 +528,89 @@ const useStreamPlayer = vlens.declareHook((): StreamState => {
           console.warn("Failed to send emote:", err);
         });
     },
+    loadChatHistory: () => {
+      server
+        .GetChatHistory({
+          roomId: state.roomId,
+          limit: 100,
+          beforeId: 0,
+          afterId: 0,
+        })
+        .then(([resp, err]) => {
+          if (!err && resp?.messages) {
+            state.chatMessages = resp.messages;
+            state.chatHasMore = resp.hasMore;
+            state.chatSettings = resp.settings;
+            state.canModerateChat = resp.canModerate;
+            state.canPinChat = resp.canPin;
+            state.isChatGuest = resp.isGuest;
+            state.chatGuestName = resp.guestName;
+            vlens.scheduleRedraw();
+          }
+        })
+        .catch((err) => {
+          console.warn("Failed to load chat history:", err);
+        });
+    },
+    loadOlderChat: () => {
+      const oldest = state.chatMessages[0];
+      if (!oldest || state.isLoadingOlderChat) return;
+      state.isLoadingOlderChat = true;
+      vlens.scheduleRedraw();
+
+      server
+        .GetChatHistory({
+          roomId: state.roomId,
+          limit: 100,
+          beforeId: oldest.id,
+          afterId: 0,
+        })
+        .then(([resp, err]) => {
+          if (!err && resp?.messages) {
+            state.chatMessages = resp.messages.concat(state.chatMessages);
+            state.chatHasMore = resp.hasMore;
+          }
+        })
+        .catch((err) => {
+          console.warn("Failed to load earlier chat:", err);
+        })
+        .finally(() => {
+          state.isLoadingOlderChat = false;
+          vlens.scheduleRedraw();
+        });
+    },
     addChatMessage: (msg: ChatMessage) => {
       state.chatMessages.push(msg);
       // Keep only last 200 messages in memory
       if (state.chatMessages.length > 200) {
         state.chatMessages = state.chatMessages.slice(-200);
+        state.chatHasMore = true;
       }
       vlens.scheduleRedraw();
     },
-    sendChatMessage: (text: string) => {
+    removeChatMessage: (messageId: number) => {
+      state.chatMessages = state.chatMessages.filter(
+        (msg) => msg.id !== messageId,
+      );
+      vlens.scheduleRedraw();
+    },
+    sendChatMessage: (text: string, replyToId: number) => {
       // Call API to send message
       // Don't add optimistically - SSE will broadcast it back to us
       server
         .SendChatMessage({
           roomId: state.roomId,
           text: text,
+          replyToId: replyToId,
         })
         .then(([resp, err]) => {
+          // Moderation refusals (slow mode, timeouts) are shown in the chat
+          state.chatNotice = err || "";
+          if (resp && resp.held) {
+            state.chatNotice =
+              "Your message is waiting for a moderator to approve it";
+          }
+          vlens.scheduleRedraw();
           if (err) {
             console.warn("Failed to send chat message:", err);
           }
@@ -512,10 +619,63 @@ const useStreamPlayer = vlens.declareHook((): StreamState => {
           console.warn("Failed to send chat message:", err);
         });
     },
+    setChatReactions: (
+      messageId: number,
+      reactions: server.ChatReaction[],
+    ) => {
+      const msg = state.chatMessages.find((m) => m.id === messageId);
+      if (!msg) return;
+      msg.reactions = reactions;
+      vlens.scheduleRedraw();
+    },
     toggleChat: () => {
       state.isChatVisible = !state.isChatVisible;
       vlens.scheduleRedraw();
     },
+    loadClassQA: () => {
+      Promise.all([
+        server.ListRoomPolls({ roomId: state.roomId }),
+        server.ListClassQuestions({ roomId: state.roomId }),
+      ])
+        .then(([[pollsResp, pollsErr], [questionsResp, questionsErr]]) => {
+          if (!pollsErr && pollsResp) {
+            state.polls = pollsResp.polls || [];
+            state.canManageClassQA = pollsResp.canManage;
+          }
+          if (!questionsErr && questionsResp) {
+            state.classQuestions = questionsResp.questions || [];
+          }
+          vlens.scheduleRedraw();
+        })
+        .catch((err) => {
+          console.warn("Failed to load polls and questions:", err);
+        });
+    },
+    setPoll: (poll: server.Poll) => {
+      const index = state.polls.findIndex((p) => p.id === poll.id);
+      if (index >= 0) {
+        state.polls[index] = poll;
+      } else {
+        state.polls = [poll, ...state.polls];
+      }
+      vlens.scheduleRedraw();
+    },
+    setClassQuestion: (question: server.ClassQuestion) => {
+      const others = state.classQuestions.filter((q) => q.id !== question.id);
+      const hidden =
+        question.status === server.QuestionStatusDismissed &&
+        !state.canManageClassQA;
+      if (hidden) {
+        state.classQuestions = others;
+      } else if (others.length < state.classQuestions.length) {
+        state.classQuestions = state.classQuestions.map((q) =>
+          q.id === question.id ? question : q,
+        );
+      } else {
+        state.classQuestions = [...state.classQuestions, question];
+      }
+      vlens.scheduleRedraw();
+    },
     showControls: () => {
       state.controlsVisible = true;
       vlens.scheduleRedraw();
@@ -565,6 +725,7 @@ const useStreamPlayer = vlens.declareHook((): StreamState => {
       state.eventSource.addEventListener("stream_ready", (e) => {
         state.setHlsReady(true);
         state.retryCount = 0; // Reset retry count now that HLS is ready
+        state.loadClassQA(); // Polls and questions belong to the new session
       });
 
       state.eventSource.addEventListener("code_revoked", (e) => {
@@ -604,9 +765,74 @@ const useStreamPlayer = vlens.declareHook((): StreamState => {
         }
       });
 
+      state.eventSource.addEventListener("chat_message_deleted", (e) => {
+        const data = JSON.parse(e.data);
+        state.removeChatMessage(data.messageId);
+      });
+
+      state.eventSource.addEventListener("chat_reaction", (e) => {
+        const data = JSON.parse(e.data);
+        const msg = state.chatMessages.find((m) => m.id === data.messageId);
+        if (!msg) return;
+        // Broadcasts carry counts only; keep which ones are ours
+        const reactions: server.ChatReaction[] = data.reactions || [];
+        for (const reaction of reactions) {
+          reaction.mine = (msg.reactions || []).some(
+            (r) => r.emote === reaction.emote && r.mine,
+          );
+        }
+        state.setChatReactions(msg.id, reactions);
+      });
+
+      state.eventSource.addEventListener("chat_pinned", (e) => {
+        const pin: server.ChatPinnedAnnouncement = JSON.parse(e.data);
+        state.chatPin = pin.text ? pin : null;
+        vlens.scheduleRedraw();
+      });
+
+      state.eventSource.addEventListener("poll", (e) => {
+        const poll: server.Poll = JSON.parse(e.data);
+        // Broadcasts carry tallies only; keep our own vote
+        const existing = state.polls.find((p) => p.id === poll.id);
+        poll.myVote = existing ? existing.myVote : -1;
+        state.setPoll(poll);
+      });
+
+      state.eventSource.addEventListener("question", (e) => {
+        const question: server.ClassQuestion = JSON.parse(e.data);
+        const existing = state.classQuestions.find((q) => q.id === question.id);
+        question.mine = existing ? existing.mine : false;
+        state.setClassQuestion(question);
+      });
+
+      state.eventSource.addEventListener("chat_settings", (e) => {
+        const data = JSON.parse(e.data);
+        if (state.chatSettings) {
+          state.chatSettings.slowModeSeconds = data.slowModeSeconds;
+          state.chatSettings.chatDisabled = data.chatDisabled;
+        }
+        vlens.scheduleRedraw();
+      });
+
+      state.eventSource.addEventListener("chat_restricted", (e) => {
+        const data = JSON.parse(e.data);
+        state.chatNotice =
+          data.kind === server.ChatRestrictionBan
+            ? "You have been banned from this chat"
+            : "You have been timed out until " +
+              new Date(data.expiresAt).toLocaleTimeString();
+        vlens.scheduleRedraw();
+      });
+
+      // The server couldn't replay what we missed while reconnecting
+      state.eventSource.addEventListener("resync", (e) => {
+        state.loadChatHistory();
+        state.loadClassQA();
+      });
+
       state.eventSource.onerror = (err) => {
         console.warn("SSE error, will auto-reconnect:", err);
-        // Browser auto-reconnects, no action needed
+        // Browser auto-reconnects and resumes with Last-Event-ID
       };
     },
     disconnectSSE: () => {
@@ -1441,21 +1667,15 @@ export function view(
     state.setStreamLive(data.room.isActive);
     state.setHlsReady(data.room.isHlsReady || false);
 
-    // Load chat history
-    server
-      .GetChatHistory({
-        roomId: data.room.id,
-        limit: 100,
-      })
-      .then(([resp, err]) => {
-        if (!err && resp?.messages) {
-          state.chatMessages = resp.messages;
-          vlens.scheduleRedraw();
-        }
-      })
-      .catch((err) => {
-        console.warn("Failed to load chat history:", err);
-      });
+    // Load the studio's emotes and chat history
+    server.GetRoomEmotes({ roomId: state.roomId }).then(([resp, err]) => {
+      if (!err && resp) {
+        state.roomEmotes = resp.emotes;
+        vlens.scheduleRedraw();
+      }
+    });
+    state.loadChatHistory();
+    state.loadClassQA();
 
     state.connectSSE();
 
@@ -1646,7 +1866,7 @@ export function view(
                     className="floating-emote"
                     style={{ left: `${emote.x}%` }}
                   >
-                    {emote.emote}
+                    <EmoteGlyph code={emote.emote} emotes={state.roomEmotes} />
                   </div>
                 ))}
               </div>
@@ -1671,6 +1891,7 @@ export function view(
               <EmotePicker
                 id={`emote-picker-${data.room?.id || 0}`}
                 controlsVisible={state.controlsVisible}
+                emotes={state.roomEmotes}
                 onLocalEmote={state.addEmote}
                 onSendEmote={state.sendEmote}
               />
@@ -1688,7 +1909,38 @@ export function view(
                 id={`chat-${data.room?.id || 0}`}
                 roomId={data.room?.id || 0}
                 messages={state.chatMessages}
+                emotes={state.roomEmotes}
+                hasMore={state.chatHasMore}
+                isLoadingOlder={state.isLoadingOlderChat}
+                onLoadOlder={state.loadOlderChat}
+                settings={state.chatSettings}
+                canModerate={state.canModerateChat}
+                canPin={state.canPinChat}
+                pin={state.chatPin}
+                onPinChange={(pin) => {
+                  state.chatPin = pin.text ? pin : null;
+                  vlens.scheduleRedraw();
+                }}
+                onReactionsChange={state.setChatReactions}
+                notice={state.chatNotice}
+                isGuest={state.isChatGuest}
+                guestName={state.chatGuestName}
+                onGuestNameChange={(name) => {
+                  state.chatGuestName = name;
+                  vlens.scheduleRedraw();
+                }}
                 onSendMessage={state.sendChatMessage}
+                qa={
+                  <ClassQAPanel
+                    roomId={data.room?.id || 0}
+                    polls={state.polls}
+                    questions={state.classQuestions}
+                    canManage={state.canManageClassQA}
+                    onPollChange={state.setPoll}
+                    onQuestionChange={state.setClassQuestion}
+                  />
+                }
+                hasOpenPoll={state.polls.some((p) => !p.isClosed)}
                 onClose={state.toggleChat}
               />
             )}
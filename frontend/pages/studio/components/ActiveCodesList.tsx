@@ -196,7 +196,12 @@ function convertGifToPng(gifDataUrl: string): Promise<string> {
   });
 }
 
-async function openQrModal(
+async // Download per-session watch time for a code as CSV
+function exportWatchReport(code: string) {
+  window.location.href = `/api/watch-report/export?code=${encodeURIComponent(code)}`;
+}
+
+function openQrModal(
   state: ActiveCodesListState,
   code: string,
   label: string,
@@ -408,6 +413,9 @@ export function ActiveCodesList(props: ActiveCodesListProps) {
                   </td>
                   <td className="label-cell">
                     {code.label || <em>No label</em>}
+                    {code.recipientName && (
+                      <div className="form-help">For {code.recipientName}</div>
+                    )}
                   </td>
                   <td className="scope-cell">
                     <span
@@ -465,6 +473,11 @@ export function ActiveCodesList(props: ActiveCodesListProps) {
                         </DropdownItem>
                       )}
                       <DropdownItem onClick={() => {}}>Analytics</DropdownItem>
+                      <DropdownItem
+                        onClick={() => exportWatchReport(code.code)}
+                      >
+                        Export Watch Report
+                      </DropdownItem>
                     </Dropdown>
                   </td>
                 </tr>
@@ -0,0 +1,262 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as server from "../../../server";
+import { Modal } from "../../../components/Modal";
+
+type ChatArchivesSectionProps = {
+  studioId: number;
+  canModerate: boolean;
+};
+
+// Mirrors CHAT_RETENTION_OFF and CHAT_RETENTION_FOREVER on the server
+const RETENTION_OFF = 0;
+const RETENTION_FOREVER = -1;
+
+const RETENTION_OPTIONS = [
+  { days: RETENTION_OFF, label: "Don't keep chat" },
+  { days: 7, label: "7 days" },
+  { days: 30, label: "30 days" },
+  { days: 90, label: "90 days" },
+  { days: 365, label: "1 year" },
+  { days: RETENTION_FOREVER, label: "Forever" },
+];
+
+type ArchivesState = {
+  loaded: boolean;
+  error: string;
+  retentionDays: number;
+  archives: server.ChatArchiveItem[];
+  replay: server.GetStreamChatReplayResponse | null;
+};
+
+const useArchives = vlens.declareHook(
+  (studioId: number): ArchivesState => ({
+    loaded: false,
+    error: "",
+    retentionDays: 0,
+    archives: [],
+    replay: null,
+  }),
+);
+
+async function loadArchives(state: ArchivesState, studioId: number) {
+  const [[settingsResp, settingsErr], [listResp, listErr]] =
+    await Promise.all([
+      server.GetChatArchiveSettings({ studioId }),
+      server.ListChatArchives({ studioId, limit: 0 }),
+    ]);
+  state.error = settingsErr || listErr || "";
+  if (settingsResp) {
+    state.retentionDays = settingsResp.settings.retentionDays;
+  }
+  state.archives = (listResp && listResp.archives) || [];
+  vlens.scheduleRedraw();
+}
+
+async function updateRetention(
+  state: ArchivesState,
+  studioId: number,
+  event: Event,
+) {
+  const retentionDays = Number((event.target as HTMLSelectElement).value);
+  if (
+    retentionDays === RETENTION_OFF &&
+    !confirm(
+      "Stop keeping chat? Chat from future streams will be cleared when they end.",
+    )
+  ) {
+    vlens.scheduleRedraw();
+    return;
+  }
+  const [resp, err] = await server.UpdateChatArchiveSettings({
+    studioId,
+    retentionDays,
+  });
+  if (err || !resp) {
+    state.error = err || "Failed to update chat retention";
+  } else {
+    state.retentionDays = resp.settings.retentionDays;
+    state.error = "";
+  }
+  vlens.scheduleRedraw();
+}
+
+async function openReplay(state: ArchivesState, streamId: number) {
+  const [resp, err] = await server.GetStreamChatReplay({ streamId });
+  if (err || !resp) {
+    state.error = err || "Failed to load chat";
+  } else {
+    state.replay = resp;
+    state.error = "";
+  }
+  vlens.scheduleRedraw();
+}
+
+async function downloadTranscript(state: ArchivesState, streamId: number) {
+  const [resp, err] = await server.ExportChatTranscript({ streamId });
+  if (err || !resp) {
+    state.error = err || "Failed to export transcript";
+    vlens.scheduleRedraw();
+    return;
+  }
+  const blob = new Blob([resp.transcript], { type: "text/plain" });
+  const url = URL.createObjectURL(blob);
+  const link = document.createElement("a");
+  link.href = url;
+  link.download = resp.fileName;
+  link.click();
+  URL.revokeObjectURL(url);
+}
+
+// Seconds since the stream started, as H:MM:SS
+function formatOffset(seconds: number): string {
+  const h = Math.floor(seconds / 3600);
+  const m = Math.floor(seconds / 60) % 60;
+  const s = seconds % 60;
+  const pad = (n: number) => n.toString().padStart(2, "0");
+  return `${h}:${pad(m)}:${pad(s)}`;
+}
+
+function retentionLabel(days: number): string {
+  const option = RETENTION_OPTIONS.find((o) => o.days === days);
+  return option ? option.label : `${days} days`;
+}
+
+// ===== Component =====
+export function ChatArchivesSection(
+  props: ChatArchivesSectionProps,
+): preact.ComponentChild {
+  const { studioId, canModerate } = props;
+  const state = useArchives(studioId);
+
+  if (!state.loaded) {
+    state.loaded = true;
+    loadArchives(state, studioId);
+  }
+
+  const replay = state.replay;
+
+  return (
+    <>
+      <div className="members-section">
+        <div className="members-header">
+          <h2 className="section-title">Chat Archives</h2>
+          <div className="table-actions">
+            {canModerate ? (
+              <select
+                className="form-input"
+                value={state.retentionDays}
+                onChange={(e) => updateRetention(state, studioId, e)}
+              >
+                {RETENTION_OPTIONS.map((option) => (
+                  <option key={option.days} value={option.days}>
+                    Keep chat: {option.label}
+                  </option>
+                ))}
+              </select>
+            ) : (
+              <span className="form-help">
+                Chat is kept: {retentionLabel(state.retentionDays)}
+              </span>
+            )}
+          </div>
+        </div>
+
+        {state.error && <div className="error-message">{state.error}</div>}
+
+        {state.archives.length === 0 ? (
+          <p className="form-help">Chat from past streams will show up here.</p>
+        ) : (
+          <div className="members-table-wrapper">
+            <table className="members-table">
+              <thead>
+                <tr>
+                  <th>Stream</th>
+                  <th>Room</th>
+                  <th>Messages</th>
+                  <th>Kept Until</th>
+                  <th>Actions</th>
+                </tr>
+              </thead>
+              <tbody>
+                {state.archives.map((archive) => (
+                  <tr key={archive.stream.id}>
+                    <td>
+                      {new Date(archive.stream.startTime).toLocaleString()}
+                    </td>
+                    <td>{archive.roomName}</td>
+                    <td>{archive.messageCount}</td>
+                    <td>
+                      {new Date(archive.expiresAt).getFullYear() > 1
+                        ? new Date(archive.expiresAt).toLocaleDateString()
+                        : "Forever"}
+                    </td>
+                    <td className="table-actions">
+                      <button
+                        className="btn btn-secondary btn-sm"
+                        onClick={() => openReplay(state, archive.stream.id)}
+                      >
+                        Replay
+                      </button>
+                      {canModerate && (
+                        <button
+                          className="btn btn-secondary btn-sm"
+                          onClick={() =>
+                            downloadTranscript(state, archive.stream.id)
+                          }
+                        >
+                          Transcript
+                        </button>
+                      )}
+                    </td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </div>
+
+      <Modal
+        isOpen={replay !== null}
+        title={replay ? `Chat: ${replay.roomName}` : "Chat"}
+        onClose={() => {
+          state.replay = null;
+          vlens.scheduleRedraw();
+        }}
+        footer={
+          <button
+            className="btn btn-secondary"
+            onClick={() => {
+              state.replay = null;
+              vlens.scheduleRedraw();
+            }}
+          >
+            Close
+          </button>
+        }
+      >
+        {replay && (
+          <div className="members-table-wrapper">
+            <p className="form-help">
+              Stream started{" "}
+              {new Date(replay.stream.startTime).toLocaleString()}. Times are
+              from the start of the stream.
+            </p>
+            <table className="members-table">
+              <tbody>
+                {replay.messages.map((msg) => (
+                  <tr key={msg.id}>
+                    <td>{formatOffset(msg.offsetSeconds)}</td>
+                    <td>{msg.userName}</td>
+                    <td>{msg.text}</td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </Modal>
+    </>
+  );
+}
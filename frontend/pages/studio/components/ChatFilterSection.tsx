@@ -0,0 +1,353 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as server from "../../../server";
+
+type ChatFilterSectionProps = {
+  studioId: number;
+};
+
+type FilterState = {
+  loaded: boolean;
+  isSaving: boolean;
+  error: string;
+  saved: boolean;
+  defaultTerms: string[];
+  disabled: boolean;
+  useDefaultList: boolean;
+  blockLinks: boolean;
+  holdForReview: boolean;
+  blockedTerms: string;
+  allowedTerms: string;
+  entries: server.ChatFilterLogItem[];
+  showDefaultTerms: boolean;
+};
+
+type ToggleField =
+  | "disabled"
+  | "useDefaultList"
+  | "blockLinks"
+  | "holdForReview";
+
+const useChatFilter = vlens.declareHook(
+  (studioId: number): FilterState => ({
+    loaded: false,
+    isSaving: false,
+    error: "",
+    saved: false,
+    defaultTerms: [],
+    disabled: false,
+    useDefaultList: true,
+    blockLinks: false,
+    holdForReview: false,
+    blockedTerms: "",
+    allowedTerms: "",
+    entries: [],
+    showDefaultTerms: false,
+  }),
+);
+
+function applySettings(
+  state: FilterState,
+  settings: server.ChatFilterSettings,
+) {
+  state.disabled = settings.disabled;
+  state.useDefaultList = settings.useDefaultList;
+  state.blockLinks = settings.blockLinks;
+  state.holdForReview = settings.action === server.ChatFilterHold;
+  state.blockedTerms = (settings.blockedTerms || []).join("\n");
+  state.allowedTerms = (settings.allowedTerms || []).join("\n");
+}
+
+async function loadFilter(state: FilterState, studioId: number) {
+  const [[settingsResp, settingsErr], [logResp, logErr]] = await Promise.all([
+    server.GetChatFilterSettings({ studioId }),
+    server.ListChatFilterLog({ studioId, pendingOnly: false, limit: 50 }),
+  ]);
+  if (settingsErr || !settingsResp) {
+    state.error = settingsErr || "Failed to load chat filter";
+  } else {
+    applySettings(state, settingsResp.settings);
+    state.defaultTerms = settingsResp.defaultTerms || [];
+    state.error = logErr || "";
+  }
+  state.entries = (logResp && logResp.entries) || [];
+  vlens.scheduleRedraw();
+}
+
+// Terms are entered one per line or comma separated
+function parseTerms(text: string): string[] {
+  return text
+    .split(/[\n,]/)
+    .map((term) => term.trim())
+    .filter((term) => term !== "");
+}
+
+async function saveFilter(state: FilterState, studioId: number) {
+  state.isSaving = true;
+  state.saved = false;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.UpdateChatFilterSettings({
+    studioId,
+    disabled: state.disabled,
+    useDefaultList: state.useDefaultList,
+    blockedTerms: parseTerms(state.blockedTerms),
+    allowedTerms: parseTerms(state.allowedTerms),
+    blockLinks: state.blockLinks,
+    action: state.holdForReview
+      ? server.ChatFilterHold
+      : server.ChatFilterBlock,
+  });
+
+  state.isSaving = false;
+  if (err || !resp) {
+    state.error = err || "Failed to save chat filter";
+  } else {
+    applySettings(state, resp.settings);
+    state.saved = true;
+  }
+  vlens.scheduleRedraw();
+}
+
+async function reviewEntry(
+  state: FilterState,
+  entry: server.ChatFilterLogItem,
+  approve: boolean,
+) {
+  const [resp, err] = await server.ReviewHeldChatMessage({
+    entryId: entry.id,
+    approve,
+  });
+  if (err || !resp) {
+    state.error = err || "Failed to review message";
+  } else {
+    entry.status = resp.entry.status;
+    state.error = "";
+  }
+  vlens.scheduleRedraw();
+}
+
+function statusLabel(status: server.ChatFilterStatus): string {
+  switch (status) {
+    case server.ChatFilterStatusPending:
+      return "Held";
+    case server.ChatFilterStatusApproved:
+      return "Approved";
+    case server.ChatFilterStatusRejected:
+      return "Rejected";
+    default:
+      return "Blocked";
+  }
+}
+
+function CheckboxField(props: {
+  id: string;
+  label: string;
+  checked: boolean;
+  disabled: boolean;
+  onChange: (checked: boolean) => void;
+}) {
+  return (
+    <div className="checkbox-toggle">
+      <input
+        type="checkbox"
+        id={props.id}
+        checked={props.checked}
+        disabled={props.disabled}
+        onChange={(e) =>
+          props.onChange((e.target as HTMLInputElement).checked)
+        }
+      />
+      <label htmlFor={props.id}>{props.label}</label>
+    </div>
+  );
+}
+
+// ===== Component =====
+export function ChatFilterSection(
+  props: ChatFilterSectionProps,
+): preact.ComponentChild {
+  const { studioId } = props;
+  const state = useChatFilter(studioId);
+
+  if (!state.loaded) {
+    state.loaded = true;
+    loadFilter(state, studioId);
+  }
+
+  const set = (field: ToggleField) => (checked: boolean) => {
+    state[field] = checked;
+    state.saved = false;
+    vlens.scheduleRedraw();
+  };
+  const pending = state.entries.filter(
+    (e) => e.status === server.ChatFilterStatusPending,
+  );
+
+  return (
+    <div className="members-section">
+      <div className="members-header">
+        <h2 className="section-title">Chat Filter</h2>
+      </div>
+
+      {state.error && <div className="error-message">{state.error}</div>}
+
+      <div className="form-group">
+        <CheckboxField
+          id="chat-filter-enabled"
+          label="Filter chat messages"
+          checked={!state.disabled}
+          disabled={state.isSaving}
+          onChange={(checked) => set("disabled")(!checked)}
+        />
+        <CheckboxField
+          id="chat-filter-default"
+          label="Use the built-in profanity list"
+          checked={state.useDefaultList}
+          disabled={state.isSaving || state.disabled}
+          onChange={set("useDefaultList")}
+        />
+        <CheckboxField
+          id="chat-filter-links"
+          label="Block links"
+          checked={state.blockLinks}
+          disabled={state.isSaving || state.disabled}
+          onChange={set("blockLinks")}
+        />
+        <CheckboxField
+          id="chat-filter-hold"
+          label="Hold flagged messages for review instead of blocking them"
+          checked={state.holdForReview}
+          disabled={state.isSaving || state.disabled}
+          onChange={set("holdForReview")}
+        />
+        {state.defaultTerms.length > 0 && (
+          <small className="form-help">
+            <a
+              href="#"
+              onClick={(e) => {
+                e.preventDefault();
+                state.showDefaultTerms = !state.showDefaultTerms;
+                vlens.scheduleRedraw();
+              }}
+            >
+              {state.showDefaultTerms ? "Hide" : "Show"} built-in list
+            </a>
+            {state.showDefaultTerms && `: ${state.defaultTerms.join(", ")}`}
+          </small>
+        )}
+      </div>
+
+      <div className="form-group">
+        <label htmlFor="chat-filter-blocked">Blocked terms</label>
+        <textarea
+          id="chat-filter-blocked"
+          className="form-input"
+          rows={4}
+          placeholder="One per line"
+          {...vlens.attrsBindInput(vlens.ref(state, "blockedTerms"))}
+          disabled={state.isSaving || state.disabled}
+        />
+      </div>
+
+      <div className="form-group">
+        <label htmlFor="chat-filter-allowed">Allowed terms</label>
+        <textarea
+          id="chat-filter-allowed"
+          className="form-input"
+          rows={2}
+          placeholder="One per line"
+          {...vlens.attrsBindInput(vlens.ref(state, "allowedTerms"))}
+          disabled={state.isSaving || state.disabled}
+        />
+        <small className="form-help">
+          Allowed terms are never flagged, even if a blocked list has them.
+          Moderators' own messages are not filtered.
+        </small>
+      </div>
+
+      <div className="table-actions">
+        <button
+          className="btn btn-primary btn-sm"
+          onClick={() => saveFilter(state, studioId)}
+          disabled={state.isSaving}
+        >
+          {state.isSaving ? "Saving..." : "Save Filter"}
+        </button>
+        {state.saved && <span className="form-help">Saved</span>}
+      </div>
+
+      {pending.length > 0 && (
+        <div className="members-table-wrapper">
+          <h3>Held for Review ({pending.length})</h3>
+          <table className="members-table">
+            <thead>
+              <tr>
+                <th>From</th>
+                <th>Room</th>
+                <th>Message</th>
+                <th>Reason</th>
+                <th>Actions</th>
+              </tr>
+            </thead>
+            <tbody>
+              {pending.map((entry) => (
+                <tr key={entry.id}>
+                  <td>{entry.userName}</td>
+                  <td>{entry.roomName}</td>
+                  <td>{entry.text}</td>
+                  <td>{entry.reason}</td>
+                  <td className="table-actions">
+                    <button
+                      className="btn btn-primary btn-sm"
+                      onClick={() => reviewEntry(state, entry, true)}
+                    >
+                      Approve
+                    </button>
+                    <button
+                      className="btn btn-danger btn-sm"
+                      onClick={() => reviewEntry(state, entry, false)}
+                    >
+                      Reject
+                    </button>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+      )}
+
+      {state.entries.length > 0 && (
+        <div className="members-table-wrapper">
+          <h3>Filtered Messages</h3>
+          <table className="members-table">
+            <thead>
+              <tr>
+                <th>When</th>
+                <th>From</th>
+                <th>Room</th>
+                <th>Message</th>
+                <th>Reason</th>
+                <th>Status</th>
+              </tr>
+            </thead>
+            <tbody>
+              {state.entries.map((entry) => (
+                <tr key={entry.id}>
+                  <td>{new Date(entry.createdAt).toLocaleString()}</td>
+                  <td>{entry.userName}</td>
+                  <td>{entry.roomName}</td>
+                  <td>{entry.text}</td>
+                  <td>{entry.reason}</td>
+                  <td>{statusLabel(entry.status)}</td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+      )}
+    </div>
+  );
+}
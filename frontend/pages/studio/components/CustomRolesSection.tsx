@@ -0,0 +1,388 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as server from "../../../server";
+import { Modal } from "../../../components/Modal";
+
+type Member = {
+  userId: number;
+  userName: string;
+  role: number;
+  customRoleId: number;
+};
+
+type CustomRolesSectionProps = {
+  studioId: number;
+  members: Member[];
+  myRole: number;
+};
+
+// ===== Roles =====
+type RolesState = {
+  loaded: boolean;
+  capabilities: server.StudioCapabilityInfo[];
+  builtinRoles: server.BuiltinStudioRole[];
+  customRoles: server.StudioCustomRole[];
+  myCapabilities: server.StudioCapability[];
+  error: string;
+};
+
+const useRoles = vlens.declareHook(
+  (studioId: number): RolesState => ({
+    loaded: false,
+    capabilities: [],
+    builtinRoles: [],
+    customRoles: [],
+    myCapabilities: [],
+    error: "",
+  }),
+);
+
+async function loadRoles(state: RolesState, studioId: number) {
+  const [resp, err] = await server.ListStudioRoles({ studioId });
+  state.loaded = true;
+  if (err || !resp) {
+    state.error = err || "Failed to load roles";
+  } else {
+    state.capabilities = resp.capabilities || [];
+    state.builtinRoles = resp.builtinRoles || [];
+    state.customRoles = resp.customRoles || [];
+    state.myCapabilities = resp.myCapabilities || [];
+    state.error = "";
+  }
+  vlens.scheduleRedraw();
+}
+
+async function deleteRole(
+  state: RolesState,
+  studioId: number,
+  role: server.StudioCustomRole,
+) {
+  if (
+    !confirm(
+      `Delete the ${role.name} role? Members with it go back to their built-in role.`,
+    )
+  ) {
+    return;
+  }
+  const [resp, err] = await server.DeleteStudioCustomRole({
+    roleId: role.id,
+  });
+  if (err) {
+    state.error = err;
+    vlens.scheduleRedraw();
+    return;
+  }
+  window.location.reload();
+}
+
+async function assignRole(
+  state: RolesState,
+  studioId: number,
+  userId: number,
+  event: Event,
+) {
+  const customRoleId = Number((event.target as HTMLSelectElement).value);
+  const [resp, err] = await server.SetMemberCustomRole({
+    studioId,
+    userId,
+    customRoleId,
+  });
+  if (err) {
+    state.error = err;
+    vlens.scheduleRedraw();
+    return;
+  }
+  window.location.reload();
+}
+
+function capabilityNames(
+  state: RolesState,
+  caps: server.StudioCapability[],
+): string {
+  return state.capabilities
+    .filter((info) => caps.includes(info.capability))
+    .map((info) => info.name)
+    .join(", ");
+}
+
+// ===== Edit Role Modal =====
+type EditRoleModal = {
+  isOpen: boolean;
+  isSubmitting: boolean;
+  error: string;
+  roleId: number;
+  name: string;
+  capabilities: server.StudioCapability[];
+};
+
+const useEditRoleModal = vlens.declareHook(
+  (): EditRoleModal => ({
+    isOpen: false,
+    isSubmitting: false,
+    error: "",
+    roleId: 0,
+    name: "",
+    capabilities: [],
+  }),
+);
+
+function openEditRoleModal(
+  modal: EditRoleModal,
+  role: server.StudioCustomRole | null,
+) {
+  modal.isOpen = true;
+  modal.error = "";
+  modal.roleId = role ? role.id : 0;
+  modal.name = role ? role.name : "";
+  modal.capabilities = role ? [...(role.capabilities || [])] : [];
+  vlens.scheduleRedraw();
+}
+
+function closeEditRoleModal(modal: EditRoleModal) {
+  modal.isOpen = false;
+  modal.error = "";
+  vlens.scheduleRedraw();
+}
+
+function toggleCapability(
+  modal: EditRoleModal,
+  capability: server.StudioCapability,
+) {
+  if (modal.capabilities.includes(capability)) {
+    modal.capabilities = modal.capabilities.filter((c) => c !== capability);
+  } else {
+    modal.capabilities = [...modal.capabilities, capability];
+  }
+  vlens.scheduleRedraw();
+}
+
+async function submitEditRole(modal: EditRoleModal, studioId: number) {
+  if (!modal.name.trim()) {
+    modal.error = "Role name is required";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  modal.isSubmitting = true;
+  modal.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.SaveStudioCustomRole({
+    studioId,
+    roleId: modal.roleId,
+    name: modal.name.trim(),
+    capabilities: modal.capabilities,
+  });
+
+  modal.isSubmitting = false;
+
+  if (err) {
+    modal.error = err || "Failed to save role";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  closeEditRoleModal(modal);
+  window.location.reload();
+}
+
+// ===== Component =====
+export function CustomRolesSection(
+  props: CustomRolesSectionProps,
+): preact.ComponentChild {
+  const { studioId, members, myRole } = props;
+  const isOwner = myRole === server.StudioRoleOwner;
+  const state = useRoles(studioId);
+  const editRoleModal = useEditRoleModal();
+
+  if (!state.loaded) {
+    state.loaded = true;
+    loadRoles(state, studioId);
+  }
+
+  // Admins can only hand out roles within their own capabilities
+  const assignable = state.customRoles.filter((role) =>
+    (role.capabilities || []).every((c) => state.myCapabilities.includes(c)),
+  );
+  const editableMembers = members.filter(
+    (m) =>
+      m.role !== server.StudioRoleOwner &&
+      (isOwner || m.role < server.StudioRoleAdmin),
+  );
+
+  return (
+    <>
+      <div className="members-section">
+        <div className="members-header">
+          <h2 className="section-title">Roles &amp; Capabilities</h2>
+          {isOwner && (
+            <div className="table-actions">
+              <button
+                className="btn btn-primary btn-sm"
+                onClick={() => openEditRoleModal(editRoleModal, null)}
+              >
+                New Role
+              </button>
+            </div>
+          )}
+        </div>
+
+        {state.error && <div className="error-message">{state.error}</div>}
+
+        <div className="members-table-wrapper">
+          <table className="members-table">
+            <thead>
+              <tr>
+                <th>Role</th>
+                <th>Can</th>
+                {isOwner && <th>Actions</th>}
+              </tr>
+            </thead>
+            <tbody>
+              {state.builtinRoles.map((role) => (
+                <tr key={`builtin-${role.role}`}>
+                  <td>{role.name}</td>
+                  <td>{capabilityNames(state, role.capabilities || [])}</td>
+                  {isOwner && <td></td>}
+                </tr>
+              ))}
+              {state.customRoles.map((role) => (
+                <tr key={role.id}>
+                  <td>{role.name}</td>
+                  <td>{capabilityNames(state, role.capabilities || [])}</td>
+                  {isOwner && (
+                    <td className="table-actions">
+                      <button
+                        className="btn btn-secondary btn-sm"
+                        onClick={() => openEditRoleModal(editRoleModal, role)}
+                      >
+                        Edit
+                      </button>
+                      <button
+                        className="btn btn-danger btn-sm"
+                        onClick={() => deleteRole(state, studioId, role)}
+                      >
+                        Delete
+                      </button>
+                    </td>
+                  )}
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+
+        {state.customRoles.length > 0 && editableMembers.length > 0 && (
+          <div className="members-table-wrapper">
+            <h3>Member Capabilities</h3>
+            <table className="members-table">
+              <thead>
+                <tr>
+                  <th>Member</th>
+                  <th>Custom Role</th>
+                </tr>
+              </thead>
+              <tbody>
+                {editableMembers.map((member) => (
+                  <tr key={member.userId}>
+                    <td>{member.userName}</td>
+                    <td>
+                      <select
+                        className="form-input"
+                        value={member.customRoleId || 0}
+                        onChange={(e) =>
+                          assignRole(state, studioId, member.userId, e)
+                        }
+                      >
+                        <option value={0}>Built-in role defaults</option>
+                        {state.customRoles.map((role) => (
+                          <option
+                            key={role.id}
+                            value={role.id}
+                            disabled={
+                              !assignable.includes(role) &&
+                              role.id !== member.customRoleId
+                            }
+                          >
+                            {role.name}
+                          </option>
+                        ))}
+                      </select>
+                    </td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </div>
+
+      <Modal
+        isOpen={editRoleModal.isOpen}
+        title={editRoleModal.roleId ? "Edit Role" : "New Role"}
+        onClose={() => closeEditRoleModal(editRoleModal)}
+        error={editRoleModal.error}
+        footer={
+          <>
+            <button
+              className="btn btn-secondary"
+              onClick={() => closeEditRoleModal(editRoleModal)}
+              disabled={editRoleModal.isSubmitting}
+            >
+              Cancel
+            </button>
+            <button
+              className="btn btn-primary"
+              onClick={() => submitEditRole(editRoleModal, studioId)}
+              disabled={editRoleModal.isSubmitting}
+            >
+              {editRoleModal.isSubmitting ? "Saving..." : "Save Role"}
+            </button>
+          </>
+        }
+      >
+        <div className="form-group">
+          <label htmlFor="custom-role-name">Name *</label>
+          <input
+            id="custom-role-name"
+            type="text"
+            className="form-input"
+            placeholder="Camera Operator"
+            {...vlens.attrsBindInput(vlens.ref(editRoleModal, "name"))}
+            disabled={editRoleModal.isSubmitting}
+          />
+        </div>
+
+        <div className="form-group">
+          <label>Capabilities</label>
+          {state.capabilities.map((info) => (
+            <div key={info.capability} className="checkbox-toggle">
+              <input
+                type="checkbox"
+                id={`capability-${info.capability}`}
+                checked={
+                  info.capability === server.CapViewStudio ||
+                  editRoleModal.capabilities.includes(info.capability)
+                }
+                disabled={
+                  info.capability === server.CapViewStudio ||
+                  editRoleModal.isSubmitting
+                }
+                onChange={() =>
+                  toggleCapability(editRoleModal, info.capability)
+                }
+              />
+              <label htmlFor={`capability-${info.capability}`}>
+                {info.name} - {info.description}
+              </label>
+            </div>
+          ))}
+          <small className="form-help">
+            Every member can view the studio. Owners always have every
+            capability.
+          </small>
+        </div>
+      </Modal>
+    </>
+  );
+}
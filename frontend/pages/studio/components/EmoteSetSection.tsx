@@ -0,0 +1,302 @@
+import * as preact from "preact";
+import * as vlens from "vlens";
+import * as server from "../../../server";
+import { EmoteGlyph } from "../../../components/EmoteGlyph";
+
+type EmoteSetSectionProps = {
+  studioId: number;
+  canManage: boolean; // Studio admins edit the set
+};
+
+type EmoteSetState = {
+  loaded: boolean;
+  isSaving: boolean;
+  error: string;
+  saved: boolean;
+  emotes: server.StudioEmote[];
+  isDefault: boolean;
+  newEmoji: string;
+  uploadName: string;
+  applause: server.StreamApplause[];
+};
+
+const useEmoteSet = vlens.declareHook(
+  (studioId: number): EmoteSetState => ({
+    loaded: false,
+    isSaving: false,
+    error: "",
+    saved: false,
+    emotes: [],
+    isDefault: true,
+    newEmoji: "",
+    uploadName: "",
+    applause: [],
+  }),
+);
+
+async function loadEmoteSet(state: EmoteSetState, studioId: number) {
+  const [[setResp, setErr], [applauseResp, applauseErr]] = await Promise.all([
+    server.GetStudioEmoteSet({ studioId }),
+    server.ListStreamApplause({ studioId, limit: 10 }),
+  ]);
+  if (setErr || !setResp) {
+    state.error = setErr || "Failed to load emotes";
+  } else {
+    state.emotes = setResp.emotes || [];
+    state.isDefault = setResp.isDefault;
+    state.error = applauseErr || "";
+  }
+  state.applause = (applauseResp && applauseResp.streams) || [];
+  vlens.scheduleRedraw();
+}
+
+// An empty list resets the studio to the default emotes
+async function saveEmoteSet(
+  state: EmoteSetState,
+  studioId: number,
+  emotes: server.StudioEmote[],
+) {
+  state.isSaving = true;
+  state.saved = false;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.UpdateStudioEmoteSet({ studioId, emotes });
+
+  state.isSaving = false;
+  if (err || !resp) {
+    state.error = err || "Failed to save emotes";
+  } else {
+    state.emotes = resp.emotes || [];
+    state.isDefault = emotes.length === 0;
+    state.newEmoji = "";
+    state.saved = true;
+  }
+  vlens.scheduleRedraw();
+}
+
+function addEmoji(state: EmoteSetState, studioId: number) {
+  const code = state.newEmoji.trim();
+  if (!code) return;
+  if (state.emotes.some((e) => e.code === code)) {
+    state.error = "That emote is already in the set";
+    vlens.scheduleRedraw();
+    return;
+  }
+  saveEmoteSet(state, studioId, [...state.emotes, { code, imageUrl: "" }]);
+}
+
+function removeEmote(
+  state: EmoteSetState,
+  studioId: number,
+  code: string,
+) {
+  const remaining = state.emotes.filter((e) => e.code !== code);
+  if (remaining.length === 0) {
+    state.error = "A studio needs at least one emote; use Reset instead";
+    vlens.scheduleRedraw();
+    return;
+  }
+  saveEmoteSet(state, studioId, remaining);
+}
+
+// Images are sent as data URLs; the server checks type, size and dimensions
+function readFileAsDataURL(file: File): Promise<string> {
+  return new Promise((resolve, reject) => {
+    const reader = new FileReader();
+    reader.onload = () => resolve(reader.result as string);
+    reader.onerror = () => reject(reader.error);
+    reader.readAsDataURL(file);
+  });
+}
+
+async function uploadEmote(
+  state: EmoteSetState,
+  studioId: number,
+  input: HTMLInputElement,
+) {
+  const file = input.files && input.files[0];
+  if (!file) return;
+
+  state.isSaving = true;
+  state.saved = false;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  let imageData = "";
+  try {
+    imageData = await readFileAsDataURL(file);
+  } catch {
+    state.isSaving = false;
+    state.error = "Couldn't read that image";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  const [resp, err] = await server.UploadStudioEmote({
+    studioId,
+    name: state.uploadName.trim(),
+    imageData,
+  });
+
+  state.isSaving = false;
+  input.value = "";
+  if (err || !resp) {
+    state.error = err || "Failed to upload emote";
+  } else {
+    state.emotes = resp.emotes || [];
+    state.isDefault = false;
+    state.uploadName = "";
+    state.saved = true;
+  }
+  vlens.scheduleRedraw();
+}
+
+// ===== Component =====
+export function EmoteSetSection(
+  props: EmoteSetSectionProps,
+): preact.ComponentChild {
+  const { studioId, canManage } = props;
+  const state = useEmoteSet(studioId);
+
+  if (!state.loaded) {
+    state.loaded = true;
+    loadEmoteSet(state, studioId);
+  }
+
+  return (
+    <div className="members-section">
+      <div className="members-header">
+        <h2 className="section-title">Emotes</h2>
+      </div>
+
+      {state.error && <div className="error-message">{state.error}</div>}
+
+      <div className="form-group">
+        <div className="table-actions">
+          {state.emotes.map((emote) => (
+            <span key={emote.code} className="table-actions">
+              <EmoteGlyph code={emote.code} emotes={state.emotes} />
+              {canManage && (
+                <button
+                  className="btn btn-secondary btn-sm"
+                  onClick={() => removeEmote(state, studioId, emote.code)}
+                  disabled={state.isSaving}
+                  title={`Remove ${emote.code}`}
+                >
+                  ✕
+                </button>
+              )}
+            </span>
+          ))}
+        </div>
+        <small className="form-help">
+          {state.isDefault
+            ? "Viewers see the default emotes."
+            : "Viewers see this studio's emotes."}{" "}
+          They're used for stream reactions and chat reactions.
+        </small>
+      </div>
+
+      {canManage && (
+        <>
+          <div className="form-group">
+            <label htmlFor="emote-emoji">Add an emoji</label>
+            <div className="table-actions">
+              <input
+                id="emote-emoji"
+                type="text"
+                className="form-input"
+                placeholder="🎉"
+                {...vlens.attrsBindInput(vlens.ref(state, "newEmoji"))}
+                disabled={state.isSaving}
+              />
+              <button
+                className="btn btn-primary btn-sm"
+                onClick={() => addEmoji(state, studioId)}
+                disabled={state.isSaving || !state.newEmoji.trim()}
+              >
+                Add
+              </button>
+            </div>
+          </div>
+
+          <div className="form-group">
+            <label htmlFor="emote-upload-name">Upload an image emote</label>
+            <div className="table-actions">
+              <input
+                id="emote-upload-name"
+                type="text"
+                className="form-input"
+                placeholder="name (e.g. bravo)"
+                {...vlens.attrsBindInput(vlens.ref(state, "uploadName"))}
+                disabled={state.isSaving}
+              />
+              <input
+                type="file"
+                accept="image/png,image/gif,image/jpeg"
+                disabled={state.isSaving || !state.uploadName.trim()}
+                onChange={(e) =>
+                  uploadEmote(state, studioId, e.target as HTMLInputElement)
+                }
+              />
+            </div>
+            <small className="form-help">
+              PNG, GIF or JPEG up to 64 KB and 128×128 pixels. Viewers use it
+              as :name:.
+            </small>
+          </div>
+
+          <div className="table-actions">
+            <button
+              className="btn btn-secondary btn-sm"
+              onClick={() => saveEmoteSet(state, studioId, [])}
+              disabled={state.isSaving || state.isDefault}
+            >
+              Reset to Defaults
+            </button>
+            {state.saved && <span className="form-help">Saved</span>}
+          </div>
+        </>
+      )}
+
+      {state.applause.length > 0 && (
+        <div className="members-table-wrapper">
+          <h3>Applause Meter</h3>
+          <table className="members-table">
+            <thead>
+              <tr>
+                <th>Stream</th>
+                <th>Room</th>
+                <th>Started</th>
+                <th>Total</th>
+                <th>Top Emotes</th>
+              </tr>
+            </thead>
+            <tbody>
+              {state.applause.map((item) => (
+                <tr key={item.stream.id}>
+                  <td>{item.stream.title}</td>
+                  <td>{item.roomName}</td>
+                  <td>{new Date(item.stream.startTime).toLocaleString()}</td>
+                  <td>{item.total}</td>
+                  <td>
+                    {(item.counts || []).slice(0, 5).map((count) => (
+                      <span key={count.code}>
+                        <EmoteGlyph
+                          code={count.code}
+                          emotes={state.emotes}
+                        />{" "}
+                        {count.count}{" "}
+                      </span>
+                    ))}
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+      )}
+    </div>
+  );
+}
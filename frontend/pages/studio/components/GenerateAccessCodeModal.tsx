@@ -18,13 +18,23 @@ type ModalState = {
   error: string;
   duration: string;
   maxViewers: number;
+  maxDevices: number;
+  scheduleId: string; // "" = any time, otherwise only during this class
+  schedules: server.ClassSchedule[];
+  schedulesLoaded: boolean;
   label: string;
+  recipientName: string;
   generatedCode: string;
   shareUrl: string;
   qrDataUrl: string;
   showSuccess: boolean;
   copyCodeSuccess: boolean;
   copyUrlSuccess: boolean;
+  inviteSingleUse: boolean;
+  isCreatingInvite: boolean;
+  inviteUrl: string;
+  inviteQrDataUrl: string;
+  copyInviteSuccess: boolean;
 };
 
 const useModalState = vlens.declareHook(
@@ -33,13 +43,23 @@ const useModalState = vlens.declareHook(
     error: "",
     duration: "24h",
     maxViewers: 30,
+    maxDevices: 0,
+    scheduleId: "",
+    schedules: [],
+    schedulesLoaded: false,
     label: "",
+    recipientName: "",
     generatedCode: "",
     shareUrl: "",
     qrDataUrl: "",
     showSuccess: false,
     copyCodeSuccess: false,
     copyUrlSuccess: false,
+    inviteSingleUse: true,
+    isCreatingInvite: false,
+    inviteUrl: "",
+    inviteQrDataUrl: "",
+    copyInviteSuccess: false,
   }),
 );
 
@@ -47,13 +67,21 @@ function resetModal(state: ModalState) {
   state.error = "";
   state.duration = "24h";
   state.maxViewers = 30;
+  state.maxDevices = 0;
+  state.scheduleId = "";
   state.label = "";
+  state.recipientName = "";
   state.generatedCode = "";
   state.shareUrl = "";
   state.qrDataUrl = "";
   state.showSuccess = false;
   state.copyCodeSuccess = false;
   state.copyUrlSuccess = false;
+  state.inviteSingleUse = true;
+  state.isCreatingInvite = false;
+  state.inviteUrl = "";
+  state.inviteQrDataUrl = "";
+  state.copyInviteSuccess = false;
   state.isSubmitting = false;
   vlens.scheduleRedraw();
 }
@@ -75,6 +103,23 @@ function durationToMinutes(duration: string): number {
   }
 }
 
+// Load the room's classes so the code can be limited to one of them
+async function loadSchedules(state: ModalState, roomId: number) {
+  state.schedulesLoaded = true;
+
+  const [resp, err] = await server.ListClassSchedules({
+    studioId: null,
+    roomId: roomId,
+  });
+
+  if (err || !resp) {
+    return;
+  }
+
+  state.schedules = (resp.schedules || []).filter((s) => s.isActive);
+  vlens.scheduleRedraw();
+}
+
 // Convert GIF data URL to PNG data URL
 function convertGifToPng(gifDataUrl: string): Promise<string> {
   return new Promise((resolve, reject) => {
@@ -107,6 +152,12 @@ async function submitGenerateCode(
     return;
   }
 
+  if (state.maxDevices < 0) {
+    state.error = "Max devices cannot be negative";
+    vlens.scheduleRedraw();
+    return;
+  }
+
   state.isSubmitting = true;
   state.error = "";
   vlens.scheduleRedraw();
@@ -116,7 +167,10 @@ async function submitGenerateCode(
     targetId: targetId,
     durationMinutes: durationToMinutes(state.duration),
     maxViewers: state.maxViewers,
+    maxDevices: state.maxDevices,
+    scheduleId: codeType === 0 ? parseInt(state.scheduleId) || 0 : 0,
     label: state.label.trim() || "",
+    recipientName: state.recipientName.trim(),
   });
 
   state.isSubmitting = false;
@@ -148,6 +202,64 @@ async function submitGenerateCode(
   vlens.scheduleRedraw();
 }
 
+function handleSingleUseChange(state: ModalState, event: Event) {
+  state.inviteSingleUse = (event.target as HTMLInputElement).checked;
+  vlens.scheduleRedraw();
+}
+
+// Create a one-click invite link (optionally single-use) for the generated code
+async function createInviteLink(state: ModalState) {
+  state.isCreatingInvite = true;
+  state.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.CreateInviteLink({
+    code: state.generatedCode,
+    roomId: 0,
+    durationMinutes: 0,
+    maxViewers: 0,
+    singleUse: state.inviteSingleUse,
+    label: state.label.trim() || "",
+  });
+
+  state.isCreatingInvite = false;
+
+  if (err || !resp) {
+    state.error = err || "Failed to create invite link";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  state.inviteUrl = resp.url;
+
+  try {
+    const qr = qrcode(0, "M");
+    qr.addData(state.inviteUrl);
+    qr.make();
+    state.inviteQrDataUrl = await convertGifToPng(qr.createDataURL(4));
+  } catch (qrError) {
+    console.error("Failed to generate QR code:", qrError);
+    state.inviteQrDataUrl = "";
+  }
+
+  vlens.scheduleRedraw();
+}
+
+async function copyInviteUrl(state: ModalState) {
+  try {
+    await navigator.clipboard.writeText(state.inviteUrl);
+    state.copyInviteSuccess = true;
+    vlens.scheduleRedraw();
+    setTimeout(() => {
+      state.copyInviteSuccess = false;
+      vlens.scheduleRedraw();
+    }, 2000);
+  } catch (err) {
+    state.error = "Failed to copy to clipboard";
+    vlens.scheduleRedraw();
+  }
+}
+
 async function copyCode(state: ModalState) {
   try {
     await navigator.clipboard.writeText(state.generatedCode);
@@ -204,6 +316,11 @@ export function GenerateAccessCodeModal(
     resetModal(state);
   }
 
+  // Room codes can be limited to one of the room's classes
+  if (props.isOpen && props.codeType === 0 && !state.schedulesLoaded) {
+    loadSchedules(state, props.targetId);
+  }
+
   const scopeDescription =
     props.codeType === 1
       ? `access to all rooms in this ${props.targetLabel.toLowerCase()}`
@@ -321,6 +438,62 @@ export function GenerateAccessCodeModal(
               </button>
             )}
           </div>
+
+          <div className="form-group">
+            <label>Invite Link</label>
+            {state.inviteUrl ? (
+              <>
+                <div className="stream-key-display">{state.inviteUrl}</div>
+                {state.inviteQrDataUrl && (
+                  <div style="text-align: center; padding: 1rem; background: white; border: 1px solid var(--border); border-radius: 8px;">
+                    <img
+                      src={state.inviteQrDataUrl}
+                      alt="QR Code for invite link"
+                      style="max-width: 256px; width: 100%; height: auto;"
+                    />
+                  </div>
+                )}
+                <div className="stream-key-actions">
+                  <button
+                    className="btn btn-secondary"
+                    onClick={() => copyInviteUrl(state)}
+                    disabled={state.copyInviteSuccess}
+                  >
+                    {state.copyInviteSuccess ? "✓ Copied Link!" : "Copy Link"}
+                  </button>
+                </div>
+              </>
+            ) : (
+              <>
+                <div className="checkbox-toggle">
+                  <input
+                    type="checkbox"
+                    id="invite-single-use"
+                    checked={state.inviteSingleUse}
+                    onChange={vlens.cachePartial(handleSingleUseChange, state)}
+                    disabled={state.isCreatingInvite}
+                  />
+                  <label htmlFor="invite-single-use">
+                    Single use (only the first device can open it)
+                  </label>
+                </div>
+                <div className="stream-key-actions">
+                  <button
+                    className="btn btn-secondary"
+                    onClick={() => createInviteLink(state)}
+                    disabled={state.isCreatingInvite}
+                  >
+                    {state.isCreatingInvite
+                      ? "Creating..."
+                      : "Create Invite Link"}
+                  </button>
+                </div>
+              </>
+            )}
+            <small className="form-help">
+              A link viewers open in one click, no code to type
+            </small>
+          </div>
         </div>
       ) : (
         // Form view - collect code parameters
@@ -349,6 +522,28 @@ export function GenerateAccessCodeModal(
             </small>
           </div>
 
+          {props.codeType === 0 && state.schedules.length > 0 && (
+            <div className="form-group">
+              <label htmlFor="code-schedule">Class</label>
+              <select
+                id="code-schedule"
+                className="form-input"
+                {...vlens.attrsBindInput(vlens.ref(state, "scheduleId"))}
+                disabled={state.isSubmitting}
+              >
+                <option value="">Any time</option>
+                {state.schedules.map((schedule) => (
+                  <option key={schedule.id} value={String(schedule.id)}>
+                    {schedule.name}
+                  </option>
+                ))}
+              </select>
+              <small className="form-help">
+                Only admit viewers during this class (including pre/post-roll)
+              </small>
+            </div>
+          )}
+
           <div className="form-group">
             <label htmlFor="code-max-viewers">Max Viewers *</label>
             <input
@@ -365,6 +560,22 @@ export function GenerateAccessCodeModal(
             </small>
           </div>
 
+          <div className="form-group">
+            <label htmlFor="code-max-devices">Max Devices</label>
+            <input
+              id="code-max-devices"
+              type="number"
+              className="form-input"
+              min="0"
+              placeholder="0 = any device"
+              {...vlens.attrsBindInput(vlens.ref(state, "maxDevices"))}
+              disabled={state.isSubmitting}
+            />
+            <small className="form-help">
+              Bind the code to the first devices that use it (0 = no limit)
+            </small>
+          </div>
+
           <div className="form-group">
             <label htmlFor="code-label">Label (Optional)</label>
             <input
@@ -379,6 +590,22 @@ export function GenerateAccessCodeModal(
               Optional label to help you identify this code later
             </small>
           </div>
+
+          <div className="form-group">
+            <label htmlFor="code-recipient">Recipient Name (Optional)</label>
+            <input
+              id="code-recipient"
+              type="text"
+              className="form-input"
+              placeholder="e.g., The Garcia Family"
+              {...vlens.attrsBindInput(vlens.ref(state, "recipientName"))}
+              disabled={state.isSubmitting}
+            />
+            <small className="form-help">
+              Viewers using this code chat under this name until they pick
+              their own
+            </small>
+          </div>
         </div>
       )}
     </Modal>
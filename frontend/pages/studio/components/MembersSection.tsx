@@ -20,6 +20,7 @@ type Member = {
 type MembersSectionProps = {
   studio: Studio;
   members: Member[];
+  myRole: number;
   canManageRooms: boolean;
 };
 
@@ -93,6 +94,213 @@ async function submitAddMember(modal: AddMemberModal) {
   window.location.reload();
 }
 
+// ===== Invitations =====
+type InvitationsState = {
+  loaded: boolean;
+  invitations: server.StudioInvitationItem[];
+  error: string;
+};
+
+const useInvitations = vlens.declareHook(
+  (studioId: number): InvitationsState => ({
+    loaded: false,
+    invitations: [],
+    error: "",
+  }),
+);
+
+async function loadInvitations(state: InvitationsState, studioId: number) {
+  const [resp, err] = await server.ListStudioInvitations({ studioId });
+  state.loaded = true;
+  if (err || !resp) {
+    state.error = err || "Failed to load invitations";
+  } else {
+    state.invitations = resp.invitations || [];
+  }
+  vlens.scheduleRedraw();
+}
+
+async function revokeInvitation(
+  state: InvitationsState,
+  studioId: number,
+  invitationId: number,
+) {
+  const [resp, err] = await server.RevokeStudioInvitation({ invitationId });
+  if (err) {
+    state.error = err;
+    vlens.scheduleRedraw();
+    return;
+  }
+  loadInvitations(state, studioId);
+}
+
+type InviteMemberModal = {
+  isOpen: boolean;
+  isSubmitting: boolean;
+  error: string;
+  studioId: number;
+  email: string;
+  role: number;
+};
+
+const useInviteMemberModal = vlens.declareHook(
+  (): InviteMemberModal => ({
+    isOpen: false,
+    isSubmitting: false,
+    error: "",
+    studioId: 0,
+    email: "",
+    role: server.StudioRoleViewer,
+  }),
+);
+
+function openInviteMemberModal(modal: InviteMemberModal, studioId: number) {
+  modal.isOpen = true;
+  modal.error = "";
+  modal.studioId = studioId;
+  modal.email = "";
+  modal.role = server.StudioRoleViewer;
+  vlens.scheduleRedraw();
+}
+
+function closeInviteMemberModal(modal: InviteMemberModal) {
+  modal.isOpen = false;
+  modal.error = "";
+  vlens.scheduleRedraw();
+}
+
+async function submitInviteMember(
+  modal: InviteMemberModal,
+  invitations: InvitationsState,
+) {
+  if (!modal.email.trim()) {
+    modal.error = "Email is required";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  modal.isSubmitting = true;
+  modal.error = "";
+  vlens.scheduleRedraw();
+
+  const [resp, err] = await server.InviteStudioMember({
+    studioId: modal.studioId,
+    email: modal.email.trim(),
+    role: modal.role,
+  });
+
+  modal.isSubmitting = false;
+
+  if (err) {
+    modal.error = err || "Failed to send invitation";
+    vlens.scheduleRedraw();
+    return;
+  }
+
+  closeInviteMemberModal(modal);
+  loadInvitations(invitations, modal.studioId);
+}
+
+// ===== Ownership Transfers =====
+type TransfersState = {
+  loaded: boolean;
+  transfers: server.OwnershipTransferItem[];
+  error: string;
+};
+
+const useTransfers = vlens.declareHook(
+  (studioId: number): TransfersState => ({
+    loaded: false,
+    transfers: [],
+    error: "",
+  }),
+);
+
+async function loadTransfers(state: TransfersState, studioId: number) {
+  const [resp, err] = await server.ListOwnershipTransfers({ studioId });
+  state.loaded = true;
+  if (err || !resp) {
+    state.error = err || "Failed to load ownership transfers";
+  } else {
+    state.transfers = resp.transfers || [];
+  }
+  vlens.scheduleRedraw();
+}
+
+async function offerOwnership(
+  state: TransfersState,
+  studioId: number,
+  userId: number,
+  userName: string,
+) {
+  if (
+    !confirm(
+      `Ask ${userName} to take over this studio? You'll stay on as an admin once they accept.`,
+    )
+  ) {
+    return;
+  }
+  const [resp, err] = await server.OfferStudioOwnership({
+    studioId,
+    newOwnerId: userId,
+  });
+  if (err) {
+    state.error = err;
+    vlens.scheduleRedraw();
+    return;
+  }
+  loadTransfers(state, studioId);
+}
+
+async function cancelTransfer(
+  state: TransfersState,
+  studioId: number,
+  transferId: number,
+) {
+  const [resp, err] = await server.CancelOwnershipTransfer({ transferId });
+  if (err) {
+    state.error = err;
+    vlens.scheduleRedraw();
+    return;
+  }
+  loadTransfers(state, studioId);
+}
+
+async function answerTransfer(
+  state: TransfersState,
+  transferId: number,
+  accept: boolean,
+) {
+  const [resp, err] = accept
+    ? await server.AcceptOwnershipTransfer({ transferId })
+    : await server.DeclineOwnershipTransfer({ transferId });
+  if (err) {
+    state.error = err;
+    vlens.scheduleRedraw();
+    return;
+  }
+  window.location.reload();
+}
+
+function getTransferStatusText(transfer: server.OwnershipTransferItem) {
+  if (transfer.isExpired) {
+    return "Expired";
+  }
+  switch (transfer.status) {
+    case server.OwnershipTransferPending:
+      return "Waiting for answer";
+    case server.OwnershipTransferAccepted:
+      return "Accepted";
+    case server.OwnershipTransferDeclined:
+      return "Declined";
+    case server.OwnershipTransferCancelled:
+      return "Cancelled";
+    case server.OwnershipTransferAssigned:
+      return "Assigned on account deletion";
+  }
+  return "";
+}
+
 // ===== Change Role Modal =====
 type ChangeRoleModal = {
   isOpen: boolean;
@@ -237,23 +445,70 @@ async function confirmRemoveMember(modal: RemoveMemberModal) {
 export function MembersSection(
   props: MembersSectionProps,
 ): preact.ComponentChild {
-  const { studio, members, canManageRooms } = props;
+  const { studio, members, myRole, canManageRooms } = props;
+  const isOwner = myRole === server.StudioRoleOwner;
   const addMemberModal = useAddMemberModal();
   const changeRoleModal = useChangeRoleModal();
   const removeMemberModal = useRemoveMemberModal();
+  const inviteMemberModal = useInviteMemberModal();
+  const invitations = useInvitations(studio.id);
+  const transfers = useTransfers(studio.id);
+
+  // Load pending invitations once for admins
+  if (canManageRooms && !invitations.loaded) {
+    invitations.loaded = true;
+    loadInvitations(invitations, studio.id);
+  }
+
+  // Every member loads transfers so the new owner can answer an offer
+  if (!transfers.loaded) {
+    transfers.loaded = true;
+    loadTransfers(transfers, studio.id);
+  }
 
   return (
     <>
       <div className="members-section">
+        {transfers.transfers
+          .filter((transfer) => transfer.canRespond)
+          .map((transfer) => (
+            <div key={transfer.id} className="success-message">
+              {transfer.fromName} asked you to take over ownership of{" "}
+              {studio.name}.{" "}
+              <button
+                className="btn btn-primary btn-sm"
+                onClick={() => answerTransfer(transfers, transfer.id, true)}
+              >
+                Accept
+              </button>{" "}
+              <button
+                className="btn btn-secondary btn-sm"
+                onClick={() => answerTransfer(transfers, transfer.id, false)}
+              >
+                Decline
+              </button>
+            </div>
+          ))}
+
         <div className="members-header">
           <h2 className="section-title">Members</h2>
           {canManageRooms && (
-            <button
-              className="btn btn-primary btn-sm"
-              onClick={() => openAddMemberModal(addMemberModal, studio.id)}
-            >
-              Add Member
-            </button>
+            <div className="table-actions">
+              <button
+                className="btn btn-secondary btn-sm"
+                onClick={() =>
+                  openInviteMemberModal(inviteMemberModal, studio.id)
+                }
+              >
+                Invite by Email
+              </button>
+              <button
+                className="btn btn-primary btn-sm"
+                onClick={() => openAddMemberModal(addMemberModal, studio.id)}
+              >
+                Add Member
+              </button>
+            </div>
           )}
         </div>
 
@@ -315,6 +570,21 @@ export function MembersSection(
                             >
                               Remove
                             </button>
+                            {isOwner && (
+                              <button
+                                className="btn btn-secondary btn-sm"
+                                onClick={() =>
+                                  offerOwnership(
+                                    transfers,
+                                    studio.id,
+                                    member.userId,
+                                    member.userName,
+                                  )
+                                }
+                              >
+                                Make Owner
+                              </button>
+                            )}
                           </>
                         ) : (
                           <span className="table-owner-label">Owner</span>
@@ -327,6 +597,100 @@ export function MembersSection(
             </table>
           </div>
         )}
+
+        {canManageRooms && invitations.error && (
+          <div className="error-message">{invitations.error}</div>
+        )}
+
+        {canManageRooms && invitations.invitations.length > 0 && (
+          <div className="members-table-wrapper">
+            <h3>Pending Invitations</h3>
+            <table className="members-table">
+              <thead>
+                <tr>
+                  <th>Email</th>
+                  <th>Role</th>
+                  <th>Invited By</th>
+                  <th>Expires</th>
+                  <th>Actions</th>
+                </tr>
+              </thead>
+              <tbody>
+                {invitations.invitations.map((inv) => (
+                  <tr key={inv.id}>
+                    <td>{inv.email}</td>
+                    <td>
+                      <span className={getRoleBadgeClass(inv.role)}>
+                        {inv.roleName}
+                      </span>
+                    </td>
+                    <td>{inv.invitedByName}</td>
+                    <td>
+                      {inv.isExpired
+                        ? "Expired"
+                        : new Date(inv.expiresAt).toLocaleDateString()}
+                    </td>
+                    <td className="table-actions">
+                      <button
+                        className="btn btn-danger btn-sm"
+                        onClick={() =>
+                          revokeInvitation(invitations, studio.id, inv.id)
+                        }
+                      >
+                        Revoke
+                      </button>
+                    </td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
+
+        {transfers.error && (
+          <div className="error-message">{transfers.error}</div>
+        )}
+
+        {canManageRooms && transfers.transfers.length > 0 && (
+          <div className="members-table-wrapper">
+            <h3>Ownership History</h3>
+            <table className="members-table">
+              <thead>
+                <tr>
+                  <th>From</th>
+                  <th>To</th>
+                  <th>Offered</th>
+                  <th>Status</th>
+                  <th>Actions</th>
+                </tr>
+              </thead>
+              <tbody>
+                {transfers.transfers.map((transfer) => (
+                  <tr key={transfer.id}>
+                    <td>{transfer.fromName}</td>
+                    <td>{transfer.toName}</td>
+                    <td>
+                      {new Date(transfer.createdAt).toLocaleDateString()}
+                    </td>
+                    <td>{getTransferStatusText(transfer)}</td>
+                    <td className="table-actions">
+                      {transfer.canCancel && (
+                        <button
+                          className="btn btn-danger btn-sm"
+                          onClick={() =>
+                            cancelTransfer(transfers, studio.id, transfer.id)
+                          }
+                        >
+                          Cancel
+                        </button>
+                      )}
+                    </td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
       </div>
 
       {/* Modals */}
@@ -384,6 +748,63 @@ export function MembersSection(
         </div>
       </Modal>
 
+      <Modal
+        isOpen={inviteMemberModal.isOpen}
+        title="Invite by Email"
+        onClose={() => closeInviteMemberModal(inviteMemberModal)}
+        error={inviteMemberModal.error}
+        footer={
+          <>
+            <button
+              className="btn btn-secondary"
+              onClick={() => closeInviteMemberModal(inviteMemberModal)}
+              disabled={inviteMemberModal.isSubmitting}
+            >
+              Cancel
+            </button>
+            <button
+              className="btn btn-primary"
+              onClick={() => submitInviteMember(inviteMemberModal, invitations)}
+              disabled={inviteMemberModal.isSubmitting}
+            >
+              {inviteMemberModal.isSubmitting
+                ? "Sending..."
+                : "Send Invitation"}
+            </button>
+          </>
+        }
+      >
+        <div className="form-group">
+          <label htmlFor="invite-email">Email *</label>
+          <input
+            id="invite-email"
+            type="email"
+            className="form-input"
+            placeholder="person@example.com"
+            {...vlens.attrsBindInput(vlens.ref(inviteMemberModal, "email"))}
+            disabled={inviteMemberModal.isSubmitting}
+          />
+          <small className="form-help">
+            They don't need an account yet. They'll join the studio when they
+            sign up with this address. Invitations expire after 7 days.
+          </small>
+        </div>
+
+        <div className="form-group">
+          <label htmlFor="invite-role">Role *</label>
+          <select
+            id="invite-role"
+            className="form-input"
+            {...vlens.attrsBindInput(vlens.ref(inviteMemberModal, "role"))}
+            disabled={inviteMemberModal.isSubmitting}
+          >
+            <option value={0}>Viewer - Can watch streams</option>
+            <option value={1}>Member - Can stream</option>
+            <option value={2}>Admin - Can manage rooms and members</option>
+          </select>
+        </div>
+      </Modal>
+
       <Modal
         isOpen={changeRoleModal.isOpen}
         title="Change Member Role"
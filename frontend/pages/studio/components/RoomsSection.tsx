@@ -28,6 +28,7 @@ type RoomsSectionProps = {
   studio: Studio;
   rooms: Room[];
   canManageRooms: boolean;
+  canControlCameras: boolean;
 };
 
 // ===== Stream Key Modal =====
@@ -634,7 +635,7 @@ function formatClassStartTime(isoTime: string): string {
 
 // ===== Component =====
 export function RoomsSection(props: RoomsSectionProps): preact.ComponentChild {
-  const { studio, rooms, canManageRooms } = props;
+  const { studio, rooms, canManageRooms, canControlCameras } = props;
   const streamKeyModal = useStreamKeyModal();
   const editRoomModal = useEditRoomModal();
   const deleteRoomModal = useDeleteRoomModal();
@@ -732,7 +733,7 @@ export function RoomsSection(props: RoomsSectionProps): preact.ComponentChild {
                   </div>
 
                   {/* Camera Controls */}
-                  {canManageRooms && cameraStatus.hasCamera && (
+                  {canControlCameras && cameraStatus.hasCamera && (
                     <div className="camera-controls">
                       {cameraStatus.error && (
                         <div className="camera-error">{cameraStatus.error}</div>
@@ -7,6 +7,10 @@ import { SchedulesSection } from "./components/SchedulesSection";
 import { ActiveCodesList } from "./components/ActiveCodesList";
 import { StudioAnalyticsSection } from "./components/StudioAnalyticsSection";
 import { MembersSection } from "./components/MembersSection";
+import { CustomRolesSection } from "./components/CustomRolesSection";
+import { ChatFilterSection } from "./components/ChatFilterSection";
+import { ChatArchivesSection } from "./components/ChatArchivesSection";
+import { EmoteSetSection } from "./components/EmoteSetSection";
 import "../../styles/global";
 import "./studio-styles";
 import "./components/ActiveCodesList-styles";
@@ -63,6 +67,10 @@ export function view(
   // Check if user can manage rooms (Admin or Owner)
   const canManageRooms = myRole >= server.StudioRoleAdmin;
 
+  // Everything else follows the member's capabilities
+  const myCapabilities = data.myCapabilities || [];
+  const can = (cap: server.StudioCapability) => myCapabilities.includes(cap);
+
   return (
     <div>
       <Header />
@@ -96,17 +104,18 @@ export function view(
             studio={studio}
             rooms={rooms}
             canManageRooms={canManageRooms}
+            canControlCameras={can(server.CapControlCameras)}
           />
 
           {/* Schedules Section with class schedule management */}
           <SchedulesSection
             studio={studio}
             rooms={rooms}
-            canManageRooms={canManageRooms}
+            canManageRooms={can(server.CapManageSchedules)}
           />
 
-          {/* Access Codes Section - only visible to admins */}
-          {canManageRooms && (
+          {/* Access Codes Section - only visible to code managers */}
+          {can(server.CapManageCodes) && (
             <ActiveCodesList
               studioId={studio.id}
               studioName={studio.name}
@@ -118,8 +127,32 @@ export function view(
           <MembersSection
             studio={studio}
             members={members}
+            myRole={myRole}
             canManageRooms={canManageRooms}
           />
+
+          {/* Custom Roles Section - admins assign, owners define */}
+          {canManageRooms && (
+            <CustomRolesSection
+              studioId={studio.id}
+              members={members}
+              myRole={myRole}
+            />
+          )}
+
+          {/* Chat Filter Section - only visible to chat moderators */}
+          {can(server.CapModerateChat) && (
+            <ChatFilterSection studioId={studio.id} />
+          )}
+
+          {/* Chat Archives Section - visible to all members */}
+          <ChatArchivesSection
+            studioId={studio.id}
+            canModerate={can(server.CapModerateChat)}
+          />
+
+          {/* Emotes Section - visible to all members, admins edit */}
+          <EmoteSetSection studioId={studio.id} canManage={canManageRooms} />
         </div>
       </main>
       <Footer />
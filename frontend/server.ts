@@ -11,15 +11,60 @@ export const StudioRoleMember: StudioRole = 1;
 export const StudioRoleAdmin: StudioRole = 2;
 export const StudioRoleOwner: StudioRole = 3;
 
+export type StudioCapability = number;
+export const CapViewStudio: StudioCapability = 0;
+export const CapControlCameras: StudioCapability = 1;
+export const CapManageCodes: StudioCapability = 2;
+export const CapViewAnalytics: StudioCapability = 3;
+export const CapManageSchedules: StudioCapability = 4;
+export const CapModerateChat: StudioCapability = 5;
+
+export type ChatRestrictionKind = number;
+export const ChatRestrictionTimeout: ChatRestrictionKind = 0;
+export const ChatRestrictionBan: ChatRestrictionKind = 1;
+
+export type ChatFilterAction = number;
+export const ChatFilterBlock: ChatFilterAction = 0;
+export const ChatFilterHold: ChatFilterAction = 1;
+
+export type QuestionStatus = number;
+export const QuestionStatusOpen: QuestionStatus = 0;
+export const QuestionStatusAnswered: QuestionStatus = 1;
+export const QuestionStatusDismissed: QuestionStatus = 2;
+
+export type ChatFilterStatus = number;
+export const ChatFilterStatusBlocked: ChatFilterStatus = 0;
+export const ChatFilterStatusPending: ChatFilterStatus = 1;
+export const ChatFilterStatusApproved: ChatFilterStatus = 2;
+export const ChatFilterStatusRejected: ChatFilterStatus = 3;
+
+export type OwnershipTransferStatus = number;
+export const OwnershipTransferPending: OwnershipTransferStatus = 0;
+export const OwnershipTransferAccepted: OwnershipTransferStatus = 1;
+export const OwnershipTransferDeclined: OwnershipTransferStatus = 2;
+export const OwnershipTransferCancelled: OwnershipTransferStatus = 3;
+export const OwnershipTransferAssigned: OwnershipTransferStatus = 4;
+
+export type CodeType = number;
+export const CodeTypeRoom: CodeType = 0;
+export const CodeTypeStudio: CodeType = 1;
+
+export type TicketPurchaseStatus = number;
+export const TicketPurchasePending: TicketPurchaseStatus = 0;
+export const TicketPurchasePaid: TicketPurchaseStatus = 1;
+export const TicketPurchaseRefunded: TicketPurchaseStatus = 2;
+
 // Errors
 export const ErrLoginFailure = "LoginFailure";
 export const ErrAuthFailure = "AuthFailure";
+export const ErrRefreshTokenReused = "refresh token reused";
 
 export interface CreateAccountRequest {
     name: string
     email: string
     password: string
     confirmPassword: string
+    inviteToken: string
 }
 
 export interface CreateAccountResponse {
@@ -38,6 +83,12 @@ export interface AuthResponse {
     isStreamAdmin: boolean
     isSiteAdmin: boolean
     canManageStudios: boolean
+    emailVerified: boolean
+}
+
+export interface ListLoginProvidersResponse {
+    google: boolean
+    providers: LoginProvider[]
 }
 
 export interface ListAllUsersRequest {
@@ -56,6 +107,131 @@ export interface UpdateUserRoleResponse {
     user: User
 }
 
+export interface RequestPasswordResetRequest {
+    email: string
+}
+
+export interface RequestPasswordResetResponse {
+    success: boolean
+}
+
+export interface ResetPasswordRequest {
+    token: string
+    password: string
+    confirmPassword: string
+}
+
+export interface ResetPasswordResponse {
+    success: boolean
+}
+
+export interface SendVerificationEmailResponse {
+    success: boolean
+}
+
+export interface VerifyEmailRequest {
+    token: string
+}
+
+export interface VerifyEmailResponse {
+    success: boolean
+    email: string
+}
+
+export interface TwoFactorStatusResponse {
+    enabled: boolean
+    required: boolean
+    recoveryCodesLeft: number
+    enabledAt: string
+}
+
+export interface BeginTwoFactorSetupResponse {
+    secret: string
+    otpauthUrl: string
+}
+
+export interface TwoFactorCodeRequest {
+    code: string
+}
+
+export interface RecoveryCodesResponse {
+    recoveryCodes: string[]
+}
+
+export interface DisableTwoFactorResponse {
+    success: boolean
+}
+
+export interface ListSessionsResponse {
+    sessions: DeviceSession[]
+}
+
+export interface RevokeSessionRequest {
+    sessionId: number
+}
+
+export interface RevokeSessionResponse {
+    success: boolean
+}
+
+export interface SignOutEverywhereResponse {
+    revoked: number
+}
+
+export interface ListSigningKeysResponse {
+    keys: SigningKey[]
+    algorithm: string
+    rotationDays: number
+}
+
+export interface RotateSigningKeysResponse {
+    kid: string
+    pruned: number
+}
+
+export interface ListSecurityEventsRequest {
+    userId: number
+    limit: number
+}
+
+export interface ListSecurityEventsResponse {
+    events: SecurityEvent[]
+    lockedAccounts: LockedAccount[]
+}
+
+export interface UnlockAccountRequest {
+    email: string
+}
+
+export interface UnlockAccountResponse {
+    success: boolean
+}
+
+export interface ExportAccountDataResponse {
+    exportedAt: string
+    profile: User
+    memberships: ExportedMembership[]
+    classPermissions: ExportedClassPermission[]
+    chatMessages: ChatMessage[]
+    viewerSessions: ViewerSession[]
+    chatRestrictions: ChatRestriction[]
+    filteredChat: ChatFilterEntry[]
+    classQuestions: ClassQuestion[]
+    pollVotes: PollVote[]
+    devices: DeviceSession[]
+}
+
+export interface DeleteAccountRequest {
+    password: string
+    confirmEmail: string
+    transfers: StudioTransfer[]
+}
+
+export interface DeleteAccountResponse {
+    success: boolean
+    studiosTransferred: number
+}
+
 export interface SetUserRoleRequest {
     userId: number
     role: UserRole
@@ -115,6 +291,7 @@ export interface GetStudioDashboardResponse {
     myRoleName: string
     rooms: RoomWithStudio[]
     members: MemberWithDetails[]
+    myCapabilities: StudioCapability[]
 }
 
 export interface UpdateStudioRequest {
@@ -265,12 +442,137 @@ export interface LeaveStudioRequest {
 export interface LeaveStudioResponse {
 }
 
+export interface InviteStudioMemberRequest {
+    studioId: number
+    email: string
+    role: StudioRole
+}
+
+export interface InviteStudioMemberResponse {
+    invitation: StudioInvitation
+}
+
+export interface ListStudioInvitationsRequest {
+    studioId: number
+}
+
+export interface ListStudioInvitationsResponse {
+    invitations: StudioInvitationItem[]
+}
+
+export interface RevokeStudioInvitationRequest {
+    invitationId: number
+}
+
+export interface RevokeStudioInvitationResponse {
+    success: boolean
+}
+
+export interface GetStudioInvitationRequest {
+    token: string
+}
+
+export interface GetStudioInvitationResponse {
+    invitationId: number
+    studioName: string
+    email: string
+    role: StudioRole
+    roleName: string
+    expiresAt: string
+    isExpired: boolean
+}
+
+export interface ListMyStudioInvitationsResponse {
+    invitations: MyStudioInvitation[]
+}
+
+export interface RespondStudioInvitationRequest {
+    invitationId: number
+    token: string
+}
+
+export interface AcceptStudioInvitationResponse {
+    membership: StudioMembership
+}
+
+export interface DeclineStudioInvitationResponse {
+    success: boolean
+}
+
+export interface OfferStudioOwnershipRequest {
+    studioId: number
+    newOwnerId: number
+}
+
+export interface OfferStudioOwnershipResponse {
+    transfer: OwnershipTransfer
+}
+
+export interface OwnershipTransferRequest {
+    transferId: number
+}
+
+export interface OwnershipTransferResponse {
+    success: boolean
+}
+
+export interface ListOwnershipTransfersRequest {
+    studioId: number
+}
+
+export interface ListOwnershipTransfersResponse {
+    transfers: OwnershipTransferItem[]
+}
+
+export interface ListStudioRolesRequest {
+    studioId: number
+}
+
+export interface ListStudioRolesResponse {
+    capabilities: StudioCapabilityInfo[]
+    builtinRoles: BuiltinStudioRole[]
+    customRoles: StudioCustomRole[]
+    myCapabilities: StudioCapability[]
+}
+
+export interface SaveStudioCustomRoleRequest {
+    studioId: number
+    roleId: number
+    name: string
+    capabilities: StudioCapability[]
+}
+
+export interface SaveStudioCustomRoleResponse {
+    role: StudioCustomRole
+}
+
+export interface DeleteStudioCustomRoleRequest {
+    roleId: number
+}
+
+export interface DeleteStudioCustomRoleResponse {
+    membersReset: number
+}
+
+export interface SetMemberCustomRoleRequest {
+    studioId: number
+    userId: number
+    customRoleId: number
+}
+
+export interface SetMemberCustomRoleResponse {
+    membership: StudioMembership
+}
+
 export interface GenerateAccessCodeRequest {
     type: number
     targetId: number
     durationMinutes: number
     maxViewers: number
+    maxDevices: number
+    scheduleId: number
     label: string
+    recipientName: string
 }
 
 export interface GenerateAccessCodeResponse {
@@ -328,6 +630,27 @@ export interface GetCodeAnalyticsResponse {
     sessions: SessionInfo[]
 }
 
+export interface GetCodeDevicesRequest {
+    code: string
+}
+
+export interface GetCodeDevicesResponse {
+    code: string
+    maxDevices: number
+    maxViewers: number
+    devices: CodeDeviceItem[]
+    sessions: CodeSessionItem[]
+}
+
+export interface KickCodeSessionRequest {
+    sessionToken: string
+    blockDevice: boolean
+}
+
+export interface KickCodeSessionResponse {
+    sessionsKilled: number
+}
+
 export interface SetCameraConfigRequest {
     roomId: number
     rtspUrl: string
@@ -378,17 +701,6 @@ export interface ReportStreamMetricsRequest {
 export interface ReportStreamMetricsResponse {
 }
 
-export interface RecalculateViewerCountsRequest {
-    studioId: number
-}
-
-export interface RecalculateViewerCountsResponse {
-    roomsUpdated: number
-    studiosUpdated: number
-    codesUpdated: number
-    message: string
-}
-
 export interface GetSystemLogsRequest {
     level: string | null
     category: string | null
@@ -435,143 +747,559 @@ export interface SendEmoteResponse {
 export interface SendChatMessageRequest {
     roomId: number
     text: string
+    replyToId: number
 }
 
 export interface SendChatMessageResponse {
     message: ChatMessage
+    held: boolean
 }
 
 export interface GetChatHistoryRequest {
     roomId: number
     limit: number
+    beforeId: number
+    afterId: number
 }
 
 export interface GetChatHistoryResponse {
     messages: ChatMessage[]
+    hasMore: boolean
+    settings: ChatRoomSettings
+    canModerate: boolean
+    canPin: boolean
+    isGuest: boolean
+    guestName: string
 }
 
-export interface CreateClassScheduleRequest {
-    roomId: number
+export interface SetChatDisplayNameRequest {
     name: string
-    description: string
-    isRecurring: boolean
-    startTime: string
-    endTime: string
-    recurStartDate: string
-    recurEndDate: string
-    recurWeekdays: number[]
-    recurTimeStart: string
-    recurTimeEnd: string
-    recurTimezone: string
-    preRollMinutes: number
-    postRollMinutes: number
-    autoStartCamera: boolean
-    autoStopCamera: boolean
 }
 
-export interface CreateClassScheduleResponse {
-    scheduleId: number
+export interface SetChatDisplayNameResponse {
+    name: string
 }
 
-export interface ListClassSchedulesRequest {
-    studioId: number | null
-    roomId: number | null
+export interface DeleteChatMessageRequest {
+    messageId: number
 }
 
-export interface ListClassSchedulesResponse {
-    schedules: ClassSchedule[]
+export interface DeleteChatMessageResponse {
+    success: boolean
 }
 
-export interface GetScheduleDetailsRequest {
-    scheduleId: number
+export interface RestrictChatViewerRequest {
+    messageId: number
+    kind: ChatRestrictionKind
+    durationMinutes: number
+    reason: string
+    deleteMessage: boolean
 }
 
-export interface GetScheduleDetailsResponse {
-    schedule: ClassSchedule
-    upcomingInstances: ClassInstance[]
-    permissions: ClassPermissionWithUser[]
+export interface RestrictChatViewerResponse {
+    restriction: ChatRestriction
 }
 
-export interface UpdateClassScheduleRequest {
-    scheduleId: number
-    name: string | null
-    description: string | null
-    startTime: string | null
-    endTime: string | null
-    recurStartDate: string | null
-    recurEndDate: string | null
-    recurWeekdays: number[]
-    recurTimeStart: string | null
-    recurTimeEnd: string | null
-    recurTimezone: string | null
-    preRollMinutes: number | null
-    postRollMinutes: number | null
-    autoStartCamera: boolean | null
-    autoStopCamera: boolean | null
+export interface LiftChatRestrictionRequest {
+    restrictionId: number
 }
 
-export interface UpdateClassScheduleResponse {
+export interface LiftChatRestrictionResponse {
     success: boolean
 }
 
-export interface DeleteClassScheduleRequest {
-    scheduleId: number
+export interface ListChatRestrictionsRequest {
+    studioId: number
 }
 
-export interface DeleteClassScheduleResponse {
-    success: boolean
+export interface ListChatRestrictionsResponse {
+    restrictions: ChatRestriction[]
 }
 
-export interface GetScheduleExecutionLogsRequest {
-    scheduleId: number | null
-    roomId: number | null
-    limit: number
-    offset: number
+export interface UpdateChatSettingsRequest {
+    roomId: number
+    slowModeSeconds: number
+    chatDisabled: boolean
 }
 
-export interface GetScheduleExecutionLogsResponse {
-    logs: ScheduleExecutionLog[]
-    total: number
+export interface UpdateChatSettingsResponse {
+    settings: ChatRoomSettings
 }
 
-export interface GrantClassPermissionRequest {
-    scheduleId: number
-    userId: number
-    role: number
+export interface GetChatFilterSettingsRequest {
+    studioId: number
 }
 
-export interface GrantClassPermissionResponse {
-    permissionId: number
+export interface GetChatFilterSettingsResponse {
+    settings: ChatFilterSettings
+    defaultTerms: string[]
 }
 
-export interface RevokeClassPermissionRequest {
-    permissionId: number
+export interface UpdateChatFilterSettingsRequest {
+    studioId: number
+    disabled: boolean
+    useDefaultList: boolean
+    blockedTerms: string[]
+    allowedTerms: string[]
+    blockLinks: boolean
+    action: ChatFilterAction
 }
 
-export interface RevokeClassPermissionResponse {
-    success: boolean
+export interface UpdateChatFilterSettingsResponse {
+    settings: ChatFilterSettings
 }
 
-export interface ListClassPermissionsRequest {
-    scheduleId: number
+export interface ListChatFilterLogRequest {
+    studioId: number
+    pendingOnly: boolean
+    limit: number
 }
 
-export interface ListClassPermissionsResponse {
-    permissions: ClassPermissionWithUser[]
+export interface ListChatFilterLogResponse {
+    entries: ChatFilterLogItem[]
 }
 
-export interface ListMyUpcomingClassesRequest {
-    limit: number
+export interface ReviewHeldChatMessageRequest {
+    entryId: number
+    approve: boolean
 }
 
-export interface ListMyUpcomingClassesResponse {
-    classes: UpcomingClassWithRoom[]
+export interface ReviewHeldChatMessageResponse {
+    entry: ChatFilterEntry
 }
 
-export interface GetTranscoderHealthRequest {
+export interface GetChatArchiveSettingsRequest {
+    studioId: number
 }
 
-export interface TranscoderHealthResponse {
+export interface GetChatArchiveSettingsResponse {
+    settings: ChatArchiveSettings
+}
+
+export interface UpdateChatArchiveSettingsRequest {
+    studioId: number
+    retentionDays: number
+}
+
+export interface UpdateChatArchiveSettingsResponse {
+    settings: ChatArchiveSettings
+}
+
+export interface ListChatArchivesRequest {
+    studioId: number
+    limit: number
+}
+
+export interface ListChatArchivesResponse {
+    archives: ChatArchiveItem[]
+}
+
+export interface GetStreamChatReplayRequest {
+    streamId: number
+}
+
+export interface GetStreamChatReplayResponse {
+    stream: Stream
+    roomName: string
+    messages: ReplayChatMessage[]
+}
+
+export interface ExportChatTranscriptRequest {
+    streamId: number
+}
+
+export interface ExportChatTranscriptResponse {
+    fileName: string
+    transcript: string
+}
+
+export interface ToggleChatReactionRequest {
+    messageId: number
+    emote: string
+}
+
+export interface ToggleChatReactionResponse {
+    reactions: ChatReaction[]
+}
+
+export interface SetChatPinnedAnnouncementRequest {
+    roomId: number
+    text: string
+}
+
+export interface SetChatPinnedAnnouncementResponse {
+    pin: ChatPinnedAnnouncement
+}
+
+export interface GetRoomEmotesRequest {
+    roomId: number
+}
+
+export interface GetRoomEmotesResponse {
+    emotes: StudioEmote[]
+}
+
+export interface GetStudioEmoteSetRequest {
+    studioId: number
+}
+
+export interface GetStudioEmoteSetResponse {
+    emotes: StudioEmote[]
+    isDefault: boolean
+}
+
+export interface UpdateStudioEmoteSetRequest {
+    studioId: number
+    emotes: StudioEmote[]
+}
+
+export interface UpdateStudioEmoteSetResponse {
+    emotes: StudioEmote[]
+}
+
+export interface UploadStudioEmoteRequest {
+    studioId: number
+    name: string
+    imageData: string
+}
+
+export interface UploadStudioEmoteResponse {
+    emote: StudioEmote
+    emotes: StudioEmote[]
+}
+
+export interface ListStreamApplauseRequest {
+    studioId: number
+    limit: number
+}
+
+export interface ListStreamApplauseResponse {
+    streams: StreamApplause[]
+    emotes: StudioEmote[]
+}
+
+export interface CreatePollRequest {
+    roomId: number
+    question: string
+    options: string[]
+}
+
+export interface PollResponse {
+    poll: Poll
+}
+
+export interface ClosePollRequest {
+    pollId: number
+}
+
+export interface VotePollRequest {
+    pollId: number
+    option: number
+}
+
+export interface ListRoomPollsRequest {
+    roomId: number
+}
+
+export interface ListRoomPollsResponse {
+    polls: Poll[]
+    canManage: boolean
+}
+
+export interface AskClassQuestionRequest {
+    roomId: number
+    text: string
+}
+
+export interface ClassQuestionResponse {
+    question: ClassQuestion
+}
+
+export interface SetClassQuestionStatusRequest {
+    questionId: number
+    status: QuestionStatus
+}
+
+export interface ListClassQuestionsRequest {
+    roomId: number
+}
+
+export interface ListClassQuestionsResponse {
+    questions: ClassQuestion[]
+    canManage: boolean
+}
+
+export interface CreateClassScheduleRequest {
+    roomId: number
+    name: string
+    description: string
+    isRecurring: boolean
+    startTime: string
+    endTime: string
+    recurStartDate: string
+    recurEndDate: string
+    recurWeekdays: number[]
+    recurTimeStart: string
+    recurTimeEnd: string
+    recurTimezone: string
+    preRollMinutes: number
+    postRollMinutes: number
+    autoStartCamera: boolean
+    autoStopCamera: boolean
+}
+
+export interface CreateClassScheduleResponse {
+    scheduleId: number
+}
+
+export interface ListClassSchedulesRequest {
+    studioId: number | null
+    roomId: number | null
+}
+
+export interface ListClassSchedulesResponse {
+    schedules: ClassSchedule[]
+}
+
+export interface GetScheduleDetailsRequest {
+    scheduleId: number
+}
+
+export interface GetScheduleDetailsResponse {
+    schedule: ClassSchedule
+    upcomingInstances: ClassInstance[]
+    permissions: ClassPermissionWithUser[]
+}
+
+export interface UpdateClassScheduleRequest {
+    scheduleId: number
+    name: string | null
+    description: string | null
+    startTime: string | null
+    endTime: string | null
+    recurStartDate: string | null
+    recurEndDate: string | null
+    recurWeekdays: number[]
+    recurTimeStart: string | null
+    recurTimeEnd: string | null
+    recurTimezone: string | null
+    preRollMinutes: number | null
+    postRollMinutes: number | null
+    autoStartCamera: boolean | null
+    autoStopCamera: boolean | null
+}
+
+export interface UpdateClassScheduleResponse {
+    success: boolean
+}
+
+export interface DeleteClassScheduleRequest {
+    scheduleId: number
+}
+
+export interface DeleteClassScheduleResponse {
+    success: boolean
+}
+
+export interface GetScheduleExecutionLogsRequest {
+    scheduleId: number | null
+    roomId: number | null
+    limit: number
+    offset: number
+}
+
+export interface GetScheduleExecutionLogsResponse {
+    logs: ScheduleExecutionLog[]
+    total: number
+}
+
+export interface GrantClassPermissionRequest {
+    scheduleId: number
+    userId: number
+    role: number
+}
+
+export interface GrantClassPermissionResponse {
+    permissionId: number
+}
+
+export interface RevokeClassPermissionRequest {
+    permissionId: number
+}
+
+export interface RevokeClassPermissionResponse {
+    success: boolean
+}
+
+export interface ListClassPermissionsRequest {
+    scheduleId: number
+}
+
+export interface ListClassPermissionsResponse {
+    permissions: ClassPermissionWithUser[]
+}
+
+export interface ListMyUpcomingClassesRequest {
+    limit: number
+}
+
+export interface ListMyUpcomingClassesResponse {
+    classes: UpcomingClassWithRoom[]
+}
+
+export interface CreateTicketProductRequest {
+    type: number
+    targetId: number
+    name: string
+    description: string
+    priceCents: number
+    currency: string
+    capacity: number
+    saleStartsAt: string
+    saleEndsAt: string
+    accessExpiresAt: string
+    maxViewers: number
+}
+
+export interface CreateTicketProductResponse {
+    product: TicketProduct
+}
+
+export interface UpdateTicketProductRequest {
+    productId: number
+    name: string
+    description: string
+    priceCents: number
+    capacity: number
+    saleStartsAt: string
+    saleEndsAt: string
+    accessExpiresAt: string
+    maxViewers: number
+    isActive: boolean
+}
+
+export interface UpdateTicketProductResponse {
+    product: TicketProduct
+}
+
+export interface ListTicketProductsRequest {
+    studioId: number
+}
+
+export interface ListTicketProductsResponse {
+    products: TicketProduct[]
+}
+
+export interface GetTicketInfoRequest {
+    productId: number
+}
+
+export interface GetTicketInfoResponse {
+    id: number
+    name: string
+    description: string
+    targetName: string
+    priceCents: number
+    currency: string
+    saleStartsAt: string
+    saleEndsAt: string
+    remaining: number
+    onSale: boolean
+    message: string
+}
+
+export interface PurchaseTicketRequest {
+    productId: number
+    buyerEmail: string
+    buyerName: string
+}
+
+export interface PurchaseTicketResponse {
+    checkoutURL: string
+}
+
+export interface ListTicketPurchasesRequest {
+    productId: number
+}
+
+export interface ListTicketPurchasesResponse {
+    purchases: TicketPurchase[]
+}
+
+export interface RefundTicketPurchaseRequest {
+    purchaseId: number
+}
+
+export interface RefundTicketPurchaseResponse {
+    sessionsKilled: number
+}
+
+export interface ResendTicketEmailRequest {
+    purchaseId: number
+}
+
+export interface ResendTicketEmailResponse {
+    emailedAt: string
+}
+
+export interface CreateInviteLinkRequest {
+    code: string
+    roomId: number
+    durationMinutes: number
+    maxViewers: number
+    singleUse: boolean
+    label: string
+}
+
+export interface CreateInviteLinkResponse {
+    invite: InviteLink
+    url: string
+}
+
+export interface ListInviteLinksRequest {
+    studioId: number
+}
+
+export interface ListInviteLinksResponse {
+    invites: InviteLinkItem[]
+}
+
+export interface RevokeInviteLinkRequest {
+    inviteId: number
+}
+
+export interface RevokeInviteLinkResponse {
+    success: boolean
+}
+
+export interface GetCodeWatchReportRequest {
+    code: string
+}
+
+export interface GetCodeWatchReportResponse {
+    code: string
+    label: string
+    codeDeleted: boolean
+    summary: WatchSummary
+    sessions: WatchSessionRow[]
+}
+
+export interface GetClassWatchReportRequest {
+    scheduleId: number
+}
+
+export interface GetClassWatchReportResponse {
+    scheduleId: number
+    scheduleName: string
+    summary: WatchSummary
+    codes: CodeWatchRow[]
+    sessions: WatchSessionRow[]
+}
+
+export interface GetTranscoderHealthRequest {
+}
+
+export interface TranscoderHealthResponse {
     active: number
     maxCapacity: number
     healthy: boolean
@@ -596,6 +1324,11 @@ export interface SRSAuthResponse {
     code: number
 }
 
+export interface LoginProvider {
+    name: string
+    displayName: string
+}
+
 export interface UserWithStats {
     id: number
     name: string
@@ -603,6 +1336,7 @@ export interface UserWithStats {
     role: UserRole
     creation: string
     lastLogin: string
+    emailVerified: boolean
     studioCount: number
 }
 
@@ -613,6 +1347,130 @@ export interface User {
     role: UserRole
     creation: string
     lastLogin: string
+    emailVerified: boolean
+}
+
+export interface DeviceSession {
+    id: number
+    userAgent: string
+    ipAddress: string
+    createdAt: string
+    lastUsedAt: string
+    expiresAt: string
+    current: boolean
+}
+
+export interface SigningKey {
+    kid: string
+    algorithm: string
+    createdAt: string
+    retiredAt: string
+}
+
+export interface SecurityEvent {
+    id: number
+    type: string
+    userId: number
+    email: string
+    ipAddress: string
+    userAgent: string
+    detail: string
+    actorId: number
+    createdAt: string
+}
+
+export interface LockedAccount {
+    userId: number
+    email: string
+    lockedUntil: string
+}
+
+export interface ExportedMembership {
+    studioId: number
+    studioName: string
+    role: StudioRole
+    roleName: string
+    joinedAt: string
+}
+
+export interface ExportedClassPermission {
+    permission: ClassPermission
+    scheduleName: string
+}
+
+export interface ChatMessage {
+    id: number
+    roomId: number
+    userId: number
+    userName: string
+    text: string
+    timestamp: string
+    streamId: number
+    replyToId: number
+    replyToName: string
+    reactions: ChatReaction[]
+}
+
+export interface ViewerSession {
+    sessionKey: string
+    viewerId: string
+    roomId: number
+    code: string
+    firstSeenAt: string
+    lastSeenAt: string
+}
+
+export interface ChatRestriction {
+    id: number
+    studioId: number
+    userId: number
+    targetName: string
+    kind: ChatRestrictionKind
+    reason: string
+    expiresAt: string
+    createdBy: number
+    createdAt: string
+}
+
+export interface ChatFilterEntry {
+    id: number
+    studioId: number
+    roomId: number
+    userId: number
+    userName: string
+    text: string
+    reason: string
+    status: ChatFilterStatus
+    createdAt: string
+    reviewedBy: number
+    reviewedAt: string
+    messageId: number
+    replyToId: number
+    replyToName: string
+}
+
+export interface ClassQuestion {
+    id: number
+    roomId: number
+    streamId: number
+    userId: number
+    userName: string
+    text: string
+    status: QuestionStatus
+    askedAt: string
+    answeredAt: string
+    mine: boolean
+}
+
+export interface PollVote {
+    pollId: number
+    option: number
+    votedAt: string
+}
+
+export interface StudioTransfer {
+    studioId: number
+    newOwnerId: number
 }
 
 export interface UserListInfo {
@@ -676,6 +1534,7 @@ export interface MemberWithDetails {
     studioId: number
     role: StudioRole
     joinedAt: string
+    customRoleId: number
     userName: string
     userEmail: string
     roleName: string
@@ -703,6 +1562,87 @@ export interface StudioMembership {
     studioId: number
     role: StudioRole
     joinedAt: string
+    customRoleId: number
+}
+
+export interface StudioInvitation {
+    id: number
+    studioId: number
+    email: string
+    role: StudioRole
+    invitedBy: number
+    createdAt: string
+    expiresAt: string
+}
+
+export interface StudioInvitationItem {
+    id: number
+    studioId: number
+    email: string
+    role: StudioRole
+    invitedBy: number
+    createdAt: string
+    expiresAt: string
+    roleName: string
+    invitedByName: string
+    isExpired: boolean
+}
+
+export interface MyStudioInvitation {
+    id: number
+    studioId: number
+    studioName: string
+    role: StudioRole
+    roleName: string
+    expiresAt: string
+}
+
+export interface OwnershipTransfer {
+    id: number
+    studioId: number
+    fromUserId: number
+    toUserId: number
+    status: OwnershipTransferStatus
+    createdAt: string
+    expiresAt: string
+    respondedAt: string
+}
+
+export interface OwnershipTransferItem {
+    id: number
+    studioId: number
+    fromUserId: number
+    toUserId: number
+    status: OwnershipTransferStatus
+    createdAt: string
+    expiresAt: string
+    respondedAt: string
+    fromName: string
+    toName: string
+    isExpired: boolean
+    canRespond: boolean
+    canCancel: boolean
+}
+
+export interface StudioCapabilityInfo {
+    capability: StudioCapability
+    name: string
+    description: string
+}
+
+export interface BuiltinStudioRole {
+    role: StudioRole
+    name: string
+    capabilities: StudioCapability[]
+}
+
+export interface StudioCustomRole {
+    id: number
+    studioId: number
+    name: string
+    capabilities: StudioCapability[]
+    createdBy: number
+    createdAt: string
 }
 
 export interface AccessCodeListItem {
@@ -713,6 +1653,9 @@ export interface AccessCodeListItem {
     expiresAt: string
     isRevoked: boolean
     isExpired: boolean
+    scheduleId: number
+    scheduleName: string
+    recipientName: string
     currentViewers: number
     totalViews: number
 }
@@ -725,6 +1668,27 @@ export interface SessionInfo {
     isActive: boolean
 }
 
+export interface CodeDeviceItem {
+    deviceId: string
+    firstSeenAt: string
+    lastSeenAt: string
+    clientIP: string
+    userAgent: string
+    redemptions: number
+    isBlocked: boolean
+    activeSessions: number
+}
+
+export interface CodeSessionItem {
+    token: string
+    deviceId: string
+    connectedAt: string
+    lastSeen: string
+    clientIP: string
+    userAgent: string
+    watchingIn: number[]
+}
+
 export interface RoomAnalytics {
     roomId: number
     totalViewsAllTime: number
@@ -825,38 +1789,147 @@ export interface SitePerformanceMetrics {
     totalRoomsWithData: number
 }
 
-export interface StudioPerformanceMetrics {
+export interface StudioPerformanceMetrics {
+    studioId: number
+    studioName: string
+    totalRooms: number
+    avgTimeToFirstFrame: number
+    startupSuccessRate: number
+    avgRebufferRatio: number
+    avgBitrateMbps: number
+    totalErrors: number
+    avgErrorsPerSession: number
+}
+
+export interface BucketInfo {
+    name: string
+    description: string
+    keyType: string
+    valueType: string
+    isIndex: boolean
+}
+
+export interface BucketEntry {
+    key: any
+    value: any
+}
+
+export interface ChatRoomSettings {
+    roomId: number
+    slowModeSeconds: number
+    chatDisabled: boolean
+    updatedBy: number
+    updatedAt: string
+}
+
+export interface ChatFilterSettings {
+    studioId: number
+    disabled: boolean
+    useDefaultList: boolean
+    blockedTerms: string[]
+    allowedTerms: string[]
+    blockLinks: boolean
+    action: ChatFilterAction
+    updatedBy: number
+    updatedAt: string
+}
+
+export interface ChatFilterLogItem {
+    id: number
+    studioId: number
+    roomId: number
+    userId: number
+    userName: string
+    text: string
+    reason: string
+    status: ChatFilterStatus
+    createdAt: string
+    reviewedBy: number
+    reviewedAt: string
+    messageId: number
+    replyToId: number
+    replyToName: string
+    roomName: string
+}
+
+export interface ChatArchiveSettings {
     studioId: number
-    studioName: string
-    totalRooms: number
-    avgTimeToFirstFrame: number
-    startupSuccessRate: number
-    avgRebufferRatio: number
-    avgBitrateMbps: number
-    totalErrors: number
-    avgErrorsPerSession: number
+    retentionDays: number
+    updatedBy: number
+    updatedAt: string
 }
 
-export interface BucketInfo {
-    name: string
-    description: string
-    keyType: string
-    valueType: string
-    isIndex: boolean
+export interface ChatArchiveItem {
+    stream: Stream
+    roomName: string
+    messageCount: number
+    expiresAt: string
 }
 
-export interface BucketEntry {
-    key: any
-    value: any
+export interface Stream {
+    id: number
+    studioId: number
+    roomId: number
+    title: string
+    description: string
+    startTime: string
+    endTime: string
+    createdBy: number
 }
 
-export interface ChatMessage {
+export interface ReplayChatMessage {
     id: number
     roomId: number
     userId: number
     userName: string
     text: string
     timestamp: string
+    streamId: number
+    replyToId: number
+    replyToName: string
+    reactions: ChatReaction[]
+    offsetSeconds: number
+}
+
+export interface ChatReaction {
+    emote: string
+    count: number
+    mine: boolean
+}
+
+export interface ChatPinnedAnnouncement {
+    roomId: number
+    text: string
+    pinnedBy: number
+    pinnedByName: string
+    pinnedAt: string
+}
+
+export interface StudioEmote {
+    code: string
+    imageUrl: string
+}
+
+export interface StreamApplause {
+    stream: Stream
+    roomName: string
+    total: number
+    counts: EmoteCount[]
+}
+
+export interface Poll {
+    id: number
+    roomId: number
+    streamId: number
+    question: string
+    options: string[]
+    votes: number[]
+    totalVotes: number
+    isClosed: boolean
+    createdBy: number
+    createdAt: string
+    closedAt: string
+    myVote: number
 }
 
 export interface ClassSchedule {
@@ -915,6 +1988,95 @@ export interface UpcomingClassWithRoom {
     studioName: string
 }
 
+export interface TicketProduct {
+    id: number
+    studioId: number
+    type: CodeType
+    targetId: number
+    name: string
+    description: string
+    priceCents: number
+    currency: string
+    capacity: number
+    soldCount: number
+    saleStartsAt: string
+    saleEndsAt: string
+    accessExpiresAt: string
+    maxViewers: number
+    isActive: boolean
+    createdBy: number
+    createdAt: string
+}
+
+export interface TicketPurchase {
+    id: number
+    productId: number
+    studioId: number
+    buyerEmail: string
+    buyerName: string
+    amountCents: number
+    currency: string
+    provider: string
+    sessionId: string
+    paymentRef: string
+    status: TicketPurchaseStatus
+    code: string
+    createdAt: string
+    paidAt: string
+    refundedAt: string
+    emailedAt: string
+    emailError: string
+}
+
+export interface InviteLink {
+    id: number
+    studioId: number
+    code: string
+    label: string
+    singleUse: boolean
+    expiresAt: string
+    isRevoked: boolean
+    redemptions: number
+    lastUsedAt: string
+    createdBy: number
+    createdAt: string
+}
+
+export interface InviteLinkItem {
+    invite: InviteLink
+    url: string
+    isUsed: boolean
+}
+
+export interface WatchSummary {
+    sessions: number
+    connections: number
+    connectedSeconds: number
+    watchSeconds: number
+    firstConnectedAt: string
+    lastConnectedAt: string
+}
+
+export interface WatchSessionRow {
+    sessionId: string
+    code: string
+    clientIP: string
+    connections: number
+    firstConnectedAt: string
+    lastConnectedAt: string
+    connectedSeconds: number
+    watchSeconds: number
+    isConnected: boolean
+}
+
+export interface CodeWatchRow {
+    code: string
+    label: string
+    sessions: number
+    connectedSeconds: number
+    watchSeconds: number
+}
+
 export interface TranscoderStatus {
     roomId: string
     streamKey: string
@@ -932,6 +2094,11 @@ export interface ClassPermission {
     grantedAt: string
 }
 
+export interface EmoteCount {
+    code: string
+    count: number
+}
+
 export async function CreateAccount(data: CreateAccountRequest): Promise<rpc.Response<CreateAccountResponse>> {
     return await rpc.call<CreateAccountResponse>('CreateAccount', JSON.stringify(data));
 }
@@ -940,6 +2107,10 @@ export async function GetAuthContext(data: Empty): Promise<rpc.Response<AuthResp
     return await rpc.call<AuthResponse>('GetAuthContext', JSON.stringify(data));
 }
 
+export async function ListLoginProviders(data: Empty): Promise<rpc.Response<ListLoginProvidersResponse>> {
+    return await rpc.call<ListLoginProvidersResponse>('ListLoginProviders', JSON.stringify(data));
+}
+
 export async function ListAllUsers(data: ListAllUsersRequest): Promise<rpc.Response<ListAllUsersResponse>> {
     return await rpc.call<ListAllUsersResponse>('ListAllUsers', JSON.stringify(data));
 }
@@ -948,6 +2119,78 @@ export async function UpdateUserRole(data: UpdateUserRoleRequest): Promise<rpc.R
     return await rpc.call<UpdateUserRoleResponse>('UpdateUserRole', JSON.stringify(data));
 }
 
+export async function RequestPasswordReset(data: RequestPasswordResetRequest): Promise<rpc.Response<RequestPasswordResetResponse>> {
+    return await rpc.call<RequestPasswordResetResponse>('RequestPasswordReset', JSON.stringify(data));
+}
+
+export async function ResetPassword(data: ResetPasswordRequest): Promise<rpc.Response<ResetPasswordResponse>> {
+    return await rpc.call<ResetPasswordResponse>('ResetPassword', JSON.stringify(data));
+}
+
+export async function SendVerificationEmail(data: Empty): Promise<rpc.Response<SendVerificationEmailResponse>> {
+    return await rpc.call<SendVerificationEmailResponse>('SendVerificationEmail', JSON.stringify(data));
+}
+
+export async function VerifyEmail(data: VerifyEmailRequest): Promise<rpc.Response<VerifyEmailResponse>> {
+    return await rpc.call<VerifyEmailResponse>('VerifyEmail', JSON.stringify(data));
+}
+
+export async function GetTwoFactorStatus(data: Empty): Promise<rpc.Response<TwoFactorStatusResponse>> {
+    return await rpc.call<TwoFactorStatusResponse>('GetTwoFactorStatus', JSON.stringify(data));
+}
+
+export async function BeginTwoFactorSetup(data: Empty): Promise<rpc.Response<BeginTwoFactorSetupResponse>> {
+    return await rpc.call<BeginTwoFactorSetupResponse>('BeginTwoFactorSetup', JSON.stringify(data));
+}
+
+export async function ConfirmTwoFactorSetup(data: TwoFactorCodeRequest): Promise<rpc.Response<RecoveryCodesResponse>> {
+    return await rpc.call<RecoveryCodesResponse>('ConfirmTwoFactorSetup', JSON.stringify(data));
+}
+
+export async function DisableTwoFactor(data: TwoFactorCodeRequest): Promise<rpc.Response<DisableTwoFactorResponse>> {
+    return await rpc.call<DisableTwoFactorResponse>('DisableTwoFactor', JSON.stringify(data));
+}
+
+export async function RegenerateRecoveryCodes(data: TwoFactorCodeRequest): Promise<rpc.Response<RecoveryCodesResponse>> {
+    return await rpc.call<RecoveryCodesResponse>('RegenerateRecoveryCodes', JSON.stringify(data));
+}
+
+export async function ListSessions(data: Empty): Promise<rpc.Response<ListSessionsResponse>> {
+    return await rpc.call<ListSessionsResponse>('ListSessions', JSON.stringify(data));
+}
+
+export async function RevokeSession(data: RevokeSessionRequest): Promise<rpc.Response<RevokeSessionResponse>> {
+    return await rpc.call<RevokeSessionResponse>('RevokeSession', JSON.stringify(data));
+}
+
+export async function SignOutEverywhere(data: Empty): Promise<rpc.Response<SignOutEverywhereResponse>> {
+    return await rpc.call<SignOutEverywhereResponse>('SignOutEverywhere', JSON.stringify(data));
+}
+
+export async function ListSigningKeys(data: Empty): Promise<rpc.Response<ListSigningKeysResponse>> {
+    return await rpc.call<ListSigningKeysResponse>('ListSigningKeys', JSON.stringify(data));
+}
+
+export async function RotateSigningKeys(data: Empty): Promise<rpc.Response<RotateSigningKeysResponse>> {
+    return await rpc.call<RotateSigningKeysResponse>('RotateSigningKeys', JSON.stringify(data));
+}
+
+export async function ListSecurityEvents(data: ListSecurityEventsRequest): Promise<rpc.Response<ListSecurityEventsResponse>> {
+    return await rpc.call<ListSecurityEventsResponse>('ListSecurityEvents', JSON.stringify(data));
+}
+
+export async function UnlockAccount(data: UnlockAccountRequest): Promise<rpc.Response<UnlockAccountResponse>> {
+    return await rpc.call<UnlockAccountResponse>('UnlockAccount', JSON.stringify(data));
+}
+
+export async function ExportAccountData(data: Empty): Promise<rpc.Response<ExportAccountDataResponse>> {
+    return await rpc.call<ExportAccountDataResponse>('ExportAccountData', JSON.stringify(data));
+}
+
+export async function DeleteAccount(data: DeleteAccountRequest): Promise<rpc.Response<DeleteAccountResponse>> {
+    return await rpc.call<DeleteAccountResponse>('DeleteAccount', JSON.stringify(data));
+}
+
 export async function SetUserRole(data: SetUserRoleRequest): Promise<rpc.Response<SetUserRoleResponse>> {
     return await rpc.call<SetUserRoleResponse>('SetUserRole', JSON.stringify(data));
 }
@@ -1040,6 +2283,70 @@ export async function LeaveStudio(data: LeaveStudioRequest): Promise<rpc.Respons
     return await rpc.call<LeaveStudioResponse>('LeaveStudio', JSON.stringify(data));
 }
 
+export async function InviteStudioMember(data: InviteStudioMemberRequest): Promise<rpc.Response<InviteStudioMemberResponse>> {
+    return await rpc.call<InviteStudioMemberResponse>('InviteStudioMember', JSON.stringify(data));
+}
+
+export async function ListStudioInvitations(data: ListStudioInvitationsRequest): Promise<rpc.Response<ListStudioInvitationsResponse>> {
+    return await rpc.call<ListStudioInvitationsResponse>('ListStudioInvitations', JSON.stringify(data));
+}
+
+export async function RevokeStudioInvitation(data: RevokeStudioInvitationRequest): Promise<rpc.Response<RevokeStudioInvitationResponse>> {
+    return await rpc.call<RevokeStudioInvitationResponse>('RevokeStudioInvitation', JSON.stringify(data));
+}
+
+export async function GetStudioInvitation(data: GetStudioInvitationRequest): Promise<rpc.Response<GetStudioInvitationResponse>> {
+    return await rpc.call<GetStudioInvitationResponse>('GetStudioInvitation', JSON.stringify(data));
+}
+
+export async function ListMyStudioInvitations(data: Empty): Promise<rpc.Response<ListMyStudioInvitationsResponse>> {
+    return await rpc.call<ListMyStudioInvitationsResponse>('ListMyStudioInvitations', JSON.stringify(data));
+}
+
+export async function AcceptStudioInvitation(data: RespondStudioInvitationRequest): Promise<rpc.Response<AcceptStudioInvitationResponse>> {
+    return await rpc.call<AcceptStudioInvitationResponse>('AcceptStudioInvitation', JSON.stringify(data));
+}
+
+export async function DeclineStudioInvitation(data: RespondStudioInvitationRequest): Promise<rpc.Response<DeclineStudioInvitationResponse>> {
+    return await rpc.call<DeclineStudioInvitationResponse>('DeclineStudioInvitation', JSON.stringify(data));
+}
+
+export async function OfferStudioOwnership(data: OfferStudioOwnershipRequest): Promise<rpc.Response<OfferStudioOwnershipResponse>> {
+    return await rpc.call<OfferStudioOwnershipResponse>('OfferStudioOwnership', JSON.stringify(data));
+}
+
+export async function CancelOwnershipTransfer(data: OwnershipTransferRequest): Promise<rpc.Response<OwnershipTransferResponse>> {
+    return await rpc.call<OwnershipTransferResponse>('CancelOwnershipTransfer', JSON.stringify(data));
+}
+
+export async function AcceptOwnershipTransfer(data: OwnershipTransferRequest): Promise<rpc.Response<OwnershipTransferResponse>> {
+    return await rpc.call<OwnershipTransferResponse>('AcceptOwnershipTransfer', JSON.stringify(data));
+}
+
+export async function DeclineOwnershipTransfer(data: OwnershipTransferRequest): Promise<rpc.Response<OwnershipTransferResponse>> {
+    return await rpc.call<OwnershipTransferResponse>('DeclineOwnershipTransfer', JSON.stringify(data));
+}
+
+export async function ListOwnershipTransfers(data: ListOwnershipTransfersRequest): Promise<rpc.Response<ListOwnershipTransfersResponse>> {
+    return await rpc.call<ListOwnershipTransfersResponse>('ListOwnershipTransfers', JSON.stringify(data));
+}
+
+export async function ListStudioRoles(data: ListStudioRolesRequest): Promise<rpc.Response<ListStudioRolesResponse>> {
+    return await rpc.call<ListStudioRolesResponse>('ListStudioRoles', JSON.stringify(data));
+}
+
+export async function SaveStudioCustomRole(data: SaveStudioCustomRoleRequest): Promise<rpc.Response<SaveStudioCustomRoleResponse>> {
+    return await rpc.call<SaveStudioCustomRoleResponse>('SaveStudioCustomRole', JSON.stringify(data));
+}
+
+export async function DeleteStudioCustomRole(data: DeleteStudioCustomRoleRequest): Promise<rpc.Response<DeleteStudioCustomRoleResponse>> {
+    return await rpc.call<DeleteStudioCustomRoleResponse>('DeleteStudioCustomRole', JSON.stringify(data));
+}
+
+export async function SetMemberCustomRole(data: SetMemberCustomRoleRequest): Promise<rpc.Response<SetMemberCustomRoleResponse>> {
+    return await rpc.call<SetMemberCustomRoleResponse>('SetMemberCustomRole', JSON.stringify(data));
+}
+
 export async function GenerateAccessCode(data: GenerateAccessCodeRequest): Promise<rpc.Response<GenerateAccessCodeResponse>> {
     return await rpc.call<GenerateAccessCodeResponse>('GenerateAccessCode', JSON.stringify(data));
 }
@@ -1060,6 +2367,14 @@ export async function GetCodeAnalytics(data: GetCodeAnalyticsRequest): Promise<r
     return await rpc.call<GetCodeAnalyticsResponse>('GetCodeAnalytics', JSON.stringify(data));
 }
 
+export async function GetCodeDevices(data: GetCodeDevicesRequest): Promise<rpc.Response<GetCodeDevicesResponse>> {
+    return await rpc.call<GetCodeDevicesResponse>('GetCodeDevices', JSON.stringify(data));
+}
+
+export async function KickCodeSession(data: KickCodeSessionRequest): Promise<rpc.Response<KickCodeSessionResponse>> {
+    return await rpc.call<KickCodeSessionResponse>('KickCodeSession', JSON.stringify(data));
+}
+
 export async function SetCameraConfig(data: SetCameraConfigRequest): Promise<rpc.Response<SetCameraConfigResponse>> {
     return await rpc.call<SetCameraConfigResponse>('SetCameraConfig', JSON.stringify(data));
 }
@@ -1084,10 +2399,6 @@ export async function ReportStreamMetrics(data: ReportStreamMetricsRequest): Pro
     return await rpc.call<ReportStreamMetricsResponse>('ReportStreamMetrics', JSON.stringify(data));
 }
 
-export async function RecalculateViewerCounts(data: RecalculateViewerCountsRequest): Promise<rpc.Response<RecalculateViewerCountsResponse>> {
-    return await rpc.call<RecalculateViewerCountsResponse>('RecalculateViewerCounts', JSON.stringify(data));
-}
-
 export async function GetSystemLogs(data: GetSystemLogsRequest): Promise<rpc.Response<GetSystemLogsResponse>> {
     return await rpc.call<GetSystemLogsResponse>('GetSystemLogs', JSON.stringify(data));
 }
@@ -1116,6 +2427,122 @@ export async function GetChatHistory(data: GetChatHistoryRequest): Promise<rpc.R
     return await rpc.call<GetChatHistoryResponse>('GetChatHistory', JSON.stringify(data));
 }
 
+export async function SetChatDisplayName(data: SetChatDisplayNameRequest): Promise<rpc.Response<SetChatDisplayNameResponse>> {
+    return await rpc.call<SetChatDisplayNameResponse>('SetChatDisplayName', JSON.stringify(data));
+}
+
+export async function DeleteChatMessage(data: DeleteChatMessageRequest): Promise<rpc.Response<DeleteChatMessageResponse>> {
+    return await rpc.call<DeleteChatMessageResponse>('DeleteChatMessage', JSON.stringify(data));
+}
+
+export async function RestrictChatViewer(data: RestrictChatViewerRequest): Promise<rpc.Response<RestrictChatViewerResponse>> {
+    return await rpc.call<RestrictChatViewerResponse>('RestrictChatViewer', JSON.stringify(data));
+}
+
+export async function LiftChatRestriction(data: LiftChatRestrictionRequest): Promise<rpc.Response<LiftChatRestrictionResponse>> {
+    return await rpc.call<LiftChatRestrictionResponse>('LiftChatRestriction', JSON.stringify(data));
+}
+
+export async function ListChatRestrictions(data: ListChatRestrictionsRequest): Promise<rpc.Response<ListChatRestrictionsResponse>> {
+    return await rpc.call<ListChatRestrictionsResponse>('ListChatRestrictions', JSON.stringify(data));
+}
+
+export async function UpdateChatSettings(data: UpdateChatSettingsRequest): Promise<rpc.Response<UpdateChatSettingsResponse>> {
+    return await rpc.call<UpdateChatSettingsResponse>('UpdateChatSettings', JSON.stringify(data));
+}
+
+export async function GetChatFilterSettings(data: GetChatFilterSettingsRequest): Promise<rpc.Response<GetChatFilterSettingsResponse>> {
+    return await rpc.call<GetChatFilterSettingsResponse>('GetChatFilterSettings', JSON.stringify(data));
+}
+
+export async function UpdateChatFilterSettings(data: UpdateChatFilterSettingsRequest): Promise<rpc.Response<UpdateChatFilterSettingsResponse>> {
+    return await rpc.call<UpdateChatFilterSettingsResponse>('UpdateChatFilterSettings', JSON.stringify(data));
+}
+
+export async function ListChatFilterLog(data: ListChatFilterLogRequest): Promise<rpc.Response<ListChatFilterLogResponse>> {
+    return await rpc.call<ListChatFilterLogResponse>('ListChatFilterLog', JSON.stringify(data));
+}
+
+export async function ReviewHeldChatMessage(data: ReviewHeldChatMessageRequest): Promise<rpc.Response<ReviewHeldChatMessageResponse>> {
+    return await rpc.call<ReviewHeldChatMessageResponse>('ReviewHeldChatMessage', JSON.stringify(data));
+}
+
+export async function GetChatArchiveSettings(data: GetChatArchiveSettingsRequest): Promise<rpc.Response<GetChatArchiveSettingsResponse>> {
+    return await rpc.call<GetChatArchiveSettingsResponse>('GetChatArchiveSettings', JSON.stringify(data));
+}
+
+export async function UpdateChatArchiveSettings(data: UpdateChatArchiveSettingsRequest): Promise<rpc.Response<UpdateChatArchiveSettingsResponse>> {
+    return await rpc.call<UpdateChatArchiveSettingsResponse>('UpdateChatArchiveSettings', JSON.stringify(data));
+}
+
+export async function ListChatArchives(data: ListChatArchivesRequest): Promise<rpc.Response<ListChatArchivesResponse>> {
+    return await rpc.call<ListChatArchivesResponse>('ListChatArchives', JSON.stringify(data));
+}
+
+export async function GetStreamChatReplay(data: GetStreamChatReplayRequest): Promise<rpc.Response<GetStreamChatReplayResponse>> {
+    return await rpc.call<GetStreamChatReplayResponse>('GetStreamChatReplay', JSON.stringify(data));
+}
+
+export async function ExportChatTranscript(data: ExportChatTranscriptRequest): Promise<rpc.Response<ExportChatTranscriptResponse>> {
+    return await rpc.call<ExportChatTranscriptResponse>('ExportChatTranscript', JSON.stringify(data));
+}
+
+export async function ToggleChatReaction(data: ToggleChatReactionRequest): Promise<rpc.Response<ToggleChatReactionResponse>> {
+    return await rpc.call<ToggleChatReactionResponse>('ToggleChatReaction', JSON.stringify(data));
+}
+
+export async function SetChatPinnedAnnouncement(data: SetChatPinnedAnnouncementRequest): Promise<rpc.Response<SetChatPinnedAnnouncementResponse>> {
+    return await rpc.call<SetChatPinnedAnnouncementResponse>('SetChatPinnedAnnouncement', JSON.stringify(data));
+}
+
+export async function GetRoomEmotes(data: GetRoomEmotesRequest): Promise<rpc.Response<GetRoomEmotesResponse>> {
+    return await rpc.call<GetRoomEmotesResponse>('GetRoomEmotes', JSON.stringify(data));
+}
+
+export async function GetStudioEmoteSet(data: GetStudioEmoteSetRequest): Promise<rpc.Response<GetStudioEmoteSetResponse>> {
+    return await rpc.call<GetStudioEmoteSetResponse>('GetStudioEmoteSet', JSON.stringify(data));
+}
+
+export async function UpdateStudioEmoteSet(data: UpdateStudioEmoteSetRequest): Promise<rpc.Response<UpdateStudioEmoteSetResponse>> {
+    return await rpc.call<UpdateStudioEmoteSetResponse>('UpdateStudioEmoteSet', JSON.stringify(data));
+}
+
+export async function UploadStudioEmote(data: UploadStudioEmoteRequest): Promise<rpc.Response<UploadStudioEmoteResponse>> {
+    return await rpc.call<UploadStudioEmoteResponse>('UploadStudioEmote', JSON.stringify(data));
+}
+
+export async function ListStreamApplause(data: ListStreamApplauseRequest): Promise<rpc.Response<ListStreamApplauseResponse>> {
+    return await rpc.call<ListStreamApplauseResponse>('ListStreamApplause', JSON.stringify(data));
+}
+
+export async function CreatePoll(data: CreatePollRequest): Promise<rpc.Response<PollResponse>> {
+    return await rpc.call<PollResponse>('CreatePoll', JSON.stringify(data));
+}
+
+export async function ClosePoll(data: ClosePollRequest): Promise<rpc.Response<PollResponse>> {
+    return await rpc.call<PollResponse>('ClosePoll', JSON.stringify(data));
+}
+
+export async function VotePoll(data: VotePollRequest): Promise<rpc.Response<PollResponse>> {
+    return await rpc.call<PollResponse>('VotePoll', JSON.stringify(data));
+}
+
+export async function ListRoomPolls(data: ListRoomPollsRequest): Promise<rpc.Response<ListRoomPollsResponse>> {
+    return await rpc.call<ListRoomPollsResponse>('ListRoomPolls', JSON.stringify(data));
+}
+
+export async function AskClassQuestion(data: AskClassQuestionRequest): Promise<rpc.Response<ClassQuestionResponse>> {
+    return await rpc.call<ClassQuestionResponse>('AskClassQuestion', JSON.stringify(data));
+}
+
+export async function SetClassQuestionStatus(data: SetClassQuestionStatusRequest): Promise<rpc.Response<ClassQuestionResponse>> {
+    return await rpc.call<ClassQuestionResponse>('SetClassQuestionStatus', JSON.stringify(data));
+}
+
+export async function ListClassQuestions(data: ListClassQuestionsRequest): Promise<rpc.Response<ListClassQuestionsResponse>> {
+    return await rpc.call<ListClassQuestionsResponse>('ListClassQuestions', JSON.stringify(data));
+}
+
 export async function CreateClassSchedule(data: CreateClassScheduleRequest): Promise<rpc.Response<CreateClassScheduleResponse>> {
     return await rpc.call<CreateClassScheduleResponse>('CreateClassSchedule', JSON.stringify(data));
 }
@@ -1156,6 +2583,58 @@ export async function ListMyUpcomingClasses(data: ListMyUpcomingClassesRequest):
     return await rpc.call<ListMyUpcomingClassesResponse>('ListMyUpcomingClasses', JSON.stringify(data));
 }
 
+export async function CreateTicketProduct(data: CreateTicketProductRequest): Promise<rpc.Response<CreateTicketProductResponse>> {
+    return await rpc.call<CreateTicketProductResponse>('CreateTicketProduct', JSON.stringify(data));
+}
+
+export async function UpdateTicketProduct(data: UpdateTicketProductRequest): Promise<rpc.Response<UpdateTicketProductResponse>> {
+    return await rpc.call<UpdateTicketProductResponse>('UpdateTicketProduct', JSON.stringify(data));
+}
+
+export async function ListTicketProducts(data: ListTicketProductsRequest): Promise<rpc.Response<ListTicketProductsResponse>> {
+    return await rpc.call<ListTicketProductsResponse>('ListTicketProducts', JSON.stringify(data));
+}
+
+export async function GetTicketInfo(data: GetTicketInfoRequest): Promise<rpc.Response<GetTicketInfoResponse>> {
+    return await rpc.call<GetTicketInfoResponse>('GetTicketInfo', JSON.stringify(data));
+}
+
+export async function PurchaseTicket(data: PurchaseTicketRequest): Promise<rpc.Response<PurchaseTicketResponse>> {
+    return await rpc.call<PurchaseTicketResponse>('PurchaseTicket', JSON.stringify(data));
+}
+
+export async function ListTicketPurchases(data: ListTicketPurchasesRequest): Promise<rpc.Response<ListTicketPurchasesResponse>> {
+    return await rpc.call<ListTicketPurchasesResponse>('ListTicketPurchases', JSON.stringify(data));
+}
+
+export async function RefundTicketPurchase(data: RefundTicketPurchaseRequest): Promise<rpc.Response<RefundTicketPurchaseResponse>> {
+    return await rpc.call<RefundTicketPurchaseResponse>('RefundTicketPurchase', JSON.stringify(data));
+}
+
+export async function ResendTicketEmail(data: ResendTicketEmailRequest): Promise<rpc.Response<ResendTicketEmailResponse>> {
+    return await rpc.call<ResendTicketEmailResponse>('ResendTicketEmail', JSON.stringify(data));
+}
+
+export async function CreateInviteLink(data: CreateInviteLinkRequest): Promise<rpc.Response<CreateInviteLinkResponse>> {
+    return await rpc.call<CreateInviteLinkResponse>('CreateInviteLink', JSON.stringify(data));
+}
+
+export async function ListInviteLinks(data: ListInviteLinksRequest): Promise<rpc.Response<ListInviteLinksResponse>> {
+    return await rpc.call<ListInviteLinksResponse>('ListInviteLinks', JSON.stringify(data));
+}
+
+export async function RevokeInviteLink(data: RevokeInviteLinkRequest): Promise<rpc.Response<RevokeInviteLinkResponse>> {
+    return await rpc.call<RevokeInviteLinkResponse>('RevokeInviteLink', JSON.stringify(data));
+}
+
+export async function GetCodeWatchReport(data: GetCodeWatchReportRequest): Promise<rpc.Response<GetCodeWatchReportResponse>> {
+    return await rpc.call<GetCodeWatchReportResponse>('GetCodeWatchReport', JSON.stringify(data));
+}
+
+export async function GetClassWatchReport(data: GetClassWatchReportRequest): Promise<rpc.Response<GetClassWatchReportResponse>> {
+    return await rpc.call<GetClassWatchReportResponse>('GetClassWatchReport', JSON.stringify(data));
+}
+
 export async function GetTranscoderHealth(data: GetTranscoderHealthRequest): Promise<rpc.Response<TranscoderHealthResponse>> {
     return await rpc.call<TranscoderHealthResponse>('GetTranscoderHealth', JSON.stringify(data));
 }